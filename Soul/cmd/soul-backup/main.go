@@ -0,0 +1,200 @@
+// Command soul-backup writes a consistent logical backup of the Soul
+// database (users, souls, sessions, messages, episodes and relations) to a
+// gzipped JSON lines file or an S3 compatible bucket, and restores one.
+//
+// It reads DB_DSN, and the BLOB_* settings for s3:// locations:
+//
+//	soul-backup -o backup.jsonl.gz
+//	soul-backup -o s3://soul-backups/demo-01/2026-10-16.jsonl.gz
+//	soul-backup -restore -conflict overwrite s3://soul-backups/demo-01/2026-10-16.jsonl.gz
+//
+// A restore runs in one transaction; -conflict decides what happens to rows
+// that already exist: skip keeps them (default), overwrite replaces them
+// with the backed up ones and fail aborts without changing anything.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"soul/internal/blob"
+	"soul/internal/db"
+)
+
+func main() {
+	var (
+		out      = flag.String("o", "", "backup destination: a file or s3://bucket/key (default soul-backup-<time>.jsonl.gz)")
+		restore  = flag.Bool("restore", false, "restore the backup named by the argument instead of writing one")
+		conflict = flag.String("conflict", "skip", "on restore, what to do with existing rows: skip, overwrite or fail")
+		dsn      = flag.String("dsn", os.Getenv("DB_DSN"), "database DSN")
+		maxBytes = flag.Int64("max-bytes", 2<<30, "largest backup read from or written to S3, which is held in memory")
+	)
+	flag.Parse()
+	if *dsn == "" {
+		fmt.Fprintln(os.Stderr, "DB_DSN or -dsn is required")
+		os.Exit(2)
+	}
+	if *restore != (flag.NArg() == 1) {
+		fmt.Fprintln(os.Stderr, "usage: soul-backup [-o FILE|s3://bucket/key] | soul-backup -restore [-conflict skip|overwrite|fail] FILE|s3://bucket/key")
+		os.Exit(2)
+	}
+	strategy, err := db.ParseRestoreConflict(*conflict)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	store, err := db.New(ctx, *dsn, db.PoolOptions{MaxConns: 2})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "connect db:", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	if *restore {
+		err = runRestore(ctx, store, flag.Arg(0), strategy, *maxBytes)
+	} else {
+		dest := *out
+		if dest == "" {
+			dest = "soul-backup-" + time.Now().UTC().Format("20060102-150405") + ".jsonl.gz"
+		}
+		err = runBackup(ctx, store, dest, *maxBytes)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func runBackup(ctx context.Context, store *db.Store, dest string, maxBytes int64) error {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var file *os.File
+	bucket, key, toS3 := parseS3(dest)
+	if !toS3 {
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		file, w = f, f
+	}
+
+	zw := gzip.NewWriter(w)
+	counts, err := store.Backup(ctx, zw)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		if file != nil {
+			_ = os.Remove(dest)
+		}
+		return fmt.Errorf("backup: %w", err)
+	}
+	if toS3 {
+		if int64(buf.Len()) > maxBytes {
+			return fmt.Errorf("backup is %d bytes, over -max-bytes", buf.Len())
+		}
+		client, err := s3Client(bucket, key)
+		if err != nil {
+			return err
+		}
+		if err := client.PutObject(ctx, key, buf.Bytes()); err != nil {
+			return fmt.Errorf("upload backup: %w", err)
+		}
+	} else if err := file.Sync(); err != nil {
+		return err
+	}
+	printCounts("backed up to "+dest, counts)
+	return nil
+}
+
+func runRestore(ctx context.Context, store *db.Store, src string, conflict db.RestoreConflict, maxBytes int64) error {
+	var r io.Reader
+	if bucket, key, fromS3 := parseS3(src); fromS3 {
+		client, err := s3Client(bucket, key)
+		if err != nil {
+			return err
+		}
+		data, err := client.GetObject(ctx, key, maxBytes)
+		if err != nil {
+			return fmt.Errorf("download backup: %w", err)
+		}
+		r = bytes.NewReader(data)
+	} else {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("open backup: %w", err)
+	}
+	defer zr.Close()
+
+	// Older backups may miss columns added since; Migrate brings the
+	// schema up to date first so they restore with their defaults.
+	if err := store.Migrate(ctx); err != nil {
+		return fmt.Errorf("migrate db: %w", err)
+	}
+	res, err := store.Restore(ctx, zr, conflict)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	fmt.Println("restored from " + src + " (" + string(conflict) + ")")
+	fmt.Printf("  %-20s %8s %8s %8s\n", "table", "restored", "skipped", "removed")
+	for _, table := range db.BackupTables() {
+		fmt.Printf("  %-20s %8d %8d %8d\n", table, res.Restored[table], res.Skipped[table], res.Removed[table])
+	}
+	return nil
+}
+
+// parseS3 splits s3://bucket/key.
+func parseS3(location string) (bucket, key string, ok bool) {
+	rest, ok := strings.CutPrefix(location, "s3://")
+	if !ok {
+		return "", "", false
+	}
+	bucket, key, _ = strings.Cut(rest, "/")
+	return bucket, key, true
+}
+
+// s3Client reaches bucket with the media storage settings of soul-server.
+func s3Client(bucket, key string) (*blob.S3Client, error) {
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("s3 locations take the form s3://bucket/key")
+	}
+	if os.Getenv("BLOB_ENDPOINT") == "" {
+		return nil, fmt.Errorf("BLOB_ENDPOINT, BLOB_ACCESS_KEY and BLOB_SECRET_KEY are required for s3:// locations")
+	}
+	return blob.NewS3Client(blob.S3Config{
+		Endpoint:  os.Getenv("BLOB_ENDPOINT"),
+		Region:    os.Getenv("BLOB_REGION"),
+		Bucket:    bucket,
+		AccessKey: os.Getenv("BLOB_ACCESS_KEY"),
+		SecretKey: os.Getenv("BLOB_SECRET_KEY"),
+		PathStyle: os.Getenv("BLOB_PATH_STYLE") != "false",
+		Timeout:   10 * time.Minute,
+	})
+}
+
+func printCounts(title string, counts map[string]int) {
+	fmt.Println(title)
+	for _, table := range db.BackupTables() {
+		fmt.Printf("  %-20s %d\n", table, counts[table])
+	}
+}
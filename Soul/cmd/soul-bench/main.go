@@ -0,0 +1,191 @@
+// Command soul-bench drives a running soul-server with synthetic MQTT
+// terminals and concurrent /v1/chat requests, then prints latency percentiles
+// and error rates. Point soul-server at the built-in stubs to take the LLM and
+// emotion service out of the measurement:
+//
+//	OPENAI_BASE_URL=http://localhost:19090/v1 EMOTION_BASE_URL=http://localhost:19090 soul-server
+//	soul-bench -terminals 8 -concurrency 16 -requests 400
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"soul/internal/domain"
+)
+
+func main() {
+	var (
+		serverURL      = flag.String("server", "http://localhost:9010", "soul-server base URL")
+		brokerURL      = flag.String("broker", "tcp://localhost:1883", "MQTT broker URL")
+		topicPrefix    = flag.String("topic-prefix", "soul", "MQTT topic prefix")
+		userID         = flag.String("user", "", "user_id sent with chat requests (server default when empty)")
+		soulID         = flag.String("soul", "", "soul_id to chat with; a bench soul is created when empty")
+		terminals      = flag.Int("terminals", 4, "number of synthetic terminals")
+		concurrency    = flag.Int("concurrency", 8, "concurrent chat requests")
+		requests       = flag.Int("requests", 200, "total chat requests")
+		timeout        = flag.Duration("timeout", 30*time.Second, "per-request timeout")
+		stubAddr       = flag.String("stub-addr", ":19090", "listen address for LLM/emotion stubs; empty disables them")
+		llmLatency     = flag.Duration("stub-llm-latency", 300*time.Millisecond, "simulated LLM latency")
+		emotionLatency = flag.Duration("stub-emotion-latency", 30*time.Millisecond, "simulated emotion service latency")
+		toolEvery      = flag.Int64("stub-tool-every", 5, "every Nth LLM call returns a bench_echo tool call; 0 disables")
+	)
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *terminals <= 0 || *concurrency <= 0 || *requests <= 0 {
+		logger.Error("terminals, concurrency and requests must be positive")
+		os.Exit(2)
+	}
+
+	if strings.TrimSpace(*stubAddr) != "" {
+		stubs := &stubServer{llmLatency: *llmLatency, emotionLatency: *emotionLatency, toolEvery: *toolEvery}
+		stubHTTP := &http.Server{Addr: *stubAddr, Handler: stubs.routes(), ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := stubHTTP.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("stub server failed", "error", err)
+				os.Exit(1)
+			}
+		}()
+		defer stubHTTP.Close()
+		logger.Info("llm/emotion stubs listening", "addr", *stubAddr)
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	runID := uuid.NewString()[:8]
+	base := strings.TrimRight(*serverURL, "/")
+
+	if strings.TrimSpace(*soulID) == "" {
+		created, err := createBenchSoul(ctx, client, base, *userID, "bench-"+runID)
+		if err != nil {
+			logger.Error("create bench soul failed", "error", err)
+			os.Exit(1)
+		}
+		*soulID = created
+		logger.Info("bench soul created", "soul_id", created)
+	}
+
+	counters := &mqttCounters{}
+	fleet := make([]*syntheticTerminal, 0, *terminals)
+	for i := 0; i < *terminals; i++ {
+		t, err := startSyntheticTerminal(*brokerURL, *topicPrefix, fmt.Sprintf("bench-%s-%02d", runID, i), counters)
+		if err != nil {
+			logger.Error("start synthetic terminal failed", "error", err)
+			os.Exit(1)
+		}
+		fleet = append(fleet, t)
+	}
+	defer func() {
+		for _, t := range fleet {
+			t.stop()
+		}
+	}()
+	// Give soul-server time to ingest the skill reports.
+	time.Sleep(time.Second)
+
+	results := make([]chatResult, *requests)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= *requests || ctx.Err() != nil {
+					return
+				}
+				terminal := fleet[i%len(fleet)]
+				results[i] = sendChat(ctx, client, base, domain.ChatRequest{
+					UserID:     *userID,
+					SessionID:  "bench-" + runID + "-" + terminal.id,
+					TerminalID: terminal.id,
+					SoulID:     *soulID,
+					Inputs: []domain.ChatInput{{
+						Type: "keyboard_text",
+						Text: fmt.Sprintf("压测消息 %d", i),
+					}},
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	done := int(min(next.Load(), int64(*requests)))
+	buildReport(results[:done], elapsed, counters).write(os.Stdout)
+}
+
+func sendChat(ctx context.Context, client *http.Client, base string, payload domain.ChatRequest) chatResult {
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/v1/chat", bytes.NewReader(body))
+	if err != nil {
+		return chatResult{class: errorTransport}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return chatResult{latency: time.Since(start), class: errorTransport}
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	latency := time.Since(start)
+
+	switch {
+	case resp.StatusCode >= 500:
+		var out struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(respBody, &out)
+		return chatResult{latency: latency, class: classifyServerError(out.Error)}
+	case resp.StatusCode >= 400:
+		return chatResult{latency: latency, class: errorClient}
+	default:
+		return chatResult{latency: latency}
+	}
+}
+
+func createBenchSoul(ctx context.Context, client *http.Client, base, userID, name string) (string, error) {
+	body, _ := json.Marshal(domain.CreateSoulPayload{UserID: userID, Name: name, MBTIType: "INTJ"})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/v1/souls", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	var profile domain.SoulProfile
+	if err := json.Unmarshal(respBody, &profile); err != nil {
+		return "", err
+	}
+	return profile.SoulID, nil
+}
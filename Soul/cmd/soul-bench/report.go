@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type errorClass string
+
+const (
+	errorTransport errorClass = "transport"
+	errorClient    errorClass = "http_4xx"
+	errorDB        errorClass = "db"
+	errorMQTT      errorClass = "mqtt"
+	errorServer    errorClass = "server_other"
+)
+
+type chatResult struct {
+	latency time.Duration
+	class   errorClass
+}
+
+// classifyServerError maps a soul-server error body onto the failure source
+// it most likely came from.
+func classifyServerError(message string) errorClass {
+	msg := strings.ToLower(message)
+	switch {
+	case strings.Contains(msg, "sqlstate"),
+		strings.Contains(msg, "pgx"),
+		strings.Contains(msg, "database"),
+		strings.Contains(msg, "failed to connect"),
+		strings.Contains(msg, "conn closed"):
+		return errorDB
+	case strings.Contains(msg, "mqtt"),
+		strings.Contains(msg, "tool timeout"),
+		strings.Contains(msg, "not connected"):
+		return errorMQTT
+	default:
+		return errorServer
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+type benchReport struct {
+	total     int
+	elapsed   time.Duration
+	latencies []time.Duration
+	errors    map[errorClass]int
+	invokes   int64
+	mqttPub   int64
+}
+
+func buildReport(results []chatResult, elapsed time.Duration, counters *mqttCounters) benchReport {
+	out := benchReport{
+		total:   len(results),
+		elapsed: elapsed,
+		errors:  map[errorClass]int{},
+	}
+	for _, r := range results {
+		if r.class != "" {
+			out.errors[r.class]++
+			continue
+		}
+		out.latencies = append(out.latencies, r.latency)
+	}
+	sort.Slice(out.latencies, func(i, j int) bool { return out.latencies[i] < out.latencies[j] })
+	if counters != nil {
+		out.invokes = counters.invokes.Load()
+		out.mqttPub = counters.publishErrors.Load()
+	}
+	return out
+}
+
+func (r benchReport) rate(n int) float64 {
+	if r.total == 0 {
+		return 0
+	}
+	return float64(n) / float64(r.total) * 100
+}
+
+func (r benchReport) write(w io.Writer) {
+	failed := r.total - len(r.latencies)
+	fmt.Fprintf(w, "requests:     %d in %s (%.1f req/s)\n", r.total, r.elapsed.Round(time.Millisecond), float64(r.total)/math.Max(r.elapsed.Seconds(), 1e-9))
+	fmt.Fprintf(w, "succeeded:    %d\n", len(r.latencies))
+	fmt.Fprintf(w, "failed:       %d (%.2f%%)\n", failed, r.rate(failed))
+	fmt.Fprintf(w, "latency p50:  %s\n", percentile(r.latencies, 50).Round(time.Millisecond))
+	fmt.Fprintf(w, "latency p95:  %s\n", percentile(r.latencies, 95).Round(time.Millisecond))
+	fmt.Fprintf(w, "latency p99:  %s\n", percentile(r.latencies, 99).Round(time.Millisecond))
+	for _, class := range []errorClass{errorDB, errorMQTT, errorServer, errorClient, errorTransport} {
+		fmt.Fprintf(w, "errors %-13s %d (%.2f%%)\n", string(class)+":", r.errors[class], r.rate(r.errors[class]))
+	}
+	fmt.Fprintf(w, "mqtt invokes: %d, terminal publish errors: %d\n", r.invokes, r.mqttPub)
+}
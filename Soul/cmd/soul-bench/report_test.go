@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 0, 20)
+	for i := 1; i <= 20; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	if got := percentile(sorted, 50); got != 10*time.Millisecond {
+		t.Fatalf("p50 = %s", got)
+	}
+	if got := percentile(sorted, 95); got != 19*time.Millisecond {
+		t.Fatalf("p95 = %s", got)
+	}
+	if got := percentile(nil, 95); got != 0 {
+		t.Fatalf("empty p95 = %s", got)
+	}
+}
+
+func TestClassifyServerError(t *testing.T) {
+	cases := map[string]errorClass{
+		`ERROR: relation "messages" does not exist (SQLSTATE 42P01)`: errorDB,
+		"tool timeout":                errorMQTT,
+		"openai status=500 body=oops": errorServer,
+	}
+	for msg, want := range cases {
+		if got := classifyServerError(msg); got != want {
+			t.Fatalf("classify(%q) = %s, want %s", msg, got, want)
+		}
+	}
+}
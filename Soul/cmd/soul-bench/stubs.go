@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const benchSkillName = "bench_echo"
+
+// stubServer answers the OpenAI-compatible chat endpoint and the emotion
+// service with canned payloads, so soul-server can be benchmarked without
+// external model latency or cost.
+type stubServer struct {
+	llmLatency     time.Duration
+	emotionLatency time.Duration
+	toolEvery      int64
+	llmCalls       atomic.Int64
+}
+
+func (s *stubServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", s.handleChatCompletions)
+	mux.HandleFunc("POST /chat/completions", s.handleChatCompletions)
+	mux.HandleFunc("POST /v1/emotion/analyze", s.handleEmotion)
+	return mux
+}
+
+func (s *stubServer) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Messages []struct {
+			Role string `json:"role"`
+		} `json:"messages"`
+		Tools []struct {
+			Function struct {
+				Name string `json:"name"`
+			} `json:"function"`
+		} `json:"tools"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":{"message":"invalid json"}}`, http.StatusBadRequest)
+		return
+	}
+	time.Sleep(s.llmLatency)
+	n := s.llmCalls.Add(1)
+
+	lastRole := ""
+	if len(req.Messages) > 0 {
+		lastRole = req.Messages[len(req.Messages)-1].Role
+	}
+	hasBenchSkill := false
+	for _, t := range req.Tools {
+		if t.Function.Name == benchSkillName {
+			hasBenchSkill = true
+			break
+		}
+	}
+
+	message := map[string]any{"role": "assistant", "content": "好的，收到。"}
+	if lastRole != "tool" && hasBenchSkill && s.toolEvery > 0 && n%s.toolEvery == 0 {
+		message["content"] = ""
+		message["tool_calls"] = []any{map[string]any{
+			"id":   "call_bench_" + time.Now().Format("150405.000000"),
+			"type": "function",
+			"function": map[string]any{
+				"name":      benchSkillName,
+				"arguments": `{"text":"ping"}`,
+			},
+		}}
+	}
+	writeStubJSON(w, map[string]any{
+		"choices": []any{map[string]any{"message": message}},
+	})
+}
+
+func (s *stubServer) handleEmotion(w http.ResponseWriter, _ *http.Request) {
+	time.Sleep(s.emotionLatency)
+	writeStubJSON(w, map[string]any{
+		"emotion":   "neutral",
+		"p":         0.1,
+		"a":         0.1,
+		"d":         0.0,
+		"intensity": 0.2,
+	})
+}
+
+func writeStubJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"soul/internal/domain"
+	"soul/internal/mqtt"
+)
+
+type mqttCounters struct {
+	invokes       atomic.Int64
+	publishErrors atomic.Int64
+}
+
+// syntheticTerminal reports a single echo skill and answers every invoke
+// immediately, standing in for a device during load tests.
+type syntheticTerminal struct {
+	id       string
+	prefix   string
+	client   paho.Client
+	counters *mqttCounters
+}
+
+func startSyntheticTerminal(brokerURL, prefix, terminalID string, counters *mqttCounters) (*syntheticTerminal, error) {
+	opts := paho.NewClientOptions().
+		AddBroker(brokerURL).
+		SetClientID(terminalID).
+		SetAutoReconnect(true).
+		SetConnectTimeout(5 * time.Second)
+	client := paho.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, fmt.Errorf("connect %s: %w", terminalID, token.Error())
+	}
+
+	t := &syntheticTerminal{id: terminalID, prefix: prefix, client: client, counters: counters}
+	invokeTopic := fmt.Sprintf("%s/terminal/%s/invoke/+", prefix, terminalID)
+	if token := client.Subscribe(invokeTopic, 1, t.handleInvoke); token.Wait() && token.Error() != nil {
+		client.Disconnect(100)
+		return nil, fmt.Errorf("subscribe %s: %w", terminalID, token.Error())
+	}
+
+	report := domain.SkillReport{
+		TerminalID:   terminalID,
+		SkillVersion: 1,
+		Skills: []domain.SkillDefinition{{
+			Name:        benchSkillName,
+			Description: "压测用回显技能",
+			InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`),
+		}},
+	}
+	body, _ := json.Marshal(report)
+	if err := t.publish(mqtt.TopicSkills(prefix, terminalID), body); err != nil {
+		client.Disconnect(100)
+		return nil, err
+	}
+	if err := t.publish(mqtt.TopicOnline(prefix, terminalID), []byte("online")); err != nil {
+		client.Disconnect(100)
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *syntheticTerminal) handleInvoke(_ paho.Client, msg paho.Message) {
+	t.counters.invokes.Add(1)
+	var req domain.InvokeRequest
+	if err := json.Unmarshal(msg.Payload(), &req); err != nil {
+		t.counters.publishErrors.Add(1)
+		return
+	}
+	if req.RequestID == "" {
+		req.RequestID = mqtt.ParseRequestID(msg.Topic())
+	}
+	body, _ := json.Marshal(domain.InvokeResult{RequestID: req.RequestID, OK: true, Output: "echo"})
+	_ = t.publish(mqtt.TopicResult(t.prefix, t.id, req.RequestID), body)
+}
+
+func (t *syntheticTerminal) publish(topic string, body []byte) error {
+	token := t.client.Publish(topic, 1, false, body)
+	if token.WaitTimeout(5*time.Second) && token.Error() == nil {
+		return nil
+	}
+	t.counters.publishErrors.Add(1)
+	if token.Error() != nil {
+		return fmt.Errorf("publish %s: %w", topic, token.Error())
+	}
+	return fmt.Errorf("publish %s: timeout", topic)
+}
+
+func (t *syntheticTerminal) stop() {
+	_ = t.publish(mqtt.TopicOnline(t.prefix, t.id), []byte("offline"))
+	t.client.Disconnect(250)
+}
@@ -0,0 +1,224 @@
+// Command soul-calibrate replays recorded user emotion traces through the
+// persona engine for every MBTI type and a grid of lock settings, reports
+// how much of the time the execution gate would be blocked, and writes the
+// setting closest to the target share as a persona config file.
+//
+// Traces are JSON lines of {"trace","at","emotion","p","a","d","intensity"};
+// soul-server writes them when EMOTION_TRACE_FILE is set. Load the written
+// config with PERSONA_CONFIG_FILE.
+//
+//	soul-calibrate -target 0.05 -out persona-config.json emotion-traces.jsonl
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/persona"
+)
+
+var mbtiTypes = []string{
+	"INTJ", "INTP", "ENTJ", "ENTP",
+	"INFJ", "INFP", "ENFJ", "ENFP",
+	"ISTJ", "ISFJ", "ESTJ", "ESFJ",
+	"ISTP", "ISFP", "ESTP", "ESFP",
+}
+
+// candidate is one point of the grid and how the traces went under it.
+type candidate struct {
+	cfg     persona.Config
+	byType  map[string]persona.ReplayResult
+	mean    float64
+	minType string
+	maxType string
+}
+
+func main() {
+	var (
+		base        = flag.String("base", "", "persona config file the grid starts from (defaults when empty)")
+		target      = flag.Float64("target", 0.05, "wanted share of time the gate is blocked, averaged over MBTI types")
+		tick        = flag.Duration("tick", 3*time.Second, "decay tick between turns, as EMOTION_TICK_INTERVAL_SECONDS")
+		tail        = flag.Duration("tail", 2*time.Minute, "replay time after the last turn of a trace")
+		lockBase    = flag.String("lock-base", "60,90,120,180", "lock_base_seconds values to try")
+		impactGain  = flag.String("impact-gain", "1.0,1.3,1.6", "negative_impact_gain values to try")
+		shockGain   = flag.String("shock-gain", "0.9,1.25,1.6", "shock_negative_gain values to try")
+		out         = flag.String("out", "persona-config.json", "write the recommended config here; empty skips")
+		showPerType = flag.Bool("types", true, "print the per-type breakdown of the recommendation")
+	)
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: soul-calibrate [flags] traces.jsonl...")
+		os.Exit(2)
+	}
+
+	var traces [][]persona.TracePoint
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		items, err := persona.ReadTraces(f)
+		_ = f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			os.Exit(2)
+		}
+		traces = append(traces, items...)
+	}
+	if len(traces) == 0 {
+		fmt.Fprintln(os.Stderr, "no traces found")
+		os.Exit(2)
+	}
+
+	baseCfg, err := persona.LoadConfigFile(*base)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	grid := [][]float64{}
+	for _, raw := range []string{*lockBase, *impactGain, *shockGain} {
+		values, err := parseFloats(raw)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		grid = append(grid, values)
+	}
+	vectors := make(map[string]domain.PersonalityVector, len(mbtiTypes))
+	for _, t := range mbtiTypes {
+		vectors[t], _ = persona.VectorFromMBTI(t)
+	}
+
+	current := evaluate(baseCfg, traces, vectors, *tick, *tail)
+	var candidates []candidate
+	for _, lb := range grid[0] {
+		for _, ig := range grid[1] {
+			for _, sg := range grid[2] {
+				cfg := baseCfg
+				cfg.LockBaseSeconds, cfg.NegativeImpactGain, cfg.ShockNegativeGain = lb, ig, sg
+				candidates = append(candidates, evaluate(cfg, traces, vectors, *tick, *tail))
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		di, dj := math.Abs(candidates[i].mean-*target), math.Abs(candidates[j].mean-*target)
+		if di != dj {
+			return di < dj
+		}
+		return candidates[i].byType[candidates[i].maxType].BlockedShare() < candidates[j].byType[candidates[j].maxType].BlockedShare()
+	})
+	best := candidates[0]
+
+	fmt.Printf("%d traces, %d MBTI types, target blocked %.1f%%\n\n", len(traces), len(mbtiTypes), *target*100)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tlock_base\timpact_gain\tshock_gain\tblocked\tmin\tmax\tlocks/h")
+	writeCandidate(w, "current", current)
+	for i, c := range candidates {
+		label := ""
+		if i == 0 {
+			label = "best"
+		}
+		writeCandidate(w, label, c)
+	}
+	_ = w.Flush()
+
+	if *showPerType {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "type\tcurrent\tbest\tlocks/h")
+		for _, t := range mbtiTypes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.1f\n", t, percent(current.byType[t].BlockedShare()), percent(best.byType[t].BlockedShare()), locksPerHour(best.byType[t]))
+		}
+		_ = w.Flush()
+	}
+
+	if *out != "" {
+		raw, _ := json.MarshalIndent(best.cfg, "", "  ")
+		if err := os.WriteFile(*out, append(raw, '\n'), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, "write config:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("\nrecommended config written to %s (load it with PERSONA_CONFIG_FILE)\n", *out)
+	}
+}
+
+// evaluate replays every trace for every type under cfg. A type's share is
+// its blocked time over its total replay time; mean averages the types.
+func evaluate(cfg persona.Config, traces [][]persona.TracePoint, vectors map[string]domain.PersonalityVector, tick, tail time.Duration) candidate {
+	engine := persona.NewEngine(cfg)
+	c := candidate{cfg: cfg, byType: make(map[string]persona.ReplayResult, len(vectors))}
+	for _, t := range mbtiTypes {
+		var sum persona.ReplayResult
+		for _, trace := range traces {
+			r := engine.Replay(vectors[t], trace, tick, tail)
+			sum.Total += r.Total
+			sum.Blocked += r.Blocked
+			sum.Locks += r.Locks
+		}
+		c.byType[t] = sum
+		share := sum.BlockedShare()
+		c.mean += share / float64(len(mbtiTypes))
+		if c.minType == "" || share < c.byType[c.minType].BlockedShare() {
+			c.minType = t
+		}
+		if c.maxType == "" || share > c.byType[c.maxType].BlockedShare() {
+			c.maxType = t
+		}
+	}
+	return c
+}
+
+func writeCandidate(w io.Writer, label string, c candidate) {
+	var locks persona.ReplayResult
+	for _, r := range c.byType {
+		locks.Total += r.Total
+		locks.Locks += r.Locks
+	}
+	fmt.Fprintf(w, "%s\t%g\t%g\t%g\t%s\t%s %s\t%s %s\t%.1f\n", label,
+		c.cfg.LockBaseSeconds, c.cfg.NegativeImpactGain, c.cfg.ShockNegativeGain,
+		percent(c.mean),
+		percent(c.byType[c.minType].BlockedShare()), c.minType,
+		percent(c.byType[c.maxType].BlockedShare()), c.maxType,
+		locksPerHour(locks))
+}
+
+func locksPerHour(r persona.ReplayResult) float64 {
+	if r.Total <= 0 {
+		return 0
+	}
+	return float64(r.Locks) / r.Total.Hours()
+}
+
+func percent(v float64) string {
+	return strconv.FormatFloat(v*100, 'f', 1, 64) + "%"
+}
+
+func parseFloats(raw string) ([]float64, error) {
+	var out []float64
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("invalid grid value %q", part)
+		}
+		out = append(out, v)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("empty grid %q", raw)
+	}
+	return out, nil
+}
@@ -0,0 +1,217 @@
+// Command soul-eval runs YAML conversation suites through an in-process
+// orchestrator with mock terminals and reports which turns broke their
+// routing expectations (executed skills, exec_mode, reply properties).
+//
+// It uses the soul-server environment (DB_DSN, LLM_*, EMOTION_*, INTENT_*);
+// point DB_DSN at a scratch database, every case creates its own soul.
+//
+//	soul-eval -model gpt-4o-mini internal/eval/testdata/smoke.yaml
+//	soul-eval -provider mock -fixture internal/llm/testdata/mock_fixture.json internal/eval/testdata/smoke.yaml
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"soul/internal/config"
+	"soul/internal/db"
+	"soul/internal/emotion"
+	"soul/internal/eval"
+	"soul/internal/intent"
+	"soul/internal/llm"
+	"soul/internal/memory"
+	"soul/internal/orchestrator"
+	"soul/internal/persona"
+	"soul/internal/skills"
+)
+
+func main() {
+	var (
+		provider   = flag.String("provider", "", "override LLM_PROVIDER")
+		model      = flag.String("model", "", "override LLM_MODEL")
+		fixture    = flag.String("fixture", "", "override LLM_MOCK_FIXTURE")
+		useEmotion = flag.Bool("emotion", true, "call the emotion service; disable for a neutral, deterministic gate")
+		useIntent  = flag.Bool("intent", true, "call the intent filter service")
+		jsonOut    = flag.String("json", "", "also write the report as JSON to this file")
+		verbose    = flag.Bool("v", false, "log orchestrator output")
+	)
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: soul-eval [flags] suite.yaml...")
+		os.Exit(2)
+	}
+
+	// Flags go through the environment so config validation sees them.
+	for env, value := range map[string]string{"LLM_PROVIDER": *provider, "LLM_MODEL": *model, "LLM_MOCK_FIXTURE": *fixture} {
+		if value != "" {
+			_ = os.Setenv(env, value)
+		}
+	}
+	cfg, err := config.LoadSoulServerConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load config:", err)
+		os.Exit(2)
+	}
+
+	personaCfg, err := persona.LoadConfigFile(cfg.PersonaConfigFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logOut := io.Discard
+	if *verbose {
+		logOut = os.Stderr
+	}
+	logger := slog.New(slog.NewTextHandler(logOut, nil))
+	ctx := context.Background()
+
+	suites := make([]eval.Suite, 0, flag.NArg())
+	for _, path := range flag.Args() {
+		suite, err := eval.LoadSuite(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		suites = append(suites, suite)
+	}
+
+	store, err := db.New(ctx, cfg.DBDSN, db.PoolOptions{MaxConns: 4})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "connect db:", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate db:", err)
+		os.Exit(1)
+	}
+
+	llmProvider, err := llm.NewProvider(llm.Config{
+		Provider:                strings.ToLower(cfg.LLMProvider),
+		Model:                   cfg.LLMModel,
+		OpenAIBaseURL:           cfg.OpenAIBaseURL,
+		OpenAIAPIKey:            cfg.OpenAIAPIKey,
+		AnthropicBaseURL:        cfg.AnthropicBaseURL,
+		AnthropicAPIKey:         cfg.AnthropicAPIKey,
+		MockFixturePath:         cfg.LLMMockFixture,
+		AnthropicMaxTokens:      cfg.AnthropicMaxTokens,
+		AnthropicThinkingBudget: cfg.AnthropicThinkingBudget,
+		AnthropicStream:         cfg.AnthropicStream,
+		EmbeddingModel:          cfg.LLMEmbeddingModel,
+		EmbeddingDimensions:     cfg.LLMEmbeddingDimensions,
+		EmbeddingBatchSize:      cfg.LLMEmbeddingBatchSize,
+		Retry: llm.RetryConfig{
+			MaxAttempts: cfg.LLMRetryMaxAttempts,
+			BaseDelay:   cfg.LLMRetryBaseDelay,
+			MaxDelay:    cfg.LLMRetryMaxDelay,
+		},
+		Logger: logger,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "init llm provider:", err)
+		os.Exit(1)
+	}
+	// No Mem0 client: eval turns must never write long-term memory.
+	memorySvc, err := memory.NewService(store, memory.ServiceConfig{
+		LLMProvider:              llmProvider,
+		LLMModel:                 cfg.LLMModel,
+		CompressMessageThreshold: cfg.SessionCompressMsgThreshold,
+		CompressCharThreshold:    cfg.SessionCompressCharThreshold,
+		CompressScanLimit:        cfg.SessionCompressScanLimit,
+	}, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "init memory service:", err)
+		os.Exit(1)
+	}
+
+	runID := strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+	userID := "eval_" + runID
+	if _, err := memorySvc.CreateUser(ctx, userID, userID, "soul-eval"); err != nil {
+		fmt.Fprintln(os.Stderr, "create eval user:", err)
+		os.Exit(1)
+	}
+
+	report := eval.Report{Provider: cfg.LLMProvider, Model: cfg.LLMModel}
+	for _, suite := range suites {
+		registry := skills.NewRegistry(time.Hour)
+		terminal := eval.NewMockTerminal(suite.Skills)
+		var emotionAnalyzer orchestrator.EmotionAnalyzer
+		if *useEmotion {
+			emotionAnalyzer = emotion.NewClient(cfg.EmotionBaseURL, cfg.EmotionTimeout)
+		}
+		var intentFilter orchestrator.IntentFilter
+		if *useIntent {
+			intentFilter = intent.NewClient(cfg.IntentFilterBaseURL, intent.Options{Timeout: cfg.IntentFilterTimeout})
+		}
+		orch := orchestrator.New(orchestrator.Config{
+			UserID:           userID,
+			ChatHistoryLimit: cfg.ChatHistoryLimit,
+			ToolTimeout:      cfg.ToolTimeout,
+			LLMModel:         cfg.LLMModel,
+		}, llmProvider, memorySvc, registry, terminal, emotionAnalyzer, intentFilter, persona.NewEngine(personaCfg), logger)
+
+		runner := &eval.Runner{
+			Chat:     orch.HandleChat,
+			Terminal: terminal,
+			UserID:   userID,
+			RunID:    runID,
+			Prepare:  prepareCase(memorySvc, registry, userID, runID),
+		}
+		report.Suites = append(report.Suites, runner.Run(ctx, suite))
+	}
+
+	report.WriteText(os.Stdout)
+	if *jsonOut != "" {
+		raw, _ := json.MarshalIndent(report, "", "  ")
+		if err := os.WriteFile(*jsonOut, raw, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, "write json report:", err)
+			os.Exit(1)
+		}
+	}
+	if _, failed := report.Counts(); failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func prepareCase(memorySvc *memory.Service, registry *skills.Registry, userID, runID string) eval.PrepareFunc {
+	var n int
+	return func(ctx context.Context, suite eval.Suite, c eval.Case) (string, string, error) {
+		n++
+		name := suite.Soul.Name
+		if name == "" {
+			name = "eval-" + suite.Name
+		}
+		vector, err := persona.VectorFromMBTI(suite.Soul.MBTI)
+		if err != nil {
+			return "", "", err
+		}
+		soul, err := memorySvc.CreateSoulProfile(ctx, userID, name, suite.Soul.MBTI, vector, persona.InitialEmotionState(time.Now().UTC()), persona.ModelVersion)
+		if err != nil {
+			return "", "", err
+		}
+		defs, err := suite.SkillDefinitions()
+		if err != nil {
+			return "", "", err
+		}
+		catalog, err := suite.IntentSpecs()
+		if err != nil {
+			return "", "", err
+		}
+		terminalID := fmt.Sprintf("eval-%s-%03d", runID, n)
+		registry.SetSkills(terminalID, soul.SoulID, 1, defs)
+		if len(catalog) > 0 {
+			registry.SetIntentCatalog(terminalID, soul.SoulID, 1, catalog)
+		}
+		return terminalID, soul.SoulID, nil
+	}
+}
@@ -0,0 +1,249 @@
+// Command soul-replay re-runs a turn recorded by soul-server
+// (REPLAY_SAMPLE_RATE) through an in-process orchestrator built from the
+// current code, and prints how the prompts, tool calls and reply differ
+// from the recording.
+//
+// The turn starts from a scratch soul with the recorded persona and mood,
+// the recorded session history and the terminal's recorded skills; emotion
+// analysis, the intent filter and the terminal answer as they did then. The
+// LLM is called live unless -recorded hands back the recorded responses,
+// which isolates changes in prompt building and reply handling. Long-term
+// memory (session summary, episodes, mem0) and quiet hours are not
+// reproduced, so the context part of the system prompt differs.
+//
+// It uses the soul-server environment (DB_DSN, LLM_*); point DB_DSN at a
+// scratch database. The turn comes from that database by id, or from a file
+// saved from GET /v1/replays/{id}:
+//
+//	soul-replay 42
+//	soul-replay -recorded -file turn.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"soul/internal/config"
+	"soul/internal/db"
+	"soul/internal/domain"
+	"soul/internal/llm"
+	"soul/internal/memory"
+	"soul/internal/orchestrator"
+	"soul/internal/persona"
+	"soul/internal/replay"
+	"soul/internal/skills"
+)
+
+func main() {
+	var (
+		file     = flag.String("file", "", "read the recorded turn from this JSON file instead of the database")
+		recorded = flag.Bool("recorded", false, "answer with the recorded LLM responses instead of calling the LLM")
+		provider = flag.String("provider", "", "override LLM_PROVIDER")
+		model    = flag.String("model", "", "override LLM_MODEL")
+		jsonOut  = flag.String("json", "", "also write the replayed turn as JSON to this file")
+		verbose  = flag.Bool("v", false, "log orchestrator output")
+	)
+	flag.Parse()
+	if (*file == "") == (flag.NArg() == 0) {
+		fmt.Fprintln(os.Stderr, "usage: soul-replay [flags] replay_id | soul-replay [flags] -file turn.json")
+		os.Exit(2)
+	}
+
+	for env, value := range map[string]string{"LLM_PROVIDER": *provider, "LLM_MODEL": *model} {
+		if value != "" {
+			_ = os.Setenv(env, value)
+		}
+	}
+	cfg, err := config.LoadSoulServerConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load config:", err)
+		os.Exit(2)
+	}
+	personaCfg, err := persona.LoadConfigFile(cfg.PersonaConfigFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logOut := io.Discard
+	if *verbose {
+		logOut = os.Stderr
+	}
+	logger := slog.New(slog.NewTextHandler(logOut, nil))
+	ctx := context.Background()
+
+	store, err := db.New(ctx, cfg.DBDSN, db.PoolOptions{MaxConns: 4})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "connect db:", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate db:", err)
+		os.Exit(1)
+	}
+
+	var turn domain.TurnReplay
+	if *file != "" {
+		raw, err := os.ReadFile(*file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "read turn:", err)
+			os.Exit(2)
+		}
+		if err := json.Unmarshal(raw, &turn); err != nil {
+			fmt.Fprintln(os.Stderr, "parse turn:", err)
+			os.Exit(2)
+		}
+	} else {
+		id, err := strconv.ParseInt(flag.Arg(0), 10, 64)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "replay_id must be an integer")
+			os.Exit(2)
+		}
+		if turn, err = store.GetTurnReplay(ctx, id); err != nil {
+			fmt.Fprintln(os.Stderr, "load turn:", err)
+			os.Exit(1)
+		}
+	}
+	if turn.Inputs == nil {
+		fmt.Fprintln(os.Stderr, "the turn ended before the LLM stage (e.g. a privacy or diary command); nothing to replay")
+		os.Exit(1)
+	}
+
+	var llmProvider llm.Provider = &recordedLLM{calls: turn.LLMCalls}
+	if !*recorded {
+		llmProvider, err = llm.NewProvider(llm.Config{
+			Provider:                strings.ToLower(cfg.LLMProvider),
+			Model:                   cfg.LLMModel,
+			OpenAIBaseURL:           cfg.OpenAIBaseURL,
+			OpenAIAPIKey:            cfg.OpenAIAPIKey,
+			AnthropicBaseURL:        cfg.AnthropicBaseURL,
+			AnthropicAPIKey:         cfg.AnthropicAPIKey,
+			MockFixturePath:         cfg.LLMMockFixture,
+			AnthropicMaxTokens:      cfg.AnthropicMaxTokens,
+			AnthropicThinkingBudget: cfg.AnthropicThinkingBudget,
+			AnthropicStream:         cfg.AnthropicStream,
+			Retry: llm.RetryConfig{
+				MaxAttempts: cfg.LLMRetryMaxAttempts,
+				BaseDelay:   cfg.LLMRetryBaseDelay,
+				MaxDelay:    cfg.LLMRetryMaxDelay,
+			},
+			Logger: logger,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "init llm provider:", err)
+			os.Exit(1)
+		}
+	}
+	// No Mem0 client: a replay must never write long-term memory.
+	memorySvc, err := memory.NewService(store, memory.ServiceConfig{
+		LLMProvider:              llmProvider,
+		LLMModel:                 cfg.LLMModel,
+		CompressMessageThreshold: cfg.SessionCompressMsgThreshold,
+		CompressCharThreshold:    cfg.SessionCompressCharThreshold,
+		CompressScanLimit:        cfg.SessionCompressScanLimit,
+	}, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "init memory service:", err)
+		os.Exit(1)
+	}
+
+	runID := strings.ReplaceAll(uuid.NewString(), "-", "")[:8]
+	req, err := prepareTurn(ctx, memorySvc, turn, runID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "prepare replay:", err)
+		os.Exit(1)
+	}
+	registry := skills.NewRegistry(time.Hour)
+	registry.SetSkills(req.TerminalID, req.SoulID, 1, turn.Inputs.Skills)
+	if len(turn.Inputs.IntentCatalog) > 0 {
+		registry.SetIntentCatalog(req.TerminalID, req.SoulID, 1, turn.Inputs.IntentCatalog)
+	}
+	var intentFilter orchestrator.IntentFilter
+	if turn.Inputs.IntentFiltered {
+		intentFilter = recordedIntent{resp: turn.Inputs.Intent}
+	}
+	terminal := newRecordedTerminal(turn.ToolCalls)
+	captured := &captureStore{}
+	orch := orchestrator.New(orchestrator.Config{
+		UserID:           req.UserID,
+		ChatHistoryLimit: cfg.ChatHistoryLimit,
+		ToolTimeout:      cfg.ToolTimeout,
+		LLMModel:         cfg.LLMModel,
+		ChildMode: orchestrator.ChildModeConfig{
+			BlockedSkills: cfg.ChildModeBlockedSkills,
+			MaxReplyRunes: cfg.ChildModeMaxReplyRunes,
+			PIN:           cfg.ChildModePIN,
+		},
+		GateBypassSkills: cfg.GateBypassSkills,
+		RecallCitations:  cfg.MemoryRecallCitations,
+		Replay:           replay.NewRecorder(captured, 1, logger),
+	}, llmProvider, memorySvc, registry, terminal, recordedEmotion{signal: turn.Inputs.UserEmotion}, intentFilter, persona.NewEngine(personaCfg), logger)
+
+	_, chatErr := orch.HandleChat(ctx, req)
+	replayed := captured.turn
+	if replayed == nil {
+		fmt.Fprintln(os.Stderr, "replay failed:", chatErr)
+		os.Exit(1)
+	}
+	writeReport(os.Stdout, turn, *replayed, terminal.unrecorded)
+	if *jsonOut != "" {
+		raw, _ := json.MarshalIndent(replayed, "", "  ")
+		if err := os.WriteFile(*jsonOut, raw, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, "write json:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// prepareTurn creates the scratch user, soul and session the turn is
+// replayed in and returns the request to send.
+func prepareTurn(ctx context.Context, memorySvc *memory.Service, turn domain.TurnReplay, runID string) (domain.ChatRequest, error) {
+	userID := "replay_" + runID
+	if _, err := memorySvc.CreateUser(ctx, userID, userID, "soul-replay"); err != nil {
+		return domain.ChatRequest{}, err
+	}
+	recorded := turn.Inputs.Soul
+	name := recorded.Name
+	if name == "" {
+		name = "replay"
+	}
+	soul, err := memorySvc.CreateSoulProfile(ctx, userID, name, recorded.MBTIType, recorded.PersonalityVector, recorded.EmotionState, recorded.ModelVersion)
+	if err != nil {
+		return domain.ChatRequest{}, err
+	}
+	if recorded.ChildMode {
+		if _, err := memorySvc.SetSoulChildMode(ctx, soul.SoulID, true); err != nil {
+			return domain.ChatRequest{}, err
+		}
+	}
+	if recorded.CharacterCard != nil {
+		if _, err := memorySvc.SetSoulCharacterCard(ctx, soul.SoulID, *recorded.CharacterCard); err != nil {
+			return domain.ChatRequest{}, err
+		}
+	}
+
+	req := turn.Request
+	req.UserID = userID
+	req.SoulID = soul.SoulID
+	req.SoulHint = ""
+	req.SessionID = "replay_" + runID
+	req.TerminalID = "replay-" + runID
+	req.ForceExecute = turn.Inputs.ForceExecute
+	for _, m := range turn.Inputs.History {
+		if err := memorySvc.PersistMessage(ctx, req.SessionID, userID, req.TerminalID, soul.SoulID, m.Role, m.Name, m.ToolCallID, m.Content); err != nil {
+			return domain.ChatRequest{}, err
+		}
+	}
+	return req, nil
+}
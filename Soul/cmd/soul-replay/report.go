@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// writeReport prints the recorded and the replayed turn side by side,
+// listing only what changed in the prompts.
+func writeReport(w io.Writer, recorded, replayed domain.TurnReplay, unrecorded []string) {
+	fmt.Fprintf(w, "replay %d  session=%s terminal=%s recorded_at=%s\n", recorded.ID, recorded.SessionID, recorded.TerminalID, recorded.CreatedAt)
+	for _, in := range recorded.Request.Inputs {
+		if text := strings.TrimSpace(in.Text); text != "" {
+			fmt.Fprintf(w, "input: %s\n", text)
+		}
+	}
+	if recorded.Inputs != nil && replayed.Inputs != nil {
+		fmt.Fprintf(w, "\ngate: recorded %s (%.3f), replayed %s (%.3f)\n", recorded.Inputs.ExecMode, recorded.Inputs.ExecProbability, replayed.Inputs.ExecMode, replayed.Inputs.ExecProbability)
+	}
+
+	fmt.Fprintf(w, "\nllm calls: recorded %d, replayed %d\n", len(recorded.LLMCalls), len(replayed.LLMCalls))
+	for i := 0; i < max(len(recorded.LLMCalls), len(replayed.LLMCalls)); i++ {
+		fmt.Fprintf(w, "-- call %d\n", i+1)
+		if i >= len(recorded.LLMCalls) {
+			fmt.Fprintf(w, "   only replayed: %s\n", describeResponse(replayed.LLMCalls[i]))
+			continue
+		}
+		if i >= len(replayed.LLMCalls) {
+			fmt.Fprintf(w, "   only recorded: %s\n", describeResponse(recorded.LLMCalls[i]))
+			continue
+		}
+		before, after := recorded.LLMCalls[i], replayed.LLMCalls[i]
+		removed, added := diffLines(before.Request.System, after.Request.System)
+		if len(removed) == 0 && len(added) == 0 {
+			fmt.Fprintln(w, "   system prompt: unchanged")
+		} else {
+			fmt.Fprintf(w, "   system prompt: -%d +%d lines\n", len(removed), len(added))
+			for _, line := range removed {
+				fmt.Fprintf(w, "   - %s\n", line)
+			}
+			for _, line := range added {
+				fmt.Fprintf(w, "   + %s\n", line)
+			}
+		}
+		if a, b := toolNames(before.Request.Tools), toolNames(after.Request.Tools); a != b {
+			fmt.Fprintf(w, "   tools: recorded [%s], replayed [%s]\n", a, b)
+		}
+		if len(before.Request.Messages) != len(after.Request.Messages) {
+			fmt.Fprintf(w, "   messages: recorded %d, replayed %d\n", len(before.Request.Messages), len(after.Request.Messages))
+		}
+		fmt.Fprintf(w, "   response recorded: %s\n", describeResponse(before))
+		fmt.Fprintf(w, "   response replayed: %s\n", describeResponse(after))
+	}
+
+	fmt.Fprintf(w, "\ntool calls recorded: %s\n", describeToolCalls(recorded.ToolCalls))
+	fmt.Fprintf(w, "tool calls replayed: %s\n", describeToolCalls(replayed.ToolCalls))
+	if len(unrecorded) > 0 {
+		fmt.Fprintf(w, "answered with \"ok\" (not in the recording): %s\n", strings.Join(unrecorded, ", "))
+	}
+
+	fmt.Fprintln(w)
+	writeOutcome(w, "recorded", recorded)
+	writeOutcome(w, "replayed", replayed)
+}
+
+func writeOutcome(w io.Writer, label string, turn domain.TurnReplay) {
+	if turn.Error != "" {
+		fmt.Fprintf(w, "%s error: %s\n", label, turn.Error)
+		return
+	}
+	if turn.Response == nil {
+		fmt.Fprintf(w, "%s: no response\n", label)
+		return
+	}
+	fmt.Fprintf(w, "%s reply: %s\n", label, turn.Response.Reply)
+	fmt.Fprintf(w, "%s executed_skills: [%s] exec_mode=%s\n", label, strings.Join(turn.Response.ExecutedSkills, " "), turn.Response.ExecMode)
+}
+
+func describeResponse(call domain.ReplayLLMCall) string {
+	if call.Error != "" {
+		return "error: " + call.Error
+	}
+	parts := make([]string, 0, len(call.Response.ToolCalls)+1)
+	for _, tc := range call.Response.ToolCalls {
+		parts = append(parts, fmt.Sprintf("%s(%s)", tc.Name, tc.Arguments))
+	}
+	if content := strings.TrimSpace(call.Response.Content); content != "" {
+		parts = append(parts, fmt.Sprintf("%q", content))
+	}
+	if len(parts) == 0 {
+		return "(empty)"
+	}
+	return strings.Join(parts, " ")
+}
+
+func describeToolCalls(calls []domain.ReplayToolCall) string {
+	if len(calls) == 0 {
+		return "(none)"
+	}
+	parts := make([]string, 0, len(calls))
+	for _, c := range calls {
+		parts = append(parts, fmt.Sprintf("%s(%s)", c.Skill, c.Arguments))
+	}
+	return strings.Join(parts, " ")
+}
+
+func toolNames(tools []domain.LLMTool) string {
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		names = append(names, t.Name)
+	}
+	return strings.Join(names, " ")
+}
+
+// diffLines returns the lines only a has and the lines only b has, each in
+// their original order; repeated lines are matched by count. Timestamps
+// such as snapshot_at always differ and are left out.
+func diffLines(a, b string) (removed, added []string) {
+	count := make(map[string]int)
+	for _, line := range strings.Split(b, "\n") {
+		count[line]++
+	}
+	for _, line := range strings.Split(a, "\n") {
+		if count[line] > 0 {
+			count[line]--
+			continue
+		}
+		if !volatileLine(line) {
+			removed = append(removed, line)
+		}
+	}
+	count = make(map[string]int)
+	for _, line := range strings.Split(a, "\n") {
+		count[line]++
+	}
+	for _, line := range strings.Split(b, "\n") {
+		if count[line] > 0 {
+			count[line]--
+			continue
+		}
+		if !volatileLine(line) {
+			added = append(added, line)
+		}
+	}
+	return removed, added
+}
+
+func volatileLine(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "- snapshot_at:")
+}
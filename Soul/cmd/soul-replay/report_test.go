@@ -0,0 +1,18 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffLinesSkipsSnapshotTime(t *testing.T) {
+	before := "规则\n- snapshot_at: 2026-03-01T08:00:00Z\n- user_emotion: sad\n- 技能 a\n- 技能 a"
+	after := "规则\n- snapshot_at: 2026-10-16T12:00:00Z\n- user_emotion: happy\n- 技能 a"
+	removed, added := diffLines(before, after)
+	if !reflect.DeepEqual(removed, []string{"- user_emotion: sad", "- 技能 a"}) {
+		t.Fatalf("removed = %q", removed)
+	}
+	if !reflect.DeepEqual(added, []string{"- user_emotion: happy"}) {
+		t.Fatalf("added = %q", added)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"soul/internal/domain"
+	"soul/internal/llm"
+)
+
+// recordedEmotion answers emotion analysis with the recorded user emotion.
+type recordedEmotion struct {
+	signal domain.EmotionSignal
+}
+
+func (e recordedEmotion) Analyze(context.Context, string, string, []domain.Message) (domain.EmotionSignal, error) {
+	return e.signal, nil
+}
+
+// recordedIntent answers the intent filter with the recorded decision.
+type recordedIntent struct {
+	resp domain.IntentFilterResponse
+}
+
+func (f recordedIntent) Filter(context.Context, domain.IntentFilterRequest) (domain.IntentFilterResponse, error) {
+	return f.resp, nil
+}
+
+// recordedLLM hands out the recorded LLM responses in order.
+type recordedLLM struct {
+	mu    sync.Mutex
+	calls []domain.ReplayLLMCall
+	next  int
+}
+
+func (p *recordedLLM) Complete(context.Context, domain.LLMRequest) (domain.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.next >= len(p.calls) {
+		return domain.LLMResponse{}, fmt.Errorf("replay asked the llm %d times, only %d calls were recorded", p.next+1, len(p.calls))
+	}
+	call := p.calls[p.next]
+	p.next++
+	if call.Error != "" {
+		return domain.LLMResponse{}, errors.New(call.Error)
+	}
+	return call.Response, nil
+}
+
+func (p *recordedLLM) Embed(context.Context, []string) (llm.Embeddings, error) {
+	return llm.Embeddings{}, llm.ErrEmbeddingUnsupported
+}
+
+// recordedTerminal answers each skill call with the next recorded result
+// of that skill; a call the recording does not have succeeds with "ok" and
+// is counted as unrecorded.
+type recordedTerminal struct {
+	mu         sync.Mutex
+	results    map[string][]domain.ReplayToolCall
+	unrecorded []string
+}
+
+func newRecordedTerminal(calls []domain.ReplayToolCall) *recordedTerminal {
+	t := &recordedTerminal{results: make(map[string][]domain.ReplayToolCall)}
+	for _, c := range calls {
+		t.results[c.Skill] = append(t.results[c.Skill], c)
+	}
+	return t
+}
+
+func (t *recordedTerminal) InvokeSkill(_ context.Context, _ string, skill string, _ json.RawMessage) (domain.InvokeResult, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	queue := t.results[skill]
+	if len(queue) == 0 {
+		t.unrecorded = append(t.unrecorded, skill)
+		return domain.InvokeResult{OK: true, Output: "ok"}, nil
+	}
+	call := queue[0]
+	t.results[skill] = queue[1:]
+	if call.Error != "" {
+		return call.Result, errors.New(call.Error)
+	}
+	return call.Result, nil
+}
+
+func (t *recordedTerminal) PublishStatus(context.Context, string, string, string, string) error {
+	return nil
+}
+
+func (t *recordedTerminal) PublishIntentAction(context.Context, string, domain.IntentActionPayload) error {
+	return nil
+}
+
+// captureStore keeps the replayed turn instead of writing it to the
+// database.
+type captureStore struct {
+	mu   sync.Mutex
+	turn *domain.TurnReplay
+}
+
+func (s *captureStore) InsertTurnReplay(_ context.Context, in domain.TurnReplay) (domain.TurnReplay, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.turn = &in
+	return in, nil
+}
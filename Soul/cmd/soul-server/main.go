@@ -2,29 +2,53 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"soul/internal/blob"
 	"soul/internal/config"
 	"soul/internal/db"
 	"soul/internal/domain"
 	"soul/internal/emotion"
+	"soul/internal/integrations/email"
 	"soul/internal/intent"
+	"soul/internal/intentresults"
+	"soul/internal/knowledge"
+	"soul/internal/language"
+	"soul/internal/liveness"
 	"soul/internal/llm"
 	"soul/internal/memory"
+	"soul/internal/moodalerts"
 	"soul/internal/mqtt"
+	"soul/internal/openapi"
 	"soul/internal/orchestrator"
 	"soul/internal/persona"
+	"soul/internal/power"
+	"soul/internal/quiethours"
+	"soul/internal/redact"
+	"soul/internal/reminders"
+	"soul/internal/replay"
+	"soul/internal/replyfilter"
+	"soul/internal/routines"
+	"soul/internal/safety"
 	"soul/internal/skills"
+	"soul/internal/terminalconfig"
+	"soul/internal/topics"
+	"soul/internal/twin"
+	"soul/internal/writebehind"
 )
 
 func main() {
@@ -39,7 +63,13 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	store, err := db.New(ctx, cfg.DBDSN)
+	store, err := db.New(ctx, cfg.DBDSN, db.PoolOptions{
+		MaxConns:               int32(cfg.DBMaxConns),
+		MinConns:               int32(cfg.DBMinConns),
+		HealthCheckPeriod:      cfg.DBHealthCheckPeriod,
+		MaxConnIdleTime:        cfg.DBMaxConnIdleTime,
+		StatementCacheCapacity: cfg.DBStatementCacheCapacity,
+	})
 	if err != nil {
 		logger.Error("connect db failed", "error", err)
 		os.Exit(1)
@@ -50,22 +80,100 @@ func main() {
 		logger.Error("migrate db failed", "error", err)
 		os.Exit(1)
 	}
+	if cfg.DBReadDSN != "" {
+		if err := store.AttachReplica(ctx, cfg.DBReadDSN, db.ReplicaOptions{
+			Pool: db.PoolOptions{
+				MaxConns:               int32(cfg.DBReadMaxConns),
+				MinConns:               int32(cfg.DBMinConns),
+				HealthCheckPeriod:      cfg.DBHealthCheckPeriod,
+				MaxConnIdleTime:        cfg.DBMaxConnIdleTime,
+				StatementCacheCapacity: cfg.DBStatementCacheCapacity,
+			},
+			MaxLag: cfg.DBReadMaxLag,
+		}); err != nil {
+			logger.Warn("connect read replica failed, reading from the primary", "error", err)
+		} else {
+			go store.RunReplicaLagCheck(ctx, 5*time.Second)
+			prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "soul_db_replica_lag_seconds", Help: "Replay lag of the read replica; -1 while unknown."}, func() float64 {
+				if lag, ok := store.ReplicaLag(); ok {
+					return lag.Seconds()
+				}
+				return -1
+			}))
+			logger.Info("read replica attached", "max_lag", cfg.DBReadMaxLag)
+		}
+	}
 
 	llmProvider, err := llm.NewProvider(llm.Config{
-		Provider:         strings.ToLower(cfg.LLMProvider),
-		Model:            cfg.LLMModel,
-		OpenAIBaseURL:    cfg.OpenAIBaseURL,
-		OpenAIAPIKey:     cfg.OpenAIAPIKey,
-		AnthropicBaseURL: cfg.AnthropicBaseURL,
-		AnthropicAPIKey:  cfg.AnthropicAPIKey,
+		Provider:                strings.ToLower(cfg.LLMProvider),
+		Model:                   cfg.LLMModel,
+		OpenAIBaseURL:           cfg.OpenAIBaseURL,
+		OpenAIAPIKey:            cfg.OpenAIAPIKey,
+		AnthropicBaseURL:        cfg.AnthropicBaseURL,
+		AnthropicAPIKey:         cfg.AnthropicAPIKey,
+		MockFixturePath:         cfg.LLMMockFixture,
+		AnthropicMaxTokens:      cfg.AnthropicMaxTokens,
+		AnthropicThinkingBudget: cfg.AnthropicThinkingBudget,
+		AnthropicStream:         cfg.AnthropicStream,
+		EmbeddingModel:          cfg.LLMEmbeddingModel,
+		EmbeddingDimensions:     cfg.LLMEmbeddingDimensions,
+		EmbeddingBatchSize:      cfg.LLMEmbeddingBatchSize,
+		Retry: llm.RetryConfig{
+			MaxAttempts: cfg.LLMRetryMaxAttempts,
+			BaseDelay:   cfg.LLMRetryBaseDelay,
+			MaxDelay:    cfg.LLMRetryMaxDelay,
+		},
+		Transport: llm.TransportConfig{
+			MaxIdleConnsPerHost: cfg.LLMMaxIdleConns,
+			IdleConnTimeout:     cfg.LLMIdleConnTimeout,
+			DialTimeout:         cfg.LLMDialTimeout,
+			TLSTimeout:          cfg.LLMTLSTimeout,
+			HTTP2:               cfg.LLMHTTP2,
+		},
+		Metrics: llm.NewMetrics(prometheus.DefaultRegisterer),
+		Logger:  logger,
 	})
 	if err != nil {
 		logger.Error("init llm provider failed", "error", err)
 		os.Exit(1)
 	}
+	if cfg.LLMWarmConns > 0 {
+		go func() {
+			warmCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			if err := llm.Warm(warmCtx, llmProvider, cfg.LLMWarmConns); err != nil {
+				logger.Warn("warm llm connections failed", "error", err)
+			}
+		}()
+	}
+
+	var redactor *redact.Redactor
+	if cfg.RedactEnabled {
+		extra, err := redact.ParseRules(cfg.RedactPatterns)
+		if err == nil {
+			redactor, err = redact.New(cfg.RedactKinds, extra)
+		}
+		if err != nil {
+			logger.Error("init redaction failed", "error", err)
+			os.Exit(1)
+		}
+		llmProvider = redact.WrapProvider(llmProvider, redactor)
+		logger.Info("personal data redaction enabled", "kinds", cfg.RedactKinds, "extra_rules", len(extra))
+	}
 
 	mem0Client := memory.NewMem0Client(cfg.Mem0BaseURL, cfg.Mem0APIKey, cfg.Mem0Timeout)
 
+	var writes *writebehind.Queue
+	if cfg.WriteBehindEnabled {
+		writes = writebehind.New(writebehind.Config{Size: cfg.WriteBehindQueueSize, Workers: cfg.WriteBehindWorkers}, logger)
+		prometheus.MustRegister(
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: "soul_write_behind_pending", Help: "Writes waiting in the write-behind queue."}, func() float64 { return float64(writes.Pending()) }),
+			prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "soul_write_behind_dropped_total", Help: "Writes dropped because the write-behind queue was full."}, func() float64 { return float64(writes.Dropped()) }),
+			prometheus.NewCounterFunc(prometheus.CounterOpts{Name: "soul_write_behind_failed_total", Help: "Write-behind writes that failed."}, func() float64 { return float64(writes.Failed()) }),
+		)
+		logger.Info("write-behind queue enabled", "size", cfg.WriteBehindQueueSize, "workers", cfg.WriteBehindWorkers)
+	}
+
 	memorySvc, err := memory.NewService(store, memory.ServiceConfig{
 		LLMProvider:              llmProvider,
 		LLMModel:                 cfg.LLMModel,
@@ -77,6 +185,13 @@ func main() {
 		IdleSummaryScanInterval:  cfg.IdleSummaryScanInterval,
 		IdleSummaryBatchSize:     50,
 		Mem0AsyncQueueEnabled:    cfg.Mem0AsyncQueueEnabled,
+		ContextCacheTTL:          cfg.MemoryContextCacheTTL,
+		Redactor:                 redactor,
+		DiaryHour:                cfg.DiaryHour,
+		SessionRetention:         time.Duration(cfg.SessionRetentionDays) * 24 * time.Hour,
+		SessionArchiveDiscard:    cfg.SessionArchiveMode == "delete",
+		Writes:                   writes,
+		SoulLocks:                cfg.SoulLocksEnabled,
 	}, logger)
 	if err != nil {
 		logger.Error("init memory service failed", "error", err)
@@ -89,39 +204,318 @@ func main() {
 		"compress_msg_threshold", cfg.SessionCompressMsgThreshold,
 		"compress_char_threshold", cfg.SessionCompressCharThreshold,
 		"mem0_async_queue_enabled", cfg.Mem0AsyncQueueEnabled,
+		"memory_context_cache_ttl", cfg.MemoryContextCacheTTL,
 	)
+	if cfg.SessionRetentionDays > 0 {
+		go memorySvc.RunSessionArchiveWorker(ctx)
+		logger.Info("session archive worker enabled", "retention_days", cfg.SessionRetentionDays, "mode", cfg.SessionArchiveMode)
+	}
+	if cfg.DiaryEnabled {
+		go memorySvc.RunDiaryWorker(ctx)
+		logger.Info("soul diary worker enabled", "hour", cfg.DiaryHour)
+	}
 
 	terminalSoulResolver := memory.NewTerminalSoulResolver(cfg.UserID, memorySvc)
 
+	if cfg.MQTTEmbeddedBroker {
+		broker, err := mqtt.StartEmbeddedBroker(mqtt.BrokerConfig{
+			Addr:     cfg.MQTTEmbeddedBrokerAddr,
+			Username: cfg.MQTTUsername,
+			Password: cfg.MQTTPassword,
+		}, logger)
+		if err != nil {
+			logger.Error("start embedded mqtt broker failed", "error", err)
+			os.Exit(1)
+		}
+		defer broker.Close()
+		logger.Info("embedded mqtt broker enabled", "addr", cfg.MQTTEmbeddedBrokerAddr, "hub_broker_url", cfg.MQTTBrokerURL)
+	}
+
+	var intentResultNotes intentresults.MessageWriter
+	if cfg.IntentResultSessionNotes {
+		intentResultNotes = memorySvc
+	}
+	intentResultRecorder := intentresults.NewRecorder(store, intentResultNotes, logger)
+
 	skillRegistry := skills.NewRegistry(cfg.SkillSnapshotTTL)
 	mqttHub := mqtt.NewHub(mqtt.HubConfig{
-		BrokerURL:   cfg.MQTTBrokerURL,
-		ClientID:    cfg.MQTTClientID,
-		Username:    cfg.MQTTUsername,
-		Password:    cfg.MQTTPassword,
-		TopicPrefix: cfg.MQTTTopicPrefix,
-	}, skillRegistry, terminalSoulResolver, logger)
+		BrokerURL:        cfg.MQTTBrokerURL,
+		ClientID:         cfg.MQTTClientID,
+		Username:         cfg.MQTTUsername,
+		Password:         cfg.MQTTPassword,
+		TopicPrefix:      cfg.MQTTTopicPrefix,
+		HeartbeatTimeout: cfg.TerminalHeartbeatTimeout,
+	}, skillRegistry, terminalSoulResolver, intentResultRecorder, logger)
+	if cfg.TerminalAlertWebhookURL != "" {
+		mqttHub.OnLiveness(liveness.NewWebhookNotifier(cfg.TerminalAlertWebhookURL, cfg.TerminalAlertWebhookToken, 0, logger).Handle)
+	}
+	desiredStates := twin.NewReconciler(store, mqttHub, skillRegistry, logger)
+	if err := desiredStates.Load(ctx); err != nil {
+		logger.Error("load desired states failed", "error", err)
+		os.Exit(1)
+	}
+	terminalConfigs := terminalconfig.NewService(store, mqttHub, logger)
+	mqttHub.OnConfigAck(terminalConfigs.RecordAck)
+	powerStates, err := power.NewManager(power.Config{
+		IdleAfter:     cfg.PowerIdleSleep,
+		ScheduleStart: cfg.PowerSleepStart,
+		ScheduleEnd:   cfg.PowerSleepEnd,
+		DecayScale:    cfg.PowerAsleepDecayScale,
+	}, mqttHub, skillRegistry, logger)
+	if err != nil {
+		logger.Error("init power policy failed", "error", err)
+		os.Exit(1)
+	}
+	mqttHub.OnReconnect(func(terminalID string) {
+		terminalConfigs.HandleReconnect(terminalID)
+		desiredStates.HandleReconnect(terminalID)
+		powerStates.HandleReconnect(terminalID)
+	})
 	if err := mqttHub.Start(ctx); err != nil {
 		logger.Error("start mqtt hub failed", "error", err)
 		os.Exit(1)
 	}
+	marketplace := skills.NewMarketplace(store, skillRegistry)
+	if err := marketplace.Load(ctx); err != nil {
+		logger.Error("load skill bundles failed", "error", err)
+		os.Exit(1)
+	}
+	rollout := skills.NewRollout(store, skillRegistry)
+	if err := rollout.Load(ctx); err != nil {
+		logger.Error("load version requirements failed", "error", err)
+		os.Exit(1)
+	}
 
 	emotionClient := emotion.NewClient(cfg.EmotionBaseURL, cfg.EmotionTimeout)
-	intentClient := intent.NewClient(cfg.IntentFilterBaseURL, cfg.IntentFilterTimeout)
-	personaEngine := persona.NewEngine(persona.DefaultConfig())
+	intentClient := intent.NewClient(cfg.IntentFilterBaseURL, intent.Options{
+		Timeout:       cfg.IntentFilterTimeout,
+		Attempts:      cfg.IntentFilterAttempts,
+		CacheTTL:      cfg.IntentFilterCacheTTL,
+		LocalFallback: cfg.IntentFilterLocalFallback,
+		Logger:        logger,
+	})
+	personaCfg, err := persona.LoadConfigFile(cfg.PersonaConfigFile)
+	if err != nil {
+		logger.Error("load persona config failed", "error", err)
+		os.Exit(1)
+	}
+	personaEngine := persona.NewEngine(personaCfg)
+	var emotionTrace *persona.TraceRecorder
+	if cfg.EmotionTraceFile != "" {
+		traceFile, err := os.OpenFile(cfg.EmotionTraceFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			logger.Error("open emotion trace file failed", "error", err)
+			os.Exit(1)
+		}
+		defer traceFile.Close()
+		emotionTrace = persona.NewTraceRecorder(traceFile)
+	}
+	var contagion orchestrator.EmotionContagionConfig
+	if cfg.EmotionContagionEnabled {
+		contagion = orchestrator.EmotionContagionConfig{Share: cfg.EmotionContagionShare, MinShock: cfg.EmotionContagionMinShock}
+	}
+
+	skillRouter := skills.NewRouter(skillRegistry, mqttHub, skills.NewHTTPExecutor(skills.HTTPConfig{
+		AllowedHosts: cfg.SkillHTTPAllowedHosts,
+		AllowHTTP:    cfg.SkillHTTPAllowHTTP,
+		Timeout:      cfg.ToolTimeout,
+	}))
+
+	if cfg.SMTPHost != "" {
+		mailer, err := email.NewSender(email.Config{
+			Host:              cfg.SMTPHost,
+			Port:              cfg.SMTPPort,
+			Username:          cfg.SMTPUsername,
+			Password:          cfg.SMTPPassword,
+			From:              cfg.SMTPFrom,
+			TLS:               cfg.SMTPTLS,
+			Timeout:           cfg.SMTPTimeout,
+			AllowedRecipients: cfg.EmailAllowedRecipients,
+		}, logger)
+		if err != nil {
+			logger.Error("init email sender failed", "error", err)
+			os.Exit(1)
+		}
+		skillRouter.Handle(email.SkillName, mailer.InvokeSkill)
+		logger.Info("send_email routed to smtp", "host", cfg.SMTPHost, "users", len(cfg.EmailAllowedRecipients))
+	}
+
+	skillRouter.Provide(routines.Definition(), routines.NewCreator(store, skillRegistry).InvokeSkill)
+	go routines.NewScheduler(store, mqttHub, logger).Run(ctx, cfg.RoutineScanInterval)
+
+	var reminderPusher reminders.Pusher
+	if cfg.ReminderPushURL != "" {
+		reminderPusher = reminders.NewWebhookPusher(cfg.ReminderPushURL, cfg.ReminderPushToken, 0)
+	}
+	quietHours := quiethours.NewPolicy(store, cfg.QuietHoursAllowedSkills)
+	if err := quietHours.Load(ctx); err != nil {
+		logger.Error("load quiet hours failed", "error", err)
+		os.Exit(1)
+	}
+	go reminders.NewDispatcher(store, memorySvc, mqttHub, reminderPusher, quietHours, logger).Run(ctx, cfg.ReminderScanInterval)
+	if cfg.MoodAlertEnabled {
+		var moodNotifier moodalerts.Notifier
+		if cfg.MoodAlertWebhookURL != "" {
+			moodNotifier = moodalerts.NewWebhookNotifier(cfg.MoodAlertWebhookURL, cfg.MoodAlertWebhookToken, 0)
+		}
+		go moodalerts.NewWorker(moodalerts.Config{Days: cfg.MoodAlertDays, NegativeShare: cfg.MoodAlertNegativeShare}, store, memorySvc, mqttHub, moodNotifier, quietHours, logger).Run(ctx, time.Hour)
+	}
+
+	var mediaSvc *blob.Service
+	if cfg.BlobEndpoint != "" {
+		blobClient, err := blob.NewS3Client(blob.S3Config{
+			Endpoint:  cfg.BlobEndpoint,
+			Region:    cfg.BlobRegion,
+			Bucket:    cfg.BlobBucket,
+			AccessKey: cfg.BlobAccessKey,
+			SecretKey: cfg.BlobSecretKey,
+			PathStyle: cfg.BlobPathStyle,
+		})
+		if err != nil {
+			logger.Error("init media storage failed", "error", err)
+			os.Exit(1)
+		}
+		mediaSvc = blob.NewService(blobClient, blob.Config{
+			UploadTTL: cfg.BlobUploadTTL,
+			MaxBytes:  cfg.BlobMaxUploadBytes,
+			Retention: time.Duration(cfg.BlobRetentionDays) * 24 * time.Hour,
+		}, logger)
+		go mediaSvc.Run(ctx, time.Hour)
+		logger.Info("media storage enabled", "endpoint", cfg.BlobEndpoint, "bucket", cfg.BlobBucket, "retention_days", cfg.BlobRetentionDays)
+	}
+
+	var knowledgeSvc *knowledge.Service
+	if cfg.KnowledgeEnabled {
+		if err := store.MigrateKnowledge(ctx); err != nil {
+			logger.Error("migrate knowledge base failed (is pgvector installed?)", "error", err)
+			os.Exit(1)
+		}
+		knowledgeSvc = knowledge.New(store, llmProvider, knowledge.Config{
+			ChunkRunes:       cfg.KnowledgeChunkRunes,
+			ChunkOverlap:     cfg.KnowledgeChunkOverlap,
+			MaxDocumentRunes: cfg.KnowledgeMaxDocumentRunes,
+			TopK:             cfg.KnowledgeTopK,
+			MinScore:         cfg.KnowledgeMinScore,
+		})
+		logger.Info("knowledge base enabled", "embedding_model", cfg.LLMEmbeddingModel, "top_k", cfg.KnowledgeTopK)
+	}
+
+	blocklist := cfg.SafetyBlocklist
+	if cfg.SafetyBlocklistFile != "" {
+		terms, err := safety.LoadBlocklist(cfg.SafetyBlocklistFile)
+		if err != nil {
+			logger.Error("load safety blocklist failed", "path", cfg.SafetyBlocklistFile, "error", err)
+			os.Exit(1)
+		}
+		blocklist = append(blocklist, terms...)
+	}
+	var moderator safety.Moderator
+	if cfg.SafetyModerationURL != "" {
+		moderator = safety.NewModerationClient(cfg.SafetyModerationURL, cfg.SafetyModerationAPIKey, cfg.SafetyModerationModel, cfg.SafetyModerationTimeout)
+	}
+	safetyFilter := safety.New(safety.Config{Blocklist: blocklist, Moderator: moderator, Mask: redactor.Mask, Refusal: cfg.SafetyRefusalReply}, store, logger)
+	if safetyFilter != nil {
+		logger.Info("content safety filter enabled", "blocklist_terms", len(blocklist), "moderation", moderator != nil)
+	}
+
+	var topicTracker *topics.Tracker
+	if cfg.TopicTrackingEnabled {
+		extraTopics, err := topics.ParseTopics(cfg.TopicKeywords)
+		if err != nil {
+			logger.Error("parse topic keywords failed", "error", err)
+			os.Exit(1)
+		}
+		topicTracker = topics.New(extraTopics)
+	}
+
+	replyFilters, err := replyfilter.NewPolicy(store, cfg.ReplyFilters, replyfilter.Options{ScrubPhrases: cfg.ReplyScrubPhrases})
+	if err != nil {
+		logger.Error("parse reply filters failed", "error", err)
+		os.Exit(1)
+	}
+	if err := replyFilters.Load(ctx); err != nil {
+		logger.Error("load reply filters failed", "error", err)
+		os.Exit(1)
+	}
+	var mediaFetcher orchestrator.MediaFetcher
+	if mediaSvc != nil {
+		mediaFetcher = mediaSvc
+	}
+	var knowledgeSearcher orchestrator.KnowledgeSearcher
+	if knowledgeSvc != nil {
+		knowledgeSearcher = knowledgeSvc
+	}
 
 	orch := orchestrator.New(orchestrator.Config{
 		UserID:           cfg.UserID,
 		ChatHistoryLimit: cfg.ChatHistoryLimit,
 		ToolTimeout:      cfg.ToolTimeout,
 		LLMModel:         cfg.LLMModel,
-	}, llmProvider, memorySvc, skillRegistry, mqttHub, emotionClient, intentClient, personaEngine, logger)
+
+		SpeakerMatchThreshold: cfg.SpeakerMatchThreshold,
+		FollowUpWindow:        cfg.FollowUpWindow,
+		ClarifyTTL:            cfg.IntentClarifyTTL,
+		QuietHours:            quietHours,
+		Safety:                safetyFilter,
+		ChildMode: orchestrator.ChildModeConfig{
+			BlockedSkills: cfg.ChildModeBlockedSkills,
+			MaxReplyRunes: cfg.ChildModeMaxReplyRunes,
+			PIN:           cfg.ChildModePIN,
+		},
+		Critic: orchestrator.CriticConfig{
+			Mode:    cfg.CriticMode,
+			Model:   cfg.CriticModel,
+			Timeout: cfg.CriticTimeout,
+		},
+		LatencyBudget: orchestrator.LatencyBudgetConfig{
+			Total:   cfg.LatencyBudget,
+			Emotion: cfg.LatencyBudgetEmotion,
+			Intent:  cfg.LatencyBudgetIntent,
+			Mem0:    cfg.LatencyBudgetMem0,
+		},
+		Speculation: orchestrator.SpeculationConfig{
+			Confirm:       cfg.SpeculationConfirm,
+			MinConfidence: cfg.SpeculationMinConfidence,
+			TTL:           cfg.SpeculationTTL,
+		},
+		ToolOutput: orchestrator.ToolOutputConfig{
+			MaxRunes:  cfg.ToolOutputMaxRunes,
+			Limits:    cfg.ToolOutputLimits,
+			Summarize: cfg.ToolOutputSummarize,
+		},
+		Presence: orchestrator.PresenceConfig{
+			Greeting:         cfg.PresenceGreeting,
+			GreetingCooldown: cfg.PresenceGreetingCooldown,
+			TTL:              cfg.PresenceTTL,
+		},
+		Topics:              topicTracker,
+		ReplyFilters:        replyFilters,
+		ExemplarTokenBudget: cfg.SoulExemplarTokenBudget,
+		GateBypassSkills:    cfg.GateBypassSkills,
+		EmotionTrace:        emotionTrace,
+		Contagion:           contagion,
+		RecallCitations:     cfg.MemoryRecallCitations,
+		Replay:              replay.NewRecorder(store, cfg.ReplaySampleRate, logger).Defer(writes),
+		Media:               mediaFetcher,
+		Power:               powerStates,
+		Reminders:           store,
+		Knowledge:           knowledgeSearcher,
+		Publisher:           mqttHub,
+	}, llmProvider, memorySvc, skillRegistry, reminders.NewTracker(skillRouter, store, logger), emotionClient, intentClient, personaEngine, logger)
 	go orch.RunEmotionDecayPublisher(ctx, cfg.EmotionTickInterval)
+	go powerStates.Run(ctx, 30*time.Second)
 
+	apiDoc := openapi.NewDocument("Soul Server API", "v1")
 	r := chi.NewRouter()
+	r.Use(freshReads)
+	r.Get("/openapi.json", apiDoc.Handler())
+	r.Get("/docs", openapi.SwaggerUIHandler("Soul Server API", "/openapi.json"))
+	apiDoc.Add(http.MethodGet, "/healthz", openapi.Operation{Summary: "健康检查", Tags: []string{"system"}, Response: okResponse{}})
 	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 	})
+	r.Handle("/metrics", promhttp.Handler())
+	apiDoc.Add(http.MethodGet, "/v1/users", openapi.Operation{Summary: "列出用户", Tags: []string{"users"}, Response: listResponse[domain.UserProfile]{}})
 	r.Get("/v1/users", func(w http.ResponseWriter, req *http.Request) {
 		items, err := memorySvc.ListUsers(req.Context())
 		if err != nil {
@@ -132,6 +526,7 @@ func main() {
 			"items": items,
 		})
 	})
+	apiDoc.Add(http.MethodPost, "/v1/users", openapi.Operation{Summary: "创建用户", Tags: []string{"users"}, Request: domain.CreateUserPayload{}, Response: domain.UserProfile{}})
 	r.Post("/v1/users", func(w http.ResponseWriter, req *http.Request) {
 		var payload domain.CreateUserPayload
 		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
@@ -145,6 +540,208 @@ func main() {
 		}
 		writeJSON(w, http.StatusOK, item)
 	})
+	apiDoc.Add(http.MethodPut, "/v1/users/{user_id}/language", openapi.Operation{Summary: "设置用户的回复语言（zh / en，空或 auto 表示跟随输入）", Tags: []string{"users"}, Request: domain.UserLanguagePayload{}, Response: domain.UserProfile{}})
+	r.Put("/v1/users/{user_id}/language", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(chi.URLParam(req, "user_id"))
+		var payload domain.UserLanguagePayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		lang := ""
+		if raw := strings.TrimSpace(payload.Language); raw != "" && !strings.EqualFold(raw, "auto") {
+			if lang = language.Normalize(raw); lang == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "language must be zh, en or auto"})
+				return
+			}
+		}
+		item, err := memorySvc.SetUserLanguage(req.Context(), userID, lang)
+		if errors.Is(err, db.ErrUserNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "user not found"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/users/{user_id}/mood_alerts", openapi.Operation{Summary: "列出用户最近 30 次情绪低落提醒", Tags: []string{"users"}, Response: userListResponse[domain.MoodAlert]{}})
+	r.Get("/v1/users/{user_id}/mood_alerts", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(chi.URLParam(req, "user_id"))
+		items, err := store.ListMoodAlerts(req.Context(), userID, 30)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, userListResponse[domain.MoodAlert]{UserID: userID, Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/users/{user_id}/mood_alerts/settings", openapi.Operation{Summary: "查看用户的情绪低落提醒设置（未设置时为服务端默认值）", Tags: []string{"users"}, Response: domain.MoodAlertSettings{}})
+	r.Get("/v1/users/{user_id}/mood_alerts/settings", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(chi.URLParam(req, "user_id"))
+		item, ok, err := store.GetMoodAlertSettings(req.Context(), userID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		if !ok {
+			item = domain.MoodAlertSettings{UserID: userID, Enabled: true, Days: cfg.MoodAlertDays, NegativeShare: cfg.MoodAlertNegativeShare}
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodPut, "/v1/users/{user_id}/mood_alerts/settings", openapi.Operation{Summary: "设置用户的情绪低落提醒（关闭或自定义天数、负面占比）", Tags: []string{"users"}, Request: domain.MoodAlertSettings{}, Response: domain.MoodAlertSettings{}})
+	r.Put("/v1/users/{user_id}/mood_alerts/settings", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(chi.URLParam(req, "user_id"))
+		var payload domain.MoodAlertSettings
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if payload.Days < 0 || payload.Days > moodalerts.MaxDays {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "days must be between 1 and 14"})
+			return
+		}
+		if payload.NegativeShare < 0 || payload.NegativeShare > 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "negative_share must be between 0 and 1"})
+			return
+		}
+		payload.UserID = userID
+		item, err := store.UpsertMoodAlertSettings(req.Context(), payload)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/users/{user_id}/episodes", openapi.Operation{Summary: "按标签、相关人物、重要度、情绪筛选用户的会话记忆片段（tag 可逗号分隔，命中任一即可）", Tags: []string{"users"}, QueryParams: []string{"soul_id", "tag", "participant", "min_importance", "sentiment", "limit", "fresh"}, Response: userListResponse[domain.MemoryEpisode]{}})
+	r.Get("/v1/users/{user_id}/episodes", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(chi.URLParam(req, "user_id"))
+		q := req.URL.Query()
+		filter := domain.EpisodeFilter{
+			SoulID:      strings.TrimSpace(q.Get("soul_id")),
+			Participant: strings.TrimSpace(q.Get("participant")),
+			Sentiment:   strings.TrimSpace(q.Get("sentiment")),
+			Limit:       20,
+		}
+		for _, tag := range strings.Split(q.Get("tag"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				filter.Tags = append(filter.Tags, tag)
+			}
+		}
+		switch filter.Sentiment {
+		case "", "positive", "negative", "neutral":
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "sentiment must be positive, negative or neutral"})
+			return
+		}
+		if v := q.Get("min_importance"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 5 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "min_importance must be between 1 and 5"})
+				return
+			}
+			filter.MinImportance = n
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "limit must be between 1 and 100"})
+				return
+			}
+			filter.Limit = n
+		}
+		items, err := store.ListMemoryEpisodes(req.Context(), userID, filter)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, userListResponse[domain.MemoryEpisode]{UserID: userID, Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/users/{user_id}/memory_corrections", openapi.Operation{Summary: "列出用户最近 50 次记忆更正", Tags: []string{"users"}, Response: userListResponse[domain.MemoryCorrection]{}})
+	r.Get("/v1/users/{user_id}/memory_corrections", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(chi.URLParam(req, "user_id"))
+		items, err := store.ListMemoryCorrections(req.Context(), userID, 50)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, userListResponse[domain.MemoryCorrection]{UserID: userID, Items: items})
+	})
+	apiDoc.Add(http.MethodPost, "/v1/users/{user_id}/memory_corrections", openapi.Operation{Summary: "手动更正一条记忆（按 memory_id、episode_id 或 original 定位；correction 为空即删除）", Tags: []string{"users"}, Request: domain.MemoryCorrection{}, Response: domain.MemoryCorrection{}})
+	r.Post("/v1/users/{user_id}/memory_corrections", func(w http.ResponseWriter, req *http.Request) {
+		var payload domain.MemoryCorrection
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		payload.UserID = strings.TrimSpace(chi.URLParam(req, "user_id"))
+		payload.Source = domain.MemoryCorrectionSourceAPI
+		item, err := memorySvc.CorrectMemory(req.Context(), payload)
+		if err != nil {
+			switch {
+			case errors.Is(err, memory.ErrCorrectionTarget):
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			case errors.Is(err, memory.ErrMemoryNotFound), errors.Is(err, db.ErrEpisodeNotFound):
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+			case errors.Is(err, memory.ErrMem0Correction):
+				writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+			default:
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/users/{user_id}/activity", openapi.Operation{Summary: "分页列出机器人为用户做过的事：技能调用、意图动作、已响的提醒与闹钟、主动关怀（新的在前）", Tags: []string{"users"}, QueryParams: []string{"since", "cursor", "limit", "fresh"}, Response: domain.ActivityPage{}})
+	r.Get("/v1/users/{user_id}/activity", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(chi.URLParam(req, "user_id"))
+		q := req.URL.Query()
+		filter := domain.ActivityFilter{Cursor: strings.TrimSpace(q.Get("cursor")), Limit: 20}
+		if v := strings.TrimSpace(q.Get("since")); v != "" {
+			since, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "since must be RFC3339"})
+				return
+			}
+			filter.Since = since
+		}
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "limit must be between 1 and 100"})
+				return
+			}
+			filter.Limit = n
+		}
+		page, err := memorySvc.ListUserActivity(req.Context(), userID, filter)
+		if err != nil {
+			if errors.Is(err, memory.ErrActivityCursor) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, page)
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/users/{user_id}/data", openapi.Operation{Summary: "删除用户的全部数据（会话、消息、摘要、mem0 记忆、关系、声纹、提醒等）并留存审计记录", Tags: []string{"users"}, Response: domain.UserDataPurge{}})
+	r.Delete("/v1/users/{user_id}/data", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(chi.URLParam(req, "user_id"))
+		result, err := memorySvc.PurgeUserData(req.Context(), userID)
+		if err != nil {
+			if errors.Is(err, memory.ErrMem0Purge) {
+				writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		if err := quietHours.Load(req.Context()); err != nil {
+			logger.Warn("reload quiet hours after purge failed", "user_id", userID, "error", err)
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/souls", openapi.Operation{Summary: "列出用户的灵魂", Tags: []string{"souls"}, QueryParams: []string{"user_id"}, Response: userListResponse[domain.SoulProfile]{}})
 	r.Get("/v1/souls", func(w http.ResponseWriter, req *http.Request) {
 		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
 		if userID == "" {
@@ -160,6 +757,7 @@ func main() {
 			"items":   items,
 		})
 	})
+	apiDoc.Add(http.MethodPost, "/v1/souls", openapi.Operation{Summary: "创建灵魂", Tags: []string{"souls"}, Request: domain.CreateSoulPayload{}, Response: domain.SoulProfile{}})
 	r.Post("/v1/souls", func(w http.ResponseWriter, req *http.Request) {
 		var payload domain.CreateSoulPayload
 		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
@@ -172,6 +770,20 @@ func main() {
 		}
 		name := strings.TrimSpace(payload.Name)
 		mbti := strings.ToUpper(strings.TrimSpace(payload.MBTIType))
+		state := persona.InitialEmotionState(time.Now().UTC())
+		if template := strings.TrimSpace(payload.Template); template != "" {
+			if name == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "name is required"})
+				return
+			}
+			profile, err := memorySvc.CreateSoulFromTemplate(req.Context(), userID, name, template, state, persona.ModelVersion)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, profile)
+			return
+		}
 		if name == "" || mbti == "" {
 			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "name and mbti_type are required"})
 			return
@@ -181,7 +793,6 @@ func main() {
 			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
 			return
 		}
-		state := persona.InitialEmotionState(time.Now().UTC())
 		profile, err := memorySvc.CreateSoulProfile(req.Context(), userID, name, mbti, vector, state, persona.ModelVersion)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
@@ -189,6 +800,32 @@ func main() {
 		}
 		writeJSON(w, http.StatusOK, profile)
 	})
+	apiDoc.Add(http.MethodGet, "/v1/soul_templates", openapi.Operation{Summary: "列出内置灵魂模板（助理型 / 陪伴型 / 毒舌型），创建灵魂时以 template 选用", Tags: []string{"souls"}, Response: listResponse[domain.SoulTemplate]{}})
+	r.Get("/v1/soul_templates", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, listResponse[domain.SoulTemplate]{Items: memory.SoulTemplates()})
+	})
+	apiDoc.Add(http.MethodPost, "/v1/souls/{soul_id}/clone", openapi.Operation{Summary: "复制灵魂的性格、角色设定与示范对话（不复制记忆、关系与日记）", Tags: []string{"souls"}, Request: domain.CloneSoulPayload{}, Response: domain.SoulProfile{}})
+	r.Post("/v1/souls/{soul_id}/clone", func(w http.ResponseWriter, req *http.Request) {
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		var payload domain.CloneSoulPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		state := persona.InitialEmotionState(time.Now().UTC())
+		profile, err := memorySvc.CloneSoul(req.Context(), soulID, strings.TrimSpace(payload.UserID), strings.TrimSpace(payload.Name), state, persona.ModelVersion)
+		if errors.Is(err, db.ErrSoulNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "soul not found"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		logger.Info("soul cloned", "source_soul_id", soulID, "soul_id", profile.SoulID)
+		writeJSON(w, http.StatusOK, profile)
+	})
+	apiDoc.Add(http.MethodPost, "/v1/souls/select", openapi.Operation{Summary: "绑定终端与灵魂", Tags: []string{"souls"}, Request: domain.SelectSoulPayload{}, Response: selectSoulResponse{}})
 	r.Post("/v1/souls/select", func(w http.ResponseWriter, req *http.Request) {
 		var payload domain.SelectSoulPayload
 		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
@@ -215,6 +852,7 @@ func main() {
 			"soul_id":     payload.SoulID,
 		})
 	})
+	apiDoc.Add(http.MethodGet, "/v1/souls/{soul_id}/relations", openapi.Operation{Summary: "列出灵魂的用户关系", Tags: []string{"souls"}, Response: soulListResponse[domain.SoulUserRelation]{}})
 	r.Get("/v1/souls/{soul_id}/relations", func(w http.ResponseWriter, req *http.Request) {
 		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
 		if soulID == "" {
@@ -231,6 +869,7 @@ func main() {
 			"items":   items,
 		})
 	})
+	apiDoc.Add(http.MethodPost, "/v1/souls/{soul_id}/relations", openapi.Operation{Summary: "创建灵魂的用户关系", Tags: []string{"souls"}, Request: domain.CreateSoulUserRelationPayload{}, Response: domain.SoulUserRelation{}})
 	r.Post("/v1/souls/{soul_id}/relations", func(w http.ResponseWriter, req *http.Request) {
 		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
 		if soulID == "" {
@@ -249,53 +888,1184 @@ func main() {
 		}
 		writeJSON(w, http.StatusOK, item)
 	})
-	r.Post("/v1/chat", func(w http.ResponseWriter, req *http.Request) {
-		var chatReq domain.ChatRequest
-		if err := json.NewDecoder(req.Body).Decode(&chatReq); err != nil {
+	apiDoc.Add(http.MethodPost, "/v1/souls/{soul_id}/child_mode", openapi.Operation{Summary: "开关灵魂的儿童模式", Tags: []string{"souls"}, Request: domain.SoulChildModePayload{}, Response: domain.SoulProfile{}})
+	r.Post("/v1/souls/{soul_id}/child_mode", func(w http.ResponseWriter, req *http.Request) {
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		if soulID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "soul_id is required"})
+			return
+		}
+		var payload domain.SoulChildModePayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
 			return
 		}
-		if chatReq.SessionID == "" || chatReq.TerminalID == "" {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "session_id and terminal_id are required"})
+		item, err := memorySvc.SetSoulChildMode(req.Context(), soulID, payload.Enabled)
+		if errors.Is(err, db.ErrSoulNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "soul not found"})
 			return
 		}
-		if len(chatReq.Inputs) == 0 {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "inputs is required"})
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
 			return
 		}
-		if !hasKeyboardTextInput(chatReq.Inputs) {
-			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "currently only input.type=keyboard_text|speech_text with non-empty text is supported"})
+		logger.Info("soul child mode updated", "soul_id", soulID, "child_mode", payload.Enabled)
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/souls/{soul_id}/prompt", openapi.Operation{Summary: "查询灵魂的角色设定（背景、说话风格、避免的话题）", Tags: []string{"souls"}, Response: domain.SoulPrompt{}})
+	r.Get("/v1/souls/{soul_id}/prompt", func(w http.ResponseWriter, req *http.Request) {
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		item, err := memorySvc.GetSoulProfileByID(req.Context(), soulID)
+		if errors.Is(err, db.ErrSoulNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "soul not found"})
 			return
 		}
-
-		resp, err := orch.HandleChat(req.Context(), chatReq)
 		if err != nil {
-			if errors.Is(err, db.ErrSoulSelectionRequired) || errors.Is(err, db.ErrSoulNotFound) {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, soulPrompt(item))
+	})
+	apiDoc.Add(http.MethodPut, "/v1/souls/{soul_id}/prompt", openapi.Operation{Summary: "设置灵魂的角色设定，追加到系统提示词（字段全空时清除）", Tags: []string{"souls"}, Request: domain.SoulCharacterCard{}, Response: domain.SoulPrompt{}})
+	r.Put("/v1/souls/{soul_id}/prompt", func(w http.ResponseWriter, req *http.Request) {
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		if soulID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "soul_id is required"})
+			return
+		}
+		var payload domain.SoulCharacterCard
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		item, err := memorySvc.SetSoulCharacterCard(req.Context(), soulID, payload)
+		if err != nil {
+			switch {
+			case errors.Is(err, memory.ErrInvalidCharacterCard):
 				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
-				return
+			case errors.Is(err, db.ErrSoulNotFound):
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": "soul not found"})
+			default:
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
 			}
-			logger.Error("chat failed", "error", err)
-			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
 			return
 		}
-
-		writeJSON(w, http.StatusOK, resp)
+		logger.Info("soul character card updated", "soul_id", soulID, "cleared", item.CharacterCard == nil)
+		writeJSON(w, http.StatusOK, soulPrompt(item))
 	})
-
-	httpServer := &http.Server{
-		Addr:              cfg.HTTPAddr,
-		Handler:           r,
-		ReadHeaderTimeout: 5 * time.Second,
-	}
-
-	go func() {
-		logger.Info("soul server started", "addr", cfg.HTTPAddr)
-		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.Error("http server error", "error", err)
-			cancel()
+	apiDoc.Add(http.MethodGet, "/v1/souls/{soul_id}/exemplars", openapi.Operation{Summary: "列出灵魂的说话风格示范对话", Tags: []string{"souls"}, Response: soulListResponse[domain.SoulExemplar]{}})
+	r.Get("/v1/souls/{soul_id}/exemplars", func(w http.ResponseWriter, req *http.Request) {
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		items, err := memorySvc.ListSoulExemplars(req.Context(), soulID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
 		}
-	}()
-
+		writeJSON(w, http.StatusOK, soulListResponse[domain.SoulExemplar]{SoulID: soulID, Items: items})
+	})
+	apiDoc.Add(http.MethodPost, "/v1/souls/{soul_id}/exemplars", openapi.Operation{Summary: "为灵魂添加一组示范对话（用户说法与灵魂回复）", Tags: []string{"souls"}, Request: domain.CreateSoulExemplarPayload{}, Response: domain.SoulExemplar{}})
+	r.Post("/v1/souls/{soul_id}/exemplars", func(w http.ResponseWriter, req *http.Request) {
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		var payload domain.CreateSoulExemplarPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		item, err := memorySvc.AddSoulExemplar(req.Context(), soulID, payload)
+		if err != nil {
+			switch {
+			case errors.Is(err, memory.ErrInvalidExemplar):
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			case errors.Is(err, db.ErrSoulNotFound):
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": "soul not found"})
+			default:
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/souls/{soul_id}/exemplars/{id}", openapi.Operation{Summary: "删除灵魂的一组示范对话", Tags: []string{"souls"}, Response: okResponse{}})
+	r.Delete("/v1/souls/{soul_id}/exemplars/{id}", func(w http.ResponseWriter, req *http.Request) {
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "id must be an integer"})
+			return
+		}
+		if err := memorySvc.DeleteSoulExemplar(req.Context(), soulID, id); err != nil {
+			if errors.Is(err, db.ErrExemplarNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, okResponse{OK: true})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/souls/{soul_id}/knowledge", openapi.Operation{Summary: "列出灵魂的知识库资料", Tags: []string{"souls"}, Response: soulListResponse[domain.KnowledgeDocument]{}})
+	r.Get("/v1/souls/{soul_id}/knowledge", func(w http.ResponseWriter, req *http.Request) {
+		if knowledgeSvc == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "knowledge base is not enabled"})
+			return
+		}
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		items, err := knowledgeSvc.ListDocuments(req.Context(), soulID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, soulListResponse[domain.KnowledgeDocument]{SoulID: soulID, Items: items})
+	})
+	apiDoc.Add(http.MethodPost, "/v1/souls/{soul_id}/knowledge", openapi.Operation{Summary: "上传一份资料（说明书、笔记等纯文本）到灵魂的知识库，切块并向量化后供 search_knowledge 检索", Tags: []string{"souls"}, Request: domain.KnowledgeDocumentPayload{}, Response: domain.KnowledgeDocument{}})
+	r.Post("/v1/souls/{soul_id}/knowledge", func(w http.ResponseWriter, req *http.Request) {
+		if knowledgeSvc == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "knowledge base is not enabled"})
+			return
+		}
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		var payload domain.KnowledgeDocumentPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		item, err := knowledgeSvc.AddDocument(req.Context(), soulID, payload.Title, payload.Content)
+		if err != nil {
+			switch {
+			case errors.Is(err, knowledge.ErrInvalidDocument):
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			case errors.Is(err, db.ErrSoulNotFound):
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": "soul not found"})
+			case errors.Is(err, llm.ErrEmbeddingUnsupported):
+				writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": err.Error()})
+			default:
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			}
+			return
+		}
+		logger.Info("knowledge document added", "soul_id", soulID, "document_id", item.ID, "chunks", item.ChunkCount)
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/souls/{soul_id}/knowledge/{id}", openapi.Operation{Summary: "从灵魂的知识库删除一份资料", Tags: []string{"souls"}, Response: okResponse{}})
+	r.Delete("/v1/souls/{soul_id}/knowledge/{id}", func(w http.ResponseWriter, req *http.Request) {
+		if knowledgeSvc == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "knowledge base is not enabled"})
+			return
+		}
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "id must be an integer"})
+			return
+		}
+		if err := knowledgeSvc.DeleteDocument(req.Context(), soulID, id); err != nil {
+			if errors.Is(err, db.ErrKnowledgeDocumentNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, okResponse{OK: true})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/souls/{soul_id}/diary", openapi.Operation{Summary: "列出灵魂最近 30 篇日记（新的在前）", Tags: []string{"souls"}, Response: soulListResponse[domain.SoulDiaryEntry]{}})
+	r.Get("/v1/souls/{soul_id}/diary", func(w http.ResponseWriter, req *http.Request) {
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		items, err := memorySvc.ListSoulDiary(req.Context(), soulID, 30)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, soulListResponse[domain.SoulDiaryEntry]{SoulID: soulID, Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/souls/{soul_id}/diary/{day}", openapi.Operation{Summary: "查询灵魂某一天（YYYY-MM-DD）的日记", Tags: []string{"souls"}, Response: domain.SoulDiaryEntry{}})
+	r.Get("/v1/souls/{soul_id}/diary/{day}", func(w http.ResponseWriter, req *http.Request) {
+		soulID := strings.TrimSpace(chi.URLParam(req, "soul_id"))
+		item, err := memorySvc.GetSoulDiaryEntry(req.Context(), soulID, strings.TrimSpace(chi.URLParam(req, "day")))
+		if err != nil {
+			switch {
+			case errors.Is(err, memory.ErrInvalidDiaryDay):
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			case errors.Is(err, db.ErrDiaryEntryNotFound):
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+			default:
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodPost, "/v1/chat", openapi.Operation{Summary: "主对话入口", Tags: []string{"chat"}, Request: domain.ChatRequest{}, Response: domain.ChatResponse{}})
+	r.Post("/v1/chat", func(w http.ResponseWriter, req *http.Request) {
+		var chatReq domain.ChatRequest
+		if err := json.NewDecoder(req.Body).Decode(&chatReq); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if chatReq.SessionID == "" || chatReq.TerminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "session_id and terminal_id are required"})
+			return
+		}
+		if len(chatReq.Inputs) == 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "inputs is required"})
+			return
+		}
+		if !hasKeyboardTextInput(chatReq.Inputs) && !orchestrator.PresenceOnly(chatReq.Inputs) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "currently only input.type=keyboard_text|speech_text with non-empty text, or presence events, is supported"})
+			return
+		}
+		if mediaSvc != nil {
+			for _, in := range chatReq.Inputs {
+				if err := mediaSvc.CheckMedia(chatReq.TerminalID, in.Media); err != nil {
+					writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error(), "input_id": in.InputID})
+					return
+				}
+			}
+		}
+		if token := req.Header.Get(gateOverrideHeader); token != "" {
+			if !gateOverrideAllowed(cfg.GateOverrideToken, token) {
+				writeJSON(w, http.StatusForbidden, map[string]any{"error": "invalid gate override token"})
+				return
+			}
+			chatReq.ForceExecute = true
+		}
+
+		resp, err := orch.HandleChat(req.Context(), chatReq)
+		if err != nil {
+			if errors.Is(err, db.ErrSoulSelectionRequired) || errors.Is(err, db.ErrSoulNotFound) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			logger.Error("chat failed", "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	})
+	apiDoc.Add(http.MethodPost, "/v1/intents/speculate", openapi.Operation{Summary: "用 ASR 中间结果预先匹配意图；连续稳定后，文字相同的最终结果到达 /v1/chat 时立即下发", Tags: []string{"chat"}, Request: domain.IntentSpeculationRequest{}, Response: domain.IntentSpeculation{}})
+	r.Post("/v1/intents/speculate", func(w http.ResponseWriter, req *http.Request) {
+		var body domain.IntentSpeculationRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if strings.TrimSpace(body.TerminalID) == "" || strings.TrimSpace(body.UtteranceID) == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id and utterance_id are required"})
+			return
+		}
+		writeJSON(w, http.StatusOK, orch.SpeculateIntent(req.Context(), body))
+	})
+
+	apiDoc.Add(http.MethodPost, "/v1/media/uploads", openapi.Operation{Summary: "申请照片/音频的预签名上传地址，上传后把 media 填入 ChatInput", Tags: []string{"chat"}, Request: domain.MediaUploadRequest{}, Response: domain.MediaUpload{}})
+	r.Post("/v1/media/uploads", func(w http.ResponseWriter, req *http.Request) {
+		if mediaSvc == nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": "media storage is not configured"})
+			return
+		}
+		var body domain.MediaUploadRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		upload, err := mediaSvc.CreateUpload(body)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, upload)
+	})
+
+	apiDoc.Add(http.MethodGet, "/v1/speakers", openapi.Operation{Summary: "列出已注册的说话人声纹", Tags: []string{"speakers"}, QueryParams: []string{"user_id"}, Response: userListResponse[domain.SpeakerProfile]{}})
+	r.Get("/v1/speakers", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		items, err := memorySvc.ListSpeakerProfiles(req.Context(), userID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"user_id": userID,
+			"items":   items,
+		})
+	})
+	apiDoc.Add(http.MethodPost, "/v1/speakers/enroll", openapi.Operation{Summary: "注册或追加说话人声纹样本", Tags: []string{"speakers"}, Request: domain.EnrollSpeakerPayload{}, Response: domain.SpeakerProfile{}})
+	r.Post("/v1/speakers/enroll", func(w http.ResponseWriter, req *http.Request) {
+		var payload domain.EnrollSpeakerPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if strings.TrimSpace(payload.UserID) == "" {
+			payload.UserID = cfg.UserID
+		}
+		item, err := memorySvc.EnrollSpeaker(req.Context(), payload)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodPut, "/v1/speakers/{speaker_id}/face", openapi.Operation{Summary: "绑定摄像头人脸 ID 到说话人（空 face_id 解绑），用于 presence 输入识别", Tags: []string{"speakers"}, Request: domain.BindSpeakerFacePayload{}, Response: domain.SpeakerProfile{}})
+	r.Put("/v1/speakers/{speaker_id}/face", func(w http.ResponseWriter, req *http.Request) {
+		var payload domain.BindSpeakerFacePayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if strings.TrimSpace(payload.UserID) == "" {
+			payload.UserID = cfg.UserID
+		}
+		item, err := memorySvc.BindSpeakerFace(req.Context(), payload.UserID, chi.URLParam(req, "speaker_id"), payload.FaceID)
+		if err != nil {
+			if errors.Is(err, db.ErrSpeakerNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodPost, "/v1/sessions/{session_id}/handoff", openapi.Operation{Summary: "将会话转移到其他终端", Tags: []string{"sessions"}, Request: domain.SessionHandoffPayload{}, Response: domain.SessionHandoffResult{}})
+	r.Post("/v1/sessions/{session_id}/handoff", func(w http.ResponseWriter, req *http.Request) {
+		sessionID := strings.TrimSpace(chi.URLParam(req, "session_id"))
+		var payload domain.SessionHandoffPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if sessionID == "" || strings.TrimSpace(payload.TerminalID) == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "session_id and terminal_id are required"})
+			return
+		}
+		result, err := orch.HandoffSession(req.Context(), sessionID, payload.TerminalID)
+		if err != nil {
+			if errors.Is(err, db.ErrSessionNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/sessions/{session_id}/messages", openapi.Operation{Summary: "列出会话消息（含消息 ID）", Tags: []string{"sessions"}, QueryParams: []string{"fresh"}, Response: sessionListResponse[domain.SessionMessage]{}})
+	r.Get("/v1/sessions/{session_id}/messages", func(w http.ResponseWriter, req *http.Request) {
+		sessionID := strings.TrimSpace(chi.URLParam(req, "session_id"))
+		if sessionID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "session_id is required"})
+			return
+		}
+		items, err := memorySvc.ListSessionMessages(req.Context(), sessionID, 500)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, sessionListResponse[domain.SessionMessage]{SessionID: sessionID, Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/sessions/{session_id}/export", openapi.Operation{Summary: "导出会话（Markdown 或 JSON），含工具调用、时间与逐轮情绪标注", Tags: []string{"sessions"}, QueryParams: []string{"format", "fresh"}, Response: domain.SessionExport{}})
+	r.Get("/v1/sessions/{session_id}/export", func(w http.ResponseWriter, req *http.Request) {
+		sessionID := strings.TrimSpace(chi.URLParam(req, "session_id"))
+		if sessionID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "session_id is required"})
+			return
+		}
+		format := strings.TrimSpace(req.URL.Query().Get("format"))
+		switch format {
+		case "":
+			format = "json"
+		case "json", "md":
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "format must be md or json"})
+			return
+		}
+		export, err := memorySvc.ExportSession(req.Context(), sessionID)
+		if err != nil {
+			if errors.Is(err, db.ErrSessionNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote("session-"+sessionID+"."+format))
+		if format == "json" {
+			writeJSON(w, http.StatusOK, export)
+			return
+		}
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(memory.RenderSessionMarkdown(export)))
+	})
+	apiDoc.Add(http.MethodGet, "/v1/sessions/{session_id}/topics", openapi.Operation{Summary: "列出会话涉及的话题及轮数", Tags: []string{"sessions"}, Response: sessionListResponse[domain.SessionTopic]{}})
+	r.Get("/v1/sessions/{session_id}/topics", func(w http.ResponseWriter, req *http.Request) {
+		sessionID := strings.TrimSpace(chi.URLParam(req, "session_id"))
+		if sessionID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "session_id is required"})
+			return
+		}
+		items, err := memorySvc.ListSessionTopics(req.Context(), sessionID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, sessionListResponse[domain.SessionTopic]{SessionID: sessionID, Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/sessions/{session_id}/affect", openapi.Operation{Summary: "会话情感报告：逐轮用户情绪、灵魂 PAD 轨迹与门控决策", Tags: []string{"sessions"}, Response: domain.SessionAffect{}})
+	r.Get("/v1/sessions/{session_id}/affect", func(w http.ResponseWriter, req *http.Request) {
+		sessionID := strings.TrimSpace(chi.URLParam(req, "session_id"))
+		if sessionID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "session_id is required"})
+			return
+		}
+		report, err := memorySvc.SessionAffect(req.Context(), sessionID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+	apiDoc.Add(http.MethodPost, "/v1/sessions/{session_id}/fork", openapi.Operation{Summary: "复制会话历史到新会话用于回放对比", Tags: []string{"sessions"}, Request: domain.SessionForkPayload{}, Response: domain.SessionForkResult{}})
+	r.Post("/v1/sessions/{session_id}/fork", func(w http.ResponseWriter, req *http.Request) {
+		sessionID := strings.TrimSpace(chi.URLParam(req, "session_id"))
+		var payload domain.SessionForkPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if sessionID == "" || payload.UpToMessageID < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "session_id is required and up_to_message_id must not be negative"})
+			return
+		}
+		result, err := memorySvc.ForkSession(req.Context(), sessionID, payload.SessionID, payload.UpToMessageID)
+		if err != nil {
+			switch {
+			case errors.Is(err, db.ErrSessionNotFound), errors.Is(err, db.ErrMessageNotFound):
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+			case errors.Is(err, db.ErrSessionExists):
+				writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error()})
+			default:
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			}
+			return
+		}
+		logger.Info("session forked", "source_session_id", sessionID, "session_id", result.SessionID, "up_to_message_id", result.UpToMessageID, "copied_messages", result.CopiedMessages)
+		writeJSON(w, http.StatusOK, result)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/sessions/{session_id}/private", openapi.Operation{Summary: "查询会话是否处于隐私模式", Tags: []string{"sessions"}, Response: domain.SessionPrivacySetting{}})
+	r.Get("/v1/sessions/{session_id}/private", func(w http.ResponseWriter, req *http.Request) {
+		sessionID := strings.TrimSpace(chi.URLParam(req, "session_id"))
+		private, err := memorySvc.IsSessionPrivate(req.Context(), sessionID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, domain.SessionPrivacySetting{SessionID: sessionID, Private: private})
+	})
+	apiDoc.Add(http.MethodPost, "/v1/sessions/{session_id}/private", openapi.Operation{Summary: "开启或关闭会话隐私模式（不保存消息、不生成摘要、不写入长期记忆）", Tags: []string{"sessions"}, Request: domain.SessionPrivacyPayload{}, Response: domain.SessionPrivacySetting{}})
+	r.Post("/v1/sessions/{session_id}/private", func(w http.ResponseWriter, req *http.Request) {
+		sessionID := strings.TrimSpace(chi.URLParam(req, "session_id"))
+		var payload domain.SessionPrivacyPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if sessionID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "session_id is required"})
+			return
+		}
+		userID := strings.TrimSpace(payload.UserID)
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		if err := memorySvc.SetSessionPrivate(req.Context(), sessionID, userID, payload.Private); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		logger.Info("session privacy updated", "session_id", sessionID, "private", payload.Private)
+		writeJSON(w, http.StatusOK, domain.SessionPrivacySetting{SessionID: sessionID, Private: payload.Private})
+	})
+	// notifyBundles asks a terminal to pull its bundles again; it is best
+	// effort, since terminals also pull when they come online.
+	notifyBundles := func(ctx context.Context, terminalID string) {
+		bundles := marketplace.Enabled(terminalID)
+		names := make([]string, 0, len(bundles))
+		for _, bundle := range bundles {
+			names = append(names, bundle.Name)
+		}
+		if err := mqttHub.PublishStatus(ctx, terminalID, domain.TerminalStatusSkillBundlesUpdated, strings.Join(names, ","), ""); err != nil {
+			logger.Warn("notify skill bundles failed", "terminal_id", terminalID, "error", err)
+		}
+	}
+	apiDoc.Add(http.MethodGet, "/v1/reminders", openapi.Operation{Summary: "列出用户最近 100 条提醒（含已送达）", Tags: []string{"reminders"}, QueryParams: []string{"user_id"}, Response: userListResponse[domain.Reminder]{}})
+	r.Get("/v1/reminders", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		items, err := store.ListReminders(req.Context(), userID, 100)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, userListResponse[domain.Reminder]{UserID: userID, Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/routines", openapi.Operation{Summary: "列出用户在对话中创建的例行任务", Tags: []string{"routines"}, QueryParams: []string{"user_id"}, Response: userListResponse[domain.Routine]{}})
+	r.Get("/v1/routines", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		items, err := store.ListRoutines(req.Context(), userID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, userListResponse[domain.Routine]{UserID: userID, Items: items})
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/routines/{id}", openapi.Operation{Summary: "删除例行任务", Tags: []string{"routines"}, QueryParams: []string{"user_id"}})
+	r.Delete("/v1/routines/{id}", func(w http.ResponseWriter, req *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "id must be an integer"})
+			return
+		}
+		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		if err := store.DeleteRoutine(req.Context(), userID, id); err != nil {
+			if errors.Is(err, db.ErrRoutineNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/quiet_hours", openapi.Operation{Summary: "查询用户的免打扰时段", Tags: []string{"quiet_hours"}, QueryParams: []string{"user_id"}, Response: userListResponse[domain.QuietHours]{}})
+	r.Get("/v1/quiet_hours", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		writeJSON(w, http.StatusOK, userListResponse[domain.QuietHours]{UserID: userID, Items: quietHours.List(userID)})
+	})
+	apiDoc.Add(http.MethodPut, "/v1/quiet_hours", openapi.Operation{Summary: "设置免打扰时段（terminal_id 为空时作用于该用户全部终端）", Tags: []string{"quiet_hours"}, Request: domain.SetQuietHoursPayload{}, Response: domain.QuietHours{}})
+	r.Put("/v1/quiet_hours", func(w http.ResponseWriter, req *http.Request) {
+		var payload domain.SetQuietHoursPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		rule := domain.QuietHours{UserID: payload.UserID, TerminalID: payload.TerminalID, Start: payload.Start, End: payload.End, Enabled: payload.Enabled == nil || *payload.Enabled}
+		if strings.TrimSpace(rule.UserID) == "" {
+			rule.UserID = cfg.UserID
+		}
+		item, err := quietHours.Set(req.Context(), rule)
+		if err != nil {
+			if errors.Is(err, quiethours.ErrInvalidRule) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/quiet_hours", openapi.Operation{Summary: "删除免打扰时段", Tags: []string{"quiet_hours"}, QueryParams: []string{"user_id", "terminal_id"}})
+	r.Delete("/v1/quiet_hours", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		if err := quietHours.Delete(req.Context(), userID, strings.TrimSpace(req.URL.Query().Get("terminal_id"))); err != nil {
+			if errors.Is(err, db.ErrQuietHoursNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/safety_incidents", openapi.Operation{Summary: "列出用户最近 100 条内容安全拦截记录", Tags: []string{"safety"}, QueryParams: []string{"user_id"}, Response: userListResponse[domain.SafetyIncident]{}})
+	r.Get("/v1/safety_incidents", func(w http.ResponseWriter, req *http.Request) {
+		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		items, err := store.ListSafetyIncidents(req.Context(), userID, 100)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, userListResponse[domain.SafetyIncident]{UserID: userID, Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/skill_bundles", openapi.Operation{Summary: "列出技能包", Tags: []string{"skill_bundles"}, Response: listResponse[domain.SkillBundle]{}})
+	r.Get("/v1/skill_bundles", func(w http.ResponseWriter, req *http.Request) {
+		items, err := marketplace.List(req.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, listResponse[domain.SkillBundle]{Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/skill_bundles/{name}", openapi.Operation{Summary: "查询技能包", Tags: []string{"skill_bundles"}, Response: domain.SkillBundle{}})
+	r.Get("/v1/skill_bundles/{name}", func(w http.ResponseWriter, req *http.Request) {
+		item, err := marketplace.Get(req.Context(), chi.URLParam(req, "name"))
+		if err != nil {
+			if errors.Is(err, db.ErrSkillBundleNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodPut, "/v1/skill_bundles/{name}", openapi.Operation{Summary: "发布技能包（同名再次发布时版本号递增）", Tags: []string{"skill_bundles"}, Request: domain.PublishSkillBundlePayload{}, Response: domain.SkillBundle{}})
+	r.Put("/v1/skill_bundles/{name}", func(w http.ResponseWriter, req *http.Request) {
+		var payload domain.PublishSkillBundlePayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		item, err := marketplace.Publish(req.Context(), chi.URLParam(req, "name"), payload)
+		if err != nil {
+			if errors.Is(err, skills.ErrInvalidBundle) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		logger.Info("skill bundle published", "name", item.Name, "version", item.Version, "skills", len(item.Skills), "intents", len(item.Intents))
+		for _, terminalID := range marketplace.Subscribers(item.Name) {
+			notifyBundles(req.Context(), terminalID)
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/version_requirements", openapi.Operation{Summary: "列出各能力要求的最低固件版本", Tags: []string{"skill_bundles"}, Response: listResponse[domain.VersionRequirement]{}})
+	r.Get("/v1/version_requirements", func(w http.ResponseWriter, req *http.Request) {
+		items, err := rollout.List(req.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, listResponse[domain.VersionRequirement]{Items: items})
+	})
+	apiDoc.Add(http.MethodPut, "/v1/version_requirements/{capability}", openapi.Operation{Summary: "设置能力（技能名）要求的最低固件版本", Tags: []string{"skill_bundles"}, Request: domain.VersionRequirementPayload{}, Response: domain.VersionRequirement{}})
+	r.Put("/v1/version_requirements/{capability}", func(w http.ResponseWriter, req *http.Request) {
+		var payload domain.VersionRequirementPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		item, err := rollout.Set(req.Context(), chi.URLParam(req, "capability"), payload.MinFirmwareVersion)
+		if err != nil {
+			if errors.Is(err, skills.ErrInvalidRequirement) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		logger.Info("version requirement updated", "capability", item.Capability, "min_firmware_version", item.MinFirmwareVersion)
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/version_requirements/{capability}", openapi.Operation{Summary: "删除能力的最低固件版本要求", Tags: []string{"skill_bundles"}, Response: okResponse{}})
+	r.Delete("/v1/version_requirements/{capability}", func(w http.ResponseWriter, req *http.Request) {
+		if err := rollout.Delete(req.Context(), chi.URLParam(req, "capability")); err != nil {
+			if errors.Is(err, db.ErrRequirementNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, okResponse{OK: true})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/versions", openapi.Operation{Summary: "查询终端上报的固件与技能包版本及需要的升级", Tags: []string{"terminals", "skill_bundles"}, Response: domain.TerminalVersions{}})
+	r.Get("/v1/terminals/{terminal_id}/versions", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		versions, ok := skillRegistry.Versions(terminalID)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "terminal not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, versions)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/skills/stats", openapi.Operation{Summary: "查询终端各技能的调用成功率与耗时", Tags: []string{"terminals"}, Response: domain.TerminalSkillStats{}})
+	r.Get("/v1/terminals/{terminal_id}/skills/stats", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		writeJSON(w, http.StatusOK, domain.TerminalSkillStats{TerminalID: terminalID, Items: skillRegistry.SkillStats(terminalID)})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/intent_results", openapi.Operation{Summary: "列出终端最近 50 条意图执行结果", Tags: []string{"terminals"}, Response: listResponse[domain.IntentResult]{}})
+	r.Get("/v1/terminals/{terminal_id}/intent_results", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		items, err := store.ListIntentResults(req.Context(), terminalID, 50)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, listResponse[domain.IntentResult]{Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/skill_bundles", openapi.Operation{Summary: "查询终端已启用的技能包（终端据此拉取技能定义）", Tags: []string{"terminals", "skill_bundles"}, Response: domain.TerminalSkillBundles{}})
+	r.Get("/v1/terminals/{terminal_id}/skill_bundles", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		writeJSON(w, http.StatusOK, domain.TerminalSkillBundles{TerminalID: terminalID, Bundles: marketplace.Enabled(terminalID)})
+	})
+	apiDoc.Add(http.MethodPut, "/v1/terminals/{terminal_id}/skill_bundles/{name}", openapi.Operation{Summary: "为终端启用技能包", Tags: []string{"terminals", "skill_bundles"}, Response: domain.TerminalSkillBundles{}})
+	r.Put("/v1/terminals/{terminal_id}/skill_bundles/{name}", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		bundles, err := marketplace.Enable(req.Context(), terminalID, chi.URLParam(req, "name"))
+		if err != nil {
+			if errors.Is(err, db.ErrSkillBundleNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		logger.Info("skill bundle enabled", "terminal_id", terminalID, "name", chi.URLParam(req, "name"))
+		notifyBundles(req.Context(), terminalID)
+		writeJSON(w, http.StatusOK, domain.TerminalSkillBundles{TerminalID: terminalID, Bundles: bundles})
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/terminals/{terminal_id}/skill_bundles/{name}", openapi.Operation{Summary: "为终端停用技能包", Tags: []string{"terminals", "skill_bundles"}, Response: domain.TerminalSkillBundles{}})
+	r.Delete("/v1/terminals/{terminal_id}/skill_bundles/{name}", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		bundles, err := marketplace.Disable(req.Context(), terminalID, chi.URLParam(req, "name"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		logger.Info("skill bundle disabled", "terminal_id", terminalID, "name", chi.URLParam(req, "name"))
+		notifyBundles(req.Context(), terminalID)
+		writeJSON(w, http.StatusOK, domain.TerminalSkillBundles{TerminalID: terminalID, Bundles: bundles})
+	})
+
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/dry_run", openapi.Operation{Summary: "查询终端演练模式", Tags: []string{"terminals"}, Response: domain.TerminalDryRunSetting{}})
+	r.Get("/v1/terminals/{terminal_id}/dry_run", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		writeJSON(w, http.StatusOK, domain.TerminalDryRunSetting{TerminalID: terminalID, Enabled: skillRegistry.IsDryRun(terminalID)})
+	})
+	apiDoc.Add(http.MethodPost, "/v1/terminals/{terminal_id}/dry_run", openapi.Operation{Summary: "开启或关闭终端演练模式", Tags: []string{"terminals"}, Request: domain.TerminalDryRunPayload{}, Response: domain.TerminalDryRunSetting{}})
+	r.Post("/v1/terminals/{terminal_id}/dry_run", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		var payload domain.TerminalDryRunPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		skillRegistry.SetDryRun(terminalID, payload.Enabled)
+		logger.Info("terminal dry run updated", "terminal_id", terminalID, "enabled", payload.Enabled)
+		writeJSON(w, http.StatusOK, domain.TerminalDryRunSetting{TerminalID: terminalID, Enabled: payload.Enabled})
+	})
+
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/soul_group", openapi.Operation{Summary: "查询终端上轮流对话的多个灵魂", Tags: []string{"terminals"}, QueryParams: []string{"user_id"}, Response: domain.TerminalSoulGroup{}})
+	r.Get("/v1/terminals/{terminal_id}/soul_group", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		item, err := memorySvc.GetTerminalSoulGroup(req.Context(), userID, terminalID)
+		if errors.Is(err, db.ErrSoulGroupNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodPut, "/v1/terminals/{terminal_id}/soul_group", openapi.Operation{Summary: "让 2~4 个灵魂共用终端，按点名或轮流回复同一会话，各自保留记忆", Tags: []string{"terminals"}, Request: domain.TerminalSoulGroupPayload{}, Response: domain.TerminalSoulGroup{}})
+	r.Put("/v1/terminals/{terminal_id}/soul_group", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		var payload domain.TerminalSoulGroupPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		userID := strings.TrimSpace(payload.UserID)
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		item, err := memorySvc.SetTerminalSoulGroup(req.Context(), userID, terminalID, payload.SoulIDs)
+		if err != nil {
+			switch {
+			case errors.Is(err, memory.ErrInvalidSoulGroup):
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+			case errors.Is(err, db.ErrSoulNotFound):
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": "soul not found for user"})
+			default:
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			}
+			return
+		}
+		logger.Info("terminal soul group updated", "terminal_id", terminalID, "souls", len(item.SoulIDs))
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/terminals/{terminal_id}/soul_group", openapi.Operation{Summary: "解散终端的多灵魂对话，恢复单一绑定灵魂", Tags: []string{"terminals"}, QueryParams: []string{"user_id"}, Response: okResponse{}})
+	r.Delete("/v1/terminals/{terminal_id}/soul_group", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		userID := strings.TrimSpace(req.URL.Query().Get("user_id"))
+		if userID == "" {
+			userID = cfg.UserID
+		}
+		if err := memorySvc.DeleteTerminalSoulGroup(req.Context(), userID, terminalID); err != nil {
+			if errors.Is(err, db.ErrSoulGroupNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, okResponse{OK: true})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/reply_filters", openapi.Operation{Summary: "查询终端的回复后处理过滤链（未单独设置时返回默认链）", Tags: []string{"terminals"}, Response: domain.TerminalReplyFilters{}})
+	r.Get("/v1/terminals/{terminal_id}/reply_filters", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		writeJSON(w, http.StatusOK, replyFilters.Get(terminalID))
+	})
+	apiDoc.Add(http.MethodPut, "/v1/terminals/{terminal_id}/reply_filters", openapi.Operation{Summary: "设置终端的回复后处理过滤链（按顺序执行）", Tags: []string{"terminals"}, Request: domain.TerminalReplyFiltersPayload{}, Response: domain.TerminalReplyFilters{}})
+	r.Put("/v1/terminals/{terminal_id}/reply_filters", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		var payload domain.TerminalReplyFiltersPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		item, err := replyFilters.Set(req.Context(), terminalID, payload.Filters)
+		if err != nil {
+			if errors.Is(err, replyfilter.ErrInvalidFilter) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		logger.Info("terminal reply filters updated", "terminal_id", terminalID, "filters", item.Filters)
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/terminals/{terminal_id}/reply_filters", openapi.Operation{Summary: "删除终端的回复过滤链，恢复默认链", Tags: []string{"terminals"}, Response: okResponse{}})
+	r.Delete("/v1/terminals/{terminal_id}/reply_filters", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if err := replyFilters.Delete(req.Context(), terminalID); err != nil {
+			if errors.Is(err, db.ErrReplyFiltersNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, okResponse{OK: true})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/state", openapi.Operation{Summary: "查询终端影子状态（灯光、表情、动作、情绪与待回执动作）", Tags: []string{"terminals"}, Response: domain.TerminalShadow{}})
+	r.Get("/v1/terminals/{terminal_id}/state", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		state, ok := mqttHub.TerminalState(terminalID)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": "terminal not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, state)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/desired_state", openapi.Operation{Summary: "查询终端期望状态及与影子状态不一致的技能", Tags: []string{"terminals"}, Response: domain.TerminalDesiredState{}})
+	r.Get("/v1/terminals/{terminal_id}/desired_state", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		item, ok := desiredStates.Get(terminalID)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": db.ErrDesiredStateNotFound.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodPut, "/v1/terminals/{terminal_id}/desired_state", openapi.Operation{Summary: "设置终端期望状态（重连后按此补发技能调用）", Tags: []string{"terminals"}, Request: domain.TerminalDesiredStatePayload{}, Response: domain.TerminalDesiredState{}})
+	r.Put("/v1/terminals/{terminal_id}/desired_state", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		var payload domain.TerminalDesiredStatePayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		item, err := desiredStates.Set(req.Context(), terminalID, payload.Skills)
+		if err != nil {
+			if errors.Is(err, twin.ErrInvalidDesiredState) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		logger.Info("terminal desired state updated", "terminal_id", terminalID, "skills", len(item.Skills), "divergent", item.Divergent)
+		// Bring an online terminal in line now instead of at its next
+		// reconnect; invokes may take up to the tool timeout each.
+		if len(item.Divergent) > 0 {
+			if state, ok := mqttHub.TerminalState(terminalID); ok && state.Online {
+				go desiredStates.Reconcile(context.Background(), terminalID)
+			}
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodDelete, "/v1/terminals/{terminal_id}/desired_state", openapi.Operation{Summary: "删除终端期望状态", Tags: []string{"terminals"}, Response: okResponse{}})
+	r.Delete("/v1/terminals/{terminal_id}/desired_state", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if err := desiredStates.Delete(req.Context(), terminalID); err != nil {
+			if errors.Is(err, db.ErrDesiredStateNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, okResponse{OK: true})
+	})
+	apiDoc.Add(http.MethodPost, "/v1/terminals/{terminal_id}/desired_state/reconcile", openapi.Operation{Summary: "立即按期望状态补发不一致的技能调用", Tags: []string{"terminals"}, Response: domain.ReconcileResult{}})
+	r.Post("/v1/terminals/{terminal_id}/desired_state/reconcile", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if _, ok := desiredStates.Get(terminalID); !ok {
+			writeJSON(w, http.StatusNotFound, map[string]any{"error": db.ErrDesiredStateNotFound.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, desiredStates.Reconcile(req.Context(), terminalID))
+	})
+	apiDoc.Add(http.MethodPost, "/v1/terminals/{terminal_id}/config", openapi.Operation{Summary: "向终端下发配置（心跳间隔、VAD 阈值、免打扰时段），等待终端回执", Tags: []string{"terminals"}, Request: domain.TerminalConfig{}, Response: domain.TerminalConfigPush{}})
+	r.Post("/v1/terminals/{terminal_id}/config", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		var payload domain.TerminalConfig
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		item, err := terminalConfigs.Push(req.Context(), terminalID, payload)
+		if err != nil {
+			if errors.Is(err, terminalconfig.ErrInvalidConfig) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			// A recorded push stays pending and is resent on reconnect.
+			if item.ID > 0 {
+				writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error(), "request_id": item.RequestID})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/config", openapi.Operation{Summary: "列出终端的配置下发记录及回执状态（新的在前）", Tags: []string{"terminals"}, QueryParams: []string{"status", "limit"}, Response: listResponse[domain.TerminalConfigPush]{}})
+	r.Get("/v1/terminals/{terminal_id}/config", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		status := strings.TrimSpace(req.URL.Query().Get("status"))
+		switch status {
+		case "", domain.ConfigPushPending, domain.ConfigPushApplied, domain.ConfigPushRejected:
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "status must be pending, applied or rejected"})
+			return
+		}
+		limit := 20
+		if v := req.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "limit must be between 1 and 100"})
+				return
+			}
+			limit = n
+		}
+		items, err := terminalConfigs.List(req.Context(), terminalID, status, limit)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, listResponse[domain.TerminalConfigPush]{Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/terminals/{terminal_id}/power", openapi.Operation{Summary: "查询终端休眠状态", Tags: []string{"terminals"}, Response: domain.TerminalPowerState{}})
+	r.Get("/v1/terminals/{terminal_id}/power", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		writeJSON(w, http.StatusOK, powerStates.State(terminalID))
+	})
+	apiDoc.Add(http.MethodPost, "/v1/terminals/{terminal_id}/power", openapi.Operation{Summary: "手动让终端休眠或唤醒（sleep/wake）", Tags: []string{"terminals"}, Request: domain.TerminalPowerPayload{}, Response: domain.TerminalPowerState{}})
+	r.Post("/v1/terminals/{terminal_id}/power", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		var payload domain.TerminalPowerPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		item, err := powerStates.Set(req.Context(), terminalID, payload.Command)
+		if err != nil {
+			if errors.Is(err, power.ErrInvalidCommand) {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+
+	apiDoc.Add(http.MethodGet, "/v1/replays", openapi.Operation{Summary: "列出录制的完整对话轮次（新的在前），供 soul-replay 重放", Tags: []string{"replays"}, QueryParams: []string{"session_id", "limit", "fresh"}, Response: listResponse[domain.TurnReplay]{}})
+	r.Get("/v1/replays", func(w http.ResponseWriter, req *http.Request) {
+		limit := 20
+		if v := req.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				writeJSON(w, http.StatusBadRequest, map[string]any{"error": "limit must be between 1 and 100"})
+				return
+			}
+			limit = n
+		}
+		items, err := store.ListTurnReplays(req.Context(), strings.TrimSpace(req.URL.Query().Get("session_id")), limit)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, listResponse[domain.TurnReplay]{Items: items})
+	})
+	apiDoc.Add(http.MethodGet, "/v1/replays/{id}", openapi.Operation{Summary: "查询一条录制的对话轮次（请求、门控输入、提示词、LLM 原始响应、工具调用）", Tags: []string{"replays"}, Response: domain.TurnReplay{}})
+	r.Get("/v1/replays/{id}", func(w http.ResponseWriter, req *http.Request) {
+		id, err := strconv.ParseInt(chi.URLParam(req, "id"), 10, 64)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "id must be an integer"})
+			return
+		}
+		item, err := store.GetTurnReplay(req.Context(), id)
+		if err != nil {
+			if errors.Is(err, db.ErrTurnReplayNotFound) {
+				writeJSON(w, http.StatusNotFound, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, item)
+	})
+
+	apiDoc.Add(http.MethodPost, "/v1/terminals/{terminal_id}/status", openapi.Operation{Summary: "向终端转发语音活动状态（listening/listening_stopped）", Tags: []string{"terminals"}, Request: domain.TerminalStatusPayload{}, Response: okResponse{}})
+	r.Post("/v1/terminals/{terminal_id}/status", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		var payload domain.TerminalStatusPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		// Only voice activity is relayed; processing statuses stay owned by
+		// the orchestrator.
+		switch payload.Status {
+		case domain.TerminalStatusListening, domain.TerminalStatusListeningStopped:
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "status must be listening or listening_stopped"})
+			return
+		}
+		if payload.Status == domain.TerminalStatusListening {
+			// The user started talking: wake the robot before the turn.
+			powerStates.Touch(req.Context(), terminalID)
+		}
+		if err := mqttHub.PublishStatus(req.Context(), terminalID, payload.Status, payload.Message, payload.SessionID); err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, okResponse{OK: true})
+	})
+
+	apiDoc.Add(http.MethodPost, "/v1/terminals/{terminal_id}/emotion_preview", openapi.Operation{Summary: "向终端下发语音中途识别的用户情绪（emotion_update preview）", Tags: []string{"terminals"}, Request: domain.EmotionPreviewPayload{}, Response: okResponse{}})
+	r.Post("/v1/terminals/{terminal_id}/emotion_preview", func(w http.ResponseWriter, req *http.Request) {
+		terminalID := strings.TrimSpace(chi.URLParam(req, "terminal_id"))
+		var payload domain.EmotionPreviewPayload
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+			return
+		}
+		if terminalID == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "terminal_id is required"})
+			return
+		}
+		if strings.TrimSpace(payload.UserEmotion.Emotion) == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "user_emotion.emotion is required"})
+			return
+		}
+		// A preview only moves the face; the soul's PAD state is updated by
+		// /v1/chat once the sentence is final.
+		update := domain.EmotionUpdatePayload{
+			SessionID:   strings.TrimSpace(payload.SessionID),
+			TerminalID:  terminalID,
+			UserEmotion: payload.UserEmotion,
+			Preview:     true,
+			TS:          time.Now().UTC().Format(time.RFC3339Nano),
+		}
+		if err := mqttHub.PublishEmotionUpdate(req.Context(), terminalID, update); err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, okResponse{OK: true})
+	})
+
+	httpServer := &http.Server{
+		Addr:              cfg.HTTPAddr,
+		Handler:           r,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		logger.Info("soul server started", "addr", cfg.HTTPAddr)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("http server error", "error", err)
+			cancel()
+		}
+	}()
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
@@ -310,6 +2080,63 @@ func main() {
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		logger.Error("http shutdown failed", "error", err)
 	}
+	if err := writes.Close(shutdownCtx); err != nil {
+		logger.Error("flush write-behind queue failed", "error", err)
+	}
+}
+
+type okResponse struct {
+	OK bool `json:"ok"`
+}
+
+type listResponse[T any] struct {
+	Items []T `json:"items"`
+}
+
+type userListResponse[T any] struct {
+	UserID string `json:"user_id"`
+	Items  []T    `json:"items"`
+}
+
+type sessionListResponse[T any] struct {
+	SessionID string `json:"session_id"`
+	Items     []T    `json:"items"`
+}
+
+type soulListResponse[T any] struct {
+	SoulID string `json:"soul_id"`
+	Items  []T    `json:"items"`
+}
+
+type selectSoulResponse struct {
+	OK         bool   `json:"ok"`
+	UserID     string `json:"user_id"`
+	TerminalID string `json:"terminal_id"`
+	SoulID     string `json:"soul_id"`
+}
+
+func soulPrompt(profile domain.SoulProfile) domain.SoulPrompt {
+	out := domain.SoulPrompt{SoulID: profile.SoulID, TabooTopics: []string{}, UpdatedAt: profile.UpdatedAt}
+	if card := profile.CharacterCard; card != nil {
+		out.Background, out.SpeakingStyle = card.Background, card.SpeakingStyle
+		if len(card.TabooTopics) > 0 {
+			out.TabooTopics = card.TabooTopics
+		}
+	}
+	return out
+}
+
+// gateOverrideHeader carries the admin token that makes /v1/chat run its
+// skills whatever the emotion gate says.
+const gateOverrideHeader = "X-Gate-Override-Token"
+
+// gateOverrideAllowed reports whether token matches the configured override
+// token; an empty configured token disables the override.
+func gateOverrideAllowed(configured, token string) bool {
+	if configured == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(configured), []byte(token)) == 1
 }
 
 func hasKeyboardTextInput(inputs []domain.ChatInput) bool {
@@ -322,6 +2149,17 @@ func hasKeyboardTextInput(inputs []domain.ChatInput) bool {
 	return false
 }
 
+// freshReads sends the reads of a request with ?fresh=true to the primary,
+// for clients that must see a write they just made.
+func freshReads(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if fresh, _ := strconv.ParseBool(req.URL.Query().Get("fresh")); fresh {
+			req = req.WithContext(db.WithStaleness(req.Context(), 0))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
 func writeJSON(w http.ResponseWriter, status int, body any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
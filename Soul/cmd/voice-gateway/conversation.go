@@ -0,0 +1,177 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// convState is where a hands-free dialog stands. The gateway owns it so every
+// client sees the same turn-taking: microphone audio is ignored while the
+// robot speaks, and listening re-arms by itself once the reply has played.
+type convState string
+
+const (
+	// stateIdle waits for the user to start a conversation.
+	stateIdle convState = "idle"
+	// stateListening is an open VAD segment.
+	stateListening convState = "listening"
+	// stateThinking covers ASR and reply generation up to the first output.
+	stateThinking convState = "thinking"
+	// stateSpeaking lasts until the reply has been delivered, including the
+	// estimated playback of its audio.
+	stateSpeaking convState = "speaking"
+	// stateFollowUp is listening re-armed after a reply, for a reply to the
+	// reply without starting over; it lapses to idle.
+	stateFollowUp convState = "follow_up"
+)
+
+// conversation is the per-session state machine. Transitions for an
+// utterance other than the current one are ignored, so a superseded reply
+// finishing late cannot drag the dialog back.
+type conversation struct {
+	mu          sync.Mutex
+	state       convState
+	utteranceID string
+	// engaged is set once a reply completed, so an empty transcript returns
+	// to follow_up rather than idle.
+	engaged  bool
+	followUp time.Duration
+	timer    *time.Timer
+	// onChange runs under the lock so observers see transitions in order.
+	onChange func(from, to convState, reason, utteranceID string)
+}
+
+func newConversation(followUp time.Duration, onChange func(from, to convState, reason, utteranceID string)) *conversation {
+	return &conversation{state: stateIdle, followUp: followUp, onChange: onChange}
+}
+
+func (c *conversation) State() convState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// SpeechStarted opens a turn from any state; speaking over a pending reply
+// is how the user interrupts it.
+func (c *conversation) SpeechStarted(utteranceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.moveLocked(stateListening, "speech_start", utteranceID)
+}
+
+func (c *conversation) SpeechEnded(utteranceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.is(stateListening, utteranceID) {
+		c.moveLocked(stateThinking, "speech_end", utteranceID)
+	}
+}
+
+// NothingHeard handles a segment whose transcript came back empty.
+func (c *conversation) NothingHeard(utteranceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.is(stateThinking, utteranceID) {
+		c.moveLocked(c.restLocked(), "empty_transcript", utteranceID)
+	}
+}
+
+// ReplyStarted marks the first reply output the user can perceive.
+func (c *conversation) ReplyStarted(utteranceID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.is(stateThinking, utteranceID) {
+		c.moveLocked(stateSpeaking, "reply", utteranceID)
+	}
+}
+
+// ReplyFinished re-arms listening once playback, still running on the
+// client for the given time, is over.
+func (c *conversation) ReplyFinished(utteranceID string, playback time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.is(stateThinking, utteranceID) && !c.is(stateSpeaking, utteranceID) {
+		return
+	}
+	c.engaged = true
+	if playback <= 0 {
+		c.moveLocked(stateFollowUp, "reply_done", utteranceID)
+		return
+	}
+	if c.state != stateSpeaking {
+		c.moveLocked(stateSpeaking, "reply", utteranceID)
+	}
+	c.armLocked(playback, stateFollowUp, "playback_done")
+}
+
+// ReplyFailed abandons the turn after an error or cancellation.
+func (c *conversation) ReplyFailed(utteranceID, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.is(stateThinking, utteranceID) || c.is(stateSpeaking, utteranceID) {
+		c.moveLocked(c.restLocked(), reason, utteranceID)
+	}
+}
+
+// PlaybackDone is the client reporting the reply audio has finished playing,
+// which is more precise than the server's estimate.
+func (c *conversation) PlaybackDone() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == stateSpeaking && c.engaged {
+		c.moveLocked(stateFollowUp, "playback_done", c.utteranceID)
+	}
+}
+
+func (c *conversation) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.moveLocked(stateIdle, "closed", c.utteranceID)
+}
+
+func (c *conversation) is(state convState, utteranceID string) bool {
+	return c.state == state && c.utteranceID == utteranceID
+}
+
+func (c *conversation) restLocked() convState {
+	if c.engaged {
+		return stateFollowUp
+	}
+	return stateIdle
+}
+
+func (c *conversation) moveLocked(to convState, reason, utteranceID string) {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	from := c.state
+	c.state, c.utteranceID = to, utteranceID
+	switch to {
+	case stateIdle:
+		c.engaged = false
+	case stateFollowUp:
+		if c.followUp > 0 {
+			c.armLocked(c.followUp, stateIdle, "follow_up_expired")
+		}
+	}
+	if from != to && c.onChange != nil {
+		c.onChange(from, to, reason, utteranceID)
+	}
+}
+
+// armLocked schedules a transition that any other transition cancels.
+func (c *conversation) armLocked(d time.Duration, to convState, reason string) {
+	utteranceID := c.utteranceID
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.timer != t {
+			return
+		}
+		c.timer = nil
+		c.moveLocked(to, reason, utteranceID)
+	})
+	c.timer = t
+}
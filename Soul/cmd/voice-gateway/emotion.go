@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"soul/internal/domain"
+)
+
+// emotionAnalyzer reads the emotion of a piece of text; emotion.Client
+// talks to emotion-server.
+type emotionAnalyzer interface {
+	Analyze(ctx context.Context, text, language string, history []domain.Message) (domain.EmotionSignal, error)
+}
+
+// emotionPreviewConfig bounds how hard partial transcripts may lean on
+// emotion-server and how much evidence moves the expression.
+type emotionPreviewConfig struct {
+	// Interval is the least time between two calls for a session.
+	Interval time.Duration
+	// MaxCalls is the per-utterance call budget.
+	MaxCalls int
+	// MinRunes skips partials too short to carry an emotion.
+	MinRunes int
+	// MinIntensity is the strength a new emotion needs to count at all.
+	MinIntensity float64
+	// Confirm is how many readings in a row a new emotion needs before it
+	// replaces the one on display.
+	Confirm int
+}
+
+// emotionPreview analyzes the partial transcripts of one session so the
+// robot's expression can change while the user is still talking. Partials
+// are debounced into at most one call in flight, spaced by Interval, and a
+// reading only reaches the face once it has held for Confirm calls: the
+// emotion on display is sticky, so a transcript that wobbles between two
+// readings does not make the face flicker.
+type emotionPreview struct {
+	analyzer emotionAnalyzer
+	cfg      emotionPreviewConfig
+	// onShow runs under the lock when the displayed emotion changes, so it
+	// cannot race a Final.
+	onShow func(utteranceID string, sig domain.EmotionSignal)
+	// onCall reports each call's result: ok or error.
+	onCall func(result string)
+
+	mu          sync.Mutex
+	utteranceID string
+	text        string
+	analyzed    string
+	calls       int
+	finished    bool
+	lastCall    time.Time
+	timer       *time.Timer
+	timerGen    int
+	inflight    bool
+	closed      bool
+
+	shown     string
+	candidate string
+	streak    int
+}
+
+func newEmotionPreview(analyzer emotionAnalyzer, cfg emotionPreviewConfig, onShow func(string, domain.EmotionSignal), onCall func(string)) *emotionPreview {
+	return &emotionPreview{analyzer: analyzer, cfg: cfg, onShow: onShow, onCall: onCall}
+}
+
+// Partial records the latest partial transcript of an utterance.
+func (e *emotionPreview) Partial(utteranceID, text string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if utteranceID != e.utteranceID {
+		e.resetLocked(utteranceID)
+	}
+	if e.finished {
+		return
+	}
+	e.text = text
+	e.scheduleLocked()
+}
+
+// Final stops previews for the utterance: its final transcript goes through
+// /v1/chat, whose emotion_update is authoritative. The next utterance starts
+// from a blank display.
+func (e *emotionPreview) Final(utteranceID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if utteranceID != e.utteranceID {
+		e.resetLocked(utteranceID)
+	}
+	e.finished = true
+	e.stopLocked()
+	e.shown = ""
+}
+
+func (e *emotionPreview) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	e.stopLocked()
+}
+
+func (e *emotionPreview) resetLocked(utteranceID string) {
+	e.stopLocked()
+	e.utteranceID = utteranceID
+	e.text, e.analyzed = "", ""
+	e.calls = 0
+	e.finished = false
+	e.candidate, e.streak = "", 0
+}
+
+func (e *emotionPreview) stopLocked() {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+}
+
+// scheduleLocked arms a call for the latest text unless one is pending, in
+// flight, over budget or pointless.
+func (e *emotionPreview) scheduleLocked() {
+	if e.closed || e.finished || e.timer != nil || e.inflight || e.calls >= e.cfg.MaxCalls {
+		return
+	}
+	if e.text == e.analyzed || utf8.RuneCountInString(e.text) < e.cfg.MinRunes {
+		return
+	}
+	wait := max(0, time.Until(e.lastCall.Add(e.cfg.Interval)))
+	e.timerGen++
+	gen := e.timerGen
+	e.timer = time.AfterFunc(wait, func() { e.fire(gen) })
+}
+
+func (e *emotionPreview) fire(gen int) {
+	e.mu.Lock()
+	if e.timer == nil || e.timerGen != gen {
+		e.mu.Unlock()
+		return
+	}
+	e.timer = nil
+	utteranceID, text := e.utteranceID, e.text
+	e.inflight = true
+	e.calls++
+	e.lastCall = time.Now()
+	e.analyzed = text
+	e.mu.Unlock()
+
+	sig, err := e.analyzer.Analyze(context.Background(), text, "", nil)
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	if e.onCall != nil {
+		e.onCall(result)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inflight = false
+	if err == nil && utteranceID == e.utteranceID && !e.finished && !e.closed && e.observeLocked(sig) && e.onShow != nil {
+		e.onShow(utteranceID, sig)
+	}
+	e.scheduleLocked()
+}
+
+// observeLocked applies the hysteresis and reports whether sig now takes
+// over the display.
+func (e *emotionPreview) observeLocked(sig domain.EmotionSignal) bool {
+	if sig.Emotion == "" || sig.Emotion == e.shown || sig.Intensity < e.cfg.MinIntensity {
+		e.candidate, e.streak = "", 0
+		return false
+	}
+	if sig.Emotion != e.candidate {
+		e.candidate, e.streak = sig.Emotion, 0
+	}
+	e.streak++
+	if e.streak < e.cfg.Confirm {
+		return false
+	}
+	e.shown = sig.Emotion
+	e.candidate, e.streak = "", 0
+	return true
+}
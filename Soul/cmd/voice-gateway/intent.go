@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"soul/internal/domain"
+)
+
+// intentSpeculator matches a partial transcript ahead of the final;
+// soulIntentSpeculator asks soul-server.
+type intentSpeculator interface {
+	Speculate(ctx context.Context, req domain.IntentSpeculationRequest) (domain.IntentSpeculation, error)
+}
+
+// soulIntentSpeculator posts partials to soul-server /v1/intents/speculate
+// under the same user, terminal and session as soulReplier's /v1/chat, so
+// the final of the utterance finds the match waiting.
+type soulIntentSpeculator struct {
+	client        *http.Client
+	baseURL       string
+	userID        string
+	terminalID    string
+	sessionPrefix string
+}
+
+func (p *soulIntentSpeculator) Speculate(ctx context.Context, req domain.IntentSpeculationRequest) (domain.IntentSpeculation, error) {
+	req.UserID = p.userID
+	req.SessionID = p.sessionPrefix + req.SessionID
+	req.TerminalID = cmp.Or(req.TerminalID, p.terminalID)
+	body, err := json.Marshal(req)
+	if err != nil {
+		return domain.IntentSpeculation{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/intents/speculate", bytes.NewReader(body))
+	if err != nil {
+		return domain.IntentSpeculation{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return domain.IntentSpeculation{}, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return domain.IntentSpeculation{}, err
+	}
+	if resp.StatusCode >= 300 {
+		return domain.IntentSpeculation{}, fmt.Errorf("intent speculate %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	var out domain.IntentSpeculation
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return domain.IntentSpeculation{}, err
+	}
+	return out, nil
+}
+
+// intentPrefetchConfig bounds how often partials are matched.
+type intentPrefetchConfig struct {
+	// Interval is the least time between two calls for a session.
+	Interval time.Duration
+	// MaxCalls is the per-utterance call budget.
+	MaxCalls int
+	// MinRunes skips partials too short to name an action.
+	MinRunes int
+}
+
+// intentPrefetch sends the partial transcripts of one session to be
+// matched while the user is still talking. soul-server holds a match once
+// it is stable across partials and fires it the moment a final with the
+// same words reaches /v1/chat. Like emotionPreview, partials are debounced
+// into at most one call in flight, spaced by Interval.
+type intentPrefetch struct {
+	speculator intentSpeculator
+	sessionID  string
+	terminalID string
+	cfg        intentPrefetchConfig
+	// onReady runs under the lock the first time the utterance's match
+	// turns stable.
+	onReady func(utteranceID string, spec domain.IntentSpeculation)
+	// onCall reports each call's result: stable, unstable or error.
+	onCall func(result string)
+
+	mu          sync.Mutex
+	utteranceID string
+	text        string
+	matched     string
+	calls       int
+	finished    bool
+	ready       bool
+	lastCall    time.Time
+	timer       *time.Timer
+	timerGen    int
+	inflight    bool
+	closed      bool
+}
+
+func newIntentPrefetch(speculator intentSpeculator, sessionID, terminalID string, cfg intentPrefetchConfig, onReady func(string, domain.IntentSpeculation), onCall func(string)) *intentPrefetch {
+	return &intentPrefetch{speculator: speculator, sessionID: sessionID, terminalID: terminalID, cfg: cfg, onReady: onReady, onCall: onCall}
+}
+
+// Partial records the latest partial transcript of an utterance.
+func (p *intentPrefetch) Partial(utteranceID, text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if utteranceID != p.utteranceID {
+		p.resetLocked(utteranceID)
+	}
+	if p.finished {
+		return
+	}
+	p.text = text
+	p.scheduleLocked()
+}
+
+// Final stops matching partials of the utterance; its final transcript
+// goes through /v1/chat, which decides whether the held match applies.
+func (p *intentPrefetch) Final(utteranceID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if utteranceID != p.utteranceID {
+		p.resetLocked(utteranceID)
+	}
+	p.finished = true
+	p.stopLocked()
+}
+
+func (p *intentPrefetch) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.stopLocked()
+}
+
+func (p *intentPrefetch) resetLocked(utteranceID string) {
+	p.stopLocked()
+	p.utteranceID = utteranceID
+	p.text, p.matched = "", ""
+	p.calls = 0
+	p.finished, p.ready = false, false
+}
+
+func (p *intentPrefetch) stopLocked() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
+
+// scheduleLocked arms a call for the latest text unless one is pending, in
+// flight, over budget or pointless.
+func (p *intentPrefetch) scheduleLocked() {
+	if p.closed || p.finished || p.timer != nil || p.inflight || p.calls >= p.cfg.MaxCalls {
+		return
+	}
+	if p.text == p.matched || utf8.RuneCountInString(p.text) < p.cfg.MinRunes {
+		return
+	}
+	wait := max(0, time.Until(p.lastCall.Add(p.cfg.Interval)))
+	p.timerGen++
+	gen := p.timerGen
+	p.timer = time.AfterFunc(wait, func() { p.fire(gen) })
+}
+
+func (p *intentPrefetch) fire(gen int) {
+	p.mu.Lock()
+	if p.timer == nil || p.timerGen != gen {
+		p.mu.Unlock()
+		return
+	}
+	p.timer = nil
+	utteranceID, text := p.utteranceID, p.text
+	p.inflight = true
+	p.calls++
+	p.lastCall = time.Now()
+	p.matched = text
+	p.mu.Unlock()
+
+	spec, err := p.speculator.Speculate(context.Background(), domain.IntentSpeculationRequest{
+		SessionID:   p.sessionID,
+		TerminalID:  p.terminalID,
+		UtteranceID: utteranceID,
+		Text:        text,
+	})
+	result := "unstable"
+	switch {
+	case err != nil:
+		result = "error"
+	case spec.Stable:
+		result = "stable"
+	}
+	if p.onCall != nil {
+		p.onCall(result)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inflight = false
+	if err == nil && utteranceID == p.utteranceID && !p.finished && !p.closed {
+		if spec.Stable && !p.ready && p.onReady != nil {
+			p.onReady(utteranceID, spec)
+		}
+		p.ready = spec.Stable
+	}
+	p.scheduleLocked()
+}
@@ -0,0 +1,280 @@
+// Command voice-gateway takes live microphone audio from a browser or device,
+// segments it with VAD, transcribes each utterance and streams a reply back.
+//
+// Audio arrives either as an Opus WebRTC track (POST /v1/voice/offer) or as
+// 16 kHz mono PCM16 frames on a WebSocket (GET /v1/voice/ws). Replies come
+// from soul-server /v1/chat by default, or straight from an OpenAI-compatible
+// endpoint with VOICE_REPLY_MODE=openai.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pion/webrtc/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"soul/internal/asr"
+	"soul/internal/config"
+	"soul/internal/emotion"
+	"soul/internal/tts"
+	"soul/internal/vadx"
+)
+
+// gateway holds what every session shares: the ASR engine, the replier and
+// the WebRTC stack.
+type gateway struct {
+	cfg       config.VoiceGatewayConfig
+	logger    *slog.Logger
+	engine    asr.Engine
+	replier   replier
+	notifier  notifier
+	tts       tts.Engine
+	ttsPolicy tts.Policy
+	emotion   emotionAnalyzer
+	intents   intentSpeculator
+	// voiceprinter embeds each segment for speaker identification; nil
+	// leaves speakers unidentified.
+	voiceprinter voiceprinter
+	vadConfig    vadx.Config
+	metrics      *metrics
+
+	api *webrtc.API
+	ice webrtc.Configuration
+
+	peersMu sync.Mutex
+	peers   map[string]*peer
+}
+
+func main() {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	cfg, err := config.LoadVoiceGatewayConfig()
+	if err != nil {
+		logger.Error("load config failed", "error", err)
+		os.Exit(1)
+	}
+
+	ice, err := iceConfiguration(cfg)
+	if err != nil {
+		logger.Error("invalid ice config", "error", err)
+		os.Exit(1)
+	}
+	api, udpConn, err := newWebRTCAPI(cfg.ICEUDPPort, cfg.ICEPublicIP)
+	if err != nil {
+		logger.Error("init webrtc failed", "error", err)
+		os.Exit(1)
+	}
+	if udpConn != nil {
+		defer udpConn.Close()
+	}
+	ttsPolicy, err := tts.LoadPolicy(cfg.TTSPolicyFile)
+	if err != nil {
+		logger.Error("load tts policy failed", "error", err)
+		os.Exit(1)
+	}
+
+	gw := &gateway{
+		cfg:          cfg,
+		logger:       logger,
+		engine:       newASREngine(cfg),
+		replier:      newReplier(cfg),
+		notifier:     newNotifier(cfg),
+		tts:          newTTSEngine(cfg),
+		ttsPolicy:    ttsPolicy,
+		emotion:      newEmotionAnalyzer(cfg),
+		intents:      newIntentSpeculator(cfg),
+		voiceprinter: newVoiceprinter(cfg),
+		vadConfig:    vadConfig(cfg),
+		metrics:      newMetrics(prometheus.DefaultRegisterer),
+		api:          api,
+		ice:          ice,
+		peers:        map[string]*peer{},
+	}
+
+	r := chi.NewRouter()
+	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true})
+	})
+	r.Handle("/metrics", promhttp.Handler())
+	r.Get("/v1/voice/ws", gw.handleWS)
+	r.Post("/v1/voice/offer", gw.handleOffer)
+	r.Get("/v1/voice/ice-servers", gw.handleICEServers)
+
+	httpServer := &http.Server{
+		Addr:    cfg.HTTPAddr,
+		Handler: r,
+	}
+
+	go func() {
+		logger.Info("voice-gateway started",
+			"addr", cfg.HTTPAddr,
+			"asr_mode", cfg.ASRMode,
+			"reply_mode", cfg.ReplyMode,
+			"tts_mode", cfg.TTSMode,
+			"ice_udp_port", cfg.ICEUDPPort,
+			"ice_servers", len(ice.ICEServers),
+		)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("http server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+	logger.Info("received shutdown signal")
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Error("http shutdown failed", "error", err)
+	}
+	gw.closePeers()
+}
+
+func newASREngine(cfg config.VoiceGatewayConfig) asr.Engine {
+	bridge := &asr.WSBridgeEngine{BaseURL: cfg.ASRBridgeURL, DialAttempts: cfg.ASRBridgeDialAttempts}
+	whisper := &asr.WhisperHTTPEngine{
+		URL:      cfg.WhisperURL,
+		Model:    cfg.WhisperModel,
+		APIKey:   cfg.WhisperAPIKey,
+		Language: cfg.WhisperLanguage,
+	}
+	switch cfg.ASRMode {
+	case "whisper":
+		return whisper
+	case "fusion":
+		return &asr.FusionEngine{
+			Engines:           []asr.Engine{bridge, whisper},
+			Timeout:           cfg.FusionTimeout,
+			DefaultConfidence: cfg.FusionConfidence,
+		}
+	case "mock":
+		return &asr.MockEngine{}
+	default:
+		return bridge
+	}
+}
+
+func newReplier(cfg config.VoiceGatewayConfig) replier {
+	client := &http.Client{Timeout: cfg.ReplyTimeout}
+	if cfg.ReplyMode == "openai" {
+		return &openAIReplier{
+			client:       client,
+			baseURL:      cfg.LLMBaseURL,
+			apiKey:       cfg.LLMAPIKey,
+			model:        cfg.LLMModel,
+			systemPrompt: cfg.LLMSystemPrompt,
+			historyTurns: cfg.LLMHistoryTurns,
+			history:      map[string][]openAIMessage{},
+		}
+	}
+	return &soulReplier{
+		client:        client,
+		baseURL:       cfg.SoulAPIBaseURL,
+		userID:        cfg.UserID,
+		terminalID:    cfg.TerminalID,
+		soulID:        cfg.SoulID,
+		sessionPrefix: cfg.SessionPrefix,
+	}
+}
+
+// newTTSEngine returns nil when VOICE_TTS_MODE=off; replies are then text only.
+func newTTSEngine(cfg config.VoiceGatewayConfig) tts.Engine {
+	client := &http.Client{Timeout: cfg.ReplyTimeout}
+	switch cfg.TTSMode {
+	case "cosyvoice":
+		return &tts.CosyVoiceEngine{BaseURL: cfg.TTSURL, Speaker: cfg.TTSVoice, CloneID: cfg.TTSCloneID, Client: client}
+	case "openai":
+		return &tts.OpenAIEngine{BaseURL: cfg.TTSURL, APIKey: cfg.TTSAPIKey, Model: cfg.TTSModel, Voice: cfg.TTSVoice, Client: client}
+	case "mock":
+		return &tts.MockEngine{}
+	default:
+		return nil
+	}
+}
+
+// newNotifier returns nil when listening statuses are off; they need
+// soul-server's MQTT relay.
+func newNotifier(cfg config.VoiceGatewayConfig) notifier {
+	if !cfg.TerminalStatus || cfg.SoulAPIBaseURL == "" {
+		return nil
+	}
+	return &soulStatusNotifier{client: &http.Client{Timeout: 5 * time.Second}, baseURL: cfg.SoulAPIBaseURL}
+}
+
+// newEmotionAnalyzer returns nil unless VOICE_EMOTION_URL points at
+// emotion-server; partial transcripts are then not analyzed.
+func newEmotionAnalyzer(cfg config.VoiceGatewayConfig) emotionAnalyzer {
+	if cfg.EmotionURL == "" {
+		return nil
+	}
+	return emotion.NewClient(cfg.EmotionURL, cfg.EmotionTimeout)
+}
+
+// newIntentSpeculator returns nil unless replies come from soul-server,
+// which holds the speculated match for the final transcript.
+func newIntentSpeculator(cfg config.VoiceGatewayConfig) intentSpeculator {
+	if !cfg.IntentPrefetch || cfg.ReplyMode == "openai" || cfg.SoulAPIBaseURL == "" {
+		return nil
+	}
+	return &soulIntentSpeculator{
+		client:        &http.Client{Timeout: cfg.ReplyTimeout},
+		baseURL:       cfg.SoulAPIBaseURL,
+		userID:        cfg.UserID,
+		terminalID:    cfg.TerminalID,
+		sessionPrefix: cfg.SessionPrefix,
+	}
+}
+
+// newVoiceprinter returns nil unless VOICE_SPEAKER_EMBED_URL is set and
+// replies come from soul-server, which matches the embeddings.
+func newVoiceprinter(cfg config.VoiceGatewayConfig) voiceprinter {
+	if cfg.SpeakerEmbedURL == "" || cfg.ReplyMode == "openai" {
+		return nil
+	}
+	return &httpVoiceprinter{client: &http.Client{Timeout: cfg.SpeakerTimeout}, url: cfg.SpeakerEmbedURL}
+}
+
+func vadConfig(cfg config.VoiceGatewayConfig) vadx.Config {
+	vc := vadx.DefaultConfig()
+	vc.ThresholdDB = cfg.VADThresholdDB
+	vc.SilenceMS = int(cfg.VADSilence / time.Millisecond)
+	vc.MinSpeechMS = int(cfg.VADMinSpeech / time.Millisecond)
+	vc.PreRollMS = int(cfg.VADPreRoll / time.Millisecond)
+	vc.MaxSegmentMS = int(cfg.VADMaxSegment / time.Millisecond)
+	return vc
+}
+
+func (gw *gateway) closePeers() {
+	gw.peersMu.Lock()
+	peers := make([]*peer, 0, len(gw.peers))
+	for _, p := range gw.peers {
+		peers = append(peers, p)
+	}
+	gw.peersMu.Unlock()
+	for _, p := range peers {
+		p.cleanup()
+	}
+	if len(peers) > 0 {
+		gw.logger.Info("closed webrtc peers", "count", len(peers))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
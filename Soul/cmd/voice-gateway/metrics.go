@@ -0,0 +1,212 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metrics are the gateway's Prometheus series. Latency buckets span the
+// 100ms-10s range that matters for turn-taking.
+type metrics struct {
+	segments        *prometheus.CounterVec
+	segmentDuration prometheus.Histogram
+	trailingSilence prometheus.Histogram
+	asrLatency      *prometheus.HistogramVec
+	asrConfidence   prometheus.Histogram
+	asrFinals       *prometheus.CounterVec
+	replyTTFT       *prometheus.HistogramVec
+	replyDuration   *prometheus.HistogramVec
+	replies         *prometheus.CounterVec
+	endToEnd        prometheus.Histogram
+	ttsFirstAudio   *prometheus.HistogramVec
+	endToEndAudio   prometheus.Histogram
+	emotionCalls    *prometheus.CounterVec
+	emotionPreviews *prometheus.CounterVec
+	// intentSpeculations counts partial transcripts sent for intent
+	// matching, by result: stable, unstable or error.
+	intentSpeculations *prometheus.CounterVec
+	// voiceprints counts segments sent for a speaker embedding, by
+	// result: ok, error or short.
+	voiceprints *prometheus.CounterVec
+}
+
+var latencyBuckets = []float64{0.1, 0.2, 0.3, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		segments: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "voice_vad_segments_total",
+			Help: "Speech segments closed by the VAD, by reason (silence, max_length, flush).",
+		}, []string{"reason"}),
+		segmentDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "voice_vad_segment_duration_seconds",
+			Help:    "Length of closed speech segments, including pre-roll and trailing silence.",
+			Buckets: []float64{0.25, 0.5, 1, 2, 3, 5, 8, 13, 20, 30},
+		}),
+		trailingSilence: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "voice_vad_trailing_silence_seconds",
+			Help:    "Silence the speaker waited through before their segment closed.",
+			Buckets: latencyBuckets,
+		}),
+		asrLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "voice_asr_latency_seconds",
+			Help:    "Time from segment close to the ASR final, by the source that produced it.",
+			Buckets: latencyBuckets,
+		}, []string{"source"}),
+		asrConfidence: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "voice_asr_confidence",
+			Help:    "Confidence of ASR finals that reported one.",
+			Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+		}),
+		asrFinals: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "voice_asr_finals_total",
+			Help: "ASR finals by result: text, empty (segment held no words) or error.",
+		}, []string{"result"}),
+		replyTTFT: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "voice_reply_ttft_seconds",
+			Help:    "Time from the ASR final to the first reply text, by reply mode.",
+			Buckets: latencyBuckets,
+		}, []string{"mode"}),
+		replyDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "voice_reply_duration_seconds",
+			Help:    "Time from the ASR final to the complete reply, by reply mode.",
+			Buckets: latencyBuckets,
+		}, []string{"mode"}),
+		replies: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "voice_replies_total",
+			Help: "Replies by outcome: ok, error or cancelled.",
+		}, []string{"outcome"}),
+		endToEnd: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "voice_end_to_end_seconds",
+			Help:    "Time from the speaker falling silent to the first reply text.",
+			Buckets: latencyBuckets,
+		}),
+		ttsFirstAudio: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "voice_tts_first_audio_seconds",
+			Help:    "Time from the ASR final to the first reply audio sent, by TTS engine.",
+			Buckets: latencyBuckets,
+		}, []string{"engine"}),
+		endToEndAudio: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "voice_end_to_end_audio_seconds",
+			Help:    "Time from the speaker falling silent to the first reply audio: the perceived response time.",
+			Buckets: latencyBuckets,
+		}),
+		emotionCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "voice_emotion_calls_total",
+			Help: "emotion-server calls made for partial transcripts, by result: ok or error.",
+		}, []string{"result"}),
+		emotionPreviews: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "voice_emotion_previews_total",
+			Help: "Expression changes sent ahead of the final transcript, by emotion.",
+		}, []string{"emotion"}),
+		intentSpeculations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "voice_intent_speculations_total",
+			Help: "Partial transcripts matched for intents ahead of the final, by result: stable, unstable or error.",
+		}, []string{"result"}),
+		voiceprints: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "voice_speaker_embeddings_total",
+			Help: "Speech segments embedded for speaker identification, by result: ok, error or short (too brief, skipped).",
+		}, []string{"result"}),
+	}
+	reg.MustRegister(m.segments, m.segmentDuration, m.trailingSilence, m.asrLatency, m.asrConfidence,
+		m.asrFinals, m.replyTTFT, m.replyDuration, m.replies, m.endToEnd, m.ttsFirstAudio, m.endToEndAudio,
+		m.emotionCalls, m.emotionPreviews, m.intentSpeculations, m.voiceprints)
+	return m
+}
+
+// utteranceTiming follows one utterance from VAD onset to the end of its
+// reply. Times are wall-clock; zero means the stage did not happen.
+type utteranceTiming struct {
+	speechStart time.Time
+	segmentEnd  time.Time
+	speech      time.Duration
+	trailing    time.Duration
+	endReason   string
+
+	asrFinal   time.Time
+	asrSource  string
+	confidence float64
+
+	firstToken time.Time
+	firstAudio time.Time
+	replyEnd   time.Time
+	outcome    string
+}
+
+// event reports the measured stages in milliseconds, both to the client and,
+// via observe, to Prometheus.
+func (t *utteranceTiming) event(utteranceID string) map[string]any {
+	ev := map[string]any{"event": "metrics", "utterance_id": utteranceID}
+	if !t.segmentEnd.IsZero() {
+		ev["speech_ms"] = t.speech.Milliseconds()
+		ev["trailing_silence_ms"] = t.trailing.Milliseconds()
+		ev["end_reason"] = t.endReason
+	}
+	if !t.segmentEnd.IsZero() && !t.asrFinal.IsZero() {
+		ev["asr_latency_ms"] = t.asrFinal.Sub(t.segmentEnd).Milliseconds()
+	}
+	if t.asrSource != "" {
+		ev["asr_source"] = t.asrSource
+	}
+	if t.confidence > 0 {
+		ev["confidence"] = t.confidence
+	}
+	if !t.firstToken.IsZero() {
+		ev["reply_ttft_ms"] = t.firstToken.Sub(t.asrFinal).Milliseconds()
+		if !t.segmentEnd.IsZero() {
+			ev["end_to_end_ms"] = t.firstToken.Sub(t.segmentEnd.Add(-t.trailing)).Milliseconds()
+		}
+	}
+	if !t.firstAudio.IsZero() {
+		ev["tts_first_audio_ms"] = t.firstAudio.Sub(t.asrFinal).Milliseconds()
+		if !t.segmentEnd.IsZero() {
+			ev["end_to_end_audio_ms"] = t.firstAudio.Sub(t.segmentEnd.Add(-t.trailing)).Milliseconds()
+		}
+	}
+	if !t.replyEnd.IsZero() {
+		ev["reply_ms"] = t.replyEnd.Sub(t.asrFinal).Milliseconds()
+	}
+	if t.outcome != "" {
+		ev["outcome"] = t.outcome
+	}
+	return ev
+}
+
+func (m *metrics) observeSegment(t *utteranceTiming) {
+	m.segments.WithLabelValues(t.endReason).Inc()
+	m.segmentDuration.Observe(t.speech.Seconds())
+	if t.endReason == "silence" {
+		m.trailingSilence.Observe(t.trailing.Seconds())
+	}
+}
+
+func (m *metrics) observeFinal(t *utteranceTiming, result string) {
+	m.asrFinals.WithLabelValues(result).Inc()
+	if !t.segmentEnd.IsZero() {
+		m.asrLatency.WithLabelValues(t.asrSource).Observe(t.asrFinal.Sub(t.segmentEnd).Seconds())
+	}
+	if t.confidence > 0 {
+		m.asrConfidence.Observe(t.confidence)
+	}
+}
+
+func (m *metrics) observeReply(t *utteranceTiming, mode, ttsEngine string) {
+	m.replies.WithLabelValues(t.outcome).Inc()
+	if t.outcome != "ok" {
+		return
+	}
+	if !t.firstAudio.IsZero() {
+		m.ttsFirstAudio.WithLabelValues(ttsEngine).Observe(t.firstAudio.Sub(t.asrFinal).Seconds())
+		if !t.segmentEnd.IsZero() {
+			m.endToEndAudio.Observe(t.firstAudio.Sub(t.segmentEnd.Add(-t.trailing)).Seconds())
+		}
+	}
+	m.replyDuration.WithLabelValues(mode).Observe(t.replyEnd.Sub(t.asrFinal).Seconds())
+	if !t.firstToken.IsZero() {
+		m.replyTTFT.WithLabelValues(mode).Observe(t.firstToken.Sub(t.asrFinal).Seconds())
+		if !t.segmentEnd.IsZero() {
+			m.endToEnd.Observe(t.firstToken.Sub(t.segmentEnd.Add(-t.trailing)).Seconds())
+		}
+	}
+}
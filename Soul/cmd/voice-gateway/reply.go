@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+)
+
+type replyRequest struct {
+	SessionID  string
+	TerminalID string
+	Text       string
+	// OnMood, when set, receives the soul's state as soon as the backend
+	// reports it, ahead of the reply text, so speech can be shaped by it.
+	OnMood func(domain.SoulEmotionState, domain.PersonalityVector)
+	// OnQuiet, when set, is called ahead of the reply text if the backend
+	// says the user is in quiet hours: the reply is shown but not spoken.
+	OnQuiet func()
+	// SpeakerEmbedding is the utterance's voiceprint, when one was taken.
+	SpeakerEmbedding []float64
+}
+
+type replyResult struct {
+	Text           string
+	ExecutedSkills []string
+}
+
+// replier answers one final transcript. onDelta receives reply text as it
+// becomes available; backends without streaming call it once.
+type replier interface {
+	Reply(ctx context.Context, req replyRequest, onDelta func(string)) (replyResult, error)
+}
+
+// soulReplier forwards transcripts to soul-server /v1/chat as speech_text,
+// so memory, persona and skills apply to voice like any other terminal.
+type soulReplier struct {
+	client        *http.Client
+	baseURL       string
+	userID        string
+	terminalID    string
+	soulID        string
+	sessionPrefix string
+}
+
+func (r *soulReplier) Reply(ctx context.Context, req replyRequest, onDelta func(string)) (replyResult, error) {
+	body, err := json.Marshal(domain.ChatRequest{
+		UserID:     r.userID,
+		SessionID:  r.sessionPrefix + req.SessionID,
+		TerminalID: cmp.Or(req.TerminalID, r.terminalID),
+		SoulID:     r.soulID,
+		Inputs: []domain.ChatInput{{
+			Type:             "speech_text",
+			Source:           "voice-gateway",
+			TS:               time.Now().UTC().Format(time.RFC3339Nano),
+			Text:             req.Text,
+			SpeakerEmbedding: req.SpeakerEmbedding,
+		}},
+	})
+	if err != nil {
+		return replyResult{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+"/v1/chat", bytes.NewReader(body))
+	if err != nil {
+		return replyResult{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		return replyResult{}, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 2<<20))
+	if err != nil {
+		return replyResult{}, err
+	}
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(raw, &failure) != nil || failure.Error == "" {
+			failure.Error = strings.TrimSpace(string(raw))
+		}
+		return replyResult{}, fmt.Errorf("soul chat status %d: %s", resp.StatusCode, failure.Error)
+	}
+	var chat domain.ChatResponse
+	if err := json.Unmarshal(raw, &chat); err != nil {
+		return replyResult{}, fmt.Errorf("decode soul chat response: %w", err)
+	}
+	if req.OnMood != nil && chat.SoulEmotion != nil && chat.Personality != nil {
+		req.OnMood(*chat.SoulEmotion, *chat.Personality)
+	}
+	if req.OnQuiet != nil && chat.QuietHours {
+		req.OnQuiet()
+	}
+	if chat.Reply != "" {
+		onDelta(chat.Reply)
+	}
+	return replyResult{Text: chat.Reply, ExecutedSkills: chat.ExecutedSkills}, nil
+}
+
+// openAIReplier streams replies from an OpenAI-compatible chat completions
+// endpoint and keeps a short per-session history in memory. It bypasses Soul
+// and is meant for latency experiments.
+type openAIReplier struct {
+	client       *http.Client
+	baseURL      string
+	apiKey       string
+	model        string
+	systemPrompt string
+	historyTurns int
+
+	mu      sync.Mutex
+	history map[string][]openAIMessage
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func (r *openAIReplier) Reply(ctx context.Context, req replyRequest, onDelta func(string)) (replyResult, error) {
+	r.mu.Lock()
+	messages := append([]openAIMessage{{Role: "system", Content: r.systemPrompt}}, r.history[req.SessionID]...)
+	r.mu.Unlock()
+	messages = append(messages, openAIMessage{Role: "user", Content: req.Text})
+
+	body, err := json.Marshal(map[string]any{"model": r.model, "messages": messages, "stream": true})
+	if err != nil {
+		return replyResult{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return replyResult{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+r.apiKey)
+	resp, err := r.client.Do(httpReq)
+	if err != nil {
+		return replyResult{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return replyResult{}, fmt.Errorf("llm status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+
+	var reply strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil || len(chunk.Choices) == 0 {
+			continue
+		}
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			reply.WriteString(delta)
+			onDelta(delta)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return replyResult{}, err
+	}
+
+	text := strings.TrimSpace(reply.String())
+	r.mu.Lock()
+	h := append(r.history[req.SessionID], openAIMessage{Role: "user", Content: req.Text}, openAIMessage{Role: "assistant", Content: text})
+	if limit := r.historyTurns * 2; limit > 0 && len(h) > limit {
+		h = h[len(h)-limit:]
+	}
+	r.history[req.SessionID] = h
+	r.mu.Unlock()
+	return replyResult{Text: text}, nil
+}
+
+// forget drops a closed session's history.
+func (r *openAIReplier) forget(sessionID string) {
+	r.mu.Lock()
+	delete(r.history, sessionID)
+	r.mu.Unlock()
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"soul/internal/asr"
+	"soul/internal/audio/resample"
+	"soul/internal/vadx"
+)
+
+// voiceSession runs one client's audio through VAD and ASR and answers each
+// final transcript. Transports feed it PCM and deliver the events it sends.
+type voiceSession struct {
+	id         string
+	terminalID string
+	gw         *gateway
+	send       func(map[string]any)
+	sendAudio  func([]byte)
+	logger     *slog.Logger
+	format     resample.Format
+	resamp     *resample.Resampler
+	vad        *vadx.Detector
+	stream     asr.Stream
+	replier    replier
+
+	conv    *conversation
+	emotion *emotionPreview
+	intents *intentPrefetch
+
+	// mu serializes audio through VAD and into the ASR stream.
+	mu sync.Mutex
+	// segment collects the open segment's audio for its voiceprint.
+	segment     []byte
+	statusQueue chan terminalUpdate
+	statusDone  chan struct{}
+
+	uttMu     sync.Mutex
+	utterance int
+	// flushed holds utterances waiting for their ASR final, oldest first.
+	flushed []string
+	// timings are in-flight utterances, reported once their reply settles.
+	timings map[string]*utteranceTiming
+	// voiceprints are the embeddings of utterances awaiting their reply.
+	voiceprints map[string]*voiceprintJob
+
+	replyMu     sync.Mutex
+	replyCancel context.CancelFunc
+	replyDone   chan struct{}
+	closed      bool
+}
+
+// sessionOptions describe one client connection.
+type sessionOptions struct {
+	ID string
+	// TerminalID is the terminal whose microphone the client streams; empty
+	// means the gateway's own.
+	TerminalID string
+	// Format is the client's audio, already normalized.
+	Format resample.Format
+	// Send delivers a JSON event to the client.
+	Send func(map[string]any)
+	// SendAudio delivers reply speech; nil when the transport cannot.
+	SendAudio func([]byte)
+}
+
+func (gw *gateway) newSession(opts sessionOptions) (*voiceSession, error) {
+	id := opts.ID
+	terminalID := opts.TerminalID
+	if terminalID == "" {
+		terminalID = gw.cfg.TerminalID
+	}
+	s := &voiceSession{
+		id:          id,
+		terminalID:  terminalID,
+		gw:          gw,
+		send:        opts.Send,
+		sendAudio:   opts.SendAudio,
+		logger:      gw.logger.With("session_id", id),
+		format:      opts.Format,
+		resamp:      resample.New(opts.Format),
+		vad:         vadx.NewDetector(gw.vadConfig),
+		replier:     gw.replier,
+		timings:     map[string]*utteranceTiming{},
+		voiceprints: map[string]*voiceprintJob{},
+	}
+	s.conv = newConversation(gw.cfg.FollowUpWindow, s.onStateChange)
+	if gw.emotion != nil {
+		s.emotion = newEmotionPreview(gw.emotion, emotionPreviewConfig{
+			Interval:     gw.cfg.EmotionInterval,
+			MaxCalls:     gw.cfg.EmotionMaxCalls,
+			MinRunes:     gw.cfg.EmotionMinRunes,
+			MinIntensity: gw.cfg.EmotionMinIntensity,
+			Confirm:      gw.cfg.EmotionConfirm,
+		}, s.onEmotionPreview, func(result string) {
+			gw.metrics.emotionCalls.WithLabelValues(result).Inc()
+		})
+	}
+	if gw.intents != nil {
+		s.intents = newIntentPrefetch(gw.intents, id, terminalID, intentPrefetchConfig{
+			Interval: gw.cfg.IntentInterval,
+			MaxCalls: gw.cfg.IntentMaxCalls,
+			MinRunes: gw.cfg.IntentMinRunes,
+		}, s.onIntentReady, func(result string) {
+			gw.metrics.intentSpeculations.WithLabelValues(result).Inc()
+		})
+	}
+	stream, err := gw.engine.NewStream(id, s.onASR)
+	if err != nil {
+		return nil, fmt.Errorf("init asr stream failed: %w", err)
+	}
+	s.stream = stream
+	if gw.notifier != nil {
+		s.statusQueue = make(chan terminalUpdate, statusQueueSize)
+		s.statusDone = make(chan struct{})
+		go s.relayStatuses(s.statusQueue, s.statusDone)
+	}
+	return s, nil
+}
+
+// PushAudio feeds PCM16LE in the session's format, resampled to mono
+// audio.SampleRate. Only audio inside a VAD segment reaches the ASR engine.
+// While the reply is speaking the microphone mostly hears the robot itself,
+// so audio is dropped unless barge-in is enabled.
+func (s *voiceSession) PushAudio(pcm16le []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pcm := s.resamp.Push(pcm16le)
+	if !s.gw.cfg.BargeIn && s.conv.State() == stateSpeaking {
+		return nil
+	}
+	return s.handleVAD(s.vad.Push(pcm))
+}
+
+// Flush closes the open segment, if any, so its transcript is produced now.
+func (s *voiceSession) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.handleVAD(s.vad.Push(s.resamp.Flush())); err != nil {
+		return err
+	}
+	return s.handleVAD(s.vad.Flush())
+}
+
+// Close drops the ASR stream and cancels a running reply.
+func (s *voiceSession) Close() {
+	s.replyMu.Lock()
+	if s.closed {
+		s.replyMu.Unlock()
+		return
+	}
+	s.closed = true
+	if s.replyCancel != nil {
+		s.replyCancel()
+	}
+	s.replyMu.Unlock()
+
+	if err := s.stream.Close(); err != nil {
+		s.logger.Warn("close asr stream failed", "error", err)
+	}
+	s.conv.Close()
+	if s.emotion != nil {
+		s.emotion.Close()
+	}
+	if s.intents != nil {
+		s.intents.Close()
+	}
+	if s.statusDone != nil {
+		close(s.statusDone)
+	}
+	if f, ok := s.replier.(interface{ forget(string) }); ok {
+		f.forget(s.id)
+	}
+}
+
+// handleVAD forwards segment audio to ASR. Callers hold s.mu.
+func (s *voiceSession) handleVAD(events []vadx.Event) error {
+	for _, ev := range events {
+		switch ev.Type {
+		case vadx.SpeechStart:
+			id := s.nextUtterance()
+			s.timing(id, func(t *utteranceTiming) { t.speechStart = time.Now() })
+			s.conv.SpeechStarted(id)
+			s.send(map[string]any{
+				"event":        "vad",
+				"state":        string(ev.Type),
+				"utterance_id": id,
+				"offset_ms":    ev.Offset.Milliseconds(),
+			})
+			if s.gw.voiceprinter != nil {
+				s.segment = append(s.segment[:0], ev.PCM...)
+			}
+			if err := s.stream.PushAudio(ev.PCM); err != nil {
+				return fmt.Errorf("push audio to asr: %w", err)
+			}
+		case vadx.SpeechAudio:
+			if s.gw.voiceprinter != nil {
+				s.segment = append(s.segment, ev.PCM...)
+			}
+			if err := s.stream.PushAudio(ev.PCM); err != nil {
+				return fmt.Errorf("push audio to asr: %w", err)
+			}
+		case vadx.SpeechEnd:
+			id := s.markFlushed()
+			s.conv.SpeechEnded(id)
+			if s.gw.voiceprinter != nil {
+				s.startVoiceprint(id, s.segment, ev.Duration-ev.Trailing)
+				s.segment = s.segment[:0]
+			}
+			s.timing(id, func(t *utteranceTiming) {
+				t.segmentEnd, t.speech, t.trailing, t.endReason = time.Now(), ev.Duration, ev.Trailing, ev.Reason
+				s.gw.metrics.observeSegment(t)
+			})
+			s.send(map[string]any{
+				"event":               "vad",
+				"state":               string(ev.Type),
+				"utterance_id":        id,
+				"reason":              ev.Reason,
+				"duration_ms":         ev.Duration.Milliseconds(),
+				"trailing_silence_ms": ev.Trailing.Milliseconds(),
+			})
+			if err := s.stream.Flush(); err != nil {
+				return fmt.Errorf("flush asr: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *voiceSession) nextUtterance() string {
+	s.uttMu.Lock()
+	defer s.uttMu.Unlock()
+	s.utterance++
+	return fmt.Sprintf("u-%d", s.utterance)
+}
+
+// markFlushed queues the current utterance for its final and returns its id.
+func (s *voiceSession) markFlushed() string {
+	s.uttMu.Lock()
+	defer s.uttMu.Unlock()
+	id := fmt.Sprintf("u-%d", s.utterance)
+	s.flushed = append(s.flushed, id)
+	return id
+}
+
+// onASR relays a transcript and starts a reply for non-empty finals. Engines
+// may call it while s.mu is held, from inside PushAudio or Flush.
+func (s *voiceSession) onASR(res asr.Result) {
+	utteranceID := s.takeUtterance(res.IsFinal)
+	s.send(map[string]any{
+		"event":        "asr",
+		"utterance_id": utteranceID,
+		"text":         res.Text,
+		"is_final":     res.IsFinal,
+		"source":       res.Source,
+		"error":        res.Error,
+		"confidence":   res.Confidence,
+		"latency_ms":   res.LatencyMS,
+		"candidates":   res.Candidates,
+	})
+	if s.emotion != nil {
+		if res.IsFinal {
+			s.emotion.Final(utteranceID)
+		} else if res.Error == "" {
+			s.emotion.Partial(utteranceID, res.Text)
+		}
+	}
+	if s.intents != nil {
+		if res.IsFinal {
+			s.intents.Final(utteranceID)
+		} else if res.Error == "" {
+			s.intents.Partial(utteranceID, res.Text)
+		}
+	}
+	if !res.IsFinal {
+		return
+	}
+	result := "text"
+	switch {
+	case res.Error != "":
+		result = "error"
+	case res.Text == "":
+		result = "empty"
+	}
+	s.timing(utteranceID, func(t *utteranceTiming) {
+		t.asrFinal, t.asrSource, t.confidence = time.Now(), res.Source, res.Confidence
+		s.gw.metrics.observeFinal(t, result)
+	})
+	if res.Text == "" {
+		s.takeVoiceprint(utteranceID)
+		s.conv.NothingHeard(utteranceID)
+		s.finishTiming(utteranceID)
+		return
+	}
+	s.startReply(utteranceID, res.Text)
+}
+
+// timing applies update to the utterance's record, creating it if an engine
+// produced a final the VAD never opened.
+func (s *voiceSession) timing(utteranceID string, update func(*utteranceTiming)) {
+	s.uttMu.Lock()
+	defer s.uttMu.Unlock()
+	t := s.timings[utteranceID]
+	if t == nil {
+		t = &utteranceTiming{}
+		s.timings[utteranceID] = t
+	}
+	update(t)
+}
+
+// finishTiming sends the utterance's metrics event and forgets it.
+func (s *voiceSession) finishTiming(utteranceID string) {
+	s.uttMu.Lock()
+	t := s.timings[utteranceID]
+	delete(s.timings, utteranceID)
+	s.uttMu.Unlock()
+	if t != nil {
+		s.send(t.event(utteranceID))
+	}
+}
+
+// takeUtterance maps a result onto the utterance it transcribes. Finals pop
+// the oldest flushed utterance; engines with their own segmentation may
+// produce finals nobody flushed, which belong to the latest one.
+func (s *voiceSession) takeUtterance(final bool) string {
+	s.uttMu.Lock()
+	defer s.uttMu.Unlock()
+	if final && len(s.flushed) > 0 {
+		id := s.flushed[0]
+		s.flushed = s.flushed[1:]
+		return id
+	}
+	if len(s.flushed) > 0 {
+		return s.flushed[0]
+	}
+	return fmt.Sprintf("u-%d", s.utterance)
+}
+
+// startReply answers text, superseding a reply still running for an earlier
+// utterance: the user has moved on, so the stale answer is cancelled.
+func (s *voiceSession) startReply(utteranceID, text string) {
+	s.replyMu.Lock()
+	if s.closed {
+		s.replyMu.Unlock()
+		return
+	}
+	if s.replyCancel != nil {
+		s.replyCancel()
+	}
+	prevDone := s.replyDone
+	// Speech may outlast the reply timeout, so only generation is bounded;
+	// superseding cancels both.
+	speechCtx, cancel := context.WithCancel(context.Background())
+	ctx, cancelReply := context.WithTimeout(speechCtx, s.gw.cfg.ReplyTimeout)
+	done := make(chan struct{})
+	s.replyCancel, s.replyDone = cancel, done
+	s.replyMu.Unlock()
+	voiceprint := s.takeVoiceprint(utteranceID)
+
+	go func() {
+		outcome := "cancelled"
+		var sp *speaker
+		defer close(done)
+		defer cancel()
+		defer cancelReply()
+		defer func() {
+			switch outcome {
+			case "ok":
+				s.conv.ReplyFinished(utteranceID, sp.remainingPlayback())
+			case "error":
+				s.conv.ReplyFailed(utteranceID, "reply_failed")
+			default:
+				s.conv.ReplyFailed(utteranceID, "reply_cancelled")
+			}
+			s.timing(utteranceID, func(t *utteranceTiming) {
+				t.replyEnd, t.outcome = time.Now(), outcome
+				// Non-streaming replies deliver their first text all at once.
+				if outcome == "ok" && t.firstToken.IsZero() {
+					t.firstToken = t.replyEnd
+				}
+				s.gw.metrics.observeReply(t, s.gw.cfg.ReplyMode, s.gw.cfg.TTSMode)
+			})
+			s.finishTiming(utteranceID)
+		}()
+		if prevDone != nil {
+			<-prevDone
+		}
+		if ctx.Err() != nil {
+			s.send(map[string]any{"event": "reply_cancelled", "utterance_id": utteranceID})
+			return
+		}
+		sp = s.newSpeaker(speechCtx, utteranceID)
+		defer func() { sp.Finish(outcome == "ok") }()
+
+		// OnQuiet and the deltas run on this goroutine, so quiet needs no lock.
+		quiet := false
+		req := replyRequest{SessionID: s.id, TerminalID: s.terminalID, Text: text, OnMood: sp.SetMood, OnQuiet: func() { quiet = true }}
+		req.SpeakerEmbedding = voiceprint.wait(ctx)
+		res, err := s.replier.Reply(ctx, req, func(delta string) {
+			s.timing(utteranceID, func(t *utteranceTiming) {
+				if t.firstToken.IsZero() {
+					t.firstToken = time.Now()
+				}
+			})
+			s.send(map[string]any{"event": "reply_delta", "utterance_id": utteranceID, "text": delta})
+			if sp == nil || quiet {
+				s.conv.ReplyStarted(utteranceID)
+				return
+			}
+			sp.Push(delta)
+		})
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				s.send(map[string]any{"event": "reply_cancelled", "utterance_id": utteranceID})
+				return
+			}
+			outcome = "error"
+			s.logger.Warn("reply failed", "utterance_id", utteranceID, "error", err)
+			s.send(map[string]any{"event": "error", "utterance_id": utteranceID, "message": err.Error()})
+			return
+		}
+		outcome = "ok"
+		s.send(map[string]any{
+			"event":           "reply",
+			"utterance_id":    utteranceID,
+			"text":            res.Text,
+			"executed_skills": res.ExecutedSkills,
+		})
+	}()
+}
@@ -0,0 +1,668 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"soul/internal/asr"
+	"soul/internal/audio"
+	"soul/internal/audio/resample"
+	"soul/internal/config"
+	"soul/internal/domain"
+	"soul/internal/tts"
+	"soul/internal/vadx"
+)
+
+type fakeReplier struct {
+	mu    sync.Mutex
+	texts []string
+	// embeddings are the requests' speaker embeddings, in order.
+	embeddings [][]float64
+	block      chan struct{}
+	// deltas replaces the default one-shot "好的" stream.
+	deltas []string
+	// mood is reported through OnMood before any text.
+	mood *domain.SoulEmotionState
+}
+
+func (f *fakeReplier) Reply(ctx context.Context, req replyRequest, onDelta func(string)) (replyResult, error) {
+	f.mu.Lock()
+	f.texts = append(f.texts, req.Text)
+	f.embeddings = append(f.embeddings, req.SpeakerEmbedding)
+	f.mu.Unlock()
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return replyResult{}, ctx.Err()
+		}
+	}
+	if f.mood != nil && req.OnMood != nil {
+		req.OnMood(*f.mood, domain.PersonalityVector{Empathy: 0.5, Sensitivity: 0.5, Stability: 0.5, Expressiveness: 0.5, Dominance: 0.5})
+	}
+	if f.deltas != nil {
+		for _, d := range f.deltas {
+			onDelta(d)
+		}
+		return replyResult{Text: strings.Join(f.deltas, "")}, nil
+	}
+	onDelta("好的")
+	return replyResult{Text: "好的，" + req.Text, ExecutedSkills: []string{"chat"}}, nil
+}
+
+type fakeNotifier struct {
+	mu       sync.Mutex
+	statuses []string
+}
+
+func (f *fakeNotifier) Notify(_ context.Context, terminalID string, payload domain.TerminalStatusPayload) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses = append(f.statuses, terminalID+":"+payload.Status)
+	return nil
+}
+
+func (f *fakeNotifier) PreviewEmotion(_ context.Context, terminalID string, payload domain.EmotionPreviewPayload) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses = append(f.statuses, terminalID+":emotion:"+payload.UserEmotion.Emotion)
+	return nil
+}
+
+func (f *fakeNotifier) snapshot() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.statuses...)
+}
+
+type eventLog struct {
+	mu     sync.Mutex
+	events []map[string]any
+	ch     chan map[string]any
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{ch: make(chan map[string]any, 64)}
+}
+
+func (l *eventLog) send(ev map[string]any) {
+	l.mu.Lock()
+	l.events = append(l.events, ev)
+	l.mu.Unlock()
+	l.ch <- ev
+}
+
+// waitFor returns the first event named name, skipping others.
+func (l *eventLog) waitFor(t *testing.T, name string) map[string]any {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-l.ch:
+			if ev["event"] == name {
+				return ev
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %q event", name)
+		}
+	}
+}
+
+func testGateway(r replier) *gateway {
+	return &gateway{
+		cfg: config.VoiceGatewayConfig{
+			ASRMode:             "mock",
+			ReplyMode:           "soul",
+			ReplyTimeout:        5 * time.Second,
+			TTSMode:             "mock",
+			TTSMinSentenceRunes: 4,
+			TTSMaxSentenceRunes: 60,
+		},
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		engine:    &asr.MockEngine{},
+		replier:   r,
+		vadConfig: vadx.DefaultConfig(),
+		metrics:   newMetrics(prometheus.NewRegistry()),
+		peers:     map[string]*peer{},
+	}
+}
+
+func tone(ms int) []byte {
+	samples := make([]int16, audio.SampleRate*ms/1000)
+	for i := range samples {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*220*float64(i)/audio.SampleRate))
+	}
+	return audio.Bytes(samples)
+}
+
+func silence(ms int) []byte {
+	return make([]byte, audio.SampleRate*ms/1000*2)
+}
+
+func TestSessionRepliesToSegmentedUtterance(t *testing.T) {
+	fr := &fakeReplier{}
+	log := newEventLog()
+	gw := testGateway(fr)
+	sess, err := gw.newSession(sessionOptions{ID: "v-test", Format: resample.Native, Send: log.send})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer sess.Close()
+
+	for _, chunk := range [][]byte{silence(300), tone(600), silence(1000)} {
+		if err := sess.PushAudio(chunk); err != nil {
+			t.Fatalf("push audio: %v", err)
+		}
+	}
+
+	start := log.waitFor(t, "vad")
+	if start["state"] != string(vadx.SpeechStart) || start["utterance_id"] != "u-1" {
+		t.Fatalf("first vad event = %v, want speech_start for u-1", start)
+	}
+	end := log.waitFor(t, "vad")
+	if end["state"] != string(vadx.SpeechEnd) || end["reason"] != "silence" {
+		t.Fatalf("second vad event = %v, want speech_end on silence", end)
+	}
+	final := log.waitFor(t, "asr")
+	if final["is_final"] != true || final["utterance_id"] != "u-1" {
+		t.Fatalf("asr event = %v, want final for u-1", final)
+	}
+	reply := log.waitFor(t, "reply")
+	if reply["utterance_id"] != "u-1" || reply["text"] != "好的，mock 会话结束" {
+		t.Fatalf("reply event = %v", reply)
+	}
+
+	m := log.waitFor(t, "metrics")
+	if m["utterance_id"] != "u-1" || m["outcome"] != "ok" || m["end_reason"] != "silence" {
+		t.Fatalf("metrics event = %v", m)
+	}
+	if m["trailing_silence_ms"] != int64(700) {
+		t.Fatalf("trailing_silence_ms = %v, want 700", m["trailing_silence_ms"])
+	}
+	for _, key := range []string{"asr_latency_ms", "reply_ttft_ms", "reply_ms", "end_to_end_ms"} {
+		if _, ok := m[key]; !ok {
+			t.Fatalf("metrics event missing %s: %v", key, m)
+		}
+	}
+	if got := testutil.ToFloat64(gw.metrics.segments.WithLabelValues("silence")); got != 1 {
+		t.Fatalf("silence segments = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(gw.metrics.replies.WithLabelValues("ok")); got != 1 {
+		t.Fatalf("ok replies = %v, want 1", got)
+	}
+	if n := testutil.CollectAndCount(gw.metrics.endToEnd); n != 1 {
+		t.Fatalf("end-to-end histogram series = %d, want 1", n)
+	}
+}
+
+func TestSessionSkipsASRForSilence(t *testing.T) {
+	log := newEventLog()
+	sess, err := testGateway(&fakeReplier{}).newSession(sessionOptions{ID: "v-test", Format: resample.Native, Send: log.send})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer sess.Close()
+
+	if err := sess.PushAudio(silence(2000)); err != nil {
+		t.Fatalf("push audio: %v", err)
+	}
+	if err := sess.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	if len(log.events) != 0 {
+		t.Fatalf("got events %v for silence, want none", log.events)
+	}
+}
+
+func TestSessionCancelsSupersededReply(t *testing.T) {
+	fr := &fakeReplier{block: make(chan struct{})}
+	log := newEventLog()
+	sess, err := testGateway(fr).newSession(sessionOptions{ID: "v-test", Format: resample.Native, Send: log.send})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer sess.Close()
+
+	sess.startReply("u-1", "第一句")
+	sess.startReply("u-2", "第二句")
+	cancelled := log.waitFor(t, "reply_cancelled")
+	if cancelled["utterance_id"] != "u-1" {
+		t.Fatalf("cancelled = %v, want u-1", cancelled)
+	}
+	close(fr.block)
+	reply := log.waitFor(t, "reply")
+	if reply["utterance_id"] != "u-2" {
+		t.Fatalf("reply = %v, want u-2", reply)
+	}
+	if m := log.waitFor(t, "metrics"); m["utterance_id"] != "u-2" || m["outcome"] != "ok" {
+		t.Fatalf("metrics = %v, want ok for u-2", m)
+	}
+}
+
+func TestSessionResamplesDeclaredFormat(t *testing.T) {
+	log := newEventLog()
+	format, err := resample.Format{SampleRate: 48000, Channels: 2}.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := testGateway(&fakeReplier{}).newSession(sessionOptions{ID: "v-test", Format: format, Send: log.send})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer sess.Close()
+
+	// 600ms of a 48 kHz stereo tone, then a second of silence.
+	var samples []int16
+	for i := 0; i < 48000*6/10; i++ {
+		v := int16(8000 * math.Sin(2*math.Pi*220*float64(i)/48000))
+		samples = append(samples, v, v)
+	}
+	samples = append(samples, make([]int16, 48000*2)...)
+	if err := sess.PushAudio(audio.Bytes(samples)); err != nil {
+		t.Fatalf("push audio: %v", err)
+	}
+
+	log.waitFor(t, "vad")
+	end := log.waitFor(t, "vad")
+	if end["state"] != string(vadx.SpeechEnd) {
+		t.Fatalf("vad event = %v, want speech_end", end)
+	}
+	// The segment spans the tone plus pre-roll and trailing silence; a
+	// missed conversion would triple or halve it.
+	if d := end["duration_ms"].(int64); d < 600 || d > 1600 {
+		t.Fatalf("segment duration = %dms, want roughly the 600ms tone plus padding", d)
+	}
+}
+
+func TestSessionRelaysListeningToTerminal(t *testing.T) {
+	fn := &fakeNotifier{}
+	gw := testGateway(&fakeReplier{})
+	gw.notifier = fn
+	log := newEventLog()
+	sess, err := gw.newSession(sessionOptions{ID: "v-test", TerminalID: "robot-1", Format: resample.Native, Send: log.send})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+
+	if err := sess.PushAudio(tone(600)); err != nil {
+		t.Fatalf("push audio: %v", err)
+	}
+	log.waitFor(t, "vad")
+	if err := sess.PushAudio(silence(1000)); err != nil {
+		t.Fatalf("push audio: %v", err)
+	}
+	log.waitFor(t, "vad")
+	// A second utterance cut off by the client going away still ends with
+	// the face released.
+	if err := sess.PushAudio(tone(600)); err != nil {
+		t.Fatalf("push audio: %v", err)
+	}
+	sess.Close()
+
+	want := []string{"robot-1:listening", "robot-1:listening_stopped", "robot-1:listening", "robot-1:listening_stopped"}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := fn.snapshot()
+		if len(got) == len(want) {
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("statuses = %v, want %v", got, want)
+				}
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("statuses = %v, want %v", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSessionSpeaksReplySentenceBySentence(t *testing.T) {
+	gw := testGateway(&fakeReplier{deltas: []string{"好的，", "我这就帮你", "把灯关上。", "还需要", "别的吗？"}})
+	gw.tts = &tts.MockEngine{}
+	log := newEventLog()
+	var (
+		audioMu sync.Mutex
+		audioN  int
+	)
+	sess, err := gw.newSession(sessionOptions{
+		ID:     "v-test",
+		Format: resample.Native,
+		Send:   log.send,
+		SendAudio: func(pcm []byte) {
+			audioMu.Lock()
+			audioN += len(pcm)
+			audioMu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer sess.Close()
+
+	sess.startReply("u-1", "关灯")
+	first := log.waitFor(t, "tts_start")
+	if first["index"] != 0 || first["text"] != "好的，我这就帮你把灯关上。" || first["sample_rate"] != 16000 {
+		t.Fatalf("first tts_start = %v", first)
+	}
+	second := log.waitFor(t, "tts_start")
+	if second["index"] != 1 || second["text"] != "还需要别的吗？" {
+		t.Fatalf("second tts_start = %v", second)
+	}
+	done := log.waitFor(t, "tts_done")
+	if done["sentences"] != 2 || done["cancelled"] != false {
+		t.Fatalf("tts_done = %v", done)
+	}
+	m := log.waitFor(t, "metrics")
+	if _, ok := m["tts_first_audio_ms"]; !ok {
+		t.Fatalf("metrics event missing tts_first_audio_ms: %v", m)
+	}
+
+	// The mock renders 50ms of 16 kHz audio per character, punctuation included.
+	runes := len([]rune("好的，我这就帮你把灯关上。")) + len([]rune("还需要别的吗？"))
+	audioMu.Lock()
+	defer audioMu.Unlock()
+	if want := runes * 800 * 2; audioN != want {
+		t.Fatalf("sent %d audio bytes, want %d", audioN, want)
+	}
+}
+
+func TestSessionReArmsListeningAfterReply(t *testing.T) {
+	log := newEventLog()
+	gw := testGateway(&fakeReplier{deltas: []string{"好的。"}})
+	gw.cfg.FollowUpWindow = 200 * time.Millisecond
+	gw.tts = &tts.MockEngine{}
+	sess, err := gw.newSession(sessionOptions{ID: "v-test", Format: resample.Native, Send: log.send, SendAudio: func([]byte) {}})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer sess.Close()
+
+	for _, chunk := range [][]byte{tone(600), silence(1000)} {
+		if err := sess.PushAudio(chunk); err != nil {
+			t.Fatalf("push audio: %v", err)
+		}
+	}
+
+	want := []struct{ state, reason string }{
+		{"listening", "speech_start"},
+		{"thinking", "speech_end"},
+		{"speaking", "reply"},
+		{"follow_up", "playback_done"},
+		{"idle", "follow_up_expired"},
+	}
+	prev := "idle"
+	for _, w := range want {
+		ev := log.waitFor(t, "state")
+		if ev["state"] != w.state || ev["previous"] != prev || ev["reason"] != w.reason || ev["utterance_id"] != "u-1" {
+			t.Fatalf("state event = %v, want %s (%s) after %s", ev, w.state, w.reason, prev)
+		}
+		if w.state == "follow_up" && ev["follow_up_ms"] != int64(200) {
+			t.Fatalf("follow_up_ms = %v, want 200", ev["follow_up_ms"])
+		}
+		prev = w.state
+	}
+}
+
+func TestSessionDropsAudioWhileSpeaking(t *testing.T) {
+	for _, bargeIn := range []bool{false, true} {
+		log := newEventLog()
+		gw := testGateway(&fakeReplier{})
+		gw.cfg.BargeIn = bargeIn
+		sess, err := gw.newSession(sessionOptions{ID: "v-test", Format: resample.Native, Send: log.send})
+		if err != nil {
+			t.Fatalf("new session: %v", err)
+		}
+		sess.conv.SpeechStarted("u-0")
+		sess.conv.SpeechEnded("u-0")
+		sess.conv.ReplyStarted("u-0")
+
+		for _, chunk := range [][]byte{tone(600), silence(1000)} {
+			if err := sess.PushAudio(chunk); err != nil {
+				t.Fatalf("push audio: %v", err)
+			}
+		}
+		log.mu.Lock()
+		heard := false
+		for _, ev := range log.events {
+			heard = heard || ev["event"] == "vad"
+		}
+		log.mu.Unlock()
+		if heard != bargeIn {
+			t.Fatalf("barge-in %v: heard speech = %v", bargeIn, heard)
+		}
+		sess.Close()
+	}
+}
+
+// scriptedAnalyzer reads emotions from a table and reports each text it was
+// asked about.
+type scriptedAnalyzer struct {
+	readings map[string]domain.EmotionSignal
+	calls    chan string
+}
+
+func (a *scriptedAnalyzer) Analyze(_ context.Context, text, _ string, _ []domain.Message) (domain.EmotionSignal, error) {
+	a.calls <- text
+	return a.readings[text], nil
+}
+
+func TestSessionPreviewsEmotionFromPartials(t *testing.T) {
+	an := &scriptedAnalyzer{
+		readings: map[string]domain.EmotionSignal{
+			"今天考试":       {Emotion: "anxiety", Intensity: 0.5},
+			"今天考试考得":     {Emotion: "joy", Intensity: 0.6},
+			"今天考试考得特别好":  {Emotion: "joy", Intensity: 0.8},
+			"今天考试考得特别好呀": {Emotion: "anxiety", Intensity: 0.9},
+		},
+		calls: make(chan string, 8),
+	}
+	fn := &fakeNotifier{}
+	gw := testGateway(&fakeReplier{})
+	gw.emotion = an
+	gw.notifier = fn
+	gw.cfg.EmotionInterval = 10 * time.Millisecond
+	gw.cfg.EmotionMaxCalls = 4
+	gw.cfg.EmotionMinRunes = 4
+	gw.cfg.EmotionMinIntensity = 0.4
+	gw.cfg.EmotionConfirm = 2
+	log := newEventLog()
+	sess, err := gw.newSession(sessionOptions{ID: "v-test", TerminalID: "robot-1", Format: resample.Native, Send: log.send})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+
+	partial := func(text string, analyzed bool) {
+		t.Helper()
+		sess.onASR(asr.Result{Text: text})
+		select {
+		case got := <-an.calls:
+			if !analyzed || got != text {
+				t.Fatalf("analyzed %q after partial %q", got, text)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if analyzed {
+				t.Fatalf("partial %q was not analyzed", text)
+			}
+		}
+	}
+	partial("今天", false) // too short to read
+	partial("今天考试", true)
+	partial("今天考试考得", true)
+	partial("今天考试考得特别好", true)
+	ev := log.waitFor(t, "emotion")
+	if ev["emotion"] != "joy" || ev["intensity"] != 0.8 || ev["partial"] != true || ev["utterance_id"] != "u-0" {
+		t.Fatalf("emotion event = %v, want joy confirmed by its second reading", ev)
+	}
+	// A single contrary reading does not move the face, and the budget is
+	// spent after four calls.
+	partial("今天考试考得特别好呀", true)
+	partial("今天考试考得特别好呀！", false)
+	sess.onASR(asr.Result{Text: "今天考试考得特别好呀！", IsFinal: true})
+	sess.Close()
+
+	want := []string{"robot-1:emotion:joy"}
+	if got := fn.snapshot(); len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("terminal updates = %v, want %v", got, want)
+	}
+	if got := testutil.ToFloat64(gw.metrics.emotionCalls.WithLabelValues("ok")); got != 4 {
+		t.Fatalf("emotion calls = %v, want 4", got)
+	}
+}
+
+func TestSessionShapesSpeechByMood(t *testing.T) {
+	for _, persona := range []bool{true, false} {
+		gw := testGateway(&fakeReplier{deltas: []string{"太好了，我们出发吧！"}, mood: &domain.SoulEmotionState{P: 0.6, A: 0.8}})
+		gw.tts = &tts.MockEngine{}
+		gw.ttsPolicy = tts.DefaultPolicy()
+		gw.cfg.TTSPersona = persona
+		log := newEventLog()
+		sess, err := gw.newSession(sessionOptions{ID: "v-test", Format: resample.Native, Send: log.send, SendAudio: func([]byte) {}})
+		if err != nil {
+			t.Fatalf("new session: %v", err)
+		}
+		sess.startReply("u-1", "出发")
+		start := log.waitFor(t, "tts_start")
+		p := start["prosody"].(tts.Prosody)
+		rate := start["sample_rate"].(int)
+		if persona && (p.Speed <= 1 || p.Pitch <= 1 || p.Energy <= 1 || rate <= 16000) {
+			t.Fatalf("excited soul: prosody %+v at %d Hz, want livelier than neutral", p, rate)
+		}
+		if !persona && (p != tts.Prosody{Speed: 0, Pitch: 1, Energy: 1} || rate != 16000) {
+			t.Fatalf("persona off: prosody %+v at %d Hz, want the base delivery", p, rate)
+		}
+		log.waitFor(t, "tts_done")
+		sess.Close()
+	}
+}
+
+// scriptedSpeculator reports a stable match once the text names the
+// action, and each text it was asked about.
+type scriptedSpeculator struct {
+	calls chan domain.IntentSpeculationRequest
+}
+
+func (p *scriptedSpeculator) Speculate(_ context.Context, req domain.IntentSpeculationRequest) (domain.IntentSpeculation, error) {
+	p.calls <- req
+	out := domain.IntentSpeculation{UtteranceID: req.UtteranceID, Text: req.Text}
+	if strings.Contains(req.Text, "开灯") {
+		out.Stable, out.Streak = true, 2
+		out.Intents = []domain.IntentActionItem{{IntentID: "light_on", Confidence: 0.95}}
+	}
+	return out, nil
+}
+
+func TestSessionPrefetchesIntentsFromPartials(t *testing.T) {
+	sp := &scriptedSpeculator{calls: make(chan domain.IntentSpeculationRequest, 8)}
+	gw := testGateway(&fakeReplier{})
+	gw.intents = sp
+	gw.cfg.IntentInterval = 10 * time.Millisecond
+	gw.cfg.IntentMaxCalls = 3
+	gw.cfg.IntentMinRunes = 2
+	log := newEventLog()
+	sess, err := gw.newSession(sessionOptions{ID: "v-test", TerminalID: "robot-1", Format: resample.Native, Send: log.send})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+
+	partial := func(text string, matched bool) {
+		t.Helper()
+		sess.onASR(asr.Result{Text: text})
+		select {
+		case got := <-sp.calls:
+			if !matched || got.Text != text || got.TerminalID != "robot-1" || got.SessionID != "v-test" || got.UtteranceID != "u-0" {
+				t.Fatalf("matched %+v after partial %q", got, text)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if matched {
+				t.Fatalf("partial %q was not matched", text)
+			}
+		}
+	}
+	partial("帮", false) // too short to name an action
+	partial("帮我", true)
+	partial("帮我开灯", true)
+	ev := log.waitFor(t, "intent_ready")
+	if ev["utterance_id"] != "u-0" || ev["text"] != "帮我开灯" {
+		t.Fatalf("intent_ready = %v", ev)
+	}
+	partial("帮我开灯", false) // unchanged
+	partial("帮我开灯吧", true)
+	partial("帮我开灯吧谢谢", false) // over budget
+	sess.onASR(asr.Result{Text: "帮我开灯吧谢谢", IsFinal: true})
+	sess.Close()
+
+	if got := testutil.ToFloat64(gw.metrics.intentSpeculations.WithLabelValues("stable")); got != 2 {
+		t.Fatalf("stable speculations = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(gw.metrics.intentSpeculations.WithLabelValues("unstable")); got != 1 {
+		t.Fatalf("unstable speculations = %v, want 1", got)
+	}
+}
+
+type fakeVoiceprinter struct {
+	mu       sync.Mutex
+	segments []time.Duration
+}
+
+func (f *fakeVoiceprinter) Embed(_ context.Context, pcm []byte) ([]float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.segments = append(f.segments, audio.Duration(len(pcm)))
+	return []float64{0.6, 0.8}, nil
+}
+
+func TestSessionSendsSpeakerEmbeddingWithReply(t *testing.T) {
+	fr := &fakeReplier{}
+	vp := &fakeVoiceprinter{}
+	gw := testGateway(fr)
+	gw.voiceprinter = vp
+	gw.cfg.SpeakerTimeout = time.Second
+	gw.cfg.SpeakerMinSpeech = 800 * time.Millisecond
+	log := newEventLog()
+	sess, err := gw.newSession(sessionOptions{ID: "v-test", Format: resample.Native, Send: log.send})
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer sess.Close()
+
+	utter := func(speech []byte) {
+		t.Helper()
+		for _, chunk := range [][]byte{silence(300), speech, silence(1000)} {
+			if err := sess.PushAudio(chunk); err != nil {
+				t.Fatalf("push audio: %v", err)
+			}
+		}
+		log.waitFor(t, "metrics")
+	}
+	utter(tone(1200))
+	utter(tone(400)) // too short to tell voices apart
+
+	vp.mu.Lock()
+	segments := append([]time.Duration(nil), vp.segments...)
+	vp.mu.Unlock()
+	if len(segments) != 1 || segments[0] < 1200*time.Millisecond {
+		t.Fatalf("embedded segments = %v, want the first utterance only", segments)
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if len(fr.embeddings) != 2 || len(fr.embeddings[0]) != 2 || fr.embeddings[1] != nil {
+		t.Fatalf("reply embeddings = %v, want one for the first utterance only", fr.embeddings)
+	}
+	if got := testutil.ToFloat64(gw.metrics.voiceprints.WithLabelValues("short")); got != 1 {
+		t.Fatalf("short voiceprints = %v, want 1", got)
+	}
+}
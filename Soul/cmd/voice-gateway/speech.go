@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"soul/internal/audio"
+	"soul/internal/domain"
+	"soul/internal/tts"
+)
+
+// speechChunkBytes is about 90ms of 22 kHz audio: small enough to start
+// playback early, large enough to keep frame overhead down.
+const speechChunkBytes = 4096
+
+// speaker voices one reply sentence by sentence while it is still being
+// generated. Sentences are synthesized in order on a single goroutine so
+// audio never interleaves.
+type speaker struct {
+	s           *voiceSession
+	ctx         context.Context
+	utteranceID string
+	split       *tts.Splitter
+	queue       chan string
+	done        chan struct{}
+	queued      int
+
+	// firstAudio and audioDur, written by run and read after done, estimate
+	// when the client finishes playing.
+	firstAudio time.Time
+	audioDur   time.Duration
+
+	// prosody applies to sentences not yet started; SetMood reshapes it.
+	prosodyMu sync.Mutex
+	prosody   tts.Prosody
+}
+
+// newSpeaker returns nil when the gateway has no TTS or the transport cannot
+// carry audio; a nil speaker ignores every call.
+func (s *voiceSession) newSpeaker(ctx context.Context, utteranceID string) *speaker {
+	if s.gw.tts == nil || s.sendAudio == nil {
+		return nil
+	}
+	sp := &speaker{
+		s:           s,
+		ctx:         ctx,
+		utteranceID: utteranceID,
+		split:       tts.NewSplitter(s.gw.cfg.TTSMinSentenceRunes, s.gw.cfg.TTSMaxSentenceRunes),
+		queue:       make(chan string, 64),
+		done:        make(chan struct{}),
+		prosody:     s.baseProsody(),
+	}
+	go sp.run()
+	return sp
+}
+
+func (s *voiceSession) baseProsody() tts.Prosody {
+	return tts.Prosody{Speed: s.gw.cfg.TTSSpeed, Pitch: 1, Energy: 1}
+}
+
+// SetMood shapes the delivery by the soul's state through the gateway's
+// prosody policy, unless VOICE_TTS_PERSONA is off.
+func (sp *speaker) SetMood(mood domain.SoulEmotionState, pv domain.PersonalityVector) {
+	if sp == nil || !sp.s.gw.cfg.TTSPersona {
+		return
+	}
+	p := sp.s.gw.ttsPolicy.Prosody(sp.s.baseProsody(), mood, pv)
+	sp.prosodyMu.Lock()
+	sp.prosody = p
+	sp.prosodyMu.Unlock()
+}
+
+// Push feeds a reply delta; completed sentences start synthesizing.
+func (sp *speaker) Push(delta string) {
+	if sp == nil {
+		return
+	}
+	for _, sentence := range sp.split.Push(delta) {
+		sp.enqueue(sentence)
+	}
+}
+
+// Finish voices the remainder and waits until the last sentence has been
+// sent, or the reply is cancelled.
+func (sp *speaker) Finish(complete bool) {
+	if sp == nil {
+		return
+	}
+	if complete {
+		if rest := sp.split.Flush(); rest != "" {
+			sp.enqueue(rest)
+		}
+	}
+	close(sp.queue)
+	<-sp.done
+}
+
+func (sp *speaker) enqueue(sentence string) {
+	select {
+	case sp.queue <- sentence:
+		sp.queued++
+	case <-sp.ctx.Done():
+	}
+}
+
+func (sp *speaker) run() {
+	defer close(sp.done)
+	index := 0
+	for sentence := range sp.queue {
+		if sp.ctx.Err() != nil {
+			continue
+		}
+		if err := sp.speak(index, sentence); err != nil && sp.ctx.Err() == nil {
+			sp.s.logger.Warn("tts failed", "utterance_id", sp.utteranceID, "sentence", index, "error", err)
+			sp.s.send(map[string]any{"event": "error", "utterance_id": sp.utteranceID, "message": "tts: " + err.Error()})
+		}
+		index++
+	}
+	sp.s.send(map[string]any{
+		"event":        "tts_done",
+		"utterance_id": sp.utteranceID,
+		"sentences":    sp.queued,
+		"cancelled":    sp.ctx.Err() != nil,
+	})
+}
+
+// speak streams one sentence: tts_start with the sample rate, binary PCM
+// chunks, then tts_end.
+//
+// Backends only take a speed, so pitch is varispeed: the sentence is
+// rendered slower by the pitch ratio and labelled with a sample rate higher
+// by the same ratio, which the client plays back at the intended rate and
+// raised pitch. Energy is a gain on the samples.
+func (sp *speaker) speak(index int, sentence string) error {
+	sp.prosodyMu.Lock()
+	p := sp.prosody
+	sp.prosodyMu.Unlock()
+	speech, err := sp.s.gw.tts.Synthesize(sp.ctx, tts.Request{
+		Text:  sentence,
+		Voice: p.Voice,
+		Speed: p.Speed / p.Pitch,
+	})
+	if err != nil {
+		return err
+	}
+	defer speech.Body.Close()
+	rate := int(math.Round(float64(speech.SampleRate) * p.Pitch))
+
+	sp.s.send(map[string]any{
+		"event":        "tts_start",
+		"utterance_id": sp.utteranceID,
+		"index":        index,
+		"text":         sentence,
+		"sample_rate":  rate,
+		"prosody":      p,
+	})
+	var sent, carry int
+	buf := make([]byte, speechChunkBytes)
+	for {
+		n, err := speech.Body.Read(buf[carry:])
+		n += carry
+		// Keep chunks sample aligned for clients that play them directly;
+		// an odd trailing byte waits for its pair.
+		even := n - n%2
+		if even > 0 {
+			if sp.firstAudio.IsZero() {
+				sp.firstAudio = time.Now()
+				sp.s.timing(sp.utteranceID, func(t *utteranceTiming) { t.firstAudio = sp.firstAudio })
+				sp.s.conv.ReplyStarted(sp.utteranceID)
+			}
+			chunk := append([]byte(nil), buf[:even]...)
+			if p.Energy != 1 {
+				audio.Gain(chunk, p.Energy)
+			}
+			sp.s.sendAudio(chunk)
+			sent += even
+		}
+		if carry = n - even; carry > 0 {
+			buf[0] = buf[even]
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	duration := time.Duration(sent/2) * time.Second / time.Duration(rate)
+	sp.audioDur += duration
+	sp.s.send(map[string]any{
+		"event":        "tts_end",
+		"utterance_id": sp.utteranceID,
+		"index":        index,
+		"duration_ms":  duration.Milliseconds(),
+	})
+	return nil
+}
+
+// remainingPlayback estimates how much of the sent audio the client has yet
+// to play, assuming playback started with the first chunk. Call after Finish.
+func (sp *speaker) remainingPlayback() time.Duration {
+	if sp == nil || sp.firstAudio.IsZero() {
+		return 0
+	}
+	return max(0, time.Until(sp.firstAudio.Add(sp.audioDur)))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// notifier tells a terminal that its user is (or stopped) speaking, and how
+// they seem to feel while they do.
+type notifier interface {
+	Notify(ctx context.Context, terminalID string, payload domain.TerminalStatusPayload) error
+	PreviewEmotion(ctx context.Context, terminalID string, payload domain.EmotionPreviewPayload) error
+}
+
+// soulStatusNotifier goes through soul-server, which owns the MQTT
+// connection to terminals.
+type soulStatusNotifier struct {
+	client  *http.Client
+	baseURL string
+}
+
+func (n *soulStatusNotifier) Notify(ctx context.Context, terminalID string, payload domain.TerminalStatusPayload) error {
+	return n.post(ctx, terminalID, "status", payload)
+}
+
+func (n *soulStatusNotifier) PreviewEmotion(ctx context.Context, terminalID string, payload domain.EmotionPreviewPayload) error {
+	return n.post(ctx, terminalID, "emotion_preview", payload)
+}
+
+func (n *soulStatusNotifier) post(ctx context.Context, terminalID, path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	endpoint := n.baseURL + "/v1/terminals/" + url.PathEscape(terminalID) + "/" + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("terminal %s %d: %s", path, resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	return nil
+}
+
+// statusQueueSize bounds undelivered statuses; a stalled relay drops the
+// newest rather than blocking the audio path.
+const statusQueueSize = 8
+
+// terminalUpdate is a status or, when emotion is set, an emotion preview.
+type terminalUpdate struct {
+	status  string
+	emotion *domain.EmotionSignal
+}
+
+// relayStatuses delivers queued updates in order until done is closed,
+// then flushes what is left.
+func (s *voiceSession) relayStatuses(queue <-chan terminalUpdate, done <-chan struct{}) {
+	deliver := func(u terminalUpdate) {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if u.emotion != nil {
+			err := s.gw.notifier.PreviewEmotion(ctx, s.terminalID, domain.EmotionPreviewPayload{SessionID: s.id, UserEmotion: *u.emotion})
+			if err != nil {
+				s.logger.Warn("relay emotion preview failed", "terminal_id", s.terminalID, "emotion", u.emotion.Emotion, "error", err)
+			}
+			return
+		}
+		err := s.gw.notifier.Notify(ctx, s.terminalID, domain.TerminalStatusPayload{Status: u.status, SessionID: s.id})
+		if err != nil {
+			s.logger.Warn("relay terminal status failed", "terminal_id", s.terminalID, "status", u.status, "error", err)
+		}
+	}
+	for {
+		select {
+		case u := <-queue:
+			deliver(u)
+		case <-done:
+			for {
+				select {
+				case u := <-queue:
+					deliver(u)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// onStateChange reports a conversation transition to the client and mirrors
+// the listening state to the terminal. It runs under the conversation lock.
+func (s *voiceSession) onStateChange(from, to convState, reason, utteranceID string) {
+	ev := map[string]any{
+		"event":        "state",
+		"state":        string(to),
+		"previous":     string(from),
+		"reason":       reason,
+		"utterance_id": utteranceID,
+	}
+	if to == stateFollowUp {
+		ev["follow_up_ms"] = s.gw.cfg.FollowUpWindow.Milliseconds()
+	}
+	s.send(ev)
+
+	switch {
+	case to == stateListening:
+		s.queueTerminal(terminalUpdate{status: domain.TerminalStatusListening})
+	case from == stateListening:
+		s.queueTerminal(terminalUpdate{status: domain.TerminalStatusListeningStopped})
+	}
+}
+
+// onEmotionPreview shows a confirmed partial-transcript emotion to the
+// client and the terminal.
+func (s *voiceSession) onEmotionPreview(utteranceID string, sig domain.EmotionSignal) {
+	s.gw.metrics.emotionPreviews.WithLabelValues(sig.Emotion).Inc()
+	s.send(map[string]any{
+		"event":        "emotion",
+		"utterance_id": utteranceID,
+		"emotion":      sig.Emotion,
+		"p":            sig.P,
+		"a":            sig.A,
+		"d":            sig.D,
+		"intensity":    sig.Intensity,
+		"partial":      true,
+	})
+	s.queueTerminal(terminalUpdate{emotion: &sig})
+}
+
+// onIntentReady tells the client which action is held for the final
+// transcript, so it can show it before the robot moves.
+func (s *voiceSession) onIntentReady(utteranceID string, spec domain.IntentSpeculation) {
+	s.send(map[string]any{
+		"event":        "intent_ready",
+		"utterance_id": utteranceID,
+		"text":         spec.Text,
+		"intents":      spec.Intents,
+	})
+}
+
+func (s *voiceSession) queueTerminal(u terminalUpdate) {
+	if s.statusQueue == nil {
+		return
+	}
+	select {
+	case s.statusQueue <- u:
+	default:
+		s.logger.Warn("terminal status queue full", "status", u.status)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"soul/internal/audio"
+)
+
+// voiceprinter turns one speech segment into a speaker embedding, which
+// soul-server matches against the speakers enrolled with POST
+// /v1/speakers/enroll.
+type voiceprinter interface {
+	Embed(ctx context.Context, pcm []byte) ([]float64, error)
+}
+
+// httpVoiceprinter POSTs the segment as a 16 kHz mono WAV to an embedding
+// service answering {"embedding": [...]}.
+type httpVoiceprinter struct {
+	client *http.Client
+	url    string
+}
+
+func (v *httpVoiceprinter) Embed(ctx context.Context, pcm []byte) ([]float64, error) {
+	var body bytes.Buffer
+	if err := audio.WriteWAV(&body, pcm); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "audio/wav")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("speaker embedding status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	var out struct {
+		Embedding []float64 `json:"embedding"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("decode speaker embedding: %w", err)
+	}
+	if len(out.Embedding) == 0 {
+		return nil, fmt.Errorf("speaker embedding is empty")
+	}
+	return out.Embedding, nil
+}
+
+// voiceprintJob is the embedding of one utterance, computed while ASR
+// transcribes it; done closes once embedding is set or given up on.
+type voiceprintJob struct {
+	done      chan struct{}
+	embedding []float64
+}
+
+// startVoiceprint embeds a closed segment in the background. Segments with
+// too little voiced audio to tell voices apart are skipped. Callers hold s.mu.
+func (s *voiceSession) startVoiceprint(utteranceID string, pcm []byte, voiced time.Duration) {
+	if voiced < s.gw.cfg.SpeakerMinSpeech {
+		s.gw.metrics.voiceprints.WithLabelValues("short").Inc()
+		return
+	}
+	job := &voiceprintJob{done: make(chan struct{})}
+	s.uttMu.Lock()
+	s.voiceprints[utteranceID] = job
+	s.uttMu.Unlock()
+	pcm = bytes.Clone(pcm)
+	go func() {
+		defer close(job.done)
+		ctx, cancel := context.WithTimeout(context.Background(), s.gw.cfg.SpeakerTimeout)
+		defer cancel()
+		embedding, err := s.gw.voiceprinter.Embed(ctx, pcm)
+		if err != nil {
+			s.gw.metrics.voiceprints.WithLabelValues("error").Inc()
+			s.logger.Warn("speaker embedding failed", "utterance_id", utteranceID, "error", err)
+			return
+		}
+		s.gw.metrics.voiceprints.WithLabelValues("ok").Inc()
+		job.embedding = embedding
+	}()
+}
+
+// takeVoiceprint hands over the utterance's embedding job, if one was
+// started; nil otherwise.
+func (s *voiceSession) takeVoiceprint(utteranceID string) *voiceprintJob {
+	s.uttMu.Lock()
+	defer s.uttMu.Unlock()
+	job := s.voiceprints[utteranceID]
+	delete(s.voiceprints, utteranceID)
+	return job
+}
+
+// wait returns the embedding once it is ready, or nil when it failed or ctx
+// ended first. The job is bounded by SpeakerTimeout from the segment's end,
+// so it mostly finishes while ASR is still running.
+func (j *voiceprintJob) wait(ctx context.Context) []float64 {
+	if j == nil {
+		return nil
+	}
+	select {
+	case <-j.done:
+		return j.embedding
+	case <-ctx.Done():
+		return nil
+	}
+}
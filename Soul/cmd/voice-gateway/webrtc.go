@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/interceptor"
+	"github.com/pion/webrtc/v4"
+
+	"soul/internal/audio"
+	"soul/internal/audio/resample"
+	"soul/internal/config"
+)
+
+type offerRequest struct {
+	SDP  string `json:"sdp"`
+	Type string `json:"type"`
+	// SessionID renegotiates an existing peer, e.g. an ICE restart after a
+	// network change, instead of starting a new session.
+	SessionID string `json:"session_id,omitempty"`
+	// TerminalID is the terminal whose microphone this is, as in the
+	// WebSocket start command.
+	TerminalID string `json:"terminal_id,omitempty"`
+}
+
+type offerResponse struct {
+	SDP       string `json:"sdp"`
+	Type      string `json:"type"`
+	SessionID string `json:"session_id"`
+	ASRMode   string `json:"asr_mode"`
+}
+
+// peer is one browser connection: an Opus audio track or "audio" data
+// channel PCM in, events out on the data channel.
+type peer struct {
+	id      string
+	pc      *webrtc.PeerConnection
+	sess    *voiceSession
+	once    sync.Once
+	cleanup func()
+
+	mu    sync.Mutex
+	grace *time.Timer
+}
+
+// newWebRTCAPI shares one UDP port across peers when udpPort is set and
+// registers Opus with the default interceptors.
+func newWebRTCAPI(udpPort int, publicIP string) (*webrtc.API, *net.UDPConn, error) {
+	var se webrtc.SettingEngine
+	var listener *net.UDPConn
+	if udpPort > 0 {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero, Port: udpPort})
+		if err != nil {
+			return nil, nil, fmt.Errorf("listen udp :%d failed: %w", udpPort, err)
+		}
+		listener = conn
+		se.SetICEUDPMux(webrtc.NewICEUDPMux(nil, conn))
+	}
+	if publicIP != "" {
+		se.SetNAT1To1IPs([]string{publicIP}, webrtc.ICECandidateTypeHost)
+	}
+
+	var m webrtc.MediaEngine
+	if err := audio.RegisterOpus(&m); err != nil {
+		return nil, nil, fmt.Errorf("register opus codec failed: %w", err)
+	}
+	var registry interceptor.Registry
+	if err := webrtc.RegisterDefaultInterceptors(&m, &registry); err != nil {
+		return nil, nil, fmt.Errorf("register interceptors failed: %w", err)
+	}
+	return webrtc.NewAPI(webrtc.WithSettingEngine(se), webrtc.WithMediaEngine(&m), webrtc.WithInterceptorRegistry(&registry)), listener, nil
+}
+
+// iceConfiguration turns the STUN/TURN URLs into the configuration shared by
+// the gateway's peers and, via /v1/voice/ice-servers, the browser's.
+func iceConfiguration(cfg config.VoiceGatewayConfig) (webrtc.Configuration, error) {
+	var out webrtc.Configuration
+	var stunURLs, turnURLs []string
+	for _, u := range cfg.ICEServers {
+		switch {
+		case strings.HasPrefix(u, "stun:") || strings.HasPrefix(u, "stuns:"):
+			stunURLs = append(stunURLs, u)
+		case strings.HasPrefix(u, "turn:") || strings.HasPrefix(u, "turns:"):
+			turnURLs = append(turnURLs, u)
+		default:
+			return out, fmt.Errorf("unsupported ICE server URL %q", u)
+		}
+	}
+	if len(stunURLs) > 0 {
+		out.ICEServers = append(out.ICEServers, webrtc.ICEServer{URLs: stunURLs})
+	}
+	if len(turnURLs) > 0 {
+		if cfg.ICEUsername == "" || cfg.ICECredential == "" {
+			return out, errors.New("TURN servers need VOICE_ICE_USERNAME and VOICE_ICE_CREDENTIAL")
+		}
+		out.ICEServers = append(out.ICEServers, webrtc.ICEServer{URLs: turnURLs, Username: cfg.ICEUsername, Credential: cfg.ICECredential})
+	}
+	out.ICETransportPolicy = webrtc.ICETransportPolicyAll
+	if cfg.ICETransportPolicy == "relay" {
+		if len(turnURLs) == 0 {
+			return out, errors.New("VOICE_ICE_TRANSPORT_POLICY=relay needs a TURN server")
+		}
+		out.ICETransportPolicy = webrtc.ICETransportPolicyRelay
+	}
+	return out, nil
+}
+
+func (gw *gateway) handleICEServers(w http.ResponseWriter, _ *http.Request) {
+	type browserICEServer struct {
+		URLs       []string `json:"urls"`
+		Username   string   `json:"username,omitempty"`
+		Credential string   `json:"credential,omitempty"`
+	}
+	servers := make([]browserICEServer, 0, len(gw.ice.ICEServers))
+	for _, srv := range gw.ice.ICEServers {
+		cred, _ := srv.Credential.(string)
+		servers = append(servers, browserICEServer{URLs: srv.URLs, Username: srv.Username, Credential: cred})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ice_servers":          servers,
+		"ice_transport_policy": gw.ice.ICETransportPolicy.String(),
+	})
+}
+
+func (gw *gateway) handleOffer(w http.ResponseWriter, r *http.Request) {
+	var req offerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid json"})
+		return
+	}
+	offer := webrtc.SessionDescription{Type: webrtc.NewSDPType(req.Type), SDP: req.SDP}
+	if req.SDP == "" || offer.Type != webrtc.SDPTypeOffer {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "sdp and type=offer are required"})
+		return
+	}
+	if req.SessionID != "" {
+		gw.renegotiate(w, req.SessionID, offer)
+		return
+	}
+
+	pc, err := gw.api.NewPeerConnection(gw.ice)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"error": fmt.Sprintf("create peer connection failed: %v", err)})
+		return
+	}
+	p := &peer{id: "v-" + uuid.NewString()[:8], pc: pc}
+	logger := gw.logger.With("session_id", p.id)
+
+	var (
+		sendMu sync.Mutex
+		dc     *webrtc.DataChannel
+	)
+	send := func(ev map[string]any) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		if dc == nil || dc.ReadyState() != webrtc.DataChannelStateOpen {
+			return
+		}
+		payload, err := json.Marshal(ev)
+		if err != nil {
+			return
+		}
+		if err := dc.SendText(string(payload)); err != nil {
+			logger.Debug("data channel send failed", "error", err)
+		}
+	}
+	sendAudio := func(pcm []byte) {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		if dc == nil || dc.ReadyState() != webrtc.DataChannelStateOpen {
+			return
+		}
+		if err := dc.Send(pcm); err != nil {
+			logger.Debug("data channel send failed", "error", err)
+		}
+	}
+	p.sess, err = gw.newSession(sessionOptions{
+		ID:         p.id,
+		TerminalID: strings.TrimSpace(req.TerminalID),
+		Format:     resample.Native,
+		Send:       send,
+		SendAudio:  sendAudio,
+	})
+	if err != nil {
+		_ = pc.Close()
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": err.Error()})
+		return
+	}
+	p.cleanup = func() {
+		p.once.Do(func() {
+			p.stopGrace()
+			gw.peersMu.Lock()
+			delete(gw.peers, p.id)
+			gw.peersMu.Unlock()
+			if err := p.sess.Flush(); err != nil {
+				logger.Warn("flush on close failed", "error", err)
+			}
+			p.sess.Close()
+			_ = pc.Close()
+		})
+	}
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		logger.Info("peer state changed", "state", state.String())
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			p.stopGrace()
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateDisconnected:
+			// The browser may be switching networks; keep the session for
+			// an ICE restart before giving up.
+			p.startGrace(gw.cfg.ICERestartGrace, logger.Info)
+		case webrtc.PeerConnectionStateClosed:
+			p.cleanup()
+		}
+	})
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if track.Kind() != webrtc.RTPCodecTypeAudio {
+			return
+		}
+		logger.Info("audio track started", "codec", track.Codec().MimeType, "ssrc", track.SSRC())
+		stats, err := audio.PumpOpusTrack(track, p.sess.PushAudio)
+		if err != nil {
+			logger.Warn("audio track stopped", "error", err)
+		}
+		logger.Info("audio track ended", "packets", stats.Packets, "lost", stats.Lost, "decode_fails", stats.DecodeFails)
+	})
+	pc.OnDataChannel(func(ch *webrtc.DataChannel) {
+		if ch.Label() != "audio" {
+			return
+		}
+		sendMu.Lock()
+		dc = ch
+		sendMu.Unlock()
+		ch.OnOpen(func() {
+			send(map[string]any{"event": "started", "session_id": p.id, "asr_mode": gw.cfg.ASRMode, "reply_mode": gw.cfg.ReplyMode, "state": p.sess.conv.State()})
+		})
+		ch.OnMessage(func(msg webrtc.DataChannelMessage) {
+			if !msg.IsString {
+				if err := p.sess.PushAudio(msg.Data); err != nil {
+					send(map[string]any{"event": "error", "message": err.Error()})
+				}
+				return
+			}
+			var cmd command
+			if err := json.Unmarshal(msg.Data, &cmd); err != nil {
+				return
+			}
+			switch cmd.Type {
+			case "flush":
+				if err := p.sess.Flush(); err != nil {
+					send(map[string]any{"event": "error", "message": err.Error()})
+				}
+			case "playback_done":
+				p.sess.conv.PlaybackDone()
+			case "ping":
+				send(map[string]any{"event": "pong"})
+			}
+		})
+		ch.OnClose(p.cleanup)
+	})
+
+	local, status, err := answerOffer(pc, offer)
+	if err != nil {
+		p.cleanup()
+		writeJSON(w, status, map[string]any{"error": err.Error()})
+		return
+	}
+	gw.peersMu.Lock()
+	gw.peers[p.id] = p
+	gw.peersMu.Unlock()
+	writeJSON(w, http.StatusOK, offerResponse{SDP: local.SDP, Type: local.Type.String(), SessionID: p.id, ASRMode: gw.cfg.ASRMode})
+}
+
+// renegotiate answers a new offer on an existing peer. Fresh ICE credentials
+// in the offer restart ICE while the session and data channel carry on.
+func (gw *gateway) renegotiate(w http.ResponseWriter, sessionID string, offer webrtc.SessionDescription) {
+	gw.peersMu.Lock()
+	p := gw.peers[sessionID]
+	gw.peersMu.Unlock()
+	if p == nil {
+		writeJSON(w, http.StatusNotFound, map[string]any{"error": "unknown or expired session"})
+		return
+	}
+	gw.logger.Info("renegotiating peer", "session_id", sessionID)
+	local, status, err := answerOffer(p.pc, offer)
+	if err != nil {
+		writeJSON(w, status, map[string]any{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, offerResponse{SDP: local.SDP, Type: local.Type.String(), SessionID: sessionID, ASRMode: gw.cfg.ASRMode})
+}
+
+// answerOffer applies offer and returns the answer with all candidates
+// gathered, along with the HTTP status to report on failure.
+func answerOffer(pc *webrtc.PeerConnection, offer webrtc.SessionDescription) (*webrtc.SessionDescription, int, error) {
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("set remote description failed: %w", err)
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("create answer failed: %w", err)
+	}
+	gatherDone := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("set local description failed: %w", err)
+	}
+	<-gatherDone
+
+	local := pc.LocalDescription()
+	if local == nil {
+		return nil, http.StatusInternalServerError, errors.New("local description is empty")
+	}
+	return local, http.StatusOK, nil
+}
+
+// startGrace tears the peer down after d unless it reconnects first.
+func (p *peer) startGrace(d time.Duration, logf func(string, ...any)) {
+	if d <= 0 {
+		p.cleanup()
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.grace != nil {
+		return
+	}
+	logf("waiting for ice restart", "grace", d)
+	p.grace = time.AfterFunc(d, p.cleanup)
+}
+
+func (p *peer) stopGrace() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.grace != nil {
+		p.grace.Stop()
+		p.grace = nil
+	}
+}
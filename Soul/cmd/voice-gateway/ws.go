@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"soul/internal/audio/resample"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  32 << 10,
+	WriteBufferSize: 32 << 10,
+	CheckOrigin:     func(*http.Request) bool { return true },
+}
+
+// command is a JSON text frame from the client. Binary frames carry audio.
+type command struct {
+	Type      string `json:"type"`
+	SessionID string `json:"session_id,omitempty"`
+	// TerminalID names the terminal whose microphone this is; replies and
+	// listening statuses go to it. Defaults to VOICE_TERMINAL_ID.
+	TerminalID string `json:"terminal_id,omitempty"`
+	// Format declares the audio that follows start; omitted fields default
+	// to 16 kHz mono PCM16LE.
+	Format resample.Format `json:"format,omitempty"`
+}
+
+// handleWS serves the voice WebSocket: start, then binary PCM16LE frames in
+// the declared format, with flush to close the current utterance and stop to end the session.
+func (gw *gateway) handleWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		gw.logger.Warn("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close()
+
+	var writeMu sync.Mutex
+	send := func(ev map[string]any) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := conn.WriteJSON(ev); err != nil {
+			gw.logger.Debug("websocket write failed", "error", err)
+		}
+	}
+
+	sendAudio := func(pcm []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := conn.WriteMessage(websocket.BinaryMessage, pcm); err != nil {
+			gw.logger.Debug("websocket write failed", "error", err)
+		}
+	}
+
+	var sess *voiceSession
+	defer func() {
+		if sess != nil {
+			sess.Close()
+		}
+	}()
+
+	for {
+		msgType, payload, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if msgType == websocket.BinaryMessage {
+			if sess == nil {
+				send(map[string]any{"event": "error", "message": "send start before audio"})
+				continue
+			}
+			if err := sess.PushAudio(payload); err != nil {
+				send(map[string]any{"event": "error", "message": err.Error()})
+			}
+			continue
+		}
+
+		var cmd command
+		if err := json.Unmarshal(payload, &cmd); err != nil {
+			send(map[string]any{"event": "error", "message": "invalid json"})
+			continue
+		}
+		switch cmd.Type {
+		case "start":
+			if sess != nil {
+				send(map[string]any{"event": "error", "message": "session already started"})
+				continue
+			}
+			format, err := cmd.Format.Normalize()
+			if err != nil {
+				send(map[string]any{"event": "error", "message": err.Error()})
+				continue
+			}
+			id := strings.TrimSpace(cmd.SessionID)
+			if id == "" {
+				id = "v-" + uuid.NewString()[:8]
+			}
+			sess, err = gw.newSession(sessionOptions{
+				ID:         id,
+				TerminalID: strings.TrimSpace(cmd.TerminalID),
+				Format:     format,
+				Send:       send,
+				SendAudio:  sendAudio,
+			})
+			if err != nil {
+				send(map[string]any{"event": "error", "message": err.Error()})
+				continue
+			}
+			send(map[string]any{"event": "started", "session_id": id, "asr_mode": gw.cfg.ASRMode, "reply_mode": gw.cfg.ReplyMode, "terminal_id": sess.terminalID, "format": format, "state": sess.conv.State()})
+		case "flush", "stop":
+			if sess == nil {
+				continue
+			}
+			if err := sess.Flush(); err != nil {
+				send(map[string]any{"event": "error", "message": err.Error()})
+			}
+			if cmd.Type == "stop" {
+				sess.Close()
+				sess = nil
+				send(map[string]any{"event": "stopped"})
+			}
+		case "playback_done":
+			if sess != nil {
+				sess.conv.PlaybackDone()
+			}
+		case "ping":
+			send(map[string]any{"event": "pong"})
+		default:
+			send(map[string]any{"event": "error", "message": "unknown command: " + cmd.Type})
+		}
+	}
+}
@@ -4,7 +4,7 @@ import re
 import shutil
 import time
 from functools import lru_cache
-from math import sqrt
+from math import exp, sqrt
 from pathlib import Path
 from typing import Any
 
@@ -12,11 +12,18 @@ from fastapi import FastAPI, HTTPException
 from pydantic import BaseModel, Field
 from transformers import AutoTokenizer, pipeline
 
+try:
+    from lexicon import Lexicon, build_lexicon
+except ImportError:  # loaded as a package, e.g. uvicorn emotion-server-py.app:app
+    from .lexicon import Lexicon, build_lexicon
+
 MODEL_ID = os.getenv(
     "EMOTION_MODEL_ID", "MoritzLaurer/mDeBERTa-v3-base-xnli-multilingual-nli-2mil7"
 )
 ENGINE = "python-mdeberta-xnli-pad"
 HYPOTHESIS_TEMPLATE = "这句话表达的是{}。"
+HYPOTHESIS_TEMPLATE_EN = "This text expresses {}."
+SUPPORTED_LANGUAGES = {"zh", "en"}
 WARMUP_TEXT = os.getenv("EMOTION_WARMUP_TEXT", "你好")
 USE_ONNX = os.getenv("EMOTION_USE_ONNX", "1") == "1"
 USE_ONNX_INT8 = os.getenv("EMOTION_ONNX_INT8", "1") == "1"
@@ -24,6 +31,16 @@ HF_HOME = os.getenv("HF_HOME", "/models")
 ONNX_ROOT = Path(os.getenv("EMOTION_ONNX_DIR", str(Path(HF_HOME) / "onnx")))
 ONNX_MODEL_DIR = ONNX_ROOT / MODEL_ID.replace("/", "--")
 ONNX_INT8_DIR = ONNX_MODEL_DIR / "int8"
+# Label scores are softmaxed at this temperature into calibrated candidate
+# probabilities; a top label leading the runner-up by less than the margin
+# is reported as ambiguous.
+SCORE_TEMPERATURE = max(float(os.getenv("EMOTION_SCORE_TEMPERATURE", "0.1")), 1e-3)
+AMBIGUITY_MARGIN = float(os.getenv("EMOTION_AMBIGUITY_MARGIN", "0.15"))
+# Replies this short ("嗯", "随便") are also read together with the last
+# CONTEXT_TURNS history turns, and that reading weighs CONTEXT_WEIGHT in PAD.
+CONTEXT_MAX_CHARS = int(os.getenv("EMOTION_CONTEXT_MAX_CHARS", "8"))
+CONTEXT_WEIGHT = min(max(float(os.getenv("EMOTION_CONTEXT_WEIGHT", "0.6")), 0.0), 1.0)
+CONTEXT_TURNS = 2
 
 logger = logging.getLogger("emotion-server")
 logging.basicConfig(level=os.getenv("LOG_LEVEL", "INFO"))
@@ -71,9 +88,28 @@ AXIS_ANCHORS: dict[str, dict[str, list[str]]] = {
     },
 }
 
-ALL_ANCHOR_LABELS: list[str] = [
-    label for axis in AXIS_ANCHORS.values() for side in axis.values() for label in side
-]
+AXIS_ANCHORS_EN: dict[str, dict[str, list[str]]] = {
+    "p": {
+        "pos": ["pleasure and positivity", "contentment and happiness", "feeling appreciated"],
+        "neg": ["pain and negativity", "loss and distress", "feeling rejected"],
+    },
+    "a": {
+        "pos": ["excitement and tension", "high arousal", "intense emotion"],
+        "neg": ["calm and relaxation", "low arousal", "mild emotion"],
+    },
+    "d": {
+        "pos": ["control and confidence", "taking the lead", "able to cope"],
+        "neg": ["powerlessness", "being controlled and withdrawing", "losing control of the situation"],
+    },
+}
+
+
+def _anchor_labels(anchors: dict[str, dict[str, list[str]]]) -> list[str]:
+    return [label for axis in anchors.values() for side in axis.values() for label in side]
+
+
+ALL_ANCHOR_LABELS: list[str] = _anchor_labels(AXIS_ANCHORS)
+ALL_ANCHOR_LABELS_EN: list[str] = _anchor_labels(AXIS_ANCHORS_EN)
 
 # Alias normalization only for /convert compatibility.
 LABEL_ALIASES = {
@@ -98,44 +134,21 @@ LABEL_ALIASES = {
     "boredom": "boredom",
 }
 
-# Text-rule fallback for low-amplitude PAD outputs.
-# Purpose: keep task commands neutral while lifting clear affective utterances.
-EMOTION_KEYWORDS: dict[str, list[str]] = {
-    "anger": ["生气", "愤怒", "火大", "气死", "恼火", "怒了", "发火"],
-    "anxiety": ["焦虑", "紧张", "不安", "慌", "睡不着", "忐忑", "压力很大", "担心"],
-    "boredom": ["无聊", "没意思", "很闲", "发呆", "无趣"],
-    "calm": ["平静", "冷静", "淡定", "放松", "安稳"],
-    "disappointment": ["失望", "落空", "白期待", "不如预期"],
-    "disgust": ["恶心", "反胃", "厌恶", "嫌弃"],
-    "excitement": ["兴奋", "激动", "太爽", "燃起来", "冲啊"],
-    "fear": ["害怕", "恐惧", "吓到", "可怕", "发怵"],
-    "frustration": ["挫败", "受挫", "崩溃", "卡住了", "做不出来", "烦死了"],
-    "gratitude": ["感谢", "谢谢", "多谢", "感激"],
-    "joy": ["开心", "高兴", "快乐", "哈哈", "太棒了", "不错", "喜悦"],
-    "neutral": [],
-    "relief": ["松了一口气", "还好", "终于结束", "放心了", "释然"],
-    "sadness": ["难过", "伤心", "失恋", "想哭", "哭了", "低落", "不开心"],
-    "surprise": ["惊讶", "震惊", "没想到", "居然", "竟然", "哇"],
-}
+# Keyword automata for the text-rule refinement, compiled before the first
+# request and rebuilt whenever PUT /v1/emotion/lexicon replaces a lexicon.
+LEXICONS: dict[str, Lexicon] = {language: build_lexicon(language) for language in SUPPORTED_LANGUAGES}
+
 
-TASK_HINTS = [
-    "开灯",
-    "关灯",
-    "亮灯",
-    "变红",
-    "变绿",
-    "提醒",
-    "闹钟",
-    "点头",
-    "摇头",
-    "发邮件",
-    "发一封邮件",
-    "设置",
-]
+class HistoryTurn(BaseModel):
+    role: str = Field(..., description="user / assistant")
+    content: str
 
 
 class AnalyzeRequest(BaseModel):
     text: str = Field(..., min_length=1)
+    language: str | None = Field(default=None, description="zh / en；缺省时按文本检测")
+    top_k: int = Field(default=3, ge=1, le=15, description="返回的候选情绪数")
+    history: list[HistoryTurn] | None = Field(default=None, description="此前的对话（旧→新）；短回复结合最近两轮理解")
 
 
 class ConvertRequest(BaseModel):
@@ -143,6 +156,11 @@ class ConvertRequest(BaseModel):
     confidence: float = Field(..., ge=0.0, le=1.0)
 
 
+class LexiconRequest(BaseModel):
+    keywords: dict[str, list[str]] = Field(..., description="情绪标签 -> 关键词；整表替换")
+    task_hints: list[str] | None = Field(default=None, description="任务指令提示词；缺省时沿用当前")
+
+
 def normalize_label(label: str) -> str | None:
     if not label:
         return None
@@ -156,29 +174,55 @@ def clamp(v: float, lo: float = -1.0, hi: float = 1.0) -> float:
     return max(lo, min(hi, v))
 
 
-def _normalize_text_for_rules(text: str) -> str:
+def resolve_language(text: str, hint: str | None) -> str:
+    if hint:
+        key = hint.strip().lower().split("-")[0].split("_")[0]
+        if key in SUPPORTED_LANGUAGES:
+            return key
+    han = len(re.findall(r"[\u4e00-\u9fff]", text))
+    words = re.findall(r"[A-Za-z]+", text)
+    if words and len(words) > han and (len(words) >= 2 or sum(len(w) for w in words) >= 4):
+        return "en"
+    return "zh"
+
+
+def _normalize_text_for_rules(text: str, language: str = "zh") -> str:
     value = text.strip().lower()
+    if language == "en":
+        # Keep single spaces so multi-word keywords still match.
+        return re.sub(r"\s+", " ", value)
     value = re.sub(r"\s+", "", value)
     return value
 
 
-def _keyword_scores(text: str) -> dict[str, float]:
-    scores: dict[str, float] = {}
-    for label, words in EMOTION_KEYWORDS.items():
-        if not words:
-            continue
-        score = 0.0
-        for word in words:
-            if word in text:
-                # Longer phrases are usually stronger emotional evidence.
-                score += min(1.0, 0.26 + 0.06 * len(word))
-        if score > 0:
-            scores[label] = clamp(score, 0.0, 1.0)
-    return scores
+def _lexicon(language: str) -> Lexicon:
+    return LEXICONS.get(language) or LEXICONS["zh"]
+
+
+def _keyword_scores(text: str, language: str = "zh") -> dict[str, float]:
+    return _lexicon(language).scores(text)
 
 
-def _looks_like_task_command(text: str) -> bool:
-    return any(hint in text for hint in TASK_HINTS)
+def _looks_like_task_command(text: str, language: str = "zh") -> bool:
+    return _lexicon(language).has_task_hint(text)
+
+
+def _context_text(text: str, history: list[HistoryTurn] | None, language: str) -> str | None:
+    """Returns text as the reply in a transcript of the last history turns
+    when it is too short to read alone, else None."""
+    if not history or len(_normalize_text_for_rules(text, language).replace(" ", "")) > CONTEXT_MAX_CHARS:
+        return None
+    speakers = {"assistant": "Robot", "user": "User"} if language == "en" else {"assistant": "机器人", "user": "用户"}
+    sep = ": " if language == "en" else "："
+    lines = [
+        speakers[turn.role] + sep + turn.content.strip()
+        for turn in history[-CONTEXT_TURNS:]
+        if turn.role in speakers and turn.content.strip()
+    ]
+    if not lines:
+        return None
+    lines.append(speakers["user"] + sep + text.strip())
+    return "\n".join(lines)
 
 
 def _pad_similarity(label: str, p: float, a: float, d: float) -> float:
@@ -191,9 +235,13 @@ def _pad_similarity(label: str, p: float, a: float, d: float) -> float:
     return clamp(1.0 - dist/2.9, 0.0, 1.0)
 
 
-def _refine_emotion_with_rules(text: str, p: float, a: float, d: float, intensity: float, base_emotion: str) -> tuple[str, float, float, float, float]:
-    normalized = _normalize_text_for_rules(text)
-    keyword_scores = _keyword_scores(normalized)
+def _refine_emotion_with_rules(
+    text: str, p: float, a: float, d: float, intensity: float, base_emotion: str, language: str = "zh"
+) -> tuple[str, float, float, float, float, dict[str, float]]:
+    """Returns the refined emotion, PAD and intensity, and the combined
+    score of every label it was chosen from."""
+    normalized = _normalize_text_for_rules(text, language)
+    keyword_scores = _keyword_scores(normalized, language)
     kw_label = "neutral"
     kw_score = 0.0
     if keyword_scores:
@@ -201,11 +249,12 @@ def _refine_emotion_with_rules(text: str, p: float, a: float, d: float, intensit
 
     # Preserve neutral on low-energy task commands.
     low_energy = intensity < 0.20 and abs(p) < 0.25 and abs(a) < 0.25 and abs(d) < 0.25
-    if low_energy and kw_score < 0.30 and _looks_like_task_command(normalized):
-        return "neutral", p, a, d, min(intensity, 0.18)
+    if low_energy and kw_score < 0.30 and _looks_like_task_command(normalized, language):
+        return "neutral", p, a, d, min(intensity, 0.18), {"neutral": 1.0}
 
     best_label = base_emotion
     best_score = -1.0
+    label_scores: dict[str, float] = {}
     for label in PAD_MAP:
         score = 0.70 * _pad_similarity(label, p, a, d)
         score += 1.05 * keyword_scores.get(label, 0.0)
@@ -213,6 +262,7 @@ def _refine_emotion_with_rules(text: str, p: float, a: float, d: float, intensit
             score += 0.04
         if label == "neutral" and kw_score >= 0.35:
             score -= 0.20
+        label_scores[label] = score
         if score > best_score:
             best_score = score
             best_label = label
@@ -231,7 +281,22 @@ def _refine_emotion_with_rules(text: str, p: float, a: float, d: float, intensit
     elif best_label != "neutral" and kw_score >= 0.30:
         intensity = max(intensity, clamp(0.18 + 0.30 * kw_score, 0.0, 1.0))
 
-    return best_label, p, a, d, clamp(intensity, 0.0, 1.0)
+    return best_label, p, a, d, clamp(intensity, 0.0, 1.0), label_scores
+
+
+def _rank_candidates(label_scores: dict[str, float], emotion: str, top_k: int) -> tuple[list[dict[str, Any]], float]:
+    """Softmaxes label scores into probabilities and returns the top_k, best
+    first, with the margin of emotion over the most likely other label. The
+    margin goes negative when the rules picked emotion over a higher-scoring
+    label."""
+    top = max(label_scores.values())
+    weights = {label: exp((score - top) / SCORE_TEMPERATURE) for label, score in label_scores.items()}
+    total = sum(weights.values())
+    probs = {label: w / total for label, w in weights.items()}
+    ranked = sorted(probs.items(), key=lambda item: item[1], reverse=True)
+    runner_up = max((prob for label, prob in ranked if label != emotion), default=0.0)
+    margin = probs.get(emotion, 0.0) - runner_up
+    return [{"emotion": label, "score": round(prob, 4)} for label, prob in ranked[:top_k]], margin
 
 
 def _ensure_onnx_export() -> Path:
@@ -319,13 +384,15 @@ def get_classifier():
     return _build_onnx_pipeline()
 
 
-def infer_pad(text: str) -> tuple[float, float, float, float]:
+def infer_pad(text: str, language: str = "zh") -> tuple[float, float, float, float]:
     classifier = get_classifier()
+    english = language == "en"
+    axis_anchors = AXIS_ANCHORS_EN if english else AXIS_ANCHORS
     result = classifier(
         text,
-        candidate_labels=ALL_ANCHOR_LABELS,
+        candidate_labels=ALL_ANCHOR_LABELS_EN if english else ALL_ANCHOR_LABELS,
         multi_label=True,
-        hypothesis_template=HYPOTHESIS_TEMPLATE,
+        hypothesis_template=HYPOTHESIS_TEMPLATE_EN if english else HYPOTHESIS_TEMPLATE,
     )
 
     labels = [str(x) for x in result.get("labels", [])]
@@ -334,7 +401,7 @@ def infer_pad(text: str) -> tuple[float, float, float, float]:
 
     axis_scores: dict[str, float] = {}
     axis_certainty: dict[str, float] = {}
-    for axis, anchors in AXIS_ANCHORS.items():
+    for axis, anchors in axis_anchors.items():
         pos_values = [score_map.get(v, 0.0) for v in anchors["pos"]]
         neg_values = [score_map.get(v, 0.0) for v in anchors["neg"]]
         pos_mean = sum(pos_values) / max(len(pos_values), 1)
@@ -414,6 +481,8 @@ def healthz() -> dict[str, Any]:
         "model": MODEL_ID,
         "analyze_mode": "pad_direct_nli",
         "nli_hypothesis_template": HYPOTHESIS_TEMPLATE,
+        "nli_hypothesis_template_en": HYPOTHESIS_TEMPLATE_EN,
+        "languages": sorted(SUPPORTED_LANGUAGES),
         "runtime_backend": RUNTIME_STATE["backend"],
         "runtime_int8": RUNTIME_STATE["int8"],
         "runtime_model_dir": RUNTIME_STATE["model_dir"],
@@ -429,6 +498,38 @@ def pad_table() -> dict[str, Any]:
     return {"pad_table": PAD_MAP}
 
 
+@app.get("/v1/emotion/lexicon/{language}")
+def get_lexicon(language: str) -> dict[str, Any]:
+    lexicon = LEXICONS.get(language)
+    if lexicon is None:
+        raise HTTPException(status_code=404, detail=f"unsupported language: {language}")
+    return {"language": language, "keywords": lexicon.keywords, "task_hints": lexicon.task_hints}
+
+
+@app.put("/v1/emotion/lexicon/{language}")
+def put_lexicon(language: str, req: LexiconRequest) -> dict[str, Any]:
+    current = LEXICONS.get(language)
+    if current is None:
+        raise HTTPException(status_code=404, detail=f"unsupported language: {language}")
+    unknown = sorted(label for label in req.keywords if label not in PAD_MAP)
+    if unknown:
+        raise HTTPException(status_code=400, detail=f"unknown emotion labels: {', '.join(unknown)}")
+    keywords = {label: [w.strip().lower() for w in words if w.strip()] for label, words in req.keywords.items()}
+    task_hints = current.task_hints if req.task_hints is None else [h.lower() for h in req.task_hints if h.strip()]
+    start = time.perf_counter()
+    # Analyze calls in flight keep the lexicon they started with; the swap is
+    # a single assignment.
+    LEXICONS[language] = build_lexicon(language, keywords, task_hints)
+    build_ms = round((time.perf_counter() - start) * 1000.0, 3)
+    logger.info("Rebuilt %s emotion lexicon in %sms", language, build_ms)
+    return {
+        "language": language,
+        "keywords": sum(len(words) for words in keywords.values()),
+        "task_hints": len(task_hints),
+        "build_ms": build_ms,
+    }
+
+
 @app.post("/v1/emotion/convert")
 def convert(req: ConvertRequest) -> dict[str, Any]:
     start = time.perf_counter()
@@ -441,15 +542,32 @@ def convert(req: ConvertRequest) -> dict[str, Any]:
 def analyze(req: AnalyzeRequest) -> dict[str, Any]:
     try:
         start = time.perf_counter()
-        p, a, d, intensity = infer_pad(req.text)
+        detect_text = req.text
+        if req.history and len(req.text.strip()) <= CONTEXT_MAX_CHARS:
+            # "嗯" alone says little about the language either.
+            detect_text = " ".join([turn.content for turn in req.history[-CONTEXT_TURNS:]] + [req.text])
+        language = resolve_language(detect_text, req.language)
+        p, a, d, intensity = infer_pad(req.text, language)
+        context = _context_text(req.text, req.history, language)
+        if context is not None:
+            cp, ca, cd, ci = infer_pad(context, language)
+            w = CONTEXT_WEIGHT
+            p, a, d = (1 - w) * p + w * cp, (1 - w) * a + w * ca, (1 - w) * d + w * cd
+            intensity = (1 - w) * intensity + w * ci
         emotion = infer_emotion_from_pad(p, a, d)
-        emotion, p, a, d, intensity = _refine_emotion_with_rules(req.text, p, a, d, intensity, emotion)
+        emotion, p, a, d, intensity, label_scores = _refine_emotion_with_rules(req.text, p, a, d, intensity, emotion, language)
+        candidates, margin = _rank_candidates(label_scores, emotion, req.top_k)
         out = {
             "emotion": emotion,
+            "language": language,
             "p": round(p, 3),
             "a": round(a, 3),
             "d": round(d, 3),
             "intensity": round(intensity, 6),
+            "candidates": candidates,
+            "margin": round(margin, 4),
+            "ambiguous": margin < AMBIGUITY_MARGIN,
+            "context_used": context is not None,
         }
         out["latency_ms"] = round((time.perf_counter() - start) * 1000.0, 3)
         return out
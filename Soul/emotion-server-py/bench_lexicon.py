@@ -0,0 +1,108 @@
+"""Benchmarks keyword scoring: per-keyword substring scans against the
+compiled automaton in lexicon.py, on the built-in lexicon and on one grown
+with synthetic keywords. Needs only the standard library:
+
+    python bench_lexicon.py [--grow 50] [--rounds 2000]
+
+It also checks that both paths score every sample the same, with the
+negation and intensifier modifiers left out since the scan has none, and
+times the automaton with them.
+"""
+
+import argparse
+import re
+import time
+
+from lexicon import (
+    EMOTION_KEYWORDS,
+    EMOTION_KEYWORDS_EN,
+    TASK_HINTS,
+    TASK_HINTS_EN,
+    build_lexicon,
+    en_weight,
+    zh_weight,
+)
+
+SAMPLES = {
+    "zh": [
+        "今天考试通过了，太棒了，我好开心哈哈",
+        "帮我把灯变红，然后设置一个明天早上七点的闹钟",
+        "最近压力很大，晚上总是睡不着，有点焦虑",
+        "他居然没来，我真的很失望也有点生气",
+        "随便聊聊吧，今天天气一般，没什么特别的事情发生",
+    ],
+    "en": [
+        "i passed the exam, i'm so happy, this is awesome haha",
+        "turn on the light and set an alarm for seven tomorrow",
+        "i've been really stressed and can't sleep, kind of anxious",
+        "he didn't show up, i'm disappointed and a bit annoyed",
+        "nothing special today, the weather is okay i guess",
+    ],
+}
+
+
+def scan_scores(text: str, keywords: dict[str, list[str]], language: str) -> dict[str, float]:
+    """The substring scan the automaton replaced."""
+    scores: dict[str, float] = {}
+    for label, words in keywords.items():
+        score = 0.0
+        for word in dict.fromkeys(words):
+            if language == "en":
+                if re.search(r"\b" + re.escape(word) + r"\b", text):
+                    score += en_weight(word)
+            elif word in text:
+                score += zh_weight(word)
+        if score > 0:
+            scores[label] = max(0.0, min(1.0, score))
+    return scores
+
+
+def grown(keywords: dict[str, list[str]], factor: int, language: str) -> dict[str, list[str]]:
+    if factor <= 1:
+        return keywords
+    out = {}
+    for label, words in keywords.items():
+        extra = [f"{w} {i}" if language == "en" else f"{w}{i}" for w in words for i in range(factor - 1)]
+        out[label] = words + extra
+    return out
+
+
+def bench(name: str, fn, texts: list[str], rounds: int) -> float:
+    start = time.perf_counter()
+    for _ in range(rounds):
+        for text in texts:
+            fn(text)
+    per_call = (time.perf_counter() - start) / (rounds * len(texts)) * 1e6
+    print(f"  {name:<10} {per_call:9.2f} µs/text")
+    return per_call
+
+
+def main() -> None:
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--grow", type=int, default=50, help="multiply the lexicon by this many synthetic variants")
+    parser.add_argument("--rounds", type=int, default=200)
+    args = parser.parse_args()
+
+    tables = {"zh": (EMOTION_KEYWORDS, TASK_HINTS), "en": (EMOTION_KEYWORDS_EN, TASK_HINTS_EN)}
+    for factor in (1, args.grow):
+        for language, (keywords, hints) in tables.items():
+            words = grown(keywords, factor, language)
+            start = time.perf_counter()
+            lexicon = build_lexicon(language, words, hints, modifiers={})
+            build_ms = (time.perf_counter() - start) * 1000.0
+            total = sum(len(w) for w in words.values())
+            print(f"{language}: {total} keywords, automaton built in {build_ms:.2f}ms")
+
+            texts = SAMPLES[language]
+            for text in texts:
+                want, got = scan_scores(text, words, language), lexicon.scores(text)
+                if want.keys() != got.keys() or any(abs(want[k] - got[k]) > 1e-9 for k in want):
+                    raise SystemExit(f"score mismatch on {text!r}: scan={want} automaton={got}")
+            scan = bench("scan", lambda t: scan_scores(t, words, language), texts, args.rounds)
+            automaton = bench("automaton", lexicon.scores, texts, args.rounds)
+            print(f"  speedup    {scan / automaton:9.1f}x")
+            bench("modifiers", build_lexicon(language, words, hints).scores, texts, args.rounds)
+
+
+if __name__ == "__main__":
+    main()
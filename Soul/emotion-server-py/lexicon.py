@@ -0,0 +1,330 @@
+"""Keyword lexicon for the text-rule refinement of /v1/emotion/analyze.
+
+Each language's keywords and task hints are compiled once into an
+Aho-Corasick automaton, so a text is scanned in a single pass however many
+keywords there are, instead of one substring search per keyword. Replacing
+a lexicon builds a new automaton and swaps it in whole.
+
+A keyword's weight is adjusted by the modifiers just before it in the same
+clause: negators ("一点也不开心", "not happy") take the evidence from its
+label and give part of it to the opposite one, intensifiers ("非常") raise
+it and diminishers ("有点") lower it. An intensifier after a negator makes
+the negation partial ("不太开心", "not very happy").
+"""
+
+import re
+from collections import deque
+from collections.abc import Callable, Iterable, Iterator
+from dataclasses import dataclass
+from typing import Generic, TypeVar
+
+T = TypeVar("T")
+
+# Text-rule fallback for low-amplitude PAD outputs.
+# Purpose: keep task commands neutral while lifting clear affective utterances.
+EMOTION_KEYWORDS: dict[str, list[str]] = {
+    "anger": ["生气", "愤怒", "火大", "气死", "恼火", "怒了", "发火"],
+    "anxiety": ["焦虑", "紧张", "不安", "慌", "睡不着", "忐忑", "压力很大", "担心"],
+    "boredom": ["无聊", "没意思", "很闲", "发呆", "无趣"],
+    "calm": ["平静", "冷静", "淡定", "放松", "安稳"],
+    "disappointment": ["失望", "落空", "白期待", "不如预期"],
+    "disgust": ["恶心", "反胃", "厌恶", "嫌弃"],
+    "excitement": ["兴奋", "激动", "太爽", "燃起来", "冲啊"],
+    "fear": ["害怕", "恐惧", "吓到", "可怕", "发怵"],
+    "frustration": ["挫败", "受挫", "崩溃", "卡住了", "做不出来", "烦死了"],
+    "gratitude": ["感谢", "谢谢", "多谢", "感激"],
+    "joy": ["开心", "高兴", "快乐", "哈哈", "太棒了", "不错", "喜悦"],
+    "neutral": [],
+    "relief": ["松了一口气", "还好", "终于结束", "放心了", "释然"],
+    "sadness": ["难过", "伤心", "失恋", "想哭", "哭了", "低落", "不开心"],
+    "surprise": ["惊讶", "震惊", "没想到", "居然", "竟然", "哇"],
+}
+
+EMOTION_KEYWORDS_EN: dict[str, list[str]] = {
+    "anger": ["angry", "furious", "pissed off", "mad at", "annoyed"],
+    "anxiety": ["anxious", "nervous", "worried", "stressed", "can't sleep", "uneasy"],
+    "boredom": ["bored", "boring", "nothing to do"],
+    "calm": ["calm", "relaxed", "peaceful", "chill"],
+    "disappointment": ["disappointed", "let down", "not as good as"],
+    "disgust": ["disgusting", "gross", "sick of"],
+    "excitement": ["excited", "thrilled", "can't wait", "pumped"],
+    "fear": ["scared", "afraid", "terrified", "frightened"],
+    "frustration": ["frustrated", "stuck", "give up", "fed up"],
+    "gratitude": ["thank you", "thanks", "grateful", "appreciate"],
+    "joy": ["happy", "glad", "great", "awesome", "haha", "love it"],
+    "neutral": [],
+    "relief": ["relieved", "phew", "finally over", "thank god"],
+    "sadness": ["sad", "upset", "heartbroken", "depressed", "want to cry", "crying"],
+    "surprise": ["surprised", "shocked", "no way", "wow", "unexpected"],
+}
+
+TASK_HINTS = [
+    "开灯",
+    "关灯",
+    "亮灯",
+    "变红",
+    "变绿",
+    "提醒",
+    "闹钟",
+    "点头",
+    "摇头",
+    "发邮件",
+    "发一封邮件",
+    "设置",
+]
+
+TASK_HINTS_EN = [
+    "turn on",
+    "turn off",
+    "light",
+    "remind",
+    "alarm",
+    "nod",
+    "shake your head",
+    "send an email",
+    "set ",
+]
+
+
+@dataclass(frozen=True)
+class Modifier:
+    """How a word before a keyword changes it; the default changes nothing
+    and only keeps phrases like "不管" from reading as "不"."""
+
+    negate: bool = False
+    scale: float = 1.0
+
+
+INTENSIFY = 1.3
+DIMINISH = 0.6
+# Share of a negated keyword's weight that goes to the opposite label.
+NEGATED_SHARE = 0.5
+
+_NEGATE = Modifier(negate=True)
+_PARTIAL = Modifier(negate=True, scale=DIMINISH)
+_UP = Modifier(scale=INTENSIFY)
+_DOWN = Modifier(scale=DIMINISH)
+_INERT = Modifier()
+
+MODIFIERS: dict[str, Modifier] = {
+    **dict.fromkeys(["不", "没", "没有", "别", "并不", "并没有", "毫不", "从不", "从没", "绝不", "不再", "不是"], _NEGATE),
+    **dict.fromkeys(["不怎么", "不大", "不够"], _PARTIAL),
+    **dict.fromkeys(
+        ["非常", "很", "太", "特别", "超", "超级", "十分", "极其", "好", "真", "真的", "挺", "最",
+         "一点也", "一点都", "一点儿也", "根本", "完全", "实在"],
+        _UP,
+    ),
+    **dict.fromkeys(["有点", "有点儿", "有些", "稍微", "稍稍", "略", "略微", "一点", "一点点", "些许"], _DOWN),
+    **dict.fromkeys(["不管", "不过", "不仅", "不但", "不论", "不知道", "不得不", "没想到", "好像", "好多"], _INERT),
+}
+
+MODIFIERS_EN: dict[str, Modifier] = {
+    **dict.fromkeys(
+        ["not", "never", "no", "don't", "doesn't", "didn't", "isn't", "wasn't", "aren't", "weren't",
+         "won't", "no longer"],
+        _NEGATE,
+    ),
+    **dict.fromkeys(["hardly", "barely", "not that"], _PARTIAL),
+    **dict.fromkeys(
+        ["very", "so", "really", "extremely", "super", "totally", "too", "incredibly", "absolutely"], _UP
+    ),
+    **dict.fromkeys(
+        ["a bit", "a little", "a little bit", "slightly", "kind of", "kinda", "somewhat", "sort of"], _DOWN
+    ),
+    **dict.fromkeys(["no matter", "not only"], _INERT),
+}
+
+# Where a negated keyword's evidence goes; labels not listed just lose it.
+NEGATION_FLIPS: dict[str, str] = {
+    "joy": "sadness",
+    "calm": "anxiety",
+    "relief": "anxiety",
+    "anger": "calm",
+    "anxiety": "calm",
+    "fear": "calm",
+}
+
+# Modifiers reach back this far from a keyword, within its clause.
+LOOKBACK_CHARS = 5
+LOOKBACK_WORDS = 3
+
+_CLAUSE_BREAK = re.compile(r"[，。！？；：、,.!?;:…\n]")
+
+
+class KeywordAutomaton(Generic[T]):
+    """Aho-Corasick automaton over (keyword, payload) pairs."""
+
+    def __init__(self, keywords: Iterable[tuple[str, T]]) -> None:
+        self._goto: list[dict[str, int]] = [{}]
+        self._fail: list[int] = [0]
+        # Outputs of a node include those of its fail chain, merged at build
+        # time so a scan never walks the chain.
+        self._out: list[list[tuple[int, T]]] = [[]]
+        for word, payload in keywords:
+            if not word:
+                continue
+            node = 0
+            for ch in word:
+                nxt = self._goto[node].get(ch)
+                if nxt is None:
+                    nxt = len(self._goto)
+                    self._goto[node][ch] = nxt
+                    self._goto.append({})
+                    self._fail.append(0)
+                    self._out.append([])
+                node = nxt
+            self._out[node].append((len(word), payload))
+        self._link()
+
+    def _link(self) -> None:
+        queue = deque(self._goto[0].values())
+        while queue:
+            node = queue.popleft()
+            for ch, child in self._goto[node].items():
+                queue.append(child)
+                fail = self._fail[node]
+                while fail and ch not in self._goto[fail]:
+                    fail = self._fail[fail]
+                target = self._goto[fail].get(ch, 0)
+                self._fail[child] = target if target != child else 0
+                self._out[child] = self._out[child] + self._out[self._fail[child]]
+
+    def find(self, text: str) -> Iterator[tuple[int, int, T]]:
+        """Yields (start, end, payload) for every occurrence, overlaps included."""
+        node = 0
+        for i, ch in enumerate(text):
+            while node and ch not in self._goto[node]:
+                node = self._fail[node]
+            node = self._goto[node].get(ch, 0)
+            for length, payload in self._out[node]:
+                yield i + 1 - length, i + 1, payload
+
+
+def _is_word_char(ch: str) -> bool:
+    return ch.isalnum() or ch == "_"
+
+
+class Lexicon:
+    """One language's emotion keywords and task hints, compiled for scanning.
+
+    weight scores a keyword's evidence for its label. With word_boundaries a
+    keyword or modifier only counts between non-word characters, like a \\b
+    regex, and modifiers are looked for LOOKBACK_WORDS words back instead of
+    LOOKBACK_CHARS characters.
+    """
+
+    def __init__(
+        self,
+        keywords: dict[str, list[str]],
+        task_hints: list[str],
+        weight: Callable[[str], float],
+        word_boundaries: bool = False,
+        modifiers: dict[str, Modifier] | None = None,
+    ) -> None:
+        self.keywords = {label: list(words) for label, words in keywords.items()}
+        self.task_hints = list(task_hints)
+        self._word_boundaries = word_boundaries
+        self._modifiers = KeywordAutomaton(modifiers.items()) if modifiers else None
+        entries = [
+            ((label, word), weight(word))
+            for label, words in self.keywords.items()
+            for word in dict.fromkeys(words)
+        ]
+        self._keywords = KeywordAutomaton((entry[1], entry) for entry, _ in entries)
+        self._weights = dict(entries)
+        self._hints = KeywordAutomaton((hint, hint) for hint in self.task_hints)
+
+    def _bounded(self, text: str, start: int, end: int) -> bool:
+        if not self._word_boundaries:
+            return True
+        return (start == 0 or not _is_word_char(text[start - 1])) and (
+            end == len(text) or not _is_word_char(text[end])
+        )
+
+    def _window(self, text: str, start: int) -> str:
+        clause = _CLAUSE_BREAK.split(text[max(0, start - 64) : start])[-1]
+        if self._word_boundaries:
+            return " ".join(clause.split()[-LOOKBACK_WORDS:])
+        return clause[-LOOKBACK_CHARS:]
+
+    def _modify(self, text: str, start: int) -> tuple[bool, float]:
+        """Returns whether the keyword at start is negated and how much its
+        weight is scaled, reading the modifiers before it left to right and
+        taking the longest where they overlap."""
+        if self._modifiers is None:
+            return False, 1.0
+        window = self._window(text, start)
+        found = sorted(
+            ((s, e, m) for s, e, m in self._modifiers.find(window) if self._bounded(window, s, e)),
+            key=lambda item: (item[0], item[0] - item[1]),
+        )
+        negated, scale, pos = False, 1.0, 0
+        for s, e, m in found:
+            if s < pos:
+                continue
+            pos = e
+            if m.negate:
+                negated = not negated
+            if negated and not m.negate and m.scale > 1:
+                scale *= DIMINISH
+            else:
+                scale *= m.scale
+        return negated, scale
+
+    def scores(self, text: str) -> dict[str, float]:
+        """Sums, per label, the modified weights of the keywords found in
+        text; a keyword counts once per label, at its strongest occurrence."""
+        best: dict[tuple[tuple[str, str], str], float] = {}
+        for start, end, entry in self._keywords.find(text):
+            if not self._bounded(text, start, end):
+                continue
+            label = entry[0]
+            negated, scale = self._modify(text, start)
+            weight = self._weights[entry] * scale
+            if negated:
+                label = NEGATION_FLIPS.get(label)
+                if label is None:
+                    continue
+                weight *= NEGATED_SHARE
+            key = (entry, label)
+            best[key] = max(best.get(key, 0.0), weight)
+        scores: dict[str, float] = {}
+        for (_, label), weight in best.items():
+            scores[label] = scores.get(label, 0.0) + weight
+        return {label: max(0.0, min(1.0, score)) for label, score in scores.items()}
+
+    def has_task_hint(self, text: str) -> bool:
+        return next(self._hints.find(text), None) is not None
+
+
+def zh_weight(word: str) -> float:
+    # Longer phrases are usually stronger emotional evidence.
+    return min(1.0, 0.26 + 0.06 * len(word))
+
+
+def en_weight(word: str) -> float:
+    return min(1.0, 0.26 + 0.18 * len(word.split()))
+
+
+def build_lexicon(
+    language: str,
+    keywords: dict[str, list[str]] | None = None,
+    task_hints: list[str] | None = None,
+    modifiers: dict[str, Modifier] | None = None,
+) -> Lexicon:
+    """Builds a language's lexicon, defaulting to the built-in tables; pass
+    modifiers={} to score keywords without them."""
+    if language == "en":
+        return Lexicon(
+            EMOTION_KEYWORDS_EN if keywords is None else keywords,
+            TASK_HINTS_EN if task_hints is None else task_hints,
+            en_weight,
+            word_boundaries=True,
+            modifiers=MODIFIERS_EN if modifiers is None else modifiers,
+        )
+    return Lexicon(
+        EMOTION_KEYWORDS if keywords is None else keywords,
+        TASK_HINTS if task_hints is None else task_hints,
+        zh_weight,
+        modifiers=MODIFIERS if modifiers is None else modifiers,
+    )
@@ -0,0 +1,94 @@
+import unittest
+
+from lexicon import KeywordAutomaton, build_lexicon
+
+
+def top(scores: dict[str, float]) -> str:
+    return max(scores.items(), key=lambda item: item[1])[0] if scores else "neutral"
+
+
+class KeywordAutomatonTestCase(unittest.TestCase):
+    def test_finds_overlapping_keywords(self) -> None:
+        automaton = KeywordAutomaton([("he", "he"), ("she", "she"), ("his", "his"), ("hers", "hers")])
+        found = sorted((start, end, word) for start, end, word in automaton.find("ushers"))
+        self.assertEqual(found, [(1, 4, "she"), (2, 4, "he"), (2, 6, "hers")])
+
+
+class ChineseModifierTestCase(unittest.TestCase):
+    def setUp(self) -> None:
+        self.lexicon = build_lexicon("zh")
+
+    def test_emphatic_negation_is_not_joy(self) -> None:
+        scores = self.lexicon.scores("一点也不开心")
+        self.assertNotIn("joy", scores)
+        self.assertEqual(top(scores), "sadness")
+        self.assertGreater(scores["sadness"], self.lexicon.scores("不开心")["sadness"])
+
+    def test_negation_gives_part_to_opposite(self) -> None:
+        for text in ("不高兴", "我并不觉得开心", "没有很快乐"):
+            with self.subTest(text=text):
+                scores = self.lexicon.scores(text)
+                self.assertNotIn("joy", scores)
+                self.assertEqual(top(scores), "sadness")
+
+    def test_negated_label_without_opposite_is_dropped(self) -> None:
+        self.assertEqual(self.lexicon.scores("我不难过"), {})
+
+    def test_double_negation(self) -> None:
+        self.assertEqual(top(self.lexicon.scores("不是不开心")), "joy")
+
+    def test_intensifier_and_diminisher(self) -> None:
+        plain = self.lexicon.scores("难过")["sadness"]
+        self.assertGreater(self.lexicon.scores("非常难过")["sadness"], plain)
+        self.assertGreater(self.lexicon.scores("太难过了")["sadness"], plain)
+        self.assertLess(self.lexicon.scores("有点难过")["sadness"], plain)
+        self.assertLess(self.lexicon.scores("稍微有些难过")["sadness"], self.lexicon.scores("有点难过")["sadness"])
+
+    def test_partial_negation(self) -> None:
+        for text in ("不太开心", "不是很开心", "不怎么开心"):
+            with self.subTest(text=text):
+                scores = self.lexicon.scores(text)
+                self.assertNotIn("joy", scores)
+                self.assertLess(scores["sadness"], self.lexicon.scores("不高兴")["sadness"])
+
+    def test_negation_stays_in_its_clause(self) -> None:
+        self.assertEqual(top(self.lexicon.scores("不，我很开心")), "joy")
+        self.assertEqual(top(self.lexicon.scores("他没来。开心")), "joy")
+
+    def test_lookalike_phrases_do_not_negate(self) -> None:
+        for text in ("不管怎样我都很开心", "没想到这么开心", "不过还是开心"):
+            with self.subTest(text=text):
+                self.assertIn("joy", self.lexicon.scores(text))
+
+    def test_task_command_unaffected(self) -> None:
+        self.assertTrue(self.lexicon.has_task_hint("不要关灯"))
+        self.assertEqual(self.lexicon.scores("帮我把灯变红"), {})
+
+
+class EnglishModifierTestCase(unittest.TestCase):
+    def setUp(self) -> None:
+        self.lexicon = build_lexicon("en")
+
+    def test_negation(self) -> None:
+        for text in ("i am not happy", "i'm never happy", "i don't feel happy"):
+            with self.subTest(text=text):
+                scores = self.lexicon.scores(text)
+                self.assertNotIn("joy", scores)
+                self.assertEqual(top(scores), "sadness")
+
+    def test_modifiers_need_word_boundaries(self) -> None:
+        # "no" inside "nothing" and "not" inside "knot" negate nothing.
+        self.assertIn("joy", self.lexicon.scores("nothing but happy"))
+        self.assertIn("joy", self.lexicon.scores("knot happy"))
+
+    def test_intensifier_and_partial_negation(self) -> None:
+        plain = self.lexicon.scores("happy")["joy"]
+        self.assertGreater(self.lexicon.scores("really happy")["joy"], plain)
+        self.assertLess(self.lexicon.scores("a bit happy")["joy"], plain)
+        scores = self.lexicon.scores("not very happy")
+        self.assertNotIn("joy", scores)
+        self.assertLess(scores["sadness"], self.lexicon.scores("not happy")["sadness"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -372,6 +372,7 @@ class IntentFilterRequest(BaseModel):
     command: str = Field(..., min_length=1, description="本轮命令文本")
     intent_catalog: list[IntentSpec] = Field(min_length=1)
     options: FilterOptions = Field(default_factory=FilterOptions)
+    locale: str | None = Field(default=None, description="调用方确定的语言区域，缺省时按命令文本检测")
 
 
 class Evidence(BaseModel):
@@ -558,9 +559,19 @@ def _extract_entities_from_text(text: str, locale: str) -> list[Entity]:
     return entities
 
 
-def _build_command_context(command: str) -> tuple[str, str, str, datetime, list[Entity]]:
+def _resolve_locale(command: str, hint: str | None) -> str:
     detected_locale = _detect_locale(command)
-    locale = detected_locale if detected_locale in SUPPORTED_LOCALES else DEFAULT_LOCALE
+    if hint in SUPPORTED_LOCALES:
+        # Keep the detected script variant (zh-TW) when the caller only knows
+        # the language is Chinese.
+        if hint.startswith("zh") and detected_locale.startswith("zh"):
+            return detected_locale
+        return hint
+    return detected_locale if detected_locale in SUPPORTED_LOCALES else DEFAULT_LOCALE
+
+
+def _build_command_context(command: str, locale_hint: str | None = None) -> tuple[str, str, str, datetime, list[Entity]]:
+    locale = _resolve_locale(command, locale_hint)
     command_text = _normalize_command_text(command, locale)
     timezone = DEFAULT_TIMEZONE
     now = _resolve_now(None, timezone)
@@ -1182,7 +1193,7 @@ def filter_intents(payload: IntentFilterRequest) -> IntentFilterResponse:
     start = time.perf_counter()
 
     request_id = payload.request_id or f"ifr-{uuid.uuid4().hex}"
-    command_text, timezone, locale, now, entities = _build_command_context(payload.command)
+    command_text, timezone, locale, now, entities = _build_command_context(payload.command, payload.locale)
 
     time_signals: list[dict[str, Any]] = []
     if payload.options.enable_time_parser:
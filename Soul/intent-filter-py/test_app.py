@@ -86,6 +86,20 @@ class IntentFilterTestCase(unittest.TestCase):
         self.assertEqual(body["meta"]["locale"], "en-US")
         self.assertEqual(body["decision"]["action"], "execute_intents")
 
+    def test_locale_hint(self) -> None:
+        payload = {
+            "command": "ok, lights off",
+            "intent_catalog": self._catalog(),
+            "locale": "en-US",
+        }
+        response = self.client.post("/v1/intents/filter", json=payload)
+        self.assertEqual(response.status_code, 200)
+        self.assertEqual(response.json()["meta"]["locale"], "en-US")
+
+        payload = {"command": "請幫我關閉臥室的燈", "intent_catalog": self._catalog(), "locale": "zh-CN"}
+        response = self.client.post("/v1/intents/filter", json=payload)
+        self.assertEqual(response.json()["meta"]["locale"], "zh-TW")
+
     def test_locale_ko(self) -> None:
         payload = {
             "command": "침실 불 꺼줘 그리고 10분 뒤에 알려줘",
@@ -0,0 +1,354 @@
+package asr
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFusionTimeout    = 3 * time.Second
+	defaultFusionConfidence = 0.5
+)
+
+// FusionEngine runs several engines on the same audio and emits, for each
+// utterance, the final with the highest confidence. The first engine is the
+// primary and only its partials are forwarded. Whichever engine finals first
+// ends the utterance and flushes the others, so flush-driven engines such as
+// WhisperHTTPEngine follow the primary's VAD segmentation.
+type FusionEngine struct {
+	Engines []Engine
+	// Timeout bounds how long an utterance waits for slower engines.
+	Timeout time.Duration
+	// DefaultConfidence stands in for engines that report none.
+	DefaultConfidence float64
+}
+
+func (e *FusionEngine) Name() string {
+	return "fusion"
+}
+
+func (e *FusionEngine) NewStream(sessionID string, onResult func(Result)) (Stream, error) {
+	if len(e.Engines) == 0 {
+		return nil, fmt.Errorf("fusion engine has no engines")
+	}
+	s := &fusionStream{
+		onResult:          onResult,
+		timeout:           e.Timeout,
+		defaultConfidence: e.DefaultConfidence,
+		rounds:            make(map[int]*fusionRound),
+	}
+	if s.timeout <= 0 {
+		s.timeout = defaultFusionTimeout
+	}
+	if s.defaultConfidence <= 0 {
+		s.defaultConfidence = defaultFusionConfidence
+	}
+
+	for i, engine := range e.Engines {
+		m := &fusionMember{name: engine.Name(), primary: i == 0, flushes: make(chan struct{}, 8)}
+		stream, err := engine.NewStream(sessionID, func(res Result) { s.handle(m, res) })
+		if err != nil {
+			if m.primary {
+				_ = s.Close()
+				return nil, fmt.Errorf("start %s failed: %w", m.name, err)
+			}
+			s.emit(Result{
+				Text:   fmt.Sprintf("%s 不可用，继续使用其余引擎: %v", m.name, err),
+				Source: m.name,
+				Error:  err.Error(),
+			})
+			continue
+		}
+		s.mu.Lock()
+		m.stream = stream
+		s.members = append(s.members, m)
+		s.mu.Unlock()
+		if !m.primary {
+			go s.flushLoop(m)
+		}
+	}
+	return s, nil
+}
+
+type fusionMember struct {
+	name    string
+	primary bool
+	stream  Stream
+	// finals counts this engine's finals; the n-th final belongs to round n.
+	finals int
+	// flushes feeds flushLoop so a slow engine answers utterances in order.
+	flushes chan struct{}
+}
+
+// fusionRound collects the engines' finals for one utterance.
+type fusionRound struct {
+	started time.Time
+	results map[*fusionMember]Candidate
+	flushed map[*fusionMember]bool
+	timer   *time.Timer
+}
+
+type fusionStream struct {
+	onResult          func(Result)
+	timeout           time.Duration
+	defaultConfidence float64
+
+	mu           sync.Mutex
+	members      []*fusionMember
+	rounds       map[int]*fusionRound
+	emitted      int
+	segmentStart time.Time
+	closed       bool
+}
+
+func (s *fusionStream) PushAudio(pcm16le []byte) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.segmentStart.IsZero() {
+		s.segmentStart = time.Now()
+	}
+	members := append([]*fusionMember(nil), s.members...)
+	s.mu.Unlock()
+
+	var primaryErr error
+	for _, m := range members {
+		if err := m.stream.PushAudio(pcm16le); err != nil && m.primary {
+			primaryErr = err
+		}
+	}
+	return primaryErr
+}
+
+// Flush ends the current utterance on every engine not already flushed for
+// it. The primary is flushed inline; others may block on a request and are
+// queued to their own worker.
+func (s *fusionStream) Flush() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	var toFlush []*fusionMember
+	for _, m := range s.members {
+		round := s.round(m.finals)
+		if !round.flushed[m] {
+			round.flushed[m] = true
+			toFlush = append(toFlush, m)
+		}
+	}
+	s.mu.Unlock()
+
+	var primaryErr error
+	for _, m := range toFlush {
+		if m.primary {
+			if err := m.stream.Flush(); err != nil {
+				primaryErr = err
+				s.flushFailed(m, err)
+			}
+			continue
+		}
+		s.flush(m)
+	}
+	return primaryErr
+}
+
+func (s *fusionStream) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	for _, round := range s.rounds {
+		round.timer.Stop()
+	}
+	members := s.members
+	for _, m := range members {
+		if !m.primary {
+			close(m.flushes)
+		}
+	}
+	s.mu.Unlock()
+
+	var firstErr error
+	for _, m := range members {
+		if err := m.stream.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (s *fusionStream) handle(m *fusionMember, res Result) {
+	if !res.IsFinal {
+		if m.primary || res.Error != "" {
+			if res.Source == "" {
+				res.Source = m.name
+			}
+			s.emit(res)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	seq := m.finals
+	m.finals++
+	if seq < s.emitted {
+		// Late answer for an utterance already decided.
+		s.mu.Unlock()
+		return
+	}
+	round := s.round(seq)
+	round.results[m] = Candidate{
+		Source:     m.name,
+		Text:       res.Text,
+		Confidence: res.Confidence,
+		LatencyMS:  time.Since(round.started).Milliseconds(),
+		Error:      res.Error,
+	}
+	round.flushed[m] = true
+	var toFlush []*fusionMember
+	for _, other := range s.members {
+		if !round.flushed[other] {
+			round.flushed[other] = true
+			toFlush = append(toFlush, other)
+		}
+	}
+	done := len(round.results) >= len(s.members)
+	s.mu.Unlock()
+
+	for _, other := range toFlush {
+		s.flush(other)
+	}
+	if done {
+		s.finish(seq)
+	}
+}
+
+// round returns round seq, opening it if needed. Callers hold s.mu.
+func (s *fusionStream) round(seq int) *fusionRound {
+	if round, ok := s.rounds[seq]; ok {
+		return round
+	}
+	started := s.segmentStart
+	if started.IsZero() {
+		started = time.Now()
+	}
+	s.segmentStart = time.Time{}
+	round := &fusionRound{
+		started: started,
+		results: make(map[*fusionMember]Candidate),
+		flushed: make(map[*fusionMember]bool),
+	}
+	round.timer = time.AfterFunc(s.timeout, func() { s.finish(seq) })
+	s.rounds[seq] = round
+	return round
+}
+
+// flush queues a flush for a secondary engine, or flushes the primary in a
+// goroutine since handle may run on the primary's result callback.
+func (s *fusionStream) flush(m *fusionMember) {
+	if m.primary {
+		go func() {
+			if err := m.stream.Flush(); err != nil {
+				s.flushFailed(m, err)
+			}
+		}()
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case m.flushes <- struct{}{}:
+	default:
+		go s.flushFailed(m, errors.New("flush queue full"))
+	}
+}
+
+func (s *fusionStream) flushLoop(m *fusionMember) {
+	for range m.flushes {
+		if err := m.stream.Flush(); err != nil {
+			s.flushFailed(m, err)
+		}
+	}
+}
+
+// flushFailed records a failed flush as an empty final so the round does not
+// wait for the timeout and the engine's later finals stay aligned.
+func (s *fusionStream) flushFailed(m *fusionMember, err error) {
+	s.handle(m, Result{IsFinal: true, Source: m.name, Error: err.Error()})
+}
+
+// finish emits round seq and any older rounds still open, in order.
+func (s *fusionStream) finish(seq int) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	var out []Result
+	for ; s.emitted <= seq; s.emitted++ {
+		round, ok := s.rounds[s.emitted]
+		if !ok {
+			continue
+		}
+		round.timer.Stop()
+		delete(s.rounds, s.emitted)
+		out = append(out, s.fuse(round))
+	}
+	s.mu.Unlock()
+
+	for _, res := range out {
+		s.emit(res)
+	}
+}
+
+// fuse picks the non-empty candidate with the highest confidence, preferring
+// earlier engines on ties. Callers hold s.mu.
+func (s *fusionStream) fuse(round *fusionRound) Result {
+	res := Result{IsFinal: true, Source: "fusion", LatencyMS: time.Since(round.started).Milliseconds()}
+	best, bestScore := -1, 0.0
+	for _, m := range s.members {
+		c, ok := round.results[m]
+		if !ok {
+			continue
+		}
+		res.Candidates = append(res.Candidates, c)
+		if c.Error != "" || c.Text == "" {
+			continue
+		}
+		score := c.Confidence
+		if score <= 0 {
+			score = s.defaultConfidence
+		}
+		if best < 0 || score > bestScore {
+			best, bestScore = len(res.Candidates)-1, score
+		}
+	}
+	if best < 0 {
+		if len(res.Candidates) == 0 {
+			res.Error = "no engine finished before the fusion timeout"
+		}
+		return res
+	}
+	res.Text, res.Source, res.Confidence = res.Candidates[best].Text, res.Candidates[best].Source, bestScore
+	return res
+}
+
+func (s *fusionStream) emit(res Result) {
+	if s.onResult != nil {
+		s.onResult(res)
+	}
+}
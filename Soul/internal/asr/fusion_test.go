@@ -0,0 +1,152 @@
+package asr
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedEngine answers each Flush with the next scripted final.
+type scriptedEngine struct {
+	name    string
+	finals  []Result
+	delay   time.Duration
+	failNew error
+}
+
+func (e *scriptedEngine) Name() string { return e.name }
+
+func (e *scriptedEngine) NewStream(_ string, onResult func(Result)) (Stream, error) {
+	if e.failNew != nil {
+		return nil, e.failNew
+	}
+	return &scriptedStream{engine: e, onResult: onResult}, nil
+}
+
+type scriptedStream struct {
+	engine   *scriptedEngine
+	onResult func(Result)
+	mu       sync.Mutex
+	next     int
+}
+
+func (s *scriptedStream) PushAudio([]byte) error { return nil }
+func (s *scriptedStream) Close() error           { return nil }
+
+func (s *scriptedStream) Flush() error {
+	s.mu.Lock()
+	if s.next >= len(s.engine.finals) {
+		s.mu.Unlock()
+		return nil
+	}
+	res := s.engine.finals[s.next]
+	s.next++
+	s.mu.Unlock()
+	time.Sleep(s.engine.delay)
+	res.IsFinal = true
+	s.onResult(res)
+	return nil
+}
+
+type resultLog struct {
+	mu  sync.Mutex
+	out []Result
+}
+
+func (l *resultLog) add(r Result) {
+	l.mu.Lock()
+	l.out = append(l.out, r)
+	l.mu.Unlock()
+}
+
+func (l *resultLog) finals(t *testing.T, want int) []Result {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		l.mu.Lock()
+		var finals []Result
+		for _, r := range l.out {
+			if r.IsFinal {
+				finals = append(finals, r)
+			}
+		}
+		l.mu.Unlock()
+		if len(finals) >= want {
+			return finals
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d finals", want)
+	return nil
+}
+
+func TestFusionPicksHigherConfidence(t *testing.T) {
+	primary := &scriptedEngine{name: "bridge", finals: []Result{{Text: "今天天汽", Confidence: 0.6}, {Text: "关灯"}}}
+	second := &scriptedEngine{name: "whisper", finals: []Result{{Text: "今天天气", Confidence: 0.9}, {Text: "关等", Confidence: 0.4}}, delay: 20 * time.Millisecond}
+	var log resultLog
+	s, err := (&FusionEngine{Engines: []Engine{primary, second}, Timeout: time.Second}).NewStream("s1", log.add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	finals := log.finals(t, 2)
+	if finals[0].Text != "今天天气" || finals[0].Source != "whisper" {
+		t.Fatalf("first utterance = %+v", finals[0])
+	}
+	if len(finals[0].Candidates) != 2 || finals[0].Candidates[1].LatencyMS < 20 {
+		t.Fatalf("candidates = %+v", finals[0].Candidates)
+	}
+	// No confidence from the bridge counts as the 0.5 default, beating 0.4.
+	if finals[1].Text != "关灯" || finals[1].Source != "bridge" {
+		t.Fatalf("second utterance = %+v", finals[1])
+	}
+}
+
+func TestFusionTimesOutSlowEngine(t *testing.T) {
+	primary := &scriptedEngine{name: "bridge", finals: []Result{{Text: "你好"}}}
+	slow := &scriptedEngine{name: "whisper", finals: []Result{{Text: "你好呀", Confidence: 0.99}}, delay: 300 * time.Millisecond}
+	var log resultLog
+	s, err := (&FusionEngine{Engines: []Engine{primary, slow}, Timeout: 50 * time.Millisecond}).NewStream("s1", log.add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	_ = s.Flush()
+	finals := log.finals(t, 1)
+	if finals[0].Text != "你好" || len(finals[0].Candidates) != 1 {
+		t.Fatalf("timed out utterance = %+v", finals[0])
+	}
+	// The late answer must not leak into a later utterance.
+	time.Sleep(350 * time.Millisecond)
+	if got := log.finals(t, 1); len(got) != 1 {
+		t.Fatalf("late final emitted: %+v", got)
+	}
+}
+
+func TestFusionSkipsSecondaryThatFailsToStart(t *testing.T) {
+	primary := &scriptedEngine{name: "bridge", finals: []Result{{Text: "开灯"}}}
+	broken := &scriptedEngine{name: "whisper", failNew: errors.New("down")}
+	var log resultLog
+	s, err := (&FusionEngine{Engines: []Engine{primary, broken}}).NewStream("s1", log.add)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	_ = s.Flush()
+	if finals := log.finals(t, 1); finals[0].Text != "开灯" {
+		t.Fatalf("final = %+v", finals[0])
+	}
+	if _, err := (&FusionEngine{Engines: []Engine{broken}}).NewStream("s2", log.add); err == nil {
+		t.Fatal("failing primary should fail the stream")
+	}
+}
@@ -0,0 +1,36 @@
+// Package asr defines the speech recognition engines behind the voice
+// gateway. Engines consume mono PCM16LE at audio.SampleRate; a stream's
+// Flush ends the current utterance and makes the engine emit its final.
+package asr
+
+type Result struct {
+	Text    string `json:"text"`
+	IsFinal bool   `json:"is_final"`
+	Source  string `json:"source,omitempty"`
+	Error   string `json:"error,omitempty"`
+	// Confidence is in [0,1]; 0 means the engine did not report one.
+	Confidence float64 `json:"confidence,omitempty"`
+	// LatencyMS is measured from the start of the utterance's audio.
+	LatencyMS  int64       `json:"latency_ms,omitempty"`
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// Candidate is one engine's final for an utterance, as seen by FusionEngine.
+type Candidate struct {
+	Source     string  `json:"source"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence,omitempty"`
+	LatencyMS  int64   `json:"latency_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type Stream interface {
+	PushAudio(pcm16le []byte) error
+	Flush() error
+	Close() error
+}
+
+type Engine interface {
+	Name() string
+	NewStream(sessionID string, onResult func(Result)) (Stream, error)
+}
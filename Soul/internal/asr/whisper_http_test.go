@@ -0,0 +1,70 @@
+package asr
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWhisperHTTPFlushPostsWAV(t *testing.T) {
+	var gotModel string
+	var gotBytes int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer k" {
+			t.Errorf("authorization = %q", r.Header.Get("Authorization"))
+		}
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("form file: %v", err)
+			return
+		}
+		raw, _ := io.ReadAll(f)
+		if string(raw[:4]) != "RIFF" || binary.LittleEndian.Uint32(raw[40:]) != uint32(len(raw)-44) {
+			t.Errorf("bad wav header")
+		}
+		gotModel, gotBytes = r.FormValue("model"), len(raw)-44
+		_, _ = w.Write([]byte(`{"text":" 打开客厅的灯 ","segments":[{"text":"打开客厅的灯","avg_logprob":-0.2,"no_speech_prob":0.1}]}`))
+	}))
+	defer srv.Close()
+
+	var got []Result
+	s, err := (&WhisperHTTPEngine{URL: srv.URL, Model: "whisper-1", APIKey: "k"}).NewStream("s1", func(r Result) { got = append(got, r) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.PushAudio(make([]byte, 3200))
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if gotModel != "whisper-1" || gotBytes != 3200 {
+		t.Fatalf("request model=%q pcm=%d", gotModel, gotBytes)
+	}
+	want := math.Exp(-0.2) * 0.9
+	if len(got) != 1 || !got[0].IsFinal || got[0].Text != "打开客厅的灯" || math.Abs(got[0].Confidence-want) > 1e-9 {
+		t.Fatalf("results = %+v", got)
+	}
+
+	// An empty buffer still closes the utterance without a request.
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Text != "" || !got[1].IsFinal {
+		t.Fatalf("empty flush = %+v", got)
+	}
+}
+
+func TestWhisperHTTPStatusError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s, _ := (&WhisperHTTPEngine{URL: srv.URL}).NewStream("s1", func(Result) { t.Error("no result expected on error") })
+	_ = s.PushAudio(make([]byte, 320))
+	if err := s.Flush(); err == nil {
+		t.Fatal("want error for 503")
+	}
+}
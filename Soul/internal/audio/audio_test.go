@@ -0,0 +1,51 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSamplesRoundTrip(t *testing.T) {
+	in := []int16{0, 1, -1, 32767, -32768}
+	got := Samples(Bytes(in))
+	if len(got) != len(in) {
+		t.Fatalf("got %d samples, want %d", len(got), len(in))
+	}
+	for i := range in {
+		if got[i] != in[i] {
+			t.Fatalf("sample %d = %d, want %d", i, got[i], in[i])
+		}
+	}
+	if n := len(Samples([]byte{1, 2, 3})); n != 1 {
+		t.Fatalf("odd trailing byte: got %d samples, want 1", n)
+	}
+	if d := Duration(SampleRate * 2); d != time.Second {
+		t.Fatalf("Duration = %s, want 1s", d)
+	}
+}
+
+func TestGainClips(t *testing.T) {
+	pcm := Bytes([]int16{100, -100, 20000, -20000})
+	Gain(pcm, 2)
+	want := []int16{200, -200, 32767, -32768}
+	for i, got := range Samples(pcm) {
+		if got != want[i] {
+			t.Fatalf("sample %d = %d, want %d", i, got, want[i])
+		}
+	}
+}
+
+func TestOpusDecoderSilenceFrame(t *testing.T) {
+	dec, err := NewOpusDecoder(SampleRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 20ms CELT silence frame as sent by browsers during DTX.
+	pcm, err := dec.Decode([]byte{0xF8, 0xFF, 0xFE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := SampleRate / 50 * 2; len(pcm) != want {
+		t.Fatalf("decoded %d bytes, want %d", len(pcm), want)
+	}
+}
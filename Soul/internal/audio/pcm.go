@@ -0,0 +1,75 @@
+// Package audio holds the voice path's sample formats: mono PCM16LE at
+// SampleRate is what VAD and ASR consume, whatever the transport delivered.
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// SampleRate is the rate of the PCM handed to VAD and ASR.
+const SampleRate = 16000
+
+// Samples decodes PCM16LE into samples; a trailing odd byte is ignored.
+func Samples(pcm16le []byte) []int16 {
+	out := make([]int16, len(pcm16le)/2)
+	for i := range out {
+		out[i] = int16(binary.LittleEndian.Uint16(pcm16le[i*2:]))
+	}
+	return out
+}
+
+// Bytes encodes samples as PCM16LE.
+func Bytes(samples []int16) []byte {
+	out := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(s))
+	}
+	return out
+}
+
+// Duration is the playback length of n PCM16LE bytes at SampleRate.
+func Duration(n int) time.Duration {
+	return time.Duration(n/2) * time.Second / SampleRate
+}
+
+// Gain scales PCM16LE in place, clipping at full scale.
+func Gain(pcm16le []byte, gain float64) {
+	for i := 0; i+1 < len(pcm16le); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(pcm16le[i:]))) * gain
+		v = max(math.MinInt16, min(math.MaxInt16, math.Round(v)))
+		binary.LittleEndian.PutUint16(pcm16le[i:], uint16(int16(v)))
+	}
+}
+
+// WriteWAV wraps mono PCM16LE at SampleRate in a RIFF header.
+func WriteWAV(w io.Writer, pcm []byte) error {
+	header := struct {
+		RIFF          [4]byte
+		ChunkSize     uint32
+		WAVE          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		RIFF: [4]byte{'R', 'I', 'F', 'F'}, ChunkSize: uint32(36 + len(pcm)),
+		WAVE: [4]byte{'W', 'A', 'V', 'E'}, Fmt: [4]byte{'f', 'm', 't', ' '}, FmtSize: 16,
+		AudioFormat: 1, Channels: 1, SampleRate: SampleRate, ByteRate: SampleRate * 2,
+		BlockAlign: 2, BitsPerSample: 16,
+		Data: [4]byte{'d', 'a', 't', 'a'}, DataSize: uint32(len(pcm)),
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	_, err := w.Write(pcm)
+	return err
+}
@@ -0,0 +1,198 @@
+// Package resample converts client PCM16LE at common capture rates and
+// channel counts to the mono audio.SampleRate stream VAD and ASR expect.
+package resample
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"soul/internal/audio"
+)
+
+// EncodingPCM16LE is the only sample encoding clients may declare.
+const EncodingPCM16LE = "pcm_s16le"
+
+// SupportedRates are the input sample rates clients may declare.
+var SupportedRates = []int{8000, 11025, 16000, 22050, 24000, 32000, 44100, 48000}
+
+// Format describes interleaved PCM16LE input.
+type Format struct {
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+	Encoding   string `json:"encoding,omitempty"`
+}
+
+// Native is the format VAD and ASR consume; it passes through untouched.
+var Native = Format{SampleRate: audio.SampleRate, Channels: 1, Encoding: EncodingPCM16LE}
+
+// Normalize fills unset fields from Native and rejects what the voice path
+// cannot convert.
+func (f Format) Normalize() (Format, error) {
+	if f.SampleRate == 0 {
+		f.SampleRate = Native.SampleRate
+	}
+	if f.Channels == 0 {
+		f.Channels = Native.Channels
+	}
+	if f.Encoding == "" {
+		f.Encoding = EncodingPCM16LE
+	}
+	if f.Encoding != EncodingPCM16LE {
+		return f, fmt.Errorf("unsupported encoding %q, want %s", f.Encoding, EncodingPCM16LE)
+	}
+	if !slices.Contains(SupportedRates, f.SampleRate) {
+		return f, fmt.Errorf("unsupported sample rate %d, want one of %v", f.SampleRate, SupportedRates)
+	}
+	if f.Channels != 1 && f.Channels != 2 {
+		return f, fmt.Errorf("unsupported channel count %d, want 1 or 2", f.Channels)
+	}
+	return f, nil
+}
+
+// halfWidth is the filter's one-sided length in output-rate samples. Wider
+// means a sharper anti-aliasing cutoff at the cost of latency and CPU.
+const halfWidth = 16
+
+// Resampler streams one Format to mono audio.SampleRate with a windowed-sinc
+// filter, which band-limits the input when downsampling so 48 kHz content
+// above 8 kHz does not fold back into the speech band. It is not safe for
+// concurrent use.
+type Resampler struct {
+	in    Format
+	scale float64 // filter cutoff relative to the input Nyquist rate
+	half  int     // one-sided filter length in input samples
+
+	pending []byte    // partial frame carried to the next Push
+	buf     []float64 // mono input history, starting half zeros back
+	// The next output sits at buf index pos + frac/audio.SampleRate. Exact
+	// integer stepping keeps the output independent of how input is chunked.
+	pos  int
+	frac int
+}
+
+// New returns a Resampler for f, which must already be normalized.
+func New(f Format) *Resampler {
+	r := &Resampler{
+		in:    f,
+		scale: math.Min(1, audio.SampleRate/float64(f.SampleRate)),
+	}
+	r.half = int(math.Ceil(halfWidth / r.scale))
+	r.reset()
+	return r
+}
+
+// Passthrough reports whether input is already in the native format.
+func (r *Resampler) Passthrough() bool {
+	return r.in.SampleRate == audio.SampleRate && r.in.Channels == 1
+}
+
+// Push converts pcm16le and returns the output ready so far. The filter
+// looks ahead a fraction of a millisecond, so the newest samples are held
+// back until more input or Flush arrives.
+func (r *Resampler) Push(pcm16le []byte) []byte {
+	if r.Passthrough() {
+		return pcm16le
+	}
+	frameBytes := 2 * r.in.Channels
+	data := pcm16le
+	if len(r.pending) > 0 {
+		data = append(r.pending, pcm16le...)
+		r.pending = nil
+	}
+	whole := len(data) / frameBytes * frameBytes
+	if whole < len(data) {
+		r.pending = append([]byte(nil), data[whole:]...)
+	}
+
+	samples := audio.Samples(data[:whole])
+	for i := 0; i < len(samples); i += r.in.Channels {
+		var sum float64
+		for c := 0; c < r.in.Channels; c++ {
+			sum += float64(samples[i+c])
+		}
+		r.buf = append(r.buf, sum/float64(r.in.Channels))
+	}
+	return r.drain()
+}
+
+// Flush emits the held-back tail and resets the filter, for the end of a
+// stream or an utterance.
+func (r *Resampler) Flush() []byte {
+	if r.Passthrough() {
+		return nil
+	}
+	r.pending = nil
+	r.buf = append(r.buf, make([]float64, r.half)...)
+	out := r.drain()
+	r.reset()
+	return out
+}
+
+func (r *Resampler) reset() {
+	r.buf = make([]float64, r.half)
+	r.pos, r.frac = r.half, 0
+}
+
+// drain emits every output whose filter window is fully buffered and drops
+// input no later output can reach.
+func (r *Resampler) drain() []byte {
+	var out []int16
+	for r.pos+r.half < len(r.buf) {
+		t := float64(r.pos) + float64(r.frac)/audio.SampleRate
+		out = append(out, clamp(r.sample(t)))
+		r.frac += r.in.SampleRate
+		r.pos += r.frac / audio.SampleRate
+		r.frac %= audio.SampleRate
+	}
+	if drop := r.pos - r.half; drop > 0 {
+		r.buf = r.buf[drop:]
+		r.pos -= drop
+	}
+	return audio.Bytes(out)
+}
+
+// sample evaluates the band-limited signal at t, in input samples.
+func (r *Resampler) sample(t float64) float64 {
+	lo := int(math.Ceil(t)) - r.half
+	hi := int(t) + r.half
+	var acc, norm float64
+	for i := lo; i <= hi; i++ {
+		x := t - float64(i)
+		w := r.scale * sinc(r.scale*x) * blackman(x/float64(r.half+1))
+		acc += r.buf[i] * w
+		norm += w
+	}
+	if norm == 0 {
+		return 0
+	}
+	// Normalizing the taps keeps DC gain at exactly one for every phase.
+	return acc / norm
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman is the window over x in [-1, 1].
+func blackman(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	p := math.Pi * (x + 1)
+	return 0.42 - 0.5*math.Cos(p) + 0.08*math.Cos(2*p)
+}
+
+func clamp(v float64) int16 {
+	v = math.Round(v)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
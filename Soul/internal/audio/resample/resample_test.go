@@ -0,0 +1,103 @@
+package resample
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"soul/internal/audio"
+)
+
+func sine(rate, channels, ms int, freq, amplitude float64) []byte {
+	n := rate * ms / 1000
+	samples := make([]int16, 0, n*channels)
+	for i := 0; i < n; i++ {
+		v := int16(amplitude * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+		for c := 0; c < channels; c++ {
+			samples = append(samples, v)
+		}
+	}
+	return audio.Bytes(samples)
+}
+
+// rms skips the filter's edges.
+func rms(pcm []byte) float64 {
+	s := audio.Samples(pcm)
+	s = s[len(s)/10 : len(s)-len(s)/10]
+	var sum float64
+	for _, v := range s {
+		sum += float64(v) * float64(v)
+	}
+	return math.Sqrt(sum / float64(len(s)))
+}
+
+func convert(t *testing.T, f Format, pcm []byte) []byte {
+	t.Helper()
+	f, err := f.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(f)
+	return append(r.Push(pcm), r.Flush()...)
+}
+
+func TestResamplerKeepsSpeechBand(t *testing.T) {
+	for _, rate := range []int{8000, 44100, 48000} {
+		out := convert(t, Format{SampleRate: rate}, sine(rate, 1, 1000, 440, 10000))
+		if got, want := len(out)/2, audio.SampleRate; got < want-2 || got > want+2 {
+			t.Errorf("%d Hz: got %d samples for 1s, want ~%d", rate, got, want)
+		}
+		if got, want := rms(out), 10000/math.Sqrt2; math.Abs(got-want) > want*0.02 {
+			t.Errorf("%d Hz: 440 Hz tone rms = %.0f, want ~%.0f", rate, got, want)
+		}
+	}
+}
+
+func TestResamplerRejectsAliases(t *testing.T) {
+	// 12 kHz would fold to 4 kHz at 16 kHz without band-limiting.
+	out := convert(t, Format{SampleRate: 48000}, sine(48000, 1, 500, 12000, 10000))
+	if got := rms(out); got > 100 {
+		t.Fatalf("12 kHz tone leaked through at rms %.0f", got)
+	}
+}
+
+func TestResamplerDownmixesStereo(t *testing.T) {
+	mono := convert(t, Format{SampleRate: 48000, Channels: 1}, sine(48000, 1, 500, 440, 8000))
+	stereo := convert(t, Format{SampleRate: 48000, Channels: 2}, sine(48000, 2, 500, 440, 8000))
+	if !bytes.Equal(mono, stereo) {
+		t.Fatal("stereo with identical channels should downmix to the mono result")
+	}
+}
+
+func TestResamplerChunkingIsTransparent(t *testing.T) {
+	f, _ := Format{SampleRate: 44100, Channels: 2}.Normalize()
+	in := sine(44100, 2, 300, 300, 9000)
+	whole := convert(t, f, in)
+
+	r := New(f)
+	var chunked []byte
+	for rest := in; len(rest) > 0; {
+		n := min(len(rest), 333) // odd sizes split frames and samples
+		chunked = append(chunked, r.Push(rest[:n])...)
+		rest = rest[n:]
+	}
+	chunked = append(chunked, r.Flush()...)
+	if !bytes.Equal(whole, chunked) {
+		t.Fatalf("chunked output differs: %d vs %d bytes", len(chunked), len(whole))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	f, err := Format{}.Normalize()
+	if err != nil || f != Native {
+		t.Fatalf("empty format = %+v, %v; want Native", f, err)
+	}
+	if !New(f).Passthrough() {
+		t.Fatal("native format should pass through")
+	}
+	for _, bad := range []Format{{SampleRate: 96000}, {Channels: 6}, {Encoding: "opus"}} {
+		if _, err := bad.Normalize(); err == nil {
+			t.Errorf("%+v: expected error", bad)
+		}
+	}
+}
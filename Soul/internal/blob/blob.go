@@ -0,0 +1,190 @@
+// Package blob keeps the photos and audio clips terminals attach to chat
+// inputs in an S3 compatible bucket such as MinIO. Terminals upload
+// straight to the bucket through a presigned URL, reference the object from
+// ChatInput.media, and a cleanup worker deletes objects past retention.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"soul/internal/domain"
+)
+
+// ErrInvalidUpload wraps every reason CreateUpload rejects a request.
+var ErrInvalidUpload = errors.New("invalid media upload")
+
+// ErrForeignMedia is returned by CheckMedia for an object another terminal
+// uploaded.
+var ErrForeignMedia = errors.New("media belongs to another terminal")
+
+// keyPrefix is where uploads land; the cleanup worker only looks under it.
+const keyPrefix = "media/"
+
+// mediaExtensions lists the accepted mime types and the file extension
+// their objects get.
+var mediaExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"audio/wav":  ".wav",
+	"audio/mpeg": ".mp3",
+	"audio/ogg":  ".ogg",
+	"audio/opus": ".opus",
+}
+
+// Client is the bucket the service works on; S3Client implements it.
+type Client interface {
+	Bucket() string
+	PresignPut(key, mime string, size int64, ttl time.Duration) string
+	GetObject(ctx context.Context, key string, limit int64) ([]byte, error)
+	ListObjects(ctx context.Context, prefix, token string) ([]Object, string, error)
+	DeleteObject(ctx context.Context, key string) error
+}
+
+type Config struct {
+	UploadTTL time.Duration
+	MaxBytes  int64
+	// Retention is how long objects are kept; 0 keeps them forever.
+	Retention time.Duration
+}
+
+type Service struct {
+	client Client
+	cfg    Config
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewService(client Client, cfg Config, logger *slog.Logger) *Service {
+	if cfg.UploadTTL <= 0 {
+		cfg.UploadTTL = 15 * time.Minute
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 10 << 20
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Service{client: client, cfg: cfg, logger: logger, now: time.Now}
+}
+
+// CreateUpload validates the request and presigns a PUT for a fresh object
+// under media/<terminal_id>/<date>/.
+func (s *Service) CreateUpload(in domain.MediaUploadRequest) (domain.MediaUpload, error) {
+	terminalID := strings.TrimSpace(in.TerminalID)
+	if terminalID == "" || strings.ContainsAny(terminalID, "/\\") || terminalID == "." || terminalID == ".." {
+		return domain.MediaUpload{}, fmt.Errorf("%w: terminal_id is required and must not contain slashes", ErrInvalidUpload)
+	}
+	mime := strings.ToLower(strings.TrimSpace(in.Mime))
+	ext, ok := mediaExtensions[mime]
+	if !ok {
+		return domain.MediaUpload{}, fmt.Errorf("%w: unsupported mime %q", ErrInvalidUpload, in.Mime)
+	}
+	if in.SizeBytes <= 0 || in.SizeBytes > s.cfg.MaxBytes {
+		return domain.MediaUpload{}, fmt.Errorf("%w: size_bytes must be between 1 and %d", ErrInvalidUpload, s.cfg.MaxBytes)
+	}
+	now := s.now().UTC()
+	key := keyPrefix + terminalID + "/" + now.Format("2006/01/02") + "/" + uuid.NewString() + ext
+	return domain.MediaUpload{
+		UploadURL: s.client.PresignPut(key, mime, in.SizeBytes, s.cfg.UploadTTL),
+		Method:    http.MethodPut,
+		Headers:   map[string]string{"Content-Type": mime},
+		ExpiresAt: now.Add(s.cfg.UploadTTL),
+		Media: domain.InputMedia{
+			Provider:       "s3",
+			Bucket:         s.client.Bucket(),
+			ObjectKey:      key,
+			Mime:           mime,
+			SizeBytes:      in.SizeBytes,
+			ChecksumSHA256: strings.TrimSpace(in.ChecksumSHA256),
+		},
+	}, nil
+}
+
+// CheckMedia rejects a chat input that points into this bucket at an
+// object the terminal did not upload. Media kept elsewhere passes.
+func (s *Service) CheckMedia(terminalID string, media *domain.InputMedia) error {
+	if media == nil || media.Bucket != s.client.Bucket() {
+		return nil
+	}
+	if !strings.HasPrefix(media.ObjectKey, keyPrefix+terminalID+"/") || strings.Contains(media.ObjectKey, "..") {
+		return ErrForeignMedia
+	}
+	return nil
+}
+
+// FetchMedia downloads an object the terminal uploaded to this bucket,
+// e.g. the photo capture_image returned.
+func (s *Service) FetchMedia(ctx context.Context, terminalID string, media domain.InputMedia) ([]byte, error) {
+	if media.Bucket != s.client.Bucket() {
+		return nil, fmt.Errorf("media bucket %q is not %q", media.Bucket, s.client.Bucket())
+	}
+	if err := s.CheckMedia(terminalID, &media); err != nil {
+		return nil, err
+	}
+	return s.client.GetObject(ctx, media.ObjectKey, s.cfg.MaxBytes)
+}
+
+// Run deletes expired objects every interval until ctx is done; it does
+// nothing when retention is off.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	if s.cfg.Retention <= 0 {
+		return
+	}
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.logger.Info("media cleanup worker started", "interval", interval, "retention", s.cfg.Retention)
+	for {
+		if n, err := s.Cleanup(ctx); err != nil {
+			s.logger.Warn("media cleanup failed", "deleted", n, "error", err)
+		} else if n > 0 {
+			s.logger.Info("expired media deleted", "deleted", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Cleanup deletes every uploaded object older than the retention and
+// returns how many went.
+func (s *Service) Cleanup(ctx context.Context) (int, error) {
+	if s.cfg.Retention <= 0 {
+		return 0, nil
+	}
+	cutoff := s.now().Add(-s.cfg.Retention)
+	deleted := 0
+	token := ""
+	for {
+		objects, next, err := s.client.ListObjects(ctx, keyPrefix, token)
+		if err != nil {
+			return deleted, err
+		}
+		for _, obj := range objects {
+			if !obj.LastModified.Before(cutoff) {
+				continue
+			}
+			if err := s.client.DeleteObject(ctx, obj.Key); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		if next == "" {
+			return deleted, nil
+		}
+		token = next
+	}
+}
@@ -0,0 +1,92 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestUploadAndCleanupAgainstFakeBucket(t *testing.T) {
+	var deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if strings.HasPrefix(r.URL.Path, "/media-bucket/media/") {
+				fmt.Fprint(w, "jpeg-bytes")
+				return
+			}
+			if r.URL.Path != "/media-bucket/" || r.URL.Query().Get("prefix") != "media/" {
+				t.Errorf("unexpected listing %s", r.URL)
+			}
+			if r.URL.Query().Get("continuation-token") == "" {
+				fmt.Fprint(w, `<ListBucketResult><IsTruncated>true</IsTruncated><NextContinuationToken>p2</NextContinuationToken>
+<Contents><Key>media/t1/2026/01/01/old.jpg</Key><Size>10</Size><LastModified>2026-01-01T00:00:00.000Z</LastModified></Contents>
+<Contents><Key>media/t1/2026/03/01/new.jpg</Key><Size>10</Size><LastModified>2026-03-01T00:00:00.000Z</LastModified></Contents>
+</ListBucketResult>`)
+				return
+			}
+			fmt.Fprint(w, `<ListBucketResult><IsTruncated>false</IsTruncated>
+<Contents><Key>media/t2/2026/01/15/old.wav</Key><Size>10</Size><LastModified>2026-01-15T00:00:00Z</LastModified></Contents>
+</ListBucketResult>`)
+		case http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/media-bucket/"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewS3Client(S3Config{Endpoint: srv.URL, Bucket: "media-bucket", AccessKey: "minio", SecretKey: "secret", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := NewService(client, Config{MaxBytes: 1000, Retention: 30 * 24 * time.Hour}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	svc.now = func() time.Time { return time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC) }
+
+	if _, err := svc.CreateUpload(domain.MediaUploadRequest{TerminalID: "t1", Mime: "video/mp4", SizeBytes: 10}); !errors.Is(err, ErrInvalidUpload) {
+		t.Fatalf("unsupported mime must be rejected, got %v", err)
+	}
+	if _, err := svc.CreateUpload(domain.MediaUploadRequest{TerminalID: "t1", Mime: "image/png", SizeBytes: 1001}); !errors.Is(err, ErrInvalidUpload) {
+		t.Fatalf("oversized upload must be rejected, got %v", err)
+	}
+	up, err := svc.CreateUpload(domain.MediaUploadRequest{TerminalID: "t1", Mime: "Image/PNG", SizeBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(up.Media.ObjectKey, "media/t1/2026/03/10/") || !strings.HasSuffix(up.Media.ObjectKey, ".png") ||
+		up.Media.Bucket != "media-bucket" || up.Headers["Content-Type"] != "image/png" || !strings.HasPrefix(up.UploadURL, srv.URL+"/media-bucket/media/t1/") {
+		t.Fatalf("unexpected upload %+v", up)
+	}
+	if err := svc.CheckMedia("t1", &up.Media); err != nil {
+		t.Fatalf("own upload must pass, got %v", err)
+	}
+	if err := svc.CheckMedia("t2", &up.Media); !errors.Is(err, ErrForeignMedia) {
+		t.Fatalf("another terminal's upload must be rejected, got %v", err)
+	}
+	if data, err := svc.FetchMedia(context.Background(), "t1", up.Media); err != nil || string(data) != "jpeg-bytes" {
+		t.Fatalf("fetch own upload: %q %v", data, err)
+	}
+	if _, err := svc.FetchMedia(context.Background(), "t2", up.Media); !errors.Is(err, ErrForeignMedia) {
+		t.Fatalf("fetching another terminal's upload must fail, got %v", err)
+	}
+
+	n, err := svc.Cleanup(context.Background())
+	if err != nil || n != 2 {
+		t.Fatalf("cleanup deleted %d, err %v", n, err)
+	}
+	if len(deleted) != 2 || deleted[0] != "media/t1/2026/01/01/old.jpg" || deleted[1] != "media/t2/2026/01/15/old.wav" {
+		t.Fatalf("only expired objects must be deleted, got %v", deleted)
+	}
+}
@@ -0,0 +1,308 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat = "20060102T150405Z"
+	// emptyPayloadHash is the SHA-256 of an empty body, which every signed
+	// request but PutObject sends.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// listBodyLimit caps listing and error bodies.
+	listBodyLimit = 8 << 20
+)
+
+// S3Config addresses a bucket on AWS S3 or an S3 compatible server.
+// PathStyle puts the bucket in the path (http://minio:9000/bucket/key), as
+// MinIO expects; otherwise it goes in the host name.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	// Timeout bounds each request; zero means 30 seconds.
+	Timeout time.Duration
+}
+
+// Object is one entry of a bucket listing.
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// S3Client signs requests with AWS Signature Version 4. It only covers what
+// the media flow and soul-backup need: presigned uploads and downloads,
+// listing, deleting and plain uploads.
+type S3Client struct {
+	cfg    S3Config
+	base   *url.URL
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3Client(cfg S3Config) (*S3Client, error) {
+	base, err := url.Parse(strings.TrimRight(strings.TrimSpace(cfg.Endpoint), "/"))
+	if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("blob endpoint must be an http(s) URL, got %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("blob bucket, access key and secret key are required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return &S3Client{
+		cfg:    cfg,
+		base:   base,
+		client: &http.Client{Timeout: cfg.Timeout},
+		now:    time.Now,
+	}, nil
+}
+
+func (c *S3Client) Bucket() string {
+	return c.cfg.Bucket
+}
+
+// PresignPut returns a URL that accepts one PUT of the object until ttl
+// passes. Content-Type and Content-Length are signed, so the upload must
+// carry exactly the declared mime and size.
+func (c *S3Client) PresignPut(key, mime string, size int64, ttl time.Duration) string {
+	return c.presign(http.MethodPut, key, map[string]string{
+		"content-length": strconv.FormatInt(size, 10),
+		"content-type":   mime,
+	}, ttl)
+}
+
+// PresignGet returns a URL that downloads the object until ttl passes.
+func (c *S3Client) PresignGet(key string, ttl time.Duration) string {
+	return c.presign(http.MethodGet, key, nil, ttl)
+}
+
+// ListObjects returns one page of the objects under prefix and the token
+// of the next page, empty on the last one.
+func (c *S3Client) ListObjects(ctx context.Context, prefix, token string) ([]Object, string, error) {
+	u := c.objectURL("")
+	q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if token != "" {
+		q.Set("continuation-token", token)
+	}
+	u.RawQuery = canonicalQuery(q)
+	body, err := c.do(ctx, http.MethodGet, u, nil, listBodyLimit)
+	if err != nil {
+		return nil, "", err
+	}
+	var page struct {
+		Contents []struct {
+			Key          string    `xml:"Key"`
+			Size         int64     `xml:"Size"`
+			LastModified time.Time `xml:"LastModified"`
+		} `xml:"Contents"`
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+	}
+	if err := xml.Unmarshal(body, &page); err != nil {
+		return nil, "", fmt.Errorf("decode bucket listing: %w", err)
+	}
+	out := make([]Object, 0, len(page.Contents))
+	for _, item := range page.Contents {
+		out = append(out, Object{Key: item.Key, Size: item.Size, LastModified: item.LastModified})
+	}
+	if !page.IsTruncated {
+		return out, "", nil
+	}
+	return out, page.NextContinuationToken, nil
+}
+
+// GetObject downloads the object, failing when it is larger than limit
+// bytes.
+func (c *S3Client) GetObject(ctx context.Context, key string, limit int64) ([]byte, error) {
+	body, err := c.do(ctx, http.MethodGet, c.objectURL(key), nil, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("object %s is larger than %d bytes", key, limit)
+	}
+	return body, nil
+}
+
+// DeleteObject removes the object; a missing object is not an error.
+func (c *S3Client) DeleteObject(ctx context.Context, key string) error {
+	_, err := c.do(ctx, http.MethodDelete, c.objectURL(key), nil, listBodyLimit)
+	return err
+}
+
+// PutObject uploads body as the object, replacing any object at key.
+func (c *S3Client) PutObject(ctx context.Context, key string, body []byte) error {
+	_, err := c.do(ctx, http.MethodPut, c.objectURL(key), body, listBodyLimit)
+	return err
+}
+
+func (c *S3Client) do(ctx context.Context, method string, u *url.URL, payload []byte, limit int64) ([]byte, error) {
+	var reqBody io.Reader
+	payloadHash := emptyPayloadHash
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+		sum := sha256.Sum256(payload)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	t := c.now().UTC()
+	req.Header.Set("X-Amz-Date", t.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           t.Format(amzDateFormat),
+	}
+	names := signedHeaderNames(headers)
+	sig := c.signature(t, canonicalRequest(method, u, headers, payloadHash))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, c.cfg.AccessKey, c.scope(t), names, sig))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("s3 %s %s: status %d: %s", method, u.Path, resp.StatusCode, strings.TrimSpace(string(body[:min(len(body), 512)])))
+	}
+	return body, nil
+}
+
+func (c *S3Client) presign(method, key string, extra map[string]string, ttl time.Duration) string {
+	t := c.now().UTC()
+	u := c.objectURL(key)
+	headers := map[string]string{"host": u.Host}
+	for name, value := range extra {
+		headers[name] = value
+	}
+	names := signedHeaderNames(headers)
+	q := url.Values{
+		"X-Amz-Algorithm":     {signAlgorithm},
+		"X-Amz-Credential":    {c.cfg.AccessKey + "/" + c.scope(t)},
+		"X-Amz-Date":          {t.Format(amzDateFormat)},
+		"X-Amz-Expires":       {strconv.Itoa(int(ttl / time.Second))},
+		"X-Amz-SignedHeaders": {names},
+	}
+	u.RawQuery = canonicalQuery(q)
+	sig := c.signature(t, canonicalRequest(method, u, headers, "UNSIGNED-PAYLOAD"))
+	u.RawQuery += "&X-Amz-Signature=" + sig
+	return u.String()
+}
+
+func (c *S3Client) objectURL(key string) *url.URL {
+	u := *c.base
+	if c.cfg.PathStyle {
+		u.Path = "/" + c.cfg.Bucket + "/" + key
+	} else {
+		u.Host = c.cfg.Bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	u.RawPath = uriEncode(u.Path, true)
+	return &u
+}
+
+func (c *S3Client) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + c.cfg.Region + "/s3/aws4_request"
+}
+
+func (c *S3Client) signature(t time.Time, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := signAlgorithm + "\n" + t.Format(amzDateFormat) + "\n" + c.scope(t) + "\n" + hex.EncodeToString(sum[:])
+	key := hmacSHA256([]byte("AWS4"+c.cfg.SecretKey), t.Format("20060102"))
+	key = hmacSHA256(key, c.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func canonicalRequest(method string, u *url.URL, headers map[string]string, payloadHash string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	return strings.Join([]string{method, u.EscapedPath(), u.RawQuery, b.String(), strings.Join(names, ";"), payloadHash}, "\n")
+}
+
+func signedHeaderNames(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ";")
+}
+
+// canonicalQuery encodes the query the way SigV4 wants it: keys sorted and
+// everything but unreserved characters percent-encoded.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k, false)+"="+uriEncode(v, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func uriEncode(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', ch == '/' && keepSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
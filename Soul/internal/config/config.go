@@ -12,34 +12,161 @@ type SoulServerConfig struct {
 	HTTPAddr                     string
 	UserID                       string
 	DBDSN                        string
+	DBMaxConns                   int
+	DBMinConns                   int
+	DBHealthCheckPeriod          time.Duration
+	DBMaxConnIdleTime            time.Duration
+	DBStatementCacheCapacity     int
+	DBReadDSN                    string
+	DBReadMaxConns               int
+	DBReadMaxLag                 time.Duration
 	MQTTBrokerURL                string
 	MQTTClientID                 string
 	MQTTUsername                 string
 	MQTTPassword                 string
 	MQTTTopicPrefix              string
+	MQTTEmbeddedBroker           bool
+	MQTTEmbeddedBrokerAddr       string
 	LLMProvider                  string
 	LLMModel                     string
 	OpenAIBaseURL                string
 	OpenAIAPIKey                 string
 	AnthropicBaseURL             string
 	AnthropicAPIKey              string
+	AnthropicMaxTokens           int
+	AnthropicThinkingBudget      int
+	AnthropicStream              bool
+	LLMRetryMaxAttempts          int
+	LLMRetryBaseDelay            time.Duration
+	LLMRetryMaxDelay             time.Duration
+	LLMMaxIdleConns              int
+	LLMIdleConnTimeout           time.Duration
+	LLMDialTimeout               time.Duration
+	LLMTLSTimeout                time.Duration
+	LLMHTTP2                     bool
+	LLMWarmConns                 int
+	LLMMockFixture               string
+	LLMEmbeddingModel            string
+	LLMEmbeddingDimensions       int
+	LLMEmbeddingBatchSize        int
 	ToolTimeout                  time.Duration
 	ChatHistoryLimit             int
 	SkillSnapshotTTL             time.Duration
+	TerminalHeartbeatTimeout     time.Duration
+	TerminalAlertWebhookURL      string
+	TerminalAlertWebhookToken    string
+	BlobEndpoint                 string
+	BlobRegion                   string
+	BlobBucket                   string
+	BlobAccessKey                string
+	BlobSecretKey                string
+	BlobPathStyle                bool
+	BlobUploadTTL                time.Duration
+	BlobMaxUploadBytes           int64
+	BlobRetentionDays            int
+	SkillHTTPAllowedHosts        []string
+	SkillHTTPAllowHTTP           bool
+	SMTPHost                     string
+	SMTPPort                     int
+	SMTPUsername                 string
+	SMTPPassword                 string
+	SMTPFrom                     string
+	SMTPTLS                      string
+	SMTPTimeout                  time.Duration
+	EmailAllowedRecipients       map[string][]string
+	ReminderScanInterval         time.Duration
+	ReminderPushURL              string
+	ReminderPushToken            string
+	RoutineScanInterval          time.Duration
+	QuietHoursAllowedSkills      []string
 	UserIdleTimeout              time.Duration
 	IdleSummaryScanInterval      time.Duration
 	SessionCompressMsgThreshold  int
 	SessionCompressCharThreshold int
 	SessionCompressScanLimit     int
+	SessionRetentionDays         int
+	SessionArchiveMode           string
 	Mem0BaseURL                  string
 	Mem0APIKey                   string
 	Mem0Timeout                  time.Duration
 	Mem0AsyncQueueEnabled        bool
+	RedactEnabled                bool
+	RedactKinds                  []string
+	RedactPatterns               string
+	SafetyBlocklist              []string
+	SafetyBlocklistFile          string
+	SafetyModerationURL          string
+	SafetyModerationAPIKey       string
+	SafetyModerationModel        string
+	SafetyModerationTimeout      time.Duration
+	SafetyRefusalReply           string
+	ChildModeBlockedSkills       []string
+	ChildModeMaxReplyRunes       int
+	ChildModePIN                 string
+	TopicTrackingEnabled         bool
+	TopicKeywords                string
+	ReplyFilters                 []string
+	ReplyScrubPhrases            []string
+	SoulExemplarTokenBudget      int
+	GateBypassSkills             []string
+	GateOverrideToken            string
+	PersonaConfigFile            string
+	EmotionTraceFile             string
+	ReplaySampleRate             float64
+	DiaryEnabled                 bool
+	DiaryHour                    int
+	EmotionContagionEnabled      bool
+	EmotionContagionShare        float64
+	EmotionContagionMinShock     float64
+	MoodAlertEnabled             bool
+	MoodAlertDays                int
+	MoodAlertNegativeShare       float64
+	MoodAlertWebhookURL          string
+	MoodAlertWebhookToken        string
+	MemoryRecallCitations        bool
+	MemoryContextCacheTTL        time.Duration
 	EmotionBaseURL               string
 	EmotionTimeout               time.Duration
 	IntentFilterBaseURL          string
 	IntentFilterTimeout          time.Duration
+	IntentFilterAttempts         int
+	IntentFilterCacheTTL         time.Duration
+	IntentFilterLocalFallback    bool
 	EmotionTickInterval          time.Duration
+	SpeakerMatchThreshold        float64
+	FollowUpWindow               time.Duration
+	PresenceGreeting             bool
+	PresenceGreetingCooldown     time.Duration
+	PresenceTTL                  time.Duration
+	PowerIdleSleep               time.Duration
+	PowerSleepStart              string
+	PowerSleepEnd                string
+	PowerAsleepDecayScale        float64
+	IntentClarifyTTL             time.Duration
+	IntentResultSessionNotes     bool
+	KnowledgeEnabled             bool
+	KnowledgeChunkRunes          int
+	KnowledgeChunkOverlap        int
+	KnowledgeMaxDocumentRunes    int
+	KnowledgeTopK                int
+	KnowledgeMinScore            float64
+	ToolOutputMaxRunes           int
+	ToolOutputLimits             map[string]int
+	ToolOutputSummarize          bool
+	CriticMode                   string
+	CriticModel                  string
+	CriticTimeout                time.Duration
+	SpeculationConfirm           int
+	SpeculationMinConfidence     float64
+	SpeculationTTL               time.Duration
+	LatencyBudget                time.Duration
+	LatencyBudgetEmotion         time.Duration
+	LatencyBudgetIntent          time.Duration
+	LatencyBudgetMem0            time.Duration
+	WriteBehindEnabled           bool
+	WriteBehindQueueSize         int
+	WriteBehindWorkers           int
+	SoulLocksEnabled             bool
 }
 
 type TerminalWebConfig struct {
@@ -57,39 +184,234 @@ type TerminalWebConfig struct {
 	UserID            string
 }
 
+// VoiceGatewayConfig configures cmd/voice-gateway: audio in over WebSocket or
+// WebRTC, VAD and ASR in the gateway, replies from soul-server or an
+// OpenAI-compatible model.
+type VoiceGatewayConfig struct {
+	HTTPAddr              string
+	UserID                string
+	TerminalID            string
+	SoulID                string
+	SessionPrefix         string
+	SoulAPIBaseURL        string
+	TerminalStatus        bool
+	ReplyMode             string
+	ReplyTimeout          time.Duration
+	LLMBaseURL            string
+	LLMAPIKey             string
+	LLMModel              string
+	LLMSystemPrompt       string
+	LLMHistoryTurns       int
+	ASRMode               string
+	ASRBridgeURL          string
+	ASRBridgeDialAttempts int
+	WhisperURL            string
+	WhisperModel          string
+	WhisperAPIKey         string
+	WhisperLanguage       string
+	FusionTimeout         time.Duration
+	FusionConfidence      float64
+	EmotionURL            string
+	EmotionTimeout        time.Duration
+	EmotionInterval       time.Duration
+	EmotionMaxCalls       int
+	EmotionMinRunes       int
+	EmotionMinIntensity   float64
+	EmotionConfirm        int
+	IntentPrefetch        bool
+	IntentInterval        time.Duration
+	IntentMaxCalls        int
+	IntentMinRunes        int
+	SpeakerEmbedURL       string
+	SpeakerTimeout        time.Duration
+	SpeakerMinSpeech      time.Duration
+	FollowUpWindow        time.Duration
+	BargeIn               bool
+	TTSMode               string
+	TTSURL                string
+	TTSAPIKey             string
+	TTSModel              string
+	TTSVoice              string
+	TTSCloneID            string
+	TTSSpeed              float64
+	TTSPersona            bool
+	TTSPolicyFile         string
+	TTSMinSentenceRunes   int
+	TTSMaxSentenceRunes   int
+	VADThresholdDB        float64
+	VADSilence            time.Duration
+	VADMinSpeech          time.Duration
+	VADPreRoll            time.Duration
+	VADMaxSegment         time.Duration
+	ICEUDPPort            int
+	ICEPublicIP           string
+	ICEServers            []string
+	ICEUsername           string
+	ICECredential         string
+	ICETransportPolicy    string
+	ICERestartGrace       time.Duration
+}
+
 func LoadSoulServerConfig() (SoulServerConfig, error) {
 	cfg := SoulServerConfig{
 		HTTPAddr:                     getenvDefault("SOUL_HTTP_ADDR", ":9010"),
 		UserID:                       getenvDefault("USER_ID", "demo-user"),
 		DBDSN:                        os.Getenv("DB_DSN"),
+		DBMaxConns:                   getenvIntDefault("DB_MAX_CONNS", 10),
+		DBMinConns:                   getenvIntDefault("DB_MIN_CONNS", 1),
+		DBHealthCheckPeriod:          time.Duration(getenvIntDefault("DB_HEALTH_CHECK_PERIOD_SECONDS", 30)) * time.Second,
+		DBMaxConnIdleTime:            time.Duration(getenvIntDefault("DB_MAX_CONN_IDLE_SECONDS", 300)) * time.Second,
+		DBStatementCacheCapacity:     getenvIntDefault("DB_STATEMENT_CACHE_CAPACITY", 256),
+		DBReadDSN:                    strings.TrimSpace(os.Getenv("DB_READ_DSN")),
+		DBReadMaxConns:               max(1, getenvIntDefault("DB_READ_MAX_CONNS", 10)),
+		DBReadMaxLag:                 time.Duration(max(0, getenvIntDefault("DB_READ_MAX_LAG_MS", 2000))) * time.Millisecond,
 		MQTTBrokerURL:                getenvDefault("MQTT_BROKER_URL", "tcp://localhost:1883"),
 		MQTTClientID:                 getenvDefault("SOUL_MQTT_CLIENT_ID", "soul-server"),
 		MQTTUsername:                 os.Getenv("MQTT_USERNAME"),
 		MQTTPassword:                 os.Getenv("MQTT_PASSWORD"),
 		MQTTTopicPrefix:              getenvDefault("MQTT_TOPIC_PREFIX", "soul"),
+		MQTTEmbeddedBroker:           getenvBoolDefault("MQTT_EMBEDDED_BROKER", false),
+		MQTTEmbeddedBrokerAddr:       getenvDefault("MQTT_EMBEDDED_BROKER_ADDR", ":1883"),
 		LLMProvider:                  getenvDefault("LLM_PROVIDER", "openai"),
 		LLMModel:                     getenvDefault("LLM_MODEL", "gpt-4o-mini"),
 		OpenAIBaseURL:                getenvDefault("OPENAI_BASE_URL", "https://api.openai.com/v1"),
 		OpenAIAPIKey:                 os.Getenv("OPENAI_API_KEY"),
 		AnthropicBaseURL:             getenvDefault("ANTHROPIC_BASE_URL", "https://api.anthropic.com"),
 		AnthropicAPIKey:              os.Getenv("ANTHROPIC_API_KEY"),
+		AnthropicMaxTokens:           getenvIntDefault("ANTHROPIC_MAX_TOKENS", 1024),
+		AnthropicThinkingBudget:      getenvIntDefault("ANTHROPIC_THINKING_BUDGET_TOKENS", 0),
+		AnthropicStream:              getenvBoolDefault("ANTHROPIC_STREAM", false),
+		LLMMockFixture:               os.Getenv("LLM_MOCK_FIXTURE"),
+		LLMRetryMaxAttempts:          getenvIntDefault("LLM_RETRY_MAX_ATTEMPTS", 3),
+		LLMRetryBaseDelay:            time.Duration(getenvIntDefault("LLM_RETRY_BASE_DELAY_MS", 300)) * time.Millisecond,
+		LLMRetryMaxDelay:             time.Duration(getenvIntDefault("LLM_RETRY_MAX_DELAY_MS", 5000)) * time.Millisecond,
+		LLMMaxIdleConns:              getenvIntDefault("LLM_MAX_IDLE_CONNS", 16),
+		LLMIdleConnTimeout:           time.Duration(getenvIntDefault("LLM_IDLE_CONN_TIMEOUT_SECONDS", 90)) * time.Second,
+		LLMDialTimeout:               time.Duration(getenvIntDefault("LLM_DIAL_TIMEOUT_MS", 5000)) * time.Millisecond,
+		LLMTLSTimeout:                time.Duration(getenvIntDefault("LLM_TLS_TIMEOUT_MS", 5000)) * time.Millisecond,
+		LLMHTTP2:                     getenvBoolDefault("LLM_HTTP2", true),
+		LLMWarmConns:                 max(0, getenvIntDefault("LLM_WARM_CONNS", 2)),
+		LLMEmbeddingModel:            getenvDefault("LLM_EMBEDDING_MODEL", "text-embedding-3-small"),
+		LLMEmbeddingDimensions:       getenvIntDefault("LLM_EMBEDDING_DIMENSIONS", 0),
+		LLMEmbeddingBatchSize:        getenvIntDefault("LLM_EMBEDDING_BATCH_SIZE", 64),
 		ToolTimeout:                  time.Duration(getenvIntDefault("TOOL_TIMEOUT_SECONDS", 8)) * time.Second,
 		ChatHistoryLimit:             getenvIntDefault("CHAT_HISTORY_LIMIT", 20),
 		SkillSnapshotTTL:             time.Duration(getenvIntDefault("SKILL_SNAPSHOT_TTL_SECONDS", 60)) * time.Second,
+		TerminalHeartbeatTimeout:     time.Duration(getenvIntDefault("TERMINAL_HEARTBEAT_TIMEOUT_SECONDS", 30)) * time.Second,
+		TerminalAlertWebhookURL:      strings.TrimSpace(os.Getenv("TERMINAL_ALERT_WEBHOOK_URL")),
+		TerminalAlertWebhookToken:    os.Getenv("TERMINAL_ALERT_WEBHOOK_TOKEN"),
+		BlobEndpoint:                 strings.TrimSpace(os.Getenv("BLOB_ENDPOINT")),
+		BlobRegion:                   getenvDefault("BLOB_REGION", "us-east-1"),
+		BlobBucket:                   getenvDefault("BLOB_BUCKET", "soul-media"),
+		BlobAccessKey:                os.Getenv("BLOB_ACCESS_KEY"),
+		BlobSecretKey:                os.Getenv("BLOB_SECRET_KEY"),
+		BlobPathStyle:                getenvBoolDefault("BLOB_PATH_STYLE", true),
+		BlobUploadTTL:                time.Duration(getenvIntDefault("BLOB_UPLOAD_TTL_SECONDS", 900)) * time.Second,
+		BlobMaxUploadBytes:           getenvInt64Default("BLOB_MAX_UPLOAD_BYTES", 10<<20),
+		BlobRetentionDays:            getenvIntDefault("BLOB_RETENTION_DAYS", 30),
+		SkillHTTPAllowedHosts:        splitList(os.Getenv("SKILL_HTTP_ALLOWED_HOSTS")),
+		SkillHTTPAllowHTTP:           getenvBoolDefault("SKILL_HTTP_ALLOW_HTTP", false),
+		SMTPHost:                     strings.TrimSpace(os.Getenv("SMTP_HOST")),
+		SMTPPort:                     getenvIntDefault("SMTP_PORT", 587),
+		SMTPUsername:                 os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:                 os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:                     strings.TrimSpace(os.Getenv("SMTP_FROM")),
+		SMTPTLS:                      getenvDefault("SMTP_TLS", "starttls"),
+		SMTPTimeout:                  time.Duration(getenvIntDefault("SMTP_TIMEOUT_SECONDS", 10)) * time.Second,
+		EmailAllowedRecipients:       parseRecipientLists(os.Getenv("EMAIL_ALLOWED_RECIPIENTS")),
+		ReminderScanInterval:         time.Duration(getenvIntDefault("REMINDER_SCAN_INTERVAL_SECONDS", 15)) * time.Second,
+		ReminderPushURL:              strings.TrimSpace(os.Getenv("REMINDER_PUSH_URL")),
+		ReminderPushToken:            os.Getenv("REMINDER_PUSH_TOKEN"),
+		RoutineScanInterval:          time.Duration(getenvIntDefault("ROUTINE_SCAN_INTERVAL_SECONDS", 15)) * time.Second,
+		QuietHoursAllowedSkills:      splitList(getenvDefault("QUIET_HOURS_ALLOWED_SKILLS", "set_reminder,create_alarm,create_routine")),
 		UserIdleTimeout:              time.Duration(getenvIntDefault("USER_IDLE_TIMEOUT_SECONDS", 180)) * time.Second,
 		IdleSummaryScanInterval:      time.Duration(getenvIntDefault("IDLE_SUMMARY_SCAN_INTERVAL_SECONDS", 15)) * time.Second,
 		SessionCompressMsgThreshold:  getenvIntDefault("SESSION_COMPRESS_MSG_THRESHOLD", 80),
 		SessionCompressCharThreshold: getenvIntDefault("SESSION_COMPRESS_CHAR_THRESHOLD", 12000),
 		SessionCompressScanLimit:     getenvIntDefault("SESSION_COMPRESS_SCAN_LIMIT", 200),
+		SessionRetentionDays:         getenvIntDefault("SESSION_RETENTION_DAYS", 0),
+		SessionArchiveMode:           strings.ToLower(strings.TrimSpace(getenvDefault("SESSION_ARCHIVE_MODE", "archive"))),
 		Mem0BaseURL:                  strings.TrimRight(getenvDefault("MEM0_BASE_URL", "http://localhost:8000"), "/"),
 		Mem0APIKey:                   os.Getenv("MEM0_API_KEY"),
 		Mem0Timeout:                  time.Duration(getenvIntDefault("MEM0_TIMEOUT_SECONDS", 5)) * time.Second,
 		Mem0AsyncQueueEnabled:        getenvBoolDefault("MEM0_ASYNC_QUEUE_ENABLED", true),
+		RedactEnabled:                getenvBoolDefault("REDACT_ENABLED", false),
+		RedactKinds:                  splitList(getenvDefault("REDACT_KINDS", "id_number,phone,address")),
+		RedactPatterns:               os.Getenv("REDACT_PATTERNS"),
+		SafetyBlocklist:              splitList(os.Getenv("SAFETY_BLOCKLIST")),
+		SafetyBlocklistFile:          strings.TrimSpace(os.Getenv("SAFETY_BLOCKLIST_FILE")),
+		SafetyModerationURL:          strings.TrimSpace(os.Getenv("SAFETY_MODERATION_URL")),
+		SafetyModerationAPIKey:       os.Getenv("SAFETY_MODERATION_API_KEY"),
+		SafetyModerationModel:        os.Getenv("SAFETY_MODERATION_MODEL"),
+		SafetyModerationTimeout:      time.Duration(getenvIntDefault("SAFETY_MODERATION_TIMEOUT_MS", 1500)) * time.Millisecond,
+		SafetyRefusalReply:           os.Getenv("SAFETY_REFUSAL_REPLY"),
+		ChildModeBlockedSkills:       splitList(getenvDefault("CHILD_MODE_BLOCKED_SKILLS", "send_email,create_routine")),
+		ChildModeMaxReplyRunes:       getenvIntDefault("CHILD_MODE_MAX_REPLY_RUNES", 60),
+		ChildModePIN:                 strings.TrimSpace(os.Getenv("CHILD_MODE_PIN")),
+		TopicTrackingEnabled:         getenvBoolDefault("TOPIC_TRACKING_ENABLED", true),
+		TopicKeywords:                os.Getenv("TOPIC_KEYWORDS"),
+		ReplyFilters:                 splitList(getenvDefault("REPLY_FILTERS", "trim,no_reply")),
+		ReplyScrubPhrases:            splitList(getenvDefault("REPLY_SCRUB_PHRASES", "作为一个AI,作为一个人工智能,作为AI语言模型,As an AI language model")),
+		SoulExemplarTokenBudget:      getenvIntDefault("SOUL_EXEMPLAR_TOKEN_BUDGET", 400),
+		GateBypassSkills:             splitList(getenvDefault("GATE_BYPASS_SKILLS", "stop_motion,emergency_stop")),
+		GateOverrideToken:            strings.TrimSpace(os.Getenv("GATE_OVERRIDE_TOKEN")),
+		PersonaConfigFile:            strings.TrimSpace(os.Getenv("PERSONA_CONFIG_FILE")),
+		EmotionTraceFile:             strings.TrimSpace(os.Getenv("EMOTION_TRACE_FILE")),
+		ReplaySampleRate:             getenvFloatDefault("REPLAY_SAMPLE_RATE", 0),
+		DiaryEnabled:                 getenvBoolDefault("DIARY_ENABLED", true),
+		DiaryHour:                    clampInt(getenvIntDefault("DIARY_HOUR", 22), 0, 23),
+		EmotionContagionEnabled:      getenvBoolDefault("EMOTION_CONTAGION_ENABLED", false),
+		EmotionContagionShare:        getenvFloatDefault("EMOTION_CONTAGION_SHARE", 0.3),
+		EmotionContagionMinShock:     getenvFloatDefault("EMOTION_CONTAGION_MIN_SHOCK", 0.3),
+		MoodAlertEnabled:             getenvBoolDefault("MOOD_ALERT_ENABLED", false),
+		MoodAlertDays:                clampInt(getenvIntDefault("MOOD_ALERT_DAYS", 3), 1, 14),
+		MoodAlertNegativeShare:       getenvFloatDefault("MOOD_ALERT_NEGATIVE_SHARE", 0.5),
+		MoodAlertWebhookURL:          strings.TrimSpace(os.Getenv("MOOD_ALERT_WEBHOOK_URL")),
+		MoodAlertWebhookToken:        os.Getenv("MOOD_ALERT_WEBHOOK_TOKEN"),
+		MemoryRecallCitations:        getenvBoolDefault("MEMORY_RECALL_CITATIONS", true),
+		MemoryContextCacheTTL:        time.Duration(getenvIntDefault("MEMORY_CONTEXT_CACHE_TTL_SECONDS", 300)) * time.Second,
 		EmotionBaseURL:               strings.TrimRight(getenvDefault("EMOTION_BASE_URL", "http://localhost:9012"), "/"),
 		EmotionTimeout:               time.Duration(getenvIntDefault("EMOTION_TIMEOUT_MS", 1500)) * time.Millisecond,
 		IntentFilterBaseURL:          strings.TrimRight(getenvDefault("INTENT_FILTER_BASE_URL", "http://localhost:9013"), "/"),
 		IntentFilterTimeout:          time.Duration(getenvIntDefault("INTENT_FILTER_TIMEOUT_MS", 1500)) * time.Millisecond,
+		IntentFilterAttempts:         clampInt(getenvIntDefault("INTENT_FILTER_ATTEMPTS", 2), 1, 5),
+		IntentFilterCacheTTL:         time.Duration(getenvIntDefault("INTENT_FILTER_CACHE_TTL_SECONDS", 30)) * time.Second,
+		IntentFilterLocalFallback:    getenvBoolDefault("INTENT_FILTER_LOCAL_FALLBACK", true),
 		EmotionTickInterval:          time.Duration(clampInt(getenvIntDefault("EMOTION_TICK_INTERVAL_SECONDS", 3), 2, 5)) * time.Second,
+		SpeakerMatchThreshold:        getenvFloatDefault("SPEAKER_MATCH_THRESHOLD", 0.75),
+		FollowUpWindow:               time.Duration(getenvIntDefault("FOLLOW_UP_WINDOW_SECONDS", 8)) * time.Second,
+		PresenceGreeting:             getenvBoolDefault("PRESENCE_GREETING", true),
+		PresenceGreetingCooldown:     time.Duration(getenvIntDefault("PRESENCE_GREETING_COOLDOWN_MINUTES", 30)) * time.Minute,
+		PresenceTTL:                  time.Duration(getenvIntDefault("PRESENCE_TTL_MINUTES", 30)) * time.Minute,
+		PowerIdleSleep:               time.Duration(getenvIntDefault("POWER_IDLE_SLEEP_MINUTES", 0)) * time.Minute,
+		PowerSleepStart:              getenvDefault("POWER_SLEEP_START", ""),
+		PowerSleepEnd:                getenvDefault("POWER_SLEEP_END", ""),
+		PowerAsleepDecayScale:        getenvFloatDefault("POWER_ASLEEP_DECAY_SCALE", 0.25),
+		IntentClarifyTTL:             time.Duration(getenvIntDefault("INTENT_CLARIFY_TTL_SECONDS", 60)) * time.Second,
+		IntentResultSessionNotes:     getenvBoolDefault("INTENT_RESULT_SESSION_NOTES", true),
+		KnowledgeEnabled:             getenvBoolDefault("KNOWLEDGE_ENABLED", false),
+		KnowledgeChunkRunes:          clampInt(getenvIntDefault("KNOWLEDGE_CHUNK_RUNES", 500), 100, 4000),
+		KnowledgeChunkOverlap:        getenvIntDefault("KNOWLEDGE_CHUNK_OVERLAP", 50),
+		KnowledgeMaxDocumentRunes:    getenvIntDefault("KNOWLEDGE_MAX_DOCUMENT_RUNES", 200000),
+		KnowledgeTopK:                clampInt(getenvIntDefault("KNOWLEDGE_TOP_K", 4), 1, 20),
+		KnowledgeMinScore:            getenvFloatDefault("KNOWLEDGE_MIN_SCORE", 0.3),
+		ToolOutputMaxRunes:           getenvIntDefault("TOOL_OUTPUT_MAX_RUNES", 2000),
+		ToolOutputLimits:             parseIntMap(os.Getenv("TOOL_OUTPUT_LIMITS")),
+		ToolOutputSummarize:          getenvBoolDefault("TOOL_OUTPUT_SUMMARIZE", true),
+		CriticMode:                   strings.ToLower(getenvDefault("CRITIC_MODE", "off")),
+		CriticModel:                  strings.TrimSpace(os.Getenv("CRITIC_MODEL")),
+		CriticTimeout:                time.Duration(getenvIntDefault("CRITIC_TIMEOUT_MS", 3000)) * time.Millisecond,
+		SpeculationConfirm:           getenvIntDefault("INTENT_SPECULATION_CONFIRM", 2),
+		SpeculationMinConfidence:     getenvFloatDefault("INTENT_SPECULATION_MIN_CONFIDENCE", 0.8),
+		SpeculationTTL:               time.Duration(getenvIntDefault("INTENT_SPECULATION_TTL_SECONDS", 10)) * time.Second,
+		LatencyBudget:                time.Duration(max(0, getenvIntDefault("LATENCY_BUDGET_MS", 10000))) * time.Millisecond,
+		LatencyBudgetEmotion:         time.Duration(max(0, getenvIntDefault("LATENCY_BUDGET_EMOTION_MS", 1500))) * time.Millisecond,
+		LatencyBudgetIntent:          time.Duration(max(0, getenvIntDefault("LATENCY_BUDGET_INTENT_MS", 1500))) * time.Millisecond,
+		LatencyBudgetMem0:            time.Duration(max(0, getenvIntDefault("LATENCY_BUDGET_MEM0_MS", 2500))) * time.Millisecond,
+		WriteBehindEnabled:           getenvBoolDefault("WRITE_BEHIND_ENABLED", true),
+		WriteBehindQueueSize:         max(1, getenvIntDefault("WRITE_BEHIND_QUEUE_SIZE", 1024)),
+		WriteBehindWorkers:           max(1, getenvIntDefault("WRITE_BEHIND_WORKERS", 2)),
+		SoulLocksEnabled:             getenvBoolDefault("SOUL_LOCKS_ENABLED", true),
 	}
 
 	if cfg.DBDSN == "" {
@@ -102,9 +424,170 @@ func LoadSoulServerConfig() (SoulServerConfig, error) {
 	if cfg.LLMProvider == "claude" && cfg.AnthropicAPIKey == "" {
 		return SoulServerConfig{}, fmt.Errorf("ANTHROPIC_API_KEY is required when LLM_PROVIDER=claude")
 	}
+	if cfg.AnthropicThinkingBudget > 0 && cfg.AnthropicThinkingBudget < 1024 {
+		return SoulServerConfig{}, fmt.Errorf("ANTHROPIC_THINKING_BUDGET_TOKENS must be 0 or at least 1024")
+	}
+	if cfg.LLMProvider == "mock" && cfg.LLMMockFixture == "" {
+		return SoulServerConfig{}, fmt.Errorf("LLM_MOCK_FIXTURE is required when LLM_PROVIDER=mock")
+	}
+	if cfg.SMTPHost != "" && cfg.SMTPFrom == "" {
+		return SoulServerConfig{}, fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+	}
+	if cfg.CriticMode != "off" && cfg.CriticMode != "risky" && cfg.CriticMode != "always" {
+		return SoulServerConfig{}, fmt.Errorf("CRITIC_MODE must be off, risky or always")
+	}
+	return cfg, nil
+}
+
+func LoadVoiceGatewayConfig() (VoiceGatewayConfig, error) {
+	cfg := VoiceGatewayConfig{
+		HTTPAddr:              getenvDefault("VOICE_HTTP_ADDR", ":9014"),
+		UserID:                getenvDefault("USER_ID", "demo-user"),
+		TerminalID:            getenvDefault("VOICE_TERMINAL_ID", "voice-gateway"),
+		SoulID:                os.Getenv("VOICE_SOUL_ID"),
+		SessionPrefix:         getenvDefault("VOICE_SESSION_PREFIX", "voice-"),
+		SoulAPIBaseURL:        strings.TrimRight(getenvDefault("SOUL_API_BASE_URL", "http://localhost:9010"), "/"),
+		TerminalStatus:        getenvBoolDefault("VOICE_TERMINAL_STATUS", true),
+		ReplyMode:             strings.ToLower(getenvDefault("VOICE_REPLY_MODE", "soul")),
+		ReplyTimeout:          time.Duration(getenvIntDefault("VOICE_REPLY_TIMEOUT_SECONDS", 30)) * time.Second,
+		LLMBaseURL:            strings.TrimRight(getenvDefault("OPENAI_BASE_URL", "https://api.openai.com/v1"), "/"),
+		LLMAPIKey:             os.Getenv("OPENAI_API_KEY"),
+		LLMModel:              getenvDefault("LLM_MODEL", "gpt-4o-mini"),
+		LLMSystemPrompt:       getenvDefault("VOICE_LLM_SYSTEM_PROMPT", "你是桌面机器人的语音助手，用简短口语化的中文回答。"),
+		LLMHistoryTurns:       getenvIntDefault("VOICE_LLM_HISTORY_TURNS", 8),
+		ASRMode:               strings.ToLower(getenvDefault("VOICE_ASR_MODE", "bridge")),
+		ASRBridgeURL:          getenvDefault("VOICE_ASR_BRIDGE_URL", "ws://localhost:2700/ws"),
+		ASRBridgeDialAttempts: getenvIntDefault("VOICE_ASR_BRIDGE_DIAL_ATTEMPTS", 3),
+		WhisperURL:            getenvDefault("VOICE_WHISPER_URL", "http://localhost:9000/v1/audio/transcriptions"),
+		WhisperModel:          getenvDefault("VOICE_WHISPER_MODEL", "whisper-1"),
+		WhisperAPIKey:         os.Getenv("VOICE_WHISPER_API_KEY"),
+		WhisperLanguage:       os.Getenv("VOICE_WHISPER_LANGUAGE"),
+		FusionTimeout:         time.Duration(getenvIntDefault("VOICE_FUSION_TIMEOUT_MS", 3000)) * time.Millisecond,
+		FusionConfidence:      getenvFloatDefault("VOICE_FUSION_DEFAULT_CONFIDENCE", 0.5),
+		EmotionURL:            strings.TrimRight(os.Getenv("VOICE_EMOTION_URL"), "/"),
+		EmotionTimeout:        time.Duration(getenvIntDefault("VOICE_EMOTION_TIMEOUT_MS", 800)) * time.Millisecond,
+		EmotionInterval:       time.Duration(getenvIntDefault("VOICE_EMOTION_INTERVAL_MS", 700)) * time.Millisecond,
+		EmotionMaxCalls:       getenvIntDefault("VOICE_EMOTION_MAX_CALLS", 4),
+		EmotionMinRunes:       getenvIntDefault("VOICE_EMOTION_MIN_RUNES", 4),
+		EmotionMinIntensity:   getenvFloatDefault("VOICE_EMOTION_MIN_INTENSITY", 0.4),
+		EmotionConfirm:        max(1, getenvIntDefault("VOICE_EMOTION_CONFIRM", 2)),
+		IntentPrefetch:        getenvBoolDefault("VOICE_INTENT_PREFETCH", true),
+		IntentInterval:        time.Duration(getenvIntDefault("VOICE_INTENT_INTERVAL_MS", 250)) * time.Millisecond,
+		IntentMaxCalls:        getenvIntDefault("VOICE_INTENT_MAX_CALLS", 8),
+		IntentMinRunes:        getenvIntDefault("VOICE_INTENT_MIN_RUNES", 2),
+		SpeakerEmbedURL:       strings.TrimSpace(os.Getenv("VOICE_SPEAKER_EMBED_URL")),
+		SpeakerTimeout:        time.Duration(getenvIntDefault("VOICE_SPEAKER_TIMEOUT_MS", 800)) * time.Millisecond,
+		SpeakerMinSpeech:      time.Duration(getenvIntDefault("VOICE_SPEAKER_MIN_SPEECH_MS", 800)) * time.Millisecond,
+		FollowUpWindow:        time.Duration(getenvIntDefault("VOICE_FOLLOW_UP_SECONDS", 8)) * time.Second,
+		BargeIn:               getenvBoolDefault("VOICE_BARGE_IN", false),
+		TTSMode:               strings.ToLower(getenvDefault("VOICE_TTS_MODE", "off")),
+		TTSURL:                strings.TrimRight(os.Getenv("VOICE_TTS_URL"), "/"),
+		TTSAPIKey:             getenvDefault("VOICE_TTS_API_KEY", os.Getenv("OPENAI_API_KEY")),
+		TTSModel:              getenvDefault("VOICE_TTS_MODEL", "tts-1"),
+		TTSVoice:              os.Getenv("VOICE_TTS_VOICE"),
+		TTSCloneID:            os.Getenv("VOICE_TTS_CLONE_ID"),
+		TTSSpeed:              getenvFloatDefault("VOICE_TTS_SPEED", 1),
+		TTSPersona:            getenvBoolDefault("VOICE_TTS_PERSONA", true),
+		TTSPolicyFile:         os.Getenv("VOICE_TTS_POLICY_FILE"),
+		TTSMinSentenceRunes:   getenvIntDefault("VOICE_TTS_MIN_SENTENCE_RUNES", 4),
+		TTSMaxSentenceRunes:   getenvIntDefault("VOICE_TTS_MAX_SENTENCE_RUNES", 60),
+		VADThresholdDB:        getenvFloatDefault("VOICE_VAD_THRESHOLD_DB", -45),
+		VADSilence:            time.Duration(getenvIntDefault("VOICE_VAD_SILENCE_MS", 700)) * time.Millisecond,
+		VADMinSpeech:          time.Duration(getenvIntDefault("VOICE_VAD_MIN_SPEECH_MS", 120)) * time.Millisecond,
+		VADPreRoll:            time.Duration(getenvIntDefault("VOICE_VAD_PRE_ROLL_MS", 200)) * time.Millisecond,
+		VADMaxSegment:         time.Duration(getenvIntDefault("VOICE_VAD_MAX_SEGMENT_MS", 30000)) * time.Millisecond,
+		ICEUDPPort:            getenvIntDefault("VOICE_ICE_UDP_PORT", 19000),
+		ICEPublicIP:           os.Getenv("VOICE_ICE_PUBLIC_IP"),
+		ICEServers:            splitList(os.Getenv("VOICE_ICE_SERVERS")),
+		ICEUsername:           os.Getenv("VOICE_ICE_USERNAME"),
+		ICECredential:         os.Getenv("VOICE_ICE_CREDENTIAL"),
+		ICETransportPolicy:    strings.ToLower(getenvDefault("VOICE_ICE_TRANSPORT_POLICY", "all")),
+		ICERestartGrace:       time.Duration(getenvIntDefault("VOICE_ICE_RESTART_GRACE_SECONDS", 20)) * time.Second,
+	}
+
+	switch cfg.ReplyMode {
+	case "soul":
+	case "openai":
+		if cfg.LLMAPIKey == "" {
+			return VoiceGatewayConfig{}, fmt.Errorf("OPENAI_API_KEY is required when VOICE_REPLY_MODE=openai")
+		}
+	default:
+		return VoiceGatewayConfig{}, fmt.Errorf("unsupported VOICE_REPLY_MODE: %s", cfg.ReplyMode)
+	}
+	switch cfg.ASRMode {
+	case "bridge", "mock":
+	case "whisper", "fusion":
+		if cfg.WhisperURL == "" {
+			return VoiceGatewayConfig{}, fmt.Errorf("VOICE_WHISPER_URL is required when VOICE_ASR_MODE=%s", cfg.ASRMode)
+		}
+	default:
+		return VoiceGatewayConfig{}, fmt.Errorf("unsupported VOICE_ASR_MODE: %s", cfg.ASRMode)
+	}
+	if (cfg.ASRMode == "bridge" || cfg.ASRMode == "fusion") && cfg.ASRBridgeURL == "" {
+		return VoiceGatewayConfig{}, fmt.Errorf("VOICE_ASR_BRIDGE_URL is required when VOICE_ASR_MODE=%s", cfg.ASRMode)
+	}
+	switch cfg.TTSMode {
+	case "off", "mock":
+	case "cosyvoice":
+		if cfg.TTSURL == "" {
+			return VoiceGatewayConfig{}, fmt.Errorf("VOICE_TTS_URL is required when VOICE_TTS_MODE=cosyvoice")
+		}
+	case "openai":
+		if cfg.TTSAPIKey == "" {
+			return VoiceGatewayConfig{}, fmt.Errorf("VOICE_TTS_API_KEY or OPENAI_API_KEY is required when VOICE_TTS_MODE=openai")
+		}
+		if cfg.TTSURL == "" {
+			cfg.TTSURL = cfg.LLMBaseURL
+		}
+	default:
+		return VoiceGatewayConfig{}, fmt.Errorf("unsupported VOICE_TTS_MODE: %s", cfg.TTSMode)
+	}
+	if cfg.ICETransportPolicy != "all" && cfg.ICETransportPolicy != "relay" {
+		return VoiceGatewayConfig{}, fmt.Errorf("VOICE_ICE_TRANSPORT_POLICY must be all or relay")
+	}
 	return cfg, nil
 }
 
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseRecipientLists reads "user_a=a@x.com,@y.com;user_b=b@x.com" into a
+// per-user list.
+func parseRecipientLists(v string) map[string][]string {
+	out := make(map[string][]string)
+	for _, entry := range strings.Split(v, ";") {
+		user, addrs, ok := strings.Cut(entry, "=")
+		if user = strings.TrimSpace(user); !ok || user == "" {
+			continue
+		}
+		out[user] = append(out[user], splitList(addrs)...)
+	}
+	return out
+}
+
+// parseIntMap reads "a=1,b=2"; entries without a valid integer are
+// skipped.
+func parseIntMap(v string) map[string]int {
+	out := make(map[string]int)
+	for _, entry := range splitList(v) {
+		key, val, ok := strings.Cut(entry, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(val)); err == nil {
+			out[key] = n
+		}
+	}
+	return out
+}
+
 func clampInt(v, lo, hi int) int {
 	if v < lo {
 		return lo
@@ -163,6 +646,18 @@ func getenvInt64Default(key string, val int64) int64 {
 	return n
 }
 
+func getenvFloatDefault(key string, val float64) float64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return val
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return val
+	}
+	return n
+}
+
 func getenvBoolDefault(key string, val bool) bool {
 	v := strings.TrimSpace(strings.ToLower(os.Getenv(key)))
 	if v == "" {
@@ -0,0 +1,319 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// BackupFormat names the layout Backup writes: a header line, then one
+// JSON line per row.
+const BackupFormat = "soul-backup/1"
+
+// backupTable is a table Backup covers; key is the column restore matches
+// existing rows on.
+type backupTable struct {
+	name string
+	key  string
+	// unique is a further unique key. RestoreOverwrite removes an existing
+	// row that holds a backed up row's values for it under another key, so
+	// only tables no other row points at may set it.
+	unique []string
+}
+
+// backupTables lists what a backup holds, parents before children so a
+// restore satisfies the foreign keys as it goes. messages_archive is left
+// out: archived sessions come back with their summary and episodes only.
+var backupTables = []backupTable{
+	{name: "users", key: "user_id"},
+	{name: "souls", key: "soul_id"},
+	{name: "sessions", key: "session_id"},
+	{name: "messages", key: "id"},
+	{name: "memory_episode", key: "id"},
+	// Speaker profiles keep a relation_uuid as plain text, so a relation
+	// replaced by the backed up one under the same appellation leaves them
+	// unmatched rather than broken.
+	{name: "soul_user_relations", key: "relation_uuid", unique: []string{"soul_id", "appellation"}},
+}
+
+// BackupTables returns the tables a backup holds, in restore order.
+func BackupTables() []string {
+	out := make([]string, 0, len(backupTables))
+	for _, t := range backupTables {
+		out = append(out, t.name)
+	}
+	return out
+}
+
+// BackupHeader is the first line of a backup.
+type BackupHeader struct {
+	Format    string    `json:"format"`
+	CreatedAt time.Time `json:"created_at"`
+	Tables    []string  `json:"tables"`
+}
+
+type backupRow struct {
+	Table string          `json:"table"`
+	Row   json.RawMessage `json:"row"`
+}
+
+// RestoreConflict says what Restore does with a row whose key already
+// exists.
+type RestoreConflict string
+
+const (
+	// RestoreSkip keeps the existing row.
+	RestoreSkip RestoreConflict = "skip"
+	// RestoreOverwrite replaces it with the backed up row.
+	RestoreOverwrite RestoreConflict = "overwrite"
+	// RestoreFail aborts the restore, leaving the database as it was.
+	RestoreFail RestoreConflict = "fail"
+)
+
+// RestoreResult counts what Restore did, per table.
+type RestoreResult struct {
+	// Restored is the rows inserted or, with RestoreOverwrite, updated.
+	Restored map[string]int
+	// Skipped is the backed up rows left out because an existing row held
+	// their key or another unique value. Messages and episodes keep their
+	// ids, so a skipped one may be an unrelated row that took the same id.
+	Skipped map[string]int
+	// Removed is the existing rows RestoreOverwrite deleted because they
+	// held a backed up row's unique values under another key.
+	Removed map[string]int
+}
+
+// ParseRestoreConflict accepts skip, overwrite and fail.
+func ParseRestoreConflict(s string) (RestoreConflict, error) {
+	switch c := RestoreConflict(strings.ToLower(strings.TrimSpace(s))); c {
+	case RestoreSkip, RestoreOverwrite, RestoreFail:
+		return c, nil
+	}
+	return "", fmt.Errorf("unknown conflict strategy %q: want skip, overwrite or fail", s)
+}
+
+// Backup writes users, souls, sessions, messages, episodes and relations
+// to w as they stood at one instant: every table is read in the same
+// repeatable-read snapshot while chats go on. Rows are written whole, so
+// columns added later are carried without changes here. It returns the
+// rows written per table.
+func (s *Store) Backup(ctx context.Context, w io.Writer) (map[string]int, error) {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(BackupHeader{Format: BackupFormat, CreatedAt: time.Now().UTC(), Tables: BackupTables()}); err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(backupTables))
+	err := pgx.BeginTxFunc(ctx, s.pool, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		for _, t := range backupTables {
+			rows, err := tx.Query(ctx, `SELECT to_jsonb(t) FROM `+t.name+` t ORDER BY `+t.key)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var raw []byte
+				if err := rows.Scan(&raw); err != nil {
+					rows.Close()
+					return err
+				}
+				if err := enc.Encode(backupRow{Table: t.name, Row: raw}); err != nil {
+					rows.Close()
+					return err
+				}
+				counts[t.name]++
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return fmt.Errorf("back up %s: %w", t.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// restoreBatchSize is how many rows Restore sends per round trip.
+const restoreBatchSize = 500
+
+// Restore loads a backup written by Backup in one transaction, so a failed
+// restore changes nothing. Messages and episodes keep their ids, and the id
+// sequences are moved past them; other tables match on their natural key
+// and get fresh ids. Columns the backup lacks take their defaults and
+// columns this schema no longer has are dropped.
+func (s *Store) Restore(ctx context.Context, r io.Reader, conflict RestoreConflict) (RestoreResult, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	var header BackupHeader
+	if err := dec.Decode(&header); err != nil {
+		return RestoreResult{}, fmt.Errorf("read backup header: %w", err)
+	}
+	if header.Format != BackupFormat {
+		return RestoreResult{}, fmt.Errorf("unsupported backup format %q", header.Format)
+	}
+
+	res := RestoreResult{
+		Restored: make(map[string]int, len(backupTables)),
+		Skipped:  make(map[string]int, len(backupTables)),
+		Removed:  make(map[string]int, len(backupTables)),
+	}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		columns := make(map[string][]string, len(backupTables))
+		for _, t := range backupTables {
+			cols, err := tableColumns(ctx, tx, t.name)
+			if err != nil {
+				return err
+			}
+			columns[t.name] = cols
+		}
+
+		// ops says, per queued statement, which table it restores and
+		// whether it is a row's eviction rather than its insert.
+		type op struct {
+			table string
+			evict bool
+		}
+		batch := &pgx.Batch{}
+		var ops []op
+		flush := func() error {
+			if batch.Len() == 0 {
+				return nil
+			}
+			results := tx.SendBatch(ctx, batch)
+			for _, o := range ops {
+				tag, err := results.Exec()
+				if err != nil {
+					results.Close()
+					return fmt.Errorf("restore %s: %w", o.table, err)
+				}
+				n := int(tag.RowsAffected())
+				switch {
+				case o.evict:
+					res.Removed[o.table] += n
+				case n == 0:
+					res.Skipped[o.table]++
+				default:
+					res.Restored[o.table] += n
+				}
+			}
+			batch, ops = &pgx.Batch{}, ops[:0]
+			return results.Close()
+		}
+
+		for {
+			var row backupRow
+			if err := dec.Decode(&row); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return fmt.Errorf("read backup: %w", err)
+			}
+			i := slices.IndexFunc(backupTables, func(t backupTable) bool { return t.name == row.Table })
+			if i < 0 {
+				return fmt.Errorf("backup holds unknown table %q", row.Table)
+			}
+			query, err := restoreQuery(backupTables[i], columns[row.Table], row.Row, conflict)
+			if err != nil {
+				return err
+			}
+			if evict := evictQuery(backupTables[i]); evict != "" && conflict == RestoreOverwrite {
+				batch.Queue(evict, string(row.Row))
+				ops = append(ops, op{table: row.Table, evict: true})
+			}
+			batch.Queue(query, string(row.Row))
+			ops = append(ops, op{table: row.Table})
+			if batch.Len() >= restoreBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		for _, t := range backupTables {
+			if t.key != "id" {
+				continue
+			}
+			if _, err := tx.Exec(ctx, `SELECT setval(pg_get_serial_sequence($1, 'id'), GREATEST((SELECT COALESCE(MAX(id), 0) FROM `+t.name+`), 1))`, t.name); err != nil {
+				return fmt.Errorf("reset %s id sequence: %w", t.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	return res, nil
+}
+
+func tableColumns(ctx context.Context, tx pgx.Tx, table string) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// restoreQuery builds the insert of one backed up row, taking the columns
+// both the row and the table have. A serial id that is not the key is left
+// to the sequence, so it cannot clash with rows already there.
+func restoreQuery(t backupTable, tableCols []string, raw json.RawMessage, conflict RestoreConflict) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return "", fmt.Errorf("restore %s: %w", t.name, err)
+	}
+	var cols, updates []string
+	for _, c := range tableCols {
+		if _, ok := fields[c]; !ok || (c == "id" && t.key != "id") {
+			continue
+		}
+		quoted := pgx.Identifier{c}.Sanitize()
+		cols = append(cols, quoted)
+		if c != t.key {
+			updates = append(updates, quoted+"=EXCLUDED."+quoted)
+		}
+	}
+	if !slices.Contains(cols, pgx.Identifier{t.key}.Sanitize()) {
+		return "", fmt.Errorf("restore %s: row has no %s", t.name, t.key)
+	}
+	list := strings.Join(cols, ", ")
+	query := `INSERT INTO ` + t.name + ` (` + list + `) SELECT ` + list + ` FROM jsonb_populate_record(NULL::` + t.name + `, $1::jsonb)`
+	switch {
+	case conflict == RestoreOverwrite && len(updates) > 0:
+		query += ` ON CONFLICT (` + t.key + `) DO UPDATE SET ` + strings.Join(updates, ", ")
+	case conflict != RestoreFail:
+		query += ` ON CONFLICT DO NOTHING`
+	}
+	return query, nil
+}
+
+// evictQuery deletes the row holding a backed up row's unique values under
+// another key, so the restored row can take them; empty for tables without
+// a further unique key.
+func evictQuery(t backupTable) string {
+	if len(t.unique) == 0 {
+		return ""
+	}
+	conds := make([]string, 0, len(t.unique)+1)
+	for _, c := range t.unique {
+		quoted := pgx.Identifier{c}.Sanitize()
+		conds = append(conds, "cur."+quoted+" = r."+quoted)
+	}
+	key := pgx.Identifier{t.key}.Sanitize()
+	conds = append(conds, "cur."+key+" <> r."+key)
+	return `DELETE FROM ` + t.name + ` cur USING jsonb_populate_record(NULL::` + t.name + `, $1::jsonb) r WHERE ` + strings.Join(conds, " AND ")
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"soul/internal/domain"
+)
+
+func TestRestoreQuery(t *testing.T) {
+	users := backupTable{name: "users", key: "user_id"}
+	cols := []string{"id", "user_id", "display_name", "language"}
+	row := json.RawMessage(`{"id": 7, "user_id": "u1", "display_name": "小明", "dropped_column": 1}`)
+
+	skip, err := restoreQuery(users, cols, row, RestoreSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(skip, `INSERT INTO users ("user_id", "display_name") SELECT "user_id", "display_name" FROM jsonb_populate_record(NULL::users, $1::jsonb)`) ||
+		!strings.HasSuffix(skip, "ON CONFLICT DO NOTHING") {
+		t.Fatalf("skip query must leave out the serial id, missing and unknown columns: %s", skip)
+	}
+	over, _ := restoreQuery(users, cols, row, RestoreOverwrite)
+	if !strings.HasSuffix(over, `ON CONFLICT (user_id) DO UPDATE SET "display_name"=EXCLUDED."display_name"`) {
+		t.Fatalf("unexpected overwrite query: %s", over)
+	}
+	fail, _ := restoreQuery(users, cols, row, RestoreFail)
+	if strings.Contains(fail, "ON CONFLICT") {
+		t.Fatalf("fail must not absorb conflicts: %s", fail)
+	}
+
+	msg, _ := restoreQuery(backupTable{name: "messages", key: "id"}, []string{"id", "content"}, json.RawMessage(`{"id": 3, "content": "hi"}`), RestoreSkip)
+	if !strings.Contains(msg, `("id", "content")`) {
+		t.Fatalf("messages must keep their ids: %s", msg)
+	}
+	if got := evictQuery(backupTables[len(backupTables)-1]); got != `DELETE FROM soul_user_relations cur USING jsonb_populate_record(NULL::soul_user_relations, $1::jsonb) r WHERE cur."soul_id" = r."soul_id" AND cur."appellation" = r."appellation" AND cur."relation_uuid" <> r."relation_uuid"` {
+		t.Fatalf("unexpected evict query: %s", got)
+	}
+	if got := evictQuery(users); got != "" {
+		t.Fatalf("users have no further unique key to evict on: %s", got)
+	}
+	if _, err := restoreQuery(users, cols, json.RawMessage(`{"display_name": "x"}`), RestoreSkip); err == nil {
+		t.Fatal("a row without its key must be rejected")
+	}
+	if _, err := ParseRestoreConflict("merge"); err == nil {
+		t.Fatal("unknown strategies must be rejected")
+	}
+}
+
+// TestBackupRestore runs against a real database:
+//
+//	SOUL_TEST_DB_DSN=postgres://... go test ./internal/db -run BackupRestore
+func TestBackupRestore(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	store, err := New(ctx, dsn, PoolOptions{MaxConns: 2})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	userID := "it_" + uuid.NewString()[:8]
+	t.Cleanup(func() {
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM souls WHERE user_id=$1`, userID)
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM users WHERE user_id=$1`, userID)
+	})
+	if _, err := store.CreateUser(ctx, userID, "before", ""); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	soul, err := store.CreateSoulProfile(ctx, userID, "it_"+uuid.NewString()[:8], "INFP", domain.PersonalityVector{}, domain.SoulEmotionState{}, "persona-pad-v2")
+	if err != nil {
+		t.Fatalf("create soul: %v", err)
+	}
+	mom, err := store.CreateSoulUserRelation(ctx, soul.SoulID, domain.CreateSoulUserRelationPayload{Appellation: "妈妈", RelationToOwner: "母亲"})
+	if err != nil {
+		t.Fatalf("create relation: %v", err)
+	}
+	var backup bytes.Buffer
+	counts, err := store.Backup(ctx, &backup)
+	if err != nil || counts["users"] == 0 {
+		t.Fatalf("backup: %v %v", counts, err)
+	}
+	if _, err := store.pool.Exec(ctx, `UPDATE users SET display_name='after' WHERE user_id=$1`, userID); err != nil {
+		t.Fatal(err)
+	}
+	// The appellation is taken again under a fresh relation_uuid.
+	if _, err := store.pool.Exec(ctx, `DELETE FROM soul_user_relations WHERE relation_uuid=$1`, mom.RelationUUID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateSoulUserRelation(ctx, soul.SoulID, domain.CreateSoulUserRelationPayload{Appellation: "妈妈", RelationToOwner: "继母"}); err != nil {
+		t.Fatal(err)
+	}
+	relationOf := func() domain.SoulUserRelation {
+		t.Helper()
+		relations, err := store.ListSoulUserRelations(ctx, soul.SoulID)
+		if err != nil || len(relations) != 1 {
+			t.Fatalf("relations: %+v %v", relations, err)
+		}
+		return relations[0]
+	}
+
+	if _, err := store.Restore(ctx, bytes.NewReader(backup.Bytes()), RestoreFail); err == nil {
+		t.Fatal("fail must abort on existing rows")
+	}
+	skipped, err := store.Restore(ctx, bytes.NewReader(backup.Bytes()), RestoreSkip)
+	if err != nil {
+		t.Fatalf("skip: %v", err)
+	}
+	if got, _ := store.GetUserByID(ctx, userID); got.DisplayName != "after" {
+		t.Fatalf("skip must keep the existing row, got %+v", got)
+	}
+	if got := relationOf(); got.RelationUUID == mom.RelationUUID || got.RelationToOwner != "继母" {
+		t.Fatalf("skip must keep the existing relation, got %+v", got)
+	}
+	if skipped.Skipped["users"] == 0 || skipped.Skipped["soul_user_relations"] == 0 {
+		t.Fatalf("skip must report the rows it left out: %+v", skipped)
+	}
+	over, err := store.Restore(ctx, bytes.NewReader(backup.Bytes()), RestoreOverwrite)
+	if err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	if got, _ := store.GetUserByID(ctx, userID); got.DisplayName != "before" {
+		t.Fatalf("overwrite must restore the backed up row, got %+v", got)
+	}
+	if got := relationOf(); got.RelationUUID != mom.RelationUUID || got.RelationToOwner != "母亲" {
+		t.Fatalf("overwrite must replace the relation holding the appellation, got %+v", got)
+	}
+	if over.Removed["soul_user_relations"] == 0 || over.Skipped["users"] != 0 {
+		t.Fatalf("unexpected overwrite result: %+v", over)
+	}
+}
@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReplicaOptions configures the read replica heavy reads go to.
+type ReplicaOptions struct {
+	Pool PoolOptions
+	// MaxLag is the staleness replica reads accept unless their context
+	// says otherwise (see WithStaleness); a replica further behind, or
+	// whose lag is unknown, is skipped for the primary.
+	MaxLag time.Duration
+}
+
+type replica struct {
+	pool   *pgxpool.Pool
+	maxLag time.Duration
+	// lag is the last measured replay lag in nanoseconds, -1 while unknown.
+	lag atomic.Int64
+}
+
+// AttachReplica connects the read replica at dsn. History, export, episode,
+// activity, replay and knowledge reads use it from then on; everything
+// else, and every write, stays on the primary.
+func (s *Store) AttachReplica(ctx context.Context, dsn string, opts ReplicaOptions) error {
+	pool, err := newPool(ctx, dsn, opts.Pool)
+	if err != nil {
+		return err
+	}
+	r := &replica{pool: pool, maxLag: opts.MaxLag}
+	r.lag.Store(-1)
+	r.check(ctx)
+	s.replica = r
+	return nil
+}
+
+// RunReplicaLagCheck measures the replica's lag every interval until ctx
+// ends.
+func (s *Store) RunReplicaLagCheck(ctx context.Context, interval time.Duration) {
+	if s.replica == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.replica.check(ctx)
+		}
+	}
+}
+
+// ReplicaLag is the replica's last measured lag; ok is false without a
+// replica or while its lag is unknown.
+func (s *Store) ReplicaLag() (lag time.Duration, ok bool) {
+	if s.replica == nil {
+		return 0, false
+	}
+	n := s.replica.lag.Load()
+	return time.Duration(n), n >= 0
+}
+
+// check stores the replay lag, zero once the replica has replayed all it
+// received: an idle primary writes nothing, so the age of the last replayed
+// transaction alone would overstate it.
+func (r *replica) check(ctx context.Context) {
+	var seconds float64
+	err := r.pool.QueryRow(ctx, `
+		SELECT CASE
+			WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END
+	`).Scan(&seconds)
+	if err != nil {
+		r.lag.Store(-1)
+		return
+	}
+	r.lag.Store(int64(seconds * float64(time.Second)))
+}
+
+type stalenessKey struct{}
+
+// WithStaleness sets how stale the replica may be for reads under ctx;
+// zero sends them to the primary, for callers that must see their own
+// writes.
+func WithStaleness(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, stalenessKey{}, d)
+}
+
+// reader is the pool for a read that tolerates replica lag.
+func (s *Store) reader(ctx context.Context) *pgxpool.Pool {
+	r := s.replica
+	if r == nil {
+		return s.pool
+	}
+	tolerance := r.maxLag
+	if d, ok := ctx.Value(stalenessKey{}).(time.Duration); ok {
+		tolerance = d
+	}
+	lag := r.lag.Load()
+	if tolerance <= 0 || lag < 0 || time.Duration(lag) > tolerance {
+		return s.pool
+	}
+	return r.pool
+}
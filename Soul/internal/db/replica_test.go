@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestReaderRoutesByLag(t *testing.T) {
+	primary, standby := &pgxpool.Pool{}, &pgxpool.Pool{}
+	s := &Store{pool: primary}
+	ctx := context.Background()
+	if s.reader(ctx) != primary {
+		t.Fatal("without a replica reads go to the primary")
+	}
+
+	s.replica = &replica{pool: standby, maxLag: 2 * time.Second}
+	s.replica.lag.Store(-1)
+	if s.reader(ctx) != primary {
+		t.Fatal("an unknown lag must read from the primary")
+	}
+	s.replica.lag.Store(int64(time.Second))
+	if s.reader(ctx) != standby {
+		t.Fatal("a replica within MaxLag serves reads")
+	}
+	if s.reader(WithStaleness(ctx, 0)) != primary {
+		t.Fatal("zero staleness must read from the primary")
+	}
+	if s.reader(WithStaleness(ctx, 500*time.Millisecond)) != primary {
+		t.Fatal("a replica behind the context's tolerance must be skipped")
+	}
+	s.replica.lag.Store(int64(3 * time.Second))
+	if s.reader(ctx) != primary {
+		t.Fatal("a replica behind MaxLag must be skipped")
+	}
+	if s.reader(WithStaleness(ctx, 5*time.Second)) != standby {
+		t.Fatal("a looser context tolerance must allow the lagging replica")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,12 +17,35 @@ import (
 )
 
 var (
-	ErrSoulNotFound          = errors.New("soul not found")
-	ErrSoulSelectionRequired = errors.New("soul selection is required before chat")
+	ErrSoulNotFound              = errors.New("soul not found")
+	ErrSoulSelectionRequired     = errors.New("soul selection is required before chat")
+	ErrSessionNotFound           = errors.New("session not found")
+	ErrSessionExists             = errors.New("session already exists")
+	ErrMessageNotFound           = errors.New("message not found in session")
+	ErrSkillBundleNotFound       = errors.New("skill bundle not found")
+	ErrReminderNotFound          = errors.New("reminder not found")
+	ErrRoutineNotFound           = errors.New("routine not found")
+	ErrQuietHoursNotFound        = errors.New("quiet hours not found")
+	ErrUserNotFound              = errors.New("user not found")
+	ErrReplyFiltersNotFound      = errors.New("reply filters not found")
+	ErrExemplarNotFound          = errors.New("exemplar not found")
+	ErrSoulGroupNotFound         = errors.New("soul group not found")
+	ErrKnowledgeDocumentNotFound = errors.New("knowledge document not found")
+	ErrDiaryEntryNotFound        = errors.New("diary entry not found")
+	ErrMoodAlertExists           = errors.New("mood alert already recorded")
+	ErrEpisodeNotFound           = errors.New("memory episode not found")
+	ErrDesiredStateNotFound      = errors.New("desired state not found")
+	ErrRequirementNotFound       = errors.New("version requirement not found")
+	ErrConfigPushNotFound        = errors.New("config push not found")
+	ErrTurnReplayNotFound        = errors.New("turn replay not found")
+	ErrSoulEmotionConflict       = errors.New("soul emotion state changed concurrently")
+	ErrSpeakerNotFound           = errors.New("speaker profile not found")
 )
 
 type Store struct {
 	pool *pgxpool.Pool
+	// replica serves heavy reads when attached; see reader.
+	replica *replica
 }
 
 type MessageChunk struct {
@@ -46,18 +70,105 @@ type IdleSession struct {
 	TerminalID       string
 	SoulID           string
 	LastUserActiveAt time.Time
+	// Forked sessions are replays and must not feed long-term memory.
+	Forked bool
+	// Private sessions asked not to be remembered.
+	Private bool
 }
 
-func New(ctx context.Context, dsn string) (*Store, error) {
-	pool, err := pgxpool.New(ctx, dsn)
+// PoolOptions tunes the pgx connection pool; zero values keep pgx defaults.
+type PoolOptions struct {
+	MaxConns          int32
+	MinConns          int32
+	HealthCheckPeriod time.Duration
+	MaxConnIdleTime   time.Duration
+	// StatementCacheCapacity sizes the per-connection prepared statement
+	// cache used for every query issued by the store.
+	StatementCacheCapacity int
+}
+
+func New(ctx context.Context, dsn string, opts PoolOptions) (*Store, error) {
+	pool, err := newPool(ctx, dsn, opts)
 	if err != nil {
 		return nil, err
 	}
 	return &Store{pool: pool}, nil
 }
 
+func newPool(ctx context.Context, dsn string, opts PoolOptions) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxConns > 0 {
+		cfg.MaxConns = opts.MaxConns
+	}
+	if opts.MinConns > 0 {
+		cfg.MinConns = min(opts.MinConns, cfg.MaxConns)
+	}
+	if opts.HealthCheckPeriod > 0 {
+		cfg.HealthCheckPeriod = opts.HealthCheckPeriod
+	}
+	if opts.MaxConnIdleTime > 0 {
+		cfg.MaxConnIdleTime = opts.MaxConnIdleTime
+	}
+	cfg.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+	if opts.StatementCacheCapacity > 0 {
+		cfg.ConnConfig.StatementCacheCapacity = opts.StatementCacheCapacity
+	}
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
 func (s *Store) Close() {
 	s.pool.Close()
+	if s.replica != nil {
+		s.replica.pool.Close()
+	}
+}
+
+// Lock takes the Postgres advisory lock named key, shared by every
+// soul-server on the database, and returns the function that releases it.
+// With wait false it gives up at once, returning ok false, when another
+// session holds the lock; with wait true it blocks until ctx ends. The lock
+// lives on a pooled connection held until release, so it also goes away if
+// the process or the connection dies.
+func (s *Store) Lock(ctx context.Context, key string, wait bool) (release func(), ok bool, err error) {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if wait {
+		_, err = conn.Exec(ctx, `SELECT pg_advisory_lock(hashtextextended($1, 0))`, key)
+		ok = err == nil
+	} else {
+		err = conn.QueryRow(ctx, `SELECT pg_try_advisory_lock(hashtextextended($1, 0))`, key).Scan(&ok)
+	}
+	if err != nil || !ok {
+		conn.Release()
+		return nil, false, err
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.Exec(ctx, `SELECT pg_advisory_unlock(hashtextextended($1, 0))`, key); err != nil {
+			// Closing the connection is the only other way to drop the lock.
+			_ = conn.Conn().Close(ctx)
+		}
+		conn.Release()
+	}, true, nil
 }
 
 func (s *Store) Migrate(ctx context.Context) error {
@@ -148,6 +259,8 @@ func (s *Store) Migrate(ctx context.Context) error {
 		`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_user_active_at TIMESTAMPTZ;`,
 		`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS idle_processed_at TIMESTAMPTZ;`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_last_user_active ON sessions(last_user_active_at);`,
+		`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS forked_from TEXT;`,
+		`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS forked_from_message_id BIGINT;`,
 		`ALTER TABLE memory_episode ADD COLUMN IF NOT EXISTS session_id TEXT;`,
 		`CREATE TABLE IF NOT EXISTS mem0_async_jobs (
 			id BIGSERIAL PRIMARY KEY,
@@ -274,6 +387,239 @@ func (s *Store) Migrate(ctx context.Context) error {
 			END IF;
 		END
 		$$;`,
+		`CREATE TABLE IF NOT EXISTS speaker_profiles (
+			speaker_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			display_name TEXT NOT NULL,
+			speaker_user_id TEXT REFERENCES users(user_id) ON DELETE SET NULL,
+			relation_uuid TEXT,
+			embedding JSONB NOT NULL,
+			sample_count INT NOT NULL DEFAULT 1,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (user_id, display_name)
+		);`,
+		`CREATE TABLE IF NOT EXISTS skill_bundles (
+			name TEXT PRIMARY KEY,
+			version BIGINT NOT NULL DEFAULT 1,
+			description TEXT NOT NULL DEFAULT '',
+			skills JSONB NOT NULL,
+			intents JSONB NOT NULL DEFAULT '[]'::jsonb,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS terminal_skill_bundles (
+			terminal_id TEXT NOT NULL,
+			bundle_name TEXT NOT NULL REFERENCES skill_bundles(name) ON DELETE CASCADE,
+			enabled_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (terminal_id, bundle_name)
+		);`,
+		`CREATE TABLE IF NOT EXISTS reminders (
+			id BIGSERIAL PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			terminal_id TEXT NOT NULL,
+			session_id TEXT NOT NULL,
+			soul_id TEXT,
+			skill TEXT NOT NULL,
+			content TEXT NOT NULL,
+			due_at TIMESTAMPTZ NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			delivered_at TIMESTAMPTZ
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_reminders_pending_due ON reminders(due_at) WHERE status = 'pending';`,
+		`CREATE INDEX IF NOT EXISTS idx_reminders_user ON reminders(user_id, due_at DESC);`,
+		`CREATE TABLE IF NOT EXISTS intent_results (
+			id BIGSERIAL PRIMARY KEY,
+			request_id TEXT NOT NULL,
+			terminal_id TEXT NOT NULL,
+			session_id TEXT,
+			soul_id TEXT,
+			intent_id TEXT NOT NULL,
+			intent_name TEXT,
+			skill TEXT,
+			ok BOOLEAN NOT NULL,
+			output TEXT,
+			error TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_intent_results_terminal ON intent_results(terminal_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_intent_results_session ON intent_results(session_id);`,
+		`CREATE TABLE IF NOT EXISTS routines (
+			id BIGSERIAL PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			terminal_id TEXT NOT NULL,
+			session_id TEXT,
+			soul_id TEXT,
+			name TEXT NOT NULL,
+			at_time TEXT NOT NULL,
+			weekdays JSONB NOT NULL DEFAULT '[]'::jsonb,
+			actions JSONB NOT NULL,
+			next_run_at TIMESTAMPTZ NOT NULL,
+			last_run_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_routines_next_run ON routines(next_run_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_routines_user ON routines(user_id, created_at DESC);`,
+		`CREATE TABLE IF NOT EXISTS quiet_hours (
+			user_id TEXT NOT NULL,
+			terminal_id TEXT NOT NULL DEFAULT '',
+			start_time TEXT NOT NULL,
+			end_time TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (user_id, terminal_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS private_sessions (
+			session_id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`ALTER TABLE souls ADD COLUMN IF NOT EXISTS child_mode BOOLEAN NOT NULL DEFAULT FALSE;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS topics TEXT[] NOT NULL DEFAULT '{}';`,
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS affect JSONB;`,
+		`ALTER TABLE souls ADD COLUMN IF NOT EXISTS character_card JSONB NOT NULL DEFAULT '{}'::jsonb;`,
+		`ALTER TABLE soul_user_relations ADD COLUMN IF NOT EXISTS rapport JSONB;`,
+		`CREATE TABLE IF NOT EXISTS safety_incidents (
+			id BIGSERIAL PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			terminal_id TEXT NOT NULL,
+			soul_id TEXT,
+			stage TEXT NOT NULL,
+			skill TEXT,
+			source TEXT NOT NULL,
+			category TEXT,
+			excerpt TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_safety_incidents_user ON safety_incidents(user_id, created_at DESC);`,
+		`CREATE TABLE IF NOT EXISTS terminal_reply_filters (
+			terminal_id TEXT PRIMARY KEY,
+			filters TEXT[] NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS soul_exemplars (
+			id BIGSERIAL PRIMARY KEY,
+			soul_id TEXT NOT NULL,
+			user_text TEXT NOT NULL,
+			reply TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_soul_exemplars_soul ON soul_exemplars(soul_id, id);`,
+		`CREATE TABLE IF NOT EXISTS soul_diary_entries (
+			soul_id TEXT NOT NULL,
+			day DATE NOT NULL,
+			content TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (soul_id, day)
+		);`,
+		`CREATE TABLE IF NOT EXISTS user_mood_alert_settings (
+			user_id TEXT PRIMARY KEY,
+			enabled BOOLEAN NOT NULL,
+			days INT NOT NULL DEFAULT 0,
+			negative_share DOUBLE PRECISION NOT NULL DEFAULT 0,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS user_mood_alerts (
+			id BIGSERIAL PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			first_day DATE NOT NULL,
+			last_day DATE NOT NULL,
+			days INT NOT NULL,
+			negative_share DOUBLE PRECISION NOT NULL,
+			user_valence_avg DOUBLE PRECISION NOT NULL,
+			session_id TEXT,
+			terminal_id TEXT,
+			check_in TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE (user_id, first_day)
+		);`,
+		`ALTER TABLE memory_episode ADD COLUMN IF NOT EXISTS correction TEXT;`,
+		`ALTER TABLE memory_episode ADD COLUMN IF NOT EXISTS corrected_at TIMESTAMPTZ;`,
+		`ALTER TABLE memory_episode ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';`,
+		`ALTER TABLE memory_episode ADD COLUMN IF NOT EXISTS participants TEXT[] NOT NULL DEFAULT '{}';`,
+		`ALTER TABLE memory_episode ADD COLUMN IF NOT EXISTS sentiment DOUBLE PRECISION;`,
+		`ALTER TABLE memory_episode ADD COLUMN IF NOT EXISTS importance INT NOT NULL DEFAULT 0;`,
+		`CREATE INDEX IF NOT EXISTS idx_memory_episode_tags ON memory_episode USING GIN (tags);`,
+		`CREATE TABLE IF NOT EXISTS memory_corrections (
+			id BIGSERIAL PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			soul_id TEXT,
+			memory_id TEXT,
+			episode_id BIGINT,
+			session_id TEXT,
+			original TEXT NOT NULL DEFAULT '',
+			correction TEXT NOT NULL DEFAULT '',
+			source TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_memory_corrections_user_created ON memory_corrections(user_id, created_at DESC);`,
+		`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;`,
+		// messages_archive mirrors messages column for column so archiving is
+		// INSERT ... SELECT *; a column added to messages must be added here too.
+		`CREATE TABLE IF NOT EXISTS messages_archive (LIKE messages INCLUDING DEFAULTS);`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_archive_session ON messages_archive(session_id, id);`,
+		`CREATE TABLE IF NOT EXISTS terminal_desired_state (
+			terminal_id TEXT PRIMARY KEY,
+			skills JSONB NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS version_requirements (
+			capability TEXT PRIMARY KEY,
+			min_firmware_version TEXT NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE TABLE IF NOT EXISTS terminal_config_pushes (
+			id BIGSERIAL PRIMARY KEY,
+			request_id TEXT NOT NULL UNIQUE,
+			terminal_id TEXT NOT NULL,
+			config JSONB NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			error TEXT,
+			attempts INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			sent_at TIMESTAMPTZ,
+			acked_at TIMESTAMPTZ
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_terminal_config_pushes_terminal ON terminal_config_pushes(terminal_id, id DESC);`,
+		`CREATE TABLE IF NOT EXISTS turn_replays (
+			id BIGSERIAL PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			terminal_id TEXT NOT NULL,
+			soul_id TEXT,
+			user_id TEXT,
+			record JSONB NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_turn_replays_session ON turn_replays(session_id, id DESC);`,
+		`ALTER TABLE speaker_profiles ADD COLUMN IF NOT EXISTS face_id TEXT;`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_user_tool ON messages(user_id, created_at) WHERE role = 'tool';`,
+		`CREATE TABLE IF NOT EXISTS terminal_soul_groups (
+			user_id TEXT NOT NULL,
+			terminal_id TEXT NOT NULL,
+			soul_ids TEXT[] NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (user_id, terminal_id)
+		);`,
+		`CREATE TABLE IF NOT EXISTS knowledge_documents (
+			id BIGSERIAL PRIMARY KEY,
+			soul_id TEXT NOT NULL REFERENCES souls(soul_id) ON DELETE CASCADE,
+			title TEXT NOT NULL,
+			chars INT NOT NULL,
+			chunk_count INT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_knowledge_documents_soul ON knowledge_documents(soul_id, id);`,
+		`CREATE TABLE IF NOT EXISTS user_data_purges (
+			id BIGSERIAL PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			deleted JSONB NOT NULL,
+			mem0_purged BOOLEAN NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);`,
+		`ALTER TABLE souls ADD COLUMN IF NOT EXISTS emotion_version BIGINT NOT NULL DEFAULT 0;`,
 	}
 
 	for _, q := range queries {
@@ -328,7 +674,7 @@ func (s *Store) GetUserByID(ctx context.Context, userID string) (domain.UserProf
 	var createdAt time.Time
 	var updatedAt time.Time
 	err := s.pool.QueryRow(ctx, `
-		SELECT id, user_id, user_uuid, display_name, description, created_at, updated_at
+		SELECT id, user_id, user_uuid, display_name, description, created_at, updated_at, language
 		FROM users
 		WHERE user_id=$1
 	`, userID).Scan(
@@ -339,6 +685,7 @@ func (s *Store) GetUserByID(ctx context.Context, userID string) (domain.UserProf
 		&out.Description,
 		&createdAt,
 		&updatedAt,
+		&out.Language,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return domain.UserProfile{}, fmt.Errorf("user not found: %s", userID)
@@ -353,7 +700,7 @@ func (s *Store) GetUserByID(ctx context.Context, userID string) (domain.UserProf
 
 func (s *Store) ListUsers(ctx context.Context) ([]domain.UserProfile, error) {
 	rows, err := s.pool.Query(ctx, `
-		SELECT id, user_id, user_uuid, display_name, description, created_at, updated_at
+		SELECT id, user_id, user_uuid, display_name, description, created_at, updated_at, language
 		FROM users
 		ORDER BY created_at ASC
 	`)
@@ -375,6 +722,7 @@ func (s *Store) ListUsers(ctx context.Context) ([]domain.UserProfile, error) {
 			&item.Description,
 			&createdAt,
 			&updatedAt,
+			&item.Language,
 		); err != nil {
 			return nil, err
 		}
@@ -388,6 +736,34 @@ func (s *Store) ListUsers(ctx context.Context) ([]domain.UserProfile, error) {
 	return out, nil
 }
 
+// SetUserLanguage stores the user's reply language; "" clears it.
+func (s *Store) SetUserLanguage(ctx context.Context, userID, language string) (domain.UserProfile, error) {
+	userID = strings.TrimSpace(userID)
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE users
+		SET language=$2, updated_at=NOW()
+		WHERE user_id=$1
+	`, userID, language)
+	if err != nil {
+		return domain.UserProfile{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.UserProfile{}, ErrUserNotFound
+	}
+	return s.GetUserByID(ctx, userID)
+}
+
+// GetUserLanguage returns "" for users without a preference, including
+// users not created yet.
+func (s *Store) GetUserLanguage(ctx context.Context, userID string) (string, error) {
+	var language string
+	err := s.pool.QueryRow(ctx, `SELECT language FROM users WHERE user_id=$1`, strings.TrimSpace(userID)).Scan(&language)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return language, err
+}
+
 func (s *Store) ResolveOrCreateSoul(ctx context.Context, userID, terminalID, soulHint string) (string, error) {
 	return s.ResolveSoul(ctx, userID, terminalID, soulHint)
 }
@@ -507,7 +883,79 @@ func (s *Store) BindTerminalSoul(ctx context.Context, userID, terminalID, soulID
 	return s.bindTerminalSoul(ctx, userID, terminalID, soulID)
 }
 
+// SetTerminalSoulGroup makes the souls share the terminal's conversations,
+// in the given order. Every soul must belong to the user.
+func (s *Store) SetTerminalSoulGroup(ctx context.Context, userID, terminalID string, soulIDs []string) (domain.TerminalSoulGroup, error) {
+	if err := s.ensureUserExists(ctx, userID); err != nil {
+		return domain.TerminalSoulGroup{}, err
+	}
+	var owned int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM souls WHERE user_id=$1 AND soul_id = ANY($2)`, userID, soulIDs).Scan(&owned); err != nil {
+		return domain.TerminalSoulGroup{}, err
+	}
+	if owned != len(soulIDs) {
+		return domain.TerminalSoulGroup{}, ErrSoulNotFound
+	}
+	var updatedAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO terminal_soul_groups(user_id, terminal_id, soul_ids)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, terminal_id)
+		DO UPDATE SET soul_ids = EXCLUDED.soul_ids, updated_at = NOW()
+		RETURNING updated_at
+	`, userID, terminalID, soulIDs).Scan(&updatedAt)
+	if err != nil {
+		return domain.TerminalSoulGroup{}, err
+	}
+	return domain.TerminalSoulGroup{UserID: userID, TerminalID: terminalID, SoulIDs: soulIDs, UpdatedAt: updatedAt.UTC().Format(time.RFC3339Nano)}, nil
+}
+
+// GetTerminalSoulGroup returns the souls sharing a terminal;
+// ErrSoulGroupNotFound when it hosts a single soul.
+func (s *Store) GetTerminalSoulGroup(ctx context.Context, userID, terminalID string) (domain.TerminalSoulGroup, error) {
+	out := domain.TerminalSoulGroup{UserID: userID, TerminalID: terminalID}
+	var updatedAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		SELECT soul_ids, updated_at
+		FROM terminal_soul_groups
+		WHERE user_id=$1 AND terminal_id=$2
+	`, userID, terminalID).Scan(&out.SoulIDs, &updatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.TerminalSoulGroup{}, ErrSoulGroupNotFound
+	}
+	if err != nil {
+		return domain.TerminalSoulGroup{}, err
+	}
+	out.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return out, nil
+}
+
+func (s *Store) DeleteTerminalSoulGroup(ctx context.Context, userID, terminalID string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM terminal_soul_groups WHERE user_id=$1 AND terminal_id=$2`, userID, terminalID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSoulGroupNotFound
+	}
+	return nil
+}
+
 func (s *Store) CreateSoulProfile(ctx context.Context, userID, name, mbtiType string, vector domain.PersonalityVector, state domain.SoulEmotionState, modelVersion string) (domain.SoulProfile, error) {
+	return s.CreateSeededSoulProfile(ctx, userID, name, mbtiType, vector, state, modelVersion, SoulSeed{})
+}
+
+// SoulSeed is what a new soul starts with besides its personality: the
+// character card, child mode and exemplars of a template or cloned soul.
+type SoulSeed struct {
+	ChildMode     bool
+	CharacterCard domain.SoulCharacterCard
+	Exemplars     []domain.CreateSoulExemplarPayload
+}
+
+// CreateSeededSoulProfile creates a soul and its seed in one transaction,
+// so a soul never exists without the card and exemplars it was made with.
+func (s *Store) CreateSeededSoulProfile(ctx context.Context, userID, name, mbtiType string, vector domain.PersonalityVector, state domain.SoulEmotionState, modelVersion string, seed SoulSeed) (domain.SoulProfile, error) {
 	if err := s.ensureUserExists(ctx, userID); err != nil {
 		return domain.SoulProfile{}, err
 	}
@@ -523,32 +971,46 @@ func (s *Store) CreateSoulProfile(ctx context.Context, userID, name, mbtiType st
 	if err != nil {
 		return domain.SoulProfile{}, err
 	}
-
-	tag, err := s.pool.Exec(ctx, `
-		INSERT INTO souls(soul_id, user_id, name, mbti_type, personality_vector, emotion_state, model_version)
-		VALUES ($1, $2, $3, $4, $5::jsonb, $6::jsonb, $7)
-		ON CONFLICT (user_id, name) DO NOTHING
-	`, soulID, userID, name, strings.ToUpper(strings.TrimSpace(mbtiType)), string(vecJSON), string(stateJSON), modelVersion)
+	cardJSON, err := json.Marshal(seed.CharacterCard)
 	if err != nil {
 		return domain.SoulProfile{}, err
 	}
-	if tag.RowsAffected() == 0 {
-		return domain.SoulProfile{}, fmt.Errorf("soul name already exists: %s", name)
+
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO souls(soul_id, user_id, name, mbti_type, personality_vector, emotion_state, model_version, child_mode, character_card)
+			VALUES ($1, $2, $3, $4, $5::jsonb, $6::jsonb, $7, $8, $9::jsonb)
+			ON CONFLICT (user_id, name) DO NOTHING
+		`, soulID, userID, name, strings.ToUpper(strings.TrimSpace(mbtiType)), string(vecJSON), string(stateJSON), modelVersion, seed.ChildMode, string(cardJSON))
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("soul name already exists: %s", name)
+		}
+		for _, ex := range seed.Exemplars {
+			if _, err := tx.Exec(ctx, `INSERT INTO soul_exemplars(soul_id, user_text, reply) VALUES ($1, $2, $3)`, soulID, ex.User, ex.Reply); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.SoulProfile{}, err
 	}
 	return s.GetSoulProfileByID(ctx, soulID)
 }
 
-func (s *Store) GetSoulProfileByID(ctx context.Context, soulID string) (domain.SoulProfile, error) {
+const soulProfileColumns = `soul_id, user_id, name, mbti_type, personality_vector, emotion_state, model_version, child_mode, character_card, emotion_version, created_at, updated_at`
+
+func scanSoulProfile(row pgx.Row) (domain.SoulProfile, error) {
 	var out domain.SoulProfile
 	var vectorRaw []byte
 	var stateRaw []byte
+	var cardRaw []byte
 	var createdAt time.Time
 	var updatedAt time.Time
-	err := s.pool.QueryRow(ctx, `
-		SELECT soul_id, user_id, name, mbti_type, personality_vector, emotion_state, model_version, created_at, updated_at
-		FROM souls
-		WHERE soul_id=$1
-	`, soulID).Scan(
+	if err := row.Scan(
 		&out.SoulID,
 		&out.UserID,
 		&out.Name,
@@ -556,13 +1018,12 @@ func (s *Store) GetSoulProfileByID(ctx context.Context, soulID string) (domain.S
 		&vectorRaw,
 		&stateRaw,
 		&out.ModelVersion,
+		&out.ChildMode,
+		&cardRaw,
+		&out.EmotionVersion,
 		&createdAt,
 		&updatedAt,
-	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return domain.SoulProfile{}, ErrSoulNotFound
-	}
-	if err != nil {
+	); err != nil {
 		return domain.SoulProfile{}, err
 	}
 	if err := json.Unmarshal(vectorRaw, &out.PersonalityVector); err != nil {
@@ -571,14 +1032,36 @@ func (s *Store) GetSoulProfileByID(ctx context.Context, soulID string) (domain.S
 	if err := json.Unmarshal(stateRaw, &out.EmotionState); err != nil {
 		return domain.SoulProfile{}, err
 	}
+	var card domain.SoulCharacterCard
+	if err := json.Unmarshal(cardRaw, &card); err != nil {
+		return domain.SoulProfile{}, err
+	}
+	if card.Background != "" || card.SpeakingStyle != "" || len(card.TabooTopics) > 0 {
+		out.CharacterCard = &card
+	}
 	out.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
 	out.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
 	return out, nil
 }
 
+func (s *Store) GetSoulProfileByID(ctx context.Context, soulID string) (domain.SoulProfile, error) {
+	out, err := scanSoulProfile(s.pool.QueryRow(ctx, `
+		SELECT `+soulProfileColumns+`
+		FROM souls
+		WHERE soul_id=$1
+	`, soulID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.SoulProfile{}, ErrSoulNotFound
+	}
+	if err != nil {
+		return domain.SoulProfile{}, err
+	}
+	return out, nil
+}
+
 func (s *Store) ListSoulProfiles(ctx context.Context, userID string) ([]domain.SoulProfile, error) {
 	rows, err := s.pool.Query(ctx, `
-		SELECT soul_id
+		SELECT `+soulProfileColumns+`
 		FROM souls
 		WHERE user_id=$1
 		ORDER BY created_at ASC
@@ -590,11 +1073,7 @@ func (s *Store) ListSoulProfiles(ctx context.Context, userID string) ([]domain.S
 
 	var out []domain.SoulProfile
 	for rows.Next() {
-		var soulID string
-		if err := rows.Scan(&soulID); err != nil {
-			return nil, err
-		}
-		item, err := s.GetSoulProfileByID(ctx, soulID)
+		item, err := scanSoulProfile(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -613,7 +1092,7 @@ func (s *Store) UpdateSoulEmotionState(ctx context.Context, soulID string, state
 	}
 	tag, err := s.pool.Exec(ctx, `
 		UPDATE souls
-		SET emotion_state=$2::jsonb, updated_at=NOW()
+		SET emotion_state=$2::jsonb, emotion_version=emotion_version+1, updated_at=NOW()
 		WHERE soul_id=$1
 	`, soulID, string(raw))
 	if err != nil {
@@ -625,67 +1104,356 @@ func (s *Store) UpdateSoulEmotionState(ctx context.Context, soulID string, state
 	return nil
 }
 
-func (s *Store) LoadSoulProfilePrompt(ctx context.Context, soulID string) (string, error) {
-	p, err := s.GetSoulProfileByID(ctx, soulID)
+// CompareAndSetSoulEmotionState writes state only if the soul's emotion
+// state is still at version, the EmotionVersion it was read at, and returns
+// the new version. ErrSoulEmotionConflict means another writer got there
+// first; reload and apply the change again.
+func (s *Store) CompareAndSetSoulEmotionState(ctx context.Context, soulID string, version int64, state domain.SoulEmotionState) (int64, error) {
+	raw, err := json.Marshal(state)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
-
-	prompt := fmt.Sprintf(
-		"灵魂画像: MBTI=%s, T=(empathy=%.2f, sensitivity=%.2f, stability=%.2f, expressiveness=%.2f, dominance=%.2f)。当前PAD=(P=%.2f, A=%.2f, D=%.2f)，请保持该灵魂风格并兼顾安全。",
-		p.MBTIType,
-		p.PersonalityVector.Empathy,
-		p.PersonalityVector.Sensitivity,
-		p.PersonalityVector.Stability,
-		p.PersonalityVector.Expressiveness,
-		p.PersonalityVector.Dominance,
-		p.EmotionState.P,
-		p.EmotionState.A,
-		p.EmotionState.D,
-	)
-	return prompt, nil
-}
-
-func (s *Store) SaveMessage(ctx context.Context, sessionID, userID, terminalID, soulID, role, name, toolCallID, content string) error {
-	if err := s.ensureUserExists(ctx, userID); err != nil {
-		return err
+	var next int64
+	err = s.pool.QueryRow(ctx, `
+		UPDATE souls
+		SET emotion_state=$2::jsonb, emotion_version=emotion_version+1, updated_at=NOW()
+		WHERE soul_id=$1 AND emotion_version=$3
+		RETURNING emotion_version
+	`, soulID, string(raw), version).Scan(&next)
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		if err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM souls WHERE soul_id=$1)`, soulID).Scan(&exists); err != nil {
+			return 0, err
+		}
+		if !exists {
+			return 0, ErrSoulNotFound
+		}
+		return 0, ErrSoulEmotionConflict
 	}
-	_, err := s.pool.Exec(ctx, `
-		INSERT INTO sessions(session_id, user_id, terminal_id, soul_id)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (session_id)
-		DO UPDATE SET user_id=EXCLUDED.user_id, terminal_id=EXCLUDED.terminal_id, soul_id=EXCLUDED.soul_id;
-	`, sessionID, userID, terminalID, soulID)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return next, nil
+}
 
-	_, err = s.pool.Exec(ctx, `
-		INSERT INTO messages(session_id, user_id, terminal_id, soul_id, role, name, tool_call_id, content)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`, sessionID, userID, terminalID, soulID, role, nullIfEmpty(name), nullIfEmpty(toolCallID), content)
+func (s *Store) SetSoulChildMode(ctx context.Context, soulID string, enabled bool) (domain.SoulProfile, error) {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE souls
+		SET child_mode=$2, updated_at=NOW()
+		WHERE soul_id=$1
+	`, soulID, enabled)
 	if err != nil {
-		return err
+		return domain.SoulProfile{}, err
 	}
-
-	if role == "user" {
-		return s.MarkUserActive(ctx, sessionID, userID, terminalID, soulID, time.Now())
+	if tag.RowsAffected() == 0 {
+		return domain.SoulProfile{}, ErrSoulNotFound
 	}
-	return nil
+	return s.GetSoulProfileByID(ctx, soulID)
 }
 
-func (s *Store) GetRecentMessages(ctx context.Context, sessionID string, limit int) ([]domain.Message, error) {
-	rows, err := s.pool.Query(ctx, `
-		SELECT role, COALESCE(content, ''), COALESCE(name, ''), COALESCE(tool_call_id, '')
-		FROM (
-			SELECT role, content, name, tool_call_id, created_at
-			FROM messages
-			WHERE session_id=$1 AND role IN ('user', 'assistant', 'tool')
-			ORDER BY created_at DESC
-			LIMIT $2
-		) t
-		ORDER BY created_at ASC
-	`, sessionID, limit)
+// SetSoulCharacterCard replaces the soul's character card; an empty card
+// clears it.
+func (s *Store) SetSoulCharacterCard(ctx context.Context, soulID string, card domain.SoulCharacterCard) (domain.SoulProfile, error) {
+	raw, err := json.Marshal(card)
+	if err != nil {
+		return domain.SoulProfile{}, err
+	}
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE souls
+		SET character_card=$2::jsonb, updated_at=NOW()
+		WHERE soul_id=$1
+	`, soulID, string(raw))
+	if err != nil {
+		return domain.SoulProfile{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.SoulProfile{}, ErrSoulNotFound
+	}
+	return s.GetSoulProfileByID(ctx, soulID)
+}
+
+// InsertSoulExemplar adds an example exchange to an existing soul.
+func (s *Store) InsertSoulExemplar(ctx context.Context, in domain.SoulExemplar) (domain.SoulExemplar, error) {
+	var createdAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO soul_exemplars(soul_id, user_text, reply)
+		SELECT soul_id, $2, $3 FROM souls WHERE soul_id=$1
+		RETURNING id, created_at
+	`, in.SoulID, in.User, in.Reply).Scan(&in.ID, &createdAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.SoulExemplar{}, ErrSoulNotFound
+	}
+	if err != nil {
+		return domain.SoulExemplar{}, err
+	}
+	in.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	return in, nil
+}
+
+// ListSoulExemplars returns a soul's exemplars, oldest first.
+func (s *Store) ListSoulExemplars(ctx context.Context, soulID string) ([]domain.SoulExemplar, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, soul_id, user_text, reply, created_at
+		FROM soul_exemplars
+		WHERE soul_id=$1
+		ORDER BY id ASC
+	`, soulID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.SoulExemplar, 0, 4)
+	for rows.Next() {
+		var item domain.SoulExemplar
+		var createdAt time.Time
+		if err := rows.Scan(&item.ID, &item.SoulID, &item.User, &item.Reply, &createdAt); err != nil {
+			return nil, err
+		}
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) DeleteSoulExemplar(ctx context.Context, soulID string, id int64) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM soul_exemplars WHERE soul_id=$1 AND id=$2`, soulID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrExemplarNotFound
+	}
+	return nil
+}
+
+func (s *Store) LoadSoulProfilePrompt(ctx context.Context, soulID string) (string, error) {
+	p, err := s.GetSoulProfileByID(ctx, soulID)
+	if err != nil {
+		return "", err
+	}
+	return SoulProfilePrompt(p), nil
+}
+
+func SoulProfilePrompt(p domain.SoulProfile) string {
+	return fmt.Sprintf(
+		"灵魂画像: MBTI=%s, T=(empathy=%.2f, sensitivity=%.2f, stability=%.2f, expressiveness=%.2f, dominance=%.2f)。当前PAD=(P=%.2f, A=%.2f, D=%.2f)，请保持该灵魂风格并兼顾安全。",
+		p.MBTIType,
+		p.PersonalityVector.Empathy,
+		p.PersonalityVector.Sensitivity,
+		p.PersonalityVector.Stability,
+		p.PersonalityVector.Expressiveness,
+		p.PersonalityVector.Dominance,
+		p.EmotionState.P,
+		p.EmotionState.A,
+		p.EmotionState.D,
+	)
+}
+
+// SoulMessage is a history message with the soul that was talking when it
+// was written.
+type SoulMessage struct {
+	domain.Message
+	SoulID string
+}
+
+// GetRecentSoulMessages is GetRecentMessages keeping each message's soul,
+// for sessions several souls take part in.
+func (s *Store) GetRecentSoulMessages(ctx context.Context, sessionID string, limit int) ([]SoulMessage, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT role, COALESCE(content, ''), COALESCE(name, ''), COALESCE(tool_call_id, ''), COALESCE(soul_id, '')
+		FROM (
+			SELECT id, role, content, name, tool_call_id, soul_id, created_at
+			FROM messages
+			WHERE session_id=$1 AND role IN ('user', 'assistant', 'tool', 'system')
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		) t
+		ORDER BY created_at ASC, id ASC
+	`, sessionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	msgs := make([]SoulMessage, 0, limit)
+	for rows.Next() {
+		var m SoulMessage
+		if err := rows.Scan(&m.Role, &m.Content, &m.Name, &m.ToolCallID, &m.SoulID); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// SaveMessage reports whether the message created its session.
+func (s *Store) SaveMessage(ctx context.Context, sessionID, userID, terminalID, soulID, role, name, toolCallID, content string) (bool, error) {
+	return s.SaveTurn(ctx, TurnWrite{
+		SessionID:  sessionID,
+		UserID:     userID,
+		TerminalID: terminalID,
+		SoulID:     soulID,
+		Messages:   []PendingMessage{{Role: role, Name: name, ToolCallID: toolCallID, Content: content}},
+	})
+}
+
+type PendingMessage struct {
+	Role       string
+	Name       string
+	ToolCallID string
+	Content    string
+	// At is when the message was produced; zero stores the write time.
+	// Messages of one turn written in separate batches keep their order
+	// by it.
+	At time.Time
+}
+
+type TurnWrite struct {
+	SessionID  string
+	UserID     string
+	TerminalID string
+	SoulID     string
+	Messages   []PendingMessage
+	// Topics label the turn; they are stored on its user message.
+	Topics []string
+	// Affect is stored on the turn's user message too; nil leaves it out.
+	Affect *domain.TurnAffect
+}
+
+// SaveTurn writes the session upsert and all messages of a turn as one
+// pipelined batch inside a transaction, so a turn is stored entirely or not at
+// all. It reports whether the turn created its session.
+func (s *Store) SaveTurn(ctx context.Context, turn TurnWrite) (bool, error) {
+	userID := strings.TrimSpace(turn.UserID)
+	if userID == "" {
+		return false, fmt.Errorf("user_id is required")
+	}
+	if len(turn.Messages) == 0 {
+		return false, nil
+	}
+
+	var created bool
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		batch := &pgx.Batch{}
+		batch.Queue(`
+			INSERT INTO users(user_id, display_name)
+			VALUES ($1, $1)
+			ON CONFLICT (user_id) DO NOTHING;
+		`, userID)
+		batch.Queue(`
+			INSERT INTO sessions(session_id, user_id, terminal_id, soul_id)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (session_id)
+			DO UPDATE SET user_id=EXCLUDED.user_id, terminal_id=EXCLUDED.terminal_id, soul_id=EXCLUDED.soul_id
+			RETURNING (xmax = 0);
+		`, turn.SessionID, userID, turn.TerminalID, turn.SoulID).QueryRow(func(row pgx.Row) error {
+			return row.Scan(&created)
+		})
+		var affect []byte
+		if turn.Affect != nil {
+			raw, err := json.Marshal(turn.Affect)
+			if err != nil {
+				return err
+			}
+			affect = raw
+		}
+		hasUserMessage := false
+		now := time.Now()
+		for _, m := range turn.Messages {
+			topics := []string{}
+			var msgAffect []byte
+			if m.Role == "user" {
+				if turn.Topics != nil {
+					topics = turn.Topics
+				}
+				msgAffect = affect
+			}
+			at := m.At
+			if at.IsZero() {
+				at = now
+			}
+			batch.Queue(`
+				INSERT INTO messages(session_id, user_id, terminal_id, soul_id, role, name, tool_call_id, content, topics, affect, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			`, turn.SessionID, userID, turn.TerminalID, turn.SoulID, m.Role, nullIfEmpty(m.Name), nullIfEmpty(m.ToolCallID), m.Content, topics, msgAffect, at)
+			if m.Role == "user" {
+				hasUserMessage = true
+			}
+		}
+		if hasUserMessage {
+			batch.Queue(`
+				UPDATE sessions
+				SET last_user_active_at=$2, idle_processed_at=NULL
+				WHERE session_id=$1
+			`, turn.SessionID, time.Now())
+		}
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return false, err
+	}
+	return created, nil
+}
+
+// AppendTurnMessages adds messages to a session SaveTurn already stored,
+// without touching the user or the session row: a deferred write must not
+// move a session handed off to another terminal back, or bring back one
+// deleted in the meantime, whose messages are then dropped. It reports how
+// many messages were stored.
+func (s *Store) AppendTurnMessages(ctx context.Context, turn TurnWrite) (int, error) {
+	if len(turn.Messages) == 0 {
+		return 0, nil
+	}
+	var stored int
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		// The lock holds off a concurrent delete until the messages are in.
+		var one int
+		err := tx.QueryRow(ctx, `
+			SELECT 1 FROM sessions WHERE session_id=$1 FOR KEY SHARE
+		`, turn.SessionID).Scan(&one)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		batch := &pgx.Batch{}
+		for _, m := range turn.Messages {
+			at := m.At
+			if at.IsZero() {
+				at = now
+			}
+			batch.Queue(`
+				INSERT INTO messages(session_id, user_id, terminal_id, soul_id, role, name, tool_call_id, content, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			`, turn.SessionID, turn.UserID, turn.TerminalID, turn.SoulID, m.Role, nullIfEmpty(m.Name), nullIfEmpty(m.ToolCallID), m.Content, at)
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+		stored = len(turn.Messages)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return stored, nil
+}
+
+func (s *Store) GetRecentMessages(ctx context.Context, sessionID string, limit int) ([]domain.Message, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT role, COALESCE(content, ''), COALESCE(name, ''), COALESCE(tool_call_id, '')
+		FROM (
+			SELECT id, role, content, name, tool_call_id, created_at
+			FROM messages
+			WHERE session_id=$1 AND role IN ('user', 'assistant', 'tool', 'system')
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		) t
+		ORDER BY created_at ASC, id ASC
+	`, sessionID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -707,7 +1475,7 @@ func (s *Store) GetRecentMessages(ctx context.Context, sessionID string, limit i
 
 func (s *Store) GetRecentEpisodes(ctx context.Context, soulID string, limit int) ([]string, error) {
 	rows, err := s.pool.Query(ctx, `
-		SELECT summary
+		SELECT `+episodeSummaryColumn+`
 		FROM memory_episode
 		WHERE soul_id=$1
 		ORDER BY created_at DESC
@@ -769,62 +1537,68 @@ func (s *Store) GetSessionSummary(ctx context.Context, sessionID string) (string
 	return summary, nil
 }
 
-func (s *Store) GetSessionCompactionState(ctx context.Context, sessionID string) (SessionCompactionState, error) {
-	var state SessionCompactionState
+func (s *Store) GetSession(ctx context.Context, sessionID string) (domain.SessionInfo, error) {
+	var out domain.SessionInfo
+	var createdAt time.Time
+	var lastActive *time.Time
 	err := s.pool.QueryRow(ctx, `
-		SELECT COALESCE(summary, ''), COALESCE(last_compacted_message_id, 0)
+		SELECT session_id, user_id, terminal_id, COALESCE(soul_id, ''), created_at, last_user_active_at
 		FROM sessions
 		WHERE session_id=$1
-	`, sessionID).Scan(&state.Summary, &state.LastCompactedMessageID)
+	`, sessionID).Scan(&out.SessionID, &out.UserID, &out.TerminalID, &out.SoulID, &createdAt, &lastActive)
 	if errors.Is(err, pgx.ErrNoRows) {
-		return SessionCompactionState{}, nil
+		return domain.SessionInfo{}, ErrSessionNotFound
 	}
 	if err != nil {
-		return SessionCompactionState{}, err
+		return domain.SessionInfo{}, err
 	}
-	return state, nil
+	out.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	if lastActive != nil {
+		out.LastUserActiveAt = lastActive.UTC().Format(time.RFC3339Nano)
+	}
+	return out, nil
 }
 
-func (s *Store) GetSessionCompactionStats(ctx context.Context, sessionID string, lastCompactedMessageID int64) (SessionCompactionStats, error) {
-	var stats SessionCompactionStats
-	err := s.pool.QueryRow(ctx, `
-		SELECT COUNT(*), COALESCE(SUM(char_length(content)), 0)
-		FROM messages
+func (s *Store) UpdateSessionTerminal(ctx context.Context, sessionID, terminalID string) error {
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE sessions
+		SET terminal_id=$2
 		WHERE session_id=$1
-		  AND id > $2
-		  AND role IN ('user', 'assistant', 'tool', 'observation')
-	`, sessionID, lastCompactedMessageID).Scan(&stats.MessageCount, &stats.CharCount)
+	`, sessionID, terminalID)
 	if err != nil {
-		return SessionCompactionStats{}, err
+		return err
 	}
-	return stats, nil
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
 }
 
-func (s *Store) GetMessagesSince(ctx context.Context, sessionID string, lastCompactedMessageID int64, limit int) ([]MessageChunk, error) {
+func (s *Store) ListSessionMessages(ctx context.Context, sessionID string, limit int) ([]domain.SessionMessage, error) {
 	if limit <= 0 {
 		limit = 200
 	}
-	rows, err := s.pool.Query(ctx, `
-		SELECT id, role, COALESCE(content, '')
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT id, role, COALESCE(name, ''), COALESCE(tool_call_id, ''), content, topics, created_at
 		FROM messages
 		WHERE session_id=$1
-		  AND id > $2
-		  AND role IN ('user', 'assistant', 'tool', 'observation')
-		ORDER BY id ASC
-		LIMIT $3
-	`, sessionID, lastCompactedMessageID, limit)
+		ORDER BY created_at ASC, id ASC
+		LIMIT $2
+	`, sessionID, limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	out := make([]MessageChunk, 0, limit)
+	out := make([]domain.SessionMessage, 0, limit)
 	for rows.Next() {
-		var chunk MessageChunk
-		if err := rows.Scan(&chunk.ID, &chunk.Role, &chunk.Content); err != nil {
+		var m domain.SessionMessage
+		var createdAt time.Time
+		if err := rows.Scan(&m.ID, &m.Role, &m.Name, &m.ToolCallID, &m.Content, &m.Topics, &createdAt); err != nil {
 			return nil, err
 		}
-		out = append(out, chunk)
+		m.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, m)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -832,96 +1606,66 @@ func (s *Store) GetMessagesSince(ctx context.Context, sessionID string, lastComp
 	return out, nil
 }
 
-func (s *Store) UpdateSessionSummary(ctx context.Context, sessionID, userID, terminalID, soulID, summary string, lastCompactedMessageID int64) error {
-	if err := s.ensureUserExists(ctx, userID); err != nil {
-		return err
-	}
-	tag, err := s.pool.Exec(ctx, `
-		UPDATE sessions
-		SET summary=$2,
-			summary_updated_at=NOW(),
-			last_compacted_message_id=$3
+// ListSessionExportMessages returns a session's messages oldest first, the
+// user messages with their turn's affect.
+func (s *Store) ListSessionExportMessages(ctx context.Context, sessionID string, limit int) ([]domain.SessionExportMessage, error) {
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT id, role, COALESCE(name, ''), COALESCE(tool_call_id, ''), content, topics, affect, created_at
+		FROM messages
 		WHERE session_id=$1
-	`, sessionID, summary, lastCompactedMessageID)
+		ORDER BY created_at ASC, id ASC
+		LIMIT $2
+	`, sessionID, limit)
 	if err != nil {
-		return err
-	}
-	if tag.RowsAffected() > 0 {
-		return nil
-	}
-
-	_, err = s.pool.Exec(ctx, `
-		INSERT INTO sessions(
-			session_id, user_id, terminal_id, soul_id, summary, summary_updated_at, last_compacted_message_id
-		)
-		VALUES ($1, $2, $3, $4, $5, NOW(), $6)
-		ON CONFLICT (session_id)
-		DO UPDATE SET
-			user_id=EXCLUDED.user_id,
-			terminal_id=EXCLUDED.terminal_id,
-			soul_id=EXCLUDED.soul_id,
-			summary=EXCLUDED.summary,
-			summary_updated_at=NOW(),
-			last_compacted_message_id=EXCLUDED.last_compacted_message_id;
-	`, sessionID, userID, terminalID, soulID, summary, lastCompactedMessageID)
-	return err
-}
-
-func (s *Store) InsertMemoryEpisode(ctx context.Context, sessionID, userID, terminalID, soulID, summary string) error {
-	if strings.TrimSpace(summary) == "" {
-		return nil
-	}
-	if err := s.ensureUserExists(ctx, userID); err != nil {
-		return err
+		return nil, err
 	}
-	_, err := s.pool.Exec(ctx, `
-		INSERT INTO memory_episode(session_id, user_id, terminal_id, soul_id, summary)
-		VALUES ($1, $2, $3, $4, $5)
-	`, sessionID, userID, terminalID, soulID, summary)
-	return err
-}
+	defer rows.Close()
 
-func (s *Store) EnqueueMem0AsyncJob(ctx context.Context, sessionID, userID, terminalID, soulID, summary, triggerSource string) error {
-	if strings.TrimSpace(summary) == "" {
-		return nil
-	}
-	if err := s.ensureUserExists(ctx, userID); err != nil {
-		return err
-	}
-	if triggerSource == "" {
-		triggerSource = "idle_timeout"
+	out := make([]domain.SessionExportMessage, 0, 32)
+	for rows.Next() {
+		var m domain.SessionExportMessage
+		var affect []byte
+		var createdAt time.Time
+		if err := rows.Scan(&m.ID, &m.Role, &m.Name, &m.ToolCallID, &m.Content, &m.Topics, &affect, &createdAt); err != nil {
+			return nil, err
+		}
+		m.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		if len(affect) > 0 {
+			var a domain.TurnAffect
+			if err := json.Unmarshal(affect, &a); err != nil {
+				return nil, err
+			}
+			m.Affect = &a
+		}
+		out = append(out, m)
 	}
-	_, err := s.pool.Exec(ctx, `
-		INSERT INTO mem0_async_jobs(session_id, user_id, terminal_id, soul_id, summary, trigger_source, status, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, 'pending', NOW())
-	`, sessionID, userID, terminalID, soulID, summary, triggerSource)
-	return err
+	return out, rows.Err()
 }
 
-func (s *Store) ListIdleSessionsForSummary(ctx context.Context, idleBefore time.Time, limit int) ([]IdleSession, error) {
-	if limit <= 0 {
-		limit = 50
-	}
-	rows, err := s.pool.Query(ctx, `
-		SELECT session_id, user_id, terminal_id, soul_id, last_user_active_at
-		FROM sessions
-		WHERE last_user_active_at IS NOT NULL
-		  AND last_user_active_at <= $1
-		  AND (idle_processed_at IS NULL OR idle_processed_at < last_user_active_at)
-		ORDER BY last_user_active_at ASC
-		LIMIT $2
-	`, idleBefore, limit)
+// ListSessionTopics counts the turns labelled with each topic in a
+// session, the most recently discussed first.
+func (s *Store) ListSessionTopics(ctx context.Context, sessionID string) ([]domain.SessionTopic, error) {
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT topic, COUNT(*), MIN(m.created_at), MAX(m.created_at)
+		FROM messages m, unnest(m.topics) AS topic
+		WHERE m.session_id=$1
+		GROUP BY topic
+		ORDER BY MAX(m.created_at) DESC, topic ASC
+	`, sessionID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	out := make([]IdleSession, 0, limit)
+	out := make([]domain.SessionTopic, 0, 8)
 	for rows.Next() {
-		var item IdleSession
-		if err := rows.Scan(&item.SessionID, &item.UserID, &item.TerminalID, &item.SoulID, &item.LastUserActiveAt); err != nil {
+		var item domain.SessionTopic
+		var firstAt, lastAt time.Time
+		if err := rows.Scan(&item.Topic, &item.Turns, &firstAt, &lastAt); err != nil {
 			return nil, err
 		}
+		item.FirstAt = firstAt.UTC().Format(time.RFC3339Nano)
+		item.LastAt = lastAt.UTC().Format(time.RFC3339Nano)
 		out = append(out, item)
 	}
 	if err := rows.Err(); err != nil {
@@ -930,164 +1674,2374 @@ func (s *Store) ListIdleSessionsForSummary(ctx context.Context, idleBefore time.
 	return out, nil
 }
 
-func (s *Store) MarkIdleSummaryProcessed(ctx context.Context, sessionID string, at time.Time) error {
-	if at.IsZero() {
-		at = time.Now()
-	}
-	_, err := s.pool.Exec(ctx, `
-		UPDATE sessions
-		SET idle_processed_at=$2
+// ListSessionAffect returns the affect stored on a session's user messages,
+// oldest first.
+func (s *Store) ListSessionAffect(ctx context.Context, sessionID string) ([]domain.TurnAffect, error) {
+	return s.queryTurnAffect(ctx, `
+		SELECT affect, created_at
+		FROM messages
+		WHERE session_id=$1 AND role='user' AND affect IS NOT NULL
+		ORDER BY id ASC
+	`, sessionID)
+}
+
+// ListSoulAffect returns the affect of a soul's turns in [from, to), oldest
+// first.
+func (s *Store) ListSoulAffect(ctx context.Context, soulID string, from, to time.Time) ([]domain.TurnAffect, error) {
+	return s.queryTurnAffect(ctx, `
+		SELECT affect, created_at
+		FROM messages
+		WHERE soul_id=$1 AND role='user' AND affect IS NOT NULL
+		  AND created_at >= $2 AND created_at < $3
+		ORDER BY id ASC
+	`, soulID, from, to)
+}
+
+func (s *Store) queryTurnAffect(ctx context.Context, query string, args ...any) ([]domain.TurnAffect, error) {
+	rows, err := s.reader(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.TurnAffect, 0, 16)
+	for rows.Next() {
+		var raw []byte
+		var createdAt time.Time
+		if err := rows.Scan(&raw, &createdAt); err != nil {
+			return nil, err
+		}
+		var item domain.TurnAffect
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, err
+		}
+		item.At = createdAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListDiaryDueSouls returns the souls that talked with a user in [from, to)
+// and have no diary entry for day yet.
+func (s *Store) ListDiaryDueSouls(ctx context.Context, day string, from, to time.Time, limit int) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT s.soul_id
+		FROM souls s
+		WHERE EXISTS (
+			SELECT 1 FROM messages m
+			WHERE m.soul_id=s.soul_id AND m.role='user' AND m.created_at >= $2 AND m.created_at < $3
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM soul_diary_entries d WHERE d.soul_id=s.soul_id AND d.day=$1::date
+		)
+		ORDER BY s.soul_id
+		LIMIT $4
+	`, day, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var soulID string
+		if err := rows.Scan(&soulID); err != nil {
+			return nil, err
+		}
+		out = append(out, soulID)
+	}
+	return out, rows.Err()
+}
+
+// episodeSummaryColumn reads an episode summary with the user's
+// correction, if any, appended.
+const episodeSummaryColumn = `CASE WHEN correction IS NULL THEN summary ELSE summary || '（用户更正：' || COALESCE(NULLIF(correction, ''), '此内容有误') || '）' END`
+
+// InsertMemoryCorrection records a correction and, when it names an
+// episode of the user, marks that episode in the same transaction. Missing
+// soul, session and original text are filled in from the episode.
+func (s *Store) InsertMemoryCorrection(ctx context.Context, in domain.MemoryCorrection) (domain.MemoryCorrection, error) {
+	var out domain.MemoryCorrection
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if in.EpisodeID > 0 {
+			var soulID, sessionID, summary string
+			err := tx.QueryRow(ctx, `
+				UPDATE memory_episode
+				SET correction=$3, corrected_at=NOW()
+				WHERE id=$1 AND user_id=$2
+				RETURNING soul_id, COALESCE(session_id, ''), summary
+			`, in.EpisodeID, in.UserID, in.Correction).Scan(&soulID, &sessionID, &summary)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrEpisodeNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if in.SoulID == "" {
+				in.SoulID = soulID
+			}
+			if in.SessionID == "" {
+				in.SessionID = sessionID
+			}
+			if in.Original == "" {
+				in.Original = summary
+			}
+		}
+		var episodeID *int64
+		if in.EpisodeID > 0 {
+			episodeID = &in.EpisodeID
+		}
+		item, err := scanMemoryCorrection(tx.QueryRow(ctx, `
+			INSERT INTO memory_corrections(user_id, soul_id, memory_id, episode_id, session_id, original, correction, source)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING `+memoryCorrectionColumns,
+			in.UserID, nullIfEmpty(in.SoulID), nullIfEmpty(in.MemoryID), episodeID, nullIfEmpty(in.SessionID), in.Original, in.Correction, in.Source))
+		out = item
+		return err
+	})
+	return out, err
+}
+
+// ListMemoryCorrections returns a user's memory corrections, newest first.
+func (s *Store) ListMemoryCorrections(ctx context.Context, userID string, limit int) ([]domain.MemoryCorrection, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+memoryCorrectionColumns+`
+		FROM memory_corrections
+		WHERE user_id=$1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]domain.MemoryCorrection, 0, 8)
+	for rows.Next() {
+		item, err := scanMemoryCorrection(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+const memoryCorrectionColumns = `id, user_id, COALESCE(soul_id, ''), COALESCE(memory_id, ''), COALESCE(episode_id, 0), COALESCE(session_id, ''), original, correction, source, created_at`
+
+func scanMemoryCorrection(row pgx.Row) (domain.MemoryCorrection, error) {
+	var item domain.MemoryCorrection
+	var createdAt time.Time
+	if err := row.Scan(&item.ID, &item.UserID, &item.SoulID, &item.MemoryID, &item.EpisodeID, &item.SessionID, &item.Original, &item.Correction, &item.Source, &createdAt); err != nil {
+		return domain.MemoryCorrection{}, err
+	}
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	return item, nil
+}
+
+// LatestSessionEpisodes returns, per session, the user's newest stored
+// session summary. Sessions with none are absent from the map.
+func (s *Store) LatestSessionEpisodes(ctx context.Context, userID string, sessionIDs []string) (map[string]domain.EpisodeRef, error) {
+	out := map[string]domain.EpisodeRef{}
+	if len(sessionIDs) == 0 {
+		return out, nil
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT ON (session_id) id, session_id, created_at, tags
+		FROM memory_episode
+		WHERE user_id=$1 AND session_id = ANY($2)
+		ORDER BY session_id, created_at DESC
+	`, userID, sessionIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ref domain.EpisodeRef
+		if err := rows.Scan(&ref.ID, &ref.SessionID, &ref.CreatedAt, &ref.Tags); err != nil {
+			return nil, err
+		}
+		out[ref.SessionID] = ref
+	}
+	return out, rows.Err()
+}
+
+// ListSoulEpisodes returns the session summaries a soul stored in
+// [from, to), oldest first.
+func (s *Store) ListSoulEpisodes(ctx context.Context, soulID string, from, to time.Time) ([]string, error) {
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT `+episodeSummaryColumn+`
+		FROM memory_episode
+		WHERE soul_id=$1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at ASC
+	`, soulID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var summary string
+		if err := rows.Scan(&summary); err != nil {
+			return nil, err
+		}
+		out = append(out, summary)
+	}
+	return out, rows.Err()
+}
+
+// InsertSoulDiaryEntry stores a diary entry; it reports false when the soul
+// already has one for that day.
+func (s *Store) InsertSoulDiaryEntry(ctx context.Context, entry domain.SoulDiaryEntry) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO soul_diary_entries(soul_id, day, content)
+		VALUES ($1, $2::date, $3)
+		ON CONFLICT (soul_id, day) DO NOTHING
+	`, entry.SoulID, entry.Day, entry.Content)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListSoulDiaryEntries returns a soul's diary, newest day first.
+func (s *Store) ListSoulDiaryEntries(ctx context.Context, soulID string, limit int) ([]domain.SoulDiaryEntry, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT soul_id, day, content, created_at
+		FROM soul_diary_entries
+		WHERE soul_id=$1
+		ORDER BY day DESC
+		LIMIT $2
+	`, soulID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.SoulDiaryEntry, 0, 8)
+	for rows.Next() {
+		item, err := scanSoulDiaryEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) GetSoulDiaryEntry(ctx context.Context, soulID, day string) (domain.SoulDiaryEntry, error) {
+	item, err := scanSoulDiaryEntry(s.pool.QueryRow(ctx, `
+		SELECT soul_id, day, content, created_at
+		FROM soul_diary_entries
+		WHERE soul_id=$1 AND day=$2::date
+	`, soulID, day))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.SoulDiaryEntry{}, ErrDiaryEntryNotFound
+	}
+	return item, err
+}
+
+func scanSoulDiaryEntry(row pgx.Row) (domain.SoulDiaryEntry, error) {
+	var item domain.SoulDiaryEntry
+	var day, createdAt time.Time
+	if err := row.Scan(&item.SoulID, &day, &item.Content, &createdAt); err != nil {
+		return domain.SoulDiaryEntry{}, err
+	}
+	item.Day = day.Format(time.DateOnly)
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	return item, nil
+}
+
+// ListAffectUsers returns the users with recorded turn affect since since.
+func (s *Store) ListAffectUsers(ctx context.Context, since time.Time) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT DISTINCT user_id
+		FROM messages
+		WHERE role='user' AND affect IS NOT NULL AND created_at >= $1
+		ORDER BY user_id
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		out = append(out, userID)
+	}
+	return out, rows.Err()
+}
+
+// ListUserAffect returns a user's turn affect in [from, to) across all
+// sessions and souls, oldest first.
+func (s *Store) ListUserAffect(ctx context.Context, userID string, from, to time.Time) ([]domain.TurnAffect, error) {
+	return s.queryTurnAffect(ctx, `
+		SELECT affect, created_at
+		FROM messages
+		WHERE user_id=$1 AND role='user' AND affect IS NOT NULL
+		  AND created_at >= $2 AND created_at < $3
+		ORDER BY id ASC
+	`, userID, from, to)
+}
+
+// LatestUserSession returns the session the user last spoke in, leaving out
+// forks.
+func (s *Store) LatestUserSession(ctx context.Context, userID string) (domain.SessionInfo, error) {
+	var out domain.SessionInfo
+	var createdAt time.Time
+	var lastActive *time.Time
+	err := s.pool.QueryRow(ctx, `
+		SELECT session_id, user_id, terminal_id, COALESCE(soul_id, ''), created_at, last_user_active_at
+		FROM sessions
+		WHERE user_id=$1 AND forked_from IS NULL
+		ORDER BY COALESCE(last_user_active_at, created_at) DESC
+		LIMIT 1
+	`, userID).Scan(&out.SessionID, &out.UserID, &out.TerminalID, &out.SoulID, &createdAt, &lastActive)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.SessionInfo{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return domain.SessionInfo{}, err
+	}
+	out.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	if lastActive != nil {
+		out.LastUserActiveAt = lastActive.UTC().Format(time.RFC3339Nano)
+	}
+	return out, nil
+}
+
+// ListMoodAlertSettings returns every user's stored choice; users without
+// one follow the server defaults.
+func (s *Store) ListMoodAlertSettings(ctx context.Context) ([]domain.MoodAlertSettings, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT user_id, enabled, days, negative_share, updated_at
+		FROM user_mood_alert_settings
+		ORDER BY user_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []domain.MoodAlertSettings
+	for rows.Next() {
+		item, err := scanMoodAlertSettings(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// GetMoodAlertSettings reports false when the user has not chosen.
+func (s *Store) GetMoodAlertSettings(ctx context.Context, userID string) (domain.MoodAlertSettings, bool, error) {
+	item, err := scanMoodAlertSettings(s.pool.QueryRow(ctx, `
+		SELECT user_id, enabled, days, negative_share, updated_at
+		FROM user_mood_alert_settings
+		WHERE user_id=$1
+	`, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.MoodAlertSettings{}, false, nil
+	}
+	if err != nil {
+		return domain.MoodAlertSettings{}, false, err
+	}
+	return item, true, nil
+}
+
+func (s *Store) UpsertMoodAlertSettings(ctx context.Context, in domain.MoodAlertSettings) (domain.MoodAlertSettings, error) {
+	return scanMoodAlertSettings(s.pool.QueryRow(ctx, `
+		INSERT INTO user_mood_alert_settings(user_id, enabled, days, negative_share)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id)
+		DO UPDATE SET enabled=EXCLUDED.enabled, days=EXCLUDED.days, negative_share=EXCLUDED.negative_share, updated_at=NOW()
+		RETURNING user_id, enabled, days, negative_share, updated_at
+	`, in.UserID, in.Enabled, in.Days, in.NegativeShare))
+}
+
+func scanMoodAlertSettings(row pgx.Row) (domain.MoodAlertSettings, error) {
+	var item domain.MoodAlertSettings
+	var updatedAt time.Time
+	if err := row.Scan(&item.UserID, &item.Enabled, &item.Days, &item.NegativeShare, &updatedAt); err != nil {
+		return domain.MoodAlertSettings{}, err
+	}
+	item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return item, nil
+}
+
+// InsertMoodAlert records an alert; a stretch already alerted from the same
+// first day returns ErrMoodAlertExists, so with several soul-server
+// instances each stretch is alerted once.
+func (s *Store) InsertMoodAlert(ctx context.Context, in domain.MoodAlert) (domain.MoodAlert, error) {
+	item, err := scanMoodAlert(s.pool.QueryRow(ctx, `
+		INSERT INTO user_mood_alerts(user_id, first_day, last_day, days, negative_share, user_valence_avg, session_id, terminal_id, check_in)
+		VALUES ($1, $2::date, $3::date, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id, first_day) DO NOTHING
+		RETURNING `+moodAlertColumns,
+		in.UserID, in.FirstDay, in.LastDay, in.Days, in.NegativeShare, in.UserValenceAvg,
+		nullIfEmpty(in.SessionID), nullIfEmpty(in.TerminalID), nullIfEmpty(in.CheckIn)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.MoodAlert{}, ErrMoodAlertExists
+	}
+	return item, err
+}
+
+// ListMoodAlerts returns a user's alerts, newest first.
+func (s *Store) ListMoodAlerts(ctx context.Context, userID string, limit int) ([]domain.MoodAlert, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+moodAlertColumns+`
+		FROM user_mood_alerts
+		WHERE user_id=$1
+		ORDER BY last_day DESC, id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]domain.MoodAlert, 0, 8)
+	for rows.Next() {
+		item, err := scanMoodAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+const moodAlertColumns = `id, user_id, first_day, last_day, days, negative_share, user_valence_avg, COALESCE(session_id, ''), COALESCE(terminal_id, ''), COALESCE(check_in, ''), created_at`
+
+func scanMoodAlert(row pgx.Row) (domain.MoodAlert, error) {
+	var item domain.MoodAlert
+	var firstDay, lastDay, createdAt time.Time
+	if err := row.Scan(&item.ID, &item.UserID, &firstDay, &lastDay, &item.Days, &item.NegativeShare, &item.UserValenceAvg, &item.SessionID, &item.TerminalID, &item.CheckIn, &createdAt); err != nil {
+		return domain.MoodAlert{}, err
+	}
+	item.FirstDay = firstDay.Format(time.DateOnly)
+	item.LastDay = lastDay.Format(time.DateOnly)
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	return item, nil
+}
+
+// ForkSession copies the history of sourceID up to and including
+// upToMessageID (0 copies everything) into the new session targetID. The cut
+// goes by created_at, not id: a turn's deferred tool rows are stored after
+// its reply but dated before it, so forking at the reply keeps them. The fork
+// keeps the source terminal and soul but starts without a summary, so its
+// context is rebuilt from the copied messages only.
+func (s *Store) ForkSession(ctx context.Context, sourceID, targetID string, upToMessageID int64) (domain.SessionForkResult, error) {
+	out := domain.SessionForkResult{SourceSessionID: sourceID, SessionID: targetID}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			SELECT user_id, terminal_id, COALESCE(soul_id, '')
+			FROM sessions
+			WHERE session_id=$1
+		`, sourceID).Scan(&out.UserID, &out.TerminalID, &out.SoulID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if upToMessageID > 0 {
+			var found bool
+			if err := tx.QueryRow(ctx, `
+				SELECT EXISTS(SELECT 1 FROM messages WHERE session_id=$1 AND id=$2)
+			`, sourceID, upToMessageID).Scan(&found); err != nil {
+				return err
+			}
+			if !found {
+				return ErrMessageNotFound
+			}
+		}
+
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO sessions(session_id, user_id, terminal_id, soul_id, forked_from, forked_from_message_id)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6::bigint, 0))
+			ON CONFLICT (session_id) DO NOTHING
+		`, targetID, out.UserID, out.TerminalID, out.SoulID, sourceID, upToMessageID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrSessionExists
+		}
+
+		tag, err = tx.Exec(ctx, `
+			INSERT INTO messages(session_id, user_id, terminal_id, soul_id, role, name, tool_call_id, content, topics, affect, created_at)
+			SELECT $2, user_id, terminal_id, soul_id, role, name, tool_call_id, content, topics, affect, created_at
+			FROM messages
+			WHERE session_id=$1
+			  AND ($3::bigint = 0 OR created_at <= (SELECT created_at FROM messages WHERE session_id=$1 AND id=$3::bigint))
+			ORDER BY created_at ASC, id ASC
+		`, sourceID, targetID, upToMessageID)
+		if err != nil {
+			return err
+		}
+		out.CopiedMessages = int(tag.RowsAffected())
+		out.UpToMessageID = upToMessageID
+		return nil
+	})
+	if err != nil {
+		return domain.SessionForkResult{}, err
+	}
+	return out, nil
+}
+
+func (s *Store) GetSessionCompactionState(ctx context.Context, sessionID string) (SessionCompactionState, error) {
+	var state SessionCompactionState
+	err := s.pool.QueryRow(ctx, `
+		SELECT COALESCE(summary, ''), COALESCE(last_compacted_message_id, 0)
+		FROM sessions
 		WHERE session_id=$1
-	`, sessionID, at)
-	return err
+	`, sessionID).Scan(&state.Summary, &state.LastCompactedMessageID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return SessionCompactionState{}, nil
+	}
+	if err != nil {
+		return SessionCompactionState{}, err
+	}
+	return state, nil
+}
+
+func (s *Store) GetSessionCompactionStats(ctx context.Context, sessionID string, lastCompactedMessageID int64) (SessionCompactionStats, error) {
+	var stats SessionCompactionStats
+	err := s.pool.QueryRow(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(char_length(content)), 0)
+		FROM messages
+		WHERE session_id=$1
+		  AND id > $2
+		  AND role IN ('user', 'assistant', 'tool', 'observation', 'system')
+	`, sessionID, lastCompactedMessageID).Scan(&stats.MessageCount, &stats.CharCount)
+	if err != nil {
+		return SessionCompactionStats{}, err
+	}
+	return stats, nil
+}
+
+// GetMessagesSince returns up to limit messages stored after
+// lastCompactedMessageID. The batch is the next ids, so the highest one is
+// the new cursor, but it is ordered by created_at: deferred tool rows get
+// ids after their turn's reply and still read before it.
+func (s *Store) GetMessagesSince(ctx context.Context, sessionID string, lastCompactedMessageID int64, limit int) ([]MessageChunk, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, role, content
+		FROM (
+			SELECT id, role, COALESCE(content, '') AS content, created_at
+			FROM messages
+			WHERE session_id=$1
+			  AND id > $2
+			  AND role IN ('user', 'assistant', 'tool', 'observation', 'system')
+			ORDER BY id ASC
+			LIMIT $3
+		) t
+		ORDER BY created_at ASC, id ASC
+	`, sessionID, lastCompactedMessageID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]MessageChunk, 0, limit)
+	for rows.Next() {
+		var chunk MessageChunk
+		if err := rows.Scan(&chunk.ID, &chunk.Role, &chunk.Content); err != nil {
+			return nil, err
+		}
+		out = append(out, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) UpdateSessionSummary(ctx context.Context, sessionID, userID, terminalID, soulID, summary string, lastCompactedMessageID int64) error {
+	if err := s.ensureUserExists(ctx, userID); err != nil {
+		return err
+	}
+	tag, err := s.pool.Exec(ctx, `
+		UPDATE sessions
+		SET summary=$2,
+			summary_updated_at=NOW(),
+			last_compacted_message_id=$3
+		WHERE session_id=$1
+	`, sessionID, summary, lastCompactedMessageID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	_, err = s.pool.Exec(ctx, `
+		INSERT INTO sessions(
+			session_id, user_id, terminal_id, soul_id, summary, summary_updated_at, last_compacted_message_id
+		)
+		VALUES ($1, $2, $3, $4, $5, NOW(), $6)
+		ON CONFLICT (session_id)
+		DO UPDATE SET
+			user_id=EXCLUDED.user_id,
+			terminal_id=EXCLUDED.terminal_id,
+			soul_id=EXCLUDED.soul_id,
+			summary=EXCLUDED.summary,
+			summary_updated_at=NOW(),
+			last_compacted_message_id=EXCLUDED.last_compacted_message_id;
+	`, sessionID, userID, terminalID, soulID, summary, lastCompactedMessageID)
+	return err
+}
+
+func (s *Store) InsertMemoryEpisode(ctx context.Context, sessionID, userID, terminalID, soulID, summary string, meta domain.EpisodeMeta) error {
+	if strings.TrimSpace(summary) == "" {
+		return nil
+	}
+	if err := s.ensureUserExists(ctx, userID); err != nil {
+		return err
+	}
+	tags, participants := meta.Tags, meta.Participants
+	if tags == nil {
+		tags = []string{}
+	}
+	if participants == nil {
+		participants = []string{}
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO memory_episode(session_id, user_id, terminal_id, soul_id, summary, tags, participants, sentiment, importance)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, sessionID, userID, terminalID, soulID, summary, tags, participants, meta.Sentiment, meta.Importance)
+	return err
+}
+
+// episodeSentimentBand is the valence either side of zero that still
+// counts as neutral when filtering episodes by sentiment.
+const episodeSentimentBand = 0.15
+
+// ListMemoryEpisodes returns a user's episodes matching filter, newest
+// first. Tags match when the episode has any of them.
+func (s *Store) ListMemoryEpisodes(ctx context.Context, userID string, filter domain.EpisodeFilter) ([]domain.MemoryEpisode, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	where := []string{"user_id=$1"}
+	args := []any{userID}
+	add := func(cond string, v any) {
+		args = append(args, v)
+		where = append(where, strings.ReplaceAll(cond, "?", fmt.Sprintf("$%d", len(args))))
+	}
+	if filter.SoulID != "" {
+		add("soul_id=?", filter.SoulID)
+	}
+	if len(filter.Tags) > 0 {
+		add("tags && ?", filter.Tags)
+	}
+	if filter.Participant != "" {
+		add("? = ANY(participants)", filter.Participant)
+	}
+	if filter.MinImportance > 0 {
+		add("importance >= ?", filter.MinImportance)
+	}
+	switch filter.Sentiment {
+	case "positive":
+		add("sentiment > ?", episodeSentimentBand)
+	case "negative":
+		add("sentiment < ?", -episodeSentimentBand)
+	case "neutral":
+		add("sentiment BETWEEN -? AND ?", episodeSentimentBand)
+	}
+	args = append(args, filter.Limit)
+	rows, err := s.reader(ctx).Query(ctx, fmt.Sprintf(`
+		SELECT id, user_id, soul_id, COALESCE(session_id, ''), terminal_id, summary, tags, participants, sentiment, importance, correction, created_at
+		FROM memory_episode
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d
+	`, strings.Join(where, " AND "), len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.MemoryEpisode, 0, 8)
+	for rows.Next() {
+		var item domain.MemoryEpisode
+		var createdAt time.Time
+		if err := rows.Scan(&item.ID, &item.UserID, &item.SoulID, &item.SessionID, &item.TerminalID, &item.Summary, &item.Tags, &item.Participants, &item.Sentiment, &item.Importance, &item.Correction, &createdAt); err != nil {
+			return nil, err
+		}
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) EnqueueMem0AsyncJob(ctx context.Context, sessionID, userID, terminalID, soulID, summary, triggerSource string) error {
+	if strings.TrimSpace(summary) == "" {
+		return nil
+	}
+	if err := s.ensureUserExists(ctx, userID); err != nil {
+		return err
+	}
+	if triggerSource == "" {
+		triggerSource = "idle_timeout"
+	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO mem0_async_jobs(session_id, user_id, terminal_id, soul_id, summary, trigger_source, status, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', NOW())
+	`, sessionID, userID, terminalID, soulID, summary, triggerSource)
+	return err
+}
+
+func (s *Store) ListIdleSessionsForSummary(ctx context.Context, idleBefore time.Time, limit int) ([]IdleSession, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT session_id, user_id, terminal_id, soul_id, last_user_active_at, forked_from IS NOT NULL,
+			EXISTS (SELECT 1 FROM private_sessions p WHERE p.session_id = sessions.session_id)
+		FROM sessions
+		WHERE last_user_active_at IS NOT NULL
+		  AND last_user_active_at <= $1
+		  AND (idle_processed_at IS NULL OR idle_processed_at < last_user_active_at)
+		ORDER BY last_user_active_at ASC
+		LIMIT $2
+	`, idleBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]IdleSession, 0, limit)
+	for rows.Next() {
+		var item IdleSession
+		if err := rows.Scan(&item.SessionID, &item.UserID, &item.TerminalID, &item.SoulID, &item.LastUserActiveAt, &item.Forked, &item.Private); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListExpiredSessions returns sessions with no user activity since before
+// that still hold messages to archive, oldest first. A session used again
+// after it was archived is listed once it expires again.
+func (s *Store) ListExpiredSessions(ctx context.Context, before time.Time, limit int) ([]IdleSession, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT session_id, user_id, terminal_id, soul_id, COALESCE(last_user_active_at, created_at), forked_from IS NOT NULL,
+			EXISTS (SELECT 1 FROM private_sessions p WHERE p.session_id = sessions.session_id)
+		FROM sessions
+		WHERE COALESCE(last_user_active_at, created_at) < $1
+		  AND (archived_at IS NULL OR archived_at < COALESCE(last_user_active_at, created_at))
+		ORDER BY COALESCE(last_user_active_at, created_at) ASC
+		LIMIT $2
+	`, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]IdleSession, 0, limit)
+	for rows.Next() {
+		var item IdleSession
+		if err := rows.Scan(&item.SessionID, &item.UserID, &item.TerminalID, &item.SoulID, &item.LastUserActiveAt, &item.Forked, &item.Private); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// HasSessionEpisode reports whether a session's summary was stored as an
+// episode.
+func (s *Store) HasSessionEpisode(ctx context.Context, sessionID string) (bool, error) {
+	var exists bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM memory_episode WHERE session_id=$1)`, sessionID).Scan(&exists)
+	return exists, err
+}
+
+// ArchiveSessionMessages takes a session's messages out of the messages
+// table, copying them to messages_archive first unless discard is set, and
+// marks the session archived. It returns how many messages were moved.
+func (s *Store) ArchiveSessionMessages(ctx context.Context, sessionID string, discard bool) (int64, error) {
+	var moved int64
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if !discard {
+			if _, err := tx.Exec(ctx, `INSERT INTO messages_archive SELECT * FROM messages WHERE session_id=$1`, sessionID); err != nil {
+				return err
+			}
+		}
+		tag, err := tx.Exec(ctx, `DELETE FROM messages WHERE session_id=$1`, sessionID)
+		if err != nil {
+			return err
+		}
+		moved = tag.RowsAffected()
+		_, err = tx.Exec(ctx, `UPDATE sessions SET archived_at=NOW() WHERE session_id=$1`, sessionID)
+		return err
+	})
+	return moved, err
+}
+
+// IdleSummaryPending reports whether the session still waits for its idle
+// summary, which another instance may have written since it was listed.
+func (s *Store) IdleSummaryPending(ctx context.Context, sessionID string) (bool, error) {
+	var pending bool
+	err := s.pool.QueryRow(ctx, `
+		SELECT last_user_active_at IS NOT NULL AND (idle_processed_at IS NULL OR idle_processed_at < last_user_active_at)
+		FROM sessions
+		WHERE session_id=$1
+	`, sessionID).Scan(&pending)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return pending, err
+}
+
+func (s *Store) MarkIdleSummaryProcessed(ctx context.Context, sessionID string, at time.Time) error {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	_, err := s.pool.Exec(ctx, `
+		UPDATE sessions
+		SET idle_processed_at=$2
+		WHERE session_id=$1
+	`, sessionID, at)
+	return err
+}
+
+func (s *Store) BuildMemoryContext(ctx context.Context, soulID string) (string, error) {
+	profile, err := s.LoadSoulProfilePrompt(ctx, soulID)
+	if err != nil {
+		return "", err
+	}
+	episodes, err := s.GetRecentEpisodes(ctx, soulID, 3)
+	if err != nil {
+		return "", err
+	}
+
+	if len(episodes) == 0 {
+		return profile, nil
+	}
+
+	return profile + "\n近期片段记忆:\n- " + strings.Join(episodes, "\n- "), nil
+}
+
+func (s *Store) CreateSoulUserRelation(ctx context.Context, soulID string, payload domain.CreateSoulUserRelationPayload) (domain.SoulUserRelation, error) {
+	soulID = strings.TrimSpace(soulID)
+	if soulID == "" {
+		return domain.SoulUserRelation{}, fmt.Errorf("soul_id is required")
+	}
+	appellation := strings.TrimSpace(payload.Appellation)
+	if appellation == "" {
+		return domain.SoulUserRelation{}, fmt.Errorf("appellation is required")
+	}
+	relationToOwner := strings.TrimSpace(payload.RelationToOwner)
+	if relationToOwner == "" {
+		return domain.SoulUserRelation{}, fmt.Errorf("relation_to_owner is required")
+	}
+	relatedUserID := strings.TrimSpace(payload.RelatedUserID)
+	if relatedUserID != "" {
+		if _, err := s.GetUserByID(ctx, relatedUserID); err != nil {
+			return domain.SoulUserRelation{}, err
+		}
+	}
+
+	var personalityJSON any
+	if payload.PersonalityModel != nil {
+		raw, err := json.Marshal(payload.PersonalityModel)
+		if err != nil {
+			return domain.SoulUserRelation{}, err
+		}
+		personalityJSON = string(raw)
+	}
+
+	var out domain.SoulUserRelation
+	var personalityRaw []byte
+	var rapportRaw []byte
+	var createdAt time.Time
+	var updatedAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO soul_user_relations(
+			soul_id, related_user_id, appellation, relation_to_owner, user_description, personality_model
+		)
+		VALUES ($1, $2, $3, $4, $5, $6::jsonb)
+		RETURNING id, relation_uuid, soul_id, COALESCE(related_user_id, ''), appellation, relation_to_owner, user_description, personality_model, rapport, created_at, updated_at
+	`,
+		soulID,
+		nullIfEmpty(relatedUserID),
+		appellation,
+		relationToOwner,
+		strings.TrimSpace(payload.UserDescription),
+		personalityJSON,
+	).Scan(
+		&out.ID,
+		&out.RelationUUID,
+		&out.SoulID,
+		&out.RelatedUserID,
+		&out.Appellation,
+		&out.RelationToOwner,
+		&out.UserDescription,
+		&personalityRaw,
+		&rapportRaw,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return domain.SoulUserRelation{}, err
+	}
+	if len(personalityRaw) > 0 {
+		var model domain.PersonalityVector
+		if err := json.Unmarshal(personalityRaw, &model); err != nil {
+			return domain.SoulUserRelation{}, err
+		}
+		out.PersonalityModel = &model
+	}
+	if len(rapportRaw) > 0 {
+		var rapport domain.RelationRapport
+		if err := json.Unmarshal(rapportRaw, &rapport); err != nil {
+			return domain.SoulUserRelation{}, err
+		}
+		out.Rapport = &rapport
+	}
+	out.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	out.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return out, nil
+}
+
+func (s *Store) ListSoulUserRelations(ctx context.Context, soulID string) ([]domain.SoulUserRelation, error) {
+	soulID = strings.TrimSpace(soulID)
+	if soulID == "" {
+		return nil, fmt.Errorf("soul_id is required")
+	}
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, relation_uuid, soul_id, COALESCE(related_user_id, ''), appellation, relation_to_owner, user_description, personality_model, rapport, created_at, updated_at
+		FROM soul_user_relations
+		WHERE soul_id=$1
+		ORDER BY created_at ASC
+	`, soulID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.SoulUserRelation, 0, 8)
+	for rows.Next() {
+		var item domain.SoulUserRelation
+		var personalityRaw []byte
+		var rapportRaw []byte
+		var createdAt time.Time
+		var updatedAt time.Time
+		if err := rows.Scan(
+			&item.ID,
+			&item.RelationUUID,
+			&item.SoulID,
+			&item.RelatedUserID,
+			&item.Appellation,
+			&item.RelationToOwner,
+			&item.UserDescription,
+			&personalityRaw,
+			&rapportRaw,
+			&createdAt,
+			&updatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if len(personalityRaw) > 0 {
+			var model domain.PersonalityVector
+			if err := json.Unmarshal(personalityRaw, &model); err != nil {
+				return nil, err
+			}
+			item.PersonalityModel = &model
+		}
+		if len(rapportRaw) > 0 {
+			var rapport domain.RelationRapport
+			if err := json.Unmarshal(rapportRaw, &rapport); err != nil {
+				return nil, err
+			}
+			item.Rapport = &rapport
+		}
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetRelationRapport returns the rapport of soulID with userID, kept on the
+// user's earliest relation row; ok is false before their first recorded turn.
+func (s *Store) GetRelationRapport(ctx context.Context, soulID, userID string) (domain.RelationRapport, bool, error) {
+	var raw []byte
+	err := s.pool.QueryRow(ctx, `
+		SELECT rapport
+		FROM soul_user_relations
+		WHERE soul_id=$1 AND related_user_id=$2
+		ORDER BY created_at ASC, id ASC
+		LIMIT 1
+	`, soulID, userID).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && len(raw) == 0) {
+		return domain.RelationRapport{}, false, nil
+	}
+	if err != nil {
+		return domain.RelationRapport{}, false, err
+	}
+	var out domain.RelationRapport
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return domain.RelationRapport{}, false, err
+	}
+	return out, true, nil
+}
+
+// UpdateRelationRapport applies next to the rapport of soulID with userID
+// while holding the relation row. A user without a relation row gets one
+// named after them, related to the soul's owner as 本人 when they are the
+// owner and unknown otherwise; if that name is taken the update is dropped.
+func (s *Store) UpdateRelationRapport(ctx context.Context, soulID, userID string, next func(domain.RelationRapport) domain.RelationRapport) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var id int64
+		var raw []byte
+		err := tx.QueryRow(ctx, `
+			SELECT id, rapport
+			FROM soul_user_relations
+			WHERE soul_id=$1 AND related_user_id=$2
+			ORDER BY created_at ASC, id ASC
+			LIMIT 1
+			FOR UPDATE
+		`, soulID, userID).Scan(&id, &raw)
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = tx.QueryRow(ctx, `
+				INSERT INTO soul_user_relations(soul_id, related_user_id, appellation, relation_to_owner)
+				SELECT s.soul_id, u.user_id, COALESCE(NULLIF(TRIM(u.display_name), ''), u.user_id),
+					CASE WHEN s.user_id=u.user_id THEN '本人' ELSE 'unknown' END
+				FROM souls s
+				JOIN users u ON u.user_id=$2
+				WHERE s.soul_id=$1
+				ON CONFLICT (soul_id, appellation) DO NOTHING
+				RETURNING id
+			`, soulID, userID).Scan(&id)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+		}
+		if err != nil {
+			return err
+		}
+		var rapport domain.RelationRapport
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &rapport); err != nil {
+				return err
+			}
+		}
+		updated, err := json.Marshal(next(rapport))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE soul_user_relations
+			SET rapport=$2::jsonb, updated_at=NOW()
+			WHERE id=$1
+		`, id, string(updated))
+		return err
+	})
+}
+
+func (s *Store) UpsertSpeakerProfile(ctx context.Context, profile domain.SpeakerProfile) (domain.SpeakerProfile, error) {
+	if err := s.ensureUserExists(ctx, profile.UserID); err != nil {
+		return domain.SpeakerProfile{}, err
+	}
+	if strings.TrimSpace(profile.SpeakerID) == "" {
+		profile.SpeakerID = "spk_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	}
+	embeddingJSON, err := json.Marshal(profile.Embedding)
+	if err != nil {
+		return domain.SpeakerProfile{}, err
+	}
+	var createdAt time.Time
+	var updatedAt time.Time
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO speaker_profiles(speaker_id, user_id, display_name, speaker_user_id, relation_uuid, embedding, sample_count)
+		VALUES ($1, $2, $3, $4, $5, $6::jsonb, $7)
+		ON CONFLICT (user_id, display_name)
+		DO UPDATE SET
+			speaker_user_id = EXCLUDED.speaker_user_id,
+			relation_uuid = EXCLUDED.relation_uuid,
+			embedding = EXCLUDED.embedding,
+			sample_count = EXCLUDED.sample_count,
+			updated_at = NOW()
+		RETURNING speaker_id, created_at, updated_at
+	`,
+		profile.SpeakerID,
+		profile.UserID,
+		profile.DisplayName,
+		nullIfEmpty(profile.SpeakerUserID),
+		nullIfEmpty(profile.RelationUUID),
+		string(embeddingJSON),
+		profile.SampleCount,
+	).Scan(&profile.SpeakerID, &createdAt, &updatedAt)
+	if err != nil {
+		return domain.SpeakerProfile{}, err
+	}
+	profile.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	profile.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return profile, nil
+}
+
+func (s *Store) ListSpeakerProfiles(ctx context.Context, userID string) ([]domain.SpeakerProfile, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT speaker_id, user_id, display_name, COALESCE(speaker_user_id, ''), COALESCE(relation_uuid, ''), COALESCE(face_id, ''), embedding, sample_count, created_at, updated_at
+		FROM speaker_profiles
+		WHERE user_id=$1
+		ORDER BY created_at ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.SpeakerProfile, 0, 4)
+	for rows.Next() {
+		var item domain.SpeakerProfile
+		var embeddingRaw []byte
+		var createdAt time.Time
+		var updatedAt time.Time
+		if err := rows.Scan(
+			&item.SpeakerID,
+			&item.UserID,
+			&item.DisplayName,
+			&item.SpeakerUserID,
+			&item.RelationUUID,
+			&item.FaceID,
+			&embeddingRaw,
+			&item.SampleCount,
+			&createdAt,
+			&updatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(embeddingRaw, &item.Embedding); err != nil {
+			return nil, err
+		}
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// UpsertSkillBundle publishes a bundle; republishing the same name bumps its
+// version.
+func (s *Store) UpsertSkillBundle(ctx context.Context, bundle domain.SkillBundle) (domain.SkillBundle, error) {
+	skillsJSON, err := json.Marshal(bundle.Skills)
+	if err != nil {
+		return domain.SkillBundle{}, err
+	}
+	intents := bundle.Intents
+	if intents == nil {
+		intents = []domain.IntentSpec{}
+	}
+	intentsJSON, err := json.Marshal(intents)
+	if err != nil {
+		return domain.SkillBundle{}, err
+	}
+	var updatedAt time.Time
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO skill_bundles(name, description, skills, intents)
+		VALUES ($1, $2, $3::jsonb, $4::jsonb)
+		ON CONFLICT (name)
+		DO UPDATE SET
+			version = skill_bundles.version + 1,
+			description = EXCLUDED.description,
+			skills = EXCLUDED.skills,
+			intents = EXCLUDED.intents,
+			updated_at = NOW()
+		RETURNING version, updated_at
+	`, bundle.Name, bundle.Description, string(skillsJSON), string(intentsJSON)).Scan(&bundle.Version, &updatedAt)
+	if err != nil {
+		return domain.SkillBundle{}, err
+	}
+	bundle.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return bundle, nil
+}
+
+const skillBundleColumns = `b.name, b.version, b.description, b.skills, b.intents, b.updated_at`
+
+// scanSkillBundle reads skillBundleColumns, after any leading columns the
+// query selects into lead.
+func scanSkillBundle(row pgx.Row, lead ...any) (domain.SkillBundle, error) {
+	var out domain.SkillBundle
+	var skillsRaw, intentsRaw []byte
+	var updatedAt time.Time
+	dest := append(lead, &out.Name, &out.Version, &out.Description, &skillsRaw, &intentsRaw, &updatedAt)
+	if err := row.Scan(dest...); err != nil {
+		return domain.SkillBundle{}, err
+	}
+	if err := json.Unmarshal(skillsRaw, &out.Skills); err != nil {
+		return domain.SkillBundle{}, err
+	}
+	if err := json.Unmarshal(intentsRaw, &out.Intents); err != nil {
+		return domain.SkillBundle{}, err
+	}
+	out.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return out, nil
+}
+
+func (s *Store) GetSkillBundle(ctx context.Context, name string) (domain.SkillBundle, error) {
+	out, err := scanSkillBundle(s.pool.QueryRow(ctx, `SELECT `+skillBundleColumns+` FROM skill_bundles b WHERE b.name=$1`, name))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.SkillBundle{}, ErrSkillBundleNotFound
+	}
+	return out, err
+}
+
+func (s *Store) ListSkillBundles(ctx context.Context) ([]domain.SkillBundle, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+skillBundleColumns+` FROM skill_bundles b ORDER BY b.name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.SkillBundle, 0, 8)
+	for rows.Next() {
+		item, err := scanSkillBundle(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) EnableTerminalSkillBundle(ctx context.Context, terminalID, name string) error {
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO terminal_skill_bundles(terminal_id, bundle_name)
+		SELECT $1, name FROM skill_bundles WHERE name=$2
+		ON CONFLICT (terminal_id, bundle_name) DO NOTHING
+	`, terminalID, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := s.GetSkillBundle(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DisableTerminalSkillBundle reports whether the bundle had been enabled.
+func (s *Store) DisableTerminalSkillBundle(ctx context.Context, terminalID, name string) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM terminal_skill_bundles WHERE terminal_id=$1 AND bundle_name=$2`, terminalID, name)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListEnabledSkillBundles returns every terminal's enabled bundles, keyed by
+// terminal ID, in the order they were enabled.
+func (s *Store) ListEnabledSkillBundles(ctx context.Context) (map[string][]domain.SkillBundle, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT t.terminal_id, `+skillBundleColumns+`
+		FROM terminal_skill_bundles t
+		JOIN skill_bundles b ON b.name = t.bundle_name
+		ORDER BY t.terminal_id ASC, t.enabled_at ASC, b.name ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string][]domain.SkillBundle)
+	for rows.Next() {
+		var terminalID string
+		item, err := scanSkillBundle(rows, &terminalID)
+		if err != nil {
+			return nil, err
+		}
+		out[terminalID] = append(out[terminalID], item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) CreateReminder(ctx context.Context, r domain.Reminder, dueAt time.Time) (domain.Reminder, error) {
+	var createdAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO reminders(user_id, terminal_id, session_id, soul_id, skill, content, due_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, r.UserID, r.TerminalID, r.SessionID, nullIfEmpty(r.SoulID), r.Skill, r.Content, dueAt).Scan(&r.ID, &createdAt)
+	if err != nil {
+		return domain.Reminder{}, err
+	}
+	r.DueAt = dueAt.UTC().Format(time.RFC3339Nano)
+	r.Status = domain.ReminderStatusPending
+	r.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	return r, nil
+}
+
+const reminderColumns = `id, user_id, terminal_id, session_id, COALESCE(soul_id, ''), skill, content, due_at, status, created_at, delivered_at`
+
+func scanReminder(row pgx.Row) (domain.Reminder, error) {
+	var out domain.Reminder
+	var dueAt, createdAt time.Time
+	var deliveredAt *time.Time
+	if err := row.Scan(&out.ID, &out.UserID, &out.TerminalID, &out.SessionID, &out.SoulID, &out.Skill, &out.Content, &dueAt, &out.Status, &createdAt, &deliveredAt); err != nil {
+		return domain.Reminder{}, err
+	}
+	out.DueAt = dueAt.UTC().Format(time.RFC3339Nano)
+	out.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	if deliveredAt != nil {
+		out.DeliveredAt = deliveredAt.UTC().Format(time.RFC3339Nano)
+	}
+	return out, nil
+}
+
+func (s *Store) queryReminders(ctx context.Context, query string, args ...any) ([]domain.Reminder, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Reminder, 0, 8)
+	for rows.Next() {
+		item, err := scanReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// ListDueReminders returns pending reminders due at or before now, oldest
+// first.
+func (s *Store) ListDueReminders(ctx context.Context, now time.Time, limit int) ([]domain.Reminder, error) {
+	return s.queryReminders(ctx, `
+		SELECT `+reminderColumns+`
+		FROM reminders
+		WHERE status = 'pending' AND due_at <= $1
+		ORDER BY due_at ASC
+		LIMIT $2
+	`, now, limit)
+}
+
+// ListReminders returns a user's reminders, latest due first.
+func (s *Store) ListReminders(ctx context.Context, userID string, limit int) ([]domain.Reminder, error) {
+	return s.queryReminders(ctx, `
+		SELECT `+reminderColumns+`
+		FROM reminders
+		WHERE user_id = $1
+		ORDER BY due_at DESC
+		LIMIT $2
+	`, userID, limit)
+}
+
+// ListPendingReminders returns the reminders a user set on a terminal that
+// have not been delivered yet, soonest first.
+func (s *Store) ListPendingReminders(ctx context.Context, userID, terminalID string, limit int) ([]domain.Reminder, error) {
+	return s.queryReminders(ctx, `
+		SELECT `+reminderColumns+`
+		FROM reminders
+		WHERE status = 'pending' AND user_id = $1 AND terminal_id = $2
+		ORDER BY due_at ASC
+		LIMIT $3
+	`, userID, terminalID, limit)
+}
+
+func (s *Store) MarkReminderDelivered(ctx context.Context, id int64, at time.Time) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE reminders SET status='delivered', delivered_at=$2 WHERE id=$1 AND status='pending'`, id, at)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrReminderNotFound
+	}
+	return nil
+}
+
+// SaveIntentResults stores every result of one intent_result report in a
+// single batch.
+func (s *Store) SaveIntentResults(ctx context.Context, payload domain.IntentResultPayload) ([]domain.IntentResult, error) {
+	out := make([]domain.IntentResult, 0, len(payload.Results))
+	batch := &pgx.Batch{}
+	for _, item := range payload.Results {
+		r := domain.IntentResult{
+			RequestID:  payload.RequestID,
+			TerminalID: payload.TerminalID,
+			SessionID:  payload.SessionID,
+			SoulID:     payload.SoulID,
+			IntentID:   item.IntentID,
+			IntentName: item.IntentName,
+			Skill:      item.Skill,
+			OK:         item.OK,
+			Output:     item.Output,
+			Error:      item.Error,
+		}
+		idx := len(out)
+		out = append(out, r)
+		batch.Queue(`
+			INSERT INTO intent_results(request_id, terminal_id, session_id, soul_id, intent_id, intent_name, skill, ok, output, error)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id, created_at
+		`, r.RequestID, r.TerminalID, nullIfEmpty(r.SessionID), nullIfEmpty(r.SoulID), r.IntentID, nullIfEmpty(r.IntentName), nullIfEmpty(r.Skill), r.OK, nullIfEmpty(r.Output), nullIfEmpty(r.Error)).QueryRow(func(row pgx.Row) error {
+			var createdAt time.Time
+			if err := row.Scan(&out[idx].ID, &createdAt); err != nil {
+				return err
+			}
+			out[idx].CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+			return nil
+		})
+	}
+	if len(out) == 0 {
+		return out, nil
+	}
+	if err := s.pool.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListIntentResults returns a terminal's latest intent results, newest first.
+func (s *Store) ListIntentResults(ctx context.Context, terminalID string, limit int) ([]domain.IntentResult, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT id, request_id, terminal_id, COALESCE(session_id, ''), COALESCE(soul_id, ''), intent_id, COALESCE(intent_name, ''), COALESCE(skill, ''), ok, COALESCE(output, ''), COALESCE(error, ''), created_at
+		FROM intent_results
+		WHERE terminal_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, terminalID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.IntentResult, 0, limit)
+	for rows.Next() {
+		var item domain.IntentResult
+		var createdAt time.Time
+		if err := rows.Scan(&item.ID, &item.RequestID, &item.TerminalID, &item.SessionID, &item.SoulID, &item.IntentID, &item.IntentName, &item.Skill, &item.OK, &item.Output, &item.Error, &createdAt); err != nil {
+			return nil, err
+		}
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// ActivityCursor marks the last entry of an activity page; the next page
+// starts strictly after it in (at, kind, id) order.
+type ActivityCursor struct {
+	At   time.Time
+	Kind string
+	ID   int64
+}
+
+// ListUserActivity merges what the robot did for a user, newest first: the
+// skills invoked in chat turns, the intent actions terminals reported on
+// the user's sessions, the reminders delivered and the mood check-ins
+// started. Entries older than since are left out; a nil after starts at
+// the newest entry.
+func (s *Store) ListUserActivity(ctx context.Context, userID string, since time.Time, after *ActivityCursor, limit int) ([]domain.ActivityItem, error) {
+	args := []any{userID, since, limit}
+	cond := ""
+	if after != nil {
+		args = append(args, after.At, after.Kind, after.ID)
+		cond = "WHERE (at, kind, id) < ($4, $5, $6)"
+	}
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT kind, id, at, terminal_id, session_id, soul_id, name, summary, ok
+		FROM (
+			SELECT 'skill' AS kind, id, created_at AS at, terminal_id, session_id, COALESCE(soul_id, '') AS soul_id,
+				COALESCE(name, '') AS name, LEFT(content, 200) AS summary, NULL::boolean AS ok
+			FROM messages
+			WHERE user_id = $1 AND role = 'tool' AND created_at >= $2
+			UNION ALL
+			SELECT 'intent', ir.id, ir.created_at, ir.terminal_id, ir.session_id, COALESCE(ir.soul_id, ''),
+				COALESCE(NULLIF(ir.intent_name, ''), ir.intent_id), LEFT(COALESCE(NULLIF(ir.error, ''), ir.output, ''), 200), ir.ok
+			FROM intent_results ir
+			JOIN sessions se ON se.session_id = ir.session_id
+			WHERE se.user_id = $1 AND ir.created_at >= $2
+			UNION ALL
+			SELECT 'reminder', id, delivered_at, terminal_id, session_id, COALESCE(soul_id, ''), skill, content, NULL
+			FROM reminders
+			WHERE user_id = $1 AND status = 'delivered' AND delivered_at >= $2
+			UNION ALL
+			SELECT 'check_in', id, created_at, COALESCE(terminal_id, ''), COALESCE(session_id, ''), '', 'mood_alert', check_in, NULL
+			FROM user_mood_alerts
+			WHERE user_id = $1 AND check_in IS NOT NULL AND created_at >= $2
+		) activity
+		`+cond+`
+		ORDER BY at DESC, kind DESC, id DESC
+		LIMIT $3
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.ActivityItem, 0, limit)
+	for rows.Next() {
+		var item domain.ActivityItem
+		var at time.Time
+		if err := rows.Scan(&item.Kind, &item.ID, &at, &item.TerminalID, &item.SessionID, &item.SoulID, &item.Name, &item.Summary, &item.OK); err != nil {
+			return nil, err
+		}
+		item.At = at.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) CreateRoutine(ctx context.Context, r domain.Routine, nextRunAt time.Time) (domain.Routine, error) {
+	weekdays, err := json.Marshal(append([]int{}, r.Weekdays...))
+	if err != nil {
+		return domain.Routine{}, err
+	}
+	actions, err := json.Marshal(r.Actions)
+	if err != nil {
+		return domain.Routine{}, err
+	}
+	var createdAt time.Time
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO routines(user_id, terminal_id, session_id, soul_id, name, at_time, weekdays, actions, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`, r.UserID, r.TerminalID, nullIfEmpty(r.SessionID), nullIfEmpty(r.SoulID), r.Name, r.Time, weekdays, actions, nextRunAt).Scan(&r.ID, &createdAt)
+	if err != nil {
+		return domain.Routine{}, err
+	}
+	r.NextRunAt = nextRunAt.UTC().Format(time.RFC3339Nano)
+	r.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	return r, nil
+}
+
+const routineColumns = `id, user_id, terminal_id, COALESCE(session_id, ''), COALESCE(soul_id, ''), name, at_time, weekdays, actions, next_run_at, last_run_at, created_at`
+
+func (s *Store) queryRoutines(ctx context.Context, query string, args ...any) ([]domain.Routine, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.Routine, 0, 8)
+	for rows.Next() {
+		var item domain.Routine
+		var weekdays, actions []byte
+		var nextRunAt, createdAt time.Time
+		var lastRunAt *time.Time
+		if err := rows.Scan(&item.ID, &item.UserID, &item.TerminalID, &item.SessionID, &item.SoulID, &item.Name, &item.Time, &weekdays, &actions, &nextRunAt, &lastRunAt, &createdAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(weekdays, &item.Weekdays); err != nil {
+			return nil, fmt.Errorf("decode routine %d weekdays: %w", item.ID, err)
+		}
+		if err := json.Unmarshal(actions, &item.Actions); err != nil {
+			return nil, fmt.Errorf("decode routine %d actions: %w", item.ID, err)
+		}
+		item.NextRunAt = nextRunAt.UTC().Format(time.RFC3339Nano)
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		if lastRunAt != nil {
+			item.LastRunAt = lastRunAt.UTC().Format(time.RFC3339Nano)
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// ListDueRoutines returns routines whose next run is at or before now,
+// oldest first.
+func (s *Store) ListDueRoutines(ctx context.Context, now time.Time, limit int) ([]domain.Routine, error) {
+	return s.queryRoutines(ctx, `
+		SELECT `+routineColumns+`
+		FROM routines
+		WHERE next_run_at <= $1
+		ORDER BY next_run_at ASC
+		LIMIT $2
+	`, now, limit)
+}
+
+// ListRoutines returns a user's routines, newest first.
+func (s *Store) ListRoutines(ctx context.Context, userID string) ([]domain.Routine, error) {
+	return s.queryRoutines(ctx, `
+		SELECT `+routineColumns+`
+		FROM routines
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+}
+
+// AdvanceRoutine moves a routine's next run from due to next. It only
+// succeeds while the routine is still due at due, so with several instances
+// each run is claimed once; otherwise it returns ErrRoutineNotFound.
+func (s *Store) AdvanceRoutine(ctx context.Context, id int64, due, next time.Time) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE routines SET last_run_at=$2, next_run_at=$3 WHERE id=$1 AND next_run_at=$2`, id, due, next)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRoutineNotFound
+	}
+	return nil
+}
+
+func (s *Store) DeleteRoutine(ctx context.Context, userID string, id int64) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM routines WHERE id=$1 AND user_id=$2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRoutineNotFound
+	}
+	return nil
+}
+
+// ListQuietHours returns every quiet hours rule, for loading the policy at
+// startup.
+func (s *Store) ListQuietHours(ctx context.Context) ([]domain.QuietHours, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT user_id, terminal_id, start_time, end_time, enabled, updated_at
+		FROM quiet_hours
+		ORDER BY user_id, terminal_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.QuietHours, 0, 4)
+	for rows.Next() {
+		var item domain.QuietHours
+		var updatedAt time.Time
+		if err := rows.Scan(&item.UserID, &item.TerminalID, &item.Start, &item.End, &item.Enabled, &updatedAt); err != nil {
+			return nil, err
+		}
+		item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// UpsertQuietHours stores the rule for (user, terminal), replacing any
+// previous one.
+func (s *Store) UpsertQuietHours(ctx context.Context, q domain.QuietHours) (domain.QuietHours, error) {
+	var updatedAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO quiet_hours(user_id, terminal_id, start_time, end_time, enabled, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (user_id, terminal_id) DO UPDATE SET
+			start_time = EXCLUDED.start_time,
+			end_time = EXCLUDED.end_time,
+			enabled = EXCLUDED.enabled,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, q.UserID, q.TerminalID, q.Start, q.End, q.Enabled).Scan(&updatedAt)
+	if err != nil {
+		return domain.QuietHours{}, err
+	}
+	q.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return q, nil
+}
+
+func (s *Store) DeleteQuietHours(ctx context.Context, userID, terminalID string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM quiet_hours WHERE user_id=$1 AND terminal_id=$2`, userID, terminalID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrQuietHoursNotFound
+	}
+	return nil
+}
+
+// ListTerminalReplyFilters returns every terminal's reply filter chain, for
+// loading the policy at startup.
+func (s *Store) ListTerminalReplyFilters(ctx context.Context) ([]domain.TerminalReplyFilters, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT terminal_id, filters, updated_at
+		FROM terminal_reply_filters
+		ORDER BY terminal_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.TerminalReplyFilters, 0, 4)
+	for rows.Next() {
+		var item domain.TerminalReplyFilters
+		var updatedAt time.Time
+		if err := rows.Scan(&item.TerminalID, &item.Filters, &updatedAt); err != nil {
+			return nil, err
+		}
+		item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) UpsertTerminalReplyFilters(ctx context.Context, in domain.TerminalReplyFilters) (domain.TerminalReplyFilters, error) {
+	if in.Filters == nil {
+		in.Filters = []string{}
+	}
+	var updatedAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO terminal_reply_filters(terminal_id, filters, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (terminal_id) DO UPDATE SET
+			filters = EXCLUDED.filters,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, in.TerminalID, in.Filters).Scan(&updatedAt)
+	if err != nil {
+		return domain.TerminalReplyFilters{}, err
+	}
+	in.Default = false
+	in.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return in, nil
+}
+
+func (s *Store) DeleteTerminalReplyFilters(ctx context.Context, terminalID string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM terminal_reply_filters WHERE terminal_id=$1`, terminalID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrReplyFiltersNotFound
+	}
+	return nil
+}
+
+// ListTerminalDesiredStates returns every terminal's desired state, for
+// loading the reconciler at startup.
+func (s *Store) ListTerminalDesiredStates(ctx context.Context) ([]domain.TerminalDesiredState, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT terminal_id, skills, updated_at
+		FROM terminal_desired_state
+		ORDER BY terminal_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.TerminalDesiredState, 0, 4)
+	for rows.Next() {
+		var item domain.TerminalDesiredState
+		var skills []byte
+		var updatedAt time.Time
+		if err := rows.Scan(&item.TerminalID, &skills, &updatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(skills, &item.Skills); err != nil {
+			return nil, fmt.Errorf("terminal %s desired state: %w", item.TerminalID, err)
+		}
+		item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) UpsertTerminalDesiredState(ctx context.Context, in domain.TerminalDesiredState) (domain.TerminalDesiredState, error) {
+	if in.Skills == nil {
+		in.Skills = map[string]json.RawMessage{}
+	}
+	skills, err := json.Marshal(in.Skills)
+	if err != nil {
+		return domain.TerminalDesiredState{}, err
+	}
+	var updatedAt time.Time
+	err = s.pool.QueryRow(ctx, `
+		INSERT INTO terminal_desired_state(terminal_id, skills, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (terminal_id) DO UPDATE SET
+			skills = EXCLUDED.skills,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, in.TerminalID, skills).Scan(&updatedAt)
+	if err != nil {
+		return domain.TerminalDesiredState{}, err
+	}
+	in.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return in, nil
+}
+
+func (s *Store) DeleteTerminalDesiredState(ctx context.Context, terminalID string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM terminal_desired_state WHERE terminal_id=$1`, terminalID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrDesiredStateNotFound
+	}
+	return nil
+}
+
+func (s *Store) ListVersionRequirements(ctx context.Context) ([]domain.VersionRequirement, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT capability, min_firmware_version, updated_at
+		FROM version_requirements
+		ORDER BY capability
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.VersionRequirement, 0, 4)
+	for rows.Next() {
+		var item domain.VersionRequirement
+		var updatedAt time.Time
+		if err := rows.Scan(&item.Capability, &item.MinFirmwareVersion, &updatedAt); err != nil {
+			return nil, err
+		}
+		item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+func (s *Store) UpsertVersionRequirement(ctx context.Context, in domain.VersionRequirement) (domain.VersionRequirement, error) {
+	var updatedAt time.Time
+	err := s.pool.QueryRow(ctx, `
+		INSERT INTO version_requirements(capability, min_firmware_version, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (capability) DO UPDATE SET
+			min_firmware_version = EXCLUDED.min_firmware_version,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at
+	`, in.Capability, in.MinFirmwareVersion).Scan(&updatedAt)
+	if err != nil {
+		return domain.VersionRequirement{}, err
+	}
+	in.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
+	return in, nil
+}
+
+func (s *Store) DeleteVersionRequirement(ctx context.Context, capability string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM version_requirements WHERE capability=$1`, capability)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRequirementNotFound
+	}
+	return nil
 }
 
-func (s *Store) BuildMemoryContext(ctx context.Context, soulID string) (string, error) {
-	profile, err := s.LoadSoulProfilePrompt(ctx, soulID)
+const configPushColumns = `id, request_id, terminal_id, config, status, COALESCE(error, ''), attempts, created_at, sent_at, acked_at`
+
+func scanConfigPush(row pgx.Row) (domain.TerminalConfigPush, error) {
+	var item domain.TerminalConfigPush
+	var config []byte
+	var createdAt time.Time
+	var sentAt, ackedAt *time.Time
+	if err := row.Scan(&item.ID, &item.RequestID, &item.TerminalID, &config, &item.Status, &item.Error, &item.Attempts, &createdAt, &sentAt, &ackedAt); err != nil {
+		return domain.TerminalConfigPush{}, err
+	}
+	if err := json.Unmarshal(config, &item.Config); err != nil {
+		return domain.TerminalConfigPush{}, err
+	}
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	if sentAt != nil {
+		item.SentAt = sentAt.UTC().Format(time.RFC3339Nano)
+	}
+	if ackedAt != nil {
+		item.AckedAt = ackedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return item, nil
+}
+
+// InsertTerminalConfigPush records a config push as pending, before it is
+// published.
+func (s *Store) InsertTerminalConfigPush(ctx context.Context, in domain.TerminalConfigPush) (domain.TerminalConfigPush, error) {
+	config, err := json.Marshal(in.Config)
 	if err != nil {
-		return "", err
+		return domain.TerminalConfigPush{}, err
 	}
-	episodes, err := s.GetRecentEpisodes(ctx, soulID, 3)
+	return scanConfigPush(s.pool.QueryRow(ctx, `
+		INSERT INTO terminal_config_pushes(request_id, terminal_id, config, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+configPushColumns, in.RequestID, in.TerminalID, config, domain.ConfigPushPending))
+}
+
+// MarkTerminalConfigPushSent counts one more publish of a push.
+func (s *Store) MarkTerminalConfigPushSent(ctx context.Context, id int64) (domain.TerminalConfigPush, error) {
+	item, err := scanConfigPush(s.pool.QueryRow(ctx, `
+		UPDATE terminal_config_pushes
+		SET attempts = attempts + 1, sent_at = NOW()
+		WHERE id=$1
+		RETURNING `+configPushColumns, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.TerminalConfigPush{}, ErrConfigPushNotFound
+	}
+	return item, err
+}
+
+// AckTerminalConfigPush settles a pending push as applied or rejected; a
+// push that is unknown or already settled is ErrConfigPushNotFound.
+func (s *Store) AckTerminalConfigPush(ctx context.Context, ack domain.TerminalConfigAck) (domain.TerminalConfigPush, error) {
+	status := domain.ConfigPushApplied
+	if !ack.OK {
+		status = domain.ConfigPushRejected
+	}
+	item, err := scanConfigPush(s.pool.QueryRow(ctx, `
+		UPDATE terminal_config_pushes
+		SET status = $3, error = $4, acked_at = NOW()
+		WHERE request_id=$1 AND terminal_id=$2 AND status=$5
+		RETURNING `+configPushColumns, ack.RequestID, ack.TerminalID, status, nullIfEmpty(ack.Error), domain.ConfigPushPending))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.TerminalConfigPush{}, ErrConfigPushNotFound
+	}
+	return item, err
+}
+
+// ListTerminalConfigPushes returns a terminal's pushes, newest first;
+// status filters when set.
+func (s *Store) ListTerminalConfigPushes(ctx context.Context, terminalID, status string, limit int) ([]domain.TerminalConfigPush, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT `+configPushColumns+`
+		FROM terminal_config_pushes
+		WHERE terminal_id=$1 AND ($2 = '' OR status = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, terminalID, status, limit)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer rows.Close()
 
-	if len(episodes) == 0 {
-		return profile, nil
+	out := make([]domain.TerminalConfigPush, 0, limit)
+	for rows.Next() {
+		item, err := scanConfigPush(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
 	}
+	return out, rows.Err()
+}
 
-	return profile + "\n近期片段记忆:\n- " + strings.Join(episodes, "\n- "), nil
+func scanTurnReplay(row pgx.Row) (domain.TurnReplay, error) {
+	var id int64
+	var record []byte
+	var createdAt time.Time
+	if err := row.Scan(&id, &record, &createdAt); err != nil {
+		return domain.TurnReplay{}, err
+	}
+	var item domain.TurnReplay
+	if err := json.Unmarshal(record, &item); err != nil {
+		return domain.TurnReplay{}, err
+	}
+	item.ID = id
+	item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	return item, nil
 }
 
-func (s *Store) CreateSoulUserRelation(ctx context.Context, soulID string, payload domain.CreateSoulUserRelationPayload) (domain.SoulUserRelation, error) {
-	soulID = strings.TrimSpace(soulID)
-	if soulID == "" {
-		return domain.SoulUserRelation{}, fmt.Errorf("soul_id is required")
+// InsertTurnReplay stores a recorded turn whole as JSON.
+func (s *Store) InsertTurnReplay(ctx context.Context, in domain.TurnReplay) (domain.TurnReplay, error) {
+	record, err := json.Marshal(in)
+	if err != nil {
+		return domain.TurnReplay{}, err
 	}
-	appellation := strings.TrimSpace(payload.Appellation)
-	if appellation == "" {
-		return domain.SoulUserRelation{}, fmt.Errorf("appellation is required")
+	return scanTurnReplay(s.pool.QueryRow(ctx, `
+		INSERT INTO turn_replays(session_id, terminal_id, soul_id, user_id, record)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, record, created_at
+	`, in.SessionID, in.TerminalID, nullIfEmpty(in.SoulID), nullIfEmpty(in.UserID), record))
+}
+
+func (s *Store) GetTurnReplay(ctx context.Context, id int64) (domain.TurnReplay, error) {
+	item, err := scanTurnReplay(s.pool.QueryRow(ctx, `SELECT id, record, created_at FROM turn_replays WHERE id=$1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.TurnReplay{}, ErrTurnReplayNotFound
 	}
-	relationToOwner := strings.TrimSpace(payload.RelationToOwner)
-	if relationToOwner == "" {
-		return domain.SoulUserRelation{}, fmt.Errorf("relation_to_owner is required")
+	return item, err
+}
+
+// ListTurnReplays returns recorded turns, newest first; sessionID filters
+// when set.
+func (s *Store) ListTurnReplays(ctx context.Context, sessionID string, limit int) ([]domain.TurnReplay, error) {
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT id, record, created_at
+		FROM turn_replays
+		WHERE ($1 = '' OR session_id = $1)
+		ORDER BY id DESC
+		LIMIT $2
+	`, sessionID, limit)
+	if err != nil {
+		return nil, err
 	}
-	relatedUserID := strings.TrimSpace(payload.RelatedUserID)
-	if relatedUserID != "" {
-		if _, err := s.GetUserByID(ctx, relatedUserID); err != nil {
-			return domain.SoulUserRelation{}, err
+	defer rows.Close()
+
+	out := make([]domain.TurnReplay, 0, limit)
+	for rows.Next() {
+		item, err := scanTurnReplay(rows)
+		if err != nil {
+			return nil, err
 		}
+		out = append(out, item)
 	}
+	return out, rows.Err()
+}
 
-	var personalityJSON any
-	if payload.PersonalityModel != nil {
-		raw, err := json.Marshal(payload.PersonalityModel)
+// SetSpeakerFace binds the camera's face id to a speaker profile of the
+// user; an empty faceID unbinds it. A face belongs to one profile, so it
+// is taken off any other profile of the user first.
+func (s *Store) SetSpeakerFace(ctx context.Context, userID, speakerID, faceID string) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if faceID != "" {
+			if _, err := tx.Exec(ctx, `UPDATE speaker_profiles SET face_id=NULL, updated_at=NOW() WHERE user_id=$1 AND face_id=$2 AND speaker_id<>$3`, userID, faceID, speakerID); err != nil {
+				return err
+			}
+		}
+		tag, err := tx.Exec(ctx, `UPDATE speaker_profiles SET face_id=$3, updated_at=NOW() WHERE user_id=$1 AND speaker_id=$2`, userID, speakerID, nullIfEmpty(faceID))
 		if err != nil {
-			return domain.SoulUserRelation{}, err
+			return err
 		}
-		personalityJSON = string(raw)
+		if tag.RowsAffected() == 0 {
+			return ErrSpeakerNotFound
+		}
+		return nil
+	})
+}
+
+// SetSessionPrivate marks a session private or public again. Only the flag
+// is stored; a session needs no row in sessions to be marked.
+func (s *Store) SetSessionPrivate(ctx context.Context, sessionID, userID string, private bool) error {
+	if !private {
+		_, err := s.pool.Exec(ctx, `DELETE FROM private_sessions WHERE session_id=$1`, sessionID)
+		return err
 	}
+	_, err := s.pool.Exec(ctx, `
+		INSERT INTO private_sessions(session_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (session_id) DO NOTHING
+	`, sessionID, userID)
+	return err
+}
 
-	var out domain.SoulUserRelation
-	var personalityRaw []byte
+func (s *Store) IsSessionPrivate(ctx context.Context, sessionID string) (bool, error) {
+	var private bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM private_sessions WHERE session_id=$1)`, sessionID).Scan(&private)
+	return private, err
+}
+
+// IsSessionForked reports whether the session was created by ForkSession.
+func (s *Store) IsSessionForked(ctx context.Context, sessionID string) (bool, error) {
+	var forked bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM sessions WHERE session_id=$1 AND forked_from IS NOT NULL)`, sessionID).Scan(&forked)
+	return forked, err
+}
+
+func (s *Store) InsertSafetyIncident(ctx context.Context, in domain.SafetyIncident) (domain.SafetyIncident, error) {
 	var createdAt time.Time
-	var updatedAt time.Time
 	err := s.pool.QueryRow(ctx, `
-		INSERT INTO soul_user_relations(
-			soul_id, related_user_id, appellation, relation_to_owner, user_description, personality_model
-		)
-		VALUES ($1, $2, $3, $4, $5, $6::jsonb)
-		RETURNING id, relation_uuid, soul_id, COALESCE(related_user_id, ''), appellation, relation_to_owner, user_description, personality_model, created_at, updated_at
-	`,
-		soulID,
-		nullIfEmpty(relatedUserID),
-		appellation,
-		relationToOwner,
-		strings.TrimSpace(payload.UserDescription),
-		personalityJSON,
-	).Scan(
-		&out.ID,
-		&out.RelationUUID,
-		&out.SoulID,
-		&out.RelatedUserID,
-		&out.Appellation,
-		&out.RelationToOwner,
-		&out.UserDescription,
-		&personalityRaw,
-		&createdAt,
-		&updatedAt,
-	)
+		INSERT INTO safety_incidents(session_id, user_id, terminal_id, soul_id, stage, skill, source, category, excerpt)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`, in.SessionID, in.UserID, in.TerminalID, nullIfEmpty(in.SoulID), in.Stage, nullIfEmpty(in.Skill), in.Source, nullIfEmpty(in.Category), nullIfEmpty(in.Excerpt)).Scan(&in.ID, &createdAt)
 	if err != nil {
-		return domain.SoulUserRelation{}, err
+		return domain.SafetyIncident{}, err
 	}
-	if len(personalityRaw) > 0 {
-		var model domain.PersonalityVector
-		if err := json.Unmarshal(personalityRaw, &model); err != nil {
-			return domain.SoulUserRelation{}, err
+	in.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+	return in, nil
+}
+
+// ListSafetyIncidents returns a user's latest incidents, newest first.
+func (s *Store) ListSafetyIncidents(ctx context.Context, userID string, limit int) ([]domain.SafetyIncident, error) {
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT id, session_id, user_id, terminal_id, COALESCE(soul_id, ''), stage, COALESCE(skill, ''), source, COALESCE(category, ''), COALESCE(excerpt, ''), created_at
+		FROM safety_incidents
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]domain.SafetyIncident, 0, limit)
+	for rows.Next() {
+		var item domain.SafetyIncident
+		var createdAt time.Time
+		if err := rows.Scan(&item.ID, &item.SessionID, &item.UserID, &item.TerminalID, &item.SoulID, &item.Stage, &item.Skill, &item.Source, &item.Category, &item.Excerpt, &createdAt); err != nil {
+			return nil, err
 		}
-		out.PersonalityModel = &model
+		item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		out = append(out, item)
+	}
+	return out, rows.Err()
+}
+
+// ListUserSessionIDs returns the user's sessions, including private ones
+// that never reached the sessions table.
+func (s *Store) ListUserSessionIDs(ctx context.Context, userID string) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT session_id FROM sessions WHERE user_id=$1
+		UNION
+		SELECT session_id FROM private_sessions WHERE user_id=$1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]string, 0, 16)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		out = append(out, id)
+	}
+	return out, rows.Err()
+}
+
+// userDataPurgeSteps deletes a user's rows, children before parents so the
+// RESTRICT keys on souls hold. intent_results has no user_id and goes by the
+// user's sessions; relations and voiceprints other users hold about this
+// user are removed too.
+var userDataPurgeSteps = []struct {
+	table string
+	query string
+}{
+	{"intent_results", `DELETE FROM intent_results WHERE session_id IN (SELECT session_id FROM sessions WHERE user_id=$1)`},
+	{"messages", `DELETE FROM messages WHERE user_id=$1`},
+	{"messages_archive", `DELETE FROM messages_archive WHERE user_id=$1`},
+	{"turn_replays", `DELETE FROM turn_replays WHERE user_id=$1`},
+	{"sessions", `DELETE FROM sessions WHERE user_id=$1`},
+	{"private_sessions", `DELETE FROM private_sessions WHERE user_id=$1`},
+	{"memory_episode", `DELETE FROM memory_episode WHERE user_id=$1`},
+	{"mem0_async_jobs", `DELETE FROM mem0_async_jobs WHERE user_id=$1`},
+	{"reminders", `DELETE FROM reminders WHERE user_id=$1`},
+	{"routines", `DELETE FROM routines WHERE user_id=$1`},
+	{"quiet_hours", `DELETE FROM quiet_hours WHERE user_id=$1`},
+	{"safety_incidents", `DELETE FROM safety_incidents WHERE user_id=$1`},
+	{"speaker_profiles", `DELETE FROM speaker_profiles WHERE user_id=$1 OR speaker_user_id=$1`},
+	{"soul_user_relations", `DELETE FROM soul_user_relations WHERE related_user_id=$1 OR soul_id IN (SELECT soul_id FROM souls WHERE user_id=$1)`},
+	{"knowledge_documents", `DELETE FROM knowledge_documents WHERE soul_id IN (SELECT soul_id FROM souls WHERE user_id=$1)`},
+	{"soul_exemplars", `DELETE FROM soul_exemplars WHERE soul_id IN (SELECT soul_id FROM souls WHERE user_id=$1)`},
+	{"soul_diary_entries", `DELETE FROM soul_diary_entries WHERE soul_id IN (SELECT soul_id FROM souls WHERE user_id=$1)`},
+	{"user_mood_alert_settings", `DELETE FROM user_mood_alert_settings WHERE user_id=$1`},
+	{"user_mood_alerts", `DELETE FROM user_mood_alerts WHERE user_id=$1`},
+	{"memory_corrections", `DELETE FROM memory_corrections WHERE user_id=$1`},
+	{"terminal_soul_groups", `DELETE FROM terminal_soul_groups WHERE user_id=$1`},
+	{"terminal_soul_bindings", `DELETE FROM terminal_soul_bindings WHERE user_id=$1 OR soul_id IN (SELECT soul_id FROM souls WHERE user_id=$1)`},
+	{"souls", `DELETE FROM souls WHERE user_id=$1`},
+	{"users", `DELETE FROM users WHERE user_id=$1`},
+}
+
+// PurgeUserData deletes everything stored about a user in one transaction
+// and records the purge in user_data_purges. Either every table is cleared
+// and audited or nothing changes. Purging an unknown user succeeds with
+// zero counts.
+func (s *Store) PurgeUserData(ctx context.Context, userID string, mem0Purged bool) (domain.UserDataPurge, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return domain.UserDataPurge{}, fmt.Errorf("user_id is required")
+	}
+	out := domain.UserDataPurge{UserID: userID, Deleted: make(map[string]int64, len(userDataPurgeSteps)), Mem0Purged: mem0Purged}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, step := range userDataPurgeSteps {
+			tag, err := tx.Exec(ctx, step.query, userID)
+			if err != nil {
+				return fmt.Errorf("purge %s: %w", step.table, err)
+			}
+			out.Deleted[step.table] = tag.RowsAffected()
+		}
+		deleted, err := json.Marshal(out.Deleted)
+		if err != nil {
+			return err
+		}
+		var purgedAt time.Time
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO user_data_purges(user_id, deleted, mem0_purged)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at
+		`, userID, deleted, mem0Purged).Scan(&out.ID, &purgedAt); err != nil {
+			return err
+		}
+		out.PurgedAt = purgedAt.UTC().Format(time.RFC3339Nano)
+		return nil
+	})
+	if err != nil {
+		return domain.UserDataPurge{}, err
 	}
-	out.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
-	out.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
 	return out, nil
 }
 
-func (s *Store) ListSoulUserRelations(ctx context.Context, soulID string) ([]domain.SoulUserRelation, error) {
-	soulID = strings.TrimSpace(soulID)
-	if soulID == "" {
-		return nil, fmt.Errorf("soul_id is required")
+// MigrateKnowledge creates the pgvector extension and the knowledge chunk
+// table. It is separate from Migrate so databases without pgvector keep
+// working while the knowledge base is disabled.
+func (s *Store) MigrateKnowledge(ctx context.Context) error {
+	for _, q := range []string{
+		`CREATE EXTENSION IF NOT EXISTS vector;`,
+		// The embedding column is unsized so changing the embedding model
+		// needs no migration; search scans one soul's chunks exactly.
+		`CREATE TABLE IF NOT EXISTS knowledge_chunks (
+			id BIGSERIAL PRIMARY KEY,
+			document_id BIGINT NOT NULL REFERENCES knowledge_documents(id) ON DELETE CASCADE,
+			soul_id TEXT NOT NULL,
+			seq INT NOT NULL,
+			content TEXT NOT NULL,
+			embedding vector NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_soul ON knowledge_chunks(soul_id);`,
+	} {
+		if _, err := s.pool.Exec(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertKnowledgeDocument stores a document with its chunks and their
+// embeddings (one vector per chunk) in one transaction.
+func (s *Store) InsertKnowledgeDocument(ctx context.Context, doc domain.KnowledgeDocument, chunks []string, vectors [][]float32) (domain.KnowledgeDocument, error) {
+	if len(chunks) != len(vectors) {
+		return domain.KnowledgeDocument{}, fmt.Errorf("%d chunks but %d embeddings", len(chunks), len(vectors))
+	}
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		var createdAt time.Time
+		err := tx.QueryRow(ctx, `
+			INSERT INTO knowledge_documents(soul_id, title, chars, chunk_count)
+			SELECT soul_id, $2, $3, $4 FROM souls WHERE soul_id=$1
+			RETURNING id, created_at
+		`, doc.SoulID, doc.Title, doc.Chars, len(chunks)).Scan(&doc.ID, &createdAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrSoulNotFound
+		}
+		if err != nil {
+			return err
+		}
+		doc.ChunkCount = len(chunks)
+		doc.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
+		batch := &pgx.Batch{}
+		for i, chunk := range chunks {
+			batch.Queue(`
+				INSERT INTO knowledge_chunks(document_id, soul_id, seq, content, embedding)
+				VALUES ($1, $2, $3, $4, $5::vector)
+			`, doc.ID, doc.SoulID, i, chunk, vectorLiteral(vectors[i]))
+		}
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return domain.KnowledgeDocument{}, err
 	}
+	return doc, nil
+}
+
+// ListKnowledgeDocuments returns a soul's documents, oldest first.
+func (s *Store) ListKnowledgeDocuments(ctx context.Context, soulID string) ([]domain.KnowledgeDocument, error) {
 	rows, err := s.pool.Query(ctx, `
-		SELECT id, relation_uuid, soul_id, COALESCE(related_user_id, ''), appellation, relation_to_owner, user_description, personality_model, created_at, updated_at
-		FROM soul_user_relations
+		SELECT id, soul_id, title, chars, chunk_count, created_at
+		FROM knowledge_documents
 		WHERE soul_id=$1
-		ORDER BY created_at ASC
+		ORDER BY id ASC
 	`, soulID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	out := make([]domain.SoulUserRelation, 0, 8)
+	out := make([]domain.KnowledgeDocument, 0, 4)
 	for rows.Next() {
-		var item domain.SoulUserRelation
-		var personalityRaw []byte
+		var item domain.KnowledgeDocument
 		var createdAt time.Time
-		var updatedAt time.Time
-		if err := rows.Scan(
-			&item.ID,
-			&item.RelationUUID,
-			&item.SoulID,
-			&item.RelatedUserID,
-			&item.Appellation,
-			&item.RelationToOwner,
-			&item.UserDescription,
-			&personalityRaw,
-			&createdAt,
-			&updatedAt,
-		); err != nil {
+		if err := rows.Scan(&item.ID, &item.SoulID, &item.Title, &item.Chars, &item.ChunkCount, &createdAt); err != nil {
 			return nil, err
 		}
-		if len(personalityRaw) > 0 {
-			var model domain.PersonalityVector
-			if err := json.Unmarshal(personalityRaw, &model); err != nil {
-				return nil, err
-			}
-			item.PersonalityModel = &model
-		}
 		item.CreatedAt = createdAt.UTC().Format(time.RFC3339Nano)
-		item.UpdatedAt = updatedAt.UTC().Format(time.RFC3339Nano)
 		out = append(out, item)
 	}
-	if err := rows.Err(); err != nil {
+	return out, rows.Err()
+}
+
+// HasKnowledge reports whether a soul has any document.
+func (s *Store) HasKnowledge(ctx context.Context, soulID string) (bool, error) {
+	var ok bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM knowledge_documents WHERE soul_id=$1)`, soulID).Scan(&ok)
+	return ok, err
+}
+
+func (s *Store) DeleteKnowledgeDocument(ctx context.Context, soulID string, id int64) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM knowledge_documents WHERE soul_id=$1 AND id=$2`, soulID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrKnowledgeDocumentNotFound
+	}
+	return nil
+}
+
+// SearchKnowledge returns the soul's chunks closest to vector by cosine
+// similarity, best first. Chunks embedded with a model of another size
+// are skipped.
+func (s *Store) SearchKnowledge(ctx context.Context, soulID string, vector []float32, limit int) ([]domain.KnowledgeHit, error) {
+	rows, err := s.reader(ctx).Query(ctx, `
+		SELECT c.document_id, d.title, c.seq, c.content, 1 - (c.embedding <=> $2::vector) AS score
+		FROM knowledge_chunks c
+		JOIN knowledge_documents d ON d.id = c.document_id
+		WHERE c.soul_id=$1 AND vector_dims(c.embedding) = $3
+		ORDER BY c.embedding <=> $2::vector
+		LIMIT $4
+	`, soulID, vectorLiteral(vector), len(vector), limit)
+	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	defer rows.Close()
+
+	out := make([]domain.KnowledgeHit, 0, limit)
+	for rows.Next() {
+		var item domain.KnowledgeHit
+		if err := rows.Scan(&item.DocumentID, &item.Title, &item.Seq, &item.Content, &item.Score); err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, rows.Err()
 }
 
-func nullIfEmpty(s string) any {
-	if s == "" {
-		return nil
+// vectorLiteral renders v in pgvector's text form, "[0.1,0.2]".
+func vectorLiteral(v []float32) string {
+	var sb strings.Builder
+	sb.Grow(len(v) * 10)
+	sb.WriteByte('[')
+	for i, x := range v {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
 	}
-	return s
+	sb.WriteByte(']')
+	return sb.String()
 }
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"soul/internal/domain"
+)
+
+// Benchmarks run against a real database:
+//
+//	SOUL_BENCH_DB_DSN=postgres://... go test ./internal/db -run '^$' -bench .
+func openBenchStore(b *testing.B) *Store {
+	b.Helper()
+	dsn := os.Getenv("SOUL_BENCH_DB_DSN")
+	if dsn == "" {
+		b.Skip("SOUL_BENCH_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	store, err := New(ctx, dsn, PoolOptions{MaxConns: 4})
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	b.Cleanup(store.Close)
+	if err := store.Migrate(ctx); err != nil {
+		b.Fatalf("migrate: %v", err)
+	}
+	return store
+}
+
+func seedBenchSouls(b *testing.B, store *Store, count int) string {
+	b.Helper()
+	ctx := context.Background()
+	userID := "bench_" + uuid.NewString()[:8]
+	if _, err := store.CreateUser(ctx, userID, userID, ""); err != nil {
+		b.Fatalf("create user: %v", err)
+	}
+	b.Cleanup(func() {
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM souls WHERE user_id=$1`, userID)
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM users WHERE user_id=$1`, userID)
+	})
+	vector := domain.PersonalityVector{Empathy: 0.5, Sensitivity: 0.5, Stability: 0.5, Expressiveness: 0.5, Dominance: 0.5}
+	for i := 0; i < count; i++ {
+		if _, err := store.CreateSoulProfile(ctx, userID, fmt.Sprintf("soul-%02d", i), "INTJ", vector, domain.SoulEmotionState{}, "bench"); err != nil {
+			b.Fatalf("create soul: %v", err)
+		}
+	}
+	return userID
+}
+
+// listSoulProfilesPerRow is the previous N+1 implementation kept for comparison.
+func listSoulProfilesPerRow(ctx context.Context, store *Store, userID string) ([]domain.SoulProfile, error) {
+	rows, err := store.pool.Query(ctx, `SELECT soul_id FROM souls WHERE user_id=$1 ORDER BY created_at ASC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	out := make([]domain.SoulProfile, 0, len(ids))
+	for _, id := range ids {
+		item, err := store.GetSoulProfileByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func BenchmarkListSoulProfiles(b *testing.B) {
+	store := openBenchStore(b)
+	userID := seedBenchSouls(b, store, 20)
+	ctx := context.Background()
+
+	b.Run("joined", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.ListSoulProfiles(ctx, userID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("per_row", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := listSoulProfilesPerRow(ctx, store, userID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetRecentMessages(b *testing.B) {
+	store := openBenchStore(b)
+	userID := seedBenchSouls(b, store, 1)
+	ctx := context.Background()
+	souls, err := store.ListSoulProfiles(ctx, userID)
+	if err != nil || len(souls) == 0 {
+		b.Fatalf("list souls: %v", err)
+	}
+	sessionID := "bench_" + uuid.NewString()
+	b.Cleanup(func() {
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM sessions WHERE session_id=$1`, sessionID)
+	})
+	for i := 0; i < 40; i++ {
+		if _, err := store.SaveMessage(ctx, sessionID, userID, "bench-terminal", souls[0].SoulID, "user", "", "", fmt.Sprintf("message %d", i)); err != nil {
+			b.Fatalf("save message: %v", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetRecentMessages(ctx, sessionID, 20); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
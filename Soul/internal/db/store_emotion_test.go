@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"soul/internal/domain"
+)
+
+// TestCompareAndSetSoulEmotionState runs against a real database:
+//
+//	SOUL_TEST_DB_DSN=postgres://... go test ./internal/db -run CompareAndSet
+func TestCompareAndSetSoulEmotionState(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	store, err := New(ctx, dsn, PoolOptions{MaxConns: 2})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	userID := "it_" + uuid.NewString()[:8]
+	t.Cleanup(func() {
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM souls WHERE user_id=$1`, userID)
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM users WHERE user_id=$1`, userID)
+	})
+	if _, err := store.CreateUser(ctx, userID, "", ""); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	soul, err := store.CreateSoulProfile(ctx, userID, "it_"+uuid.NewString()[:8], "INFP", domain.PersonalityVector{}, domain.SoulEmotionState{}, "persona-pad-v2")
+	if err != nil {
+		t.Fatalf("create soul: %v", err)
+	}
+
+	version, err := store.CompareAndSetSoulEmotionState(ctx, soul.SoulID, soul.EmotionVersion, domain.SoulEmotionState{P: 0.3})
+	if err != nil || version != soul.EmotionVersion+1 {
+		t.Fatalf("first write: version=%d err=%v", version, err)
+	}
+	if _, err := store.CompareAndSetSoulEmotionState(ctx, soul.SoulID, soul.EmotionVersion, domain.SoulEmotionState{P: -0.5}); !errors.Is(err, ErrSoulEmotionConflict) {
+		t.Fatalf("expected ErrSoulEmotionConflict for a stale version, got %v", err)
+	}
+	got, err := store.GetSoulProfileByID(ctx, soul.SoulID)
+	if err != nil || got.EmotionState.P != 0.3 || got.EmotionVersion != version {
+		t.Fatalf("stale write must not land: %v %+v", err, got)
+	}
+
+	if err := store.UpdateSoulEmotionState(ctx, soul.SoulID, domain.SoulEmotionState{}); err != nil {
+		t.Fatalf("unconditional write: %v", err)
+	}
+	if _, err := store.CompareAndSetSoulEmotionState(ctx, soul.SoulID, version, domain.SoulEmotionState{}); !errors.Is(err, ErrSoulEmotionConflict) {
+		t.Fatalf("unconditional writes must bump the version, got %v", err)
+	}
+	if _, err := store.CompareAndSetSoulEmotionState(ctx, "soul_missing_"+uuid.NewString(), 0, domain.SoulEmotionState{}); !errors.Is(err, ErrSoulNotFound) {
+		t.Fatalf("expected ErrSoulNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// TestForkSession runs against a real database:
+//
+//	SOUL_TEST_DB_DSN=postgres://... go test ./internal/db -run ForkSession
+func TestForkSession(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	store, err := New(ctx, dsn, PoolOptions{MaxConns: 2})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	userID := "it_" + uuid.NewString()[:8]
+	source := "it_" + uuid.NewString()
+	fork := source + "_fork"
+	t.Cleanup(func() {
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM sessions WHERE session_id IN ($1, $2)`, source, fork)
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM users WHERE user_id=$1`, userID)
+	})
+	if _, err := store.SaveTurn(ctx, TurnWrite{
+		SessionID: source, UserID: userID, TerminalID: "t1", SoulID: "soul_it",
+		Messages: []PendingMessage{{Role: "user", Content: "开灯"}, {Role: "assistant", Content: "好的"}},
+	}); err != nil {
+		t.Fatalf("save first turn: %v", err)
+	}
+	if _, err := store.SaveTurn(ctx, TurnWrite{
+		SessionID: source, UserID: userID, TerminalID: "t1", SoulID: "soul_it",
+		Messages: []PendingMessage{{Role: "user", Content: "关灯"}, {Role: "assistant", Content: "已关"}},
+	}); err != nil {
+		t.Fatalf("save second turn: %v", err)
+	}
+
+	msgs, err := store.ListSessionMessages(ctx, source, 10)
+	if err != nil || len(msgs) != 4 {
+		t.Fatalf("list source messages: %v %+v", err, msgs)
+	}
+
+	result, err := store.ForkSession(ctx, source, fork, msgs[1].ID)
+	if err != nil {
+		t.Fatalf("fork: %v", err)
+	}
+	if result.CopiedMessages != 2 || result.SoulID != "soul_it" || result.TerminalID != "t1" {
+		t.Fatalf("unexpected fork result: %+v", result)
+	}
+	forked, err := store.ListSessionMessages(ctx, fork, 10)
+	if err != nil || len(forked) != 2 || forked[1].Content != "好的" {
+		t.Fatalf("unexpected forked history: %v %+v", err, forked)
+	}
+	if isFork, err := store.IsSessionForked(ctx, fork); err != nil || !isFork {
+		t.Fatalf("expected the fork to be marked forked: %v %v", isFork, err)
+	}
+	if isFork, err := store.IsSessionForked(ctx, source); err != nil || isFork {
+		t.Fatalf("expected the source not to be marked forked: %v %v", isFork, err)
+	}
+
+	if _, err := store.ForkSession(ctx, source, fork, 0); !errors.Is(err, ErrSessionExists) {
+		t.Fatalf("expected ErrSessionExists, got %v", err)
+	}
+	if _, err := store.ForkSession(ctx, source, fork+"_x", forked[0].ID); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound for a message of another session, got %v", err)
+	}
+}
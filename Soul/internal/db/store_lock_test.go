@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestLock runs against a real database:
+//
+//	SOUL_TEST_DB_DSN=postgres://... go test ./internal/db -run TestLock
+func TestLock(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	store, err := New(ctx, dsn, PoolOptions{MaxConns: 4})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer store.Close()
+
+	key := "soul:test:" + time.Now().Format(time.RFC3339Nano)
+	release, ok, err := store.Lock(ctx, key, false)
+	if err != nil || !ok {
+		t.Fatalf("first lock: ok=%v err=%v", ok, err)
+	}
+	if _, ok, err := store.Lock(ctx, key, false); err != nil || ok {
+		t.Fatalf("a held lock must not be taken again: ok=%v err=%v", ok, err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if _, _, err := store.Lock(waitCtx, key, true); err == nil {
+		t.Fatal("waiting on a held lock must end with ctx")
+	}
+
+	release()
+	again, ok, err := store.Lock(ctx, key, true)
+	if err != nil || !ok {
+		t.Fatalf("lock after release: ok=%v err=%v", ok, err)
+	}
+	again()
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestAppendTurnMessagesAfterHandoff runs against a real database:
+//
+//	SOUL_TEST_DB_DSN=postgres://... go test ./internal/db -run AppendTurnMessages
+func TestAppendTurnMessagesAfterHandoff(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	store, err := New(ctx, dsn, PoolOptions{MaxConns: 2})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	userID := "it_" + uuid.NewString()[:8]
+	sessionID := "it_" + uuid.NewString()
+	t.Cleanup(func() {
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM sessions WHERE session_id=$1`, sessionID)
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM users WHERE user_id=$1`, userID)
+	})
+	turn := TurnWrite{
+		SessionID: sessionID, UserID: userID, TerminalID: "t1", SoulID: "soul_it",
+		Messages: []PendingMessage{{Role: "user", Content: "开灯"}, {Role: "assistant", Content: "好的"}},
+	}
+	if _, err := store.SaveTurn(ctx, turn); err != nil {
+		t.Fatalf("save turn: %v", err)
+	}
+
+	// The session moves to t2 before the turn's tool output is flushed.
+	if err := store.UpdateSessionTerminal(ctx, sessionID, "t2"); err != nil {
+		t.Fatalf("handoff: %v", err)
+	}
+	deferred := turn
+	deferred.Messages = []PendingMessage{{Role: "tool", Name: "control_light", ToolCallID: "call_1", Content: "ok"}}
+	if n, err := store.AppendTurnMessages(ctx, deferred); err != nil || n != 1 {
+		t.Fatalf("append: %d %v", n, err)
+	}
+	session, err := store.GetSession(ctx, sessionID)
+	if err != nil || session.TerminalID != "t2" {
+		t.Fatalf("session after flush: %+v %v; want it to stay on t2", session, err)
+	}
+	msgs, err := store.ListSessionMessages(ctx, sessionID, 10)
+	if err != nil || len(msgs) != 3 {
+		t.Fatalf("messages: %v %+v", err, msgs)
+	}
+
+	// A flush after the user's data was deleted stores nothing and brings
+	// neither the session nor the user back.
+	if _, err := store.pool.Exec(ctx, `DELETE FROM sessions WHERE session_id=$1`, sessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.pool.Exec(ctx, `DELETE FROM users WHERE user_id=$1`, userID); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.AppendTurnMessages(ctx, deferred); err != nil || n != 0 {
+		t.Fatalf("append after delete: %d %v", n, err)
+	}
+	if _, err := store.GetSession(ctx, sessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("session recreated: %v", err)
+	}
+	var users int
+	if err := store.pool.QueryRow(ctx, `SELECT COUNT(*) FROM users WHERE user_id=$1`, userID).Scan(&users); err != nil || users != 0 {
+		t.Fatalf("user recreated: %d %v", users, err)
+	}
+}
+
+// TestDeferredMessagesReadInTurnOrder runs against a real database:
+//
+//	SOUL_TEST_DB_DSN=postgres://... go test ./internal/db -run DeferredMessages
+func TestDeferredMessagesReadInTurnOrder(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	store, err := New(ctx, dsn, PoolOptions{MaxConns: 2})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	userID := "it_" + uuid.NewString()[:8]
+	sessionID := "it_" + uuid.NewString()
+	forkID := "it_" + uuid.NewString()
+	t.Cleanup(func() {
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM sessions WHERE session_id IN ($1, $2)`, sessionID, forkID)
+		_, _ = store.pool.Exec(context.Background(), `DELETE FROM users WHERE user_id=$1`, userID)
+	})
+	start := time.Now().Add(-time.Minute)
+	turn := TurnWrite{
+		SessionID: sessionID, UserID: userID, TerminalID: "t1", SoulID: "soul_it",
+		Messages: []PendingMessage{
+			{Role: "user", Content: "开灯", At: start},
+			{Role: "assistant", Content: "好的", At: start.Add(2 * time.Second)},
+		},
+	}
+	if _, err := store.SaveTurn(ctx, turn); err != nil {
+		t.Fatalf("save turn: %v", err)
+	}
+	// The tool row is flushed after the reply, so its id is higher.
+	deferred := turn
+	deferred.Messages = []PendingMessage{{Role: "tool", Name: "control_light", ToolCallID: "call_1", Content: "ok", At: start.Add(time.Second)}}
+	if _, err := store.AppendTurnMessages(ctx, deferred); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	msgs, err := store.ListSessionMessages(ctx, sessionID, 10)
+	if err != nil || len(msgs) != 3 {
+		t.Fatalf("messages: %v %+v", err, msgs)
+	}
+	if msgs[0].Role != "user" || msgs[1].Role != "tool" || msgs[2].Role != "assistant" {
+		t.Fatalf("messages out of turn order: %+v", msgs)
+	}
+	chunks, err := store.GetMessagesSince(ctx, sessionID, 0, 10)
+	if err != nil || len(chunks) != 3 || chunks[1].Role != "tool" {
+		t.Fatalf("compaction chunks: %v %+v", err, chunks)
+	}
+	fork, err := store.ForkSession(ctx, sessionID, forkID, msgs[2].ID)
+	if err != nil || fork.CopiedMessages != 3 {
+		t.Fatalf("fork at the reply must keep the turn's tool row: %+v %v", fork, err)
+	}
+}
@@ -16,6 +16,10 @@ type IntentFilterRequest struct {
 	Command       string              `json:"command"`
 	IntentCatalog []IntentSpec        `json:"intent_catalog"`
 	Options       IntentFilterOptions `json:"options"`
+	// Locale overrides the locale the service detects from the command.
+	Locale string `json:"locale,omitempty"`
+	// TerminalID scopes the client's response cache; it is not sent.
+	TerminalID string `json:"-"`
 }
 
 type IntentFilterTextSpan struct {
@@ -55,3 +59,35 @@ type IntentFilterResponse struct {
 	Decision  IntentFilterDecision `json:"decision"`
 	Meta      map[string]any       `json:"meta"`
 }
+
+// IntentMatcherLocal is Meta["matcher"] of a response the client matched
+// in process because the intent filter service could not be reached.
+const IntentMatcherLocal = "local"
+
+// MatchedLocally reports whether r came from the local matcher.
+func (r IntentFilterResponse) MatchedLocally() bool {
+	m, _ := r.Meta["matcher"].(string)
+	return m == IntentMatcherLocal
+}
+
+// IntentSpeculationRequest carries a partial transcript to match before the
+// user has finished speaking.
+type IntentSpeculationRequest struct {
+	UserID      string `json:"user_id,omitempty"`
+	SessionID   string `json:"session_id"`
+	TerminalID  string `json:"terminal_id"`
+	UtteranceID string `json:"utterance_id"`
+	Text        string `json:"text"`
+}
+
+// IntentSpeculation is what the partials of an utterance match so far.
+// Stable is set once Streak partials in a row agreed on the same ready
+// action intents; a final transcript equal to Text then fires them without
+// matching again.
+type IntentSpeculation struct {
+	UtteranceID string             `json:"utterance_id"`
+	Text        string             `json:"text"`
+	Stable      bool               `json:"stable"`
+	Streak      int                `json:"streak"`
+	Intents     []IntentActionItem `json:"intents,omitempty"`
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type ChatRequest struct {
 	UserID     string      `json:"user_id,omitempty"`
@@ -9,18 +12,143 @@ type ChatRequest struct {
 	SoulID     string      `json:"soul_id,omitempty"`
 	SoulHint   string      `json:"soul_hint,omitempty"`
 	Inputs     []ChatInput `json:"inputs"`
+	// DryRun runs intent matching and tool selection but only announces what
+	// would execute; nothing is sent to the terminal.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Language ("zh" or "en") pins the reply language for this request,
+	// overriding the user's preference and the detected input language.
+	Language string `json:"language,omitempty"`
+	// ForceExecute runs the turn's skills whatever the emotion gate says.
+	// soul-server sets it only for requests carrying the gate override
+	// token; it cannot be sent in the body.
+	ForceExecute bool `json:"-"`
 }
 
 type ChatResponse struct {
-	SessionID       string   `json:"session_id"`
-	TerminalID      string   `json:"terminal_id"`
-	SoulID          string   `json:"soul_id"`
-	Reply           string   `json:"reply"`
-	ExecutedSkills  []string `json:"executed_skills,omitempty"`
-	ContextSummary  string   `json:"context_summary,omitempty"`
-	IntentDecision  string   `json:"intent_decision,omitempty"`
-	ExecMode        string   `json:"exec_mode,omitempty"`
-	ExecProbability float64  `json:"exec_probability,omitempty"`
+	SessionID       string           `json:"session_id"`
+	TerminalID      string           `json:"terminal_id"`
+	SoulID          string           `json:"soul_id"`
+	Reply           string           `json:"reply"`
+	ExecutedSkills  []string         `json:"executed_skills,omitempty"`
+	ContextSummary  string           `json:"context_summary,omitempty"`
+	IntentDecision  string           `json:"intent_decision,omitempty"`
+	ExecMode        string           `json:"exec_mode,omitempty"`
+	ExecProbability float64          `json:"exec_probability,omitempty"`
+	Speaker         *SpeakerIdentity `json:"speaker,omitempty"`
+	FollowUp        bool             `json:"follow_up,omitempty"`
+	DryRun          bool             `json:"dry_run,omitempty"`
+	// QuietHours is set when the turn fell in the user's quiet hours; the
+	// reply should be shown, not spoken.
+	QuietHours bool `json:"quiet_hours,omitempty"`
+	// Private is set while the session is in privacy mode: nothing of it is
+	// stored, summarised or written to long-term memory.
+	Private bool `json:"private,omitempty"`
+	// SafetyBlocked is set when the safety filter replaced the reply or
+	// held back a skill call.
+	SafetyBlocked bool `json:"safety_blocked,omitempty"`
+	// ChildMode is set when the soul is in child mode.
+	ChildMode bool `json:"child_mode,omitempty"`
+	// Language is the language the reply was asked for.
+	Language string `json:"language,omitempty"`
+	// Topics are the conversation topics this turn was labelled with.
+	Topics []string `json:"topics,omitempty"`
+	// SoulEmotion and Personality are the soul's state after this turn, for
+	// clients that shape delivery by it, such as voice prosody.
+	SoulEmotion *SoulEmotionState  `json:"soul_emotion,omitempty"`
+	Personality *PersonalityVector `json:"personality,omitempty"`
+	// RecalledMemories are the long-term memories recall_memory returned
+	// this turn, with where and when each came from.
+	RecalledMemories []RecalledMemory `json:"recalled_memories,omitempty"`
+	// ReplyRevised is set when the reply critic rewrote the drafted reply.
+	ReplyRevised bool `json:"reply_revised,omitempty"`
+	// Degraded names the dependencies the turn skipped to stay within its
+	// latency budget: emotion, intent or mem0; intent_local means intents
+	// were matched in process because the intent filter service was down.
+	Degraded []string `json:"degraded,omitempty"`
+}
+
+// RecalledMemory is one long-term memory handed to the LLM, with its
+// provenance: the mem0 entry, the session it was written from and that
+// session's stored episode. At is when the memory was made (RFC 3339),
+// empty when unknown.
+type RecalledMemory struct {
+	MemoryID  string  `json:"memory_id,omitempty"`
+	EpisodeID int64   `json:"episode_id,omitempty"`
+	SessionID string  `json:"session_id,omitempty"`
+	Content   string  `json:"content"`
+	At        string  `json:"at,omitempty"`
+	Score     float64 `json:"score,omitempty"`
+	// Tags are the tags of the episode the memory came from.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// EpisodeRef points at a stored session summary in memory_episode.
+type EpisodeRef struct {
+	ID        int64
+	SessionID string
+	CreatedAt time.Time
+	Tags      []string
+}
+
+// EpisodeMeta is what is worked out about a session when its episode is
+// stored. Sentiment is the user's average valence (-1..1), nil without
+// analysed turns; Importance runs from 1 (small talk) to 5, 0 when unrated.
+type EpisodeMeta struct {
+	Tags         []string `json:"tags"`
+	Participants []string `json:"participants"`
+	Sentiment    *float64 `json:"sentiment,omitempty"`
+	Importance   int      `json:"importance,omitempty"`
+}
+
+// MemoryEpisode is a stored session summary with its metadata.
+type MemoryEpisode struct {
+	ID           int64    `json:"id"`
+	UserID       string   `json:"user_id"`
+	SoulID       string   `json:"soul_id"`
+	SessionID    string   `json:"session_id,omitempty"`
+	TerminalID   string   `json:"terminal_id"`
+	Summary      string   `json:"summary"`
+	Tags         []string `json:"tags"`
+	Participants []string `json:"participants"`
+	Sentiment    *float64 `json:"sentiment,omitempty"`
+	Importance   int      `json:"importance,omitempty"`
+	// Correction is the user's fix to this episode, if any.
+	Correction *string `json:"correction,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+// EpisodeFilter scopes an episode listing. Empty fields match everything;
+// Sentiment is positive, negative or neutral.
+type EpisodeFilter struct {
+	SoulID        string
+	Tags          []string
+	Participant   string
+	MinImportance int
+	Sentiment     string
+	Limit         int
+}
+
+const (
+	MemoryCorrectionSourceChat = "chat"
+	MemoryCorrectionSourceAPI  = "api"
+)
+
+// MemoryCorrection records a fix to something the robot remembered
+// wrongly: the mem0 memory was overwritten with Correction (or deleted when
+// it is empty) and the session episode it came from was marked. Original
+// keeps the text as it was. As a request, Original picks the memory to fix
+// when neither MemoryID nor EpisodeID is given.
+type MemoryCorrection struct {
+	ID         int64  `json:"id,omitempty"`
+	UserID     string `json:"user_id,omitempty"`
+	SoulID     string `json:"soul_id,omitempty"`
+	MemoryID   string `json:"memory_id,omitempty"`
+	EpisodeID  int64  `json:"episode_id,omitempty"`
+	SessionID  string `json:"session_id,omitempty"`
+	Original   string `json:"original,omitempty"`
+	Correction string `json:"correction"`
+	Source     string `json:"source,omitempty"`
+	CreatedAt  string `json:"created_at,omitempty"`
 }
 
 type Message struct {
@@ -29,12 +157,46 @@ type Message struct {
 	Name       string
 	ToolCallID string
 	ToolCalls  []ToolCall
+	// Thinking carries the provider's reasoning blocks of an assistant turn
+	// so a tool loop can hand them back unchanged.
+	Thinking []ThinkingBlock
+	// Images are shown to vision models with a user message.
+	Images []MessageImage
+}
+
+// MessageImage is an image attached to a message. Ref names where it came
+// from (an object key); the bytes are left out of JSON so recorded turns
+// stay small.
+type MessageImage struct {
+	Mime string
+	Ref  string
+	Data []byte `json:"-"`
 }
 
 type SkillDefinition struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	InputSchema json.RawMessage `json:"input_schema"`
+	// URL, when set, makes soul-server execute the skill by POSTing to it
+	// instead of invoking the terminal over MQTT.
+	URL  string     `json:"url,omitempty"`
+	Auth *SkillAuth `json:"auth,omitempty"`
+	// Priority is realtime for expressive skills that must not wait behind
+	// slow ones; empty means normal.
+	Priority string `json:"priority,omitempty"`
+	// BypassGate lets the skill run while the emotion gate is blocked;
+	// it is meant for safety-critical skills like stop_motion.
+	BypassGate bool `json:"bypass_gate,omitempty"`
+}
+
+// SkillAuth says how soul-server authenticates to an HTTP skill. The secret
+// itself stays in soul-server's environment, so skill definitions reported
+// by terminals or published in bundles never carry it.
+type SkillAuth struct {
+	// Type is bearer (Authorization: Bearer <token>) or header (<Header>: <token>).
+	Type     string `json:"type"`
+	Header   string `json:"header,omitempty"`
+	TokenEnv string `json:"token_env"`
 }
 
 type ToolCall struct {
@@ -54,21 +216,41 @@ type LLMRequest struct {
 	System   string
 	Tools    []LLMTool
 	Messages []Message
+	// ResponseFormat asks the provider for a JSON reply matching the schema,
+	// using its native structured-output mode when it has one.
+	ResponseFormat *LLMResponseFormat
+}
+
+type LLMResponseFormat struct {
+	Name   string
+	Schema json.RawMessage
 }
 
 type LLMResponse struct {
 	Content   string
 	ToolCalls []ToolCall
+	Thinking  []ThinkingBlock
+}
+
+// ThinkingBlock is an opaque extended-thinking block. Redacted blocks only
+// carry Data; both kinds must be returned with their signature intact.
+type ThinkingBlock struct {
+	Thinking  string
+	Signature string
+	Redacted  bool
+	Data      string
 }
 
 type ChatInput struct {
-	InputID string          `json:"input_id,omitempty"`
-	Type    string          `json:"type"`
-	Source  string          `json:"source,omitempty"`
-	TS      string          `json:"ts,omitempty"`
-	Text    string          `json:"text,omitempty"`
-	Media   *InputMedia     `json:"media,omitempty"`
-	Data    json.RawMessage `json:"data,omitempty"`
+	InputID          string           `json:"input_id,omitempty"`
+	Type             string           `json:"type"`
+	Source           string           `json:"source,omitempty"`
+	TS               string           `json:"ts,omitempty"`
+	Text             string           `json:"text,omitempty"`
+	Media            *InputMedia      `json:"media,omitempty"`
+	Data             json.RawMessage  `json:"data,omitempty"`
+	SpeakerEmbedding []float64        `json:"speaker_embedding,omitempty"`
+	Speaker          *SpeakerIdentity `json:"speaker,omitempty"`
 }
 
 type InputMedia struct {
@@ -81,13 +263,59 @@ type InputMedia struct {
 	ChecksumSHA256 string `json:"checksum_sha256,omitempty"`
 }
 
+// MediaUploadRequest asks for a presigned URL to upload one photo or audio
+// clip before attaching it to a ChatInput.
+type MediaUploadRequest struct {
+	TerminalID     string `json:"terminal_id"`
+	Mime           string `json:"mime"`
+	SizeBytes      int64  `json:"size_bytes"`
+	ChecksumSHA256 string `json:"checksum_sha256,omitempty"`
+}
+
+// MediaUpload tells the terminal where to PUT the file and which headers
+// to send; Media goes into ChatInput.media once the upload succeeded.
+type MediaUpload struct {
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Media     InputMedia        `json:"media"`
+}
+
 // MQTT payloads
 
 type SkillReport struct {
-	TerminalID   string            `json:"terminal_id"`
-	SoulHint     string            `json:"soul_hint,omitempty"`
-	SkillVersion int64             `json:"skill_version,omitempty"`
-	Skills       []SkillDefinition `json:"skills"`
+	TerminalID   string                      `json:"terminal_id"`
+	SoulHint     string                      `json:"soul_hint,omitempty"`
+	SkillVersion int64                       `json:"skill_version,omitempty"`
+	Skills       []SkillDefinition           `json:"skills"`
+	Output       *TerminalOutputCapabilities `json:"output,omitempty"`
+	Capabilities *TerminalCapabilities       `json:"capabilities,omitempty"`
+	// FirmwareVersion is dotted numbers like 1.4.2; BundleVersions maps each
+	// installed skill bundle to its version.
+	FirmwareVersion string           `json:"firmware_version,omitempty"`
+	BundleVersions  map[string]int64 `json:"bundle_versions,omitempty"`
+}
+
+// TerminalCapabilities is the hardware a terminal physically has, so the
+// LLM does not offer actions it cannot carry out, e.g. a screen-only desk
+// clock reports audio_out=false and no motors.
+type TerminalCapabilities struct {
+	AudioOut bool `json:"audio_out"`
+	Display  bool `json:"display"`
+	// Motors names the movable parts, e.g. head_pan, head_tilt, wheels;
+	// empty means nothing moves.
+	Motors         []string `json:"motors,omitempty"`
+	BatteryPowered bool     `json:"battery_powered"`
+}
+
+// TerminalOutputCapabilities describes how replies are rendered on the terminal,
+// e.g. a 2-line OLED with TTS reports has_screen=true, has_tts=true, max_chars=32.
+type TerminalOutputCapabilities struct {
+	HasScreen   bool `json:"has_screen"`
+	HasTTS      bool `json:"has_tts"`
+	MaxChars    int  `json:"max_chars,omitempty"`
+	ScreenLines int  `json:"screen_lines,omitempty"`
 }
 
 type IntentMatchRules struct {
@@ -112,6 +340,17 @@ type IntentSlotBinding struct {
 	FromTimeKey         string   `json:"from_time_key,omitempty"`
 	TimeKind            string   `json:"time_kind,omitempty"`
 	Default             any      `json:"default,omitempty"`
+	// Enum lists the values the terminal accepts for the slot. Captured
+	// text matching a value or one of its aliases is sent as the value;
+	// see intent.NormalizeSlots.
+	Enum []IntentSlotValue `json:"enum,omitempty"`
+}
+
+// IntentSlotValue is one canonical slot value and the words that mean it,
+// e.g. {"value": "green", "aliases": ["绿", "绿色", "变绿"]}.
+type IntentSlotValue struct {
+	Value   string   `json:"value"`
+	Aliases []string `json:"aliases,omitempty"`
 }
 
 type IntentSpec struct {
@@ -129,10 +368,104 @@ type IntentCatalogReport struct {
 	IntentCatalog  []IntentSpec `json:"intent_catalog"`
 }
 
+// SkillBundle is a reusable capability shipped as data: skill definitions
+// for the LLM plus the intents that route to them. Terminals enable bundles
+// by name instead of hard-coding the definitions they report.
+type SkillBundle struct {
+	Name        string            `json:"name"`
+	Version     int64             `json:"version"`
+	Description string            `json:"description,omitempty"`
+	Skills      []SkillDefinition `json:"skills"`
+	Intents     []IntentSpec      `json:"intents,omitempty"`
+	UpdatedAt   string            `json:"updated_at,omitempty"`
+}
+
+type PublishSkillBundlePayload struct {
+	Description string            `json:"description,omitempty"`
+	Skills      []SkillDefinition `json:"skills"`
+	Intents     []IntentSpec      `json:"intents,omitempty"`
+}
+
+// VersionRequirement is the lowest firmware a terminal needs before
+// soul-server calls the capability (a skill name) on it.
+type VersionRequirement struct {
+	Capability         string `json:"capability"`
+	MinFirmwareVersion string `json:"min_firmware_version"`
+	UpdatedAt          string `json:"updated_at,omitempty"`
+}
+
+type VersionRequirementPayload struct {
+	MinFirmwareVersion string `json:"min_firmware_version"`
+}
+
+// TerminalVersions is what a terminal reported it runs and what it must
+// upgrade before the server's requirements and published bundles are met.
+type TerminalVersions struct {
+	TerminalID      string           `json:"terminal_id"`
+	FirmwareVersion string           `json:"firmware_version,omitempty"`
+	BundleVersions  map[string]int64 `json:"bundle_versions,omitempty"`
+	UpgradeNeeded   bool             `json:"upgrade_needed"`
+	Upgrades        []UpgradeItem    `json:"upgrades"`
+}
+
+// Upgrade kinds.
+const (
+	UpgradeKindFirmware = "firmware"
+	UpgradeKindBundle   = "bundle"
+)
+
+// UpgradeItem is one thing a terminal is behind on: the firmware a
+// capability requires, or an enabled skill bundle older than the published
+// one.
+type UpgradeItem struct {
+	Kind       string `json:"kind"`
+	Capability string `json:"capability"`
+	Current    string `json:"current"`
+	Required   string `json:"required"`
+}
+
+type TerminalSkillBundles struct {
+	TerminalID string        `json:"terminal_id"`
+	Bundles    []SkillBundle `json:"bundles"`
+}
+
 type InvokeRequest struct {
 	RequestID string          `json:"request_id"`
 	Skill     string          `json:"skill"`
 	Arguments json.RawMessage `json:"arguments"`
+	// Priority is realtime for skills that declared it; terminals should run
+	// those on their own worker instead of behind slow normal skills.
+	Priority string `json:"priority,omitempty"`
+}
+
+// Invoke priorities. Expressive, low-latency skills (head motions, facial
+// expressions) declare realtime; everything else is normal.
+const (
+	InvokePriorityNormal   = "normal"
+	InvokePriorityRealtime = "realtime"
+)
+
+// SkillStats is how a skill has fared on one terminal since soul-server
+// started. The recent_* fields cover the last few calls only and drive Flaky.
+type SkillStats struct {
+	Skill            string  `json:"skill"`
+	Calls            int64   `json:"calls"`
+	Successes        int64   `json:"successes"`
+	Failures         int64   `json:"failures"`
+	SuccessRate      float64 `json:"success_rate"`
+	AvgLatencyMS     int64   `json:"avg_latency_ms"`
+	MaxLatencyMS     int64   `json:"max_latency_ms"`
+	RecentCalls      int     `json:"recent_calls"`
+	RecentFailures   int     `json:"recent_failures"`
+	Flaky            bool    `json:"flaky"`
+	LastError        string  `json:"last_error,omitempty"`
+	LastFailureAt    string  `json:"last_failure_at,omitempty"`
+	LastInvocationAt string  `json:"last_invocation_at,omitempty"`
+}
+
+type TerminalSkillStats struct {
+	TerminalID string       `json:"terminal_id"`
+	Items      []SkillStats `json:"items"`
 }
 
 type InvokeResult struct {
@@ -140,6 +473,12 @@ type InvokeResult struct {
 	OK        bool   `json:"ok"`
 	Output    string `json:"output"`
 	Error     string `json:"error,omitempty"`
+	// Confirmation, when set, is said to the user after the call, e.g. the
+	// rule a created routine will follow.
+	Confirmation string `json:"confirmation,omitempty"`
+	// Media is the object a skill uploaded, such as the photo capture_image
+	// took.
+	Media *InputMedia `json:"media,omitempty"`
 }
 
 type EmotionSignal struct {
@@ -149,6 +488,15 @@ type EmotionSignal struct {
 	D          float64 `json:"d"`
 	Intensity  float64 `json:"intensity"`
 	Confidence float64 `json:"confidence,omitempty"`
+	// Candidates are the most likely labels, best first, with calibrated
+	// probabilities; Ambiguous is set when the top label barely leads.
+	Candidates []EmotionCandidate `json:"candidates,omitempty"`
+	Ambiguous  bool               `json:"ambiguous,omitempty"`
+}
+
+type EmotionCandidate struct {
+	Emotion string  `json:"emotion"`
+	Score   float64 `json:"score"`
 }
 
 type PersonalityVector struct {
@@ -187,6 +535,85 @@ type SoulProfile struct {
 	ModelVersion      string            `json:"model_version"`
 	CreatedAt         string            `json:"created_at,omitempty"`
 	UpdatedAt         string            `json:"updated_at,omitempty"`
+	// EmotionVersion counts writes to EmotionState; a write made from a
+	// stale read is refused, so replicas cannot overwrite each other.
+	EmotionVersion int64 `json:"emotion_version"`
+	// ChildMode keeps replies short and simple, withholds risky skills and
+	// uses the child-safe system prompt.
+	ChildMode bool `json:"child_mode"`
+	// CharacterCard is the soul's freeform persona, added to the system
+	// prompt; nil when none is set.
+	CharacterCard *SoulCharacterCard `json:"character_card,omitempty"`
+}
+
+// SoulCharacterCard describes a soul in words: its background, how it
+// talks and what it will not talk about.
+type SoulCharacterCard struct {
+	Background    string   `json:"background,omitempty"`
+	SpeakingStyle string   `json:"speaking_style,omitempty"`
+	TabooTopics   []string `json:"taboo_topics,omitempty"`
+}
+
+// SoulPrompt is a soul's character card as served by /v1/souls/{id}/prompt.
+type SoulPrompt struct {
+	SoulID        string   `json:"soul_id"`
+	Background    string   `json:"background"`
+	SpeakingStyle string   `json:"speaking_style"`
+	TabooTopics   []string `json:"taboo_topics"`
+	UpdatedAt     string   `json:"updated_at,omitempty"`
+}
+
+// SoulExemplar is an example exchange that shows how a soul talks; a soul's
+// exemplars lead the messages of every LLM request.
+type SoulExemplar struct {
+	ID        int64  `json:"id"`
+	SoulID    string `json:"soul_id"`
+	User      string `json:"user"`
+	Reply     string `json:"reply"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// MoodAlertSettings is a user's choice about low-mood alerts. Zero Days or
+// NegativeShare use the server defaults.
+type MoodAlertSettings struct {
+	UserID  string `json:"user_id"`
+	Enabled bool   `json:"enabled"`
+	// Days is how many of the user's recent talking days must all be low.
+	Days int `json:"days,omitempty"`
+	// NegativeShare is the share of negative turns that makes a day low.
+	NegativeShare float64 `json:"negative_share,omitempty"`
+	UpdatedAt     string  `json:"updated_at,omitempty"`
+}
+
+// MoodAlert records a stretch of days on which a user's mood stayed low and
+// the check-in the robot offered for it.
+type MoodAlert struct {
+	ID       int64  `json:"id"`
+	UserID   string `json:"user_id"`
+	FirstDay string `json:"first_day"`
+	LastDay  string `json:"last_day"`
+	Days     int    `json:"days"`
+	// NegativeShare and UserValenceAvg cover all turns of the stretch.
+	NegativeShare  float64 `json:"negative_share"`
+	UserValenceAvg float64 `json:"user_valence_avg"`
+	SessionID      string  `json:"session_id,omitempty"`
+	TerminalID     string  `json:"terminal_id,omitempty"`
+	CheckIn        string  `json:"check_in,omitempty"`
+	CreatedAt      string  `json:"created_at,omitempty"`
+}
+
+// SoulDiaryEntry is the short first-person diary a soul writes about a day
+// (YYYY-MM-DD, server local time).
+type SoulDiaryEntry struct {
+	SoulID    string `json:"soul_id"`
+	Day       string `json:"day"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+type CreateSoulExemplarPayload struct {
+	User  string `json:"user"`
+	Reply string `json:"reply"`
 }
 
 type UserProfile struct {
@@ -197,6 +624,13 @@ type UserProfile struct {
 	Description string `json:"description,omitempty"`
 	CreatedAt   string `json:"created_at,omitempty"`
 	UpdatedAt   string `json:"updated_at,omitempty"`
+	// Language is the preferred reply language; empty follows the input.
+	Language string `json:"language,omitempty"`
+}
+
+// UserLanguagePayload sets a user's reply language; "" or "auto" clears it.
+type UserLanguagePayload struct {
+	Language string `json:"language"`
 }
 
 type CreateUserPayload struct {
@@ -205,10 +639,45 @@ type CreateUserPayload struct {
 	Description string `json:"description,omitempty"`
 }
 
+// UserDataPurge is the audit record of DELETE /v1/users/{user_id}/data:
+// rows deleted per table and whether the user's mem0 memories were erased.
+type UserDataPurge struct {
+	ID         int64            `json:"id"`
+	UserID     string           `json:"user_id"`
+	Deleted    map[string]int64 `json:"deleted"`
+	Mem0Purged bool             `json:"mem0_purged"`
+	PurgedAt   string           `json:"purged_at"`
+}
+
+type SoulChildModePayload struct {
+	Enabled bool `json:"enabled"`
+}
+
 type CreateSoulPayload struct {
 	UserID   string `json:"user_id,omitempty"`
 	Name     string `json:"name"`
 	MBTIType string `json:"mbti_type"`
+	// Template starts the soul from a built-in template; MBTIType may then
+	// be left empty.
+	Template string `json:"template,omitempty"`
+}
+
+// CloneSoulPayload names the copy of a soul; UserID defaults to the owner
+// of the source soul.
+type CloneSoulPayload struct {
+	UserID string `json:"user_id,omitempty"`
+	Name   string `json:"name"`
+}
+
+// SoulTemplate is a ready-made personality a new soul can start from.
+type SoulTemplate struct {
+	ID                string                      `json:"id"`
+	Name              string                      `json:"name"`
+	Description       string                      `json:"description"`
+	MBTIType          string                      `json:"mbti_type"`
+	PersonalityVector PersonalityVector           `json:"personality_vector"`
+	CharacterCard     SoulCharacterCard           `json:"character_card"`
+	Exemplars         []CreateSoulExemplarPayload `json:"exemplars"`
 }
 
 type SelectSoulPayload struct {
@@ -217,6 +686,46 @@ type SelectSoulPayload struct {
 	SoulID     string `json:"soul_id"`
 }
 
+// TerminalSoulGroup is two or more souls sharing one terminal: they take
+// turns replying in its sessions, each keeping its own memories.
+type TerminalSoulGroup struct {
+	UserID     string   `json:"user_id"`
+	TerminalID string   `json:"terminal_id"`
+	SoulIDs    []string `json:"soul_ids"`
+	UpdatedAt  string   `json:"updated_at,omitempty"`
+}
+
+type TerminalSoulGroupPayload struct {
+	UserID  string   `json:"user_id,omitempty"`
+	SoulIDs []string `json:"soul_ids"`
+}
+
+// KnowledgeDocument is a text a user gave a soul to look things up in,
+// such as a manual or notes; its content lives in embedded chunks.
+type KnowledgeDocument struct {
+	ID         int64  `json:"id"`
+	SoulID     string `json:"soul_id"`
+	Title      string `json:"title"`
+	Chars      int    `json:"chars"`
+	ChunkCount int    `json:"chunk_count"`
+	CreatedAt  string `json:"created_at,omitempty"`
+}
+
+type KnowledgeDocumentPayload struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// KnowledgeHit is a document chunk matching a search, Score being its
+// cosine similarity to the query.
+type KnowledgeHit struct {
+	DocumentID int64   `json:"document_id"`
+	Title      string  `json:"title"`
+	Seq        int     `json:"seq"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score"`
+}
+
 type SoulUserRelation struct {
 	ID               int64              `json:"id"`
 	RelationUUID     string             `json:"relation_uuid"`
@@ -226,10 +735,23 @@ type SoulUserRelation struct {
 	RelationToOwner  string             `json:"relation_to_owner"`
 	UserDescription  string             `json:"user_description,omitempty"`
 	PersonalityModel *PersonalityVector `json:"personality_model,omitempty"`
+	Rapport          *RelationRapport   `json:"rapport,omitempty"`
 	CreatedAt        string             `json:"created_at,omitempty"`
 	UpdatedAt        string             `json:"updated_at,omitempty"`
 }
 
+// RelationRapport is how close a soul has grown to a user. Score starts at
+// a stranger's baseline and moves with how often, how warmly and how
+// contentiously they talk, fading back while they do not.
+type RelationRapport struct {
+	Score             float64 `json:"score"`
+	Interactions      int     `json:"interactions"`
+	InteractionDays   int     `json:"interaction_days"`
+	Conflicts         int     `json:"conflicts"`
+	LastInteractionAt string  `json:"last_interaction_at,omitempty"`
+	LastConflictAt    string  `json:"last_conflict_at,omitempty"`
+}
+
 type CreateSoulUserRelationPayload struct {
 	RelatedUserID    string             `json:"related_user_id,omitempty"`
 	Appellation      string             `json:"appellation"`
@@ -246,7 +768,111 @@ type EmotionUpdatePayload struct {
 	SoulEmotion     SoulEmotionState `json:"soul_emotion"`
 	ExecProbability float64          `json:"exec_probability"`
 	ExecMode        string           `json:"exec_mode"`
-	TS              string           `json:"ts"`
+	// Preview marks an early read of a sentence the user is still speaking:
+	// only UserEmotion is set and the soul's PAD state is untouched.
+	Preview bool `json:"preview,omitempty"`
+	// ContagionFrom is the soul whose big emotional swing this soul caught;
+	// UserEmotion is then that soul's user emotion at the caught share.
+	ContagionFrom string `json:"contagion_from,omitempty"`
+	TS            string `json:"ts"`
+}
+
+// TerminalShadow is soul-server's view of a terminal's live state, kept by
+// the MQTT hub from what it sent the terminal and what the terminal
+// reported back. Light and Motion follow successful control_light and
+// set_head_motion/stop_motion calls; Expression is what the protocol's
+// emotion mapping shows for the last user emotion, or the last
+// set_expression call. Skills holds the latest call per skill; Pending
+// lists invokes and intents still waiting for their result.
+type TerminalShadow struct {
+	TerminalID string                  `json:"terminal_id"`
+	SoulID     string                  `json:"soul_id,omitempty"`
+	Online     bool                    `json:"online"`
+	LastSeenAt string                  `json:"last_seen_at,omitempty"`
+	Light      *ShadowLight            `json:"light,omitempty"`
+	Expression string                  `json:"expression,omitempty"`
+	Motion     *ShadowAction           `json:"motion,omitempty"`
+	Emotion    *ShadowEmotion          `json:"emotion,omitempty"`
+	GateLocked bool                    `json:"gate_locked"`
+	Asleep     bool                    `json:"asleep"`
+	LastStatus *ShadowStatus           `json:"last_status,omitempty"`
+	Skills     map[string]ShadowAction `json:"skills,omitempty"`
+	Pending    []PendingAction         `json:"pending"`
+	UpdatedAt  string                  `json:"updated_at,omitempty"`
+
+	// OnlineSince is when the terminal last announced online.
+	OnlineSince string          `json:"online_since,omitempty"`
+	Vitals      *TerminalVitals `json:"vitals,omitempty"`
+}
+
+// TerminalVitals is what a terminal said about itself in its last
+// heartbeat; fields it did not report stay empty.
+type TerminalVitals struct {
+	BatteryPercent *int   `json:"battery_percent,omitempty"`
+	Charging       *bool  `json:"charging,omitempty"`
+	UptimeSeconds  int64  `json:"uptime_seconds,omitempty"`
+	ReportedAt     string `json:"reported_at"`
+}
+
+type ShadowLight struct {
+	On        bool   `json:"on"`
+	Color     string `json:"color,omitempty"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// ShadowAction is one skill call and how it went. Source is invoke or
+// intent_action.
+type ShadowAction struct {
+	Skill     string          `json:"skill"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	OK        bool            `json:"ok"`
+	Error     string          `json:"error,omitempty"`
+	Source    string          `json:"source"`
+	At        string          `json:"at"`
+}
+
+type ShadowEmotion struct {
+	UserEmotion string  `json:"user_emotion,omitempty"`
+	SoulP       float64 `json:"soul_p"`
+	SoulA       float64 `json:"soul_a"`
+	SoulD       float64 `json:"soul_d"`
+	ExecMode    string  `json:"exec_mode,omitempty"`
+	At          string  `json:"at"`
+}
+
+type ShadowStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	At      string `json:"at"`
+}
+
+// PendingAction is an invoke, or one intent of an intent_action, sent to
+// a terminal that has not reported back yet.
+type PendingAction struct {
+	RequestID string          `json:"request_id"`
+	Kind      string          `json:"kind"`
+	IntentID  string          `json:"intent_id,omitempty"`
+	Skill     string          `json:"skill,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	SentAt    string          `json:"sent_at"`
+}
+
+// GateLockEvent tells a terminal the soul's emotion lock moved: while it
+// holds, skills are not executed. Message is a ready-to-show line such as
+// "机器人在生气，剩余90秒".
+type GateLockEvent struct {
+	SessionID        string `json:"session_id"`
+	TerminalID       string `json:"terminal_id"`
+	SoulID           string `json:"soul_id"`
+	Event            string `json:"event"`
+	Reason           string `json:"reason"`
+	Locked           bool   `json:"locked"`
+	LockUntil        string `json:"lock_until,omitempty"`
+	RemainingSeconds int    `json:"remaining_seconds"`
+	Mood             string `json:"mood,omitempty"`
+	Message          string `json:"message"`
+	SootheHint       string `json:"soothe_hint,omitempty"`
+	TS               string `json:"ts"`
 }
 
 type IntentActionItem struct {
@@ -266,3 +892,569 @@ type IntentActionPayload struct {
 	ExecProbability float64            `json:"exec_probability"`
 	TS              string             `json:"ts"`
 }
+
+// IntentResultPayload is a terminal's report of how the intents of one
+// intent_action went; RequestID is the intent_action's.
+type IntentResultPayload struct {
+	RequestID  string             `json:"request_id"`
+	SessionID  string             `json:"session_id"`
+	TerminalID string             `json:"terminal_id"`
+	SoulID     string             `json:"soul_id,omitempty"`
+	Results    []IntentResultItem `json:"results"`
+	TS         string             `json:"ts,omitempty"`
+}
+
+type IntentResultItem struct {
+	IntentID   string `json:"intent_id"`
+	IntentName string `json:"intent_name,omitempty"`
+	Skill      string `json:"skill,omitempty"`
+	OK         bool   `json:"ok"`
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// IntentResult is one stored intent outcome.
+type IntentResult struct {
+	ID         int64  `json:"id"`
+	RequestID  string `json:"request_id"`
+	TerminalID string `json:"terminal_id"`
+	SessionID  string `json:"session_id,omitempty"`
+	SoulID     string `json:"soul_id,omitempty"`
+	IntentID   string `json:"intent_id"`
+	IntentName string `json:"intent_name,omitempty"`
+	Skill      string `json:"skill,omitempty"`
+	OK         bool   `json:"ok"`
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// Kinds of entries in a user's activity timeline.
+const (
+	ActivityKindSkill    = "skill"
+	ActivityKindIntent   = "intent"
+	ActivityKindReminder = "reminder"
+	ActivityKindCheckIn  = "check_in"
+)
+
+// ActivityItem is one thing the robot did for a user: a skill it invoked
+// in a turn, an intent action the terminal ran, a reminder or alarm that
+// went off, or a check-in it started on its own. Name is the skill, the
+// intent or "mood_alert"; OK is set for intent actions only.
+type ActivityItem struct {
+	Kind       string `json:"kind"`
+	ID         int64  `json:"id"`
+	At         string `json:"at"`
+	TerminalID string `json:"terminal_id,omitempty"`
+	SessionID  string `json:"session_id,omitempty"`
+	SoulID     string `json:"soul_id,omitempty"`
+	Name       string `json:"name"`
+	Summary    string `json:"summary,omitempty"`
+	OK         *bool  `json:"ok,omitempty"`
+}
+
+// ActivityFilter pages through a user's activity, newest first: Since
+// bounds the oldest entry, Cursor continues after a previous page.
+type ActivityFilter struct {
+	Since  time.Time
+	Cursor string
+	Limit  int
+}
+
+// ActivityPage is one page of a user's activity; NextCursor is empty on
+// the last page.
+type ActivityPage struct {
+	UserID     string         `json:"user_id"`
+	Items      []ActivityItem `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// SafetyIncident records LLM output the safety filter blocked. Stage is
+// "reply" or "tool_call"; Excerpt is left empty for private sessions.
+type SafetyIncident struct {
+	ID         int64  `json:"id"`
+	SessionID  string `json:"session_id"`
+	UserID     string `json:"user_id"`
+	TerminalID string `json:"terminal_id"`
+	SoulID     string `json:"soul_id,omitempty"`
+	Stage      string `json:"stage"`
+	Skill      string `json:"skill,omitempty"`
+	Source     string `json:"source"`
+	Category   string `json:"category,omitempty"`
+	Excerpt    string `json:"excerpt,omitempty"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type SessionInfo struct {
+	SessionID        string `json:"session_id"`
+	UserID           string `json:"user_id"`
+	TerminalID       string `json:"terminal_id"`
+	SoulID           string `json:"soul_id"`
+	CreatedAt        string `json:"created_at,omitempty"`
+	LastUserActiveAt string `json:"last_user_active_at,omitempty"`
+}
+
+type SessionHandoffPayload struct {
+	TerminalID string `json:"terminal_id"`
+}
+
+type SessionHandoffResult struct {
+	SessionID      string `json:"session_id"`
+	UserID         string `json:"user_id"`
+	SoulID         string `json:"soul_id"`
+	FromTerminalID string `json:"from_terminal_id"`
+	ToTerminalID   string `json:"to_terminal_id"`
+}
+
+type SessionMessage struct {
+	ID         int64  `json:"id"`
+	Role       string `json:"role"`
+	Name       string `json:"name,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Content    string `json:"content"`
+	CreatedAt  string `json:"created_at"`
+	// Topics label the turn and are set on user messages only.
+	Topics []string `json:"topics,omitempty"`
+}
+
+// SessionExport is a session rendered for sharing and bug reports: its
+// messages in order, tool outputs included, each user message carrying the
+// affect of its turn.
+type SessionExport struct {
+	Session    SessionInfo            `json:"session"`
+	ExportedAt string                 `json:"exported_at"`
+	Messages   []SessionExportMessage `json:"messages"`
+}
+
+type SessionExportMessage struct {
+	SessionMessage
+	Affect *TurnAffect `json:"affect,omitempty"`
+}
+
+// SessionTopic is how often, and when, a session talked about a topic.
+type SessionTopic struct {
+	Topic   string `json:"topic"`
+	Turns   int    `json:"turns"`
+	FirstAt string `json:"first_at"`
+	LastAt  string `json:"last_at"`
+}
+
+// TurnAffect is the emotional side of one turn: what the user felt, where
+// the soul's mood ended up and what the execution gate decided.
+type TurnAffect struct {
+	At              string        `json:"at,omitempty"`
+	UserEmotion     EmotionSignal `json:"user_emotion"`
+	SoulP           float64       `json:"soul_p"`
+	SoulA           float64       `json:"soul_a"`
+	SoulD           float64       `json:"soul_d"`
+	ExecMode        string        `json:"exec_mode"`
+	ExecProbability float64       `json:"exec_probability"`
+	// GateOverride is set when an admin request forced execution.
+	GateOverride bool `json:"gate_override,omitempty"`
+}
+
+// SessionAffect is a session's turns in order with a summary for the
+// relationship health panel.
+type SessionAffect struct {
+	SessionID string               `json:"session_id"`
+	Summary   SessionAffectSummary `json:"summary"`
+	Turns     []TurnAffect         `json:"turns"`
+}
+
+type SessionAffectSummary struct {
+	Turns int `json:"turns"`
+	// UserEmotions counts the turns per user emotion label.
+	UserEmotions map[string]int `json:"user_emotions"`
+	// UserValenceAvg is the user's pleasure averaged with intensity as weight.
+	UserValenceAvg   float64 `json:"user_valence_avg"`
+	NegativeTurns    int     `json:"negative_turns"`
+	SoulValenceStart float64 `json:"soul_valence_start"`
+	SoulValenceEnd   float64 `json:"soul_valence_end"`
+	SoulValenceMin   float64 `json:"soul_valence_min"`
+	BlockedTurns     int     `json:"blocked_turns"`
+	GateOverrides    int     `json:"gate_overrides"`
+	// Health is good, strained or tense; empty without turns.
+	Health string `json:"health,omitempty"`
+}
+
+type SessionForkPayload struct {
+	// UpToMessageID is the last source message copied; 0 copies the full history.
+	UpToMessageID int64 `json:"up_to_message_id,omitempty"`
+	// SessionID names the fork; a fork_ prefixed id is generated when empty.
+	SessionID string `json:"session_id,omitempty"`
+}
+
+type SessionForkResult struct {
+	SessionID       string `json:"session_id"`
+	SourceSessionID string `json:"source_session_id"`
+	UserID          string `json:"user_id"`
+	TerminalID      string `json:"terminal_id"`
+	SoulID          string `json:"soul_id"`
+	UpToMessageID   int64  `json:"up_to_message_id,omitempty"`
+	CopiedMessages  int    `json:"copied_messages"`
+}
+
+// SessionPrivacyPayload turns a session's privacy mode on or off; UserID
+// defaults to the server's user.
+type SessionPrivacyPayload struct {
+	UserID  string `json:"user_id,omitempty"`
+	Private bool   `json:"private"`
+}
+
+type SessionPrivacySetting struct {
+	SessionID string `json:"session_id"`
+	Private   bool   `json:"private"`
+}
+
+type TerminalDryRunPayload struct {
+	Enabled bool `json:"enabled"`
+}
+
+type TerminalDryRunSetting struct {
+	TerminalID string `json:"terminal_id"`
+	Enabled    bool   `json:"enabled"`
+}
+
+// Voice activity statuses, relayed to a terminal while voice-gateway hears
+// its user speaking so the face can look attentive.
+const (
+	TerminalStatusListening        = "listening"
+	TerminalStatusListeningStopped = "listening_stopped"
+)
+
+// TerminalStatusSkillBundlesUpdated tells a terminal to pull its enabled
+// skill bundles again; the message lists their names.
+const TerminalStatusSkillBundlesUpdated = "skill_bundles_updated"
+
+// TerminalStatusUpgradeNeeded tells a terminal it is too old for some of
+// its capabilities; the message lists what to upgrade.
+const TerminalStatusUpgradeNeeded = "upgrade_needed"
+
+// TerminalStatusReminder delivers a due reminder; the message is its content.
+const TerminalStatusReminder = "reminder"
+
+// TerminalStatusCheckIn asks the terminal to say the message, a gentle
+// check-in after the user's mood stayed low for days.
+const TerminalStatusCheckIn = "check_in"
+
+const (
+	ReminderStatusPending   = "pending"
+	ReminderStatusDelivered = "delivered"
+)
+
+// Routine is a recurring rule the user set up in chat ("每天晚上十点关灯"):
+// at Time (HH:MM, server local time) on each of Weekdays (1=Monday ... 7=
+// Sunday; empty means every day) the server sends Actions to the terminal as
+// an intent_action.
+type Routine struct {
+	ID         int64              `json:"id"`
+	UserID     string             `json:"user_id"`
+	TerminalID string             `json:"terminal_id"`
+	SessionID  string             `json:"session_id,omitempty"`
+	SoulID     string             `json:"soul_id,omitempty"`
+	Name       string             `json:"name"`
+	Time       string             `json:"time"`
+	Weekdays   []int              `json:"weekdays,omitempty"`
+	Actions    []IntentActionItem `json:"actions"`
+	NextRunAt  string             `json:"next_run_at"`
+	LastRunAt  string             `json:"last_run_at,omitempty"`
+	CreatedAt  string             `json:"created_at"`
+}
+
+// Reminder is a timed reminder or alarm the user set through a skill. The
+// server keeps its own copy so it still fires when the terminal is off.
+type Reminder struct {
+	ID          int64  `json:"id"`
+	UserID      string `json:"user_id"`
+	TerminalID  string `json:"terminal_id"`
+	SessionID   string `json:"session_id"`
+	SoulID      string `json:"soul_id,omitempty"`
+	Skill       string `json:"skill"`
+	Content     string `json:"content"`
+	DueAt       string `json:"due_at"`
+	Status      string `json:"status"`
+	CreatedAt   string `json:"created_at"`
+	DeliveredAt string `json:"delivered_at,omitempty"`
+}
+
+// QuietHours is a do-not-disturb window from Start to End (HH:MM, server
+// local time; a window may cross midnight). TerminalID scopes it to one
+// terminal; empty applies it to all of the user's terminals.
+type QuietHours struct {
+	UserID     string `json:"user_id"`
+	TerminalID string `json:"terminal_id,omitempty"`
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	Enabled    bool   `json:"enabled"`
+	UpdatedAt  string `json:"updated_at,omitempty"`
+}
+
+// SetQuietHoursPayload sets a quiet hours rule; Enabled defaults to true.
+type SetQuietHoursPayload struct {
+	UserID     string `json:"user_id"`
+	TerminalID string `json:"terminal_id,omitempty"`
+	Start      string `json:"start"`
+	End        string `json:"end"`
+	Enabled    *bool  `json:"enabled,omitempty"`
+}
+
+// TerminalReplyFilters is the reply post-processing chain of a terminal;
+// Default is set when the terminal uses the service-wide chain.
+type TerminalReplyFilters struct {
+	TerminalID string   `json:"terminal_id"`
+	Filters    []string `json:"filters"`
+	Default    bool     `json:"default,omitempty"`
+	UpdatedAt  string   `json:"updated_at,omitempty"`
+}
+
+type TerminalReplyFiltersPayload struct {
+	Filters []string `json:"filters"`
+}
+
+// TerminalConfig is the settings soul-server pushes to a terminal over the
+// config topic. Only the fields set are changed; the rest stay as the
+// terminal has them.
+type TerminalConfig struct {
+	HeartbeatIntervalSeconds int                       `json:"heartbeat_interval_seconds,omitempty"`
+	VAD                      *TerminalVADConfig        `json:"vad,omitempty"`
+	QuietHours               *TerminalQuietHoursConfig `json:"quiet_hours,omitempty"`
+}
+
+// TerminalVADConfig tunes the terminal's voice activity detection, named
+// like voice-gateway's VOICE_VAD_* settings.
+type TerminalVADConfig struct {
+	ThresholdDB *float64 `json:"threshold_db,omitempty"`
+	SilenceMS   int      `json:"silence_ms,omitempty"`
+	MinSpeechMS int      `json:"min_speech_ms,omitempty"`
+}
+
+// TerminalQuietHoursConfig is the terminal's own do-not-disturb window,
+// e.g. to dim the screen and mute chimes; times are HH:MM local time.
+type TerminalQuietHoursConfig struct {
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Enabled bool   `json:"enabled"`
+}
+
+// ConfigPushPayload is what goes out on the config topic.
+type ConfigPushPayload struct {
+	RequestID  string         `json:"request_id"`
+	TerminalID string         `json:"terminal_id"`
+	Config     TerminalConfig `json:"config"`
+	TS         string         `json:"ts"`
+}
+
+// TerminalConfigAck is a terminal's answer to a config push.
+type TerminalConfigAck struct {
+	RequestID  string `json:"request_id"`
+	TerminalID string `json:"terminal_id"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	TS         string `json:"ts,omitempty"`
+}
+
+// Config push statuses.
+const (
+	ConfigPushPending  = "pending"
+	ConfigPushApplied  = "applied"
+	ConfigPushRejected = "rejected"
+)
+
+// TerminalConfigPush is one config push and where its acknowledgement
+// stands; Attempts counts how often it was published.
+type TerminalConfigPush struct {
+	ID         int64          `json:"id"`
+	RequestID  string         `json:"request_id"`
+	TerminalID string         `json:"terminal_id"`
+	Config     TerminalConfig `json:"config"`
+	Status     string         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	Attempts   int            `json:"attempts"`
+	CreatedAt  string         `json:"created_at"`
+	SentAt     string         `json:"sent_at,omitempty"`
+	AckedAt    string         `json:"acked_at,omitempty"`
+}
+
+// TurnReplay is everything one chat turn saw and produced, kept so the
+// turn can be re-run against current code. LLM messages and tools keep
+// their Go field names, as they have no JSON form of their own.
+type TurnReplay struct {
+	ID         int64            `json:"id"`
+	SessionID  string           `json:"session_id"`
+	TerminalID string           `json:"terminal_id"`
+	SoulID     string           `json:"soul_id,omitempty"`
+	UserID     string           `json:"user_id,omitempty"`
+	Request    ChatRequest      `json:"request"`
+	Inputs     *ReplayInputs    `json:"inputs,omitempty"`
+	LLMCalls   []ReplayLLMCall  `json:"llm_calls"`
+	ToolCalls  []ReplayToolCall `json:"tool_calls"`
+	Response   *ChatResponse    `json:"response,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	DurationMS int64            `json:"duration_ms"`
+	CreatedAt  string           `json:"created_at"`
+}
+
+// ReplayInputs is the state a turn started from: the soul before the
+// persona update, the session history, what the terminal offered and what
+// the emotion gate and intent filter were given and decided.
+type ReplayInputs struct {
+	Soul            SoulProfile          `json:"soul"`
+	History         []Message            `json:"history"`
+	Skills          []SkillDefinition    `json:"skills"`
+	IntentCatalog   []IntentSpec         `json:"intent_catalog,omitempty"`
+	UserEmotion     EmotionSignal        `json:"user_emotion"`
+	Intent          IntentFilterResponse `json:"intent"`
+	IntentFiltered  bool                 `json:"intent_filtered"`
+	ExecMode        string               `json:"exec_mode"`
+	ExecProbability float64              `json:"exec_probability"`
+	ForceExecute    bool                 `json:"force_execute,omitempty"`
+	QuietHours      bool                 `json:"quiet_hours,omitempty"`
+}
+
+type ReplayLLMCall struct {
+	Request    LLMRequest  `json:"request"`
+	Response   LLMResponse `json:"response"`
+	Error      string      `json:"error,omitempty"`
+	DurationMS int64       `json:"duration_ms"`
+}
+
+type ReplayToolCall struct {
+	Skill     string          `json:"skill"`
+	Arguments json.RawMessage `json:"arguments"`
+	Result    InvokeResult    `json:"result"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Terminal liveness statuses.
+const (
+	TerminalLivenessOffline = "offline"
+	TerminalLivenessOnline  = "online"
+)
+
+// TerminalLivenessEvent says a terminal's heartbeats stopped (offline) or
+// came back after that (online). Undeliverable counts the invokes that
+// were waiting on the terminal and failed when it went offline.
+type TerminalLivenessEvent struct {
+	TerminalID    string `json:"terminal_id"`
+	SoulID        string `json:"soul_id,omitempty"`
+	Status        string `json:"status"`
+	LastSeenAt    string `json:"last_seen_at"`
+	Undeliverable int    `json:"undeliverable,omitempty"`
+	TS            string `json:"ts"`
+}
+
+// TerminalDesiredState is how a terminal should be, kept by the server:
+// the arguments of each stateful skill (e.g. control_light) to re-issue
+// when the terminal comes back from a reconnect or power cycle. Divergent
+// lists the skills whose last successful call, per the terminal's shadow,
+// differs from the desired arguments.
+type TerminalDesiredState struct {
+	TerminalID string                     `json:"terminal_id"`
+	Skills     map[string]json.RawMessage `json:"skills"`
+	Divergent  []string                   `json:"divergent,omitempty"`
+	UpdatedAt  string                     `json:"updated_at,omitempty"`
+}
+
+type TerminalDesiredStatePayload struct {
+	Skills map[string]json.RawMessage `json:"skills"`
+}
+
+// ReconcileResult lists the skills a reconciliation re-issued.
+type ReconcileResult struct {
+	TerminalID string          `json:"terminal_id"`
+	Items      []ReconcileItem `json:"items"`
+}
+
+type ReconcileItem struct {
+	Skill     string          `json:"skill"`
+	Arguments json.RawMessage `json:"arguments"`
+	OK        bool            `json:"ok"`
+	Error     string          `json:"error,omitempty"`
+}
+
+type TerminalStatusPayload struct {
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// EmotionPreviewPayload is voice-gateway's reading of a partial transcript,
+// relayed so the terminal's expression can react before the sentence ends.
+type EmotionPreviewPayload struct {
+	SessionID   string        `json:"session_id,omitempty"`
+	UserEmotion EmotionSignal `json:"user_emotion"`
+}
+
+// Terminal power commands and the reasons the server sends them.
+const (
+	PowerCommandSleep = "sleep"
+	PowerCommandWake  = "wake"
+
+	PowerReasonIdle     = "idle"
+	PowerReasonSchedule = "schedule"
+	PowerReasonActivity = "activity"
+	PowerReasonManual   = "manual"
+)
+
+// PowerCommand is what goes out on the power topic: sleep dims the screen
+// and stops idle animations, wake brings the robot back.
+type PowerCommand struct {
+	RequestID  string `json:"request_id"`
+	TerminalID string `json:"terminal_id"`
+	Command    string `json:"command"`
+	Reason     string `json:"reason"`
+	TS         string `json:"ts"`
+}
+
+// TerminalPowerState is whether the server has put a terminal to sleep,
+// why and since when.
+type TerminalPowerState struct {
+	TerminalID     string `json:"terminal_id"`
+	Asleep         bool   `json:"asleep"`
+	Reason         string `json:"reason,omitempty"`
+	Since          string `json:"since,omitempty"`
+	LastActivityAt string `json:"last_activity_at,omitempty"`
+}
+
+type TerminalPowerPayload struct {
+	Command string `json:"command"`
+}
+
+type SpeakerProfile struct {
+	SpeakerID     string `json:"speaker_id"`
+	UserID        string `json:"user_id"`
+	DisplayName   string `json:"display_name"`
+	SpeakerUserID string `json:"speaker_user_id,omitempty"`
+	RelationUUID  string `json:"relation_uuid,omitempty"`
+	// FaceID is the id the terminal's face recognition reports for this
+	// person in presence events.
+	FaceID      string    `json:"face_id,omitempty"`
+	SampleCount int       `json:"sample_count"`
+	Embedding   []float64 `json:"-"`
+	CreatedAt   string    `json:"created_at,omitempty"`
+	UpdatedAt   string    `json:"updated_at,omitempty"`
+}
+
+type BindSpeakerFacePayload struct {
+	UserID string `json:"user_id,omitempty"`
+	FaceID string `json:"face_id"`
+}
+
+type EnrollSpeakerPayload struct {
+	UserID        string    `json:"user_id,omitempty"`
+	DisplayName   string    `json:"display_name"`
+	SpeakerUserID string    `json:"speaker_user_id,omitempty"`
+	RelationUUID  string    `json:"relation_uuid,omitempty"`
+	Embedding     []float64 `json:"embedding"`
+}
+
+type SpeakerIdentity struct {
+	SpeakerID        string             `json:"speaker_id"`
+	DisplayName      string             `json:"display_name"`
+	UserID           string             `json:"user_id,omitempty"`
+	RelationUUID     string             `json:"relation_uuid,omitempty"`
+	Appellation      string             `json:"appellation,omitempty"`
+	RelationToOwner  string             `json:"relation_to_owner,omitempty"`
+	Score            float64            `json:"score"`
+	PersonalityModel *PersonalityVector `json:"-"`
+}
@@ -32,11 +32,29 @@ func (c *Client) Enabled() bool {
 	return c != nil && c.baseURL != ""
 }
 
-func (c *Client) Analyze(ctx context.Context, text string) (domain.EmotionSignal, error) {
+type historyTurn struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Analyze scores text; language ("zh" or "en") picks the service's
+// keyword and prompt set, and "" lets it detect the language itself.
+// history, oldest first, is the conversation before text: the service reads
+// short replies such as "嗯" as answers to it.
+func (c *Client) Analyze(ctx context.Context, text, language string, history []domain.Message) (domain.EmotionSignal, error) {
 	if !c.Enabled() {
 		return domain.EmotionSignal{}, fmt.Errorf("emotion service is not configured")
 	}
-	payload := map[string]string{"text": strings.TrimSpace(text)}
+	payload := struct {
+		Text     string        `json:"text"`
+		Language string        `json:"language,omitempty"`
+		History  []historyTurn `json:"history,omitempty"`
+	}{Text: strings.TrimSpace(text), Language: language}
+	for _, m := range history {
+		if content := strings.TrimSpace(m.Content); content != "" && (m.Role == "user" || m.Role == "assistant") {
+			payload.History = append(payload.History, historyTurn{Role: m.Role, Content: content})
+		}
+	}
 	body, _ := json.Marshal(payload)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/emotion/analyze", bytes.NewReader(body))
 	if err != nil {
@@ -56,21 +74,33 @@ func (c *Client) Analyze(ctx context.Context, text string) (domain.EmotionSignal
 	}
 
 	var out struct {
-		Emotion   string  `json:"emotion"`
-		P         float64 `json:"p"`
-		A         float64 `json:"a"`
-		D         float64 `json:"d"`
-		Intensity float64 `json:"intensity"`
+		Emotion    string                    `json:"emotion"`
+		P          float64                   `json:"p"`
+		A          float64                   `json:"a"`
+		D          float64                   `json:"d"`
+		Intensity  float64                   `json:"intensity"`
+		Candidates []domain.EmotionCandidate `json:"candidates"`
+		Ambiguous  bool                      `json:"ambiguous"`
 	}
 	if err := json.Unmarshal(respBody, &out); err != nil {
 		return domain.EmotionSignal{}, err
 	}
+	// Older servers send no candidates; intensity stands in for confidence.
+	confidence := out.Intensity
+	for _, c := range out.Candidates {
+		if c.Emotion == out.Emotion {
+			confidence = c.Score
+			break
+		}
+	}
 	return domain.EmotionSignal{
 		Emotion:    out.Emotion,
 		P:          out.P,
 		A:          out.A,
 		D:          out.D,
 		Intensity:  out.Intensity,
-		Confidence: out.Intensity,
+		Confidence: confidence,
+		Candidates: out.Candidates,
+		Ambiguous:  out.Ambiguous,
 	}, nil
 }
@@ -0,0 +1,66 @@
+package emotion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestAnalyzeSendsHistory(t *testing.T) {
+	var got struct {
+		Text     string        `json:"text"`
+		Language string        `json:"language"`
+		History  []historyTurn `json:"history"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"emotion":"calm","p":0.2,"a":-0.3,"d":0.1,"intensity":0.4,"candidates":[{"emotion":"calm","score":0.7},{"emotion":"relief","score":0.2}],"ambiguous":false,"context_used":true}`))
+	}))
+	defer srv.Close()
+
+	sig, err := NewClient(srv.URL, time.Second).Analyze(context.Background(), " 嗯 ", "zh", []domain.Message{
+		{Role: "assistant", Content: "要不要听首歌放松一下？"},
+		{Role: "tool", Content: `{"ok":true}`},
+		{Role: "user", Content: "  "},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Text != "嗯" || got.Language != "zh" || len(got.History) != 1 || got.History[0].Role != "assistant" {
+		t.Fatalf("request = %+v", got)
+	}
+	if sig.Emotion != "calm" || sig.Confidence != 0.7 || len(sig.Candidates) != 2 || sig.Intensity != 0.4 {
+		t.Fatalf("signal = %+v", sig)
+	}
+}
+
+func TestAnalyzeOmitsEmptyHistory(t *testing.T) {
+	var raw map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&raw)
+		_, _ = w.Write([]byte(`{"emotion":"joy","intensity":0.8}`))
+	}))
+	defer srv.Close()
+
+	sig, err := NewClient(srv.URL, time.Second).Analyze(context.Background(), "太棒了", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw["history"]; ok {
+		t.Fatalf("request = %v", raw)
+	}
+	if _, ok := raw["language"]; ok {
+		t.Fatalf("request = %v", raw)
+	}
+	// Without candidates, intensity stands in for confidence.
+	if sig.Confidence != 0.8 {
+		t.Fatalf("confidence = %v", sig.Confidence)
+	}
+}
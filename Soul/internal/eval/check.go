@@ -0,0 +1,62 @@
+package eval
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"soul/internal/domain"
+)
+
+// CheckTurn returns one message per failed assertion; nil means the turn passed.
+func CheckTurn(expect Expect, resp domain.ChatResponse, invoked []string) []string {
+	var failures []string
+	if expect.ExecutedSkills != nil && !sameSet(*expect.ExecutedSkills, resp.ExecutedSkills) {
+		failures = append(failures, fmt.Sprintf("executed_skills: want %v, got %v", *expect.ExecutedSkills, resp.ExecutedSkills))
+	}
+	if expect.Invoked != nil && !sameSet(*expect.Invoked, invoked) {
+		failures = append(failures, fmt.Sprintf("invoked: want %v, got %v", *expect.Invoked, invoked))
+	}
+	if expect.ExecMode != "" && expect.ExecMode != resp.ExecMode {
+		failures = append(failures, fmt.Sprintf("exec_mode: want %s, got %s", expect.ExecMode, resp.ExecMode))
+	}
+	if expect.IntentDecision != "" && expect.IntentDecision != resp.IntentDecision {
+		failures = append(failures, fmt.Sprintf("intent_decision: want %s, got %s", expect.IntentDecision, resp.IntentDecision))
+	}
+	for _, sub := range expect.ReplyContains {
+		if !strings.Contains(resp.Reply, sub) {
+			failures = append(failures, fmt.Sprintf("reply should contain %q", sub))
+		}
+	}
+	for _, sub := range expect.ReplyNotContains {
+		if strings.Contains(resp.Reply, sub) {
+			failures = append(failures, fmt.Sprintf("reply should not contain %q", sub))
+		}
+	}
+	if expect.ReplyMaxRunes > 0 {
+		if n := utf8.RuneCountInString(resp.Reply); n > expect.ReplyMaxRunes {
+			failures = append(failures, fmt.Sprintf("reply has %d runes, max %d", n, expect.ReplyMaxRunes))
+		}
+	}
+	if expect.NoReply && resp.Reply != "" {
+		failures = append(failures, fmt.Sprintf("expected no reply, got %q", resp.Reply))
+	}
+	return failures
+}
+
+func sameSet(want, got []string) bool {
+	if len(want) != len(got) {
+		return false
+	}
+	a := append([]string(nil), want...)
+	b := append([]string(nil), got...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
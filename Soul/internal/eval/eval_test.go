@@ -0,0 +1,88 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestLoadSmokeSuite(t *testing.T) {
+	suite, err := LoadSuite("testdata/smoke.yaml")
+	if err != nil {
+		t.Fatalf("load suite: %v", err)
+	}
+	if len(suite.Cases) != 3 {
+		t.Fatalf("unexpected cases: %+v", suite.Cases)
+	}
+	if want := suite.Cases[1].Turns[0].Expect.ExecutedSkills; want == nil || len(*want) != 0 {
+		t.Fatalf("explicit empty executed_skills must be kept, got %v", want)
+	}
+	if suite.Cases[0].Turns[0].Expect.Invoked == nil || suite.Cases[2].Turns[0].Expect.ExecMode != "" {
+		t.Fatalf("unexpected expectations: %+v", suite.Cases)
+	}
+	defs, err := suite.SkillDefinitions()
+	if err != nil || len(defs) != 1 || !json.Valid(defs[0].InputSchema) {
+		t.Fatalf("unexpected skill definitions: %v %+v", err, defs)
+	}
+}
+
+func TestParseSuiteRejectsEmptyInput(t *testing.T) {
+	_, err := ParseSuite([]byte("name: bad\ncases:\n  - name: c\n    turns:\n      - input: ''\n"))
+	if err == nil || !strings.Contains(err.Error(), "no input") {
+		t.Fatalf("expected input validation error, got %v", err)
+	}
+}
+
+func TestCheckTurn(t *testing.T) {
+	skills := []string{"light_off"}
+	none := []string{}
+	cases := []struct {
+		name     string
+		expect   Expect
+		resp     domain.ChatResponse
+		invoked  []string
+		failures int
+	}{
+		{"unset expectations pass", Expect{}, domain.ChatResponse{Reply: "好"}, nil, 0},
+		{"skills match ignoring order", Expect{ExecutedSkills: &[]string{"b", "a"}}, domain.ChatResponse{ExecutedSkills: []string{"a", "b"}}, nil, 0},
+		{"missing skill", Expect{ExecutedSkills: &skills}, domain.ChatResponse{}, nil, 1},
+		{"explicit none", Expect{Invoked: &none}, domain.ChatResponse{}, []string{"light_off"}, 1},
+		{"exec mode", Expect{ExecMode: "auto_execute"}, domain.ChatResponse{ExecMode: "blocked"}, nil, 1},
+		{"reply rules", Expect{ReplyContains: []string{"灯"}, ReplyNotContains: []string{"抱歉"}, ReplyMaxRunes: 3}, domain.ChatResponse{Reply: "抱歉没有找到"}, nil, 3},
+		{"no reply", Expect{NoReply: true}, domain.ChatResponse{Reply: "在"}, nil, 1},
+	}
+	for _, tc := range cases {
+		if got := CheckTurn(tc.expect, tc.resp, tc.invoked); len(got) != tc.failures {
+			t.Fatalf("%s: want %d failures, got %v", tc.name, tc.failures, got)
+		}
+	}
+}
+
+func TestRunnerStopsCaseOnChatError(t *testing.T) {
+	suite := Suite{Name: "s", Cases: []Case{{
+		Name:  "c",
+		Turns: []Turn{{Input: "报错"}, {Input: "不会执行"}},
+	}}}
+	var calls int
+	runner := &Runner{
+		Terminal: NewMockTerminal(nil),
+		RunID:    "r1",
+		Prepare: func(context.Context, Suite, Case) (string, string, error) {
+			return "t1", "soul1", nil
+		},
+		Chat: func(_ context.Context, req domain.ChatRequest) (domain.ChatResponse, error) {
+			calls++
+			return domain.ChatResponse{}, context.DeadlineExceeded
+		},
+	}
+	report := runner.Run(context.Background(), suite)
+	if calls != 1 || report.Cases[0].Passed || len(report.Cases[0].Turns) != 1 {
+		t.Fatalf("unexpected report: calls=%d %+v", calls, report)
+	}
+	if _, failed := (Report{Suites: []SuiteReport{report}}).Counts(); failed != 1 {
+		t.Fatalf("expected one failed case")
+	}
+}
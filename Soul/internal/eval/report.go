@@ -0,0 +1,78 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type Report struct {
+	Provider string        `json:"provider"`
+	Model    string        `json:"model"`
+	Suites   []SuiteReport `json:"suites"`
+}
+
+type SuiteReport struct {
+	Name  string       `json:"name"`
+	Cases []CaseReport `json:"cases"`
+}
+
+type CaseReport struct {
+	Name   string       `json:"name"`
+	Passed bool         `json:"passed"`
+	Error  string       `json:"error,omitempty"`
+	Turns  []TurnReport `json:"turns,omitempty"`
+}
+
+type TurnReport struct {
+	Input          string   `json:"input"`
+	Reply          string   `json:"reply"`
+	ExecutedSkills []string `json:"executed_skills,omitempty"`
+	Invoked        []string `json:"invoked,omitempty"`
+	Statuses       []string `json:"statuses,omitempty"`
+	ExecMode       string   `json:"exec_mode,omitempty"`
+	IntentDecision string   `json:"intent_decision,omitempty"`
+	LatencyMS      int64    `json:"latency_ms"`
+	Failures       []string `json:"failures,omitempty"`
+}
+
+func (r Report) Counts() (passed, failed int) {
+	for _, s := range r.Suites {
+		for _, c := range s.Cases {
+			if c.Passed {
+				passed++
+			} else {
+				failed++
+			}
+		}
+	}
+	return passed, failed
+}
+
+func (r Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "provider=%s model=%s\n", r.Provider, r.Model)
+	for _, s := range r.Suites {
+		fmt.Fprintf(w, "\nsuite %s\n", s.Name)
+		for _, c := range s.Cases {
+			mark := "PASS"
+			if !c.Passed {
+				mark = "FAIL"
+			}
+			fmt.Fprintf(w, "  [%s] %s\n", mark, c.Name)
+			if c.Error != "" {
+				fmt.Fprintf(w, "      %s\n", c.Error)
+			}
+			for i, t := range c.Turns {
+				if len(t.Failures) == 0 {
+					continue
+				}
+				fmt.Fprintf(w, "      turn %d %q -> %q (skills=%s mode=%s)\n", i+1, t.Input, t.Reply, strings.Join(t.ExecutedSkills, ","), t.ExecMode)
+				for _, f := range t.Failures {
+					fmt.Fprintf(w, "        - %s\n", f)
+				}
+			}
+		}
+	}
+	passed, failed := r.Counts()
+	fmt.Fprintf(w, "\n%d passed, %d failed\n", passed, failed)
+}
@@ -0,0 +1,81 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"soul/internal/domain"
+)
+
+type ChatFunc func(ctx context.Context, req domain.ChatRequest) (domain.ChatResponse, error)
+
+// PrepareFunc sets up a fresh terminal and soul for one case so emotion state
+// and history never leak between cases. It returns the ids to chat with.
+type PrepareFunc func(ctx context.Context, suite Suite, c Case) (terminalID, soulID string, err error)
+
+type Runner struct {
+	Chat     ChatFunc
+	Prepare  PrepareFunc
+	Terminal *MockTerminal
+	UserID   string
+	// RunID keeps session ids unique across runs against the same database.
+	RunID string
+}
+
+func (r *Runner) Run(ctx context.Context, suite Suite) SuiteReport {
+	report := SuiteReport{Name: suite.Name}
+	for i, c := range suite.Cases {
+		report.Cases = append(report.Cases, r.runCase(ctx, suite, i, c))
+	}
+	return report
+}
+
+func (r *Runner) runCase(ctx context.Context, suite Suite, index int, c Case) CaseReport {
+	out := CaseReport{Name: c.Name, Passed: true}
+	terminalID, soulID, err := r.Prepare(ctx, suite, c)
+	if err != nil {
+		out.Passed = false
+		out.Error = fmt.Sprintf("prepare: %v", err)
+		return out
+	}
+	sessionID := fmt.Sprintf("eval_%s_%s_%02d", suite.Name, r.RunID, index+1)
+	r.Terminal.Take()
+
+	for _, turn := range c.Turns {
+		start := time.Now()
+		resp, err := r.Chat(ctx, domain.ChatRequest{
+			UserID:     r.UserID,
+			SessionID:  sessionID,
+			TerminalID: terminalID,
+			SoulID:     soulID,
+			DryRun:     turn.DryRun,
+			Inputs:     []domain.ChatInput{{Type: "keyboard_text", Text: turn.Input}},
+		})
+		invoked, statuses := r.Terminal.Take()
+		result := TurnReport{
+			Input:     turn.Input,
+			LatencyMS: time.Since(start).Milliseconds(),
+			Invoked:   invoked,
+			Statuses:  statuses,
+		}
+		if err != nil {
+			result.Failures = []string{fmt.Sprintf("chat failed: %v", err)}
+		} else {
+			result.Reply = resp.Reply
+			result.ExecutedSkills = resp.ExecutedSkills
+			result.ExecMode = resp.ExecMode
+			result.IntentDecision = resp.IntentDecision
+			result.Failures = CheckTurn(turn.Expect, resp, invoked)
+		}
+		if len(result.Failures) > 0 {
+			out.Passed = false
+		}
+		out.Turns = append(out.Turns, result)
+		if err != nil {
+			// Later turns depend on this one's history; stop the case here.
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,151 @@
+// Package eval runs scripted conversations through the orchestrator against
+// mock terminals and checks the routing decisions of each turn, so prompt and
+// model changes can be regression-tested before they reach real devices.
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"soul/internal/domain"
+)
+
+// Suite is the YAML file format:
+//
+//	name: lights
+//	soul: {mbti: INFJ}
+//	skills:
+//	  - name: light_off
+//	    description: 关灯
+//	    input_schema: {type: object, properties: {room: {type: string}}}
+//	    output: 已关灯
+//	cases:
+//	  - name: turn off the light
+//	    turns:
+//	      - input: 帮我把客厅的灯关掉
+//	        expect:
+//	          executed_skills: [light_off]
+//	          exec_mode: auto_execute
+//	          reply_not_contains: [抱歉]
+type Suite struct {
+	Name          string           `yaml:"name"`
+	Soul          SoulSpec         `yaml:"soul"`
+	Skills        []SkillSpec      `yaml:"skills"`
+	IntentCatalog []map[string]any `yaml:"intent_catalog"`
+	Cases         []Case           `yaml:"cases"`
+}
+
+type SoulSpec struct {
+	Name string `yaml:"name"`
+	MBTI string `yaml:"mbti"`
+}
+
+type SkillSpec struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	InputSchema map[string]any `yaml:"input_schema"`
+	// Output is what the mock terminal answers when the skill is invoked.
+	Output string `yaml:"output"`
+	// Fail makes the mock terminal report an execution failure.
+	Fail bool `yaml:"fail"`
+}
+
+type Case struct {
+	Name  string `yaml:"name"`
+	Turns []Turn `yaml:"turns"`
+}
+
+type Turn struct {
+	Input  string `yaml:"input"`
+	DryRun bool   `yaml:"dry_run"`
+	Expect Expect `yaml:"expect"`
+}
+
+// Expect lists the assertions of a turn; unset fields are not checked.
+type Expect struct {
+	// ExecutedSkills must equal the reported executed skills, ignoring order.
+	// An empty list written explicitly asserts that nothing was executed.
+	ExecutedSkills   *[]string `yaml:"executed_skills"`
+	Invoked          *[]string `yaml:"invoked"`
+	ExecMode         string    `yaml:"exec_mode"`
+	IntentDecision   string    `yaml:"intent_decision"`
+	ReplyContains    []string  `yaml:"reply_contains"`
+	ReplyNotContains []string  `yaml:"reply_not_contains"`
+	ReplyMaxRunes    int       `yaml:"reply_max_runes"`
+	NoReply          bool      `yaml:"no_reply"`
+}
+
+func LoadSuite(path string) (Suite, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Suite{}, fmt.Errorf("read eval suite: %w", err)
+	}
+	return ParseSuite(raw)
+}
+
+func ParseSuite(raw []byte) (Suite, error) {
+	var suite Suite
+	if err := yaml.Unmarshal(raw, &suite); err != nil {
+		return Suite{}, fmt.Errorf("parse eval suite: %w", err)
+	}
+	if strings.TrimSpace(suite.Name) == "" {
+		return Suite{}, fmt.Errorf("eval suite name is required")
+	}
+	if len(suite.Cases) == 0 {
+		return Suite{}, fmt.Errorf("eval suite %s has no cases", suite.Name)
+	}
+	for i, c := range suite.Cases {
+		if strings.TrimSpace(c.Name) == "" {
+			return Suite{}, fmt.Errorf("eval suite %s: case %d has no name", suite.Name, i+1)
+		}
+		if len(c.Turns) == 0 {
+			return Suite{}, fmt.Errorf("eval suite %s: case %q has no turns", suite.Name, c.Name)
+		}
+		for j, t := range c.Turns {
+			if strings.TrimSpace(t.Input) == "" {
+				return Suite{}, fmt.Errorf("eval suite %s: case %q turn %d has no input", suite.Name, c.Name, j+1)
+			}
+		}
+	}
+	if strings.TrimSpace(suite.Soul.MBTI) == "" {
+		suite.Soul.MBTI = "INTJ"
+	}
+	return suite, nil
+}
+
+func (s Suite) SkillDefinitions() ([]domain.SkillDefinition, error) {
+	out := make([]domain.SkillDefinition, 0, len(s.Skills))
+	for _, sk := range s.Skills {
+		schema := json.RawMessage(`{"type":"object","properties":{}}`)
+		if len(sk.InputSchema) > 0 {
+			raw, err := json.Marshal(sk.InputSchema)
+			if err != nil {
+				return nil, fmt.Errorf("skill %s input_schema: %w", sk.Name, err)
+			}
+			schema = raw
+		}
+		out = append(out, domain.SkillDefinition{Name: sk.Name, Description: sk.Description, InputSchema: schema})
+	}
+	return out, nil
+}
+
+// IntentSpecs converts the free-form YAML catalog through JSON so it follows
+// the same field names as the MQTT intent_catalog report.
+func (s Suite) IntentSpecs() ([]domain.IntentSpec, error) {
+	if len(s.IntentCatalog) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(s.IntentCatalog)
+	if err != nil {
+		return nil, fmt.Errorf("intent_catalog: %w", err)
+	}
+	var out []domain.IntentSpec
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, fmt.Errorf("intent_catalog: %w", err)
+	}
+	return out, nil
+}
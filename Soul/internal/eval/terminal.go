@@ -0,0 +1,71 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"soul/internal/domain"
+)
+
+// MockTerminal stands in for the MQTT hub: it answers skill invocations from
+// the suite and records what the orchestrator sent to the device.
+type MockTerminal struct {
+	mu       sync.Mutex
+	skills   map[string]SkillSpec
+	invoked  []string
+	statuses []string
+}
+
+func NewMockTerminal(skills []SkillSpec) *MockTerminal {
+	t := &MockTerminal{skills: make(map[string]SkillSpec, len(skills))}
+	for _, sk := range skills {
+		t.skills[sk.Name] = sk
+	}
+	return t
+}
+
+func (t *MockTerminal) InvokeSkill(_ context.Context, _ string, skill string, _ json.RawMessage) (domain.InvokeResult, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.invoked = append(t.invoked, skill)
+
+	spec, ok := t.skills[skill]
+	if !ok {
+		return domain.InvokeResult{}, fmt.Errorf("skill %s is not registered on the mock terminal", skill)
+	}
+	if spec.Fail {
+		return domain.InvokeResult{OK: false, Error: "mock failure", Output: "执行失败"}, nil
+	}
+	output := spec.Output
+	if output == "" {
+		output = "ok"
+	}
+	return domain.InvokeResult{OK: true, Output: output}, nil
+}
+
+func (t *MockTerminal) PublishStatus(_ context.Context, _ string, status, _ string, _ string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.statuses = append(t.statuses, status)
+	return nil
+}
+
+func (t *MockTerminal) PublishIntentAction(_ context.Context, _ string, payload domain.IntentActionPayload) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, in := range payload.Intents {
+		t.invoked = append(t.invoked, "intent:"+in.IntentID)
+	}
+	return nil
+}
+
+// Take returns and clears what was recorded since the previous call.
+func (t *MockTerminal) Take() (invoked, statuses []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	invoked, statuses = t.invoked, t.statuses
+	t.invoked, t.statuses = nil, nil
+	return invoked, statuses
+}
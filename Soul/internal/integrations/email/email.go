@@ -0,0 +1,256 @@
+// Package email sends mail over SMTP on behalf of the send_email skill.
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/skills"
+)
+
+// SkillName is the terminal skill this package takes over.
+const SkillName = "send_email"
+
+// TLS modes: starttls upgrades a plain connection (port 587), tls dials TLS
+// directly (port 465), none sends in the clear and is meant for local relays.
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+)
+
+// ErrRecipientNotAllowed is returned for any recipient outside the user's
+// allow-list.
+var ErrRecipientNotAllowed = errors.New("recipient not allowed")
+
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	TLS      string
+	Timeout  time.Duration
+	// AllowedRecipients maps a user ID to the addresses it may mail; an
+	// entry starting with @ allows a whole domain. The user "*" applies to
+	// everyone. A user with no entry may not send at all.
+	AllowedRecipients map[string][]string
+}
+
+// Message is one plain-text mail.
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender delivers mail through one SMTP server. Every attempt, allowed or
+// not, is written to the audit log.
+type Sender struct {
+	cfg     Config
+	from    *mail.Address
+	audit   *slog.Logger
+	deliver func(ctx context.Context, from string, to []string, data []byte) error
+	now     func() time.Time
+}
+
+func NewSender(cfg Config, logger *slog.Logger) (*Sender, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if cfg.Port <= 0 {
+		cfg.Port = 587
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	switch cfg.TLS {
+	case "":
+		cfg.TLS = TLSStartTLS
+	case TLSStartTLS, TLSImplicit, TLSNone:
+	default:
+		return nil, fmt.Errorf("unsupported smtp tls mode %q", cfg.TLS)
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address: %w", err)
+	}
+	s := &Sender{cfg: cfg, from: from, audit: logger.With("component", "email_audit"), now: time.Now}
+	s.deliver = s.smtpDeliver
+	return s, nil
+}
+
+// Send checks the recipients against the user's allow-list and delivers msg.
+func (s *Sender) Send(ctx context.Context, userID, terminalID string, msg Message) error {
+	recipients, err := s.recipients(userID, msg.To)
+	if err == nil {
+		var data []byte
+		if data, err = s.compose(recipients, msg); err == nil {
+			addrs := make([]string, 0, len(recipients))
+			for _, r := range recipients {
+				addrs = append(addrs, r.Address)
+			}
+			err = s.deliver(ctx, s.from.Address, addrs, data)
+		}
+	}
+
+	attrs := []any{"user_id", userID, "terminal_id", terminalID, "to", strings.Join(msg.To, ","), "subject", msg.Subject, "body_runes", len([]rune(msg.Body))}
+	if err != nil {
+		s.audit.Warn("email rejected", append(attrs, "error", err)...)
+		return err
+	}
+	s.audit.Info("email sent", attrs...)
+	return nil
+}
+
+// InvokeSkill runs send_email for skills.Router; arguments are to, subject
+// and body, with to holding one or more comma-separated addresses.
+func (s *Sender) InvokeSkill(ctx context.Context, terminalID string, args json.RawMessage) (domain.InvokeResult, error) {
+	var in struct {
+		To      string `json:"to"`
+		Subject string `json:"subject"`
+		Body    string `json:"body"`
+	}
+	if err := json.Unmarshal(args, &in); err != nil {
+		return domain.InvokeResult{}, fmt.Errorf("invalid send_email arguments: %w", err)
+	}
+	if strings.TrimSpace(in.To) == "" || strings.TrimSpace(in.Subject) == "" || strings.TrimSpace(in.Body) == "" {
+		return domain.InvokeResult{}, fmt.Errorf("send_email requires to, subject and body")
+	}
+	caller, _ := skills.CallerFrom(ctx)
+	msg := Message{To: strings.Split(in.To, ","), Subject: strings.TrimSpace(in.Subject), Body: in.Body}
+	if err := s.Send(ctx, caller.UserID, terminalID, msg); err != nil {
+		return domain.InvokeResult{}, err
+	}
+	return domain.InvokeResult{OK: true, Output: fmt.Sprintf("邮件已发送给 %s", strings.TrimSpace(in.To))}, nil
+}
+
+func (s *Sender) recipients(userID string, to []string) ([]*mail.Address, error) {
+	allowed := append(append([]string{}, s.cfg.AllowedRecipients[userID]...), s.cfg.AllowedRecipients["*"]...)
+	var out []*mail.Address
+	for _, raw := range to {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", raw, err)
+		}
+		if !recipientAllowed(addr.Address, allowed) {
+			return nil, fmt.Errorf("%w: %s", ErrRecipientNotAllowed, addr.Address)
+		}
+		out = append(out, addr)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no recipients")
+	}
+	return out, nil
+}
+
+func recipientAllowed(address string, allowed []string) bool {
+	address = strings.ToLower(address)
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == address || (strings.HasPrefix(entry, "@") && strings.HasSuffix(address, entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Sender) compose(to []*mail.Address, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, fmt.Errorf("subject must be a single line")
+	}
+	recipients := make([]string, 0, len(to))
+	for _, addr := range to {
+		recipients = append(recipients, addr.String())
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", s.from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", s.now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes(), nil
+}
+
+func (s *Sender) smtpDeliver(ctx context.Context, from string, to []string, data []byte) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host}
+
+	var conn net.Conn
+	var err error
+	if s.cfg.TLS == TLSImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	deadline := time.Now().Add(s.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer client.Close()
+	if s.cfg.TLS == TLSStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp rcpt %s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,83 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/skills"
+)
+
+type capturedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+func newTestSender(t *testing.T, allowed map[string][]string) (*Sender, *[]capturedMail, *bytes.Buffer) {
+	t.Helper()
+	audit := &bytes.Buffer{}
+	s, err := NewSender(Config{Host: "smtp.example.com", From: "Soul <robot@example.com>", AllowedRecipients: allowed}, slog.New(slog.NewTextHandler(audit, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []capturedMail
+	s.deliver = func(_ context.Context, from string, to []string, data []byte) error {
+		sent = append(sent, capturedMail{from: from, to: to, data: string(data)})
+		return nil
+	}
+	s.now = func() time.Time { return time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC) }
+	return s, &sent, audit
+}
+
+func TestInvokeSkillSendsToAllowedRecipients(t *testing.T) {
+	s, sent, audit := newTestSender(t, map[string][]string{
+		"alice": {"bob@example.com"},
+		"*":     {"@family.org"},
+	})
+	ctx := skills.WithCaller(context.Background(), skills.Caller{UserID: "alice"})
+
+	result, err := s.InvokeSkill(ctx, "t1", json.RawMessage(`{"to":"bob@example.com, Mom <mom@family.org>","subject":"晚饭","body":"今晚回家吃饭"}`))
+	if err != nil || !result.OK {
+		t.Fatalf("send: %+v %v", result, err)
+	}
+	if len(*sent) != 1 {
+		t.Fatalf("expected one delivery, got %d", len(*sent))
+	}
+	got := (*sent)[0]
+	if got.from != "robot@example.com" || strings.Join(got.to, ",") != "bob@example.com,mom@family.org" {
+		t.Fatalf("unexpected envelope: %+v", got)
+	}
+	if !strings.Contains(got.data, "Subject: =?UTF-8?b?5pma6aWt?=\r\n") || !strings.Contains(got.data, "Content-Type: text/plain; charset=UTF-8") {
+		t.Fatalf("unexpected message:\n%s", got.data)
+	}
+	if !strings.Contains(audit.String(), "email sent") || !strings.Contains(audit.String(), "user_id=alice") {
+		t.Fatalf("missing audit entry: %s", audit.String())
+	}
+}
+
+func TestSendRejectsRecipientsOutsideAllowList(t *testing.T) {
+	s, sent, audit := newTestSender(t, map[string][]string{"alice": {"bob@example.com"}})
+
+	err := s.Send(context.Background(), "alice", "t1", Message{To: []string{"bob@example.com", "eve@example.com"}, Subject: "hi", Body: "x"})
+	if !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Fatalf("expected ErrRecipientNotAllowed, got %v", err)
+	}
+	if err := s.Send(context.Background(), "mallory", "t1", Message{To: []string{"bob@example.com"}, Subject: "hi", Body: "x"}); !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Fatalf("a user without a list must not send: %v", err)
+	}
+	if err := s.Send(context.Background(), "alice", "t1", Message{To: []string{"bob@example.com"}, Subject: "hi\r\nBcc: eve@example.com", Body: "x"}); err == nil {
+		t.Fatal("header injection through the subject must be refused")
+	}
+	if len(*sent) != 0 {
+		t.Fatalf("nothing may be delivered: %+v", *sent)
+	}
+	if n := strings.Count(audit.String(), "email rejected"); n != 3 {
+		t.Fatalf("every rejection must be audited, got %d:\n%s", n, audit.String())
+	}
+}
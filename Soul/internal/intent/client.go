@@ -3,28 +3,79 @@ package intent
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"soul/internal/domain"
 )
 
+// retryBackoff is the pause before a second attempt, doubled before a third.
+const retryBackoff = 25 * time.Millisecond
+
+// Options tunes the client; the zero value makes one call per filter with
+// no cache and no fallback.
+type Options struct {
+	// Timeout bounds a whole Filter call, retries included; each of the
+	// Attempts gets an equal share of it.
+	Timeout  time.Duration
+	Attempts int
+	// CacheTTL keeps a response for the same terminal, catalog and
+	// normalized command this long; zero disables the cache.
+	CacheTTL  time.Duration
+	CacheSize int
+	// LocalFallback answers with MatchLocal when the service fails, and
+	// keeps answering locally for DownCooldown before trying it again.
+	LocalFallback bool
+	DownCooldown  time.Duration
+	Logger        *slog.Logger
+}
+
 type Client struct {
 	baseURL string
 	http    *http.Client
+	opts    Options
+	now     func() time.Time
+
+	mu        sync.Mutex
+	cache     map[string]cachedResponse
+	downUntil time.Time
+}
+
+type cachedResponse struct {
+	resp    domain.IntentFilterResponse
+	expires time.Time
 }
 
-func NewClient(baseURL string, timeout time.Duration) *Client {
-	if timeout <= 0 {
-		timeout = 1500 * time.Millisecond
+func NewClient(baseURL string, opts Options) *Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 1500 * time.Millisecond
+	}
+	opts.Attempts = max(opts.Attempts, 1)
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = 1024
+	}
+	if opts.DownCooldown <= 0 {
+		opts.DownCooldown = 5 * time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.DiscardHandler)
 	}
 	return &Client{
 		baseURL: strings.TrimRight(strings.TrimSpace(baseURL), "/"),
-		http:    &http.Client{Timeout: timeout},
+		http:    &http.Client{},
+		opts:    opts,
+		now:     time.Now,
+		cache:   make(map[string]cachedResponse),
 	}
 }
 
@@ -45,6 +96,12 @@ func DefaultOptions() domain.IntentFilterOptions {
 	}
 }
 
+// Filter asks the service which catalog intents the command names. A
+// transient failure is retried within Timeout; when the service still
+// fails, or failed within the last DownCooldown, and LocalFallback is set,
+// the command is matched locally instead (see MatchLocal) and no error is
+// returned. Responses are cached per terminal unless they depend on the
+// current time.
 func (c *Client) Filter(ctx context.Context, req domain.IntentFilterRequest) (domain.IntentFilterResponse, error) {
 	if !c.Enabled() {
 		return domain.IntentFilterResponse{}, fmt.Errorf("intent filter service is not configured")
@@ -52,7 +109,75 @@ func (c *Client) Filter(ctx context.Context, req domain.IntentFilterRequest) (do
 	if len(req.IntentCatalog) == 0 {
 		return domain.IntentFilterResponse{}, fmt.Errorf("intent catalog is empty")
 	}
+	key := c.cacheKey(req)
+	if resp, ok := c.cached(key); ok {
+		return resp, nil
+	}
+	if c.opts.LocalFallback && c.down() {
+		return MatchLocal(req), nil
+	}
+
+	resp, err := c.filterRemote(ctx, req)
+	if err != nil {
+		if !c.opts.LocalFallback || ctx.Err() != nil {
+			return domain.IntentFilterResponse{}, err
+		}
+		c.markDown()
+		c.opts.Logger.Warn("intent filter unavailable, matching locally", "error", err)
+		return MatchLocal(req), nil
+	}
+	c.store(key, resp)
+	return resp, nil
+}
+
+func (c *Client) filterRemote(ctx context.Context, req domain.IntentFilterRequest) (domain.IntentFilterResponse, error) {
 	body, _ := json.Marshal(req)
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	attemptTimeout := c.opts.Timeout / time.Duration(c.opts.Attempts)
+	var lastErr error
+	for attempt := 1; attempt <= c.opts.Attempts; attempt++ {
+		resp, err := c.post(ctx, body, attemptTimeout)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if attempt == c.opts.Attempts || !retryable(err) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return domain.IntentFilterResponse{}, lastErr
+		case <-time.After(retryBackoff * time.Duration(attempt)):
+		}
+	}
+	return domain.IntentFilterResponse{}, lastErr
+}
+
+// statusError is a non-2xx reply from the service.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("intent filter status=%d body=%s", e.code, e.body)
+}
+
+// retryable reports whether another attempt may succeed: timeouts, network
+// errors and overload are transient, a rejected request is not.
+func retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code >= 500 || se.code == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (c *Client) post(ctx context.Context, body []byte, timeout time.Duration) (domain.IntentFilterResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/intents/filter", bytes.NewReader(body))
 	if err != nil {
 		return domain.IntentFilterResponse{}, err
@@ -63,9 +188,12 @@ func (c *Client) Filter(ctx context.Context, req domain.IntentFilterRequest) (do
 		return domain.IntentFilterResponse{}, err
 	}
 	defer resp.Body.Close()
-	respBody, _ := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return domain.IntentFilterResponse{}, err
+	}
 	if resp.StatusCode >= 300 {
-		return domain.IntentFilterResponse{}, fmt.Errorf("intent filter status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		return domain.IntentFilterResponse{}, &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(respBody))}
 	}
 
 	var out domain.IntentFilterResponse
@@ -74,3 +202,77 @@ func (c *Client) Filter(ctx context.Context, req domain.IntentFilterRequest) (do
 	}
 	return out, nil
 }
+
+func (c *Client) down() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now().Before(c.downUntil)
+}
+
+func (c *Client) markDown() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.downUntil = c.now().Add(c.opts.DownCooldown)
+}
+
+// cacheKey is empty when caching is off. The catalog and options are
+// hashed in, so a terminal reporting a new catalog misses.
+func (c *Client) cacheKey(req domain.IntentFilterRequest) string {
+	if c.opts.CacheTTL <= 0 {
+		return ""
+	}
+	h := sha256.New()
+	_ = json.NewEncoder(h).Encode(struct {
+		Catalog []domain.IntentSpec
+		Options domain.IntentFilterOptions
+		Locale  string
+	}{req.IntentCatalog, req.Options, req.Locale})
+	return req.TerminalID + "\x00" + normalizeCommand(req.Command) + "\x00" + hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeCommand folds case, whitespace and closing punctuation, which
+// do not change what a command asks for.
+func normalizeCommand(text string) string {
+	text = strings.Join(strings.Fields(strings.ToLower(text)), " ")
+	return strings.TrimRight(text, "。！？，、.!?,~～ ")
+}
+
+func (c *Client) cached(key string) (domain.IntentFilterResponse, bool) {
+	if key == "" {
+		return domain.IntentFilterResponse{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[key]
+	if !ok || !c.now().Before(e.expires) {
+		return domain.IntentFilterResponse{}, false
+	}
+	return e.resp, true
+}
+
+// store caches resp unless it holds times resolved against now, such as a
+// reminder "in 10 minutes", which would be stale on a repeat.
+func (c *Client) store(key string, resp domain.IntentFilterResponse) {
+	if key == "" {
+		return
+	}
+	if n, _ := resp.Meta["time_signals"].(float64); n > 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.cache) >= c.opts.CacheSize {
+		for k, e := range c.cache {
+			if !now.Before(e.expires) {
+				delete(c.cache, k)
+			}
+		}
+		// Still full of live entries: start over rather than track
+		// recency for a cache this short-lived.
+		if len(c.cache) >= c.opts.CacheSize {
+			clear(c.cache)
+		}
+	}
+	c.cache[key] = cachedResponse{resp: resp, expires: now.Add(c.opts.CacheTTL)}
+}
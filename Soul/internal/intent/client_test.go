@@ -0,0 +1,160 @@
+package intent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func testRequest(command string) domain.IntentFilterRequest {
+	return domain.IntentFilterRequest{
+		TerminalID: "t1",
+		Command:    command,
+		IntentCatalog: []domain.IntentSpec{
+			{
+				ID:    "music.play",
+				Match: domain.IntentMatchRules{KeywordsAny: []string{"播放", "放首歌"}},
+				Slots: []domain.IntentSlotBinding{{Name: "song", Regex: `播放(.+)`, RegexGroup: 1}},
+			},
+			{
+				ID:    "light.on",
+				Match: domain.IntentMatchRules{KeywordsAny: []string{"开灯"}},
+				Slots: []domain.IntentSlotBinding{{Name: "room", Required: true, Regex: `(客厅|卧室)`, RegexGroup: 1}},
+			},
+		},
+		Locale: "zh-CN",
+	}
+}
+
+const okBody = `{"request_id":"r1","intents":[{"intent_id":"music.play","status":"ready"}],"decision":{"action":"execute_intents","trigger_intent_id":"music.play"},"meta":{"time_signals":0}}`
+
+func TestFilterRetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(okBody))
+	}))
+	defer srv.Close()
+
+	resp, err := NewClient(srv.URL, Options{Timeout: time.Second, Attempts: 2}).Filter(context.Background(), testRequest("播放晴天"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 || resp.RequestID != "r1" || resp.MatchedLocally() {
+		t.Fatalf("calls = %d, resp = %+v", calls.Load(), resp)
+	}
+}
+
+func TestFilterDoesNotRetryRejectedRequests(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad catalog", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.URL, Options{Timeout: time.Second, Attempts: 3}).Filter(context.Background(), testRequest("播放晴天")); err == nil {
+		t.Fatal("want error")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d", calls.Load())
+	}
+}
+
+func TestFilterCachesPerTerminalAndNormalizedText(t *testing.T) {
+	var calls atomic.Int32
+	body := okBody
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, Options{Timeout: time.Second, CacheTTL: time.Minute})
+	ctx := context.Background()
+	for _, command := range []string{"播放 晴天", "  播放   晴天！"} {
+		if _, err := c.Filter(ctx, testRequest(command)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want a cache hit", calls.Load())
+	}
+	other := testRequest("播放晴天")
+	other.TerminalID = "t2"
+	if _, err := c.Filter(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want a miss for another terminal", calls.Load())
+	}
+
+	// Resolved times go stale, so those replies are not kept.
+	body = `{"request_id":"r2","meta":{"time_signals":1}}`
+	for range 2 {
+		if _, err := c.Filter(ctx, testRequest("十分钟后提醒我")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls.Load() != 4 {
+		t.Fatalf("calls = %d, want time-dependent replies uncached", calls.Load())
+	}
+
+	now := time.Now()
+	c.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if _, err := c.Filter(ctx, testRequest("播放晴天")); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 5 {
+		t.Fatalf("calls = %d, want an expired entry refetched", calls.Load())
+	}
+}
+
+func TestFilterFallsBackToLocalMatcher(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, Options{Timeout: time.Second, Attempts: 2, LocalFallback: true})
+	resp, err := c.Filter(context.Background(), testRequest("播放晴天"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.MatchedLocally() || resp.Decision.TriggerIntentID != "music.play" || resp.Intents[0].Parameters["song"] != "晴天" {
+		t.Fatalf("resp = %+v", resp)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d", calls.Load())
+	}
+
+	// Within the cooldown the service is not tried again.
+	if resp, err = c.Filter(context.Background(), testRequest("开灯")); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 || !resp.MatchedLocally() || resp.Decision.TriggerIntentID != systemFallbackIntent {
+		t.Fatalf("calls = %d, resp = %+v; want a required slot left to the LLM", calls.Load(), resp)
+	}
+}
+
+func TestMatchLocalRespectsExclusions(t *testing.T) {
+	req := testRequest("客厅开灯")
+	req.IntentCatalog[1].Match.NegativeKeywords = []string{"别"}
+	if resp := MatchLocal(req); resp.Decision.TriggerIntentID != "light.on" || resp.Intents[0].Parameters["room"] != "客厅" {
+		t.Fatalf("resp = %+v", resp)
+	}
+	req.Command = "客厅别开灯"
+	if resp := MatchLocal(req); len(resp.Intents) != 0 || resp.Decision.Action != "fallback_reasoning" {
+		t.Fatalf("resp = %+v", resp)
+	}
+}
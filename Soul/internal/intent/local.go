@@ -0,0 +1,228 @@
+package intent
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"soul/internal/domain"
+)
+
+// systemFallbackIntent is the intent-filter service's trigger for handing a
+// command to the LLM.
+const systemFallbackIntent = "sys.fallback_reasoning"
+
+// MatchLocal matches req.Command against the keyword and regex rules of the
+// catalog in process, for when the intent filter service cannot be reached.
+// It scores like the service, but without entity, time and example evidence,
+// so it stays conservative instead: it takes only the best intent, only
+// when the command carries keyword or regex evidence for it and every
+// required slot fills from a regex or default. Anything else is left to the
+// LLM. The response's Meta["matcher"] is domain.IntentMatcherLocal.
+func MatchLocal(req domain.IntentFilterRequest) domain.IntentFilterResponse {
+	text := strings.TrimSpace(req.Command)
+	folded := strings.ToLower(text)
+
+	type candidate struct {
+		spec     domain.IntentSpec
+		score    float64
+		evidence []domain.IntentFilterEvidence
+		params   map[string]any
+	}
+	var candidates []candidate
+	for _, spec := range req.IntentCatalog {
+		score, evidence, ok := localScore(spec, text, folded)
+		if !ok {
+			continue
+		}
+		params, ok := localSlots(spec.Slots, text)
+		if !ok {
+			continue
+		}
+		candidates = append(candidates, candidate{spec, score, evidence, params})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.spec.Priority != b.spec.Priority {
+			return a.spec.Priority > b.spec.Priority
+		}
+		return a.spec.ID < b.spec.ID
+	})
+
+	resp := domain.IntentFilterResponse{
+		RequestID: req.RequestID,
+		Intents:   []domain.SelectedIntent{},
+		Decision: domain.IntentFilterDecision{
+			Action:          "fallback_reasoning",
+			TriggerIntentID: systemFallbackIntent,
+			Reason:          "no_catalog_intent_matched",
+		},
+		Meta: map[string]any{
+			"matcher":      domain.IntentMatcherLocal,
+			"catalog_size": len(req.IntentCatalog),
+			"locale":       req.Locale,
+		},
+	}
+	if resp.RequestID == "" {
+		resp.RequestID = "ifr-" + uuid.NewString()
+	}
+	if len(candidates) == 0 {
+		return resp
+	}
+	best := candidates[0]
+	name := best.spec.Name
+	if name == "" {
+		name = best.spec.ID
+	}
+	resp.Intents = append(resp.Intents, domain.SelectedIntent{
+		IntentID:          best.spec.ID,
+		IntentName:        name,
+		Confidence:        math.Round(best.score*1e4) / 1e4,
+		Status:            "ready",
+		Span:              domain.IntentFilterTextSpan{Text: text, End: len([]rune(text))},
+		Parameters:        best.params,
+		Normalized:        map[string]any{},
+		MissingParameters: []string{},
+		Evidence:          best.evidence,
+	})
+	resp.Decision = domain.IntentFilterDecision{
+		Action:          "execute_intents",
+		TriggerIntentID: best.spec.ID,
+		Reason:          "matched_catalog_intents",
+	}
+	return resp
+}
+
+// localScore applies the service's weights to the rules that need no
+// entity extraction; ok is false when a rule rules the intent out or the
+// text gives no evidence for it.
+func localScore(spec domain.IntentSpec, text, folded string) (float64, []domain.IntentFilterEvidence, bool) {
+	rules := spec.Match
+	if len(rules.EntityTypesAll) > 0 {
+		return 0, nil, false
+	}
+	for _, kw := range rules.NegativeKeywords {
+		if kw != "" && strings.Contains(folded, strings.ToLower(kw)) {
+			return 0, nil, false
+		}
+	}
+
+	var score float64
+	var evidence []domain.IntentFilterEvidence
+	matched := false
+	if len(rules.KeywordsAny) > 0 {
+		var hits []string
+		for _, kw := range rules.KeywordsAny {
+			if kw != "" && strings.Contains(folded, strings.ToLower(kw)) {
+				hits = append(hits, kw)
+			}
+		}
+		if len(hits) > 0 {
+			ratio := float64(len(hits)) / float64(len(rules.KeywordsAny))
+			score += 0.38 * ratio
+			for _, kw := range hits {
+				evidence = append(evidence, domain.IntentFilterEvidence{Type: "keyword_any", Value: kw, Score: ratio})
+			}
+			matched = true
+		}
+	}
+	if len(rules.KeywordsAll) > 0 {
+		for _, kw := range rules.KeywordsAll {
+			if kw != "" && !strings.Contains(folded, strings.ToLower(kw)) {
+				return 0, nil, false
+			}
+		}
+		score += 0.25
+		for _, kw := range rules.KeywordsAll {
+			evidence = append(evidence, domain.IntentFilterEvidence{Type: "keyword_all", Value: kw, Score: 1})
+		}
+		matched = true
+	}
+	if len(rules.RegexAny) > 0 {
+		hit := false
+		for _, pattern := range rules.RegexAny {
+			if re := compileRule(pattern); re != nil && re.MatchString(text) {
+				hit = true
+				evidence = append(evidence, domain.IntentFilterEvidence{Type: "regex_any", Value: pattern, Score: 1})
+			}
+		}
+		if hit {
+			score += 0.22
+			matched = true
+		}
+	}
+	if len(rules.RegexAll) > 0 {
+		for _, pattern := range rules.RegexAll {
+			if re := compileRule(pattern); re == nil || !re.MatchString(text) {
+				return 0, nil, false
+			}
+		}
+		score += 0.12
+		for _, pattern := range rules.RegexAll {
+			evidence = append(evidence, domain.IntentFilterEvidence{Type: "regex_all", Value: pattern, Score: 1})
+		}
+		matched = true
+	}
+	if !matched {
+		return 0, nil, false
+	}
+	if spec.HintScore > 0 {
+		score += 0.16 * min(spec.HintScore, 1)
+	}
+	if spec.Priority > 0 {
+		score += 0.03 * float64(min(spec.Priority, 100)) / 100
+	}
+	score = min(score, 1)
+	if score < rules.MinConfidence {
+		return 0, nil, false
+	}
+	return score, evidence, true
+}
+
+// localSlots fills slots from their regex or default; ok is false when a
+// required slot needs more than that.
+func localSlots(slots []domain.IntentSlotBinding, text string) (map[string]any, bool) {
+	params := map[string]any{}
+	for _, slot := range slots {
+		var value any
+		if re := compileRule(slot.Regex); re != nil {
+			if m := re.FindStringSubmatch(text); m != nil {
+				if slot.RegexGroup >= 0 && slot.RegexGroup < len(m) {
+					value = m[slot.RegexGroup]
+				} else {
+					value = m[0]
+				}
+			}
+		}
+		if value == nil {
+			value = slot.Default
+		}
+		if value == nil {
+			if slot.Required {
+				return nil, false
+			}
+			continue
+		}
+		params[slot.Name] = value
+	}
+	return params, true
+}
+
+// compileRule compiles a catalog pattern case-insensitively, as the service
+// does for English; patterns Go cannot compile match nothing.
+func compileRule(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil
+	}
+	return re
+}
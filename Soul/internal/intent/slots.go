@@ -0,0 +1,92 @@
+package intent
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// NormalizeSlots maps the slot values of resp's intents onto the canonical
+// values their catalog bindings enumerate, so terminals receive "green"
+// whether the user said 绿, 绿色 or 变绿, and a new value only needs a
+// catalog entry. A canonical value replaces the captured one in Parameters
+// and is copied into Normalized, which terminals execute. A value outside
+// the enum is dropped; for a required slot the intent then waits for
+// clarification instead of reaching the terminal with a value it cannot
+// run. Slots without an enum are left as they are.
+func NormalizeSlots(catalog []domain.IntentSpec, resp domain.IntentFilterResponse) domain.IntentFilterResponse {
+	if len(resp.Intents) == 0 {
+		return resp
+	}
+	intents := make([]domain.SelectedIntent, len(resp.Intents))
+	for i, in := range resp.Intents {
+		intents[i] = in
+		idx := slices.IndexFunc(catalog, func(spec domain.IntentSpec) bool { return spec.ID == in.IntentID })
+		if idx < 0 {
+			continue
+		}
+		intents[i] = normalizeIntent(catalog[idx].Slots, in)
+	}
+	resp.Intents = intents
+	return resp
+}
+
+func normalizeIntent(slots []domain.IntentSlotBinding, in domain.SelectedIntent) domain.SelectedIntent {
+	copied := false
+	for _, slot := range slots {
+		if len(slot.Enum) == 0 {
+			continue
+		}
+		raw, ok := in.Parameters[slot.Name]
+		if !ok || raw == nil {
+			continue
+		}
+		if !copied {
+			in.Parameters = copyMap(in.Parameters)
+			in.Normalized = copyMap(in.Normalized)
+			copied = true
+		}
+		if value, ok := canonicalValue(slot.Enum, raw); ok {
+			in.Parameters[slot.Name] = value
+			in.Normalized[slot.Name] = value
+			continue
+		}
+		delete(in.Parameters, slot.Name)
+		delete(in.Normalized, slot.Name)
+		if slot.Required && !slices.Contains(in.MissingParameters, slot.Name) {
+			in.MissingParameters = append(slices.Clone(in.MissingParameters), slot.Name)
+			in.Status = "need_clarification"
+		}
+	}
+	return in
+}
+
+// canonicalValue finds the enum entry raw names, ignoring case and
+// surrounding space.
+func canonicalValue(enum []domain.IntentSlotValue, raw any) (string, bool) {
+	text := strings.TrimSpace(fmt.Sprint(raw))
+	if text == "" {
+		return "", false
+	}
+	for _, v := range enum {
+		if strings.EqualFold(text, strings.TrimSpace(v.Value)) {
+			return v.Value, true
+		}
+		for _, alias := range v.Aliases {
+			if strings.EqualFold(text, strings.TrimSpace(alias)) {
+				return v.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+func copyMap(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package intent
+
+import (
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func lightCatalog() []domain.IntentSpec {
+	return []domain.IntentSpec{{
+		ID: "intent_light_control",
+		Slots: []domain.IntentSlotBinding{
+			{Name: "skill", Default: "control_light"},
+			{Name: "mode", Regex: "(开灯|关灯|变红|变绿)", RegexGroup: 1, Enum: []domain.IntentSlotValue{
+				{Value: "on", Aliases: []string{"开灯"}},
+				{Value: "off", Aliases: []string{"关灯"}},
+				{Value: "set_color", Aliases: []string{"变红", "变绿"}},
+			}},
+			{Name: "color", Regex: "(红色|红|绿色|绿)", RegexGroup: 1, Enum: []domain.IntentSlotValue{
+				{Value: "red", Aliases: []string{"红", "红色"}},
+				{Value: "green", Aliases: []string{"绿", "绿色"}},
+			}},
+			{Name: "room", Required: true, Enum: []domain.IntentSlotValue{{Value: "living_room", Aliases: []string{"客厅"}}}},
+		},
+	}}
+}
+
+func TestNormalizeSlotsMapsAliasesToCanonicalValues(t *testing.T) {
+	resp := domain.IntentFilterResponse{Intents: []domain.SelectedIntent{{
+		IntentID:   "intent_light_control",
+		Status:     "ready",
+		Parameters: map[string]any{"skill": "control_light", "mode": "变绿", "color": "绿色", "room": "客厅"},
+		Normalized: map[string]any{"skill": "control_light"},
+	}}}
+	got := NormalizeSlots(lightCatalog(), resp).Intents[0]
+	if got.Parameters["mode"] != "set_color" || got.Parameters["color"] != "green" || got.Parameters["room"] != "living_room" {
+		t.Fatalf("parameters = %v", got.Parameters)
+	}
+	if got.Normalized["color"] != "green" || got.Normalized["skill"] != "control_light" || got.Status != "ready" {
+		t.Fatalf("intent = %+v", got)
+	}
+	// The filter's response is not modified in place.
+	if resp.Intents[0].Parameters["color"] != "绿色" || len(resp.Intents[0].Normalized) != 1 {
+		t.Fatalf("input changed: %+v", resp.Intents[0])
+	}
+}
+
+func TestNormalizeSlotsRejectsValuesOutsideTheEnum(t *testing.T) {
+	resp := domain.IntentFilterResponse{Intents: []domain.SelectedIntent{
+		{
+			IntentID:   "intent_light_control",
+			Status:     "ready",
+			Parameters: map[string]any{"mode": "变绿", "color": "紫", "room": "阳台"},
+		},
+		{IntentID: "intent_unknown", Status: "ready", Parameters: map[string]any{"color": "紫"}},
+	}}
+	got := NormalizeSlots(lightCatalog(), resp).Intents
+	if _, ok := got[0].Parameters["color"]; ok {
+		t.Fatalf("parameters = %v", got[0].Parameters)
+	}
+	if got[0].Status != "need_clarification" || len(got[0].MissingParameters) != 1 || got[0].MissingParameters[0] != "room" {
+		t.Fatalf("intent = %+v; want the required room asked for", got[0])
+	}
+	if got[1].Parameters["color"] != "紫" || got[1].Status != "ready" {
+		t.Fatalf("intent without a catalog entry changed: %+v", got[1])
+	}
+}
@@ -0,0 +1,95 @@
+// Package intentresults records what terminals report back after running an
+// intent_action, and optionally notes the outcome in the owning session so
+// the next chat turn knows what actually happened.
+package intentresults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+type Store interface {
+	SaveIntentResults(ctx context.Context, payload domain.IntentResultPayload) ([]domain.IntentResult, error)
+	GetSession(ctx context.Context, sessionID string) (domain.SessionInfo, error)
+}
+
+// MessageWriter appends a message to a session; memory.Service does it.
+type MessageWriter interface {
+	PersistMessage(ctx context.Context, sessionID, userID, terminalID, soulID, role, name, toolCallID, content string) error
+}
+
+// Recorder persists intent results for mqtt.Hub.
+type Recorder struct {
+	store    Store
+	messages MessageWriter
+	logger   *slog.Logger
+}
+
+// NewRecorder builds a Recorder; with a nil messages results are stored but
+// not written into sessions.
+func NewRecorder(store Store, messages MessageWriter, logger *slog.Logger) *Recorder {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Recorder{store: store, messages: messages, logger: logger}
+}
+
+func (r *Recorder) RecordIntentResults(ctx context.Context, payload domain.IntentResultPayload) error {
+	if len(payload.Results) == 0 {
+		return nil
+	}
+	if _, err := r.store.SaveIntentResults(ctx, payload); err != nil {
+		return err
+	}
+	r.logger.Info("intent results recorded", "request_id", payload.RequestID, "terminal_id", payload.TerminalID, "count", len(payload.Results))
+	if r.messages == nil || strings.TrimSpace(payload.SessionID) == "" {
+		return nil
+	}
+
+	// The session row carries the user; the terminal's report does not.
+	session, err := r.store.GetSession(ctx, payload.SessionID)
+	if err != nil {
+		if errors.Is(err, db.ErrSessionNotFound) {
+			return nil
+		}
+		return fmt.Errorf("load session: %w", err)
+	}
+	soulID := session.SoulID
+	if soulID == "" {
+		soulID = payload.SoulID
+	}
+	return r.messages.PersistMessage(ctx, payload.SessionID, session.UserID, payload.TerminalID, soulID, "system", "intent_result", "", resultMessage(payload.Results))
+}
+
+// resultMessage summarises the results in one line per intent, e.g.
+// "控制灯光（control_light）：成功，已开灯".
+func resultMessage(results []domain.IntentResultItem) string {
+	lines := make([]string, 0, len(results)+1)
+	lines = append(lines, "终端意图执行结果：")
+	for _, item := range results {
+		name := strings.TrimSpace(item.IntentName)
+		if name == "" {
+			name = item.IntentID
+		}
+		if skill := strings.TrimSpace(item.Skill); skill != "" {
+			name = fmt.Sprintf("%s（%s）", name, skill)
+		}
+		outcome := "成功"
+		detail := strings.TrimSpace(item.Output)
+		if !item.OK {
+			outcome = "失败"
+			detail = strings.TrimSpace(item.Error)
+		}
+		if detail != "" {
+			outcome += "，" + detail
+		}
+		lines = append(lines, fmt.Sprintf("- %s：%s", name, outcome))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,91 @@
+package intentresults
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+type memoryStore struct {
+	saved    []domain.IntentResultPayload
+	sessions map[string]domain.SessionInfo
+}
+
+func (s *memoryStore) SaveIntentResults(_ context.Context, payload domain.IntentResultPayload) ([]domain.IntentResult, error) {
+	s.saved = append(s.saved, payload)
+	return nil, nil
+}
+
+func (s *memoryStore) GetSession(_ context.Context, sessionID string) (domain.SessionInfo, error) {
+	info, ok := s.sessions[sessionID]
+	if !ok {
+		return domain.SessionInfo{}, db.ErrSessionNotFound
+	}
+	return info, nil
+}
+
+type sessionMessage struct {
+	sessionID, userID, soulID, role, name, content string
+}
+
+type recordingWriter struct {
+	messages []sessionMessage
+}
+
+func (w *recordingWriter) PersistMessage(_ context.Context, sessionID, userID, _, soulID, role, name, _, content string) error {
+	w.messages = append(w.messages, sessionMessage{sessionID, userID, soulID, role, name, content})
+	return nil
+}
+
+func TestRecordIntentResultsNotesSession(t *testing.T) {
+	store := &memoryStore{sessions: map[string]domain.SessionInfo{"s1": {SessionID: "s1", UserID: "u1", SoulID: "soul-1"}}}
+	writer := &recordingWriter{}
+	recorder := NewRecorder(store, writer, nil)
+
+	payload := domain.IntentResultPayload{
+		RequestID:  "ia-1",
+		SessionID:  "s1",
+		TerminalID: "t1",
+		Results: []domain.IntentResultItem{
+			{IntentID: "light", IntentName: "控制灯光", Skill: "control_light", OK: true, Output: "灯已打开"},
+			{IntentID: "alarm", OK: false, Error: "闹钟已满"},
+		},
+	}
+	if err := recorder.RecordIntentResults(context.Background(), payload); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if len(store.saved) != 1 {
+		t.Fatalf("expected results to be stored, got %d", len(store.saved))
+	}
+	if len(writer.messages) != 1 {
+		t.Fatalf("expected one session message, got %d", len(writer.messages))
+	}
+	msg := writer.messages[0]
+	if msg.userID != "u1" || msg.soulID != "soul-1" || msg.role != "system" || msg.name != "intent_result" {
+		t.Fatalf("unexpected session message %+v", msg)
+	}
+	for _, want := range []string{"控制灯光（control_light）：成功，灯已打开", "alarm：失败，闹钟已满"} {
+		if !strings.Contains(msg.content, want) {
+			t.Fatalf("message %q is missing %q", msg.content, want)
+		}
+	}
+}
+
+func TestRecordIntentResultsWithoutSession(t *testing.T) {
+	store := &memoryStore{}
+	writer := &recordingWriter{}
+	payload := domain.IntentResultPayload{RequestID: "ia-1", SessionID: "gone", TerminalID: "t1", Results: []domain.IntentResultItem{{IntentID: "light", OK: true}}}
+
+	if err := NewRecorder(store, writer, nil).RecordIntentResults(context.Background(), payload); err != nil {
+		t.Fatalf("unknown session must not fail the report: %v", err)
+	}
+	if err := NewRecorder(store, nil, nil).RecordIntentResults(context.Background(), payload); err != nil {
+		t.Fatalf("record without notes: %v", err)
+	}
+	if len(store.saved) != 2 || len(writer.messages) != 0 {
+		t.Fatalf("expected results stored without session notes, saved=%d messages=%d", len(store.saved), len(writer.messages))
+	}
+}
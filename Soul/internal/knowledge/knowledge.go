@@ -0,0 +1,197 @@
+// Package knowledge lets a soul answer from documents its user uploads,
+// such as device manuals or notes: documents are split into overlapping
+// chunks, embedded with the LLM provider and searched by cosine similarity
+// in pgvector.
+package knowledge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"soul/internal/domain"
+	"soul/internal/llm"
+)
+
+// ErrInvalidDocument wraps the reasons an upload is rejected.
+var ErrInvalidDocument = errors.New("invalid knowledge document")
+
+type Store interface {
+	InsertKnowledgeDocument(ctx context.Context, doc domain.KnowledgeDocument, chunks []string, vectors [][]float32) (domain.KnowledgeDocument, error)
+	ListKnowledgeDocuments(ctx context.Context, soulID string) ([]domain.KnowledgeDocument, error)
+	DeleteKnowledgeDocument(ctx context.Context, soulID string, id int64) error
+	HasKnowledge(ctx context.Context, soulID string) (bool, error)
+	SearchKnowledge(ctx context.Context, soulID string, vector []float32, limit int) ([]domain.KnowledgeHit, error)
+}
+
+// Embedder turns texts into vectors; llm.Provider does it.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) (llm.Embeddings, error)
+}
+
+type Config struct {
+	// ChunkRunes is the target chunk length and ChunkOverlap how many runes
+	// of a chunk's tail the next one repeats, so a sentence cut at a
+	// boundary is still found whole.
+	ChunkRunes   int
+	ChunkOverlap int
+	// MaxDocumentRunes rejects larger uploads.
+	MaxDocumentRunes int
+	// TopK bounds the hits of a search; hits below MinScore are dropped.
+	TopK     int
+	MinScore float64
+}
+
+func (c Config) withDefaults() Config {
+	if c.ChunkRunes <= 0 {
+		c.ChunkRunes = 500
+	}
+	if c.ChunkOverlap < 0 || c.ChunkOverlap >= c.ChunkRunes {
+		c.ChunkOverlap = c.ChunkRunes / 10
+	}
+	if c.MaxDocumentRunes <= 0 {
+		c.MaxDocumentRunes = 200000
+	}
+	if c.TopK <= 0 {
+		c.TopK = 4
+	}
+	return c
+}
+
+type Service struct {
+	store    Store
+	embedder Embedder
+	cfg      Config
+}
+
+func New(store Store, embedder Embedder, cfg Config) *Service {
+	return &Service{store: store, embedder: embedder, cfg: cfg.withDefaults()}
+}
+
+// AddDocument chunks and embeds content and stores it for soulID.
+func (s *Service) AddDocument(ctx context.Context, soulID, title, content string) (domain.KnowledgeDocument, error) {
+	title = strings.TrimSpace(title)
+	content = strings.TrimSpace(content)
+	if title == "" || content == "" {
+		return domain.KnowledgeDocument{}, fmt.Errorf("%w: title and content are required", ErrInvalidDocument)
+	}
+	chars := utf8.RuneCountInString(content)
+	if chars > s.cfg.MaxDocumentRunes {
+		return domain.KnowledgeDocument{}, fmt.Errorf("%w: %d characters, at most %d", ErrInvalidDocument, chars, s.cfg.MaxDocumentRunes)
+	}
+	chunks := Chunk(content, s.cfg.ChunkRunes, s.cfg.ChunkOverlap)
+	emb, err := s.embedder.Embed(ctx, chunks)
+	if err != nil {
+		return domain.KnowledgeDocument{}, fmt.Errorf("embed document: %w", err)
+	}
+	if len(emb.Vectors) != len(chunks) {
+		return domain.KnowledgeDocument{}, fmt.Errorf("embed document: %d vectors for %d chunks", len(emb.Vectors), len(chunks))
+	}
+	return s.store.InsertKnowledgeDocument(ctx, domain.KnowledgeDocument{SoulID: soulID, Title: title, Chars: chars}, chunks, emb.Vectors)
+}
+
+func (s *Service) ListDocuments(ctx context.Context, soulID string) ([]domain.KnowledgeDocument, error) {
+	return s.store.ListKnowledgeDocuments(ctx, soulID)
+}
+
+func (s *Service) DeleteDocument(ctx context.Context, soulID string, id int64) error {
+	return s.store.DeleteKnowledgeDocument(ctx, soulID, id)
+}
+
+// HasDocuments tells whether searching soulID's knowledge can find anything.
+func (s *Service) HasDocuments(ctx context.Context, soulID string) (bool, error) {
+	return s.store.HasKnowledge(ctx, soulID)
+}
+
+// Search returns the chunks of soulID's documents closest to query, best
+// first.
+func (s *Service) Search(ctx context.Context, soulID, query string) ([]domain.KnowledgeHit, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+	emb, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	if len(emb.Vectors) != 1 {
+		return nil, fmt.Errorf("embed query: %d vectors for 1 text", len(emb.Vectors))
+	}
+	hits, err := s.store.SearchKnowledge(ctx, soulID, emb.Vectors[0], s.cfg.TopK)
+	if err != nil {
+		return nil, err
+	}
+	out := hits[:0]
+	for _, h := range hits {
+		if h.Score >= s.cfg.MinScore {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+// Chunk splits text into pieces of about size runes, breaking between
+// paragraphs where it can and at sentence ends otherwise. Each piece after
+// the first starts with the last overlap runes of the previous one.
+func Chunk(text string, size, overlap int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if overlap >= size {
+		overlap = 0
+	}
+	var pieces []string
+	for _, para := range strings.Split(text, "\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		pieces = append(pieces, splitLong([]rune(para), size-overlap)...)
+	}
+
+	// cur holds the chunk being built; carried is how much of it is the
+	// previous chunk's tail, which alone is not worth a chunk.
+	var chunks []string
+	var cur []rune
+	carried := 0
+	for _, p := range pieces {
+		r := []rune(p)
+		if len(cur) > carried && len(cur)+1+len(r) > size {
+			chunks = append(chunks, string(cur))
+			cur = append([]rune(nil), cur[max(0, len(cur)-overlap):]...)
+			carried = len(cur)
+		}
+		if len(cur) > 0 {
+			cur = append(cur, '\n')
+		}
+		cur = append(cur, r...)
+	}
+	if len(cur) > carried {
+		chunks = append(chunks, string(cur))
+	}
+	return chunks
+}
+
+// splitLong cuts a paragraph longer than size runes, preferring to cut
+// after sentence-ending punctuation in the second half of each piece.
+func splitLong(r []rune, size int) []string {
+	var out []string
+	for len(r) > size {
+		cut := size
+		for i := size - 1; i >= size/2; i-- {
+			if strings.ContainsRune("。！？；.!?;", r[i]) {
+				cut = i + 1
+				break
+			}
+		}
+		out = append(out, strings.TrimSpace(string(r[:cut])))
+		r = r[cut:]
+	}
+	if s := strings.TrimSpace(string(r)); s != "" {
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+package knowledge
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"soul/internal/domain"
+	"soul/internal/llm"
+)
+
+func TestChunk(t *testing.T) {
+	if got := Chunk("  ", 100, 10); got != nil {
+		t.Fatalf("blank text: got %v", got)
+	}
+	if got := Chunk("短文。", 100, 10); len(got) != 1 || got[0] != "短文。" {
+		t.Fatalf("short text: got %v", got)
+	}
+
+	para := strings.Repeat("这是一句说明。", 10) // 70 runes
+	text := strings.Join([]string{para, para, para, para}, "\n\n")
+	chunks := Chunk(text, 160, 20)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+	for i, c := range chunks {
+		if n := utf8.RuneCountInString(c); n > 161 {
+			t.Fatalf("chunk %d has %d runes", i, n)
+		}
+		if i > 0 {
+			prev := []rune(chunks[i-1])
+			tail := string(prev[len(prev)-20:])
+			if !strings.HasPrefix(c, tail) {
+				t.Fatalf("chunk %d does not start with the previous tail %q", i, tail)
+			}
+		}
+	}
+
+	long := strings.Repeat("一二三四五六七八九。", 30) // one 300-rune paragraph
+	for i, c := range Chunk(long, 100, 0) {
+		if n := utf8.RuneCountInString(c); n > 100 {
+			t.Fatalf("long paragraph chunk %d has %d runes", i, n)
+		}
+		if !strings.HasSuffix(c, "。") {
+			t.Fatalf("chunk %d not cut at a sentence end: %q", i, c)
+		}
+	}
+}
+
+type fakeStore struct {
+	Store
+	chunks []string
+	hits   []domain.KnowledgeHit
+}
+
+func (f *fakeStore) InsertKnowledgeDocument(_ context.Context, doc domain.KnowledgeDocument, chunks []string, _ [][]float32) (domain.KnowledgeDocument, error) {
+	f.chunks = chunks
+	doc.ChunkCount = len(chunks)
+	return doc, nil
+}
+
+func (f *fakeStore) SearchKnowledge(context.Context, string, []float32, int) ([]domain.KnowledgeHit, error) {
+	return f.hits, nil
+}
+
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) Embed(_ context.Context, texts []string) (llm.Embeddings, error) {
+	out := llm.Embeddings{Dimensions: 2}
+	for range texts {
+		out.Vectors = append(out.Vectors, []float32{1, 0})
+	}
+	return out, nil
+}
+
+func TestServiceAddAndSearch(t *testing.T) {
+	store := &fakeStore{hits: []domain.KnowledgeHit{{Content: "a", Score: 0.9}, {Content: "b", Score: 0.2}}}
+	svc := New(store, fakeEmbedder{}, Config{ChunkRunes: 50, MinScore: 0.5, MaxDocumentRunes: 1000})
+
+	if _, err := svc.AddDocument(context.Background(), "soul", "", "x"); err == nil {
+		t.Fatal("expected missing title to be rejected")
+	}
+	if _, err := svc.AddDocument(context.Background(), "soul", "big", strings.Repeat("字", 1001)); err == nil {
+		t.Fatal("expected oversized document to be rejected")
+	}
+	doc, err := svc.AddDocument(context.Background(), "soul", "说明书", strings.Repeat("按住电源键三秒开机。\n", 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ChunkCount != len(store.chunks) || doc.ChunkCount < 2 || doc.Chars == 0 {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+
+	hits, err := svc.Search(context.Background(), "soul", "怎么开机")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Content != "a" {
+		t.Fatalf("expected only the hit above MinScore, got %+v", hits)
+	}
+}
@@ -0,0 +1,75 @@
+// Package language names the reply languages the soul supports and guesses
+// which one a user wrote in.
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	Chinese = "zh"
+	English = "en"
+
+	// Default is used when neither a preference nor the input decides.
+	Default = Chinese
+)
+
+// Normalize maps a language tag or name to a supported language, or ""
+// when it is empty or unsupported: "zh-CN", "中文" -> zh, "en_US" -> en.
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i > 0 {
+		tag = tag[:i]
+	}
+	switch tag {
+	case "zh", "cn", "chinese", "中文", "汉语", "普通话":
+		return Chinese
+	case "en", "english", "英文", "英语":
+		return English
+	}
+	return ""
+}
+
+// Detect guesses the language of text from its script: Han characters mean
+// Chinese unless English words clearly outnumber them. It returns "" when
+// the text is too short to tell, e.g. "ok" or "123".
+func Detect(text string) string {
+	han, words, letters := 0, 0, 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+			inWord = false
+		case r < unicode.MaxASCII && unicode.IsLetter(r):
+			letters++
+			if !inWord {
+				words++
+			}
+			inWord = true
+		default:
+			inWord = inWord && r == '\''
+		}
+	}
+	switch {
+	case han > 0 && han >= words:
+		return Chinese
+	case words >= 2 || letters >= 4:
+		return English
+	case han > 0:
+		return Chinese
+	}
+	return ""
+}
+
+// Locale is the intent filter locale of a language.
+func Locale(lang string) string {
+	switch lang {
+	case Chinese:
+		return "zh-CN"
+	case English:
+		return "en-US"
+	}
+	return ""
+}
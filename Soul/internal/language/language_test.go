@@ -0,0 +1,38 @@
+package language
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{
+		"zh":       Chinese,
+		"zh-CN":    Chinese,
+		"中文":       Chinese,
+		"en_US":    English,
+		" English": English,
+		"fr":       "",
+		"":         "",
+	}
+	for in, want := range cases {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		"帮我把灯关掉":                    Chinese,
+		"帮我打开Spotify":               Chinese,
+		"Turn off the light please": English,
+		"What does 你好 mean":         English,
+		"don't":                     English,
+		"ok":                        "",
+		"123":                       "",
+		"好":                         Chinese,
+	}
+	for in, want := range cases {
+		if got := Detect(in); got != want {
+			t.Errorf("Detect(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package liveness passes the MQTT hub's terminal offline/online events on
+// to a webhook, so someone notices a robot that dropped off the network.
+package liveness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// WebhookNotifier POSTs each liveness event as JSON, for a chat gateway or
+// an ops dashboard to pass on.
+type WebhookNotifier struct {
+	url    string
+	token  string
+	http   *http.Client
+	logger *slog.Logger
+}
+
+func NewWebhookNotifier(url, token string, timeout time.Duration, logger *slog.Logger) *WebhookNotifier {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &WebhookNotifier{url: strings.TrimSpace(url), token: strings.TrimSpace(token), http: &http.Client{Timeout: timeout}, logger: logger}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, event domain.TerminalLivenessEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("liveness webhook status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// Handle notifies the webhook and logs a failure; it is meant for the MQTT
+// hub's liveness hook.
+func (n *WebhookNotifier) Handle(event domain.TerminalLivenessEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), n.http.Timeout)
+	defer cancel()
+	if err := n.Notify(ctx, event); err != nil {
+		n.logger.Warn("liveness webhook failed", "terminal_id", event.TerminalID, "status", event.Status, "error", err)
+	}
+}
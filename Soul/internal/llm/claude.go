@@ -1,8 +1,10 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,22 +14,55 @@ import (
 	"soul/internal/domain"
 )
 
+const claudeDefaultMaxTokens = 1024
+
+type ClaudeOptions struct {
+	MaxTokens int
+	// ThinkingBudget enables extended thinking with this many budget tokens
+	// when positive; the API requires at least 1024.
+	ThinkingBudget int
+	// Stream makes Complete use the SSE endpoint, which avoids idle timeouts on
+	// long thinking turns.
+	Stream bool
+}
+
 type ClaudeProvider struct {
 	client  *http.Client
 	baseURL string
 	apiKey  string
+	opts    ClaudeOptions
 }
 
-func NewClaudeProvider(client *http.Client, baseURL, apiKey string) *ClaudeProvider {
-	return &ClaudeProvider{client: client, baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey}
+func NewClaudeProvider(client *http.Client, baseURL, apiKey string, opts ClaudeOptions) *ClaudeProvider {
+	if opts.MaxTokens <= 0 {
+		opts.MaxTokens = claudeDefaultMaxTokens
+	}
+	if opts.ThinkingBudget > 0 && opts.MaxTokens <= opts.ThinkingBudget {
+		// max_tokens includes the thinking budget; keep room for the answer.
+		opts.MaxTokens = opts.ThinkingBudget + claudeDefaultMaxTokens
+	}
+	return &ClaudeProvider{client: client, baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, opts: opts}
 }
 
 type claudeRequest struct {
-	Model     string          `json:"model"`
-	System    string          `json:"system,omitempty"`
-	MaxTokens int             `json:"max_tokens"`
-	Messages  []claudeMessage `json:"messages"`
-	Tools     []claudeTool    `json:"tools,omitempty"`
+	Model      string            `json:"model"`
+	System     string            `json:"system,omitempty"`
+	MaxTokens  int               `json:"max_tokens"`
+	Messages   []claudeMessage   `json:"messages"`
+	Tools      []claudeTool      `json:"tools,omitempty"`
+	ToolChoice *claudeToolChoice `json:"tool_choice,omitempty"`
+	Thinking   *claudeThinking   `json:"thinking,omitempty"`
+	Stream     bool              `json:"stream,omitempty"`
+}
+
+type claudeToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type claudeThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
 }
 
 type claudeMessage struct {
@@ -43,6 +78,16 @@ type claudeBlock struct {
 	Input     json.RawMessage `json:"input,omitempty"`
 	ToolUseID string          `json:"tool_use_id,omitempty"`
 	Content   string          `json:"content,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+	Data      string          `json:"data,omitempty"`
+	Source    *claudeSource   `json:"source,omitempty"`
+}
+
+type claudeSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 type claudeTool struct {
@@ -51,51 +96,89 @@ type claudeTool struct {
 	InputSchema json.RawMessage `json:"input_schema"`
 }
 
+type claudeError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
 type claudeResponse struct {
 	Content []claudeBlock `json:"content"`
-	Error   *struct {
-		Message string `json:"message"`
-	} `json:"error,omitempty"`
+	Error   *claudeError  `json:"error,omitempty"`
 }
 
 func (p *ClaudeProvider) Complete(ctx context.Context, req domain.LLMRequest) (domain.LLMResponse, error) {
+	if p.opts.Stream {
+		return p.Stream(ctx, req, nil)
+	}
+
+	payload := p.buildRequest(req, false)
+	resp, err := p.send(ctx, payload)
+	if err != nil {
+		return domain.LLMResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	var parsed claudeResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return domain.LLMResponse{}, err
+	}
+	if parsed.Error != nil {
+		return domain.LLMResponse{}, fmt.Errorf("claude error: %s", parsed.Error.Message)
+	}
+	return claudeResponseFor(payload, parsed.Content), nil
+}
+
+func (p *ClaudeProvider) warm(ctx context.Context, n int) error {
+	return warmConns(ctx, p.client, p.baseURL, n)
+}
+
+// Stream runs the request over SSE, calling onDelta for every text or thinking
+// delta and once per completed tool call, and returns the assembled response.
+func (p *ClaudeProvider) Stream(ctx context.Context, req domain.LLMRequest, onDelta func(StreamDelta)) (domain.LLMResponse, error) {
+	if onDelta == nil {
+		onDelta = func(StreamDelta) {}
+	}
+	payload := p.buildRequest(req, true)
+	resp, err := p.send(ctx, payload)
+	if err != nil {
+		return domain.LLMResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	acc := newClaudeStreamAccumulator(onDelta)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		done, err := acc.handle([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))))
+		if err != nil {
+			return domain.LLMResponse{}, err
+		}
+		if done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return domain.LLMResponse{}, fmt.Errorf("read claude stream: %w", err)
+	}
+	return claudeResponseFor(payload, acc.blocks), nil
+}
+
+func (p *ClaudeProvider) buildRequest(req domain.LLMRequest, stream bool) claudeRequest {
 	payload := claudeRequest{
 		Model:     req.Model,
 		System:    req.System,
-		MaxTokens: 1024,
-		Messages:  make([]claudeMessage, 0, len(req.Messages)),
+		MaxTokens: p.opts.MaxTokens,
+		Messages:  buildClaudeMessages(req.Messages),
+		Stream:    stream,
 	}
-	for _, m := range req.Messages {
-		switch m.Role {
-		case "user", "assistant":
-			cm := claudeMessage{Role: m.Role}
-			if m.Content != "" {
-				cm.Content = append(cm.Content, claudeBlock{Type: "text", Text: m.Content})
-			}
-			for _, tc := range m.ToolCalls {
-				cm.Content = append(cm.Content, claudeBlock{
-					Type:  "tool_use",
-					ID:    tc.ID,
-					Name:  tc.Name,
-					Input: normalizeSchema(tc.Arguments),
-				})
-			}
-			if len(cm.Content) == 0 {
-				cm.Content = []claudeBlock{{Type: "text", Text: ""}}
-			}
-			payload.Messages = append(payload.Messages, cm)
-		case "tool":
-			payload.Messages = append(payload.Messages, claudeMessage{
-				Role: "user",
-				Content: []claudeBlock{{
-					Type:      "tool_result",
-					ToolUseID: m.ToolCallID,
-					Content:   m.Content,
-				}},
-			})
-		}
+	if p.opts.ThinkingBudget > 0 {
+		payload.Thinking = &claudeThinking{Type: "enabled", BudgetTokens: p.opts.ThinkingBudget}
 	}
-
 	if len(req.Tools) > 0 {
 		payload.Tools = make([]claudeTool, 0, len(req.Tools))
 		for _, t := range req.Tools {
@@ -106,41 +189,144 @@ func (p *ClaudeProvider) Complete(ctx context.Context, req domain.LLMRequest) (d
 			})
 		}
 	}
+	// Claude has no JSON mode; a forced tool call yields schema-shaped input.
+	// Forced tool choice is not allowed together with extended thinking, which
+	// then falls back to the prompt instructions added by CompleteJSON.
+	if f := req.ResponseFormat; f != nil && payload.Thinking == nil {
+		payload.Tools = append(payload.Tools, claudeTool{
+			Name:        f.Name,
+			Description: "以结构化 JSON 输出本轮结果。",
+			InputSchema: normalizeSchema(f.Schema),
+		})
+		payload.ToolChoice = &claudeToolChoice{Type: "tool", Name: f.Name}
+	}
+	return payload
+}
+
+// claudeResponseFor converts the reply blocks and, for a forced
+// structured-output tool, returns its input as the reply content.
+func claudeResponseFor(payload claudeRequest, blocks []claudeBlock) domain.LLMResponse {
+	out := claudeBlocksToResponse(blocks)
+	if payload.ToolChoice == nil || payload.ToolChoice.Type != "tool" {
+		return out
+	}
+	calls := out.ToolCalls[:0]
+	for _, tc := range out.ToolCalls {
+		if tc.Name == payload.ToolChoice.Name {
+			out.Content = string(tc.Arguments)
+			continue
+		}
+		calls = append(calls, tc)
+	}
+	out.ToolCalls = calls
+	return out
+}
 
+func (p *ClaudeProvider) send(ctx context.Context, payload claudeRequest) (*http.Response, error) {
 	buf, err := json.Marshal(payload)
 	if err != nil {
-		return domain.LLMResponse{}, err
+		return nil, err
 	}
 
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(buf))
 	if err != nil {
-		return domain.LLMResponse{}, err
+		return nil, err
 	}
 	httpReq.Header.Set("x-api-key", p.apiKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
 	httpReq.Header.Set("content-type", "application/json")
+	if payload.Stream {
+		httpReq.Header.Set("accept", "text/event-stream")
+	}
 
 	resp, err := p.client.Do(httpReq)
 	if err != nil {
-		return domain.LLMResponse{}, err
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 300 {
-		return domain.LLMResponse{}, fmt.Errorf("claude status %d: %s", resp.StatusCode, string(body))
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newAPIError("claude", resp, body)
 	}
+	return resp, nil
+}
 
-	var parsed claudeResponse
-	if err := json.Unmarshal(body, &parsed); err != nil {
-		return domain.LLMResponse{}, err
+// buildClaudeMessages maps the orchestrator history onto Anthropic's
+// alternating roles. Tool results become tool_result blocks of one user
+// message following the assistant tool_use; results whose tool_use is not in
+// the preceding assistant message (e.g. history reloaded from the database)
+// are sent as plain text, which the API accepts.
+func buildClaudeMessages(msgs []domain.Message) []claudeMessage {
+	out := make([]claudeMessage, 0, len(msgs))
+	appendBlocks := func(role string, blocks ...claudeBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			return
+		}
+		out = append(out, claudeMessage{Role: role, Content: blocks})
 	}
-	if parsed.Error != nil {
-		return domain.LLMResponse{}, fmt.Errorf("claude error: %s", parsed.Error.Message)
+
+	var toolUseIDs map[string]struct{}
+	for _, m := range msgs {
+		switch m.Role {
+		case "user", "assistant":
+			var blocks []claudeBlock
+			if m.Role == "assistant" {
+				for _, th := range m.Thinking {
+					if th.Redacted {
+						blocks = append(blocks, claudeBlock{Type: "redacted_thinking", Data: th.Data})
+						continue
+					}
+					blocks = append(blocks, claudeBlock{Type: "thinking", Thinking: th.Thinking, Signature: th.Signature})
+				}
+			}
+			if m.Role == "user" {
+				for _, img := range m.Images {
+					blocks = append(blocks, claudeBlock{Type: "image", Source: &claudeSource{Type: "base64", MediaType: img.Mime, Data: base64.StdEncoding.EncodeToString(img.Data)}})
+				}
+			}
+			if strings.TrimSpace(m.Content) != "" {
+				blocks = append(blocks, claudeBlock{Type: "text", Text: m.Content})
+			}
+			toolUseIDs = nil
+			for _, tc := range m.ToolCalls {
+				if toolUseIDs == nil {
+					toolUseIDs = make(map[string]struct{}, len(m.ToolCalls))
+				}
+				toolUseIDs[tc.ID] = struct{}{}
+				blocks = append(blocks, claudeBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Name,
+					Input: normalizeSchema(tc.Arguments),
+				})
+			}
+			appendBlocks(m.Role, blocks...)
+		case "system":
+			// The Messages API has no mid-conversation system turn; events
+			// such as a fired reminder reach the model as marked user text.
+			if strings.TrimSpace(m.Content) != "" {
+				appendBlocks("user", claudeBlock{Type: "text", Text: fmt.Sprintf("[系统消息] %s", m.Content)})
+			}
+		case "tool":
+			if _, ok := toolUseIDs[m.ToolCallID]; ok && m.ToolCallID != "" {
+				appendBlocks("user", claudeBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Content})
+				continue
+			}
+			if strings.TrimSpace(m.Content) != "" {
+				appendBlocks("user", claudeBlock{Type: "text", Text: fmt.Sprintf("[工具 %s 结果] %s", m.Name, m.Content)})
+			}
+		}
 	}
+	return out
+}
 
+func claudeBlocksToResponse(blocks []claudeBlock) domain.LLMResponse {
 	out := domain.LLMResponse{}
-	for _, block := range parsed.Content {
+	for _, block := range blocks {
 		switch block.Type {
 		case "text":
 			if block.Text != "" {
@@ -156,7 +342,114 @@ func (p *ClaudeProvider) Complete(ctx context.Context, req domain.LLMRequest) (d
 				Name:      block.Name,
 				Arguments: normalizeSchema(block.Input),
 			})
+		case "thinking":
+			out.Thinking = append(out.Thinking, domain.ThinkingBlock{Thinking: block.Thinking, Signature: block.Signature})
+		case "redacted_thinking":
+			out.Thinking = append(out.Thinking, domain.ThinkingBlock{Redacted: true, Data: block.Data})
+		}
+	}
+	return out
+}
+
+type claudeStreamEvent struct {
+	Type         string          `json:"type"`
+	Index        int             `json:"index"`
+	ContentBlock *claudeBlock    `json:"content_block,omitempty"`
+	Delta        json.RawMessage `json:"delta,omitempty"`
+	Error        *claudeError    `json:"error,omitempty"`
+}
+
+type claudeStreamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	PartialJSON string `json:"partial_json"`
+	Thinking    string `json:"thinking"`
+	Signature   string `json:"signature"`
+}
+
+type claudeStreamAccumulator struct {
+	onDelta func(StreamDelta)
+	blocks  []claudeBlock
+	inputs  map[int]*strings.Builder
+}
+
+func newClaudeStreamAccumulator(onDelta func(StreamDelta)) *claudeStreamAccumulator {
+	return &claudeStreamAccumulator{onDelta: onDelta, inputs: make(map[int]*strings.Builder)}
+}
+
+// handle applies one SSE data payload and reports whether the message ended.
+func (a *claudeStreamAccumulator) handle(data []byte) (bool, error) {
+	var ev claudeStreamEvent
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return false, fmt.Errorf("decode claude stream event: %w", err)
+	}
+	switch ev.Type {
+	case "error":
+		if ev.Error != nil {
+			// Errors after a 200 arrive in-stream; map the transient ones onto
+			// the status codes the retry policy understands.
+			switch ev.Error.Type {
+			case "overloaded_error":
+				return false, &APIError{Provider: "claude", StatusCode: 529, Body: ev.Error.Message}
+			case "rate_limit_error":
+				return false, &APIError{Provider: "claude", StatusCode: http.StatusTooManyRequests, Body: ev.Error.Message}
+			case "api_error":
+				return false, &APIError{Provider: "claude", StatusCode: http.StatusInternalServerError, Body: ev.Error.Message}
+			}
+			return false, fmt.Errorf("claude error: %s", ev.Error.Message)
+		}
+		return false, fmt.Errorf("claude stream error")
+	case "content_block_start":
+		if ev.ContentBlock == nil {
+			return false, nil
+		}
+		for len(a.blocks) <= ev.Index {
+			a.blocks = append(a.blocks, claudeBlock{})
+		}
+		block := *ev.ContentBlock
+		if block.Type == "tool_use" {
+			// Input arrives as input_json_delta fragments.
+			block.Input = nil
+			a.inputs[ev.Index] = &strings.Builder{}
+		}
+		a.blocks[ev.Index] = block
+	case "content_block_delta":
+		if ev.Index >= len(a.blocks) {
+			return false, fmt.Errorf("claude stream delta for unknown block %d", ev.Index)
+		}
+		var d claudeStreamDelta
+		if err := json.Unmarshal(ev.Delta, &d); err != nil {
+			return false, fmt.Errorf("decode claude stream delta: %w", err)
+		}
+		block := &a.blocks[ev.Index]
+		switch d.Type {
+		case "text_delta":
+			block.Text += d.Text
+			a.onDelta(StreamDelta{Text: d.Text})
+		case "thinking_delta":
+			block.Thinking += d.Thinking
+			a.onDelta(StreamDelta{Thinking: d.Thinking})
+		case "signature_delta":
+			block.Signature += d.Signature
+		case "input_json_delta":
+			if sb, ok := a.inputs[ev.Index]; ok {
+				sb.WriteString(d.PartialJSON)
+			}
+		}
+	case "content_block_stop":
+		if ev.Index >= len(a.blocks) {
+			return false, nil
+		}
+		block := &a.blocks[ev.Index]
+		if sb, ok := a.inputs[ev.Index]; ok {
+			if sb.Len() > 0 {
+				block.Input = json.RawMessage(sb.String())
+			}
+			delete(a.inputs, ev.Index)
+			a.onDelta(StreamDelta{ToolCall: &domain.ToolCall{ID: block.ID, Name: block.Name, Arguments: normalizeSchema(block.Input)}})
 		}
+	case "message_stop":
+		return true, nil
 	}
-	return out, nil
+	return false, nil
 }
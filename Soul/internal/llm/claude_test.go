@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestBuildClaudeMessagesMapsToolLoop(t *testing.T) {
+	msgs := buildClaudeMessages([]domain.Message{
+		// Reloaded history: a stored tool row without its tool_use.
+		{Role: "tool", Name: "light_off", ToolCallID: "old_1", Content: "已关灯"},
+		{Role: "user", Content: "再把窗帘也拉上"},
+		{
+			Role:      "assistant",
+			Thinking:  []domain.ThinkingBlock{{Thinking: "需要两个工具", Signature: "sig"}, {Redacted: true, Data: "enc"}},
+			ToolCalls: []domain.ToolCall{{ID: "tu_1", Name: "curtain_close"}, {ID: "tu_2", Name: "light_off"}},
+		},
+		{Role: "tool", Name: "curtain_close", ToolCallID: "tu_1", Content: "ok"},
+		{Role: "tool", Name: "light_off", ToolCallID: "tu_2", Content: "ok"},
+	})
+
+	if len(msgs) != 3 {
+		t.Fatalf("expected user/assistant/user, got %+v", msgs)
+	}
+	first := msgs[0]
+	if first.Role != "user" || len(first.Content) != 2 || first.Content[0].Type != "text" || !strings.Contains(first.Content[0].Text, "已关灯") {
+		t.Fatalf("orphan tool result must become text merged into the user turn: %+v", first)
+	}
+	assistant := msgs[1]
+	types := make([]string, 0, len(assistant.Content))
+	for _, b := range assistant.Content {
+		types = append(types, b.Type)
+	}
+	if strings.Join(types, ",") != "thinking,redacted_thinking,tool_use,tool_use" || assistant.Content[0].Signature != "sig" {
+		t.Fatalf("unexpected assistant blocks: %+v", assistant.Content)
+	}
+	results := msgs[2]
+	if len(results.Content) != 2 || results.Content[0].Type != "tool_result" || results.Content[1].ToolUseID != "tu_2" {
+		t.Fatalf("tool results must share one user message: %+v", results)
+	}
+}
+
+func TestBuildClaudeMessagesFoldsSystemRowsIntoUserTurn(t *testing.T) {
+	msgs := buildClaudeMessages([]domain.Message{
+		{Role: "assistant", Content: "好的，八点提醒你吃药"},
+		{Role: "system", Name: "reminder", Content: "提醒已触发：吃药"},
+		{Role: "user", Content: "吃过了"},
+	})
+	if len(msgs) != 2 || msgs[1].Role != "user" || len(msgs[1].Content) != 2 || msgs[1].Content[0].Text != "[系统消息] 提醒已触发：吃药" {
+		t.Fatalf("system row must become marked text in the next user turn: %+v", msgs)
+	}
+}
+
+func TestBuildClaudeMessagesPutsImagesBeforeText(t *testing.T) {
+	msgs := buildClaudeMessages([]domain.Message{
+		{Role: "assistant", ToolCalls: []domain.ToolCall{{ID: "tu_1", Name: "capture_image"}}},
+		{Role: "tool", Name: "capture_image", ToolCallID: "tu_1", Content: "已拍照"},
+		{Role: "user", Content: "拍到的画面", Images: []domain.MessageImage{{Mime: "image/jpeg", Data: []byte("jpg")}}},
+	})
+	if len(msgs) != 2 || len(msgs[1].Content) != 3 {
+		t.Fatalf("image must join the tool result user turn: %+v", msgs)
+	}
+	img := msgs[1].Content[1]
+	if msgs[1].Content[0].Type != "tool_result" || img.Type != "image" || img.Source.MediaType != "image/jpeg" || img.Source.Data != "anBn" || msgs[1].Content[2].Text != "拍到的画面" {
+		t.Fatalf("unexpected blocks %+v", msgs[1].Content)
+	}
+}
+
+func TestClaudeProviderStreamsThinkingAndToolUse(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1"}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"用户想关灯"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig_abc"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"好的，"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"马上关。"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"tu_1","name":"light_off","input":{}}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"room\":"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"客厅\"}"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+		`{"type":"message_stop"}`,
+	}
+	var sent claudeRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &sent)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range events {
+			_, _ = io.WriteString(w, "event: x\ndata: "+ev+"\n\n")
+		}
+	}))
+	defer srv.Close()
+
+	p := NewClaudeProvider(srv.Client(), srv.URL, "key", ClaudeOptions{ThinkingBudget: 2048, Stream: true})
+	var text strings.Builder
+	var tools []domain.ToolCall
+	resp, err := p.Stream(context.Background(), domain.LLMRequest{
+		Model:    "claude-test",
+		Messages: []domain.Message{{Role: "user", Content: "关灯"}},
+		Tools:    []domain.LLMTool{{Name: "light_off"}},
+	}, func(d StreamDelta) {
+		text.WriteString(d.Text)
+		if d.ToolCall != nil {
+			tools = append(tools, *d.ToolCall)
+		}
+	})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+
+	if !sent.Stream || sent.Thinking == nil || sent.Thinking.BudgetTokens != 2048 || sent.MaxTokens <= 2048 {
+		t.Fatalf("unexpected request: stream=%v thinking=%+v max_tokens=%d", sent.Stream, sent.Thinking, sent.MaxTokens)
+	}
+	if text.String() != "好的，马上关。" || resp.Content != "好的，马上关。" {
+		t.Fatalf("unexpected text: deltas=%q content=%q", text.String(), resp.Content)
+	}
+	if len(tools) != 1 || len(resp.ToolCalls) != 1 || string(resp.ToolCalls[0].Arguments) != `{"room":"客厅"}` {
+		t.Fatalf("unexpected tool calls: deltas=%+v resp=%+v", tools, resp.ToolCalls)
+	}
+	if len(resp.Thinking) != 1 || resp.Thinking[0].Signature != "sig_abc" || resp.Thinking[0].Thinking != "用户想关灯" {
+		t.Fatalf("unexpected thinking: %+v", resp.Thinking)
+	}
+}
+
+func TestClaudeProviderStreamError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n")
+	}))
+	defer srv.Close()
+
+	p := NewClaudeProvider(srv.Client(), srv.URL, "key", ClaudeOptions{Stream: true})
+	if _, err := p.Complete(context.Background(), domain.LLMRequest{Messages: []domain.Message{{Role: "user", Content: "hi"}}}); err == nil || !strings.Contains(err.Error(), "Overloaded") {
+		t.Fatalf("expected overloaded error, got %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+)
+
+const (
+	DefaultEmbeddingModel     = "text-embedding-3-small"
+	DefaultEmbeddingBatchSize = 64
+	mockEmbeddingDimensions   = 64
+)
+
+// ErrEmbeddingUnsupported is returned by providers without an embeddings
+// endpoint, e.g. Anthropic.
+var ErrEmbeddingUnsupported = errors.New("llm provider does not support embeddings")
+
+// Embeddings holds one vector per input text, in input order. Dimensions is
+// the vector length, needed to size pgvector columns.
+type Embeddings struct {
+	Model      string
+	Dimensions int
+	Vectors    [][]float32
+}
+
+type openAIEmbeddingRequest struct {
+	Model          string   `json:"model"`
+	Input          []string `json:"input"`
+	Dimensions     int      `json:"dimensions,omitempty"`
+	EncodingFormat string   `json:"encoding_format"`
+}
+
+type openAIEmbeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Embed splits texts into batches of EmbeddingBatchSize and concatenates the
+// results, so callers can pass a whole memory backlog at once.
+func (p *OpenAIProvider) Embed(ctx context.Context, texts []string) (Embeddings, error) {
+	out := Embeddings{Model: p.opts.EmbeddingModel, Vectors: make([][]float32, 0, len(texts))}
+	for start := 0; start < len(texts); start += p.opts.EmbeddingBatchSize {
+		end := min(start+p.opts.EmbeddingBatchSize, len(texts))
+		batch, model, err := p.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return Embeddings{}, err
+		}
+		for _, v := range batch {
+			if out.Dimensions == 0 {
+				out.Dimensions = len(v)
+			} else if len(v) != out.Dimensions {
+				return Embeddings{}, fmt.Errorf("openai embeddings: inconsistent dimensions %d and %d", out.Dimensions, len(v))
+			}
+		}
+		if model != "" {
+			out.Model = model
+		}
+		out.Vectors = append(out.Vectors, batch...)
+	}
+	return out, nil
+}
+
+func (p *OpenAIProvider) embedBatch(ctx context.Context, texts []string) ([][]float32, string, error) {
+	buf, err := json.Marshal(openAIEmbeddingRequest{
+		Model:          p.opts.EmbeddingModel,
+		Input:          texts,
+		Dimensions:     p.opts.EmbeddingDimensions,
+		EncodingFormat: "float",
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/embeddings", bytes.NewReader(buf))
+	if err != nil {
+		return nil, "", err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, "", newAPIError("openai", resp, body)
+	}
+	var parsed openAIEmbeddingResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, "", err
+	}
+	if parsed.Error != nil {
+		return nil, "", fmt.Errorf("openai error: %s", parsed.Error.Message)
+	}
+	if len(parsed.Data) != len(texts) {
+		return nil, "", fmt.Errorf("openai embeddings: got %d vectors for %d inputs", len(parsed.Data), len(texts))
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range parsed.Data {
+		if d.Index < 0 || d.Index >= len(texts) || vectors[d.Index] != nil {
+			return nil, "", fmt.Errorf("openai embeddings: unexpected index %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, parsed.Model, nil
+}
+
+func (p *ClaudeProvider) Embed(context.Context, []string) (Embeddings, error) {
+	return Embeddings{}, ErrEmbeddingUnsupported
+}
+
+// Embed returns deterministic bag-of-bigram vectors: texts sharing characters
+// score a higher cosine similarity, which is enough for similarity tests.
+func (p *MockProvider) Embed(ctx context.Context, texts []string) (Embeddings, error) {
+	if err := ctx.Err(); err != nil {
+		return Embeddings{}, err
+	}
+	dims := p.fixture.EmbeddingDimensions
+	if dims <= 0 {
+		dims = mockEmbeddingDimensions
+	}
+	out := Embeddings{Model: "mock-embedding", Dimensions: dims, Vectors: make([][]float32, 0, len(texts))}
+	for _, text := range texts {
+		out.Vectors = append(out.Vectors, mockEmbedding(text, dims))
+	}
+	return out, nil
+}
+
+func mockEmbedding(text string, dims int) []float32 {
+	vec := make([]float32, dims)
+	runes := []rune(strings.ToLower(strings.TrimSpace(text)))
+	for i := range runes {
+		end := min(i+2, len(runes))
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(string(runes[i:end])))
+		vec[h.Sum32()%uint32(dims)]++
+	}
+	var norm float64
+	for _, v := range vec {
+		norm += float64(v * v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vec {
+			vec[i] *= scale
+		}
+	}
+	return vec
+}
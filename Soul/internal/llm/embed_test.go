@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIEmbedBatchesAndOrders(t *testing.T) {
+	var batches [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/embeddings" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		var req openAIEmbeddingRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "text-embedding-3-small" || req.Dimensions != 3 {
+			t.Fatalf("unexpected request: %+v", req)
+		}
+		batches = append(batches, req.Input)
+		// Reply in reverse to check vectors are placed by index.
+		data := make([]map[string]any, 0, len(req.Input))
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			var n float32
+			_, _ = fmt.Sscanf(req.Input[i], "t%f", &n)
+			data = append(data, map[string]any{"index": i, "embedding": []float32{n, 0, 0}})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"model": "text-embedding-3-small", "data": data})
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider(srv.Client(), srv.URL, "key", OpenAIOptions{EmbeddingDimensions: 3, EmbeddingBatchSize: 2})
+	out, err := p.Embed(context.Background(), []string{"t0", "t1", "t2", "t3", "t4"})
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if len(batches) != 3 || len(batches[2]) != 1 {
+		t.Fatalf("expected batches of 2, got %v", batches)
+	}
+	if out.Dimensions != 3 || len(out.Vectors) != 5 {
+		t.Fatalf("unexpected result: %+v", out)
+	}
+	for i, v := range out.Vectors {
+		if v[0] != float32(i) {
+			t.Fatalf("vector %d out of order: %v", i, v)
+		}
+	}
+}
+
+func TestClaudeEmbedUnsupported(t *testing.T) {
+	p := NewClaudeProvider(nil, "", "", ClaudeOptions{})
+	if _, err := p.Embed(context.Background(), []string{"x"}); !errors.Is(err, ErrEmbeddingUnsupported) {
+		t.Fatalf("expected ErrEmbeddingUnsupported, got %v", err)
+	}
+}
+
+func TestMockEmbedSimilarity(t *testing.T) {
+	p := NewMockProvider(MockFixture{EmbeddingDimensions: 32})
+	out, err := p.Embed(context.Background(), []string{"打开台灯", "把台灯打开", "今天天气怎么样"})
+	if err != nil || out.Dimensions != 32 || len(out.Vectors) != 3 {
+		t.Fatalf("unexpected result: %+v %v", out, err)
+	}
+	dot := func(a, b []float32) (s float32) {
+		for i := range a {
+			s += a[i] * b[i]
+		}
+		return s
+	}
+	if dot(out.Vectors[0], out.Vectors[1]) <= dot(out.Vectors[0], out.Vectors[2]) {
+		t.Fatalf("expected related texts to score higher")
+	}
+}
@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"soul/internal/domain"
+)
+
+// MockFixture scripts the replies of MockProvider. Rules are tried in order
+// against the last message of each request; the first match wins and Default
+// answers everything else.
+type MockFixture struct {
+	Rules   []MockRule    `json:"rules"`
+	Default *MockResponse `json:"default,omitempty"`
+	// EmbeddingDimensions sizes the mock Embed vectors, 64 when unset.
+	EmbeddingDimensions int `json:"embedding_dimensions,omitempty"`
+}
+
+type MockRule struct {
+	// Match is a substring of the last message content; empty matches any.
+	Match string `json:"match"`
+	// LastRole restricts the rule to a role of the last message, e.g. "tool"
+	// for the second pass after a tool call.
+	LastRole string `json:"last_role,omitempty"`
+	// SystemContains optionally requires a substring of the system prompt.
+	SystemContains string       `json:"system_contains,omitempty"`
+	Response       MockResponse `json:"response"`
+}
+
+type MockResponse struct {
+	Content   string         `json:"content"`
+	ToolCalls []MockToolCall `json:"tool_calls,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type MockToolCall struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type MockProvider struct {
+	fixture MockFixture
+
+	mu    sync.Mutex
+	calls []domain.LLMRequest
+}
+
+func NewMockProvider(fixture MockFixture) *MockProvider {
+	return &MockProvider{fixture: fixture}
+}
+
+func NewMockProviderFromFile(path string) (*MockProvider, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mock llm fixture: %w", err)
+	}
+	var fixture MockFixture
+	if err := json.Unmarshal(raw, &fixture); err != nil {
+		return nil, fmt.Errorf("parse mock llm fixture: %w", err)
+	}
+	return NewMockProvider(fixture), nil
+}
+
+func (p *MockProvider) Complete(ctx context.Context, req domain.LLMRequest) (domain.LLMResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.LLMResponse{}, err
+	}
+	p.mu.Lock()
+	p.calls = append(p.calls, req)
+	p.mu.Unlock()
+
+	var last domain.Message
+	if len(req.Messages) > 0 {
+		last = req.Messages[len(req.Messages)-1]
+	}
+	resp := p.fixture.Default
+	for i := range p.fixture.Rules {
+		rule := &p.fixture.Rules[i]
+		if rule.LastRole != "" && rule.LastRole != last.Role {
+			continue
+		}
+		if rule.SystemContains != "" && !strings.Contains(req.System, rule.SystemContains) {
+			continue
+		}
+		if !strings.Contains(last.Content, rule.Match) {
+			continue
+		}
+		resp = &rule.Response
+		break
+	}
+	if resp == nil {
+		return domain.LLMResponse{}, fmt.Errorf("mock llm: no fixture rule matches %q", last.Content)
+	}
+	if resp.Error != "" {
+		return domain.LLMResponse{}, fmt.Errorf("mock llm: %s", resp.Error)
+	}
+
+	out := domain.LLMResponse{Content: resp.Content}
+	for i, tc := range resp.ToolCalls {
+		id := tc.ID
+		if id == "" {
+			id = fmt.Sprintf("call_mock_%d", i+1)
+		}
+		args := tc.Arguments
+		if len(args) == 0 {
+			args = json.RawMessage(`{}`)
+		}
+		out.ToolCalls = append(out.ToolCalls, domain.ToolCall{ID: id, Name: tc.Name, Arguments: args})
+	}
+	return out, nil
+}
+
+// Calls returns the requests received so far, for assertions in tests.
+func (p *MockProvider) Calls() []domain.LLMRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]domain.LLMRequest(nil), p.calls...)
+}
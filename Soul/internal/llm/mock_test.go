@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestMockProviderReplaysFixture(t *testing.T) {
+	p, err := NewMockProviderFromFile("testdata/mock_fixture.json")
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	ctx := context.Background()
+
+	resp, err := p.Complete(ctx, domain.LLMRequest{Messages: []domain.Message{{Role: "user", Content: "帮我关灯"}}})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "light_off" || !strings.Contains(string(resp.ToolCalls[0].Arguments), "客厅") {
+		t.Fatalf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+
+	resp, err = p.Complete(ctx, domain.LLMRequest{Messages: []domain.Message{
+		{Role: "user", Content: "帮我关灯"},
+		{Role: "tool", Name: "light_off", Content: "ok"},
+	}})
+	if err != nil || resp.Content != "已经处理好了。" || len(resp.ToolCalls) != 0 {
+		t.Fatalf("unexpected second pass: %+v err=%v", resp, err)
+	}
+
+	resp, err = p.Complete(ctx, domain.LLMRequest{Messages: []domain.Message{{Role: "user", Content: "你好"}}})
+	if err != nil || resp.Content != "好的，我在。" {
+		t.Fatalf("unexpected default: %+v err=%v", resp, err)
+	}
+
+	if _, err := p.Complete(ctx, domain.LLMRequest{Messages: []domain.Message{{Role: "user", Content: "请报错"}}}); err == nil {
+		t.Fatalf("expected scripted error")
+	}
+	if got := len(p.Calls()); got != 4 {
+		t.Fatalf("expected 4 recorded calls, got %d", got)
+	}
+}
+
+func TestMockProviderWithoutDefault(t *testing.T) {
+	p := NewMockProvider(MockFixture{})
+	if _, err := p.Complete(context.Background(), domain.LLMRequest{Messages: []domain.Message{{Role: "user", Content: "x"}}}); err == nil {
+		t.Fatalf("expected error when no rule matches")
+	}
+}
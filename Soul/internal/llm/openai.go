@@ -3,6 +3,7 @@ package llm
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,17 +17,43 @@ type OpenAIProvider struct {
 	client  *http.Client
 	baseURL string
 	apiKey  string
+	opts    OpenAIOptions
 }
 
-func NewOpenAIProvider(client *http.Client, baseURL, apiKey string) *OpenAIProvider {
-	return &OpenAIProvider{client: client, baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey}
+type OpenAIOptions struct {
+	EmbeddingModel string
+	// EmbeddingDimensions shortens text-embedding-3 vectors when positive.
+	EmbeddingDimensions int
+	EmbeddingBatchSize  int
+}
+
+func NewOpenAIProvider(client *http.Client, baseURL, apiKey string, opts OpenAIOptions) *OpenAIProvider {
+	if opts.EmbeddingModel == "" {
+		opts.EmbeddingModel = DefaultEmbeddingModel
+	}
+	if opts.EmbeddingBatchSize <= 0 {
+		opts.EmbeddingBatchSize = DefaultEmbeddingBatchSize
+	}
+	return &OpenAIProvider{client: client, baseURL: strings.TrimRight(baseURL, "/"), apiKey: apiKey, opts: opts}
 }
 
 type openAIRequest struct {
-	Model      string          `json:"model"`
-	Messages   []openAIMessage `json:"messages"`
-	Tools      []openAITool    `json:"tools,omitempty"`
-	ToolChoice string          `json:"tool_choice,omitempty"`
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	Tools          []openAITool          `json:"tools,omitempty"`
+	ToolChoice     string                `json:"tool_choice,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type       string           `json:"type"`
+	JSONSchema openAIJSONSchema `json:"json_schema"`
+}
+
+type openAIJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
 }
 
 type openAIMessage struct {
@@ -35,6 +62,30 @@ type openAIMessage struct {
 	Name       string           `json:"name,omitempty"`
 	ToolCallID string           `json:"tool_call_id,omitempty"`
 	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	// Parts replaces Content with text and image parts when images are
+	// attached.
+	Parts []openAIContentPart `json:"-"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+func (m openAIMessage) MarshalJSON() ([]byte, error) {
+	type plain openAIMessage
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []openAIContentPart `json:"content"`
+	}{plain(m), m.Parts})
 }
 
 type openAITool struct {
@@ -66,6 +117,10 @@ type openAIToolCall struct {
 	} `json:"function"`
 }
 
+func (p *OpenAIProvider) warm(ctx context.Context, n int) error {
+	return warmConns(ctx, p.client, p.baseURL, n)
+}
+
 func (p *OpenAIProvider) Complete(ctx context.Context, req domain.LLMRequest) (domain.LLMResponse, error) {
 	payload := openAIRequest{
 		Model:    req.Model,
@@ -81,6 +136,17 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req domain.LLMRequest) (d
 			Name:       m.Name,
 			ToolCallID: m.ToolCallID,
 		}
+		if m.Role == "user" && len(m.Images) > 0 {
+			om.Parts = make([]openAIContentPart, 0, len(m.Images)+1)
+			if strings.TrimSpace(m.Content) != "" {
+				om.Parts = append(om.Parts, openAIContentPart{Type: "text", Text: m.Content})
+			}
+			for _, img := range m.Images {
+				om.Parts = append(om.Parts, openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{
+					URL: "data:" + img.Mime + ";base64," + base64.StdEncoding.EncodeToString(img.Data),
+				}})
+			}
+		}
 		if len(m.ToolCalls) > 0 {
 			om.ToolCalls = make([]openAIToolCall, 0, len(m.ToolCalls))
 			for _, tc := range m.ToolCalls {
@@ -107,6 +173,12 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req domain.LLMRequest) (d
 		}
 		payload.ToolChoice = "auto"
 	}
+	if req.ResponseFormat != nil {
+		payload.ResponseFormat = &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: openAIJSONSchema{Name: req.ResponseFormat.Name, Schema: normalizeSchema(req.ResponseFormat.Schema)},
+		}
+	}
 
 	buf, err := json.Marshal(payload)
 	if err != nil {
@@ -128,7 +200,7 @@ func (p *OpenAIProvider) Complete(ctx context.Context, req domain.LLMRequest) (d
 
 	body, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode >= 300 {
-		return domain.LLMResponse{}, fmt.Errorf("openai status %d: %s", resp.StatusCode, string(body))
+		return domain.LLMResponse{}, newAPIError("openai", resp, body)
 	}
 
 	var parsed openAIResponse
@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestOpenAIProviderSendsImagesAsContentParts(t *testing.T) {
+	var messages []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []map[string]any `json:"messages"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		messages = req.Messages
+		_ = json.NewEncoder(w).Encode(map[string]any{"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": "桌上有一个杯子"}}}})
+	}))
+	defer srv.Close()
+
+	p := NewOpenAIProvider(srv.Client(), srv.URL, "key", OpenAIOptions{})
+	resp, err := p.Complete(context.Background(), domain.LLMRequest{Messages: []domain.Message{
+		{Role: "user", Content: "看看桌上有什么"},
+		{Role: "user", Content: "拍到的画面", Images: []domain.MessageImage{{Mime: "image/png", Data: []byte("png")}}},
+	}})
+	if err != nil || resp.Content != "桌上有一个杯子" {
+		t.Fatalf("unexpected response %+v %v", resp, err)
+	}
+	if len(messages) != 2 || messages[0]["content"] != "看看桌上有什么" {
+		t.Fatalf("plain messages must keep string content: %+v", messages)
+	}
+	parts, ok := messages[1]["content"].([]any)
+	if !ok || len(parts) != 2 {
+		t.Fatalf("image message must send content parts: %+v", messages[1])
+	}
+	image := parts[1].(map[string]any)
+	if image["type"] != "image_url" || image["image_url"].(map[string]any)["url"] != "data:image/png;base64,cG5n" {
+		t.Fatalf("unexpected image part %+v", image)
+	}
+}
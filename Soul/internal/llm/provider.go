@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -11,6 +12,24 @@ import (
 
 type Provider interface {
 	Complete(ctx context.Context, req domain.LLMRequest) (domain.LLMResponse, error)
+	// Embed returns one vector per text with the configured embedding model,
+	// or ErrEmbeddingUnsupported.
+	Embed(ctx context.Context, texts []string) (Embeddings, error)
+}
+
+// StreamDelta is one incremental piece of a streamed completion. ToolCall is
+// set once a tool call's arguments are complete.
+type StreamDelta struct {
+	Text     string
+	Thinking string
+	ToolCall *domain.ToolCall
+}
+
+// StreamingProvider is implemented by providers that can report partial
+// output while the completion is generated.
+type StreamingProvider interface {
+	Provider
+	Stream(ctx context.Context, req domain.LLMRequest, onDelta func(StreamDelta)) (domain.LLMResponse, error)
 }
 
 type Config struct {
@@ -20,16 +39,44 @@ type Config struct {
 	OpenAIAPIKey     string
 	AnthropicBaseURL string
 	AnthropicAPIKey  string
+	// AnthropicMaxTokens caps each Claude reply, thinking included.
+	AnthropicMaxTokens int
+	// AnthropicThinkingBudget enables extended thinking when positive.
+	AnthropicThinkingBudget int
+	AnthropicStream         bool
+	MockFixturePath         string
+	// Embedding* configure Embed on the openai provider.
+	EmbeddingModel      string
+	EmbeddingDimensions int
+	EmbeddingBatchSize  int
+	// Retry applies to the HTTP providers; the mock provider never retries.
+	Retry RetryConfig
+	// Transport tunes the HTTP providers' connections.
+	Transport TransportConfig
+	// Metrics, when set, receives the HTTP providers' connection and TTFT
+	// metrics.
+	Metrics *Metrics
+	Logger  *slog.Logger
 }
 
 func NewProvider(cfg Config) (Provider, error) {
-	client := &http.Client{Timeout: 60 * time.Second}
+	client := &http.Client{Timeout: 60 * time.Second, Transport: newTransport(cfg.Transport, cfg.Metrics, cfg.Provider)}
 
 	switch cfg.Provider {
 	case "openai":
-		return NewOpenAIProvider(client, cfg.OpenAIBaseURL, cfg.OpenAIAPIKey), nil
+		return WithRetry(NewOpenAIProvider(client, cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, OpenAIOptions{
+			EmbeddingModel:      cfg.EmbeddingModel,
+			EmbeddingDimensions: cfg.EmbeddingDimensions,
+			EmbeddingBatchSize:  cfg.EmbeddingBatchSize,
+		}), cfg.Retry, cfg.Logger), nil
 	case "claude":
-		return NewClaudeProvider(client, cfg.AnthropicBaseURL, cfg.AnthropicAPIKey), nil
+		return WithRetry(NewClaudeProvider(client, cfg.AnthropicBaseURL, cfg.AnthropicAPIKey, ClaudeOptions{
+			MaxTokens:      cfg.AnthropicMaxTokens,
+			ThinkingBudget: cfg.AnthropicThinkingBudget,
+			Stream:         cfg.AnthropicStream,
+		}), cfg.Retry, cfg.Logger), nil
+	case "mock":
+		return NewMockProviderFromFile(cfg.MockFixturePath)
 	default:
 		return nil, fmt.Errorf("unsupported LLM provider: %s", cfg.Provider)
 	}
@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// APIError is a non-2xx reply from an LLM endpoint.
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	// RetryAfter is the server-requested wait, zero when not sent.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s status %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// Retryable reports whether the same request may succeed later: rate limits,
+// overload and gateway errors are transient, request and auth errors are not.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+		529: // Anthropic overloaded_error
+		return true
+	default:
+		return false
+	}
+}
+
+func newAPIError(provider string, resp *http.Response, body []byte) *APIError {
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header, time.Now()),
+	}
+}
+
+// parseRetryAfter understands OpenAI's retry-after-ms as well as the standard
+// Retry-After header in seconds or HTTP-date form.
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	if v := strings.TrimSpace(h.Get("retry-after-ms")); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if at, err := http.ParseTime(v); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+func isRetryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || strings.Contains(err.Error(), "connection reset")
+}
+
+type RetryConfig struct {
+	// MaxAttempts counts the first call; values below 2 disable retries.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+type retryProvider struct {
+	inner  Provider
+	cfg    RetryConfig
+	logger *slog.Logger
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// WithRetry wraps p so transient failures are retried with exponential
+// backoff and jitter, honoring Retry-After. A Retry-After beyond MaxDelay is
+// not waited for: the chat turn fails fast instead of hanging.
+func WithRetry(p Provider, cfg RetryConfig, logger *slog.Logger) Provider {
+	if cfg.MaxAttempts < 2 {
+		return p
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 300 * time.Millisecond
+	}
+	if cfg.MaxDelay < cfg.BaseDelay {
+		cfg.MaxDelay = cfg.BaseDelay
+	}
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &retryProvider{inner: p, cfg: cfg, logger: logger, sleep: sleepContext}
+}
+
+func (r *retryProvider) Complete(ctx context.Context, req domain.LLMRequest) (domain.LLMResponse, error) {
+	return retryCall(ctx, r, func() (domain.LLMResponse, error) {
+		return r.inner.Complete(ctx, req)
+	}, nil)
+}
+
+func (r *retryProvider) Embed(ctx context.Context, texts []string) (Embeddings, error) {
+	return retryCall(ctx, r, func() (Embeddings, error) {
+		return r.inner.Embed(ctx, texts)
+	}, nil)
+}
+
+// Stream retries only while nothing has been delivered to onDelta, so callers
+// never see duplicated partial output.
+func (r *retryProvider) Stream(ctx context.Context, req domain.LLMRequest, onDelta func(StreamDelta)) (domain.LLMResponse, error) {
+	streamer, ok := r.inner.(StreamingProvider)
+	if !ok {
+		resp, err := r.Complete(ctx, req)
+		if err == nil && onDelta != nil && resp.Content != "" {
+			onDelta(StreamDelta{Text: resp.Content})
+		}
+		return resp, err
+	}
+	delivered := false
+	forward := func(d StreamDelta) {
+		delivered = true
+		if onDelta != nil {
+			onDelta(d)
+		}
+	}
+	return retryCall(ctx, r, func() (domain.LLMResponse, error) {
+		return streamer.Stream(ctx, req, forward)
+	}, func() bool { return !delivered })
+}
+
+func retryCall[T any](ctx context.Context, r *retryProvider, call func() (T, error), canRetry func() bool) (T, error) {
+	var zero T
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		resp, err := call()
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if attempt >= r.cfg.MaxAttempts || !isRetryable(ctx, err) || (canRetry != nil && !canRetry()) {
+			return zero, lastErr
+		}
+		delay := r.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			if apiErr.RetryAfter > r.cfg.MaxDelay {
+				return zero, lastErr
+			}
+			delay = apiErr.RetryAfter
+		}
+		r.logger.Warn("llm call failed, retrying", "attempt", attempt, "delay_ms", delay.Milliseconds(), "error", err)
+		if err := r.sleep(ctx, delay); err != nil {
+			return zero, lastErr
+		}
+	}
+}
+
+// backoff is exponential with equal jitter: half the step is fixed, half is
+// random, so concurrent turns hitting a 429 spread out.
+func (r *retryProvider) backoff(attempt int) time.Duration {
+	step := r.cfg.BaseDelay << (attempt - 1)
+	if step <= 0 || step > r.cfg.MaxDelay {
+		step = r.cfg.MaxDelay
+	}
+	half := step / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func newTestRetry(p Provider, attempts int) (*retryProvider, *[]time.Duration) {
+	r := WithRetry(p, RetryConfig{MaxAttempts: attempts, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second}, nil).(*retryProvider)
+	var waits []time.Duration
+	r.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return r, &waits
+}
+
+func TestRetryHonorsRetryAfterThenSucceeds(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, `{"error":{"message":"rate limited"}}`, http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"好的"}}]}`))
+	}))
+	defer srv.Close()
+
+	r, waits := newTestRetry(NewOpenAIProvider(srv.Client(), srv.URL, "key", OpenAIOptions{}), 3)
+	resp, err := r.Complete(context.Background(), domain.LLMRequest{Messages: []domain.Message{{Role: "user", Content: "hi"}}})
+	if err != nil || resp.Content != "好的" {
+		t.Fatalf("unexpected result: %+v %v", resp, err)
+	}
+	if calls != 2 || len(*waits) != 1 || (*waits)[0] != time.Second {
+		t.Fatalf("expected one wait of Retry-After, calls=%d waits=%v", calls, *waits)
+	}
+}
+
+func TestRetryStopsOnFatalStatus(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, `{"error":{"message":"bad key"}}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	r, _ := newTestRetry(NewOpenAIProvider(srv.Client(), srv.URL, "key", OpenAIOptions{}), 3)
+	_, err := r.Complete(context.Background(), domain.LLMRequest{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || calls != 1 {
+		t.Fatalf("expected a single 401, calls=%d err=%v", calls, err)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	r, waits := newTestRetry(NewClaudeProvider(srv.Client(), srv.URL, "key", ClaudeOptions{}), 3)
+	_, err := r.Complete(context.Background(), domain.LLMRequest{})
+	if err == nil || calls != 3 || len(*waits) != 2 {
+		t.Fatalf("calls=%d waits=%v err=%v", calls, *waits, err)
+	}
+	for i, w := range *waits {
+		step := 100 * time.Millisecond << i
+		if w < step/2 || w > step {
+			t.Fatalf("wait %d = %s outside jitter range of %s", i, w, step)
+		}
+	}
+}
+
+func TestRetryDoesNotWaitBeyondMaxDelay(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("retry-after-ms", "60000")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	r, waits := newTestRetry(NewOpenAIProvider(srv.Client(), srv.URL, "key", OpenAIOptions{}), 3)
+	if _, err := r.Complete(context.Background(), domain.LLMRequest{}); err == nil || calls != 1 || len(*waits) != 0 {
+		t.Fatalf("calls=%d waits=%v err=%v", calls, *waits, err)
+	}
+}
+
+func TestRetryStreamNotRepeatedAfterDelta(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+			"data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"半句\"}}\n\n" +
+			"data: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"))
+	}))
+	defer srv.Close()
+
+	r, _ := newTestRetry(NewClaudeProvider(srv.Client(), srv.URL, "key", ClaudeOptions{}), 3)
+	var got strings.Builder
+	_, err := r.Stream(context.Background(), domain.LLMRequest{}, func(d StreamDelta) { got.WriteString(d.Text) })
+	if err == nil || calls != 1 || got.String() != "半句" {
+		t.Fatalf("calls=%d deltas=%q err=%v", calls, got.String(), err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		header, value string
+		want          time.Duration
+	}{
+		{"Retry-After", "2", 2 * time.Second},
+		{"Retry-After", now.Add(3 * time.Second).Format(http.TimeFormat), 3 * time.Second},
+		{"retry-after-ms", "250", 250 * time.Millisecond},
+		{"Retry-After", "soon", 0},
+	}
+	for _, tc := range cases {
+		h := http.Header{}
+		h.Set(tc.header, tc.value)
+		if got := parseRetryAfter(h, now); got != tc.want {
+			t.Fatalf("%s=%s: want %s, got %s", tc.header, tc.value, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// StructuredRetries is how many times CompleteJSON re-asks the model after an
+// unparsable or schema-violating reply.
+const StructuredRetries = 2
+
+// StructuredOutputError is returned when no attempt produced valid JSON.
+type StructuredOutputError struct {
+	Attempts int
+	Raw      string
+	Err      error
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("structured output invalid after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *StructuredOutputError) Unwrap() error { return e.Err }
+
+// CompleteJSON asks p for a reply matching schema and decodes it into out.
+// Providers with a native structured mode receive req.ResponseFormat; every
+// provider also gets the schema in the system prompt, and a reply that fails
+// to parse or validate is fed back to the model for another attempt.
+func CompleteJSON(ctx context.Context, p Provider, req domain.LLMRequest, schema json.RawMessage, out any) error {
+	var parsedSchema map[string]any
+	if err := json.Unmarshal(normalizeSchema(schema), &parsedSchema); err != nil {
+		return fmt.Errorf("invalid json schema: %w", err)
+	}
+	name := "structured_output"
+	if req.ResponseFormat != nil && req.ResponseFormat.Name != "" {
+		name = req.ResponseFormat.Name
+	}
+	req.ResponseFormat = &domain.LLMResponseFormat{Name: name, Schema: normalizeSchema(schema)}
+	req.System = strings.TrimSpace(req.System + "\n\n只输出一个符合以下 JSON Schema 的 JSON 值，不要输出解释或 Markdown 代码块：\n" + string(normalizeSchema(schema)))
+	req.Messages = append([]domain.Message(nil), req.Messages...)
+
+	var lastErr error
+	var raw string
+	for attempt := 1; attempt <= StructuredRetries+1; attempt++ {
+		resp, err := p.Complete(ctx, req)
+		if err != nil {
+			return err
+		}
+		raw = resp.Content
+		if lastErr = decodeStructured(raw, parsedSchema, out); lastErr == nil {
+			return nil
+		}
+		req.Messages = append(req.Messages,
+			domain.Message{Role: "assistant", Content: raw},
+			domain.Message{Role: "user", Content: fmt.Sprintf("上一次输出不符合要求：%v。请只输出符合 JSON Schema 的 JSON。", lastErr)},
+		)
+	}
+	return &StructuredOutputError{Attempts: StructuredRetries + 1, Raw: raw, Err: lastErr}
+}
+
+func decodeStructured(raw string, schema map[string]any, out any) error {
+	text := extractJSON(raw)
+	if text == "" {
+		return errors.New("reply contains no JSON")
+	}
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return fmt.Errorf("parse json: %w", err)
+	}
+	if err := validateSchema(value, schema, "$"); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(text), out); err != nil {
+		return fmt.Errorf("decode json: %w", err)
+	}
+	return nil
+}
+
+// extractJSON strips Markdown fences and surrounding prose that models add
+// despite instructions, returning the outermost object or array.
+func extractJSON(raw string) string {
+	text := strings.TrimSpace(raw)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		if i := strings.LastIndex(text, "```"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+	}
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return ""
+	}
+	closer := "}"
+	if text[start] == '[' {
+		closer = "]"
+	}
+	end := strings.LastIndex(text, closer)
+	if end <= start {
+		return ""
+	}
+	return text[start : end+1]
+}
+
+// validateSchema checks the subset of JSON Schema the prompts here use:
+// type, required, properties, items and enum.
+func validateSchema(value any, schema map[string]any, path string) error {
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+		}
+	}
+
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		if required, ok := schema["required"].([]any); ok {
+			for _, r := range required {
+				key, _ := r.(string)
+				if _, ok := obj[key]; !ok {
+					return fmt.Errorf("%s: missing required field %q", path, key)
+				}
+			}
+		}
+		if props, ok := schema["properties"].(map[string]any); ok {
+			for key, sub := range props {
+				subSchema, ok := sub.(map[string]any)
+				v, present := obj[key]
+				if !ok || !present {
+					continue
+				}
+				if err := validateSchema(v, subSchema, path+"."+key); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, v := range arr {
+				if err := validateSchema(v, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number", path)
+		}
+	case "integer":
+		f, ok := value.(float64)
+		if !ok || f != float64(int64(f)) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	}
+	return nil
+}
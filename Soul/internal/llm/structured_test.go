@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+var emotionLabelSchema = json.RawMessage(`{
+	"type": "object",
+	"required": ["emotion", "intensity"],
+	"properties": {
+		"emotion": {"type": "string", "enum": ["joy", "sadness", "neutral"]},
+		"intensity": {"type": "number"}
+	}
+}`)
+
+type emotionLabel struct {
+	Emotion   string  `json:"emotion"`
+	Intensity float64 `json:"intensity"`
+}
+
+func TestCompleteJSONRetriesInvalidReply(t *testing.T) {
+	p := NewMockProvider(MockFixture{
+		Rules: []MockRule{
+			{Match: "不符合要求", Response: MockResponse{Content: "```json\n{\"emotion\":\"joy\",\"intensity\":0.8}\n```"}},
+			{Match: "今天好开心", Response: MockResponse{Content: `{"emotion":"happy","intensity":0.8}`}},
+		},
+	})
+	var out emotionLabel
+	err := CompleteJSON(context.Background(), p, domain.LLMRequest{
+		System:   "标注情绪。",
+		Messages: []domain.Message{{Role: "user", Content: "今天好开心"}},
+	}, emotionLabelSchema, &out)
+	if err != nil {
+		t.Fatalf("complete json: %v", err)
+	}
+	if out.Emotion != "joy" || out.Intensity != 0.8 {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	calls := p.Calls()
+	if len(calls) != 2 || calls[0].ResponseFormat == nil || calls[0].ResponseFormat.Name != "structured_output" {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+	if len(calls[1].Messages) != 3 {
+		t.Fatalf("retry must carry the rejected reply and the correction, got %+v", calls[1].Messages)
+	}
+}
+
+func TestCompleteJSONGivesUp(t *testing.T) {
+	p := NewMockProvider(MockFixture{Default: &MockResponse{Content: "我觉得是开心"}})
+	var out emotionLabel
+	err := CompleteJSON(context.Background(), p, domain.LLMRequest{Messages: []domain.Message{{Role: "user", Content: "x"}}}, emotionLabelSchema, &out)
+	var structErr *StructuredOutputError
+	if !errors.As(err, &structErr) || structErr.Attempts != StructuredRetries+1 || structErr.Raw != "我觉得是开心" {
+		t.Fatalf("expected StructuredOutputError, got %v", err)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	var schema map[string]any
+	_ = json.Unmarshal([]byte(`{"type":"object","required":["items"],"properties":{"items":{"type":"array","items":{"type":"integer"}}}}`), &schema)
+	cases := []struct {
+		raw string
+		ok  bool
+	}{
+		{`{"items":[1,2]}`, true},
+		{`{"items":[1,2.5]}`, false},
+		{`{"items":"1"}`, false},
+		{`{}`, false},
+		{`[]`, false},
+	}
+	for _, tc := range cases {
+		var v any
+		_ = json.Unmarshal([]byte(tc.raw), &v)
+		if err := validateSchema(v, schema, "$"); (err == nil) != tc.ok {
+			t.Fatalf("%s: ok=%v err=%v", tc.raw, tc.ok, err)
+		}
+	}
+}
+
+func TestClaudeForcedToolBecomesContent(t *testing.T) {
+	p := NewClaudeProvider(nil, "", "", ClaudeOptions{})
+	payload := p.buildRequest(domain.LLMRequest{
+		ResponseFormat: &domain.LLMResponseFormat{Name: "label", Schema: emotionLabelSchema},
+	}, false)
+	if payload.ToolChoice == nil || payload.ToolChoice.Name != "label" || len(payload.Tools) != 1 {
+		t.Fatalf("expected forced tool, got %+v", payload)
+	}
+	resp := claudeResponseFor(payload, []claudeBlock{{Type: "tool_use", ID: "tu", Name: "label", Input: json.RawMessage(`{"emotion":"joy","intensity":1}`)}})
+	if resp.Content != `{"emotion":"joy","intensity":1}` || len(resp.ToolCalls) != 0 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	thinking := NewClaudeProvider(nil, "", "", ClaudeOptions{ThinkingBudget: 1024})
+	if payload := thinking.buildRequest(domain.LLMRequest{ResponseFormat: &domain.LLMResponseFormat{Name: "label"}}, false); payload.ToolChoice != nil {
+		t.Fatalf("forced tool choice must be skipped with thinking enabled")
+	}
+}
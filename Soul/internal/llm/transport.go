@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TransportConfig tunes the HTTP connections to the LLM endpoint. A turn
+// makes several calls in a row, so keeping connections open saves a TCP
+// and TLS handshake on each of them.
+type TransportConfig struct {
+	// MaxIdleConnsPerHost is how many idle connections are kept per host.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout closes connections idle for longer.
+	IdleConnTimeout time.Duration
+	DialTimeout     time.Duration
+	TLSTimeout      time.Duration
+	// HTTP2 negotiates HTTP/2 where the endpoint offers it; its
+	// connections are pinged while idle so a dead one is found before a
+	// turn needs it.
+	HTTP2 bool
+	// WarmConns is how many connections Warm opens at startup.
+	WarmConns int
+}
+
+// DefaultTransportConfig is used for zero fields of TransportConfig.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         5 * time.Second,
+		TLSTimeout:          5 * time.Second,
+		HTTP2:               true,
+	}
+}
+
+// newTransport returns the LLM transport, reporting to m when it is set.
+func newTransport(cfg TransportConfig, m *Metrics, provider string) http.RoundTripper {
+	def := DefaultTransportConfig()
+	if cfg.MaxIdleConnsPerHost <= 0 {
+		cfg.MaxIdleConnsPerHost = def.MaxIdleConnsPerHost
+	}
+	if cfg.IdleConnTimeout <= 0 {
+		cfg.IdleConnTimeout = def.IdleConnTimeout
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = def.DialTimeout
+	}
+	if cfg.TLSTimeout <= 0 {
+		cfg.TLSTimeout = def.TLSTimeout
+	}
+	t := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     cfg.HTTP2,
+		MaxIdleConns:          cfg.MaxIdleConnsPerHost * 2,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	if cfg.HTTP2 {
+		t.HTTP2 = &http.HTTP2Config{SendPingTimeout: 30 * time.Second, PingTimeout: 10 * time.Second}
+	} else {
+		// A non-nil empty map turns HTTP/2 off.
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if m == nil {
+		return t
+	}
+	return &tracedTransport{base: t, metrics: m, provider: provider}
+}
+
+// Metrics are the LLM connection and latency metrics.
+type Metrics struct {
+	ttft    *prometheus.HistogramVec
+	connect *prometheus.HistogramVec
+	conns   *prometheus.CounterVec
+}
+
+// NewMetrics registers the LLM metrics on reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		ttft: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "llm_ttft_seconds",
+			Help:    "Time from sending an LLM request to the first response byte (the first token when streaming), by provider and whether the connection was new or reused.",
+			Buckets: []float64{0.1, 0.2, 0.3, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10, 20, 40},
+		}, []string{"provider", "conn"}),
+		connect: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "llm_connect_seconds",
+			Help:    "Time spent opening a new LLM connection: DNS, TCP and TLS.",
+			Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.2, 0.3, 0.5, 1, 2, 5},
+		}, []string{"provider"}),
+		conns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "llm_requests_total",
+			Help: "LLM HTTP requests by provider and whether the connection was new or reused.",
+		}, []string{"provider", "conn"}),
+	}
+	reg.MustRegister(m.ttft, m.connect, m.conns)
+	return m
+}
+
+// tracedTransport times each request's connection and first byte.
+type tracedTransport struct {
+	base     http.RoundTripper
+	metrics  *Metrics
+	provider string
+}
+
+func (t *tracedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var (
+		mu         sync.Mutex
+		conn       = "new"
+		getConnAt  time.Time
+		connectDur time.Duration
+		firstByte  time.Duration
+	)
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			mu.Lock()
+			getConnAt = time.Now()
+			mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if info.Reused {
+				conn = "reused"
+			} else if !getConnAt.IsZero() {
+				connectDur = time.Since(getConnAt)
+			}
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			firstByte = time.Since(start)
+			mu.Unlock()
+		},
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	mu.Lock()
+	defer mu.Unlock()
+	t.metrics.conns.WithLabelValues(t.provider, conn).Inc()
+	if conn == "new" && connectDur > 0 {
+		t.metrics.connect.WithLabelValues(t.provider).Observe(connectDur.Seconds())
+	}
+	if err == nil && firstByte > 0 {
+		t.metrics.ttft.WithLabelValues(t.provider, conn).Observe(firstByte.Seconds())
+	}
+	return resp, err
+}
+
+// warmer is implemented by providers that talk to an HTTP endpoint.
+type warmer interface {
+	warm(ctx context.Context, n int) error
+}
+
+// Warm opens up to n connections to p's endpoint so the first turns do
+// not pay for the handshakes. Providers without an endpoint are left
+// alone.
+func Warm(ctx context.Context, p Provider, n int) error {
+	if r, ok := p.(*retryProvider); ok {
+		p = r.inner
+	}
+	w, ok := p.(warmer)
+	if !ok || n <= 0 {
+		return nil
+	}
+	return w.warm(ctx, n)
+}
+
+// warmConns sends n concurrent HEAD requests to url. Any response means a
+// connection is open and back in the pool; the status does not matter.
+func warmConns(ctx context.Context, client *http.Client, url string, n int) error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
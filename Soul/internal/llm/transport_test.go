@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"soul/internal/domain"
+)
+
+func TestTransportReusesWarmedConnections(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"好的"}}]}`))
+	}))
+	defer srv.Close()
+
+	m := NewMetrics(prometheus.NewRegistry())
+	p, err := NewProvider(Config{Provider: "openai", OpenAIBaseURL: srv.URL, Metrics: m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Warm(context.Background(), p, 1); err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	for range 2 {
+		if _, err := p.Complete(context.Background(), domain.LLMRequest{Model: "m", Messages: []domain.Message{{Role: "user", Content: "hi"}}}); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+	}
+	if got := testutil.ToFloat64(m.conns.WithLabelValues("openai", "new")); got != 1 {
+		t.Fatalf("new connections = %v, want only the warm-up's", got)
+	}
+	if got := testutil.ToFloat64(m.conns.WithLabelValues("openai", "reused")); got != 2 {
+		t.Fatalf("reused connections = %v, want 2", got)
+	}
+	if got := testutil.CollectAndCount(m.ttft); got != 2 {
+		t.Fatalf("ttft series = %d, want new and reused", got)
+	}
+}
+
+func TestWarmSkipsProvidersWithoutAnEndpoint(t *testing.T) {
+	if err := Warm(context.Background(), NewMockProvider(MockFixture{}), 2); err != nil {
+		t.Fatalf("mock warm: %v", err)
+	}
+}
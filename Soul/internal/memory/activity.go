@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+// ErrActivityCursor means a cursor was not one ListUserActivity handed out.
+var ErrActivityCursor = errors.New("invalid activity cursor")
+
+// ListUserActivity returns one page of what the robot did for a user:
+// skill invocations, intent actions, delivered reminders and mood
+// check-ins, newest first.
+func (s *Service) ListUserActivity(ctx context.Context, userID string, filter domain.ActivityFilter) (domain.ActivityPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 20
+	}
+	var after *db.ActivityCursor
+	if filter.Cursor != "" {
+		c, err := decodeActivityCursor(filter.Cursor)
+		if err != nil {
+			return domain.ActivityPage{}, err
+		}
+		after = &c
+	}
+	// One extra row tells whether another page follows.
+	items, err := s.store.ListUserActivity(ctx, userID, filter.Since, after, filter.Limit+1)
+	if err != nil {
+		return domain.ActivityPage{}, err
+	}
+	page := domain.ActivityPage{UserID: userID, Items: items}
+	if len(items) > filter.Limit {
+		page.Items = items[:filter.Limit]
+		next, err := activityCursorOf(page.Items[filter.Limit-1])
+		if err != nil {
+			return domain.ActivityPage{}, err
+		}
+		page.NextCursor = encodeActivityCursor(next)
+	}
+	return page, nil
+}
+
+func activityCursorOf(item domain.ActivityItem) (db.ActivityCursor, error) {
+	at, err := time.Parse(time.RFC3339Nano, item.At)
+	if err != nil {
+		return db.ActivityCursor{}, err
+	}
+	return db.ActivityCursor{At: at, Kind: item.Kind, ID: item.ID}, nil
+}
+
+// encodeActivityCursor packs a position as "<unix micros>.<kind>.<id>";
+// Postgres keeps microseconds, so the round trip is exact.
+func encodeActivityCursor(c db.ActivityCursor) string {
+	raw := strconv.FormatInt(c.At.UnixMicro(), 10) + "." + c.Kind + "." + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeActivityCursor(s string) (db.ActivityCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return db.ActivityCursor{}, ErrActivityCursor
+	}
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 3 {
+		return db.ActivityCursor{}, ErrActivityCursor
+	}
+	micros, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return db.ActivityCursor{}, ErrActivityCursor
+	}
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return db.ActivityCursor{}, ErrActivityCursor
+	}
+	switch parts[1] {
+	case domain.ActivityKindSkill, domain.ActivityKindIntent, domain.ActivityKindReminder, domain.ActivityKindCheckIn:
+	default:
+		return db.ActivityCursor{}, ErrActivityCursor
+	}
+	return db.ActivityCursor{At: time.UnixMicro(micros).UTC(), Kind: parts[1], ID: id}, nil
+}
@@ -0,0 +1,32 @@
+package memory
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestActivityCursorRoundTrip(t *testing.T) {
+	item := domain.ActivityItem{Kind: domain.ActivityKindCheckIn, ID: 42, At: "2026-10-16T08:30:01.123456Z"}
+	c, err := activityCursorOf(item)
+	if err != nil {
+		t.Fatalf("cursor of item: %v", err)
+	}
+	got, err := decodeActivityCursor(encodeActivityCursor(c))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !got.At.Equal(c.At) || got.Kind != c.Kind || got.ID != c.ID {
+		t.Fatalf("round trip changed the cursor: %+v != %+v", got, c)
+	}
+}
+
+func TestDecodeActivityCursorRejectsGarbage(t *testing.T) {
+	for _, s := range []string{"%%%", "MTIz", base64.RawURLEncoding.EncodeToString([]byte("1.chat.2")), base64.RawURLEncoding.EncodeToString([]byte("x.skill.2"))} {
+		if _, err := decodeActivityCursor(s); !errors.Is(err, ErrActivityCursor) {
+			t.Fatalf("cursor %q: expected ErrActivityCursor, got %v", s, err)
+		}
+	}
+}
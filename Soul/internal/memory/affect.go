@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// A user turn counts as negative when its pleasure is below
+// negativeValence at no less than negativeIntensity.
+const (
+	negativeValence   = -0.2
+	negativeIntensity = 0.3
+)
+
+// SessionAffect returns a session's per-turn affect and its summary. A
+// session without recorded turns has an empty summary.
+func (s *Service) SessionAffect(ctx context.Context, sessionID string) (domain.SessionAffect, error) {
+	turns, err := s.store.ListSessionAffect(ctx, sessionID)
+	if err != nil {
+		return domain.SessionAffect{}, err
+	}
+	return domain.SessionAffect{
+		SessionID: sessionID,
+		Summary:   summarizeAffect(turns),
+		Turns:     turns,
+	}, nil
+}
+
+// NegativeTurn reports whether the user's emotion in a turn was clearly
+// negative.
+func NegativeTurn(t domain.TurnAffect) bool {
+	return t.UserEmotion.P < negativeValence && t.UserEmotion.Intensity >= negativeIntensity
+}
+
+func summarizeAffect(turns []domain.TurnAffect) domain.SessionAffectSummary {
+	sum := domain.SessionAffectSummary{Turns: len(turns), UserEmotions: map[string]int{}}
+	if len(turns) == 0 {
+		return sum
+	}
+	var valence, weight float64
+	sum.SoulValenceStart = turns[0].SoulP
+	sum.SoulValenceEnd = turns[len(turns)-1].SoulP
+	sum.SoulValenceMin = turns[0].SoulP
+	for _, t := range turns {
+		label := strings.ToLower(strings.TrimSpace(t.UserEmotion.Emotion))
+		if label == "" {
+			label = "neutral"
+		}
+		sum.UserEmotions[label]++
+		valence += t.UserEmotion.P * t.UserEmotion.Intensity
+		weight += t.UserEmotion.Intensity
+		if NegativeTurn(t) {
+			sum.NegativeTurns++
+		}
+		sum.SoulValenceMin = min(sum.SoulValenceMin, t.SoulP)
+		if strings.TrimSpace(t.ExecMode) == "blocked" {
+			sum.BlockedTurns++
+		}
+		if t.GateOverride {
+			sum.GateOverrides++
+		}
+	}
+	if weight > 0 {
+		sum.UserValenceAvg = valence / weight
+	}
+	sum.Health = affectHealth(sum)
+	return sum
+}
+
+// affectHealth grades a session: tense when a fifth of the turns hit a
+// locked gate or half were negative, strained when the gate locked at all,
+// a quarter of the turns were negative or the soul's mood fell noticeably.
+func affectHealth(sum domain.SessionAffectSummary) string {
+	turns := float64(sum.Turns)
+	blocked := float64(sum.BlockedTurns) / turns
+	negative := float64(sum.NegativeTurns) / turns
+	switch {
+	case blocked >= 0.2 || negative >= 0.5:
+		return "tense"
+	case sum.BlockedTurns > 0 || negative >= 0.25 || sum.SoulValenceEnd < sum.SoulValenceStart-0.2:
+		return "strained"
+	default:
+		return "good"
+	}
+}
@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestSummarizeAffect(t *testing.T) {
+	if sum := summarizeAffect(nil); sum.Turns != 0 || sum.Health != "" {
+		t.Fatalf("empty session must have an empty summary: %+v", sum)
+	}
+
+	calm := domain.TurnAffect{UserEmotion: domain.EmotionSignal{Emotion: "joy", P: 0.6, Intensity: 0.5}, SoulP: 0.3, ExecMode: "auto_execute"}
+	sum := summarizeAffect([]domain.TurnAffect{calm, calm, calm, calm})
+	if sum.Health != "good" || sum.UserEmotions["joy"] != 4 || sum.UserValenceAvg != 0.6 {
+		t.Fatalf("unexpected calm summary: %+v", sum)
+	}
+
+	angry := domain.TurnAffect{UserEmotion: domain.EmotionSignal{Emotion: "Anger", P: -0.8, Intensity: 0.9}, SoulP: -0.5, ExecMode: "blocked"}
+	overridden := angry
+	overridden.ExecMode, overridden.GateOverride = "auto_execute", true
+	sum = summarizeAffect([]domain.TurnAffect{calm, calm, calm, angry, overridden})
+	if sum.NegativeTurns != 2 || sum.BlockedTurns != 1 || sum.GateOverrides != 1 || sum.UserEmotions["anger"] != 2 {
+		t.Fatalf("unexpected counts: %+v", sum)
+	}
+	if sum.SoulValenceStart != 0.3 || sum.SoulValenceEnd != -0.5 || sum.SoulValenceMin != -0.5 {
+		t.Fatalf("unexpected soul trajectory: %+v", sum)
+	}
+	if sum.Health != "tense" {
+		t.Fatalf("health = %q, want tense", sum.Health)
+	}
+	upset := angry
+	upset.ExecMode = "auto_execute"
+	if got := summarizeAffect([]domain.TurnAffect{calm, calm, calm, calm, upset}).Health; got != "strained" {
+		t.Fatalf("health = %q, want strained", got)
+	}
+}
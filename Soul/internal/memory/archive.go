@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	sessionArchiveScanInterval = time.Hour
+	sessionArchiveBatchSize    = 50
+)
+
+// RunSessionArchiveWorker archives sessions idle for longer than the
+// retention period, keeping the messages table to recent conversations.
+func (s *Service) RunSessionArchiveWorker(ctx context.Context) {
+	ticker := time.NewTicker(sessionArchiveScanInterval)
+	defer ticker.Stop()
+
+	for {
+		s.archiveExpiredSessions(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// archiveExpiredSessions compacts each expired session's remaining messages
+// into its summary, stores that as the session's episode if it has none
+// yet, then moves the raw messages out. A session whose compaction fails
+// keeps its messages until a later scan.
+func (s *Service) archiveExpiredSessions(ctx context.Context, now time.Time) {
+	if s.sessionRetention <= 0 {
+		return
+	}
+	items, err := s.store.ListExpiredSessions(ctx, now.Add(-s.sessionRetention), sessionArchiveBatchSize)
+	if err != nil {
+		s.logger.Warn("list expired sessions failed", "error", err)
+		return
+	}
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return
+		}
+		summary, _, err := s.MaybeCompressSession(ctx, item.SessionID, item.UserID, item.TerminalID, item.SoulID, true)
+		if err != nil {
+			s.logger.Warn("final session compaction failed", "session_id", item.SessionID, "error", err)
+			continue
+		}
+		if summary != "" && !item.Forked && !item.Private {
+			if stored, err := s.store.HasSessionEpisode(ctx, item.SessionID); err != nil {
+				s.logger.Warn("check session episode failed", "session_id", item.SessionID, "error", err)
+				continue
+			} else if !stored {
+				s.storeEpisode(ctx, item, summary, "session_archive")
+			}
+		}
+		moved, err := s.store.ArchiveSessionMessages(ctx, item.SessionID, s.sessionArchiveDiscard)
+		if err != nil {
+			s.logger.Warn("archive session messages failed", "session_id", item.SessionID, "error", err)
+			continue
+		}
+		s.contextCache.invalidateSession(item.SessionID)
+		s.logger.Info("session archived", "session_id", item.SessionID, "messages", moved, "discarded", s.sessionArchiveDiscard)
+	}
+}
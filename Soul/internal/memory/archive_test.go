@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestArchiveExpiredSessionsDisabledWithoutRetention(t *testing.T) {
+	// No store is set: with retention off the worker must not touch it.
+	svc := &Service{}
+	svc.archiveExpiredSessions(context.Background(), time.Now())
+}
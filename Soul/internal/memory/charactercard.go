@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// ErrInvalidCharacterCard wraps the reasons a character card is rejected.
+var ErrInvalidCharacterCard = errors.New("invalid character card")
+
+// Character cards go into every system prompt, so they are kept short.
+const (
+	maxCardBackgroundRunes = 1000
+	maxCardStyleRunes      = 300
+	maxCardTabooTopics     = 20
+	maxCardTabooRunes      = 30
+)
+
+// SetSoulCharacterCard validates and stores the soul's character card; an
+// empty card clears it.
+func (s *Service) SetSoulCharacterCard(ctx context.Context, soulID string, card domain.SoulCharacterCard) (domain.SoulProfile, error) {
+	card, err := normalizeCharacterCard(card)
+	if err != nil {
+		return domain.SoulProfile{}, err
+	}
+	profile, err := s.store.SetSoulCharacterCard(ctx, soulID, card)
+	if err != nil {
+		return domain.SoulProfile{}, err
+	}
+	s.contextCache.invalidateSoul(soulID)
+	return profile, nil
+}
+
+func normalizeCharacterCard(card domain.SoulCharacterCard) (domain.SoulCharacterCard, error) {
+	card.Background = strings.TrimSpace(card.Background)
+	card.SpeakingStyle = strings.TrimSpace(card.SpeakingStyle)
+	if n := len([]rune(card.Background)); n > maxCardBackgroundRunes {
+		return card, fmt.Errorf("%w: background is %d characters, at most %d", ErrInvalidCharacterCard, n, maxCardBackgroundRunes)
+	}
+	if n := len([]rune(card.SpeakingStyle)); n > maxCardStyleRunes {
+		return card, fmt.Errorf("%w: speaking_style is %d characters, at most %d", ErrInvalidCharacterCard, n, maxCardStyleRunes)
+	}
+	topics := make([]string, 0, len(card.TabooTopics))
+	seen := make(map[string]struct{}, len(card.TabooTopics))
+	for _, topic := range card.TabooTopics {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			continue
+		}
+		if _, ok := seen[topic]; ok {
+			continue
+		}
+		if len([]rune(topic)) > maxCardTabooRunes {
+			return card, fmt.Errorf("%w: taboo topic %q is longer than %d characters", ErrInvalidCharacterCard, topic, maxCardTabooRunes)
+		}
+		seen[topic] = struct{}{}
+		topics = append(topics, topic)
+	}
+	if len(topics) > maxCardTabooTopics {
+		return card, fmt.Errorf("%w: at most %d taboo topics", ErrInvalidCharacterCard, maxCardTabooTopics)
+	}
+	card.TabooTopics = topics
+	return card, nil
+}
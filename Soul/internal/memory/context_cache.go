@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// contextCache keeps the soul profile and compressed summary used by
+// BuildContext per session. Summaries are dropped when the session is
+// compacted or a new episode is written; emotion updates are written through.
+type contextCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]contextCacheEntry
+}
+
+type contextCacheEntry struct {
+	soulID   string
+	profile  domain.SoulProfile
+	summary  string
+	loadedAt time.Time
+}
+
+func newContextCache(ttl time.Duration) *contextCache {
+	return &contextCache{ttl: ttl, entries: make(map[string]contextCacheEntry)}
+}
+
+func (c *contextCache) enabled() bool {
+	return c != nil && c.ttl > 0
+}
+
+func (c *contextCache) get(sessionID, soulID string, now time.Time) (contextCacheEntry, bool) {
+	if !c.enabled() {
+		return contextCacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[sessionID]
+	if !ok {
+		return contextCacheEntry{}, false
+	}
+	if entry.soulID != soulID || now.Sub(entry.loadedAt) >= c.ttl {
+		delete(c.entries, sessionID)
+		return contextCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *contextCache) put(sessionID string, entry contextCacheEntry) {
+	if !c.enabled() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, existing := range c.entries {
+		if entry.loadedAt.Sub(existing.loadedAt) >= c.ttl {
+			delete(c.entries, id)
+		}
+	}
+	c.entries[sessionID] = entry
+}
+
+func (c *contextCache) invalidateSession(sessionID string) {
+	if !c.enabled() {
+		return
+	}
+	c.mu.Lock()
+	delete(c.entries, sessionID)
+	c.mu.Unlock()
+}
+
+func (c *contextCache) invalidateSoul(soulID string) {
+	if !c.enabled() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, entry := range c.entries {
+		if entry.soulID == soulID {
+			delete(c.entries, id)
+		}
+	}
+}
+
+func (c *contextCache) clear() {
+	if !c.enabled() {
+		return
+	}
+	c.mu.Lock()
+	c.entries = make(map[string]contextCacheEntry)
+	c.mu.Unlock()
+}
+
+func (c *contextCache) updateSoulEmotion(soulID string, state domain.SoulEmotionState) {
+	if !c.enabled() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, entry := range c.entries {
+		if entry.soulID != soulID {
+			continue
+		}
+		entry.profile.EmotionState = state
+		c.entries[id] = entry
+	}
+}
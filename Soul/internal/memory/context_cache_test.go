@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestContextCacheInvalidationAndEmotionWriteThrough(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newContextCache(time.Minute)
+	cache.put("s1", contextCacheEntry{soulID: "soul_a", summary: "旧摘要", loadedAt: now})
+
+	if _, ok := cache.get("s1", "soul_b", now); ok {
+		t.Fatalf("entry for a different soul must miss")
+	}
+	cache.put("s1", contextCacheEntry{soulID: "soul_a", summary: "旧摘要", loadedAt: now})
+
+	cache.updateSoulEmotion("soul_a", domain.SoulEmotionState{P: 0.4})
+	entry, ok := cache.get("s1", "soul_a", now.Add(time.Second))
+	if !ok || entry.profile.EmotionState.P != 0.4 {
+		t.Fatalf("expected emotion write-through, got ok=%v entry=%+v", ok, entry)
+	}
+
+	cache.invalidateSession("s1")
+	if _, ok := cache.get("s1", "soul_a", now); ok {
+		t.Fatalf("invalidated entry must miss")
+	}
+
+	cache.put("s2", contextCacheEntry{soulID: "soul_a", loadedAt: now})
+	if _, ok := cache.get("s2", "soul_a", now.Add(time.Minute)); ok {
+		t.Fatalf("expired entry must miss")
+	}
+}
+
+func TestContextCacheDisabled(t *testing.T) {
+	cache := newContextCache(0)
+	cache.put("s1", contextCacheEntry{soulID: "soul_a", loadedAt: time.Now()})
+	if _, ok := cache.get("s1", "soul_a", time.Now()); ok {
+		t.Fatalf("disabled cache must never hit")
+	}
+}
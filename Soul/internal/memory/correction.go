@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+var (
+	// ErrCorrectionTarget means a correction named no memory to fix.
+	ErrCorrectionTarget = errors.New("original, memory_id or episode_id is required")
+	// ErrMemoryNotFound means no memory of the user matched the correction.
+	ErrMemoryNotFound = errors.New("memory to correct not found")
+	// ErrMem0Correction means mem0 could not apply the correction; nothing
+	// was recorded locally and it can be retried.
+	ErrMem0Correction = errors.New("mem0 correction failed")
+)
+
+// CorrectMemory fixes something the robot remembered wrongly. The memory is
+// picked by MemoryID, else EpisodeID, else the mem0 memory closest to
+// Original. mem0 is updated first (the memory is overwritten with
+// Correction, or deleted when Correction is empty), then the correction is
+// recorded and the episode it came from is marked.
+func (s *Service) CorrectMemory(ctx context.Context, in domain.MemoryCorrection) (domain.MemoryCorrection, error) {
+	in.UserID = strings.TrimSpace(in.UserID)
+	in.MemoryID = strings.TrimSpace(in.MemoryID)
+	in.Original = strings.TrimSpace(in.Original)
+	in.Correction = strings.TrimSpace(in.Correction)
+	if in.UserID == "" {
+		return domain.MemoryCorrection{}, fmt.Errorf("user_id is required")
+	}
+	if in.Source == "" {
+		in.Source = domain.MemoryCorrectionSourceAPI
+	}
+
+	switch {
+	case in.MemoryID != "":
+		if s.mem0Client == nil {
+			return domain.MemoryCorrection{}, fmt.Errorf("%w: mem0 is not configured", ErrMem0Correction)
+		}
+		item, owner, err := s.mem0Client.Get(ctx, in.MemoryID)
+		if err != nil {
+			return domain.MemoryCorrection{}, fmt.Errorf("%w: %v", ErrMem0Correction, err)
+		}
+		if owner != in.UserID {
+			return domain.MemoryCorrection{}, ErrMemoryNotFound
+		}
+		in.Original = item.Content
+		in.SessionID = item.SessionID
+		s.linkEpisode(ctx, &in)
+	case in.EpisodeID > 0:
+	case in.Original != "":
+		if s.mem0Client == nil {
+			return domain.MemoryCorrection{}, fmt.Errorf("%w: mem0 is not configured", ErrMem0Correction)
+		}
+		found, err := s.RecallFromMem0(ctx, in.Original, ExternalMemoryFilter{UserID: in.UserID, SoulID: in.SoulID}, 1)
+		if err != nil {
+			return domain.MemoryCorrection{}, fmt.Errorf("%w: %v", ErrMem0Correction, err)
+		}
+		if len(found) == 0 {
+			return domain.MemoryCorrection{}, ErrMemoryNotFound
+		}
+		in.MemoryID, in.EpisodeID, in.SessionID, in.Original = found[0].MemoryID, found[0].EpisodeID, found[0].SessionID, found[0].Content
+	default:
+		return domain.MemoryCorrection{}, ErrCorrectionTarget
+	}
+
+	if in.MemoryID != "" {
+		var err error
+		if in.Correction == "" {
+			err = s.mem0Client.Delete(ctx, in.MemoryID)
+		} else {
+			err = s.mem0Client.Update(ctx, in.MemoryID, s.redactor.Mask(in.Correction))
+		}
+		if err != nil {
+			return domain.MemoryCorrection{}, fmt.Errorf("%w: %v", ErrMem0Correction, err)
+		}
+	}
+	out, err := s.store.InsertMemoryCorrection(ctx, in)
+	if err != nil {
+		return domain.MemoryCorrection{}, err
+	}
+	s.logger.Info("memory corrected", "user_id", out.UserID, "correction_id", out.ID, "memory_id", out.MemoryID, "episode_id", out.EpisodeID, "source", out.Source)
+	return out, nil
+}
+
+// linkEpisode points a correction at the episode of its session, when the
+// caller gave none.
+func (s *Service) linkEpisode(ctx context.Context, in *domain.MemoryCorrection) {
+	if in.EpisodeID > 0 || in.SessionID == "" {
+		return
+	}
+	episodes, err := s.store.LatestSessionEpisodes(ctx, in.UserID, []string{in.SessionID})
+	if err != nil {
+		s.logger.Warn("correction episode lookup failed", "user_id", in.UserID, "session_id", in.SessionID, "error", err)
+		return
+	}
+	if ep, ok := episodes[in.SessionID]; ok {
+		in.EpisodeID = ep.ID
+	}
+}
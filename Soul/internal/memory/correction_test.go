@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestCorrectMemoryNeedsTarget(t *testing.T) {
+	svc := &Service{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	_, err := svc.CorrectMemory(context.Background(), domain.MemoryCorrection{UserID: "u1", Correction: "不喝咖啡"})
+	if !errors.Is(err, ErrCorrectionTarget) {
+		t.Fatalf("expected ErrCorrectionTarget, got %v", err)
+	}
+}
+
+func TestCorrectMemoryRejectsOtherUsersMemory(t *testing.T) {
+	changed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			changed = true
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": "m1", "memory": "喜欢喝咖啡", "user_id": "u2"})
+	}))
+	defer srv.Close()
+
+	svc := &Service{mem0Client: NewMem0Client(srv.URL, "", time.Second), logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	_, err := svc.CorrectMemory(context.Background(), domain.MemoryCorrection{UserID: "u1", MemoryID: "m1", Correction: "不喝咖啡"})
+	if !errors.Is(err, ErrMemoryNotFound) {
+		t.Fatalf("expected ErrMemoryNotFound, got %v", err)
+	}
+	if changed {
+		t.Fatal("another user's memory must not be changed")
+	}
+}
@@ -0,0 +1,198 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// ErrInvalidDiaryDay is returned for a day that is not YYYY-MM-DD.
+var ErrInvalidDiaryDay = errors.New("invalid diary day")
+
+const (
+	diaryScanInterval = 10 * time.Minute
+	diaryBatchSize    = 20
+	maxDiaryRunes     = 400
+)
+
+// RunDiaryWorker writes each soul's diary for the day once the local hour
+// reaches the configured diary hour. Before that hour it catches up on
+// yesterday, so a night the server was down still gets its entries.
+func (s *Service) RunDiaryWorker(ctx context.Context) {
+	ticker := time.NewTicker(diaryScanInterval)
+	defer ticker.Stop()
+
+	for {
+		s.writeDueDiaries(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// diaryDay is the local day whose diary is due at now.
+func diaryDay(now time.Time, hour int) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if now.Hour() < hour {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+func (s *Service) writeDueDiaries(ctx context.Context, now time.Time) {
+	from := diaryDay(now.Local(), s.diaryHour)
+	to := from.AddDate(0, 0, 1)
+	day := from.Format(time.DateOnly)
+	soulIDs, err := s.store.ListDiaryDueSouls(ctx, day, from, to, diaryBatchSize)
+	if err != nil {
+		s.logger.Warn("list diary souls failed", "day", day, "error", err)
+		return
+	}
+	for _, soulID := range soulIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.writeDiary(ctx, soulID, from, to); err != nil {
+			s.logger.Warn("write soul diary failed", "soul_id", soulID, "day", day, "error", err)
+		}
+	}
+}
+
+// writeDiary asks the LLM for the soul's diary of [from, to). A day whose
+// sessions have not been summarised yet is left for a later scan.
+func (s *Service) writeDiary(ctx context.Context, soulID string, from, to time.Time) error {
+	episodes, err := s.store.ListSoulEpisodes(ctx, soulID, from, to)
+	if err != nil {
+		return err
+	}
+	if len(episodes) == 0 {
+		return nil
+	}
+	affect, err := s.store.ListSoulAffect(ctx, soulID, from, to)
+	if err != nil {
+		return err
+	}
+	profile, err := s.GetSoulProfileByID(ctx, soulID)
+	if err != nil {
+		return err
+	}
+	system, prompt := buildDiaryPrompt(profile, from, episodes, summarizeAffect(affect))
+	resp, err := s.llmProvider.Complete(ctx, domain.LLMRequest{
+		Model:    s.llmModel,
+		System:   system,
+		Messages: []domain.Message{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return err
+	}
+	content := strings.TrimSpace(resp.Content)
+	if content == "" {
+		return fmt.Errorf("empty diary from llm")
+	}
+	if runes := []rune(content); len(runes) > maxDiaryRunes {
+		content = string(runes[:maxDiaryRunes])
+	}
+	day := from.Format(time.DateOnly)
+	if _, err := s.store.InsertSoulDiaryEntry(ctx, domain.SoulDiaryEntry{SoulID: soulID, Day: day, Content: content}); err != nil {
+		return err
+	}
+	s.logger.Info("soul diary written", "soul_id", soulID, "day", day)
+	return nil
+}
+
+func buildDiaryPrompt(profile domain.SoulProfile, day time.Time, episodes []string, mood domain.SessionAffectSummary) (string, string) {
+	var sys strings.Builder
+	name := strings.TrimSpace(profile.Name)
+	if name == "" {
+		name = "桌面机器人"
+	}
+	sys.WriteString(fmt.Sprintf("你是桌面机器人「%s」，现在以第一人称给自己写今天的日记。\n", name))
+	if card := profile.CharacterCard; card != nil {
+		if strings.TrimSpace(card.Background) != "" {
+			sys.WriteString("你的背景：" + strings.TrimSpace(card.Background) + "\n")
+		}
+		if strings.TrimSpace(card.SpeakingStyle) != "" {
+			sys.WriteString("你的说话风格：" + strings.TrimSpace(card.SpeakingStyle) + "\n")
+		}
+	}
+	sys.WriteString("要求：200字以内，口语化、温暖，可以写下自己的心情变化；只写对话摘要里真实发生的事，不要编造；不要写手机号、地址等个人信息；不要标题、日期和条目编号，日记会被朗读给家人听。")
+
+	var prompt strings.Builder
+	prompt.WriteString(fmt.Sprintf("日期：%s\n\n今天的对话摘要：\n", day.Format("2006年1月2日")))
+	for _, e := range episodes {
+		if e = strings.TrimSpace(e); e != "" {
+			prompt.WriteString("- " + e + "\n")
+		}
+	}
+	if mood.Turns > 0 {
+		prompt.WriteString(fmt.Sprintf("\n今天的情绪：共 %d 轮对话", mood.Turns))
+		if labels := topEmotions(mood.UserEmotions, 3); labels != "" {
+			prompt.WriteString("，对方的情绪多为 " + labels)
+		}
+		prompt.WriteString(fmt.Sprintf("；我的心情从「%s」到「%s」", moodWord(mood.SoulValenceStart), moodWord(mood.SoulValenceEnd)))
+		if mood.SoulValenceMin < mood.SoulValenceStart && mood.SoulValenceMin < mood.SoulValenceEnd {
+			prompt.WriteString(fmt.Sprintf("，最低时「%s」", moodWord(mood.SoulValenceMin)))
+		}
+		if mood.BlockedTurns > 0 {
+			prompt.WriteString(fmt.Sprintf("；有 %d 轮我情绪太激动，没有照做对方的指令", mood.BlockedTurns))
+		}
+		prompt.WriteString("。\n")
+	}
+	prompt.WriteString("\n请写今天的日记。")
+	return sys.String(), prompt.String()
+}
+
+// topEmotions lists the n most frequent labels, most frequent first.
+func topEmotions(counts map[string]int, n int) string {
+	labels := make([]string, 0, len(counts))
+	for label := range counts {
+		if label != "neutral" {
+			labels = append(labels, label)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if counts[labels[i]] != counts[labels[j]] {
+			return counts[labels[i]] > counts[labels[j]]
+		}
+		return labels[i] < labels[j]
+	})
+	if len(labels) > n {
+		labels = labels[:n]
+	}
+	return strings.Join(labels, "、")
+}
+
+func moodWord(p float64) string {
+	switch {
+	case p >= 0.3:
+		return "开心"
+	case p >= 0.05:
+		return "愉快"
+	case p > -0.05:
+		return "平静"
+	case p > -0.3:
+		return "有点低落"
+	default:
+		return "难过"
+	}
+}
+
+// ListSoulDiary returns a soul's diary entries, newest day first.
+func (s *Service) ListSoulDiary(ctx context.Context, soulID string, limit int) ([]domain.SoulDiaryEntry, error) {
+	return s.store.ListSoulDiaryEntries(ctx, soulID, limit)
+}
+
+// GetSoulDiaryEntry returns the soul's entry for day (YYYY-MM-DD).
+func (s *Service) GetSoulDiaryEntry(ctx context.Context, soulID, day string) (domain.SoulDiaryEntry, error) {
+	if _, err := time.Parse(time.DateOnly, day); err != nil {
+		return domain.SoulDiaryEntry{}, fmt.Errorf("%w: %q is not YYYY-MM-DD", ErrInvalidDiaryDay, day)
+	}
+	return s.store.GetSoulDiaryEntry(ctx, soulID, day)
+}
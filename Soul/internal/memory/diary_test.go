@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestDiaryDay(t *testing.T) {
+	evening := time.Date(2026, 10, 16, 22, 5, 0, 0, time.Local)
+	if got := diaryDay(evening, 22); !got.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("after the diary hour the day is today, got %s", got)
+	}
+	morning := time.Date(2026, 10, 17, 8, 0, 0, 0, time.Local)
+	if got := diaryDay(morning, 22); !got.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("before the diary hour the day is yesterday, got %s", got)
+	}
+}
+
+func TestBuildDiaryPrompt(t *testing.T) {
+	profile := domain.SoulProfile{Name: "小暖", CharacterCard: &domain.SoulCharacterCard{SpeakingStyle: "爱用叠词"}}
+	mood := summarizeAffect([]domain.TurnAffect{
+		{UserEmotion: domain.EmotionSignal{Emotion: "joy", P: 0.6, Intensity: 0.5}, SoulP: 0.4, ExecMode: "auto_execute"},
+		{UserEmotion: domain.EmotionSignal{Emotion: "anger", P: -0.8, Intensity: 0.9}, SoulP: -0.5, ExecMode: "blocked"},
+		{UserEmotion: domain.EmotionSignal{Emotion: "joy", P: 0.5, Intensity: 0.4}, SoulP: 0.1, ExecMode: "auto_execute"},
+	})
+	system, prompt := buildDiaryPrompt(profile, time.Date(2026, 10, 16, 0, 0, 0, 0, time.Local), []string{"用户让我提醒明早喝水。"}, mood)
+	for _, want := range []string{"小暖", "爱用叠词", "第一人称"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system prompt misses %q: %s", want, system)
+		}
+	}
+	for _, want := range []string{"2026年10月16日", "- 用户让我提醒明早喝水。", "共 3 轮对话", "joy、anger", "从「开心」到「愉快」", "最低时「难过」", "有 1 轮"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt misses %q: %s", want, prompt)
+		}
+	}
+}
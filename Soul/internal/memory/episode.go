@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+	"soul/internal/llm"
+)
+
+const (
+	maxEpisodeTags         = 8
+	maxEpisodeParticipants = 6
+)
+
+var episodeMetaSchema = json.RawMessage(`{
+	"type": "object",
+	"properties": {
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"participants": {"type": "array", "items": {"type": "string"}},
+		"importance": {"type": "integer", "enum": [1, 2, 3, 4, 5]}
+	},
+	"required": ["tags", "participants", "importance"]
+}`)
+
+// episodeMeta labels a session's episode. Tags start with the topics the
+// session's turns were labelled with and sentiment is the user's average
+// valence over its turns; the LLM adds free tags, the people involved and
+// an importance from 1 to 5. When the LLM fails the episode keeps what the
+// session itself recorded.
+func (s *Service) episodeMeta(ctx context.Context, item db.IdleSession, summary string) domain.EpisodeMeta {
+	var meta domain.EpisodeMeta
+	if topics, err := s.store.ListSessionTopics(ctx, item.SessionID); err != nil {
+		s.logger.Warn("list episode topics failed", "session_id", item.SessionID, "error", err)
+	} else {
+		for _, t := range topics {
+			meta.Tags = append(meta.Tags, t.Topic)
+		}
+	}
+	if affect, err := s.store.ListSessionAffect(ctx, item.SessionID); err != nil {
+		s.logger.Warn("list episode affect failed", "session_id", item.SessionID, "error", err)
+	} else if sum := summarizeAffect(affect); sum.Turns > 0 {
+		valence := sum.UserValenceAvg
+		meta.Sentiment = &valence
+	}
+
+	var tagged struct {
+		Tags         []string `json:"tags"`
+		Participants []string `json:"participants"`
+		Importance   int      `json:"importance"`
+	}
+	err := llm.CompleteJSON(ctx, s.llmProvider, domain.LLMRequest{
+		Model:    s.llmModel,
+		System:   "你是记忆标注器。根据一段会话摘要输出：tags 为不超过 5 个简短中文标签（如 工作、家人、健康、饮食、出行）；participants 为摘要中涉及的人（如 用户、妈妈、同事小王），不要编造；importance 为这段记忆对以后陪伴用户的重要程度，1 为闲聊琐事，5 为重要的人生事件、健康状况或长期偏好。",
+		Messages: []domain.Message{{Role: "user", Content: s.redactor.Mask(summary)}},
+	}, episodeMetaSchema, &tagged)
+	if err != nil {
+		s.logger.Warn("tag memory episode failed", "session_id", item.SessionID, "error", err)
+	} else {
+		meta.Tags = append(meta.Tags, tagged.Tags...)
+		meta.Participants = cleanLabels(tagged.Participants, maxEpisodeParticipants)
+		meta.Importance = tagged.Importance
+	}
+	meta.Tags = cleanLabels(meta.Tags, maxEpisodeTags)
+	return meta
+}
+
+// cleanLabels trims, drops empty and repeated labels and keeps at most max.
+func cleanLabels(labels []string, max int) []string {
+	out := make([]string, 0, len(labels))
+	seen := map[string]struct{}{}
+	for _, l := range labels {
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		if _, ok := seen[l]; ok {
+			continue
+		}
+		seen[l] = struct{}{}
+		out = append(out, l)
+		if len(out) == max {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,22 @@
+package memory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCleanLabels(t *testing.T) {
+	got := cleanLabels([]string{" 工作 ", "", "家人", "工作", "健康"}, 2)
+	if want := []string{"工作", "家人"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("cleanLabels = %v, want %v", got, want)
+	}
+}
+
+func TestHasAnyTag(t *testing.T) {
+	if !hasAnyTag([]string{"家人", "工作"}, []string{"工作"}) {
+		t.Fatal("expected a shared tag to match")
+	}
+	if hasAnyTag(nil, []string{"工作"}) || hasAnyTag([]string{"健康"}, []string{"工作"}) {
+		t.Fatal("expected no match without a shared tag")
+	}
+}
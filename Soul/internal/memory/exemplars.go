@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// ErrInvalidExemplar wraps the reasons an exemplar is rejected.
+var ErrInvalidExemplar = errors.New("invalid exemplar")
+
+const (
+	maxExemplarRunes    = 300
+	maxExemplarsPerSoul = 20
+)
+
+// AddSoulExemplar validates and stores an example exchange for the soul.
+func (s *Service) AddSoulExemplar(ctx context.Context, soulID string, payload domain.CreateSoulExemplarPayload) (domain.SoulExemplar, error) {
+	item := domain.SoulExemplar{
+		SoulID: strings.TrimSpace(soulID),
+		User:   strings.TrimSpace(payload.User),
+		Reply:  strings.TrimSpace(payload.Reply),
+	}
+	if item.User == "" || item.Reply == "" {
+		return domain.SoulExemplar{}, fmt.Errorf("%w: user and reply are required", ErrInvalidExemplar)
+	}
+	if len([]rune(item.User)) > maxExemplarRunes || len([]rune(item.Reply)) > maxExemplarRunes {
+		return domain.SoulExemplar{}, fmt.Errorf("%w: user and reply are at most %d characters each", ErrInvalidExemplar, maxExemplarRunes)
+	}
+	existing, err := s.store.ListSoulExemplars(ctx, item.SoulID)
+	if err != nil {
+		return domain.SoulExemplar{}, err
+	}
+	if len(existing) >= maxExemplarsPerSoul {
+		return domain.SoulExemplar{}, fmt.Errorf("%w: a soul has at most %d exemplars", ErrInvalidExemplar, maxExemplarsPerSoul)
+	}
+	return s.store.InsertSoulExemplar(ctx, item)
+}
+
+func (s *Service) ListSoulExemplars(ctx context.Context, soulID string) ([]domain.SoulExemplar, error) {
+	return s.store.ListSoulExemplars(ctx, soulID)
+}
+
+func (s *Service) DeleteSoulExemplar(ctx context.Context, soulID string, id int64) error {
+	return s.store.DeleteSoulExemplar(ctx, soulID, id)
+}
@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// exportMessageLimit bounds the messages one export carries.
+const exportMessageLimit = 2000
+
+// ExportSession gathers a session for sharing: its details and messages,
+// the user messages with their turn's affect. It returns
+// db.ErrSessionNotFound for unknown sessions.
+func (s *Service) ExportSession(ctx context.Context, sessionID string) (domain.SessionExport, error) {
+	info, err := s.store.GetSession(ctx, sessionID)
+	if err != nil {
+		return domain.SessionExport{}, err
+	}
+	messages, err := s.store.ListSessionExportMessages(ctx, sessionID, exportMessageLimit)
+	if err != nil {
+		return domain.SessionExport{}, err
+	}
+	return domain.SessionExport{
+		Session:    info,
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Messages:   messages,
+	}, nil
+}
+
+var exportRoleNames = map[string]string{
+	"user":        "用户",
+	"assistant":   "机器人",
+	"tool":        "工具",
+	"observation": "观察",
+	"system":      "系统",
+}
+
+// RenderSessionMarkdown renders an export as Markdown: every message under
+// its role and UTC time, tool outputs fenced under the skill name, and each
+// user turn followed by its emotion annotation.
+func RenderSessionMarkdown(e domain.SessionExport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# 会话 %s\n\n", e.Session.SessionID)
+	fmt.Fprintf(&sb, "- 用户：%s\n", e.Session.UserID)
+	fmt.Fprintf(&sb, "- 终端：%s\n", e.Session.TerminalID)
+	if e.Session.SoulID != "" {
+		fmt.Fprintf(&sb, "- 灵魂：%s\n", e.Session.SoulID)
+	}
+	fmt.Fprintf(&sb, "- 创建时间：%s\n", exportTime(e.Session.CreatedAt))
+	fmt.Fprintf(&sb, "- 导出时间：%s\n", exportTime(e.ExportedAt))
+	fmt.Fprintf(&sb, "- 消息数：%d\n", len(e.Messages))
+
+	for _, m := range e.Messages {
+		role := exportRoleNames[m.Role]
+		if role == "" {
+			role = m.Role
+		}
+		if m.Name != "" {
+			role += " `" + m.Name + "`"
+		}
+		fmt.Fprintf(&sb, "\n### %s · %s\n\n", role, exportTime(m.CreatedAt))
+		content := strings.TrimSpace(m.Content)
+		if m.Role == "tool" {
+			fence := "```"
+			for strings.Contains(content, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&sb, "%s\n%s\n%s\n", fence, content, fence)
+		} else {
+			sb.WriteString(content + "\n")
+		}
+		if len(m.Topics) > 0 {
+			fmt.Fprintf(&sb, "\n话题：%s\n", strings.Join(m.Topics, "、"))
+		}
+		if a := m.Affect; a != nil {
+			sb.WriteString("\n> " + describeTurnAffect(*a) + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func describeTurnAffect(a domain.TurnAffect) string {
+	emotion := strings.TrimSpace(a.UserEmotion.Emotion)
+	if emotion == "" {
+		emotion = "neutral"
+	}
+	gate := fmt.Sprintf("%s（%.2f）", a.ExecMode, a.ExecProbability)
+	if a.GateOverride {
+		gate += "，强制执行"
+	}
+	return fmt.Sprintf("情绪：用户 %s（强度 %.2f）· 灵魂 PAD P=%.2f A=%.2f D=%.2f · 门控 %s",
+		emotion, a.UserEmotion.Intensity, a.SoulP, a.SoulA, a.SoulD, gate)
+}
+
+// exportTime shows a stored RFC 3339 time to the second in UTC.
+func exportTime(raw string) string {
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return raw
+	}
+	return t.UTC().Format("2006-01-02 15:04:05") + " UTC"
+}
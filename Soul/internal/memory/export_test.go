@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestRenderSessionMarkdown(t *testing.T) {
+	e := domain.SessionExport{
+		Session:    domain.SessionInfo{SessionID: "s1", UserID: "u1", TerminalID: "t1", CreatedAt: "2026-10-16T12:00:00.123Z"},
+		ExportedAt: "2026-10-16T13:00:00Z",
+		Messages: []domain.SessionExportMessage{
+			{
+				SessionMessage: domain.SessionMessage{Role: "user", Content: "开灯", CreatedAt: "2026-10-16T12:00:01Z", Topics: []string{"家居"}},
+				Affect:         &domain.TurnAffect{UserEmotion: domain.EmotionSignal{Emotion: "joy", Intensity: 0.4}, SoulP: 0.3, ExecMode: "auto_execute", ExecProbability: 0.9},
+			},
+			{SessionMessage: domain.SessionMessage{Role: "tool", Name: "control_light", Content: "ok ```", CreatedAt: "2026-10-16T12:00:02Z"}},
+			{SessionMessage: domain.SessionMessage{Role: "assistant", Content: "灯开好了", CreatedAt: "2026-10-16T12:00:03Z"}},
+		},
+	}
+	got := RenderSessionMarkdown(e)
+	for _, want := range []string{
+		"# 会话 s1\n",
+		"- 创建时间：2026-10-16 12:00:00 UTC\n",
+		"### 用户 · 2026-10-16 12:00:01 UTC\n\n开灯\n\n话题：家居\n\n> 情绪：用户 joy（强度 0.40）· 灵魂 PAD P=0.30 A=0.00 D=0.00 · 门控 auto_execute（0.90）\n",
+		"### 工具 `control_light` · 2026-10-16 12:00:02 UTC\n\n````\nok ```\n````\n",
+		"### 机器人 · 2026-10-16 12:00:03 UTC\n\n灯开好了\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("export must contain %q, got:\n%s", want, got)
+		}
+	}
+}
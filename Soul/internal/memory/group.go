@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+// ErrInvalidSoulGroup wraps the reasons a soul group is rejected.
+var ErrInvalidSoulGroup = errors.New("invalid soul group")
+
+// maxSoulGroupSize keeps a group small enough that addressing by name
+// stays unambiguous and every soul gets a turn.
+const maxSoulGroupSize = 4
+
+// SetTerminalSoulGroup lets 2 to 4 of the user's souls share a terminal.
+func (s *Service) SetTerminalSoulGroup(ctx context.Context, userID, terminalID string, soulIDs []string) (domain.TerminalSoulGroup, error) {
+	ids := make([]string, 0, len(soulIDs))
+	seen := make(map[string]struct{}, len(soulIDs))
+	for _, id := range soulIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	if len(ids) < 2 || len(ids) > maxSoulGroupSize {
+		return domain.TerminalSoulGroup{}, fmt.Errorf("%w: a group has 2 to %d souls", ErrInvalidSoulGroup, maxSoulGroupSize)
+	}
+	return s.store.SetTerminalSoulGroup(ctx, userID, terminalID, ids)
+}
+
+// GetTerminalSoulGroup returns db.ErrSoulGroupNotFound for terminals that
+// host a single soul.
+func (s *Service) GetTerminalSoulGroup(ctx context.Context, userID, terminalID string) (domain.TerminalSoulGroup, error) {
+	return s.store.GetTerminalSoulGroup(ctx, userID, terminalID)
+}
+
+func (s *Service) DeleteTerminalSoulGroup(ctx context.Context, userID, terminalID string) error {
+	return s.store.DeleteTerminalSoulGroup(ctx, userID, terminalID)
+}
+
+// RecentGroupMessages is RecentMessages for a session several souls share,
+// seen by soulID: the replies of the other souls are prefixed with their
+// names from names so the LLM does not take them for its own.
+func (s *Service) RecentGroupMessages(ctx context.Context, sessionID string, limit int, soulID string, names map[string]string) ([]domain.Message, error) {
+	private, err := s.IsSessionPrivate(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if private {
+		return s.RecentMessages(ctx, sessionID, limit)
+	}
+	msgs, err := s.store.GetRecentSoulMessages(ctx, sessionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return labelGroupHistory(msgs, soulID, names), nil
+}
+
+func labelGroupHistory(msgs []db.SoulMessage, soulID string, names map[string]string) []domain.Message {
+	out := make([]domain.Message, 0, len(msgs))
+	for _, m := range msgs {
+		msg := m.Message
+		if msg.Role == "assistant" && m.SoulID != "" && m.SoulID != soulID {
+			name := names[m.SoulID]
+			if name == "" {
+				name = m.SoulID
+			}
+			msg.Content = "【" + name + "】" + msg.Content
+		}
+		out = append(out, msg)
+	}
+	return out
+}
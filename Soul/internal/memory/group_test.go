@@ -0,0 +1,27 @@
+package memory
+
+import (
+	"testing"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+func TestLabelGroupHistory(t *testing.T) {
+	msgs := []db.SoulMessage{
+		{Message: domain.Message{Role: "user", Content: "你们好"}, SoulID: "a"},
+		{Message: domain.Message{Role: "assistant", Content: "你好呀"}, SoulID: "a"},
+		{Message: domain.Message{Role: "assistant", Content: "哼，又来了"}, SoulID: "b"},
+		{Message: domain.Message{Role: "assistant", Content: "在的"}, SoulID: "c"},
+	}
+	got := labelGroupHistory(msgs, "a", map[string]string{"a": "小白", "b": "小黑"})
+	want := []string{"你们好", "你好呀", "【小黑】哼，又来了", "【c】在的"}
+	for i, w := range want {
+		if got[i].Content != w {
+			t.Fatalf("message %d: got %q, want %q", i, got[i].Content, w)
+		}
+	}
+	if msgs[2].Content != "哼，又来了" {
+		t.Fatal("labelling must not modify the input")
+	}
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"soul/internal/domain"
+)
+
+// userLanguages caches reply language preferences, which every chat turn
+// reads and only SetUserLanguage changes.
+type userLanguages struct {
+	mu    sync.Mutex
+	known map[string]string
+}
+
+func newUserLanguages() *userLanguages {
+	return &userLanguages{known: make(map[string]string)}
+}
+
+func (u *userLanguages) lookup(userID string) (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	language, ok := u.known[userID]
+	return language, ok
+}
+
+func (u *userLanguages) set(userID, language string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.known[userID] = language
+}
+
+func (u *userLanguages) forget(userID string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.known, userID)
+}
+
+// UserLanguage returns the user's preferred reply language, "" for none.
+func (s *Service) UserLanguage(ctx context.Context, userID string) (string, error) {
+	userID = strings.TrimSpace(userID)
+	if language, ok := s.languages.lookup(userID); ok {
+		return language, nil
+	}
+	language, err := s.store.GetUserLanguage(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	s.languages.set(userID, language)
+	return language, nil
+}
+
+// SetUserLanguage stores the user's preferred reply language; "" clears it.
+func (s *Service) SetUserLanguage(ctx context.Context, userID, language string) (domain.UserProfile, error) {
+	profile, err := s.store.SetUserLanguage(ctx, userID, language)
+	if err != nil {
+		return domain.UserProfile{}, err
+	}
+	s.languages.set(profile.UserID, profile.Language)
+	return profile, nil
+}
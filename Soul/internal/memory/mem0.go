@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
+
+	"soul/internal/domain"
 )
 
 type Mem0Client struct {
@@ -31,6 +34,9 @@ type ExternalMemoryFilter struct {
 	SoulID     string
 	SessionID  string
 	TerminalID string
+	// Tags keeps memories whose episode has any of them; it is applied
+	// after the mem0 search, by RecallFromMem0.
+	Tags []string
 }
 
 func NewMem0Client(baseURL, apiKey string, timeout time.Duration) *Mem0Client {
@@ -64,7 +70,9 @@ func (m *Mem0Client) Add(ctx context.Context, entry ExternalMemoryEntry) error {
 	return m.postJSON(ctx, "/memories", payload, nil)
 }
 
-func (m *Mem0Client) Search(ctx context.Context, query string, filter ExternalMemoryFilter, topK int) ([]string, error) {
+// Search returns the memories mem0 ranks closest to query, with whatever
+// provenance mem0 reports for each (id, run_id, created_at, score).
+func (m *Mem0Client) Search(ctx context.Context, query string, filter ExternalMemoryFilter, topK int) ([]domain.RecalledMemory, error) {
 	if strings.TrimSpace(query) == "" {
 		return nil, nil
 	}
@@ -99,17 +107,75 @@ func (m *Mem0Client) Search(ctx context.Context, query string, filter ExternalMe
 	return extractMem0Results(out), nil
 }
 
-func (m *Mem0Client) postJSON(ctx context.Context, path string, payload any, out any) error {
-	body, err := json.Marshal(payload)
+// DeleteUser erases every memory mem0 holds for the user.
+func (m *Mem0Client) DeleteUser(ctx context.Context, userID string) error {
+	if strings.TrimSpace(userID) == "" {
+		return fmt.Errorf("user_id is required")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, m.baseURL+"/memories?"+url.Values{"user_id": {userID}}.Encode(), nil)
 	if err != nil {
 		return err
 	}
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mem0 status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// Get returns one memory by id with the user it belongs to.
+func (m *Mem0Client) Get(ctx context.Context, memoryID string) (domain.RecalledMemory, string, error) {
+	var out map[string]any
+	if err := m.doJSON(ctx, http.MethodGet, "/memories/"+url.PathEscape(memoryID), nil, &out); err != nil {
+		return domain.RecalledMemory{}, "", err
+	}
+	item, ok := mem0Item(out)
+	if !ok {
+		return domain.RecalledMemory{}, "", fmt.Errorf("mem0 memory %s has no content", memoryID)
+	}
+	userID, _ := out["user_id"].(string)
+	return item, userID, nil
+}
+
+// Update overwrites the text of one memory.
+func (m *Mem0Client) Update(ctx context.Context, memoryID, text string) error {
+	return m.doJSON(ctx, http.MethodPut, "/memories/"+url.PathEscape(memoryID), map[string]any{"text": text}, nil)
+}
+
+// Delete removes one memory.
+func (m *Mem0Client) Delete(ctx context.Context, memoryID string) error {
+	return m.doJSON(ctx, http.MethodDelete, "/memories/"+url.PathEscape(memoryID), nil, nil)
+}
+
+func (m *Mem0Client) postJSON(ctx context.Context, path string, payload any, out any) error {
+	return m.doJSON(ctx, http.MethodPost, path, payload, out)
+}
+
+func (m *Mem0Client) doJSON(ctx context.Context, method, path string, payload any, out any) error {
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+path, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, method, m.baseURL+path, body)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	if m.apiKey != "" {
 		req.Header.Set("Authorization", "Bearer "+m.apiKey)
 	}
@@ -147,8 +213,8 @@ func (m *Mem0Client) IsReady(ctx context.Context) bool {
 	return resp.StatusCode < 500
 }
 
-func extractMem0Results(out map[string]any) []string {
-	candidates := make([]string, 0, 8)
+func extractMem0Results(out map[string]any) []domain.RecalledMemory {
+	candidates := make([]domain.RecalledMemory, 0, 8)
 
 	readItems := func(items []any) {
 		for _, item := range items {
@@ -156,11 +222,8 @@ func extractMem0Results(out map[string]any) []string {
 			if !ok {
 				continue
 			}
-			for _, key := range []string{"memory", "text", "content"} {
-				if v, ok := obj[key].(string); ok && strings.TrimSpace(v) != "" {
-					candidates = append(candidates, strings.TrimSpace(v))
-					break
-				}
+			if rec, ok := mem0Item(obj); ok {
+				candidates = append(candidates, rec)
 			}
 		}
 	}
@@ -173,13 +236,52 @@ func extractMem0Results(out map[string]any) []string {
 
 	// dedup
 	seen := map[string]struct{}{}
-	final := make([]string, 0, len(candidates))
+	final := make([]domain.RecalledMemory, 0, len(candidates))
 	for _, c := range candidates {
-		if _, ok := seen[c]; ok {
+		if _, ok := seen[c.Content]; ok {
 			continue
 		}
-		seen[c] = struct{}{}
+		seen[c.Content] = struct{}{}
 		final = append(final, c)
 	}
 	return final
 }
+
+// mem0Item reads one mem0 memory object; ok is false when it has no text.
+func mem0Item(obj map[string]any) (domain.RecalledMemory, bool) {
+	var content string
+	for _, key := range []string{"memory", "text", "content"} {
+		if v, ok := obj[key].(string); ok && strings.TrimSpace(v) != "" {
+			content = strings.TrimSpace(v)
+			break
+		}
+	}
+	if content == "" {
+		return domain.RecalledMemory{}, false
+	}
+	rec := domain.RecalledMemory{Content: content}
+	rec.MemoryID, _ = obj["id"].(string)
+	rec.SessionID, _ = obj["run_id"].(string)
+	rec.Score, _ = obj["score"].(float64)
+	for _, key := range []string{"created_at", "updated_at"} {
+		if v, ok := obj[key].(string); ok {
+			if at, ok := parseMem0Time(v); ok {
+				rec.At = at.UTC().Format(time.RFC3339)
+				break
+			}
+		}
+	}
+	return rec, true
+}
+
+// parseMem0Time reads mem0's timestamps, which are ISO 8601 with or
+// without a zone offset.
+func parseMem0Time(v string) (time.Time, bool) {
+	v = strings.TrimSpace(v)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMem0DeleteUser(t *testing.T) {
+	var gotMethod, gotPath, gotUser, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotUser, gotAuth = r.Method, r.URL.Path, r.URL.Query().Get("user_id"), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewMem0Client(srv.URL, "key", time.Second)
+	if err := client.DeleteUser(context.Background(), "u 1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if gotMethod != http.MethodDelete || gotPath != "/memories" || gotUser != "u 1" || gotAuth != "Bearer key" {
+		t.Fatalf("unexpected request %s %s user=%q auth=%q", gotMethod, gotPath, gotUser, gotAuth)
+	}
+}
+
+func TestMem0DeleteUserFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := NewMem0Client(srv.URL, "", time.Second).DeleteUser(context.Background(), "u1"); err == nil {
+		t.Fatal("expected an error for a failed delete")
+	}
+}
+
+func TestMem0SearchKeepsProvenance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"results": []map[string]any{
+			{"id": "m1", "memory": "不喝咖啡", "run_id": "s1", "score": 0.9, "created_at": "2026-03-02T09:30:00.123456-08:00"},
+			{"id": "m2", "memory": "不喝咖啡", "run_id": "s2"},
+			{"id": "m3", "text": "养了一只猫"},
+		}})
+	}))
+	defer srv.Close()
+
+	got, err := NewMem0Client(srv.URL, "", time.Second).Search(context.Background(), "咖啡", ExternalMemoryFilter{UserID: "u1"}, 5)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected duplicates dropped, got %+v", got)
+	}
+	first := got[0]
+	if first.MemoryID != "m1" || first.SessionID != "s1" || first.Score != 0.9 || first.At != "2026-03-02T17:30:00Z" {
+		t.Fatalf("unexpected provenance %+v", first)
+	}
+	if got[1].Content != "养了一只猫" || got[1].At != "" {
+		t.Fatalf("unexpected second memory %+v", got[1])
+	}
+}
+
+func TestMem0UpdateAndDeleteMemory(t *testing.T) {
+	var calls []string
+	var gotText string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodPut {
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			gotText = body["text"]
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := NewMem0Client(srv.URL, "", time.Second)
+	if err := client.Update(context.Background(), "m 1", "用户不喝咖啡"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := client.Delete(context.Background(), "m2"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if len(calls) != 2 || calls[0] != "PUT /memories/m 1" || calls[1] != "DELETE /memories/m2" || gotText != "用户不喝咖啡" {
+		t.Fatalf("unexpected calls %v text=%q", calls, gotText)
+	}
+}
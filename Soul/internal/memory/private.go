@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"soul/internal/domain"
+)
+
+// privateHistoryLimit bounds the messages a private session keeps in process.
+const privateHistoryLimit = 40
+
+// privateSessions remembers which sessions are private and keeps their
+// messages in process only: a private conversation keeps its context across
+// turns, but nothing reaches the messages table, summaries or mem0, and it is
+// gone once the server restarts or the session goes public again.
+type privateSessions struct {
+	mu       sync.Mutex
+	known    map[string]bool
+	messages map[string][]domain.Message
+}
+
+func newPrivateSessions() *privateSessions {
+	return &privateSessions{known: make(map[string]bool), messages: make(map[string][]domain.Message)}
+}
+
+func (p *privateSessions) lookup(sessionID string) (private, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	private, ok = p.known[sessionID]
+	return private, ok
+}
+
+func (p *privateSessions) set(sessionID string, private bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.known[sessionID] = private
+	if !private {
+		delete(p.messages, sessionID)
+	}
+}
+
+// forgetUser drops the user's sessions, after their data is purged.
+func (p *privateSessions) forgetUser(sessionIDs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range sessionIDs {
+		delete(p.known, id)
+		delete(p.messages, id)
+	}
+}
+
+// add keeps the conversational messages, the ones RecentMessages returns.
+func (p *privateSessions) add(sessionID string, role, name, toolCallID, content string) {
+	switch role {
+	case "user", "assistant", "tool", "system":
+	default:
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	msgs := append(p.messages[sessionID], domain.Message{Role: role, Name: name, ToolCallID: toolCallID, Content: content})
+	if len(msgs) > privateHistoryLimit {
+		msgs = append([]domain.Message(nil), msgs[len(msgs)-privateHistoryLimit:]...)
+	}
+	p.messages[sessionID] = msgs
+}
+
+func (p *privateSessions) recent(sessionID string) []domain.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]domain.Message(nil), p.messages[sessionID]...)
+}
+
+// IsSessionPrivate reports whether the session asked not to be remembered.
+func (s *Service) IsSessionPrivate(ctx context.Context, sessionID string) (bool, error) {
+	sessionID = strings.TrimSpace(sessionID)
+	if private, ok := s.private.lookup(sessionID); ok {
+		return private, nil
+	}
+	private, err := s.store.IsSessionPrivate(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	s.private.set(sessionID, private)
+	return private, nil
+}
+
+// SetSessionPrivate turns privacy mode on or off for a session. While on,
+// turns and session notes are kept in process only, and the session is
+// neither summarised nor written to long-term memory. Messages stored before
+// it was turned on are kept.
+func (s *Service) SetSessionPrivate(ctx context.Context, sessionID, userID string, private bool) error {
+	sessionID = strings.TrimSpace(sessionID)
+	if err := s.store.SetSessionPrivate(ctx, sessionID, userID, private); err != nil {
+		return err
+	}
+	s.private.set(sessionID, private)
+	return nil
+}
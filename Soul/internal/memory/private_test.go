@@ -0,0 +1,27 @@
+package memory
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPrivateSessionsKeepBoundedHistory(t *testing.T) {
+	p := newPrivateSessions()
+	p.set("s1", true)
+	p.add("s1", "observation", "", "", "视觉：桌上有杯子")
+	for i := 0; i < privateHistoryLimit+5; i++ {
+		p.add("s1", "user", "", "", fmt.Sprintf("m%d", i))
+	}
+	msgs := p.recent("s1")
+	if len(msgs) != privateHistoryLimit || msgs[0].Content != "m5" {
+		t.Fatalf("expected the last %d messages, got %d starting %q", privateHistoryLimit, len(msgs), msgs[0].Content)
+	}
+	if private, ok := p.lookup("s1"); !ok || !private {
+		t.Fatalf("expected s1 to be known private")
+	}
+
+	p.set("s1", false)
+	if len(p.recent("s1")) != 0 {
+		t.Fatalf("leaving privacy mode must drop the in-process messages")
+	}
+}
@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// ErrMem0Purge means mem0 could not erase the user's memories; nothing was
+// deleted locally and the purge can be retried.
+var ErrMem0Purge = errors.New("mem0 purge failed")
+
+// PurgeUserData erases everything stored about a user: mem0 memories first,
+// then every table in one transaction, then the in-process caches. mem0 goes
+// first so a failure there leaves the user intact rather than half erased.
+func (s *Service) PurgeUserData(ctx context.Context, userID string) (domain.UserDataPurge, error) {
+	userID = strings.TrimSpace(userID)
+	if userID == "" {
+		return domain.UserDataPurge{}, fmt.Errorf("user_id is required")
+	}
+	sessionIDs, err := s.store.ListUserSessionIDs(ctx, userID)
+	if err != nil {
+		return domain.UserDataPurge{}, err
+	}
+	mem0Purged := false
+	if s.mem0Client != nil {
+		if err := s.mem0Client.DeleteUser(ctx, userID); err != nil {
+			return domain.UserDataPurge{}, fmt.Errorf("%w: %v", ErrMem0Purge, err)
+		}
+		mem0Purged = true
+	}
+	result, err := s.store.PurgeUserData(ctx, userID, mem0Purged)
+	if err != nil {
+		return domain.UserDataPurge{}, err
+	}
+	s.private.forgetUser(sessionIDs)
+	s.languages.forget(userID)
+	s.contextCache.clear()
+	s.logger.Info("user data purged", "user_id", userID, "purge_id", result.ID, "deleted", result.Deleted, "mem0_purged", mem0Purged)
+	return result, nil
+}
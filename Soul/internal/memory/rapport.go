@@ -0,0 +1,106 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// Rapport moves in [0, 1] from a stranger's baseline. The first turn of a
+// day counts for much more than the ones after it, so daily contact builds
+// closeness over weeks rather than one long chat; warm turns add to a
+// turn's gain and cold ones subtract, and a conflict costs several days of
+// progress. Gains shrink as the score nears 1 and losses as it nears 0.
+// Without contact the distance to the baseline halves every
+// rapportHalfLife.
+const (
+	rapportBaseline     = 0.3
+	rapportHalfLife     = 21 * 24 * time.Hour
+	rapportDayGain      = 0.04
+	rapportTurnGain     = 0.004
+	rapportWarmthGain   = 0.02
+	rapportConflictLoss = 0.08
+)
+
+// RelationRapport returns how close soulID has grown to userID; ok is false
+// before they have talked.
+func (s *Service) RelationRapport(ctx context.Context, soulID, userID string) (domain.RelationRapport, bool, error) {
+	soulID = strings.TrimSpace(soulID)
+	userID = strings.TrimSpace(userID)
+	if soulID == "" || userID == "" {
+		return domain.RelationRapport{}, false, nil
+	}
+	return s.store.GetRelationRapport(ctx, soulID, userID)
+}
+
+// progressRapport folds a committed turn into the rapport of its soul and
+// user. Rapport is a side effect of the turn, so a failure is only logged.
+func (s *Service) progressRapport(ctx context.Context, soulID, userID string, affect domain.TurnAffect) {
+	soulID = strings.TrimSpace(soulID)
+	userID = strings.TrimSpace(userID)
+	if soulID == "" || userID == "" {
+		return
+	}
+	now := time.Now()
+	err := s.store.UpdateRelationRapport(ctx, soulID, userID, func(prev domain.RelationRapport) domain.RelationRapport {
+		return nextRapport(prev, affect, now)
+	})
+	if err != nil {
+		s.logger.Warn("update relation rapport failed", "soul_id", soulID, "user_id", userID, "error", err)
+	}
+}
+
+func nextRapport(prev domain.RelationRapport, affect domain.TurnAffect, now time.Time) domain.RelationRapport {
+	out := prev
+	score := prev.Score
+	if prev.Interactions == 0 {
+		score = rapportBaseline
+	}
+	last, err := time.Parse(time.RFC3339Nano, prev.LastInteractionAt)
+	newDay := err != nil || !sameDay(last, now)
+	if err == nil && now.After(last) {
+		fade := math.Pow(0.5, float64(now.Sub(last))/float64(rapportHalfLife))
+		score = rapportBaseline + (score-rapportBaseline)*fade
+	}
+
+	gain := rapportTurnGain
+	if newDay {
+		gain = rapportDayGain
+		out.InteractionDays++
+	}
+	gain += rapportWarmthGain * affect.UserEmotion.P * affect.UserEmotion.Intensity
+	if conflictTurn(affect) {
+		gain -= rapportConflictLoss
+		out.Conflicts++
+		out.LastConflictAt = now.UTC().Format(time.RFC3339Nano)
+	}
+	if gain > 0 {
+		score += gain * (1 - score)
+	} else {
+		score += gain * score
+	}
+
+	out.Score = math.Max(0, math.Min(1, score))
+	out.Interactions++
+	out.LastInteractionAt = now.UTC().Format(time.RFC3339Nano)
+	return out
+}
+
+// conflictTurn reports a negative turn that is hostile rather than hurt: the
+// user was dominant in it (anger, contempt), or it left the soul's gate
+// locked.
+func conflictTurn(affect domain.TurnAffect) bool {
+	if !NegativeTurn(affect) {
+		return false
+	}
+	return affect.UserEmotion.D > 0 || strings.TrimSpace(affect.ExecMode) == "blocked"
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.In(b.Location()).Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
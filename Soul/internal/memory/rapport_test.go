@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestNextRapport(t *testing.T) {
+	warm := domain.TurnAffect{UserEmotion: domain.EmotionSignal{Emotion: "joy", P: 0.6, A: 0.3, D: 0.2, Intensity: 0.5}, ExecMode: "auto_execute"}
+	start := time.Date(2026, 3, 2, 20, 0, 0, 0, time.UTC)
+
+	first := nextRapport(domain.RelationRapport{}, warm, start)
+	if first.Interactions != 1 || first.InteractionDays != 1 || first.Score <= rapportBaseline {
+		t.Fatalf("first turn must start above the baseline: %+v", first)
+	}
+
+	// Five warm turns a day for three weeks.
+	var r domain.RelationRapport
+	var week1 float64
+	for day := 0; day < 21; day++ {
+		for turn := 0; turn < 5; turn++ {
+			r = nextRapport(r, warm, start.Add(time.Duration(day)*24*time.Hour+time.Duration(turn)*time.Minute))
+		}
+		if day == 6 {
+			week1 = r.Score
+		}
+	}
+	if r.Interactions != 105 || r.InteractionDays != 21 {
+		t.Fatalf("unexpected counts: %+v", r)
+	}
+	if week1 < 0.45 || r.Score < 0.6 || r.Score <= week1 {
+		t.Fatalf("daily warm contact must build rapport over weeks: week1=%.3f week3=%.3f", week1, r.Score)
+	}
+
+	// A single long chat is worth much less than daily contact.
+	var binge domain.RelationRapport
+	for turn := 0; turn < 105; turn++ {
+		binge = nextRapport(binge, warm, start.Add(time.Duration(turn)*time.Minute))
+	}
+	if binge.Score >= r.Score {
+		t.Fatalf("one long chat %.3f must not match three weeks %.3f", binge.Score, r.Score)
+	}
+
+	last := start.Add(20*24*time.Hour + 4*time.Minute)
+	angry := domain.TurnAffect{UserEmotion: domain.EmotionSignal{Emotion: "anger", P: -0.7, D: 0.4, Intensity: 0.8}, ExecMode: "auto_execute"}
+	fought := nextRapport(r, angry, last.Add(time.Minute))
+	if fought.Conflicts != 1 || fought.LastConflictAt == "" || fought.Score >= r.Score-0.03 {
+		t.Fatalf("conflict must cost rapport: before=%.3f after=%+v", r.Score, fought)
+	}
+	sad := domain.TurnAffect{UserEmotion: domain.EmotionSignal{Emotion: "sadness", P: -0.6, D: -0.3, Intensity: 0.8}, ExecMode: "auto_execute"}
+	if got := nextRapport(r, sad, last.Add(time.Minute)); got.Conflicts != 0 {
+		t.Fatalf("a sad turn is not a conflict: %+v", got)
+	}
+
+	faded := nextRapport(r, domain.TurnAffect{}, last.Add(60*24*time.Hour))
+	if faded.Score >= r.Score-0.2 || faded.Score < rapportBaseline {
+		t.Fatalf("two months apart must fade toward the baseline: before=%.3f after=%.3f", r.Score, faded.Score)
+	}
+}
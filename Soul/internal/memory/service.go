@@ -8,9 +8,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
+
 	"soul/internal/db"
 	"soul/internal/domain"
 	"soul/internal/llm"
+	"soul/internal/redact"
+	"soul/internal/speaker"
+	"soul/internal/writebehind"
 )
 
 type ServiceConfig struct {
@@ -24,6 +29,28 @@ type ServiceConfig struct {
 	IdleSummaryScanInterval  time.Duration
 	IdleSummaryBatchSize     int
 	Mem0AsyncQueueEnabled    bool
+	// ContextCacheTTL bounds how long BuildContext inputs stay cached per
+	// session; zero disables the cache.
+	ContextCacheTTL time.Duration
+	// Redactor masks personal data in mem0 queries and queued mem0 entries;
+	// nil sends text as is.
+	Redactor *redact.Redactor
+	// DiaryHour is the local hour from which RunDiaryWorker writes the
+	// day's diary.
+	DiaryHour int
+	// SessionRetention is how long a session may sit idle before
+	// RunSessionArchiveWorker archives its messages; zero keeps them.
+	SessionRetention time.Duration
+	// SessionArchiveDiscard deletes expired messages instead of moving
+	// them to messages_archive.
+	SessionArchiveDiscard bool
+	// Writes defers the messages of a turn other than its user and
+	// assistant messages; nil stores the whole turn at once.
+	Writes *writebehind.Queue
+	// SoulLocks takes database advisory locks in LockSoul, so instances
+	// sharing the database do not run the same soul's work twice; off,
+	// LockSoul always succeeds.
+	SoulLocks bool
 }
 
 type Service struct {
@@ -42,6 +69,15 @@ type Service struct {
 	idleSummaryScanInterval  time.Duration
 	idleSummaryBatchSize     int
 	mem0AsyncQueueEnabled    bool
+	contextCache             *contextCache
+	private                  *privateSessions
+	languages                *userLanguages
+	redactor                 *redact.Redactor
+	diaryHour                int
+	sessionRetention         time.Duration
+	sessionArchiveDiscard    bool
+	writes                   *writebehind.Queue
+	soulLocks                bool
 	logger                   *slog.Logger
 }
 
@@ -82,6 +118,7 @@ func NewService(store *db.Store, cfg ServiceConfig, logger *slog.Logger) (*Servi
 		llmProvider:              cfg.LLMProvider,
 		llmModel:                 cfg.LLMModel,
 		mem0Client:               cfg.Mem0Client,
+		redactor:                 cfg.Redactor,
 		mem0ReadyCheckTTL:        5 * time.Second,
 		compressMessageThreshold: cfg.CompressMessageThreshold,
 		compressCharThreshold:    cfg.CompressCharThreshold,
@@ -90,6 +127,14 @@ func NewService(store *db.Store, cfg ServiceConfig, logger *slog.Logger) (*Servi
 		idleSummaryScanInterval:  cfg.IdleSummaryScanInterval,
 		idleSummaryBatchSize:     cfg.IdleSummaryBatchSize,
 		mem0AsyncQueueEnabled:    cfg.Mem0AsyncQueueEnabled,
+		contextCache:             newContextCache(cfg.ContextCacheTTL),
+		private:                  newPrivateSessions(),
+		languages:                newUserLanguages(),
+		diaryHour:                cfg.DiaryHour,
+		sessionRetention:         cfg.SessionRetention,
+		sessionArchiveDiscard:    cfg.SessionArchiveDiscard,
+		writes:                   cfg.Writes,
+		soulLocks:                cfg.SoulLocks,
 		logger:                   logger,
 	}, nil
 }
@@ -138,34 +183,287 @@ func (s *Service) ListSoulUserRelations(ctx context.Context, soulID string) ([]d
 	return s.store.ListSoulUserRelations(ctx, soulID)
 }
 
+func (s *Service) ListSpeakerProfiles(ctx context.Context, userID string) ([]domain.SpeakerProfile, error) {
+	return s.store.ListSpeakerProfiles(ctx, userID)
+}
+
+// EnrollSpeaker adds a voiceprint sample; repeated enrollments under the same
+// display name are averaged into a single profile.
+func (s *Service) EnrollSpeaker(ctx context.Context, payload domain.EnrollSpeakerPayload) (domain.SpeakerProfile, error) {
+	userID := strings.TrimSpace(payload.UserID)
+	displayName := strings.TrimSpace(payload.DisplayName)
+	if userID == "" || displayName == "" {
+		return domain.SpeakerProfile{}, fmt.Errorf("user_id and display_name are required")
+	}
+	if len(payload.Embedding) == 0 {
+		return domain.SpeakerProfile{}, fmt.Errorf("embedding is required")
+	}
+
+	existing, err := s.store.ListSpeakerProfiles(ctx, userID)
+	if err != nil {
+		return domain.SpeakerProfile{}, err
+	}
+	profile := domain.SpeakerProfile{
+		UserID:        userID,
+		DisplayName:   displayName,
+		SpeakerUserID: strings.TrimSpace(payload.SpeakerUserID),
+		RelationUUID:  strings.TrimSpace(payload.RelationUUID),
+		Embedding:     append([]float64{}, payload.Embedding...),
+		SampleCount:   1,
+	}
+	for _, item := range existing {
+		if item.DisplayName != displayName {
+			continue
+		}
+		profile.SpeakerID = item.SpeakerID
+		profile.Embedding, profile.SampleCount = speaker.MergeEmbedding(item.Embedding, item.SampleCount, payload.Embedding)
+		if profile.SpeakerUserID == "" {
+			profile.SpeakerUserID = item.SpeakerUserID
+		}
+		if profile.RelationUUID == "" {
+			profile.RelationUUID = item.RelationUUID
+		}
+		profile.FaceID = item.FaceID
+		break
+	}
+	return s.store.UpsertSpeakerProfile(ctx, profile)
+}
+
+// BindSpeakerFace links a face id reported in presence events to one of
+// the user's speaker profiles; an empty faceID unbinds it.
+func (s *Service) BindSpeakerFace(ctx context.Context, userID, speakerID, faceID string) (domain.SpeakerProfile, error) {
+	userID = strings.TrimSpace(userID)
+	speakerID = strings.TrimSpace(speakerID)
+	if err := s.store.SetSpeakerFace(ctx, userID, speakerID, strings.TrimSpace(faceID)); err != nil {
+		return domain.SpeakerProfile{}, err
+	}
+	profiles, err := s.store.ListSpeakerProfiles(ctx, userID)
+	if err != nil {
+		return domain.SpeakerProfile{}, err
+	}
+	for _, item := range profiles {
+		if item.SpeakerID == speakerID {
+			return item, nil
+		}
+	}
+	return domain.SpeakerProfile{}, db.ErrSpeakerNotFound
+}
+
+// SpeakerByFace returns the user's speaker profile bound to faceID.
+func (s *Service) SpeakerByFace(ctx context.Context, userID, faceID string) (domain.SpeakerProfile, bool, error) {
+	faceID = strings.TrimSpace(faceID)
+	if faceID == "" {
+		return domain.SpeakerProfile{}, false, nil
+	}
+	profiles, err := s.store.ListSpeakerProfiles(ctx, userID)
+	if err != nil {
+		return domain.SpeakerProfile{}, false, err
+	}
+	for _, item := range profiles {
+		if item.FaceID == faceID {
+			return item, true, nil
+		}
+	}
+	return domain.SpeakerProfile{}, false, nil
+}
+
 func (s *Service) UpdateSoulEmotionState(ctx context.Context, soulID string, state domain.SoulEmotionState) error {
-	return s.store.UpdateSoulEmotionState(ctx, soulID, state)
+	if err := s.store.UpdateSoulEmotionState(ctx, soulID, state); err != nil {
+		return err
+	}
+	s.contextCache.updateSoulEmotion(soulID, state)
+	return nil
+}
+
+// CompareAndSetSoulEmotionState stores state if the soul's emotion state is
+// still at version; see db.Store.CompareAndSetSoulEmotionState.
+func (s *Service) CompareAndSetSoulEmotionState(ctx context.Context, soulID string, version int64, state domain.SoulEmotionState) (int64, error) {
+	next, err := s.store.CompareAndSetSoulEmotionState(ctx, soulID, version, state)
+	if err != nil {
+		return 0, err
+	}
+	s.contextCache.updateSoulEmotion(soulID, state)
+	return next, nil
+}
+
+// SetSoulChildMode turns child mode on or off for a soul.
+func (s *Service) SetSoulChildMode(ctx context.Context, soulID string, enabled bool) (domain.SoulProfile, error) {
+	profile, err := s.store.SetSoulChildMode(ctx, soulID, enabled)
+	if err != nil {
+		return domain.SoulProfile{}, err
+	}
+	s.contextCache.invalidateSoul(soulID)
+	return profile, nil
 }
 
 func (s *Service) PersistMessage(ctx context.Context, sessionID, userID, terminalID, soulID, role, name, toolCallID, content string) error {
-	return s.store.SaveMessage(ctx, sessionID, userID, terminalID, soulID, role, name, toolCallID, content)
+	return s.saveMessage(ctx, sessionID, userID, terminalID, soulID, role, name, toolCallID, content)
 }
 
 func (s *Service) PersistObservation(ctx context.Context, sessionID, userID, terminalID, soulID, content string) error {
 	if strings.TrimSpace(content) == "" {
 		return nil
 	}
-	return s.store.SaveMessage(ctx, sessionID, userID, terminalID, soulID, "observation", "", "", content)
+	return s.saveMessage(ctx, sessionID, userID, terminalID, soulID, "observation", "", "", content)
+}
+
+func (s *Service) saveMessage(ctx context.Context, sessionID, userID, terminalID, soulID, role, name, toolCallID, content string) error {
+	private, err := s.IsSessionPrivate(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if private {
+		s.private.add(sessionID, role, name, toolCallID, content)
+		return nil
+	}
+	created, err := s.store.SaveMessage(ctx, sessionID, userID, terminalID, soulID, role, name, toolCallID, content)
+	if err != nil {
+		return err
+	}
+	if created {
+		s.warmContext(ctx, soulID, sessionID)
+	}
+	return nil
 }
 
+// warmContext seeds the context cache for a brand-new session, which has no
+// summary yet, so the first BuildContext only needs the cached profile.
+func (s *Service) warmContext(ctx context.Context, soulID, sessionID string) {
+	if !s.contextCache.enabled() {
+		return
+	}
+	if _, ok := s.contextCache.get(sessionID, soulID, time.Now()); ok {
+		return
+	}
+	profile, err := s.store.GetSoulProfileByID(ctx, soulID)
+	if err != nil {
+		s.logger.Warn("warm memory context failed", "session_id", sessionID, "soul_id", soulID, "error", err)
+		return
+	}
+	s.contextCache.put(sessionID, contextCacheEntry{soulID: soulID, profile: profile, loadedAt: time.Now()})
+}
+
+// RecentMessages returns the last limit messages of the session; for a
+// private session the in-process messages follow the stored ones.
 func (s *Service) RecentMessages(ctx context.Context, sessionID string, limit int) ([]domain.Message, error) {
-	return s.store.GetRecentMessages(ctx, sessionID, limit)
+	private, err := s.IsSessionPrivate(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := s.store.GetRecentMessages(ctx, sessionID, limit)
+	if err != nil || !private {
+		return msgs, err
+	}
+	msgs = append(msgs, s.private.recent(sessionID)...)
+	if len(msgs) > limit {
+		msgs = msgs[len(msgs)-limit:]
+	}
+	return msgs, nil
+}
+
+func (s *Service) GetSession(ctx context.Context, sessionID string) (domain.SessionInfo, error) {
+	return s.store.GetSession(ctx, sessionID)
+}
+
+func (s *Service) ListSessionMessages(ctx context.Context, sessionID string, limit int) ([]domain.SessionMessage, error) {
+	return s.store.ListSessionMessages(ctx, sessionID, limit)
+}
+
+func (s *Service) ListSessionTopics(ctx context.Context, sessionID string) ([]domain.SessionTopic, error) {
+	return s.store.ListSessionTopics(ctx, sessionID)
+}
+
+// ForkSession copies a session's history into a new session for what-if
+// replays. Forks never write memory episodes or mem0 jobs.
+func (s *Service) ForkSession(ctx context.Context, sourceID, targetID string, upToMessageID int64) (domain.SessionForkResult, error) {
+	targetID = strings.TrimSpace(targetID)
+	if targetID == "" {
+		targetID = "fork_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:16]
+	}
+	return s.store.ForkSession(ctx, sourceID, targetID, upToMessageID)
+}
+
+// IsSessionForked reports whether the session is a what-if replay made by
+// ForkSession. Chatting on a fork runs as a dry run.
+func (s *Service) IsSessionForked(ctx context.Context, sessionID string) (bool, error) {
+	return s.store.IsSessionForked(ctx, strings.TrimSpace(sessionID))
+}
+
+func (s *Service) UpdateSessionTerminal(ctx context.Context, sessionID, terminalID string) error {
+	return s.store.UpdateSessionTerminal(ctx, sessionID, terminalID)
 }
 
 func (s *Service) GetSessionSummary(ctx context.Context, sessionID string) (string, error) {
 	return s.store.GetSessionSummary(ctx, sessionID)
 }
 
-func (s *Service) RecallFromMem0(ctx context.Context, query string, filter ExternalMemoryFilter, topK int) ([]string, error) {
+// RecallFromMem0 searches mem0 and links each memory to the stored
+// episode of the session it came from. Memories without a timestamp of
+// their own take the episode's. With filter.Tags only memories whose
+// episode has one of the tags are kept; mem0 is asked for more candidates
+// to make up for the ones dropped.
+func (s *Service) RecallFromMem0(ctx context.Context, query string, filter ExternalMemoryFilter, topK int) ([]domain.RecalledMemory, error) {
 	if s.mem0Client == nil {
 		return nil, fmt.Errorf("mem0 recall is not configured")
 	}
-	return s.mem0Client.Search(ctx, query, filter, topK)
+	searchK := topK
+	if len(filter.Tags) > 0 {
+		searchK = min(max(topK, 1)*recallTagOversample, maxRecallTagCandidates)
+	}
+	items, err := s.mem0Client.Search(ctx, s.redactor.Mask(query), filter, searchK)
+	if err != nil || len(items) == 0 || filter.UserID == "" {
+		return items, err
+	}
+
+	sessionIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.SessionID != "" {
+			sessionIDs = append(sessionIDs, item.SessionID)
+		}
+	}
+	episodes, err := s.store.LatestSessionEpisodes(ctx, filter.UserID, sessionIDs)
+	if err != nil {
+		if len(filter.Tags) > 0 {
+			return nil, err
+		}
+		s.logger.Warn("recall episode lookup failed", "user_id", filter.UserID, "error", err)
+		return items, nil
+	}
+	kept := items[:0]
+	for _, item := range items {
+		ep, ok := episodes[item.SessionID]
+		if ok {
+			item.EpisodeID = ep.ID
+			item.Tags = ep.Tags
+			if item.At == "" {
+				item.At = ep.CreatedAt.UTC().Format(time.RFC3339)
+			}
+		}
+		if len(filter.Tags) > 0 && !hasAnyTag(item.Tags, filter.Tags) {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	if topK > 0 && len(kept) > topK {
+		kept = kept[:topK]
+	}
+	return kept, nil
+}
+
+const (
+	recallTagOversample    = 3
+	maxRecallTagCandidates = 30
+)
+
+func hasAnyTag(tags, want []string) bool {
+	for _, t := range tags {
+		for _, w := range want {
+			if t == w {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (s *Service) IsMem0RecallReady(ctx context.Context) bool {
@@ -194,15 +492,12 @@ func (s *Service) IsMem0RecallReady(ctx context.Context) bool {
 }
 
 func (s *Service) BuildContext(ctx context.Context, soulID, sessionID, observationDigest string) (string, string, error) {
-	profile, err := s.store.LoadSoulProfilePrompt(ctx, soulID)
-	if err != nil {
-		return "", "", err
-	}
-
-	summary, err := s.store.GetSessionSummary(ctx, sessionID)
+	entry, err := s.loadContextEntry(ctx, soulID, sessionID)
 	if err != nil {
 		return "", "", err
 	}
+	profile := db.SoulProfilePrompt(entry.profile)
+	summary := entry.summary
 	if strings.TrimSpace(summary) == "" {
 		summary = "暂无历史摘要。"
 	}
@@ -219,11 +514,50 @@ func (s *Service) BuildContext(ctx context.Context, soulID, sessionID, observati
 	return sb.String(), summary, nil
 }
 
+func (s *Service) loadContextEntry(ctx context.Context, soulID, sessionID string) (contextCacheEntry, error) {
+	now := time.Now()
+	if entry, ok := s.contextCache.get(sessionID, soulID, now); ok {
+		return entry, nil
+	}
+	profile, err := s.store.GetSoulProfileByID(ctx, soulID)
+	if err != nil {
+		return contextCacheEntry{}, err
+	}
+	summary, err := s.store.GetSessionSummary(ctx, sessionID)
+	if err != nil {
+		return contextCacheEntry{}, err
+	}
+	entry := contextCacheEntry{soulID: soulID, profile: profile, summary: summary, loadedAt: now}
+	s.contextCache.put(sessionID, entry)
+	return entry, nil
+}
+
+// Soul lock scopes. Emotion writes and compaction lock apart, so a slow
+// summary does not hold up a turn's persona update.
+const (
+	SoulLockEmotion    = "emotion"
+	SoulLockCompaction = "compaction"
+)
+
+// LockSoul takes the soul's lock for scope across every instance on the
+// database; see db.Store.Lock. With soul locks off it returns a no-op
+// release and ok true.
+func (s *Service) LockSoul(ctx context.Context, soulID, scope string, wait bool) (release func(), ok bool, err error) {
+	if !s.soulLocks {
+		return func() {}, true, nil
+	}
+	return s.store.Lock(ctx, "soul:"+scope+":"+soulID, wait)
+}
+
 func (s *Service) MaybeCompressSession(ctx context.Context, sessionID, userID, terminalID, soulID string, force bool) (string, bool, error) {
 	state, err := s.store.GetSessionCompactionState(ctx, sessionID)
 	if err != nil {
 		return "", false, err
 	}
+	// A private session's messages must not end up in a summary.
+	if private, err := s.IsSessionPrivate(ctx, sessionID); err != nil || private {
+		return state.Summary, false, err
+	}
 
 	stats, err := s.store.GetSessionCompactionStats(ctx, sessionID, state.LastCompactedMessageID)
 	if err != nil {
@@ -253,10 +587,14 @@ func (s *Service) MaybeCompressSession(ctx context.Context, sessionID, userID, t
 		nextSummary = state.Summary
 	}
 
-	lastCompactedID := chunks[len(chunks)-1].ID
+	var lastCompactedID int64
+	for _, c := range chunks {
+		lastCompactedID = max(lastCompactedID, c.ID)
+	}
 	if err := s.store.UpdateSessionSummary(ctx, sessionID, userID, terminalID, soulID, nextSummary, lastCompactedID); err != nil {
 		return "", false, err
 	}
+	s.contextCache.invalidateSession(sessionID)
 	return nextSummary, true, nil
 }
 
@@ -283,26 +621,61 @@ func (s *Service) processIdleSummaries(ctx context.Context) {
 	}
 
 	for _, item := range items {
-		summary, _, err := s.MaybeCompressSession(ctx, item.SessionID, item.UserID, item.TerminalID, item.SoulID, true)
-		if err != nil {
-			s.logger.Warn("idle compaction failed", "session_id", item.SessionID, "error", err)
-			continue
-		}
-		summary = strings.TrimSpace(summary)
+		s.processIdleSession(ctx, item)
+	}
+}
 
-		if summary != "" {
-			if err := s.store.InsertMemoryEpisode(ctx, item.SessionID, item.UserID, item.TerminalID, item.SoulID, summary); err != nil {
-				s.logger.Warn("insert memory episode failed", "session_id", item.SessionID, "error", err)
-			}
-			if s.mem0AsyncQueueEnabled {
-				if err := s.store.EnqueueMem0AsyncJob(ctx, item.SessionID, item.UserID, item.TerminalID, item.SoulID, summary, "idle_timeout"); err != nil {
-					s.logger.Warn("enqueue mem0 async job failed", "session_id", item.SessionID, "error", err)
-				}
-			}
+// processIdleSession summarizes one idle session into an episode. Another
+// instance holding the soul's compaction lock is working on it already, and
+// one that held it earlier may have finished it, so it is skipped then.
+func (s *Service) processIdleSession(ctx context.Context, item db.IdleSession) {
+	release, ok, err := s.LockSoul(ctx, item.SoulID, SoulLockCompaction, false)
+	if err != nil {
+		s.logger.Warn("idle compaction: lock soul failed", "session_id", item.SessionID, "error", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	defer release()
+	if s.soulLocks {
+		if pending, err := s.store.IdleSummaryPending(ctx, item.SessionID); err != nil || !pending {
+			return
 		}
+	}
+
+	summary, _, err := s.MaybeCompressSession(ctx, item.SessionID, item.UserID, item.TerminalID, item.SoulID, true)
+	if err != nil {
+		s.logger.Warn("idle compaction failed", "session_id", item.SessionID, "error", err)
+		return
+	}
+	summary = strings.TrimSpace(summary)
+
+	if summary != "" && item.Forked {
+		s.logger.Info("skip long-term memory for forked session", "session_id", item.SessionID)
+	} else if summary != "" && item.Private {
+		s.logger.Info("skip long-term memory for private session", "session_id", item.SessionID)
+	} else if summary != "" {
+		s.storeEpisode(ctx, item, summary, "idle_timeout")
+	}
+
+	if err := s.store.MarkIdleSummaryProcessed(ctx, item.SessionID, time.Now()); err != nil {
+		s.logger.Warn("mark idle summary processed failed", "session_id", item.SessionID, "error", err)
+	}
+}
 
-		if err := s.store.MarkIdleSummaryProcessed(ctx, item.SessionID, time.Now()); err != nil {
-			s.logger.Warn("mark idle summary processed failed", "session_id", item.SessionID, "error", err)
+// storeEpisode keeps a session summary as a tagged episode and queues it
+// for mem0.
+func (s *Service) storeEpisode(ctx context.Context, item db.IdleSession, summary, trigger string) {
+	meta := s.episodeMeta(ctx, item, summary)
+	if err := s.store.InsertMemoryEpisode(ctx, item.SessionID, item.UserID, item.TerminalID, item.SoulID, summary, meta); err != nil {
+		s.logger.Warn("insert memory episode failed", "session_id", item.SessionID, "error", err)
+	} else {
+		s.contextCache.invalidateSession(item.SessionID)
+	}
+	if s.mem0AsyncQueueEnabled {
+		if err := s.store.EnqueueMem0AsyncJob(ctx, item.SessionID, item.UserID, item.TerminalID, item.SoulID, s.redactor.Mask(summary), trigger); err != nil {
+			s.logger.Warn("enqueue mem0 async job failed", "session_id", item.SessionID, "error", err)
 		}
 	}
 }
@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"testing"
+
+	"soul/internal/persona"
+)
+
+func TestSoulTemplatesAreValid(t *testing.T) {
+	seen := map[string]bool{}
+	for _, tpl := range SoulTemplates() {
+		if seen[tpl.ID] {
+			t.Fatalf("duplicate template id %q", tpl.ID)
+		}
+		seen[tpl.ID] = true
+		if _, err := persona.VectorFromMBTI(tpl.MBTIType); err != nil {
+			t.Fatalf("template %q: %v", tpl.ID, err)
+		}
+		if _, err := normalizeCharacterCard(tpl.CharacterCard); err != nil {
+			t.Fatalf("template %q: %v", tpl.ID, err)
+		}
+		if len(tpl.Exemplars) > maxExemplarsPerSoul {
+			t.Fatalf("template %q has %d exemplars", tpl.ID, len(tpl.Exemplars))
+		}
+		for _, ex := range tpl.Exemplars {
+			if ex.User == "" || ex.Reply == "" || len([]rune(ex.User)) > maxExemplarRunes || len([]rune(ex.Reply)) > maxExemplarRunes {
+				t.Fatalf("template %q has an invalid exemplar %+v", tpl.ID, ex)
+			}
+		}
+	}
+	for _, id := range []string{"assistant", "companion", "snarky"} {
+		if _, ok := SoulTemplate(id); !ok {
+			t.Fatalf("template %q is missing", id)
+		}
+	}
+	if _, ok := SoulTemplate("nope"); ok {
+		t.Fatal("unknown template must not be found")
+	}
+}
@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+// Turn buffers the messages produced while handling one chat request so they
+// are committed together by CommitTurn.
+type Turn struct {
+	write db.TurnWrite
+	// speakerUserID is the recognised speaker when it is not the session's
+	// user; the turn builds their rapport with the soul.
+	speakerUserID string
+}
+
+func (s *Service) BeginTurn(sessionID, userID, terminalID, soulID string) *Turn {
+	return &Turn{write: db.TurnWrite{
+		SessionID:  sessionID,
+		UserID:     userID,
+		TerminalID: terminalID,
+		SoulID:     soulID,
+	}}
+}
+
+func (t *Turn) AddMessage(role, name, toolCallID, content string) {
+	t.write.Messages = append(t.write.Messages, db.PendingMessage{
+		Role:       role,
+		Name:       name,
+		ToolCallID: toolCallID,
+		Content:    content,
+		At:         time.Now(),
+	})
+}
+
+// SetTopics labels the turn; the labels are stored on its user message.
+func (t *Turn) SetTopics(topics []string) {
+	t.write.Topics = topics
+}
+
+// SetAffect records the turn's user emotion, soul mood and gate decision;
+// it is stored on the turn's user message.
+func (t *Turn) SetAffect(affect domain.TurnAffect) {
+	t.write.Affect = &affect
+}
+
+// SetSpeaker attributes the turn to a recognised speaker with an account
+// of their own.
+func (t *Turn) SetSpeaker(userID string) {
+	t.speakerUserID = strings.TrimSpace(userID)
+}
+
+func (t *Turn) AddObservation(content string) {
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	t.AddMessage("observation", "", "", content)
+}
+
+// CommitTurn stores the turn's messages and, for a turn with affect, moves
+// the rapport between its soul and user; a private session keeps the
+// messages in process instead and leaves rapport alone. With a write-behind
+// queue only the user and assistant messages are stored before it returns;
+// tool outputs and observations follow from the queue, appended to the
+// session as it is by then.
+func (s *Service) CommitTurn(ctx context.Context, t *Turn) error {
+	private, err := s.IsSessionPrivate(ctx, t.write.SessionID)
+	if err != nil {
+		return err
+	}
+	if private {
+		for _, m := range t.write.Messages {
+			s.private.add(t.write.SessionID, m.Role, m.Name, m.ToolCallID, m.Content)
+		}
+		return nil
+	}
+	write := t.write
+	var deferred []db.PendingMessage
+	if s.writes != nil {
+		write.Messages, deferred = splitTurnMessages(t.write.Messages)
+	}
+	created, err := s.store.SaveTurn(ctx, write)
+	if err != nil {
+		return err
+	}
+	if len(deferred) > 0 {
+		rest := write
+		rest.Messages, rest.Topics, rest.Affect = deferred, nil, nil
+		s.writes.Do(ctx, "turn_messages", func(ctx context.Context) error {
+			_, err := s.store.AppendTurnMessages(ctx, rest)
+			return err
+		})
+	}
+	if created {
+		s.warmContext(ctx, t.write.SoulID, t.write.SessionID)
+	}
+	if t.write.Affect != nil {
+		userID := t.speakerUserID
+		if userID == "" {
+			userID = t.write.UserID
+		}
+		s.progressRapport(ctx, t.write.SoulID, userID, *t.write.Affect)
+	}
+	return nil
+}
+
+// splitTurnMessages separates the user and assistant messages, which the
+// next turn's history cannot do without, from the rest.
+func splitTurnMessages(msgs []db.PendingMessage) (now, later []db.PendingMessage) {
+	for _, m := range msgs {
+		if m.Role == "user" || m.Role == "assistant" {
+			now = append(now, m)
+		} else {
+			later = append(later, m)
+		}
+	}
+	return now, later
+}
@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"testing"
+
+	"soul/internal/db"
+)
+
+func TestSplitTurnMessagesKeepsTheConversationSynchronous(t *testing.T) {
+	s := &Service{}
+	turn := s.BeginTurn("s1", "u1", "t1", "soul-1")
+	turn.AddObservation("看到用户在桌前")
+	turn.AddMessage("user", "", "", "开灯")
+	turn.AddMessage("tool", "control_light", "call_1", "ok")
+	turn.AddMessage("assistant", "", "", "灯开了")
+	now, later := splitTurnMessages(turn.write.Messages)
+	roles := func(msgs []db.PendingMessage) []string {
+		out := make([]string, 0, len(msgs))
+		for _, m := range msgs {
+			out = append(out, m.Role)
+		}
+		return out
+	}
+	if got := roles(now); len(got) != 2 || got[0] != "user" || got[1] != "assistant" {
+		t.Fatalf("synchronous messages = %v", got)
+	}
+	if got := roles(later); len(got) != 2 || got[0] != "observation" || got[1] != "tool" {
+		t.Fatalf("deferred messages = %v", got)
+	}
+	// Stamped when added, so the deferred tool output still sorts between
+	// the user message and the reply.
+	if later[1].At.Before(now[0].At) || later[1].At.After(now[1].At) {
+		t.Fatalf("tool output at %v is not between %v and %v", later[1].At, now[0].At, now[1].At)
+	}
+}
@@ -0,0 +1,300 @@
+// Package moodalerts watches the emotion recorded on each user turn and
+// notices when a user's mood has stayed low for several days. It then has
+// the robot offer a gentle check-in on the terminal the user last spoke to,
+// writes it into that session and, when configured, notifies a webhook
+// (for example a family member's chat app). Users can opt out or set their
+// own thresholds.
+package moodalerts
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"time"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+	"soul/internal/memory"
+	"soul/internal/quiethours"
+)
+
+// A day with fewer user turns than minDayTurns says too little about the
+// user's mood and is skipped.
+const minDayTurns = 2
+
+// MaxDays caps the per-user Days setting.
+const MaxDays = 14
+
+// CheckInMessage is what the robot says after a low stretch.
+const CheckInMessage = "这几天感觉你心情不太好，要不要跟我聊聊？我一直都在。"
+
+type Store interface {
+	ListAffectUsers(ctx context.Context, since time.Time) ([]string, error)
+	ListUserAffect(ctx context.Context, userID string, from, to time.Time) ([]domain.TurnAffect, error)
+	ListMoodAlertSettings(ctx context.Context) ([]domain.MoodAlertSettings, error)
+	ListMoodAlerts(ctx context.Context, userID string, limit int) ([]domain.MoodAlert, error)
+	InsertMoodAlert(ctx context.Context, in domain.MoodAlert) (domain.MoodAlert, error)
+	LatestUserSession(ctx context.Context, userID string) (domain.SessionInfo, error)
+}
+
+// MessageWriter appends a message to a session; memory.Service does it.
+type MessageWriter interface {
+	PersistMessage(ctx context.Context, sessionID, userID, terminalID, soulID, role, name, toolCallID, content string) error
+}
+
+type StatusPublisher interface {
+	PublishStatus(ctx context.Context, terminalID, status, message, sessionID string) error
+}
+
+// Notifier passes an alert on to someone who can look after the user.
+type Notifier interface {
+	Notify(ctx context.Context, alert domain.MoodAlert) error
+}
+
+// QuietHours tells whether a user is in a do-not-disturb window;
+// quiethours.Policy does it.
+type QuietHours interface {
+	Active(userID, terminalID string, now time.Time) (quiethours.Window, bool)
+}
+
+// Config holds the defaults for users without settings of their own.
+type Config struct {
+	// Days is how many of the user's recent talking days must all be low.
+	Days int
+	// NegativeShare is the share of negative turns that makes a day low.
+	NegativeShare float64
+}
+
+// Worker scans for low stretches and delivers their alerts.
+type Worker struct {
+	cfg      Config
+	store    Store
+	messages MessageWriter
+	status   StatusPublisher
+	notifier Notifier
+	quiet    QuietHours
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+// NewWorker builds a Worker; notifier and quiet may be nil.
+func NewWorker(cfg Config, store Store, messages MessageWriter, status StatusPublisher, notifier Notifier, quiet QuietHours, logger *slog.Logger) *Worker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	cfg.Days = clampDays(cfg.Days)
+	if cfg.NegativeShare <= 0 || cfg.NegativeShare > 1 {
+		cfg.NegativeShare = 0.5
+	}
+	return &Worker{cfg: cfg, store: store, messages: messages, status: status, notifier: notifier, quiet: quiet, logger: logger, now: time.Now}
+}
+
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	w.logger.Info("mood alert worker started", "interval", interval, "days", w.cfg.Days, "negative_share", w.cfg.NegativeShare)
+	for {
+		w.Scan(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan alerts every user whose mood has stayed low. A stretch is alerted
+// once; the next alert needs a whole new stretch after it. During the
+// user's quiet hours the alert waits for a later scan.
+func (w *Worker) Scan(ctx context.Context) {
+	now := w.now()
+	users, err := w.store.ListAffectUsers(ctx, now.AddDate(0, 0, -2*MaxDays))
+	if err != nil {
+		w.logger.Warn("list users for mood alerts failed", "error", err)
+		return
+	}
+	if len(users) == 0 {
+		return
+	}
+	stored, err := w.store.ListMoodAlertSettings(ctx)
+	if err != nil {
+		w.logger.Warn("list mood alert settings failed", "error", err)
+		return
+	}
+	settings := make(map[string]domain.MoodAlertSettings, len(stored))
+	for _, item := range stored {
+		settings[item.UserID] = item
+	}
+	for _, userID := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		days, share, ok := w.thresholds(settings, userID)
+		if !ok {
+			continue
+		}
+		if err := w.check(ctx, userID, days, share, now); err != nil {
+			w.logger.Warn("mood alert check failed", "user_id", userID, "error", err)
+		}
+	}
+}
+
+// thresholds returns the user's days and negative share; ok is false when
+// the user opted out.
+func (w *Worker) thresholds(settings map[string]domain.MoodAlertSettings, userID string) (int, float64, bool) {
+	days, share := w.cfg.Days, w.cfg.NegativeShare
+	item, found := settings[userID]
+	if !found {
+		return days, share, true
+	}
+	if !item.Enabled {
+		return 0, 0, false
+	}
+	if item.Days > 0 {
+		days = clampDays(item.Days)
+	}
+	if item.NegativeShare > 0 && item.NegativeShare <= 1 {
+		share = item.NegativeShare
+	}
+	return days, share, true
+}
+
+func (w *Worker) check(ctx context.Context, userID string, days int, share float64, now time.Time) error {
+	turns, err := w.store.ListUserAffect(ctx, userID, now.AddDate(0, 0, -2*days), now)
+	if err != nil {
+		return err
+	}
+	alert, ok := lowStretch(turns, days, share, time.Local)
+	if !ok {
+		return nil
+	}
+	alert.UserID = userID
+	last, err := w.store.ListMoodAlerts(ctx, userID, 1)
+	if err != nil {
+		return err
+	}
+	if len(last) > 0 && alert.FirstDay <= last[0].LastDay {
+		return nil
+	}
+
+	session, err := w.store.LatestUserSession(ctx, userID)
+	if err != nil && !errors.Is(err, db.ErrSessionNotFound) {
+		return err
+	}
+	if w.quiet != nil {
+		if window, quiet := w.quiet.Active(userID, session.TerminalID, now); quiet {
+			w.logger.Debug("mood alert held for quiet hours", "user_id", userID, "until", window.Until)
+			return nil
+		}
+	}
+	alert.SessionID, alert.TerminalID = session.SessionID, session.TerminalID
+	if session.SessionID != "" {
+		alert.CheckIn = CheckInMessage
+	}
+	alert, err = w.store.InsertMoodAlert(ctx, alert)
+	if errors.Is(err, db.ErrMoodAlertExists) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w.deliver(ctx, alert, session.SoulID)
+	return nil
+}
+
+// deliver runs after the alert is recorded, so a failure here is logged
+// rather than retried.
+func (w *Worker) deliver(ctx context.Context, alert domain.MoodAlert, soulID string) {
+	if alert.CheckIn != "" {
+		if err := w.messages.PersistMessage(ctx, alert.SessionID, alert.UserID, alert.TerminalID, soulID, "assistant", "", "", alert.CheckIn); err != nil {
+			w.logger.Warn("write mood check-in to session failed", "user_id", alert.UserID, "session_id", alert.SessionID, "error", err)
+		}
+		if err := w.status.PublishStatus(ctx, alert.TerminalID, domain.TerminalStatusCheckIn, alert.CheckIn, alert.SessionID); err != nil {
+			w.logger.Warn("publish mood check-in failed", "user_id", alert.UserID, "terminal_id", alert.TerminalID, "error", err)
+		}
+	}
+	if w.notifier != nil {
+		if err := w.notifier.Notify(ctx, alert); err != nil {
+			w.logger.Warn("notify mood alert failed", "user_id", alert.UserID, "error", err)
+		}
+	}
+	w.logger.Info("mood alert", "user_id", alert.UserID, "first_day", alert.FirstDay, "last_day", alert.LastDay, "negative_share", alert.NegativeShare)
+}
+
+type dayMood struct {
+	day      string
+	turns    int
+	negative int
+	valence  float64
+	weight   float64
+}
+
+// lowStretch looks at the user's last days talking days with at least
+// minDayTurns turns each. It reports a stretch when all of them were low,
+// meaning no less than share of the day's turns were negative.
+func lowStretch(turns []domain.TurnAffect, days int, share float64, loc *time.Location) (domain.MoodAlert, bool) {
+	byDay := make(map[string]*dayMood)
+	for _, t := range turns {
+		at, err := time.Parse(time.RFC3339Nano, t.At)
+		if err != nil {
+			continue
+		}
+		day := at.In(loc).Format(time.DateOnly)
+		mood := byDay[day]
+		if mood == nil {
+			mood = &dayMood{day: day}
+			byDay[day] = mood
+		}
+		mood.turns++
+		if memory.NegativeTurn(t) {
+			mood.negative++
+		}
+		mood.valence += t.UserEmotion.P * t.UserEmotion.Intensity
+		mood.weight += t.UserEmotion.Intensity
+	}
+
+	talked := make([]*dayMood, 0, len(byDay))
+	for _, mood := range byDay {
+		if mood.turns >= minDayTurns {
+			talked = append(talked, mood)
+		}
+	}
+	if len(talked) < days {
+		return domain.MoodAlert{}, false
+	}
+	sort.Slice(talked, func(i, j int) bool { return talked[i].day > talked[j].day })
+	stretch := talked[:days]
+
+	var turnCount, negative int
+	var valence, weight float64
+	for _, mood := range stretch {
+		if float64(mood.negative) < share*float64(mood.turns) {
+			return domain.MoodAlert{}, false
+		}
+		turnCount += mood.turns
+		negative += mood.negative
+		valence += mood.valence
+		weight += mood.weight
+	}
+	alert := domain.MoodAlert{
+		FirstDay:      stretch[days-1].day,
+		LastDay:       stretch[0].day,
+		Days:          days,
+		NegativeShare: float64(negative) / float64(turnCount),
+	}
+	if weight > 0 {
+		alert.UserValenceAvg = valence / weight
+	}
+	return alert, true
+}
+
+func clampDays(days int) int {
+	if days < 1 {
+		return 3
+	}
+	return min(days, MaxDays)
+}
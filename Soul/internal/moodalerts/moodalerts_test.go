@@ -0,0 +1,177 @@
+package moodalerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+type memoryStore struct {
+	turns    map[string][]domain.TurnAffect
+	settings []domain.MoodAlertSettings
+	alerts   []domain.MoodAlert
+	session  map[string]domain.SessionInfo
+}
+
+func (s *memoryStore) ListAffectUsers(context.Context, time.Time) ([]string, error) {
+	var out []string
+	for userID := range s.turns {
+		out = append(out, userID)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) ListUserAffect(_ context.Context, userID string, from, to time.Time) ([]domain.TurnAffect, error) {
+	var out []domain.TurnAffect
+	for _, t := range s.turns[userID] {
+		at, _ := time.Parse(time.RFC3339Nano, t.At)
+		if !at.Before(from) && at.Before(to) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) ListMoodAlertSettings(context.Context) ([]domain.MoodAlertSettings, error) {
+	return s.settings, nil
+}
+
+func (s *memoryStore) ListMoodAlerts(_ context.Context, userID string, limit int) ([]domain.MoodAlert, error) {
+	var out []domain.MoodAlert
+	for i := len(s.alerts) - 1; i >= 0 && len(out) < limit; i-- {
+		if s.alerts[i].UserID == userID {
+			out = append(out, s.alerts[i])
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) InsertMoodAlert(_ context.Context, in domain.MoodAlert) (domain.MoodAlert, error) {
+	for _, a := range s.alerts {
+		if a.UserID == in.UserID && a.FirstDay == in.FirstDay {
+			return domain.MoodAlert{}, db.ErrMoodAlertExists
+		}
+	}
+	in.ID = int64(len(s.alerts) + 1)
+	s.alerts = append(s.alerts, in)
+	return in, nil
+}
+
+func (s *memoryStore) LatestUserSession(_ context.Context, userID string) (domain.SessionInfo, error) {
+	session, ok := s.session[userID]
+	if !ok {
+		return domain.SessionInfo{}, db.ErrSessionNotFound
+	}
+	return session, nil
+}
+
+type sessionWrites struct{ rows []string }
+
+func (w *sessionWrites) PersistMessage(_ context.Context, sessionID, _, _, _, role, _, _, content string) error {
+	w.rows = append(w.rows, sessionID+"|"+role+"|"+content)
+	return nil
+}
+
+type statusLog struct{ sent []string }
+
+func (l *statusLog) PublishStatus(_ context.Context, terminalID, status, message, _ string) error {
+	l.sent = append(l.sent, terminalID+"|"+status+"|"+message)
+	return nil
+}
+
+type notifyLog struct{ alerts []domain.MoodAlert }
+
+func (n *notifyLog) Notify(_ context.Context, alert domain.MoodAlert) error {
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+var (
+	sad  = domain.EmotionSignal{Emotion: "sadness", P: -0.6, D: -0.3, Intensity: 0.7}
+	calm = domain.EmotionSignal{Emotion: "calm", P: 0.2, Intensity: 0.3}
+)
+
+// day appends turns spoken at noon UTC, n days before start.
+func day(turns []domain.TurnAffect, start time.Time, daysAgo int, emotions ...domain.EmotionSignal) []domain.TurnAffect {
+	noon := start.AddDate(0, 0, -daysAgo)
+	for i, e := range emotions {
+		at := noon.Add(time.Duration(i) * time.Minute)
+		turns = append(turns, domain.TurnAffect{At: at.Format(time.RFC3339Nano), UserEmotion: e})
+	}
+	return turns
+}
+
+func TestLowStretch(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	var turns []domain.TurnAffect
+	turns = day(turns, now, 4, sad, sad, calm)
+	turns = day(turns, now, 2, sad, calm)
+	turns = day(turns, now, 1, sad) // too few turns to count
+	turns = day(turns, now, 0, sad, sad, sad)
+
+	alert, ok := lowStretch(turns, 3, 0.5, time.UTC)
+	if !ok || alert.FirstDay != "2026-03-06" || alert.LastDay != "2026-03-10" || alert.Days != 3 {
+		t.Fatalf("expected a three-day stretch skipping the quiet day, got %+v %v", alert, ok)
+	}
+	if alert.NegativeShare != 6.0/8.0 || alert.UserValenceAvg >= 0 {
+		t.Fatalf("unexpected stretch figures: %+v", alert)
+	}
+
+	if _, ok := lowStretch(turns, 3, 0.7, time.UTC); ok {
+		t.Fatalf("a 50%% day must not be low at a 70%% threshold")
+	}
+	if _, ok := lowStretch(turns, 4, 0.5, time.UTC); ok {
+		t.Fatalf("three talking days cannot make a four-day stretch")
+	}
+	turns = day(turns, now, 3, calm, calm)
+	if _, ok := lowStretch(turns, 4, 0.5, time.UTC); ok {
+		t.Fatalf("a good day breaks the stretch")
+	}
+}
+
+func TestScanAlertsOncePerStretch(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	var low []domain.TurnAffect
+	for d := 0; d < 3; d++ {
+		low = day(low, now, d, sad, sad)
+	}
+	store := &memoryStore{
+		turns:    map[string][]domain.TurnAffect{"u1": low, "u2": low},
+		settings: []domain.MoodAlertSettings{{UserID: "u2", Enabled: false}},
+		session:  map[string]domain.SessionInfo{"u1": {SessionID: "s1", UserID: "u1", TerminalID: "t1", SoulID: "soul_a"}},
+	}
+	writes, status, notify := &sessionWrites{}, &statusLog{}, &notifyLog{}
+	worker := NewWorker(Config{Days: 3, NegativeShare: 0.5}, store, writes, status, notify, nil, nil)
+	worker.now = func() time.Time { return now.Add(time.Hour) }
+
+	worker.Scan(context.Background())
+	worker.Scan(context.Background())
+	if len(notify.alerts) != 1 || notify.alerts[0].UserID != "u1" || notify.alerts[0].SessionID != "s1" {
+		t.Fatalf("expected one alert for u1 only, got %+v", notify.alerts)
+	}
+	if len(writes.rows) != 1 || writes.rows[0] != "s1|assistant|"+CheckInMessage {
+		t.Fatalf("unexpected session writes: %v", writes.rows)
+	}
+	if len(status.sent) != 1 || status.sent[0] != "t1|check_in|"+CheckInMessage {
+		t.Fatalf("unexpected check-in: %v", status.sent)
+	}
+
+	// The next day extends the same stretch; only a new one alerts again.
+	store.turns["u1"] = day(low, now, -1, sad, sad)
+	worker.now = func() time.Time { return now.Add(25 * time.Hour) }
+	worker.Scan(context.Background())
+	if len(notify.alerts) != 1 {
+		t.Fatalf("an overlapping stretch must not alert again: %+v", notify.alerts)
+	}
+	for d := -4; d <= -2; d++ {
+		store.turns["u1"] = day(store.turns["u1"], now, d, sad, sad)
+	}
+	worker.now = func() time.Time { return now.AddDate(0, 0, 4).Add(time.Hour) }
+	worker.Scan(context.Background())
+	if len(notify.alerts) != 2 || notify.alerts[1].FirstDay != "2026-03-12" {
+		t.Fatalf("expected a second alert for the new stretch, got %+v", notify.alerts)
+	}
+}
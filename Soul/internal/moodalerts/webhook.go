@@ -0,0 +1,54 @@
+package moodalerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// WebhookNotifier POSTs each alert as JSON, for a chat gateway or a care
+// dashboard to pass on.
+type WebhookNotifier struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+func NewWebhookNotifier(url, token string, timeout time.Duration) *WebhookNotifier {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &WebhookNotifier{url: strings.TrimSpace(url), token: strings.TrimSpace(token), http: &http.Client{Timeout: timeout}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, alert domain.MoodAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("mood alert webhook status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package mqtt
+
+import (
+	"fmt"
+	"log/slog"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+type BrokerConfig struct {
+	Addr     string
+	Username string
+	Password string
+}
+
+// EmbeddedBroker runs an in-process MQTT broker so a single soul-server binary
+// can serve terminals without a separate Mosquitto deployment.
+type EmbeddedBroker struct {
+	server *mochi.Server
+}
+
+func StartEmbeddedBroker(cfg BrokerConfig, logger *slog.Logger) (*EmbeddedBroker, error) {
+	if cfg.Addr == "" {
+		cfg.Addr = ":1883"
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	server := mochi.New(&mochi.Options{
+		Logger: logger.With("component", "mqtt-broker"),
+	})
+	var err error
+	if cfg.Username != "" {
+		err = server.AddHook(new(auth.Hook), &auth.Options{
+			Ledger: &auth.Ledger{
+				Auth: auth.AuthRules{{Username: auth.RString(cfg.Username), Password: auth.RString(cfg.Password), Allow: true}},
+				ACL:  auth.ACLRules{{}},
+			},
+		})
+	} else {
+		err = server.AddHook(new(auth.AllowHook), nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("configure embedded broker auth: %w", err)
+	}
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "tcp", Address: cfg.Addr})); err != nil {
+		return nil, fmt.Errorf("listen embedded broker on %s: %w", cfg.Addr, err)
+	}
+	if err := server.Serve(); err != nil {
+		return nil, fmt.Errorf("start embedded broker: %w", err)
+	}
+	return &EmbeddedBroker{server: server}, nil
+}
+
+func (b *EmbeddedBroker) Close() error {
+	return b.server.Close()
+}
@@ -22,38 +22,98 @@ type HubConfig struct {
 	Username    string
 	Password    string
 	TopicPrefix string
+	// HeartbeatTimeout is how long a terminal may stay silent before it is
+	// declared offline; zero disables the liveness monitor.
+	HeartbeatTimeout time.Duration
 }
 
 type Hub struct {
-	cfg          HubConfig
-	client       paho.Client
-	registry     *skills.Registry
-	soulResolver SoulResolver
-	logger       *slog.Logger
+	cfg           HubConfig
+	client        paho.Client
+	registry      *skills.Registry
+	soulResolver  SoulResolver
+	intentResults IntentResultSink
+	logger        *slog.Logger
 
 	pendingMu sync.Mutex
-	pending   map[string]chan domain.InvokeResult
+	pending   map[string]pendingInvoke
+
+	shadow   *shadowStore
+	liveness *livenessTracker
+	out      *publisher
+
+	// reconnecting marks terminals that announced online and have not
+	// reported skills since; their next skill report fires onReconnect.
+	reconnectMu  sync.Mutex
+	reconnecting map[string]bool
+	onReconnect  func(terminalID string)
+	onConfigAck  func(ack domain.TerminalConfigAck)
+	onLiveness   func(event domain.TerminalLivenessEvent)
 }
 
+// pendingInvoke is an invoke waiting for the terminal's result.
+type pendingInvoke struct {
+	terminalID string
+	ch         chan domain.InvokeResult
+}
+
+// publishAckTimeout bounds how long a publish waits for the broker's
+// acknowledgement.
+const publishAckTimeout = 5 * time.Second
+
 type statusEventPayload struct {
-	Status    string `json:"status"`
-	Message   string `json:"message,omitempty"`
-	SessionID string `json:"session_id,omitempty"`
-	TS        string `json:"ts"`
+	Status         string `json:"status"`
+	Message        string `json:"message,omitempty"`
+	SessionID      string `json:"session_id,omitempty"`
+	CatalogVersion int64  `json:"catalog_version,omitempty"`
+	TS             string `json:"ts"`
 }
 
 type SoulResolver interface {
 	ResolveOrCreateSoul(ctx context.Context, terminalID, soulHint string) (string, error)
 }
 
-func NewHub(cfg HubConfig, registry *skills.Registry, soulResolver SoulResolver, logger *slog.Logger) *Hub {
-	return &Hub{
-		cfg:          cfg,
-		registry:     registry,
-		soulResolver: soulResolver,
-		logger:       logger,
-		pending:      make(map[string]chan domain.InvokeResult),
-	}
+// IntentResultSink receives the intent_result reports terminals publish.
+type IntentResultSink interface {
+	RecordIntentResults(ctx context.Context, payload domain.IntentResultPayload) error
+}
+
+// NewHub builds a Hub; intentResults may be nil, in which case intent results
+// are only logged.
+func NewHub(cfg HubConfig, registry *skills.Registry, soulResolver SoulResolver, intentResults IntentResultSink, logger *slog.Logger) *Hub {
+	h := &Hub{
+		cfg:           cfg,
+		registry:      registry,
+		soulResolver:  soulResolver,
+		intentResults: intentResults,
+		logger:        logger,
+		pending:       make(map[string]pendingInvoke),
+		shadow:        newShadowStore(),
+		liveness:      newLivenessTracker(),
+		reconnecting:  make(map[string]bool),
+	}
+	h.out = newPublisher(h.sendNow)
+	return h
+}
+
+// OnReconnect registers fn to run, on its own goroutine, once a terminal
+// that came online has reported its skills and can take invokes again. It
+// must be set before Start.
+func (h *Hub) OnReconnect(fn func(terminalID string)) {
+	h.onReconnect = fn
+}
+
+// OnConfigAck registers fn to receive, on its own goroutine, the
+// config_ack reports terminals publish. It must be set before Start.
+func (h *Hub) OnConfigAck(fn func(ack domain.TerminalConfigAck)) {
+	h.onConfigAck = fn
+}
+
+// OnLiveness registers fn to receive, on its own goroutine, an event when a
+// terminal's heartbeats stop for longer than HeartbeatTimeout and when they
+// resume. It must be set before Start.
+func (h *Hub) OnLiveness(fn func(event domain.TerminalLivenessEvent)) {
+	h.onLiveness = fn
 }
 
 func (h *Hub) Start(ctx context.Context) error {
@@ -77,9 +137,13 @@ func (h *Hub) Start(ctx context.Context) error {
 		return token.Error()
 	}
 
+	go h.out.run(ctx)
 	if err := h.subscribeHandlers(); err != nil {
 		return err
 	}
+	if h.cfg.HeartbeatTimeout > 0 {
+		go h.watchLiveness(ctx)
+	}
 
 	go func() {
 		<-ctx.Done()
@@ -105,6 +169,12 @@ func (h *Hub) subscribeHandlers() error {
 	if token := h.client.Subscribe(TopicTerminalResult(h.cfg.TopicPrefix), 1, h.handleInvokeResult); token.Wait() && token.Error() != nil {
 		return token.Error()
 	}
+	if token := h.client.Subscribe(TopicTerminalIntentResult(h.cfg.TopicPrefix), 1, h.handleIntentResult); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
+	if token := h.client.Subscribe(TopicTerminalConfigAck(h.cfg.TopicPrefix), 1, h.handleConfigAck); token.Wait() && token.Error() != nil {
+		return token.Error()
+	}
 	return nil
 }
 
@@ -144,9 +214,32 @@ func (h *Hub) handleSkillReport(_ paho.Client, msg paho.Message) {
 	}
 
 	h.registry.SetSkills(terminalID, soulID, report.SkillVersion, report.Skills)
+	if report.Output != nil {
+		h.registry.SetOutputCapabilities(terminalID, report.Output)
+	}
+	if report.Capabilities != nil {
+		h.registry.SetCapabilities(terminalID, report.Capabilities)
+	}
+	h.registry.SetVersions(terminalID, report.FirmwareVersion, report.BundleVersions)
 	h.registry.SetOnline(terminalID, true)
+	h.heard(terminalID)
 	state, _ := h.registry.GetState(terminalID)
-	h.logger.Info("skills updated", "terminal_id", terminalID, "soul_id", soulID, "skill_version", state.SkillVersion, "skill_count", len(report.Skills))
+	h.logger.Info("skills updated", "terminal_id", terminalID, "soul_id", soulID, "skill_version", state.SkillVersion, "skill_count", len(report.Skills), "firmware_version", report.FirmwareVersion)
+	if versions, ok := h.registry.Versions(terminalID); ok && versions.UpgradeNeeded {
+		h.logger.Warn("terminal upgrade needed", "terminal_id", terminalID, "firmware_version", versions.FirmwareVersion, "upgrades", len(versions.Upgrades))
+		h.publishStatusAsync(terminalID, statusEventPayload{
+			Status:  domain.TerminalStatusUpgradeNeeded,
+			Message: skills.DescribeUpgrades(versions.Upgrades),
+		})
+	}
+
+	h.reconnectMu.Lock()
+	reconnected := h.reconnecting[terminalID]
+	delete(h.reconnecting, terminalID)
+	h.reconnectMu.Unlock()
+	if reconnected && h.onReconnect != nil {
+		go h.onReconnect(terminalID)
+	}
 }
 
 func (h *Hub) handleIntentCatalog(_ paho.Client, msg paho.Message) {
@@ -187,9 +280,30 @@ func (h *Hub) handleIntentCatalog(_ paho.Client, msg paho.Message) {
 		}
 	}
 
-	h.registry.SetIntentCatalog(terminalID, soulID, report.CatalogVersion, report.IntentCatalog)
-	state, _ := h.registry.GetState(terminalID)
-	h.logger.Info("intent catalog updated", "terminal_id", terminalID, "soul_id", soulID, "catalog_version", state.CatalogVersion, "intent_count", len(report.IntentCatalog))
+	update := h.registry.SetIntentCatalog(terminalID, soulID, report.CatalogVersion, report.IntentCatalog)
+	if !update.Applied {
+		h.logger.Warn("stale intent catalog rejected", "terminal_id", terminalID, "catalog_version", report.CatalogVersion, "current_version", update.Version)
+		h.publishStatusAsync(terminalID, statusEventPayload{
+			Status:         "catalog_rejected",
+			Message:        fmt.Sprintf("意图目录版本 %d 落后于已生效版本 %d，已忽略。", report.CatalogVersion, update.Version),
+			CatalogVersion: update.Version,
+		})
+		return
+	}
+	h.logger.Info("intent catalog updated",
+		"terminal_id", terminalID,
+		"soul_id", soulID,
+		"catalog_version", update.Version,
+		"previous_version", update.PreviousVersion,
+		"intent_count", len(report.IntentCatalog),
+		"added", update.Added,
+		"removed", update.Removed,
+	)
+	h.publishStatusAsync(terminalID, statusEventPayload{
+		Status:         "catalog_applied",
+		Message:        fmt.Sprintf("意图目录已生效：新增 %d 项，移除 %d 项。", len(update.Added), len(update.Removed)),
+		CatalogVersion: update.Version,
+	})
 }
 
 func (h *Hub) handleOnline(_ paho.Client, msg paho.Message) {
@@ -210,6 +324,22 @@ func (h *Hub) handleOnline(_ paho.Client, msg paho.Message) {
 		}
 	}
 	h.registry.SetOnline(terminalID, online)
+	if !online {
+		h.liveness.forget(terminalID)
+		if failed := h.failPending(terminalID); failed > 0 {
+			h.logger.Warn("invokes undeliverable, terminal went offline", "terminal_id", terminalID, "undeliverable", failed)
+		}
+	}
+	if online {
+		h.heard(terminalID)
+		// Terminals announce online on every connect, power cycles
+		// included, so what they showed before can no longer be assumed.
+		h.shadow.reset(terminalID)
+		h.shadow.online(terminalID)
+		h.reconnectMu.Lock()
+		h.reconnecting[terminalID] = true
+		h.reconnectMu.Unlock()
+	}
 	h.logger.Info("terminal online status", "terminal_id", terminalID, "online", online)
 }
 
@@ -220,6 +350,41 @@ func (h *Hub) handleHeartbeat(_ paho.Client, msg paho.Message) {
 		return
 	}
 	h.registry.SetOnline(terminalID, true)
+	h.heard(terminalID)
+	if vitals, ok := parseHeartbeat(msg.Payload()); ok {
+		h.shadow.vitals(terminalID, vitals)
+	}
+}
+
+// heartbeatPayload is the optional JSON body of a heartbeat; a plain "1"
+// heartbeat reports nothing.
+type heartbeatPayload struct {
+	BatteryPercent *int  `json:"battery_percent"`
+	Charging       *bool `json:"charging"`
+	UptimeSeconds  int64 `json:"uptime_seconds"`
+}
+
+// parseHeartbeat reads the vitals a heartbeat carries; false when it
+// carries none. A battery level outside 0-100 is dropped.
+func parseHeartbeat(raw []byte) (domain.TerminalVitals, bool) {
+	var payload heartbeatPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return domain.TerminalVitals{}, false
+	}
+	if b := payload.BatteryPercent; b != nil && (*b < 0 || *b > 100) {
+		payload.BatteryPercent = nil
+	}
+	if payload.UptimeSeconds < 0 {
+		payload.UptimeSeconds = 0
+	}
+	if payload.BatteryPercent == nil && payload.Charging == nil && payload.UptimeSeconds == 0 {
+		return domain.TerminalVitals{}, false
+	}
+	return domain.TerminalVitals{
+		BatteryPercent: payload.BatteryPercent,
+		Charging:       payload.Charging,
+		UptimeSeconds:  payload.UptimeSeconds,
+	}, true
 }
 
 func (h *Hub) handleInvokeResult(_ paho.Client, msg paho.Message) {
@@ -238,28 +403,99 @@ func (h *Hub) handleInvokeResult(_ paho.Client, msg paho.Message) {
 	}
 
 	h.pendingMu.Lock()
-	ch, ok := h.pending[result.RequestID]
+	p, ok := h.pending[result.RequestID]
 	h.pendingMu.Unlock()
 	if !ok {
 		return
 	}
 
 	select {
-	case ch <- result:
+	case p.ch <- result:
 	default:
 	}
 }
 
-func (h *Hub) InvokeSkill(ctx context.Context, terminalID, skill string, args json.RawMessage) (domain.InvokeResult, error) {
+func (h *Hub) handleIntentResult(_ paho.Client, msg paho.Message) {
+	terminalID, err := ParseTerminalID(msg.Topic(), h.cfg.TopicPrefix)
+	if err != nil {
+		h.logger.Warn("skip invalid intent result topic", "topic", msg.Topic(), "error", err)
+		return
+	}
+
+	var payload domain.IntentResultPayload
+	if err := json.Unmarshal(msg.Payload(), &payload); err != nil {
+		h.logger.Warn("invalid intent result payload", "terminal_id", terminalID, "error", err)
+		return
+	}
+	if strings.TrimSpace(payload.TerminalID) == "" {
+		payload.TerminalID = terminalID
+	}
+	if payload.TerminalID != terminalID {
+		h.logger.Warn("intent result terminal mismatch", "topic_terminal", terminalID, "payload_terminal", payload.TerminalID)
+		return
+	}
+	failed := 0
+	for _, item := range payload.Results {
+		if !item.OK {
+			failed++
+		}
+	}
+	h.logger.Info("intent result received", "terminal_id", terminalID, "request_id", payload.RequestID, "count", len(payload.Results), "failed", failed)
+	h.shadow.intentResults(terminalID, payload)
+	if h.intentResults == nil {
+		return
+	}
+
+	// Paho delivers messages in order on one goroutine; keep slow writes off it.
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := h.intentResults.RecordIntentResults(ctx, payload); err != nil {
+			h.logger.Warn("record intent result failed", "terminal_id", terminalID, "request_id", payload.RequestID, "error", err)
+		}
+	}()
+}
+
+func (h *Hub) handleConfigAck(_ paho.Client, msg paho.Message) {
+	terminalID, err := ParseTerminalID(msg.Topic(), h.cfg.TopicPrefix)
+	if err != nil {
+		h.logger.Warn("skip invalid config ack topic", "topic", msg.Topic(), "error", err)
+		return
+	}
+
+	var ack domain.TerminalConfigAck
+	if err := json.Unmarshal(msg.Payload(), &ack); err != nil {
+		h.logger.Warn("invalid config ack payload", "terminal_id", terminalID, "error", err)
+		return
+	}
+	if strings.TrimSpace(ack.TerminalID) == "" {
+		ack.TerminalID = terminalID
+	}
+	if ack.TerminalID != terminalID {
+		h.logger.Warn("config ack terminal mismatch", "topic_terminal", terminalID, "payload_terminal", ack.TerminalID)
+		return
+	}
+	h.logger.Info("config ack received", "terminal_id", terminalID, "request_id", ack.RequestID, "ok", ack.OK)
+	if h.onConfigAck != nil {
+		go h.onConfigAck(ack)
+	}
+}
+
+func (h *Hub) InvokeSkill(ctx context.Context, terminalID, skill string, args json.RawMessage) (result domain.InvokeResult, err error) {
 	if len(args) == 0 {
 		args = json.RawMessage(`{}`)
 	}
 
+	priority := domain.InvokePriorityNormal
+	if def, ok := h.registry.FindSkill(terminalID, skill); ok && def.Priority == domain.InvokePriorityRealtime {
+		priority = domain.InvokePriorityRealtime
+	}
 	requestID := uuid.NewString()
 	payload := domain.InvokeRequest{
 		RequestID: requestID,
 		Skill:     skill,
 		Arguments: args,
+		Priority:  priority,
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -268,7 +504,7 @@ func (h *Hub) InvokeSkill(ctx context.Context, terminalID, skill string, args js
 
 	resultCh := make(chan domain.InvokeResult, 1)
 	h.pendingMu.Lock()
-	h.pending[requestID] = resultCh
+	h.pending[requestID] = pendingInvoke{terminalID: terminalID, ch: resultCh}
 	h.pendingMu.Unlock()
 	defer func() {
 		h.pendingMu.Lock()
@@ -277,9 +513,17 @@ func (h *Hub) InvokeSkill(ctx context.Context, terminalID, skill string, args js
 	}()
 
 	topic := TopicInvoke(h.cfg.TopicPrefix, terminalID, requestID)
-	if token := h.client.Publish(topic, 1, false, body); token.Wait() && token.Error() != nil {
-		return domain.InvokeResult{}, token.Error()
+	if err := h.publish(ctx, priority, topic, body); err != nil {
+		return domain.InvokeResult{}, err
 	}
+	h.shadow.invokeSent(terminalID, requestID, skill, args)
+	defer func() {
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		h.shadow.invokeDone(terminalID, requestID, skill, args, errMsg)
+	}()
 
 	select {
 	case <-ctx.Done():
@@ -297,16 +541,26 @@ func (h *Hub) InvokeSkill(ctx context.Context, terminalID, skill string, args js
 	}
 }
 
-func (h *Hub) PublishStatus(_ context.Context, terminalID, status, message, sessionID string) error {
-	if h.client == nil {
-		return fmt.Errorf("mqtt client is not started")
-	}
-	payload := statusEventPayload{
+func (h *Hub) PublishStatus(ctx context.Context, terminalID, status, message, sessionID string) error {
+	return h.publishStatusEvent(ctx, terminalID, statusEventPayload{
 		Status:    strings.TrimSpace(status),
 		Message:   strings.TrimSpace(message),
 		SessionID: strings.TrimSpace(sessionID),
-		TS:        time.Now().UTC().Format(time.RFC3339),
-	}
+	})
+}
+
+// publishStatusAsync is used from subscription callbacks, where waiting on a
+// publish token would block paho's ordered message router.
+func (h *Hub) publishStatusAsync(terminalID string, payload statusEventPayload) {
+	go func() {
+		if err := h.publishStatusEvent(context.Background(), terminalID, payload); err != nil {
+			h.logger.Warn("publish status failed", "status", payload.Status, "terminal_id", terminalID, "error", err)
+		}
+	}()
+}
+
+func (h *Hub) publishStatusEvent(ctx context.Context, terminalID string, payload statusEventPayload) error {
+	payload.TS = time.Now().UTC().Format(time.RFC3339)
 	if payload.Status == "" {
 		payload.Status = "unknown"
 	}
@@ -314,36 +568,127 @@ func (h *Hub) PublishStatus(_ context.Context, terminalID, status, message, sess
 	if err != nil {
 		return err
 	}
-	topic := TopicStatus(h.cfg.TopicPrefix, terminalID)
-	token := h.client.Publish(topic, 1, false, body)
-	token.Wait()
-	return token.Error()
+	if err := h.publish(ctx, domain.InvokePriorityRealtime, TopicStatus(h.cfg.TopicPrefix, terminalID), body); err != nil {
+		return err
+	}
+	h.shadow.status(terminalID, payload.Status, payload.Message)
+	return nil
 }
 
-func (h *Hub) PublishEmotionUpdate(_ context.Context, terminalID string, payload domain.EmotionUpdatePayload) error {
-	if h.client == nil {
-		return fmt.Errorf("mqtt client is not started")
+// PublishEmotionUpdate drops the update while the terminal is asleep: the
+// face is not shown then, and the next update after waking carries the
+// current state anyway.
+func (h *Hub) PublishEmotionUpdate(ctx context.Context, terminalID string, payload domain.EmotionUpdatePayload) error {
+	if h.shadow.asleep(terminalID) {
+		return nil
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
-	topic := TopicEmotionUpdate(h.cfg.TopicPrefix, terminalID)
-	token := h.client.Publish(topic, 1, false, body)
-	token.Wait()
-	return token.Error()
+	if err := h.publish(ctx, domain.InvokePriorityRealtime, TopicEmotionUpdate(h.cfg.TopicPrefix, terminalID), body); err != nil {
+		return err
+	}
+	h.shadow.emotion(terminalID, payload)
+	return nil
 }
 
-func (h *Hub) PublishIntentAction(_ context.Context, terminalID string, payload domain.IntentActionPayload) error {
-	if h.client == nil {
-		return fmt.Errorf("mqtt client is not started")
+// PublishGateLock goes out on the realtime lane, so the terminal can show
+// why actions are refused as soon as the lock moves.
+func (h *Hub) PublishGateLock(ctx context.Context, terminalID string, event domain.GateLockEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := h.publish(ctx, domain.InvokePriorityRealtime, TopicGateLock(h.cfg.TopicPrefix, terminalID), body); err != nil {
+		return err
 	}
+	h.shadow.gateLock(terminalID, event.Locked)
+	return nil
+}
+
+// PublishIntentAction goes out on the realtime lane: intent actions are the
+// robot's immediate, mostly physical, reaction.
+func (h *Hub) PublishIntentAction(ctx context.Context, terminalID string, payload domain.IntentActionPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	// Record before publishing: a fast terminal may report back before
+	// publish returns.
+	h.shadow.intentsSent(terminalID, payload)
+	if err := h.publish(ctx, domain.InvokePriorityRealtime, TopicIntentAction(h.cfg.TopicPrefix, terminalID), body); err != nil {
+		h.shadow.dropRequest(terminalID, payload.RequestID)
+		return err
+	}
+	return nil
+}
+
+// TerminalState returns the terminal's shadow state merged with its
+// registry presence; false when the hub has never heard of the terminal.
+func (h *Hub) TerminalState(terminalID string) (domain.TerminalShadow, bool) {
+	out, known := h.shadow.snapshot(terminalID)
+	if !known {
+		out = domain.TerminalShadow{TerminalID: terminalID, Pending: []domain.PendingAction{}}
+	}
+	if state, ok := h.registry.GetState(terminalID); ok {
+		known = true
+		out.SoulID = state.SoulID
+		out.Online = state.Online
+		if !state.LastUpdated.IsZero() {
+			out.LastSeenAt = state.LastUpdated.UTC().Format(time.RFC3339)
+		}
+	}
+	if at, ok := h.liveness.seenAt(terminalID); ok {
+		out.LastSeenAt = at.UTC().Format(time.RFC3339)
+	}
+	return out, known
+}
+
+// PublishConfig goes out on the normal lane: settings are not urgent and
+// must not hold up expressions.
+func (h *Hub) PublishConfig(ctx context.Context, terminalID string, payload domain.ConfigPushPayload) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
-	topic := TopicIntentAction(h.cfg.TopicPrefix, terminalID)
+	return h.publish(ctx, domain.InvokePriorityNormal, TopicConfig(h.cfg.TopicPrefix, terminalID), body)
+}
+
+// PublishPower goes out on the realtime lane, so a wake lands before the
+// reply that follows it.
+func (h *Hub) PublishPower(ctx context.Context, terminalID string, cmd domain.PowerCommand) error {
+	body, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	if err := h.publish(ctx, domain.InvokePriorityRealtime, TopicPower(h.cfg.TopicPrefix, terminalID), body); err != nil {
+		return err
+	}
+	h.shadow.power(terminalID, cmd.Command == domain.PowerCommandSleep)
+	return nil
+}
+
+// publish sends through the hub's two-lane publisher.
+func (h *Hub) publish(ctx context.Context, priority, topic string, body []byte) error {
+	if h.client == nil {
+		return fmt.Errorf("mqtt client is not started")
+	}
+	return h.out.publish(ctx, priority, topic, body)
+}
+
+func (h *Hub) sendNow(topic string, body []byte) func(context.Context) error {
 	token := h.client.Publish(topic, 1, false, body)
-	token.Wait()
-	return token.Error()
+	return func(ctx context.Context) error {
+		timer := time.NewTimer(publishAckTimeout)
+		defer timer.Stop()
+		select {
+		case <-token.Done():
+			return token.Error()
+		case <-timer.C:
+			return fmt.Errorf("publish %s: ack timeout", topic)
+		case <-ctx.Done():
+			return fmt.Errorf("publish %s: %w", topic, ctx.Err())
+		}
+	}
 }
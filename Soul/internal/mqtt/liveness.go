@@ -0,0 +1,149 @@
+package mqtt
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// undeliverableError is what invokes still waiting on a terminal fail with
+// once the terminal is found offline.
+const undeliverableError = "terminal offline: invoke undeliverable"
+
+// livenessTracker remembers when each terminal was last heard from and
+// which ones the hub has declared offline for missing heartbeats.
+type livenessTracker struct {
+	mu       sync.Mutex
+	lastSeen map[string]time.Time
+	down     map[string]bool
+	now      func() time.Time
+}
+
+func newLivenessTracker() *livenessTracker {
+	return &livenessTracker{lastSeen: make(map[string]time.Time), down: make(map[string]bool), now: time.Now}
+}
+
+// touch records that the terminal was heard from and reports whether it
+// had been declared offline.
+func (l *livenessTracker) touch(terminalID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastSeen[terminalID] = l.now()
+	wasDown := l.down[terminalID]
+	delete(l.down, terminalID)
+	return wasDown
+}
+
+// forget stops watching a terminal that said goodbye itself.
+func (l *livenessTracker) forget(terminalID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.lastSeen, terminalID)
+	delete(l.down, terminalID)
+}
+
+func (l *livenessTracker) seenAt(terminalID string) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	at, ok := l.lastSeen[terminalID]
+	return at, ok
+}
+
+// expire declares offline, and returns sorted, the terminals silent for
+// longer than timeout; each one is returned once until it is heard again.
+func (l *livenessTracker) expire(timeout time.Duration) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var out []string
+	for terminalID, at := range l.lastSeen {
+		if l.down[terminalID] || now.Sub(at) <= timeout {
+			continue
+		}
+		l.down[terminalID] = true
+		out = append(out, terminalID)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// watchLiveness checks for silent terminals a few times per timeout until
+// ctx is done.
+func (h *Hub) watchLiveness(ctx context.Context) {
+	interval := h.cfg.HeartbeatTimeout / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.checkLiveness()
+		}
+	}
+}
+
+// checkLiveness flips terminals whose heartbeats stopped to offline, fails
+// the invokes waiting on them and emits an offline event for each.
+func (h *Hub) checkLiveness() {
+	for _, terminalID := range h.liveness.expire(h.cfg.HeartbeatTimeout) {
+		h.registry.SetOnline(terminalID, false)
+		failed := h.failPending(terminalID)
+		h.shadow.dropPending(terminalID)
+		h.logger.Warn("terminal heartbeat lost", "terminal_id", terminalID, "timeout", h.cfg.HeartbeatTimeout, "undeliverable", failed)
+		h.emitLiveness(terminalID, domain.TerminalLivenessOffline, failed)
+	}
+}
+
+// heard marks the terminal alive and emits an online event when it had
+// been declared offline.
+func (h *Hub) heard(terminalID string) {
+	if h.liveness.touch(terminalID) {
+		h.logger.Info("terminal heartbeat resumed", "terminal_id", terminalID)
+		h.emitLiveness(terminalID, domain.TerminalLivenessOnline, 0)
+	}
+}
+
+// failPending fails every invoke still waiting on the terminal and returns
+// how many there were.
+func (h *Hub) failPending(terminalID string) int {
+	h.pendingMu.Lock()
+	defer h.pendingMu.Unlock()
+	failed := 0
+	for requestID, p := range h.pending {
+		if p.terminalID != terminalID {
+			continue
+		}
+		select {
+		case p.ch <- domain.InvokeResult{RequestID: requestID, OK: false, Error: undeliverableError}:
+			failed++
+		default:
+		}
+	}
+	return failed
+}
+
+func (h *Hub) emitLiveness(terminalID, status string, undeliverable int) {
+	if h.onLiveness == nil {
+		return
+	}
+	event := domain.TerminalLivenessEvent{
+		TerminalID:    terminalID,
+		Status:        status,
+		Undeliverable: undeliverable,
+		TS:            time.Now().UTC().Format(time.RFC3339),
+	}
+	if state, ok := h.registry.GetState(terminalID); ok {
+		event.SoulID = state.SoulID
+	}
+	if at, ok := h.liveness.seenAt(terminalID); ok {
+		event.LastSeenAt = at.UTC().Format(time.RFC3339)
+	}
+	go h.onLiveness(event)
+}
@@ -0,0 +1,60 @@
+package mqtt
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/skills"
+)
+
+func TestSilentTerminalGoesOfflineAndFailsPendingInvokes(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	registry := skills.NewRegistry(time.Hour)
+	h := NewHub(HubConfig{HeartbeatTimeout: 30 * time.Second}, registry, nil, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	h.liveness.now = func() time.Time { return now }
+	events := make(chan domain.TerminalLivenessEvent, 4)
+	h.OnLiveness(func(event domain.TerminalLivenessEvent) { events <- event })
+
+	registry.SetOnline("t1", true)
+	h.heard("t1")
+	h.heard("t2")
+	waiting := make(chan domain.InvokeResult, 1)
+	other := make(chan domain.InvokeResult, 1)
+	h.pending["req-1"] = pendingInvoke{terminalID: "t1", ch: waiting}
+	h.pending["req-2"] = pendingInvoke{terminalID: "t2", ch: other}
+
+	now = now.Add(20 * time.Second)
+	h.heard("t2")
+	h.checkLiveness()
+	if len(events) != 0 {
+		t.Fatalf("terminals within the timeout must stay online")
+	}
+
+	now = now.Add(15 * time.Second)
+	h.checkLiveness()
+	event := <-events
+	if event.TerminalID != "t1" || event.Status != domain.TerminalLivenessOffline || event.Undeliverable != 1 || event.LastSeenAt != "2026-03-01T08:00:00Z" {
+		t.Fatalf("unexpected offline event %+v", event)
+	}
+	if res := <-waiting; res.OK || res.Error != undeliverableError {
+		t.Fatalf("pending invoke must fail as undeliverable, got %+v", res)
+	}
+	if len(other) != 0 {
+		t.Fatalf("invokes on live terminals must keep waiting")
+	}
+	if state, _ := registry.GetState("t1"); state.Online {
+		t.Fatalf("silent terminal must be flipped offline")
+	}
+	h.checkLiveness()
+	if len(events) != 0 {
+		t.Fatalf("offline must be reported once")
+	}
+
+	h.heard("t1")
+	if event := <-events; event.TerminalID != "t1" || event.Status != domain.TerminalLivenessOnline {
+		t.Fatalf("unexpected online event %+v", event)
+	}
+}
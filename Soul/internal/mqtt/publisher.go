@@ -0,0 +1,88 @@
+package mqtt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"soul/internal/domain"
+)
+
+// publisher serializes the hub's outgoing messages through two lanes. The
+// realtime lane (expressions, head motions, intent actions, status) is always
+// drained first, so a burst of slow-skill invokes cannot delay the robot's
+// visible reaction. The lanes only hand messages to the client in order; each
+// caller waits for its own broker acknowledgement, so a terminal whose acks
+// are slow does not hold up everyone else's messages.
+type publisher struct {
+	realtime chan outgoing
+	normal   chan outgoing
+	// send hands a message to the client without waiting for the broker and
+	// returns a func that waits for its acknowledgement.
+	send func(topic string, body []byte) (ack func(context.Context) error)
+	// stopped is closed when run returns, failing any later publish.
+	stopped chan struct{}
+}
+
+var errPublisherStopped = errors.New("mqtt publisher stopped")
+
+type outgoing struct {
+	topic string
+	body  []byte
+	sent  chan func(context.Context) error
+}
+
+func newPublisher(send func(topic string, body []byte) (ack func(context.Context) error)) *publisher {
+	return &publisher{
+		realtime: make(chan outgoing, 64),
+		normal:   make(chan outgoing, 256),
+		send:     send,
+		stopped:  make(chan struct{}),
+	}
+}
+
+func (p *publisher) run(ctx context.Context) {
+	defer close(p.stopped)
+	for {
+		// Prefer the realtime lane whenever it has anything queued.
+		select {
+		case msg := <-p.realtime:
+			msg.sent <- p.send(msg.topic, msg.body)
+			continue
+		default:
+		}
+		select {
+		case msg := <-p.realtime:
+			msg.sent <- p.send(msg.topic, msg.body)
+		case msg := <-p.normal:
+			msg.sent <- p.send(msg.topic, msg.body)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// publish queues a message on its lane and waits until the broker has
+// acknowledged it.
+func (p *publisher) publish(ctx context.Context, priority, topic string, body []byte) error {
+	lane := p.normal
+	if priority == domain.InvokePriorityRealtime {
+		lane = p.realtime
+	}
+	msg := outgoing{topic: topic, body: body, sent: make(chan func(context.Context) error, 1)}
+	select {
+	case lane <- msg:
+	case <-ctx.Done():
+		return fmt.Errorf("queue %s: %w", topic, ctx.Err())
+	case <-p.stopped:
+		return errPublisherStopped
+	}
+	select {
+	case ack := <-msg.sent:
+		return ack(ctx)
+	case <-ctx.Done():
+		return fmt.Errorf("publish %s: %w", topic, ctx.Err())
+	case <-p.stopped:
+		return errPublisherStopped
+	}
+}
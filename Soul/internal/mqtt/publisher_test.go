@@ -0,0 +1,109 @@
+package mqtt
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestPublisherDrainsRealtimeLaneFirst(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	holding, release := make(chan struct{}), make(chan struct{})
+	p := newPublisher(func(topic string, _ []byte) func(context.Context) error {
+		if topic == "invoke/slow_1" {
+			close(holding)
+			<-release // hold the publisher while both lanes fill up
+		}
+		mu.Lock()
+		sent = append(sent, topic)
+		mu.Unlock()
+		return acked
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.run(ctx)
+
+	var wg sync.WaitGroup
+	publish := func(priority, topic string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := p.publish(ctx, priority, topic, nil); err != nil {
+				t.Errorf("publish %s: %v", topic, err)
+			}
+		}()
+	}
+	publish(domain.InvokePriorityNormal, "invoke/slow_1")
+	<-holding
+	publish(domain.InvokePriorityNormal, "invoke/slow_2")
+	waitQueued(t, p.normal, 1)
+	publish(domain.InvokePriorityRealtime, "intent_action/nod")
+	waitQueued(t, p.realtime, 1)
+	close(release)
+	wg.Wait()
+
+	if got := strings.Join(sent, ","); got != "invoke/slow_1,intent_action/nod,invoke/slow_2" {
+		t.Fatalf("realtime message must overtake queued normal ones, got %s", got)
+	}
+
+	cancel()
+	<-p.stopped
+	if err := p.publish(context.Background(), domain.InvokePriorityRealtime, "status", nil); err != errPublisherStopped {
+		t.Fatalf("publish after stop must fail, got %v", err)
+	}
+}
+
+func TestPublisherDoesNotHoldLaneForAck(t *testing.T) {
+	stuck, handed := make(chan struct{}), make(chan struct{})
+	defer close(stuck)
+	p := newPublisher(func(topic string, _ []byte) func(context.Context) error {
+		if topic != "invoke/offline" {
+			return acked
+		}
+		close(handed)
+		return func(ctx context.Context) error {
+			select {
+			case <-stuck:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go p.run(ctx)
+
+	waiting := make(chan error, 1)
+	go func() { waiting <- p.publish(ctx, domain.InvokePriorityNormal, "invoke/offline", nil) }()
+	<-handed
+
+	pubCtx, pubCancel := context.WithTimeout(ctx, time.Second)
+	defer pubCancel()
+	if err := p.publish(pubCtx, domain.InvokePriorityNormal, "invoke/online", nil); err != nil {
+		t.Fatalf("a message waiting for its ack must not hold the lane: %v", err)
+	}
+	select {
+	case err := <-waiting:
+		t.Fatalf("publish returned before its ack: %v", err)
+	default:
+	}
+}
+
+func acked(context.Context) error { return nil }
+
+func waitQueued(t *testing.T, lane chan outgoing, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(lane) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("lane has %d messages, want %d", len(lane), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
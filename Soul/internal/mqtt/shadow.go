@@ -0,0 +1,388 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// pendingIntentTTL is how long an intent stays pending without an
+// intent_result; terminals are not required to report every intent.
+const pendingIntentTTL = time.Minute
+
+// cryingIntensity is the "高强度" of the protocol's emotion mapping at
+// which a sad user makes the robot cry instead of look unhappy.
+const cryingIntensity = 0.7
+
+const (
+	shadowSourceInvoke       = "invoke"
+	shadowSourceIntentAction = "intent_action"
+)
+
+// shadowStore keeps the hub's per-terminal shadow state. It only knows what
+// went through the hub, so it starts empty after a restart.
+type shadowStore struct {
+	mu        sync.Mutex
+	terminals map[string]*terminalShadow
+	now       func() time.Time
+}
+
+type terminalShadow struct {
+	state   domain.TerminalShadow
+	pending map[string]domain.PendingAction
+}
+
+func newShadowStore() *shadowStore {
+	return &shadowStore{terminals: make(map[string]*terminalShadow), now: time.Now}
+}
+
+// get returns the terminal's shadow, creating it; callers hold mu.
+func (s *shadowStore) get(terminalID string) *terminalShadow {
+	t, ok := s.terminals[terminalID]
+	if !ok {
+		t = &terminalShadow{
+			state:   domain.TerminalShadow{TerminalID: terminalID},
+			pending: make(map[string]domain.PendingAction),
+		}
+		s.terminals[terminalID] = t
+	}
+	return t
+}
+
+func (s *shadowStore) stamp() string {
+	return s.now().UTC().Format(time.RFC3339)
+}
+
+func (s *shadowStore) invokeSent(terminalID, requestID, skill string, args json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	t.pending[requestID] = domain.PendingAction{
+		RequestID: requestID,
+		Kind:      shadowSourceInvoke,
+		Skill:     skill,
+		Arguments: args,
+		SentAt:    s.stamp(),
+	}
+}
+
+// invokeDone settles an invoke; errMsg is empty when it succeeded.
+func (s *shadowStore) invokeDone(terminalID, requestID, skill string, args json.RawMessage, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	delete(t.pending, requestID)
+	s.apply(t, domain.ShadowAction{
+		Skill:     skill,
+		Arguments: args,
+		OK:        errMsg == "",
+		Error:     errMsg,
+		Source:    shadowSourceInvoke,
+		At:        s.stamp(),
+	})
+}
+
+func (s *shadowStore) intentsSent(terminalID string, payload domain.IntentActionPayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	at := s.stamp()
+	for _, in := range payload.Intents {
+		skill, args := intentSkillArgs(in)
+		t.pending[intentPendingKey(payload.RequestID, in.IntentID)] = domain.PendingAction{
+			RequestID: payload.RequestID,
+			Kind:      shadowSourceIntentAction,
+			IntentID:  in.IntentID,
+			Skill:     skill,
+			Arguments: args,
+			SentAt:    at,
+		}
+	}
+}
+
+func (s *shadowStore) intentResults(terminalID string, payload domain.IntentResultPayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	at := s.stamp()
+	for _, item := range payload.Results {
+		key := intentPendingKey(payload.RequestID, item.IntentID)
+		sent := t.pending[key]
+		delete(t.pending, key)
+		skill := strings.TrimSpace(item.Skill)
+		if skill == "" {
+			skill = sent.Skill
+		}
+		if skill == "" {
+			continue
+		}
+		s.apply(t, domain.ShadowAction{
+			Skill:     skill,
+			Arguments: sent.Arguments,
+			OK:        item.OK,
+			Error:     item.Error,
+			Source:    shadowSourceIntentAction,
+			At:        at,
+		})
+	}
+}
+
+// reset forgets what the terminal was showing and the calls that put it
+// there, keeping emotion, lock and pending actions. A terminal boots
+// awake.
+func (s *shadowStore) reset(terminalID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.terminals[terminalID]
+	if !ok {
+		return
+	}
+	t.state.Light = nil
+	t.state.Motion = nil
+	t.state.Expression = ""
+	t.state.Skills = nil
+	t.state.Asleep = false
+	t.state.UpdatedAt = s.stamp()
+}
+
+// dropRequest forgets the pending intents of an intent_action that could
+// not be published.
+func (s *shadowStore) dropRequest(terminalID, requestID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	for key, p := range t.pending {
+		if p.RequestID == requestID {
+			delete(t.pending, key)
+		}
+	}
+}
+
+// dropPending forgets the pending intents of a terminal that went
+// offline; its pending invokes settle through invokeDone.
+func (s *shadowStore) dropPending(terminalID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.terminals[terminalID]
+	if !ok {
+		return
+	}
+	for key, p := range t.pending {
+		if p.Kind == shadowSourceIntentAction {
+			delete(t.pending, key)
+		}
+	}
+}
+
+// apply records a settled skill call and, when it succeeded, moves the
+// parts of the state the skill controls.
+func (s *shadowStore) apply(t *terminalShadow, action domain.ShadowAction) {
+	if t.state.Skills == nil {
+		t.state.Skills = make(map[string]domain.ShadowAction)
+	}
+	t.state.Skills[action.Skill] = action
+	t.state.UpdatedAt = action.At
+	if !action.OK {
+		return
+	}
+	var args map[string]any
+	_ = json.Unmarshal(action.Arguments, &args)
+	switch action.Skill {
+	case "control_light":
+		light := domain.ShadowLight{UpdatedAt: action.At}
+		if t.state.Light != nil {
+			light.Color = t.state.Light.Color
+		}
+		switch mapString(args, "mode") {
+		case "off":
+		case "set_color":
+			light.On = true
+			if color := mapString(args, "color"); color != "" {
+				light.Color = color
+			}
+		default:
+			light.On = true
+		}
+		t.state.Light = &light
+	case "set_head_motion", "stop_motion":
+		motion := action
+		t.state.Motion = &motion
+	case "set_expression":
+		if expression := mapString(args, "expression"); expression != "" {
+			t.state.Expression = expression
+		}
+	}
+}
+
+func (s *shadowStore) emotion(terminalID string, payload domain.EmotionUpdatePayload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	at := s.stamp()
+	emotion := domain.ShadowEmotion{}
+	if t.state.Emotion != nil {
+		emotion = *t.state.Emotion
+	}
+	emotion.UserEmotion = payload.UserEmotion.Emotion
+	emotion.At = at
+	if !payload.Preview {
+		emotion.SoulP = payload.SoulEmotion.P
+		emotion.SoulA = payload.SoulEmotion.A
+		emotion.SoulD = payload.SoulEmotion.D
+		emotion.ExecMode = payload.ExecMode
+	}
+	t.state.Emotion = &emotion
+	if expression := expressionForEmotion(payload.UserEmotion); expression != "" {
+		t.state.Expression = expression
+	}
+	t.state.UpdatedAt = at
+}
+
+func (s *shadowStore) gateLock(terminalID string, locked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	t.state.GateLocked = locked
+	t.state.UpdatedAt = s.stamp()
+}
+
+// online starts a new connection: vitals from before it, uptime above
+// all, no longer hold.
+func (s *shadowStore) online(terminalID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	t.state.OnlineSince = s.stamp()
+	t.state.Vitals = nil
+}
+
+func (s *shadowStore) vitals(terminalID string, v domain.TerminalVitals) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	v.ReportedAt = s.stamp()
+	t.state.Vitals = &v
+}
+
+func (s *shadowStore) power(terminalID string, asleep bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	t.state.Asleep = asleep
+	t.state.UpdatedAt = s.stamp()
+}
+
+func (s *shadowStore) asleep(terminalID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.terminals[terminalID]
+	return ok && t.state.Asleep
+}
+
+func (s *shadowStore) status(terminalID, status, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.get(terminalID)
+	at := s.stamp()
+	t.state.LastStatus = &domain.ShadowStatus{Status: status, Message: message, At: at}
+	t.state.UpdatedAt = at
+}
+
+// snapshot copies the terminal's shadow, dropping intents that waited past
+// pendingIntentTTL. Pending is ordered oldest first.
+func (s *shadowStore) snapshot(terminalID string) (domain.TerminalShadow, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.terminals[terminalID]
+	if !ok {
+		return domain.TerminalShadow{}, false
+	}
+	cutoff := s.now().Add(-pendingIntentTTL)
+	out := t.state
+	out.Pending = make([]domain.PendingAction, 0, len(t.pending))
+	for key, p := range t.pending {
+		if p.Kind == shadowSourceIntentAction {
+			if sent, err := time.Parse(time.RFC3339, p.SentAt); err == nil && sent.Before(cutoff) {
+				delete(t.pending, key)
+				continue
+			}
+		}
+		out.Pending = append(out.Pending, p)
+	}
+	sort.Slice(out.Pending, func(i, j int) bool {
+		if out.Pending[i].SentAt != out.Pending[j].SentAt {
+			return out.Pending[i].SentAt < out.Pending[j].SentAt
+		}
+		return out.Pending[i].RequestID+out.Pending[i].IntentID < out.Pending[j].RequestID+out.Pending[j].IntentID
+	})
+	if t.state.Vitals != nil {
+		v := *t.state.Vitals
+		out.Vitals = &v
+	}
+	if t.state.Skills != nil {
+		out.Skills = make(map[string]domain.ShadowAction, len(t.state.Skills))
+		for k, v := range t.state.Skills {
+			out.Skills[k] = v
+		}
+	}
+	return out, true
+}
+
+func intentPendingKey(requestID, intentID string) string {
+	return requestID + "/" + intentID
+}
+
+// intentSkillArgs reads the skill an intent maps to and its arguments: the
+// normalized slots without "skill", over the raw parameters.
+func intentSkillArgs(in domain.IntentActionItem) (string, json.RawMessage) {
+	skill := mapString(in.Normalized, "skill")
+	if skill == "" {
+		skill = mapString(in.Parameters, "skill")
+	}
+	args := make(map[string]any, len(in.Parameters)+len(in.Normalized))
+	for k, v := range in.Parameters {
+		args[k] = v
+	}
+	for k, v := range in.Normalized {
+		args[k] = v
+	}
+	delete(args, "skill")
+	if len(args) == 0 {
+		return skill, nil
+	}
+	body, err := json.Marshal(args)
+	if err != nil {
+		return skill, nil
+	}
+	return skill, body
+}
+
+// expressionForEmotion follows the emotion_update mapping in the protocol
+// doc, so the shadow shows what the terminal is expected to display.
+func expressionForEmotion(e domain.EmotionSignal) string {
+	switch strings.ToLower(strings.TrimSpace(e.Emotion)) {
+	case "anger", "disgust", "frustration":
+		return "生气"
+	case "anxiety", "fear":
+		return "不开心"
+	case "sadness", "disappointment", "boredom":
+		if e.Intensity >= cryingIntensity {
+			return "哭"
+		}
+		return "不开心"
+	case "joy", "gratitude", "relief", "calm", "neutral":
+		return "微笑"
+	case "excitement", "surprise":
+		return "大笑"
+	}
+	return ""
+}
+
+func mapString(m map[string]any, key string) string {
+	v, _ := m[key].(string)
+	return strings.TrimSpace(v)
+}
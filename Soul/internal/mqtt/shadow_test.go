@@ -0,0 +1,113 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestShadowFollowsSettledActions(t *testing.T) {
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	s := newShadowStore()
+	s.now = func() time.Time { return now }
+
+	s.invokeSent("t1", "req-1", "control_light", json.RawMessage(`{"mode":"set_color","color":"red"}`))
+	got, _ := s.snapshot("t1")
+	if len(got.Pending) != 1 || got.Pending[0].Kind != "invoke" || got.Light != nil {
+		t.Fatalf("invoke must be pending until its result, got %+v", got)
+	}
+	s.invokeDone("t1", "req-1", "control_light", json.RawMessage(`{"mode":"set_color","color":"red"}`), "")
+	s.invokeSent("t1", "req-2", "control_light", json.RawMessage(`{"mode":"off"}`))
+	s.invokeDone("t1", "req-2", "control_light", json.RawMessage(`{"mode":"off"}`), "tool timeout")
+	got, _ = s.snapshot("t1")
+	if len(got.Pending) != 0 {
+		t.Fatalf("settled invokes must leave pending, got %+v", got.Pending)
+	}
+	if got.Light == nil || !got.Light.On || got.Light.Color != "red" {
+		t.Fatalf("failed call must not move the light, got %+v", got.Light)
+	}
+	if last := got.Skills["control_light"]; last.OK || last.Error != "tool timeout" {
+		t.Fatalf("skills must keep the latest call, got %+v", last)
+	}
+
+	s.intentsSent("t1", domain.IntentActionPayload{RequestID: "ia-1", Intents: []domain.IntentActionItem{
+		{IntentID: "intent_nod", Normalized: map[string]any{"skill": "set_head_motion", "action": "点头"}},
+		{IntentID: "intent_light", Normalized: map[string]any{"skill": "control_light", "mode": "off"}},
+	}})
+	s.intentResults("t1", domain.IntentResultPayload{RequestID: "ia-1", Results: []domain.IntentResultItem{
+		{IntentID: "intent_nod", OK: true},
+	}})
+	got, _ = s.snapshot("t1")
+	if got.Motion == nil || got.Motion.Skill != "set_head_motion" || string(got.Motion.Arguments) != `{"action":"点头"}` {
+		t.Fatalf("intent result must move the head from what was sent, got %+v", got.Motion)
+	}
+	if len(got.Pending) != 1 || got.Pending[0].IntentID != "intent_light" {
+		t.Fatalf("unreported intent must stay pending, got %+v", got.Pending)
+	}
+
+	now = now.Add(pendingIntentTTL + time.Second)
+	got, _ = s.snapshot("t1")
+	if len(got.Pending) != 0 {
+		t.Fatalf("intent pending past the TTL must be dropped, got %+v", got.Pending)
+	}
+}
+
+func TestShadowEmotionSetsExpression(t *testing.T) {
+	s := newShadowStore()
+	s.emotion("t1", domain.EmotionUpdatePayload{
+		UserEmotion: domain.EmotionSignal{Emotion: "joy", Intensity: 0.5},
+		SoulEmotion: domain.SoulEmotionState{P: 0.4, A: 0.2, D: 0.1},
+		ExecMode:    "auto_execute",
+	})
+	s.emotion("t1", domain.EmotionUpdatePayload{
+		UserEmotion: domain.EmotionSignal{Emotion: "sadness", Intensity: 0.9},
+		Preview:     true,
+	})
+	got, ok := s.snapshot("t1")
+	if !ok {
+		t.Fatal("terminal must be known after an emotion update")
+	}
+	if got.Expression != "哭" || got.Emotion.UserEmotion != "sadness" {
+		t.Fatalf("preview must move the face, got %q %+v", got.Expression, got.Emotion)
+	}
+	if got.Emotion.SoulP != 0.4 || got.Emotion.ExecMode != "auto_execute" {
+		t.Fatalf("preview must keep the soul's PAD, got %+v", got.Emotion)
+	}
+	if _, ok := s.snapshot("t2"); ok {
+		t.Fatal("unknown terminal must not have a shadow")
+	}
+}
+
+func TestShadowPowerClearsOnReset(t *testing.T) {
+	s := newShadowStore()
+	if s.asleep("t1") {
+		t.Fatal("unknown terminal must count as awake")
+	}
+	s.power("t1", true)
+	if got, _ := s.snapshot("t1"); !got.Asleep || !s.asleep("t1") {
+		t.Fatalf("sleep must show in the shadow, got %+v", got)
+	}
+	s.reset("t1")
+	if s.asleep("t1") {
+		t.Fatal("a reconnecting terminal boots awake")
+	}
+}
+
+func TestHeartbeatVitals(t *testing.T) {
+	if _, ok := parseHeartbeat([]byte("1")); ok {
+		t.Fatal("plain heartbeat carries no vitals")
+	}
+	v, ok := parseHeartbeat([]byte(`{"battery_percent":140,"charging":false,"uptime_seconds":60}`))
+	if !ok || v.BatteryPercent != nil || v.Charging == nil || *v.Charging || v.UptimeSeconds != 60 {
+		t.Fatalf("out of range battery must be dropped, got %+v %v", v, ok)
+	}
+
+	s := newShadowStore()
+	s.vitals("t1", v)
+	s.online("t1")
+	if got, _ := s.snapshot("t1"); got.Vitals != nil || got.OnlineSince == "" {
+		t.Fatalf("a new connection must drop old vitals, got %+v", got)
+	}
+}
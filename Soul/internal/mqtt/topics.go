@@ -22,6 +22,14 @@ func TopicTerminalIntentCatalog(prefix string) string {
 	return fmt.Sprintf("%s/terminal/+/intent_catalog", prefix)
 }
 
+func TopicTerminalIntentResult(prefix string) string {
+	return fmt.Sprintf("%s/terminal/+/intent_result", prefix)
+}
+
+func TopicTerminalConfigAck(prefix string) string {
+	return fmt.Sprintf("%s/terminal/+/config_ack", prefix)
+}
+
 func TopicInvoke(prefix, terminalID, requestID string) string {
 	return fmt.Sprintf("%s/terminal/%s/invoke/%s", prefix, terminalID, requestID)
 }
@@ -54,6 +62,26 @@ func TopicEmotionUpdate(prefix, terminalID string) string {
 	return fmt.Sprintf("%s/terminal/%s/emotion_update", prefix, terminalID)
 }
 
+func TopicGateLock(prefix, terminalID string) string {
+	return fmt.Sprintf("%s/terminal/%s/gate_lock", prefix, terminalID)
+}
+
 func TopicIntentAction(prefix, terminalID string) string {
 	return fmt.Sprintf("%s/terminal/%s/intent_action", prefix, terminalID)
 }
+
+func TopicIntentResult(prefix, terminalID string) string {
+	return fmt.Sprintf("%s/terminal/%s/intent_result", prefix, terminalID)
+}
+
+func TopicConfig(prefix, terminalID string) string {
+	return fmt.Sprintf("%s/terminal/%s/config", prefix, terminalID)
+}
+
+func TopicPower(prefix, terminalID string) string {
+	return fmt.Sprintf("%s/terminal/%s/power", prefix, terminalID)
+}
+
+func TopicConfigAck(prefix, terminalID string) string {
+	return fmt.Sprintf("%s/terminal/%s/config_ack", prefix, terminalID)
+}
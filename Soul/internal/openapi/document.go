@@ -0,0 +1,290 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const specVersion = "3.0.3"
+
+var pathParamPattern = regexp.MustCompile(`\{([^}/]+)\}`)
+
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	QueryParams []string
+	Request     any
+	Response    any
+}
+
+type Document struct {
+	mu         sync.RWMutex
+	title      string
+	version    string
+	operations map[string]map[string]Operation
+}
+
+func NewDocument(title, version string) *Document {
+	return &Document{
+		title:      title,
+		version:    version,
+		operations: make(map[string]map[string]Operation),
+	}
+}
+
+func (d *Document) Add(method, path string, op Operation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.operations[path] == nil {
+		d.operations[path] = make(map[string]Operation)
+	}
+	d.operations[path][strings.ToLower(method)] = op
+}
+
+func (d *Document) Build() map[string]any {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	gen := newSchemaGenerator()
+	paths := make(map[string]any, len(d.operations))
+	pathKeys := make([]string, 0, len(d.operations))
+	for path := range d.operations {
+		pathKeys = append(pathKeys, path)
+	}
+	sort.Strings(pathKeys)
+
+	for _, path := range pathKeys {
+		item := map[string]any{}
+		for method, op := range d.operations[path] {
+			item[method] = gen.operation(path, method, op)
+		}
+		paths[path] = item
+	}
+
+	doc := map[string]any{
+		"openapi": specVersion,
+		"info": map[string]any{
+			"title":   d.title,
+			"version": d.version,
+		},
+		"paths": paths,
+	}
+	if len(gen.components) > 0 {
+		doc["components"] = map[string]any{"schemas": gen.components}
+	}
+	return doc
+}
+
+func (d *Document) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(d.Build())
+	}
+}
+
+type schemaGenerator struct {
+	components map[string]any
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{components: map[string]any{}}
+}
+
+func (g *schemaGenerator) operation(path, method string, op Operation) map[string]any {
+	out := map[string]any{
+		"operationId": operationID(method, path),
+	}
+	if op.Summary != "" {
+		out["summary"] = op.Summary
+	}
+	if op.Description != "" {
+		out["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		out["tags"] = op.Tags
+	}
+
+	params := make([]any, 0, 4)
+	for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]any{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, name := range op.QueryParams {
+		params = append(params, map[string]any{
+			"name":   name,
+			"in":     "query",
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	if op.Request != nil {
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": g.schemaFor(reflect.TypeOf(op.Request))},
+			},
+		}
+	}
+
+	okSchema := map[string]any{"type": "object"}
+	if op.Response != nil {
+		okSchema = g.schemaFor(reflect.TypeOf(op.Response))
+	}
+	out["responses"] = map[string]any{
+		"200": map[string]any{
+			"description": "OK",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": okSchema},
+			},
+		},
+		"default": map[string]any{
+			"description": "Error",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"error": map[string]any{"type": "string"}},
+				}},
+			},
+		},
+	}
+	return out
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage{})
+
+func (g *schemaGenerator) schemaFor(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == rawMessageType {
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := g.components[name]; !ok {
+			// Reserve the name first so recursive types terminate.
+			g.components[name] = map[string]any{}
+			g.components[name] = g.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	required := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, omitEmpty, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+		props[name] = g.schemaFor(field.Type)
+		if !omitEmpty && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+	out := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		out["required"] = required
+	}
+	return out
+}
+
+// schemaName flattens generic instantiations such as
+// listResponse[soul/internal/domain.UserProfile] into listResponse_UserProfile.
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	open := strings.IndexByte(name, '[')
+	if open < 0 {
+		return name
+	}
+	args := strings.Split(strings.TrimSuffix(name[open+1:], "]"), ",")
+	parts := []string{name[:open]}
+	for _, arg := range args {
+		arg = strings.TrimSpace(arg)
+		if dot := strings.LastIndexByte(arg, '.'); dot >= 0 {
+			arg = arg[dot+1:]
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, "_")
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name := strings.TrimSpace(parts[0])
+	if name == "" {
+		name = field.Name
+	}
+	omitEmpty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, part := range strings.Split(path, "/") {
+		part = strings.Trim(part, "{}")
+		if part == "" {
+			continue
+		}
+		for _, seg := range strings.FieldsFunc(part, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+			sb.WriteString(strings.ToUpper(seg[:1]))
+			sb.WriteString(seg[1:])
+		}
+	}
+	return sb.String()
+}
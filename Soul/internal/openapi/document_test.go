@@ -0,0 +1,72 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testChild struct {
+	Label string `json:"label"`
+}
+
+type testList[T any] struct {
+	Items []T `json:"items"`
+}
+
+type testPayload struct {
+	ID       string            `json:"id"`
+	Count    int64             `json:"count,omitempty"`
+	Children []testChild       `json:"children"`
+	Extra    map[string]any    `json:"extra,omitempty"`
+	Raw      json.RawMessage   `json:"raw,omitempty"`
+	Optional *testChild        `json:"optional"`
+	Tags     map[string]string `json:"-"`
+}
+
+func TestBuildGeneratesRefsAndParams(t *testing.T) {
+	doc := NewDocument("test", "v1")
+	doc.Add("POST", "/v1/items/{item_id}", Operation{
+		Summary:     "create item",
+		QueryParams: []string{"user_id"},
+		Request:     testPayload{},
+		Response:    testChild{},
+	})
+
+	raw, err := json.Marshal(doc.Build())
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	out := string(raw)
+	for _, want := range []string{
+		`"openapi":"3.0.3"`,
+		`"operationId":"postV1ItemsItemId"`,
+		`"$ref":"#/components/schemas/testPayload"`,
+		`"$ref":"#/components/schemas/testChild"`,
+		`"name":"item_id"`,
+		`"name":"user_id"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("spec missing %s: %s", want, out)
+		}
+	}
+	if strings.Contains(out, `"Tags"`) {
+		t.Fatalf("json:\"-\" field must be skipped: %s", out)
+	}
+
+	gen := newSchemaGenerator()
+	gen.schemaFor(reflect.TypeOf(testPayload{}))
+	schema := gen.components["testPayload"].(map[string]any)
+	required := schema["required"].([]string)
+	if strings.Join(required, ",") != "id,children" {
+		t.Fatalf("unexpected required fields: %v", required)
+	}
+}
+
+func TestSchemaNameFlattensGenerics(t *testing.T) {
+	got := schemaName(reflect.TypeOf(testList[testChild]{}))
+	if got != "testList_testChild" {
+		t.Fatalf("unexpected schema name: %s", got)
+	}
+}
@@ -0,0 +1,36 @@
+package openapi
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+)
+
+const swaggerUIVersion = "5.17.14"
+
+func SwaggerUIHandler(title, specURL string) http.HandlerFunc {
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8" />
+  <title>%[1]s</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[3]s/swagger-ui.css" />
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@%[3]s/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: %[2]q, dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`, html.EscapeString(title), specURL, swaggerUIVersion)
+
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(page))
+	}
+}
@@ -0,0 +1,121 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Dependencies a turn can do without, as reported in ChatResponse.Degraded.
+// degradedIntentLocal marks intents matched in process because the intent
+// filter service was down.
+const (
+	degradedEmotion     = "emotion"
+	degradedIntent      = "intent"
+	degradedIntentLocal = "intent_local"
+	degradedMem0        = "mem0"
+)
+
+// errOverBudget is returned for a dependency call cut off by the latency
+// budget.
+var errOverBudget = errors.New("latency budget exceeded")
+
+// LatencyBudgetConfig bounds how long a turn waits for dependencies it can
+// do without. A zero duration leaves that bound off.
+type LatencyBudgetConfig struct {
+	// Total is the whole turn's target; each call below also stops when
+	// it would run past it.
+	Total time.Duration
+	// Emotion bounds emotion analysis; past it the user reads as neutral.
+	Emotion time.Duration
+	// Intent bounds intent filtering; past it the LLM chooses skills.
+	Intent time.Duration
+	// Mem0 bounds each recall_memory lookup; past it the LLM answers
+	// without long-term memory.
+	Mem0 time.Duration
+}
+
+// turnBudget is one turn's clock and the dependencies it skipped.
+type turnBudget struct {
+	cfg   LatencyBudgetConfig
+	start time.Time
+
+	mu       sync.Mutex
+	degraded []string
+}
+
+func newTurnBudget(cfg LatencyBudgetConfig, start time.Time) *turnBudget {
+	return &turnBudget{cfg: cfg, start: start}
+}
+
+// limit is how long a call bounded by sub may take now; ok is false when
+// neither sub nor the total bounds it.
+func (b *turnBudget) limit(sub time.Duration, now time.Time) (time.Duration, bool) {
+	d, ok := sub, sub > 0
+	if b.cfg.Total > 0 {
+		left := b.cfg.Total - now.Sub(b.start)
+		if !ok || left < d {
+			d, ok = left, true
+		}
+	}
+	return d, ok
+}
+
+func (b *turnBudget) degrade(dep string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !slices.Contains(b.degraded, dep) {
+		b.degraded = append(b.degraded, dep)
+	}
+}
+
+// Degraded lists the skipped dependencies in a stable order.
+func (b *turnBudget) Degraded() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := slices.Clone(b.degraded)
+	slices.Sort(out)
+	return out
+}
+
+// withinBudget runs fn under the part of the budget sub leaves and
+// returns errOverBudget, recording dep as degraded, when it runs out. fn
+// is not waited for past the budget, so a dependency that ignores its
+// context cannot hold the turn.
+func withinBudget[T any](ctx context.Context, b *turnBudget, dep string, sub time.Duration, fn func(context.Context) (T, error)) (T, error) {
+	var zero T
+	d, bounded := b.limit(sub, time.Now())
+	if !bounded {
+		return fn(ctx)
+	}
+	if d <= 0 {
+		b.degrade(dep)
+		return zero, errOverBudget
+	}
+	callCtx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn(callCtx)
+		done <- result{v, err}
+	}()
+	select {
+	case r := <-done:
+		if r.err == nil || ctx.Err() != nil || !errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+			return r.v, r.err
+		}
+	case <-callCtx.Done():
+		if ctx.Err() != nil {
+			return zero, ctx.Err()
+		}
+	}
+	b.degrade(dep)
+	return zero, errOverBudget
+}
@@ -0,0 +1,68 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWithinBudgetSkipsSlowDependencies(t *testing.T) {
+	budget := newTurnBudget(LatencyBudgetConfig{Total: time.Second}, time.Now())
+	release := make(chan struct{})
+	defer close(release)
+	start := time.Now()
+	// A dependency that ignores its context is not waited for.
+	_, err := withinBudget(context.Background(), budget, degradedEmotion, 20*time.Millisecond, func(context.Context) (string, error) {
+		<-release
+		return "late", nil
+	})
+	if !errors.Is(err, errOverBudget) || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("slow call: err = %v after %v, want over budget at its sub-budget", err, time.Since(start))
+	}
+	got, err := withinBudget(context.Background(), budget, degradedIntent, 20*time.Millisecond, func(context.Context) (string, error) {
+		return "fast", nil
+	})
+	if err != nil || got != "fast" {
+		t.Fatalf("fast call = %q, %v", got, err)
+	}
+	// One that honours it and reports the deadline counts as over budget too.
+	if _, err := withinBudget(context.Background(), budget, degradedMem0, 20*time.Millisecond, func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}); !errors.Is(err, errOverBudget) {
+		t.Fatalf("deadline error = %v, want over budget", err)
+	}
+	if got := budget.Degraded(); !slices.Equal(got, []string{"emotion", "mem0"}) {
+		t.Fatalf("degraded = %v", got)
+	}
+}
+
+func TestWithinBudgetHonoursTheTurnTotal(t *testing.T) {
+	budget := newTurnBudget(LatencyBudgetConfig{Total: time.Second}, time.Now().Add(-2*time.Second))
+	called := false
+	if _, err := withinBudget(context.Background(), budget, degradedMem0, time.Minute, func(context.Context) (int, error) {
+		called = true
+		return 0, nil
+	}); !errors.Is(err, errOverBudget) || called {
+		t.Fatalf("spent turn: err = %v, called = %v; want skipped", err, called)
+	}
+
+	unbounded := newTurnBudget(LatencyBudgetConfig{}, time.Now().Add(-time.Hour))
+	if got, err := withinBudget(context.Background(), unbounded, degradedMem0, 0, func(context.Context) (int, error) {
+		return 7, nil
+	}); err != nil || got != 7 || len(unbounded.Degraded()) != 0 {
+		t.Fatalf("no budget: %d, %v, degraded %v", got, err, unbounded.Degraded())
+	}
+}
+
+func TestWithinBudgetPassesDependencyErrors(t *testing.T) {
+	budget := newTurnBudget(LatencyBudgetConfig{Emotion: time.Second}, time.Now())
+	boom := errors.New("boom")
+	if _, err := withinBudget(context.Background(), budget, degradedEmotion, time.Second, func(context.Context) (int, error) {
+		return 0, boom
+	}); !errors.Is(err, boom) || len(budget.Degraded()) != 0 {
+		t.Fatalf("failure: err = %v, degraded %v; want the error, not a degradation", err, budget.Degraded())
+	}
+}
@@ -0,0 +1,28 @@
+package orchestrator
+
+import (
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// buildCharacterCardNotes turns the soul's character card into prompt
+// lines. It comes before the quiet hours and child mode notes, which
+// override it.
+func buildCharacterCardNotes(card *domain.SoulCharacterCard) string {
+	if card == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n角色设定（保持这个角色说话，但不改变工具集合与以上规则）：\n")
+	if card.Background != "" {
+		sb.WriteString("- 背景：" + card.Background + "\n")
+	}
+	if card.SpeakingStyle != "" {
+		sb.WriteString("- 说话风格：" + card.SpeakingStyle + "\n")
+	}
+	if len(card.TabooTopics) > 0 {
+		sb.WriteString("- 避免的话题：" + strings.Join(card.TabooTopics, "、") + "。用户提起时委婉带过，不展开。\n")
+	}
+	return sb.String()
+}
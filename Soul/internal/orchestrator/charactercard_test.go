@@ -0,0 +1,39 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestBuildSystemPromptIncludesCharacterCard(t *testing.T) {
+	if notes := buildCharacterCardNotes(nil); notes != "" {
+		t.Fatalf("want no notes without a card, got %q", notes)
+	}
+	card := &domain.SoulCharacterCard{
+		Background:    "来自海边小镇的灯塔看守人，喜欢收集贝壳。",
+		SpeakingStyle: "慢条斯理，爱用航海比喻。",
+		TabooTopics:   []string{"政治", "彩票"},
+	}
+	prompt := buildSystemPrompt(
+		"历史会话压缩摘要：\n无",
+		nil,
+		false,
+		llmEmotionPromptSnapshot{ExecMode: "auto_execute", ExecProbability: 1},
+		"",
+		nil,
+		nil,
+		nil,
+		"zh",
+		buildCharacterCardNotes(card)+buildChildModeNotes(60),
+	)
+	for _, want := range []string{"灯塔看守人", "爱用航海比喻", "避免的话题：政治、彩票"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("prompt missing %q", want)
+		}
+	}
+	if strings.Index(prompt, "角色设定") > strings.Index(prompt, "儿童模式") {
+		t.Fatal("the character card must come before the child mode notes")
+	}
+}
@@ -0,0 +1,174 @@
+package orchestrator
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// ChildModeConfig shapes turns for souls in child mode.
+type ChildModeConfig struct {
+	// BlockedSkills are neither offered to the LLM nor dispatched from
+	// intents while child mode is on.
+	BlockedSkills []string
+	// MaxReplyRunes caps LLM replies; 0 leaves them to the prompt.
+	MaxReplyRunes int
+	// PIN must be spoken to turn child mode off in chat; when empty it can
+	// only be turned off through the API.
+	PIN string
+}
+
+type childModePolicy struct {
+	blocked       map[string]struct{}
+	maxReplyRunes int
+	pin           string
+}
+
+func newChildModePolicy(cfg ChildModeConfig) childModePolicy {
+	blocked := make(map[string]struct{}, len(cfg.BlockedSkills))
+	for _, name := range cfg.BlockedSkills {
+		if name = strings.TrimSpace(name); name != "" {
+			blocked[name] = struct{}{}
+		}
+	}
+	return childModePolicy{blocked: blocked, maxReplyRunes: cfg.MaxReplyRunes, pin: strings.TrimSpace(cfg.PIN)}
+}
+
+func (p childModePolicy) blocks(skill string) bool {
+	_, ok := p.blocked[skill]
+	return ok
+}
+
+// allowedSkills drops the skills child mode withholds.
+func (p childModePolicy) allowedSkills(skills []domain.SkillDefinition) []domain.SkillDefinition {
+	if len(p.blocked) == 0 {
+		return skills
+	}
+	out := make([]domain.SkillDefinition, 0, len(skills))
+	for _, sk := range skills {
+		if !p.blocks(sk.Name) {
+			out = append(out, sk)
+		}
+	}
+	return out
+}
+
+// pinMatches compares in constant time, so timing does not reveal the PIN.
+func (p childModePolicy) pinMatches(pin string) bool {
+	return p.pin != "" && subtle.ConstantTimeCompare([]byte(pin), []byte(p.pin)) == 1
+}
+
+var (
+	childModeOffPhrases = []string{"关闭儿童模式", "退出儿童模式", "取消儿童模式"}
+	childModeOnPhrases  = []string{"打开儿童模式", "开启儿童模式", "进入儿童模式", "切换到儿童模式"}
+)
+
+var chineseDigits = strings.NewReplacer("零", "0", "〇", "0", "一", "1", "幺", "1", "二", "2", "两", "2", "三", "3", "四", "4", "五", "5", "六", "6", "七", "7", "八", "8", "九", "9")
+
+// childModeCommand reports whether text turns child mode on or off, and the
+// digits said with it, read as the PIN. Chinese numerals count as digits
+// since speech recognition often spells them out.
+func childModeCommand(text string) (on bool, pin string, ok bool) {
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) > 24 {
+		return false, "", false
+	}
+	switch {
+	case containsAny(text, childModeOffPhrases...):
+		on = false
+	case containsAny(text, childModeOnPhrases...):
+		on = true
+	default:
+		return false, "", false
+	}
+	var digits strings.Builder
+	for _, r := range chineseDigits.Replace(text) {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	return on, digits.String(), true
+}
+
+// switchChildMode answers a child mode command without the LLM. Anyone may
+// turn child mode on; turning it off needs the PIN.
+func (s *Service) switchChildMode(ctx context.Context, req domain.ChatRequest, userID, soulID, text string, on bool, pin string, speaker *domain.SpeakerIdentity, followUp, private bool) (domain.ChatResponse, error) {
+	profile, err := s.memoryService.GetSoulProfileByID(ctx, soulID)
+	if err != nil {
+		return domain.ChatResponse{}, err
+	}
+	childMode := profile.ChildMode
+	var reply string
+	switch {
+	case on && childMode:
+		reply = "儿童模式已经开着啦。"
+	case on:
+		if _, err := s.memoryService.SetSoulChildMode(ctx, soulID, true); err != nil {
+			return domain.ChatResponse{}, err
+		}
+		childMode = true
+		reply = "好的，已开启儿童模式。"
+	case !childMode:
+		reply = "现在没有开启儿童模式。"
+	case s.childMode.pin == "":
+		reply = "关闭儿童模式需要家长在管理后台操作。"
+	case !s.childMode.pinMatches(pin):
+		s.logger.Warn("child mode off refused: wrong pin", "soul_id", soulID, "terminal_id", req.TerminalID, "pin_given", pin != "")
+		reply = "需要说出正确的家长密码才能关闭儿童模式。"
+	default:
+		if _, err := s.memoryService.SetSoulChildMode(ctx, soulID, false); err != nil {
+			return domain.ChatResponse{}, err
+		}
+		childMode = false
+		reply = "好的，已关闭儿童模式。"
+	}
+	if childMode != profile.ChildMode {
+		s.logger.Info("soul child mode changed", "soul_id", soulID, "terminal_id", req.TerminalID, "child_mode", childMode)
+	}
+
+	// The PIN is not stored with the message.
+	turn := s.memoryService.BeginTurn(req.SessionID, userID, req.TerminalID, soulID)
+	turn.AddMessage("user", "", "", childModeCommandText(on))
+	turn.AddMessage("assistant", "", "", reply)
+	if err := s.memoryService.CommitTurn(ctx, turn); err != nil {
+		return domain.ChatResponse{}, err
+	}
+	s.openFollowUpWindow(ctx, req.TerminalID, req.SessionID)
+	return domain.ChatResponse{
+		SessionID:  req.SessionID,
+		TerminalID: req.TerminalID,
+		SoulID:     soulID,
+		Reply:      reply,
+		Speaker:    speaker,
+		FollowUp:   followUp,
+		Private:    private,
+		ChildMode:  childMode,
+	}, nil
+}
+
+func childModeCommandText(on bool) string {
+	if on {
+		return "开启儿童模式"
+	}
+	return "关闭儿童模式"
+}
+
+// buildChildModeNotes pins the child-safe variant of the prompt: plain
+// words, short answers and no topics unsuitable for children.
+func buildChildModeNotes(maxReplyRunes int) string {
+	var sb strings.Builder
+	sb.WriteString("\n儿童模式（对话对象是儿童，以下规则优先于其它风格要求）：\n")
+	sb.WriteString("- 用小学低年级孩子听得懂的常用词和短句，不用成语堆砌、网络用语、外语缩写或专业术语。\n")
+	if maxReplyRunes > 0 {
+		sb.WriteString(fmt.Sprintf("- 每次回复一到两句话，不超过 %d 个字。\n", maxReplyRunes))
+	} else {
+		sb.WriteString("- 每次回复一到两句话。\n")
+	}
+	sb.WriteString("- 不谈论暴力、恐怖、色情、赌博、药物、危险行为等内容；遇到这类问题温和地换个话题，或建议去问爸爸妈妈。\n")
+	sb.WriteString("- 不询问或记录孩子的住址、电话、学校等个人信息，不引导孩子购买或联系陌生人。\n")
+	sb.WriteString("- 涉及安全、身体不适或情绪低落时，鼓励孩子马上告诉身边的大人。\n")
+	return sb.String()
+}
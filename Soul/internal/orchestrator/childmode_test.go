@@ -0,0 +1,63 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestChildModeCommand(t *testing.T) {
+	cases := []struct {
+		text string
+		on   bool
+		pin  string
+		ok   bool
+	}{
+		{"开启儿童模式", true, "", true},
+		{"关闭儿童模式，密码1234", false, "1234", true},
+		{"关闭儿童模式 一二三四", false, "1234", true},
+		{"帮我把灯关掉", false, "", false},
+		{"你知道怎么关闭儿童模式吗？我想问问这个功能到底是干什么用的", false, "", false},
+	}
+	for _, c := range cases {
+		on, pin, ok := childModeCommand(c.text)
+		if on != c.on || pin != c.pin || ok != c.ok {
+			t.Fatalf("%q: got on=%v pin=%q ok=%v", c.text, on, pin, ok)
+		}
+	}
+}
+
+func TestChildModePolicy(t *testing.T) {
+	p := newChildModePolicy(ChildModeConfig{BlockedSkills: []string{"send_email", " "}, PIN: "1234"})
+	skills := p.allowedSkills([]domain.SkillDefinition{{Name: "send_email"}, {Name: "play_music"}})
+	if len(skills) != 1 || skills[0].Name != "play_music" {
+		t.Fatalf("unexpected skills allowed: %+v", skills)
+	}
+	if !p.pinMatches("1234") || p.pinMatches("4321") || p.pinMatches("") {
+		t.Fatal("pin must match exactly")
+	}
+	if newChildModePolicy(ChildModeConfig{}).pinMatches("") {
+		t.Fatal("an empty pin must never match")
+	}
+}
+
+func TestBuildSystemPromptIncludesChildMode(t *testing.T) {
+	prompt := buildSystemPrompt(
+		"历史会话压缩摘要：\n无",
+		nil,
+		false,
+		llmEmotionPromptSnapshot{ExecMode: "auto_execute", ExecProbability: 1},
+		"",
+		nil,
+		nil,
+		nil,
+		"zh",
+		buildChildModeNotes(60),
+	)
+	for _, want := range []string{"儿童模式", "不超过 60 个字"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("prompt missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package orchestrator
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// maxClarifyAttempts bounds how many times the same missing slots are asked
+// for before the turn is left to the LLM.
+const maxClarifyAttempts = 2
+
+// clarifyCancelWords end a pending clarification without executing it.
+var clarifyCancelWords = []string{"算了", "取消", "不用了", "不要了", "没事了"}
+
+// slotLabels names common slots in the clarification question; any other
+// slot is asked for by its own name.
+var slotLabels = map[string]string{
+	"time":        "时间",
+	"datetime":    "时间",
+	"due_at":      "时间",
+	"trigger_at":  "时间",
+	"at":          "时间",
+	"duration":    "时长",
+	"room":        "房间",
+	"device":      "设备",
+	"target":      "对象",
+	"content":     "内容",
+	"message":     "内容",
+	"text":        "内容",
+	"label":       "名称",
+	"name":        "名称",
+	"volume":      "音量",
+	"brightness":  "亮度",
+	"level":       "档位",
+	"temperature": "温度",
+	"location":    "地点",
+	"city":        "城市",
+	"to":          "收件人",
+	"contact":     "联系人",
+}
+
+// clarificationTracker holds, per terminal, the intents that were recognised
+// but are missing required slots, until the user answers the question asked
+// for them or the hold expires.
+type clarificationTracker struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	pending map[string]pendingClarification
+}
+
+type pendingClarification struct {
+	sessionID string
+	// utterance is the command the intents were recognised in; the answer is
+	// appended to it so the filter sees the intent and its slots together.
+	utterance string
+	intents   []domain.SelectedIntent
+	attempts  int
+	until     time.Time
+}
+
+func newClarificationTracker(ttl time.Duration) *clarificationTracker {
+	return &clarificationTracker{ttl: ttl, pending: make(map[string]pendingClarification)}
+}
+
+func (t *clarificationTracker) enabled() bool {
+	return t != nil && t.ttl > 0
+}
+
+// take removes the terminal's pending clarification and returns it when it
+// belongs to sessionID and has not expired.
+func (t *clarificationTracker) take(terminalID, sessionID string, now time.Time) (pendingClarification, bool) {
+	if !t.enabled() {
+		return pendingClarification{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pending[terminalID]
+	if !ok {
+		return pendingClarification{}, false
+	}
+	delete(t.pending, terminalID)
+	if p.sessionID != sessionID || now.After(p.until) {
+		return pendingClarification{}, false
+	}
+	return p, true
+}
+
+func (t *clarificationTracker) hold(terminalID string, p pendingClarification, now time.Time) {
+	if !t.enabled() {
+		return
+	}
+	p.until = now.Add(t.ttl)
+	t.mu.Lock()
+	t.pending[terminalID] = p
+	t.mu.Unlock()
+}
+
+// clarificationIntents returns the catalog intents the filter recognised but
+// could not fill.
+func clarificationIntents(resp domain.IntentFilterResponse) []domain.SelectedIntent {
+	if strings.TrimSpace(resp.Decision.Action) != "execute_intents" {
+		return nil
+	}
+	var out []domain.SelectedIntent
+	for _, in := range resp.Intents {
+		if strings.TrimSpace(in.Status) == "need_clarification" && len(in.MissingParameters) > 0 {
+			out = append(out, in)
+		}
+	}
+	return out
+}
+
+// mergeClarification folds the answer into the pending intents. resp is the
+// filter's reading of the original command followed by the answer; slots it
+// filled are taken from there, and a single slot it still could not fill
+// takes the answer verbatim, which covers free-text slots such as a reminder's
+// content. Only the pending intents are returned, so intents that already ran
+// on the first turn are not dispatched again.
+func mergeClarification(p pendingClarification, resp domain.IntentFilterResponse, answer string) domain.IntentFilterResponse {
+	merged := domain.IntentFilterResponse{
+		RequestID: resp.RequestID,
+		Decision: domain.IntentFilterDecision{
+			Action:          "execute_intents",
+			TriggerIntentID: p.intents[0].IntentID,
+			Reason:          "clarification_answered",
+		},
+		Meta: resp.Meta,
+	}
+	for _, prev := range p.intents {
+		in := prev
+		in.Parameters = copyParams(prev.Parameters)
+		in.Normalized = copyParams(prev.Normalized)
+		if found, ok := findIntent(resp.Intents, prev.IntentID); ok {
+			for k, v := range found.Parameters {
+				in.Parameters[k] = v
+			}
+			for k, v := range found.Normalized {
+				in.Normalized[k] = v
+			}
+		}
+
+		var missing []string
+		for _, name := range prev.MissingParameters {
+			if _, ok := in.Parameters[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == 1 && strings.TrimSpace(answer) != "" {
+			in.Parameters[missing[0]] = strings.TrimSpace(answer)
+			missing = nil
+		}
+		in.MissingParameters = missing
+		in.Status = "ready"
+		if len(missing) > 0 {
+			in.Status = "need_clarification"
+		}
+		merged.Intents = append(merged.Intents, in)
+	}
+	return merged
+}
+
+func findIntent(intents []domain.SelectedIntent, intentID string) (domain.SelectedIntent, bool) {
+	for _, in := range intents {
+		if in.IntentID == intentID {
+			return in, true
+		}
+	}
+	return domain.SelectedIntent{}, false
+}
+
+func copyParams(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+func isClarifyCancel(text string) bool {
+	text = strings.TrimSpace(text)
+	return len([]rune(text)) <= 8 && containsAny(text, clarifyCancelWords...)
+}
+
+// clarificationQuestion asks for exactly the slots that are missing, e.g.
+// "要设置提醒，还需要知道时间和内容，请补充一下。".
+func clarificationQuestion(intents []domain.SelectedIntent) string {
+	parts := make([]string, 0, len(intents))
+	for _, in := range intents {
+		labels := make([]string, 0, len(in.MissingParameters))
+		for _, name := range in.MissingParameters {
+			label := slotLabels[strings.ToLower(strings.TrimSpace(name))]
+			if label == "" {
+				label = name
+			}
+			labels = append(labels, label)
+		}
+		intentName := strings.TrimSpace(in.IntentName)
+		if intentName == "" {
+			intentName = in.IntentID
+		}
+		labels = uniqueStrings(labels)
+		asked := labels[len(labels)-1]
+		if len(labels) > 1 {
+			asked = strings.Join(labels[:len(labels)-1], "、") + "和" + asked
+		}
+		parts = append(parts, fmt.Sprintf("要%s，还需要知道%s", intentName, asked))
+	}
+	return strings.Join(parts, "；") + "，请补充一下。"
+}
+
+// startsNewIntent reports whether the answer on its own is a different ready
+// command, in which case the user moved on and the clarification is dropped.
+func startsNewIntent(resp domain.IntentFilterResponse, p pendingClarification) bool {
+	if strings.TrimSpace(resp.Decision.Action) != "execute_intents" {
+		return false
+	}
+	for _, in := range resp.Intents {
+		if strings.TrimSpace(in.Status) != "ready" {
+			continue
+		}
+		if _, ok := findIntent(p.intents, in.IntentID); !ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func reminderNeedingTime() domain.SelectedIntent {
+	return domain.SelectedIntent{
+		IntentID:          "set_reminder",
+		IntentName:        "设置提醒",
+		Status:            "need_clarification",
+		Parameters:        map[string]any{"content": "喝水"},
+		Normalized:        map[string]any{"skill": "set_reminder"},
+		MissingParameters: []string{"due_at"},
+	}
+}
+
+func TestClarificationTrackerTakeOnce(t *testing.T) {
+	tracker := newClarificationTracker(time.Minute)
+	now := time.Now()
+	tracker.hold("t1", pendingClarification{sessionID: "s1", intents: []domain.SelectedIntent{reminderNeedingTime()}}, now)
+
+	if _, ok := tracker.take("t1", "s2", now); ok {
+		t.Fatalf("clarification must not carry over to another session")
+	}
+	tracker.hold("t1", pendingClarification{sessionID: "s1", intents: []domain.SelectedIntent{reminderNeedingTime()}}, now)
+	if _, ok := tracker.take("t1", "s1", now.Add(2*time.Minute)); ok {
+		t.Fatalf("expired clarification must be dropped")
+	}
+	tracker.hold("t1", pendingClarification{sessionID: "s1", intents: []domain.SelectedIntent{reminderNeedingTime()}}, now)
+	if _, ok := tracker.take("t1", "s1", now.Add(time.Second)); !ok {
+		t.Fatalf("expected pending clarification")
+	}
+	if _, ok := tracker.take("t1", "s1", now.Add(time.Second)); ok {
+		t.Fatalf("clarification must be single-use")
+	}
+}
+
+func TestMergeClarificationFillsFromFilterAndAnswer(t *testing.T) {
+	pending := pendingClarification{utterance: "提醒我喝水", intents: []domain.SelectedIntent{reminderNeedingTime()}}
+
+	resp := domain.IntentFilterResponse{Intents: []domain.SelectedIntent{{
+		IntentID:   "set_reminder",
+		Status:     "ready",
+		Parameters: map[string]any{"content": "喝水", "due_at": "2026-10-16T15:00:00+08:00"},
+	}}}
+	merged := mergeClarification(pending, resp, "下午三点")
+	if merged.Decision.Action != "execute_intents" || len(merged.Intents) != 1 {
+		t.Fatalf("unexpected merge %+v", merged)
+	}
+	if in := merged.Intents[0]; in.Status != "ready" || in.Parameters["due_at"] != "2026-10-16T15:00:00+08:00" || in.Parameters["content"] != "喝水" {
+		t.Fatalf("expected slot from filter, got %+v", in)
+	}
+
+	merged = mergeClarification(pending, domain.IntentFilterResponse{}, "下午三点")
+	if in := merged.Intents[0]; in.Status != "ready" || in.Parameters["due_at"] != "下午三点" {
+		t.Fatalf("expected single slot filled from answer, got %+v", in)
+	}
+	if pending.intents[0].Parameters["due_at"] != nil {
+		t.Fatalf("merge must not modify the pending intent")
+	}
+
+	two := reminderNeedingTime()
+	two.MissingParameters = []string{"due_at", "content"}
+	delete(two.Parameters, "content")
+	merged = mergeClarification(pendingClarification{intents: []domain.SelectedIntent{two}}, domain.IntentFilterResponse{}, "下午三点")
+	if in := merged.Intents[0]; in.Status != "need_clarification" || len(in.MissingParameters) != 2 {
+		t.Fatalf("two unfilled slots must stay missing, got %+v", in)
+	}
+	if got := clarificationIntents(merged); len(got) != 1 {
+		t.Fatalf("expected intent to still need clarification, got %+v", got)
+	}
+}
+
+func TestClarificationQuestionAndTopicChange(t *testing.T) {
+	in := reminderNeedingTime()
+	in.MissingParameters = []string{"due_at", "content", "repeat"}
+	if got, want := clarificationQuestion([]domain.SelectedIntent{in}), "要设置提醒，还需要知道时间、内容和repeat，请补充一下。"; got != want {
+		t.Fatalf("question = %q, want %q", got, want)
+	}
+
+	pending := pendingClarification{intents: []domain.SelectedIntent{reminderNeedingTime()}}
+	lightsOff := domain.IntentFilterResponse{
+		Decision: domain.IntentFilterDecision{Action: "execute_intents"},
+		Intents:  []domain.SelectedIntent{{IntentID: "light_off", Status: "ready"}},
+	}
+	if !startsNewIntent(lightsOff, pending) {
+		t.Fatalf("a different ready intent must abandon the clarification")
+	}
+	fallback := domain.IntentFilterResponse{Decision: domain.IntentFilterDecision{Action: "fallback_reasoning"}}
+	if startsNewIntent(fallback, pending) {
+		t.Fatalf("a plain answer must not abandon the clarification")
+	}
+	if !isClarifyCancel("算了吧") || isClarifyCancel("下午三点") {
+		t.Fatalf("unexpected cancel detection")
+	}
+}
@@ -0,0 +1,105 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/persona"
+)
+
+const emotionContagionSessionID = "system_contagion"
+
+// EmotionContagionConfig lets a big swing in one soul's mood spread to the
+// other souls of the same user that are bound to online terminals.
+type EmotionContagionConfig struct {
+	// Share is the part of the PAD shock passed on; 0 disables contagion.
+	Share float64
+	// MinShock is the smallest PAD move, as a distance, that spreads.
+	MinShock float64
+}
+
+// spreadEmotion passes a damped share of soulID's move from prev to next on
+// to the owner's other online souls, stores their new state and sends each
+// of their terminals an emotion_update naming the source soul.
+func (s *Service) spreadEmotion(ctx context.Context, soulID, ownerID string, prev, next domain.SoulEmotionState, userEmotion domain.EmotionSignal, now time.Time) {
+	cfg := s.contagion
+	if cfg.Share <= 0 || s.personaEngine == nil || strings.TrimSpace(ownerID) == "" {
+		return
+	}
+	if persona.Shock(prev, next) < cfg.MinShock {
+		return
+	}
+
+	terminals := make(map[string][]string)
+	var siblings []string
+	for _, state := range s.skillRegistry.ListOnlineStates() {
+		sibling := strings.TrimSpace(state.SoulID)
+		if sibling == "" || sibling == soulID {
+			continue
+		}
+		if _, ok := terminals[sibling]; !ok {
+			siblings = append(siblings, sibling)
+		}
+		terminals[sibling] = append(terminals[sibling], state.TerminalID)
+	}
+
+	caught := userEmotion
+	caught.Intensity = clamp01(userEmotion.Intensity * cfg.Share)
+	publisher, _ := s.eventPublisher().(EmotionPublisher)
+	spread := 0
+	for _, sibling := range siblings {
+		if ctx.Err() != nil {
+			return
+		}
+		unlock := s.lockSoulEmotion(ctx, sibling)
+		profile, err := s.memoryService.GetSoulProfileByID(ctx, sibling)
+		if err != nil {
+			unlock()
+			s.logger.Warn("emotion contagion: load soul profile failed", "soul_id", sibling, "error", err)
+			continue
+		}
+		if profile.UserID != ownerID {
+			unlock()
+			continue
+		}
+		var caughtState domain.SoulEmotionState
+		profile, err = s.storeSoulEmotion(ctx, profile, func(p domain.SoulProfile) domain.SoulEmotionState {
+			caughtState = s.personaEngine.Contagion(p.PersonalityVector, p.EmotionState, prev, next, cfg.Share)
+			return caughtState
+		})
+		profile.EmotionState = caughtState
+		if err != nil {
+			unlock()
+			s.logger.Warn("emotion contagion: update soul emotion state failed", "soul_id", sibling, "error", err)
+			continue
+		}
+		unlock()
+		spread++
+
+		if publisher == nil {
+			continue
+		}
+		execProbability, execMode := s.evaluateExecGateAt(now, profile, personaBaseExecProb, "auto_execute")
+		for _, terminalID := range terminals[sibling] {
+			payload := domain.EmotionUpdatePayload{
+				SessionID:       emotionContagionSessionID,
+				TerminalID:      terminalID,
+				SoulID:          sibling,
+				UserEmotion:     caught,
+				SoulEmotion:     profile.EmotionState,
+				ExecProbability: execProbability,
+				ExecMode:        execMode,
+				ContagionFrom:   soulID,
+				TS:              now.Format(time.RFC3339Nano),
+			}
+			if err := publisher.PublishEmotionUpdate(ctx, terminalID, payload); err != nil {
+				s.logger.Warn("emotion contagion: publish emotion update failed", "terminal_id", terminalID, "soul_id", sibling, "error", err)
+			}
+		}
+	}
+	if spread > 0 {
+		s.logger.Info("emotion contagion", "soul_id", soulID, "souls", spread, "shock", persona.Shock(prev, next))
+	}
+}
@@ -0,0 +1,171 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// Critic modes.
+const (
+	CriticOff    = "off"
+	CriticRisky  = "risky"
+	CriticAlways = "always"
+)
+
+// CriticConfig sets up a second, cheap LLM pass that checks a drafted
+// reply against the turn's facts and rewrites it when it breaks them.
+type CriticConfig struct {
+	// Mode is CriticOff, CriticRisky (only replies that may report an
+	// action that did not run) or CriticAlways.
+	Mode string
+	// Model reviews the reply; empty uses the chat model.
+	Model string
+	// Timeout bounds the review; on timeout the draft is sent as is.
+	Timeout time.Duration
+}
+
+// criticInput is what the critic knows about the turn.
+type criticInput struct {
+	userText      string
+	reply         string
+	skills        []string
+	executed      []string
+	notRun        []string
+	execMode      string
+	dryRun        bool
+	quiet         bool
+	maxRunes      int
+	speakingStyle string
+}
+
+// actionClaimPhrases are ways a reply says an action was carried out.
+var actionClaimPhrases = []string{
+	"已经帮你", "已帮你", "已为你", "帮你打开了", "帮你关", "已打开", "已关闭", "已开启", "已设置", "设置好了", "已调", "调好了",
+	"打开了", "关掉了", "关上了", "开始播放", "已发送", "发好了", "定好了", "已提醒",
+	"i've turned", "i have turned", "i've set", "i have set", "turned on", "turned off",
+}
+
+// needsCritic tells whether the draft is worth reviewing in mode: in
+// CriticRisky, when a skill call did not run, or when nothing ran but the
+// reply reads as if something did.
+func needsCritic(mode string, in criticInput) bool {
+	if strings.TrimSpace(in.reply) == "" {
+		return false
+	}
+	switch mode {
+	case CriticAlways:
+		return true
+	case CriticRisky:
+		if len(in.notRun) > 0 {
+			return true
+		}
+		return len(in.executed) == 0 && containsAny(strings.ToLower(in.reply), actionClaimPhrases...)
+	}
+	return false
+}
+
+type criticVerdict struct {
+	OK     bool     `json:"ok"`
+	Issues []string `json:"issues"`
+	Reply  string   `json:"reply"`
+}
+
+// critiqueReply has the critic review the draft and returns its fix when
+// it found a violation; ok is false when the draft stands, including when
+// the review fails.
+func (s *Service) critiqueReply(ctx context.Context, in criticInput) (string, bool) {
+	if !needsCritic(s.critic.Mode, in) {
+		return "", false
+	}
+	if s.critic.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.critic.Timeout)
+		defer cancel()
+	}
+	model := s.critic.Model
+	if model == "" {
+		model = s.llmModel
+	}
+	resp, err := s.complete(ctx, domain.LLMRequest{
+		Model:    model,
+		System:   criticSystemPrompt,
+		Messages: []domain.Message{{Role: "user", Content: buildCriticPrompt(in)}},
+	})
+	if err != nil {
+		s.logger.Warn("reply critic failed, keeping draft", "error", err)
+		return "", false
+	}
+	verdict, ok := parseCriticVerdict(resp.Content)
+	if !ok {
+		s.logger.Warn("reply critic returned no verdict, keeping draft", "content", resp.Content)
+		return "", false
+	}
+	if verdict.OK || strings.TrimSpace(verdict.Reply) == "" {
+		return "", false
+	}
+	s.logger.Info("reply revised by critic", "issues", strings.Join(verdict.Issues, "; "))
+	return strings.TrimSpace(verdict.Reply), true
+}
+
+const criticSystemPrompt = `你是桌面机器人回复的审校员。检查草稿回复是否违反以下规则，并只输出一个 JSON 对象：
+1. 不得声称执行了“实际执行的技能”之外的动作；未执行的技能只能说没有执行或稍后再试，不能说已经做了。
+2. 不得提及或承诺可用技能之外的能力。
+3. 不超过长度上限（若给出）。
+4. 保持草稿的语气和说话风格，不要变得生硬。
+没有违反时输出 {"ok":true}；有违反时输出 {"ok":false,"issues":["问题"],"reply":"改正后的完整回复"}，改动尽量小。`
+
+func buildCriticPrompt(in criticInput) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "用户说：%s\n", strings.TrimSpace(in.userText))
+	fmt.Fprintf(&sb, "可用技能：%s\n", listOrNone(in.skills))
+	fmt.Fprintf(&sb, "实际执行的技能：%s\n", listOrNone(in.executed))
+	if len(in.notRun) > 0 {
+		fmt.Fprintf(&sb, "调用了但没有执行的技能：%s（%s）\n", strings.Join(in.notRun, "、"), notRunReason(in))
+	}
+	if in.maxRunes > 0 {
+		fmt.Fprintf(&sb, "长度上限：%d 字\n", in.maxRunes)
+	}
+	if style := strings.TrimSpace(in.speakingStyle); style != "" {
+		fmt.Fprintf(&sb, "说话风格：%s\n", style)
+	}
+	fmt.Fprintf(&sb, "草稿回复：%s", in.reply)
+	return sb.String()
+}
+
+func notRunReason(in criticInput) string {
+	switch {
+	case in.dryRun:
+		return "演练模式，不执行任何动作"
+	case in.quiet:
+		return "免打扰时段，部分动作暂缓"
+	case strings.TrimSpace(in.execMode) == "blocked":
+		return "情绪门控锁定，动作被拦下"
+	}
+	return "技能不存在或被拦下"
+}
+
+func listOrNone(items []string) string {
+	if len(items) == 0 {
+		return "无"
+	}
+	return strings.Join(items, "、")
+}
+
+// parseCriticVerdict reads the JSON object in content, tolerating text or
+// code fences around it.
+func parseCriticVerdict(content string) (criticVerdict, bool) {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return criticVerdict{}, false
+	}
+	var v criticVerdict
+	if err := json.Unmarshal([]byte(content[start:end+1]), &v); err != nil {
+		return criticVerdict{}, false
+	}
+	return v, true
+}
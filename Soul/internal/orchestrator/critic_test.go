@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"soul/internal/llm"
+)
+
+func TestNeedsCritic(t *testing.T) {
+	cases := []struct {
+		mode string
+		in   criticInput
+		want bool
+	}{
+		{CriticOff, criticInput{reply: "灯已打开", notRun: []string{"set_light"}}, false},
+		{CriticAlways, criticInput{reply: "你好"}, true},
+		{CriticAlways, criticInput{reply: " "}, false},
+		{CriticRisky, criticInput{reply: "好的", notRun: []string{"set_light"}}, true},
+		{CriticRisky, criticInput{reply: "已经帮你打开台灯啦"}, true},
+		{CriticRisky, criticInput{reply: "已经帮你打开台灯啦", executed: []string{"set_light"}}, false},
+		{CriticRisky, criticInput{reply: "今天天气不错"}, false},
+	}
+	for _, c := range cases {
+		if got := needsCritic(c.mode, c.in); got != c.want {
+			t.Errorf("needsCritic(%s, %+v) = %v, want %v", c.mode, c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseCriticVerdict(t *testing.T) {
+	v, ok := parseCriticVerdict("```json\n{\"ok\":false,\"issues\":[\"声称已开灯\"],\"reply\":\"现在还不能开灯哦\"}\n```")
+	if !ok || v.OK || v.Reply != "现在还不能开灯哦" || len(v.Issues) != 1 {
+		t.Fatalf("unexpected verdict %+v %v", v, ok)
+	}
+	if _, ok := parseCriticVerdict("没有问题"); ok {
+		t.Fatal("text without JSON must not parse")
+	}
+}
+
+func TestCritiqueReplyFixesBlockedClaim(t *testing.T) {
+	provider := llm.NewMockProvider(llm.MockFixture{Rules: []llm.MockRule{
+		{Match: "草稿回复：好的，灯已打开", Response: llm.MockResponse{Content: `{"ok":false,"issues":["set_light 未执行"],"reply":"我现在有点不想动，灯还没开哦。"}`}},
+		{Response: llm.MockResponse{Content: `{"ok":true}`}},
+	}})
+	svc := &Service{
+		llmProvider: provider,
+		llmModel:    "chat",
+		critic:      CriticConfig{Mode: CriticRisky, Model: "cheap"},
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	in := criticInput{userText: "开灯", reply: "好的，灯已打开", skills: []string{"set_light"}, notRun: []string{"set_light"}, execMode: "blocked"}
+	revised, ok := svc.critiqueReply(context.Background(), in)
+	if !ok || revised != "我现在有点不想动，灯还没开哦。" {
+		t.Fatalf("expected a revised reply, got %q %v", revised, ok)
+	}
+	calls := provider.Calls()
+	if len(calls) != 1 || calls[0].Model != "cheap" || !strings.Contains(calls[0].Messages[0].Content, "情绪门控锁定") {
+		t.Fatalf("unexpected critic request %+v", calls)
+	}
+
+	in.reply = "好的，我试试"
+	if _, ok := svc.critiqueReply(context.Background(), in); ok {
+		t.Fatal("an approved draft must stand")
+	}
+}
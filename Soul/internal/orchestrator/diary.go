@@ -0,0 +1,70 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// diaryReadVerbs ask for the soul's diary to be read out ("读一下你的日记").
+var diaryReadVerbs = []string{"读", "念", "讲讲", "说说", "听听", "看看"}
+
+// diaryCommand reports whether text asks the soul to read its diary. Like
+// the other commands only short utterances count.
+func diaryCommand(text string) bool {
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) > 16 || !strings.Contains(text, "日记") {
+		return false
+	}
+	return containsAny(text, diaryReadVerbs...)
+}
+
+// diaryReply introduces the entry by its day, so the reply reads well
+// when spoken.
+func diaryReply(entry domain.SoulDiaryEntry, now time.Time) string {
+	when := entry.Day
+	if day, err := time.ParseInLocation(time.DateOnly, entry.Day, now.Location()); err == nil {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		switch {
+		case day.Equal(today):
+			when = "今天"
+		case day.Equal(today.AddDate(0, 0, -1)):
+			when = "昨天"
+		default:
+			when = fmt.Sprintf("%d月%d日", day.Month(), day.Day())
+		}
+	}
+	return fmt.Sprintf("这是我%s的日记：%s", when, entry.Content)
+}
+
+// readDiary answers a diary request with the soul's latest entry, without
+// the LLM; the reply is spoken like any other.
+func (s *Service) readDiary(ctx context.Context, req domain.ChatRequest, userID, soulID, text string, speaker *domain.SpeakerIdentity, followUp, private bool) (domain.ChatResponse, error) {
+	entries, err := s.memoryService.ListSoulDiary(ctx, soulID, 1)
+	if err != nil {
+		return domain.ChatResponse{}, err
+	}
+	reply := "我还没有写过日记呢，晚上再来听吧。"
+	if len(entries) > 0 {
+		reply = diaryReply(entries[0], time.Now())
+	}
+	turn := s.memoryService.BeginTurn(req.SessionID, userID, req.TerminalID, soulID)
+	turn.AddMessage("user", "", "", text)
+	turn.AddMessage("assistant", "", "", reply)
+	if err := s.memoryService.CommitTurn(ctx, turn); err != nil {
+		return domain.ChatResponse{}, err
+	}
+	s.openFollowUpWindow(ctx, req.TerminalID, req.SessionID)
+	return domain.ChatResponse{
+		SessionID:  req.SessionID,
+		TerminalID: req.TerminalID,
+		SoulID:     soulID,
+		Reply:      reply,
+		Speaker:    speaker,
+		FollowUp:   followUp,
+		Private:    private,
+	}, nil
+}
@@ -0,0 +1,35 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestDiaryCommand(t *testing.T) {
+	for _, text := range []string{"读一下你的日记", "给我念念今天的日记", "说说你的日记吧"} {
+		if !diaryCommand(text) {
+			t.Errorf("%q must read the diary", text)
+		}
+	}
+	for _, text := range []string{"你会写日记吗", "读一本书", "我今天读了一篇很长很长的日记，里面写了好多关于旅行的事"} {
+		if diaryCommand(text) {
+			t.Errorf("%q must go through the normal turn", text)
+		}
+	}
+}
+
+func TestDiaryReply(t *testing.T) {
+	now := time.Date(2026, 10, 16, 21, 0, 0, 0, time.Local)
+	cases := map[string]string{
+		"2026-10-16": "这是我今天的日记：好开心",
+		"2026-10-15": "这是我昨天的日记：好开心",
+		"2026-10-02": "这是我10月2日的日记：好开心",
+	}
+	for day, want := range cases {
+		if got := diaryReply(domain.SoulDiaryEntry{Day: day, Content: "好开心"}, now); got != want {
+			t.Errorf("%s: got %q, want %q", day, got, want)
+		}
+	}
+}
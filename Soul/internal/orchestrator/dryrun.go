@@ -0,0 +1,56 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// Dry-run turns go through intent matching and tool selection as usual, but
+// every action that would reach the terminal is replaced by a status event
+// describing it. This lets new intent catalogs be exercised against production
+// souls without moving any hardware.
+const (
+	statusDryRunIntent = "dry_run_intent"
+	statusDryRunSkill  = "dry_run_skill"
+)
+
+func (s *Service) isDryRun(req domain.ChatRequest) bool {
+	return req.DryRun || s.skillRegistry.IsDryRun(req.TerminalID)
+}
+
+func (s *Service) publishDryRun(ctx context.Context, terminalID, sessionID, status, message string) {
+	s.logger.Info("dry run", "terminal_id", terminalID, "session_id", sessionID, "status", status, "message", message)
+	publisher, ok := s.eventPublisher().(StatusPublisher)
+	if !ok {
+		return
+	}
+	if err := publisher.PublishStatus(ctx, terminalID, status, message, sessionID); err != nil {
+		s.logger.Warn("publish dry run status failed", "terminal_id", terminalID, "status", status, "error", err)
+	}
+}
+
+func dryRunIntentMessage(items []domain.IntentActionItem, execMode string) string {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		params := "{}"
+		if len(item.Parameters) > 0 {
+			if raw, err := json.Marshal(item.Parameters); err == nil {
+				params = string(raw)
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%s%s", item.IntentID, params))
+	}
+	return fmt.Sprintf("[演练] 将执行意图 %s（mode=%s）", strings.Join(parts, ", "), strings.TrimSpace(execMode))
+}
+
+func dryRunSkillMessage(skill string, args json.RawMessage, execMode string) string {
+	params := strings.TrimSpace(string(args))
+	if params == "" {
+		params = "{}"
+	}
+	return fmt.Sprintf("[演练] 将执行技能 %s%s（mode=%s）", skill, params, strings.TrimSpace(execMode))
+}
@@ -0,0 +1,81 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/skills"
+)
+
+type dryRunInvoker struct {
+	invokes  []string
+	statuses []string
+}
+
+func (d *dryRunInvoker) InvokeSkill(_ context.Context, _ string, skill string, _ json.RawMessage) (domain.InvokeResult, error) {
+	d.invokes = append(d.invokes, skill)
+	return domain.InvokeResult{OK: true, Output: "done"}, nil
+}
+
+func (d *dryRunInvoker) PublishStatus(_ context.Context, _ string, status, message, _ string) error {
+	d.statuses = append(d.statuses, status+":"+message)
+	return nil
+}
+
+func TestDryRunSkipsTerminalInvoke(t *testing.T) {
+	registry := skills.NewRegistry(time.Minute)
+	invoker := &dryRunInvoker{}
+	svc := &Service{
+		invoker:       invoker,
+		skillRegistry: registry,
+		toolTimeout:   time.Second,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	if svc.isDryRun(domain.ChatRequest{TerminalID: "t1"}) {
+		t.Fatalf("dry run must be off by default")
+	}
+	registry.SetDryRun("t1", true)
+	if !svc.isDryRun(domain.ChatRequest{TerminalID: "t1"}) {
+		t.Fatalf("terminal dry run setting was not applied")
+	}
+
+	out, _ := svc.executeTerminalSkillWithGate(context.Background(), "t1", "s1", "light_off", json.RawMessage(`{"room":"客厅"}`), "auto_execute", 0.9, true, nil)
+	if len(invoker.invokes) != 0 {
+		t.Fatalf("dry run must not invoke the terminal, got %v", invoker.invokes)
+	}
+	if !strings.Contains(out, "演练模式") {
+		t.Fatalf("unexpected tool output: %q", out)
+	}
+	if len(invoker.statuses) != 1 || !strings.HasPrefix(invoker.statuses[0], statusDryRunSkill+":") || !strings.Contains(invoker.statuses[0], `light_off{"room":"客厅"}`) {
+		t.Fatalf("unexpected dry run status: %v", invoker.statuses)
+	}
+
+	items := []domain.IntentActionItem{{IntentID: "light_off", Parameters: map[string]any{"room": "客厅"}}}
+	if got := dryRunIntentMessage(items, "auto_execute"); !strings.Contains(got, `light_off{"room":"客厅"}`) {
+		t.Fatalf("unexpected intent message: %q", got)
+	}
+}
+
+func TestDryRunLeavesMemoryUncorrected(t *testing.T) {
+	invoker := &dryRunInvoker{}
+	// No memory service: a dry run must not reach it.
+	svc := &Service{
+		invoker: invoker,
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	out := svc.executeCorrectMemoryTool(context.Background(), json.RawMessage(`{"original":"喜欢咖啡","correction":"喜欢茶"}`), "t1", "s1", "u1", "soul1", true)
+	if !strings.Contains(out, "演练模式") || !strings.Contains(out, "喜欢咖啡") {
+		t.Fatalf("unexpected tool output: %q", out)
+	}
+	if len(invoker.statuses) != 1 || !strings.Contains(invoker.statuses[0], correctMemoryToolName) {
+		t.Fatalf("unexpected dry run status: %v", invoker.statuses)
+	}
+}
@@ -15,7 +15,7 @@ func (s *Service) RunEmotionDecayPublisher(ctx context.Context, interval time.Du
 	if s == nil || s.personaEngine == nil || s.memoryService == nil || s.skillRegistry == nil {
 		return
 	}
-	publisher, ok := s.invoker.(EmotionPublisher)
+	publisher, ok := s.eventPublisher().(EmotionPublisher)
 	if !ok {
 		return
 	}
@@ -36,12 +36,15 @@ func (s *Service) RunEmotionDecayPublisher(ctx context.Context, interval time.Du
 		case <-ctx.Done():
 			return
 		case tickAt := <-ticker.C:
-			s.publishEmotionDecayTick(ctx, publisher, tickAt.UTC())
+			s.publishEmotionDecayTick(ctx, publisher, tickAt.UTC(), interval)
 		}
 	}
 }
 
-func (s *Service) publishEmotionDecayTick(ctx context.Context, publisher EmotionPublisher, now time.Time) {
+// publishEmotionDecayTick decays and publishes each online soul's emotion.
+// Every instance ticks, so a soul another instance is ticking, or has
+// updated within half an interval, is left to that instance.
+func (s *Service) publishEmotionDecayTick(ctx context.Context, publisher EmotionPublisher, now time.Time, interval time.Duration) {
 	states := s.skillRegistry.ListOnlineStates()
 	if len(states) == 0 {
 		return
@@ -66,30 +69,44 @@ func (s *Service) publishEmotionDecayTick(ctx context.Context, publisher Emotion
 			continue
 		}
 
-		s.emotionMu.Lock()
+		unlock, ok := s.tryLockSoulEmotion(ctx, soulID)
+		if !ok {
+			continue
+		}
 		soulProfile, err := s.memoryService.GetSoulProfileByID(ctx, soulID)
 		if err != nil {
-			s.emotionMu.Unlock()
+			unlock()
 			s.logger.Warn("emotion decay tick: load soul profile failed", "terminal_id", terminalID, "soul_id", soulID, "error", err)
 			continue
 		}
+		if recentlyUpdated(soulProfile.EmotionState, now, interval/2) {
+			unlock()
+			continue
+		}
 
-		result := s.personaEngine.Update(
-			soulProfile.PersonalityVector,
-			soulProfile.EmotionState,
-			persona.UpdateInput{
-				Now:          now,
-				UserEmotion:  neutral,
-				HasUserInput: false,
-			},
-			personaBaseExecProb,
-		)
-		if err := s.memoryService.UpdateSoulEmotionState(ctx, soulID, result.State); err != nil {
-			s.emotionMu.Unlock()
+		var result persona.UpdateResult
+		timeScale := s.decayScale(terminalID)
+		soulProfile, err = s.storeSoulEmotion(ctx, soulProfile, func(p domain.SoulProfile) domain.SoulEmotionState {
+			result = s.personaEngine.Update(
+				p.PersonalityVector,
+				p.EmotionState,
+				persona.UpdateInput{
+					Now:          now,
+					UserEmotion:  neutral,
+					HasUserInput: false,
+					TimeScale:    timeScale,
+				},
+				personaBaseExecProb,
+			)
+			return result.State
+		})
+		if err != nil {
+			unlock()
 			s.logger.Warn("emotion decay tick: update soul emotion state failed", "terminal_id", terminalID, "soul_id", soulID, "error", err)
 			continue
 		}
-		s.emotionMu.Unlock()
+		unlock()
+		s.reportGateLock(ctx, emotionDecaySessionID, terminalID, soulID, soulProfile.EmotionState, result.State, now)
 
 		payload := domain.EmotionUpdatePayload{
 			SessionID:       emotionDecaySessionID,
@@ -106,3 +123,13 @@ func (s *Service) publishEmotionDecayTick(ctx context.Context, publisher Emotion
 		}
 	}
 }
+
+// recentlyUpdated reports whether state was written, by a turn or another
+// instance's tick, less than d before now.
+func recentlyUpdated(state domain.SoulEmotionState, now time.Time, d time.Duration) bool {
+	at, err := time.Parse(time.RFC3339Nano, state.LastUpdatedAt)
+	if err != nil {
+		return false
+	}
+	return now.Sub(at) < d
+}
@@ -0,0 +1,45 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+// emotionWriteAttempts bounds how often a soul's emotion update is
+// reapplied after losing a race to another writer.
+const emotionWriteAttempts = 5
+
+// soulEmotionStore is the part of memory.Service emotion writes go through.
+type soulEmotionStore interface {
+	GetSoulProfileByID(ctx context.Context, soulID string) (domain.SoulProfile, error)
+	CompareAndSetSoulEmotionState(ctx context.Context, soulID string, version int64, state domain.SoulEmotionState) (int64, error)
+}
+
+// storeSoulEmotion writes next(profile) over profile's emotion state. emotionMu
+// only orders writers within this process; when another replica has
+// written the soul since profile was read, the soul is reloaded and next
+// is applied again, so neither change is lost. It returns the profile the
+// stored state was computed from. Callers hold lockSoulEmotion, and next must
+// not have side effects, since it can run more than once.
+func (s *Service) storeSoulEmotion(ctx context.Context, profile domain.SoulProfile, next func(domain.SoulProfile) domain.SoulEmotionState) (domain.SoulProfile, error) {
+	return storeSoulEmotion(ctx, s.memoryService, profile, next)
+}
+
+func storeSoulEmotion(ctx context.Context, store soulEmotionStore, profile domain.SoulProfile, next func(domain.SoulProfile) domain.SoulEmotionState) (domain.SoulProfile, error) {
+	for attempt := 1; ; attempt++ {
+		_, err := store.CompareAndSetSoulEmotionState(ctx, profile.SoulID, profile.EmotionVersion, next(profile))
+		if !errors.Is(err, db.ErrSoulEmotionConflict) {
+			return profile, err
+		}
+		if attempt == emotionWriteAttempts {
+			return profile, fmt.Errorf("%w after %d attempts", err, attempt)
+		}
+		if profile, err = store.GetSoulProfileByID(ctx, profile.SoulID); err != nil {
+			return profile, err
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+// casSoulStore is one soul row with a version, like souls.emotion_version.
+// racer, when set, writes as another replica just before each CAS.
+type casSoulStore struct {
+	profile domain.SoulProfile
+	racer   func(*domain.SoulProfile)
+	loads   int
+}
+
+func (c *casSoulStore) GetSoulProfileByID(_ context.Context, _ string) (domain.SoulProfile, error) {
+	c.loads++
+	return c.profile, nil
+}
+
+func (c *casSoulStore) CompareAndSetSoulEmotionState(_ context.Context, _ string, version int64, state domain.SoulEmotionState) (int64, error) {
+	if c.racer != nil {
+		c.racer(&c.profile)
+	}
+	if version != c.profile.EmotionVersion {
+		return 0, db.ErrSoulEmotionConflict
+	}
+	c.profile.EmotionState = state
+	c.profile.EmotionVersion++
+	return c.profile.EmotionVersion, nil
+}
+
+func addP(d float64) func(domain.SoulProfile) domain.SoulEmotionState {
+	return func(p domain.SoulProfile) domain.SoulEmotionState {
+		state := p.EmotionState
+		state.P += d
+		return state
+	}
+}
+
+func TestStoreSoulEmotionReappliesAfterConflict(t *testing.T) {
+	store := &casSoulStore{profile: domain.SoulProfile{SoulID: "soul_a", EmotionVersion: 3}}
+	read := store.profile
+	races := 1
+	store.racer = func(p *domain.SoulProfile) {
+		if races > 0 {
+			races--
+			p.EmotionState.P += 0.5
+			p.EmotionVersion++
+		}
+	}
+
+	base, err := storeSoulEmotion(context.Background(), store, read, addP(0.25))
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if store.profile.EmotionState.P != 0.75 || store.profile.EmotionVersion != 5 {
+		t.Fatalf("both writes must land, got %+v", store.profile)
+	}
+	if store.loads != 1 || base.EmotionVersion != 4 || base.EmotionState.P != 0.5 {
+		t.Fatalf("expected one reload and the racer's state as base, loads=%d base=%+v", store.loads, base)
+	}
+}
+
+func TestStoreSoulEmotionGivesUp(t *testing.T) {
+	store := &casSoulStore{profile: domain.SoulProfile{SoulID: "soul_a"}}
+	store.racer = func(p *domain.SoulProfile) { p.EmotionVersion++ }
+
+	_, err := storeSoulEmotion(context.Background(), store, store.profile, addP(0.25))
+	if !errors.Is(err, db.ErrSoulEmotionConflict) {
+		t.Fatalf("expected a conflict error, got %v", err)
+	}
+	if store.loads != emotionWriteAttempts-1 || store.profile.EmotionState.P != 0 {
+		t.Fatalf("expected %d reloads and no write, loads=%d profile=%+v", emotionWriteAttempts-1, store.loads, store.profile)
+	}
+}
+
+func TestRecentlyUpdated(t *testing.T) {
+	now := time.Now().UTC()
+	state := domain.SoulEmotionState{LastUpdatedAt: now.Add(-time.Second).Format(time.RFC3339Nano)}
+	if !recentlyUpdated(state, now, 2*time.Second) {
+		t.Fatal("a write a second ago is recent within two seconds")
+	}
+	if recentlyUpdated(state, now, 500*time.Millisecond) {
+		t.Fatal("a write a second ago is not recent within half a second")
+	}
+	if recentlyUpdated(domain.SoulEmotionState{}, now, time.Hour) {
+		t.Fatal("a state never written is not recent")
+	}
+}
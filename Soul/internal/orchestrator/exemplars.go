@@ -0,0 +1,55 @@
+package orchestrator
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"soul/internal/domain"
+)
+
+// exemplarMessages turns a soul's example exchanges into leading user and
+// assistant messages, in order, stopping at the first one that would go
+// over the token budget.
+func exemplarMessages(items []domain.SoulExemplar, budget int) []domain.Message {
+	if budget <= 0 || len(items) == 0 {
+		return nil
+	}
+	out := make([]domain.Message, 0, 2*len(items))
+	used := 0
+	for _, item := range items {
+		cost := estimateTokens(item.User) + estimateTokens(item.Reply)
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		out = append(out,
+			domain.Message{Role: "user", Content: item.User},
+			domain.Message{Role: "assistant", Content: item.Reply},
+		)
+	}
+	return out
+}
+
+// estimateTokens approximates a tokenizer: a CJK character is about one
+// token, other text about four bytes per token.
+func estimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// buildExemplarNotes tells the LLM the leading exchanges only show the
+// soul's voice.
+func buildExemplarNotes(pairs int) string {
+	if pairs == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n对话开头的 %d 轮是说话风格示范，不是真实发生的对话：模仿其语气和用词，不要引用其中的内容。\n", pairs)
+}
@@ -0,0 +1,34 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestExemplarMessagesStayWithinBudget(t *testing.T) {
+	items := []domain.SoulExemplar{
+		{User: "你好呀", Reply: "嘿嘿，你来啦！"},
+		{User: "今天好累", Reply: "辛苦啦，先喝口水歇一歇～"},
+		{User: "讲个笑话", Reply: "从前有只小熊，它一直在冬眠，结果错过了所有的笑话。"},
+	}
+	msgs := exemplarMessages(items, 30)
+	if len(msgs) != 4 {
+		t.Fatalf("want the first two exchanges within budget, got %d messages", len(msgs))
+	}
+	if msgs[0].Role != "user" || msgs[0].Content != "你好呀" || msgs[1].Role != "assistant" || msgs[1].Content != "嘿嘿，你来啦！" {
+		t.Fatalf("exemplars out of order: %+v", msgs)
+	}
+	if got := exemplarMessages(items, 0); got != nil {
+		t.Fatalf("a zero budget must leave exemplars out, got %+v", got)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if got := estimateTokens("你好"); got != 2 {
+		t.Fatalf("estimateTokens(你好) = %d, want 2", got)
+	}
+	if got := estimateTokens("hello world!"); got != 3 {
+		t.Fatalf("estimateTokens(hello world!) = %d, want 3", got)
+	}
+}
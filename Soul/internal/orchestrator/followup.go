@@ -0,0 +1,120 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// followUpTracker keeps a short per-terminal window after each reply during
+// which new speech continues the same session/topic without a wake word.
+type followUpTracker struct {
+	mu      sync.Mutex
+	window  time.Duration
+	windows map[string]*followUpWindow
+}
+
+type followUpWindow struct {
+	sessionID string
+	until     time.Time
+	timer     *time.Timer
+}
+
+func newFollowUpTracker(window time.Duration) *followUpTracker {
+	return &followUpTracker{
+		window:  window,
+		windows: make(map[string]*followUpWindow),
+	}
+}
+
+func (t *followUpTracker) enabled() bool {
+	return t != nil && t.window > 0
+}
+
+// consume closes the terminal's open window and reports the session it belonged
+// to when now is still inside it.
+func (t *followUpTracker) consume(terminalID string, now time.Time) (string, bool) {
+	if !t.enabled() {
+		return "", false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	w, ok := t.windows[terminalID]
+	if !ok {
+		return "", false
+	}
+	delete(t.windows, terminalID)
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	if now.After(w.until) {
+		return "", false
+	}
+	return w.sessionID, true
+}
+
+// continuesFollowUp reports whether req carries on the window opened for
+// sessionID. Only speech does, and only when it names no session or that
+// one: typed messages and fork replays stay in the session they name.
+func continuesFollowUp(req domain.ChatRequest, sessionID string) bool {
+	if req.SessionID != "" && req.SessionID != sessionID {
+		return false
+	}
+	for _, in := range req.Inputs {
+		if strings.EqualFold(strings.TrimSpace(in.Type), "speech_text") {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *followUpTracker) open(terminalID, sessionID string, now time.Time, onExpire func()) {
+	if !t.enabled() {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if prev, ok := t.windows[terminalID]; ok && prev.timer != nil {
+		prev.timer.Stop()
+	}
+	w := &followUpWindow{sessionID: sessionID, until: now.Add(t.window)}
+	w.timer = time.AfterFunc(t.window, func() {
+		t.mu.Lock()
+		current, ok := t.windows[terminalID]
+		if !ok || current != w {
+			t.mu.Unlock()
+			return
+		}
+		delete(t.windows, terminalID)
+		t.mu.Unlock()
+		if onExpire != nil {
+			onExpire()
+		}
+	})
+	t.windows[terminalID] = w
+}
+
+func (s *Service) openFollowUpWindow(ctx context.Context, terminalID, sessionID string) {
+	if !s.followUps.enabled() {
+		return
+	}
+	publisher, _ := s.eventPublisher().(StatusPublisher)
+	s.followUps.open(terminalID, sessionID, time.Now(), func() {
+		if publisher == nil {
+			return
+		}
+		if err := publisher.PublishStatus(context.Background(), terminalID, "follow_up_closed", "追问窗口已关闭。", sessionID); err != nil {
+			s.logger.Warn("publish status failed", "status", "follow_up_closed", "error", err)
+		}
+	})
+	if publisher != nil {
+		msg := fmt.Sprintf("追问窗口已开启（%ds），可直接继续说话。", int(s.followUps.window.Seconds()))
+		if err := publisher.PublishStatus(ctx, terminalID, "follow_up_open", msg, sessionID); err != nil {
+			s.logger.Warn("publish status failed", "status", "follow_up_open", "error", err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestFollowUpTrackerConsumeWithinWindow(t *testing.T) {
+	tracker := newFollowUpTracker(time.Minute)
+	now := time.Now()
+	tracker.open("t1", "s1", now, nil)
+
+	sessionID, ok := tracker.consume("t1", now.Add(10*time.Second))
+	if !ok || sessionID != "s1" {
+		t.Fatalf("expected follow-up for s1, got %q ok=%v", sessionID, ok)
+	}
+	if _, ok := tracker.consume("t1", now.Add(11*time.Second)); ok {
+		t.Fatalf("window must be single-use")
+	}
+}
+
+func TestFollowUpTrackerExpiredOrDisabled(t *testing.T) {
+	tracker := newFollowUpTracker(time.Minute)
+	now := time.Now()
+	tracker.open("t1", "s1", now, nil)
+	if _, ok := tracker.consume("t1", now.Add(2*time.Minute)); ok {
+		t.Fatalf("expired window must not count as follow-up")
+	}
+
+	disabled := newFollowUpTracker(0)
+	disabled.open("t1", "s1", now, nil)
+	if _, ok := disabled.consume("t1", now); ok {
+		t.Fatalf("disabled tracker must never report follow-up")
+	}
+}
+
+func TestFollowUpOnlyContinuesSpeechInItsSession(t *testing.T) {
+	speech := []domain.ChatInput{{Type: "speech_text", Text: "那明天呢"}}
+	typed := []domain.ChatInput{{Type: "keyboard_text", Text: "那明天呢"}}
+	cases := []struct {
+		name string
+		req  domain.ChatRequest
+		want bool
+	}{
+		{"speech without a session", domain.ChatRequest{Inputs: speech}, true},
+		{"speech in the window's session", domain.ChatRequest{SessionID: "s1", Inputs: speech}, true},
+		{"speech naming another session", domain.ChatRequest{SessionID: "s1_replay", Inputs: speech}, false},
+		{"typed text", domain.ChatRequest{Inputs: typed}, false},
+	}
+	for _, c := range cases {
+		if got := continuesFollowUp(c.req, "s1"); got != c.want {
+			t.Errorf("%s: continuesFollowUp = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
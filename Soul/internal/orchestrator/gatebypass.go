@@ -0,0 +1,78 @@
+package orchestrator
+
+import (
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// gateBypass names the skills the emotion gate never holds back, on top of
+// those the terminal marks bypass_gate. Stopping the robot must not depend
+// on its mood.
+type gateBypass map[string]struct{}
+
+func newGateBypass(skills []string) gateBypass {
+	out := make(gateBypass, len(skills))
+	for _, name := range skills {
+		if name = strings.TrimSpace(name); name != "" {
+			out[name] = struct{}{}
+		}
+	}
+	return out
+}
+
+// bypassesGate reports whether skill runs whatever the emotion gate says.
+func (s *Service) bypassesGate(terminalID, skill string) bool {
+	skill = strings.TrimSpace(skill)
+	if skill == "" {
+		return false
+	}
+	if _, ok := s.gateBypass[skill]; ok {
+		return true
+	}
+	def, ok := s.skillRegistry.FindSkill(terminalID, skill)
+	return ok && def.BypassGate
+}
+
+// gateAllows reports whether skill may execute under execMode.
+func (s *Service) gateAllows(terminalID, skill, execMode string) bool {
+	return strings.TrimSpace(execMode) == "auto_execute" || s.bypassesGate(terminalID, skill)
+}
+
+// bypassGateForIntents lets the ready intents whose skill bypasses the gate
+// run while the gate is blocked. The other intents are dropped, so the reply
+// only reports what was sent; when none bypass, resp and execMode are
+// returned unchanged and the whole intent is held back as before.
+func (s *Service) bypassGateForIntents(terminalID string, resp domain.IntentFilterResponse, execMode string) (domain.IntentFilterResponse, string) {
+	if strings.TrimSpace(execMode) == "auto_execute" || strings.TrimSpace(resp.Decision.Action) != "execute_intents" {
+		return resp, execMode
+	}
+	bypassed := false
+	for _, in := range resp.Intents {
+		if strings.TrimSpace(in.Status) == "ready" && s.bypassesGate(terminalID, intentSkill(in)) {
+			bypassed = true
+			break
+		}
+	}
+	if !bypassed {
+		return resp, execMode
+	}
+	resp = dropIntentsBySkill(resp, func(skill string) bool { return !s.bypassesGate(terminalID, skill) })
+	return resp, "auto_execute"
+}
+
+// buildGateBypassNotes tells the LLM which offered skills the emotion gate
+// never holds back, so it still calls them when the gate is blocked.
+func (s *Service) buildGateBypassNotes(terminalID string, offered []domain.SkillDefinition) string {
+	names := make([]string, 0, len(offered))
+	for _, def := range offered {
+		if s.bypassesGate(terminalID, def.Name) {
+			names = append(names, def.Name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n紧急技能：%s 不受情绪门控限制，即使处于 blocked，用户要求时也照常调用。\n", strings.Join(names, "、"))
+}
@@ -0,0 +1,71 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/skills"
+)
+
+func TestGateBypassSkills(t *testing.T) {
+	registry := skills.NewRegistry(time.Minute)
+	registry.SetSkills("t1", "soul-1", 1, []domain.SkillDefinition{
+		{Name: "stop_motion", BypassGate: true},
+		{Name: "dance"},
+	})
+	invoker := &dryRunInvoker{}
+	svc := &Service{
+		invoker:       invoker,
+		skillRegistry: registry,
+		toolTimeout:   time.Second,
+		gateBypass:    newGateBypass([]string{"emergency_stop"}),
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	for _, skill := range []string{"stop_motion", "emergency_stop"} {
+		if !svc.gateAllows("t1", skill, "blocked") {
+			t.Fatalf("%s must bypass the gate", skill)
+		}
+	}
+	if svc.gateAllows("t1", "dance", "blocked") || !svc.gateAllows("t1", "dance", "auto_execute") {
+		t.Fatalf("dance must follow the gate")
+	}
+
+	out, _ := svc.executeTerminalSkillWithGate(context.Background(), "t1", "s1", "dance", json.RawMessage(`{}`), "blocked", 0.1, false, nil)
+	if !strings.Contains(out, "技能执行已拦截") {
+		t.Fatalf("dance must be held back, got %q", out)
+	}
+	if out, _ := svc.executeTerminalSkillWithGate(context.Background(), "t1", "s1", "stop_motion", json.RawMessage(`{}`), "blocked", 0.1, false, nil); out != "done" {
+		t.Fatalf("stop_motion must run, got %q", out)
+	}
+	if len(invoker.invokes) != 1 || invoker.invokes[0] != "stop_motion" {
+		t.Fatalf("unexpected invokes %v", invoker.invokes)
+	}
+
+	resp := domain.IntentFilterResponse{
+		Decision: domain.IntentFilterDecision{Action: "execute_intents"},
+		Intents: []domain.SelectedIntent{
+			{IntentID: "stop", Status: "ready", Normalized: map[string]any{"skill": "stop_motion"}},
+			{IntentID: "dance", Status: "ready", Normalized: map[string]any{"skill": "dance"}},
+		},
+	}
+	got, mode := svc.bypassGateForIntents("t1", resp, "blocked")
+	if mode != "auto_execute" || len(got.Intents) != 1 || got.Intents[0].IntentID != "stop" {
+		t.Fatalf("unexpected bypass result mode=%s intents=%+v", mode, got.Intents)
+	}
+	resp.Intents = resp.Intents[1:]
+	if got, mode := svc.bypassGateForIntents("t1", resp, "blocked"); mode != "blocked" || len(got.Intents) != 1 {
+		t.Fatalf("intents without bypass must stay held back, mode=%s", mode)
+	}
+
+	notes := svc.buildGateBypassNotes("t1", registry.GetSkills("t1"))
+	if !strings.Contains(notes, "stop_motion") || strings.Contains(notes, "dance") {
+		t.Fatalf("unexpected notes %q", notes)
+	}
+}
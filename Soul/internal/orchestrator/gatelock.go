@@ -0,0 +1,67 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/persona"
+)
+
+type GateLockPublisher interface {
+	PublishGateLock(ctx context.Context, terminalID string, event domain.GateLockEvent) error
+}
+
+const gateLockSootheHint = "温柔地安慰它、说些让它开心的话，可以让它更快平静下来。"
+
+// reportGateLock logs and publishes a move of the soul's emotion lock, so a
+// terminal can say why actions are refused instead of failing silently.
+func (s *Service) reportGateLock(ctx context.Context, sessionID, terminalID, soulID string, prev, next domain.SoulEmotionState, now time.Time) {
+	change, ok := persona.DescribeLockChange(prev, next, now)
+	if !ok {
+		return
+	}
+	event := buildGateLockEvent(change, next)
+	event.SessionID, event.TerminalID, event.SoulID = sessionID, terminalID, soulID
+	event.TS = now.UTC().Format(time.RFC3339Nano)
+	s.logger.Info("emotion gate lock changed", "session_id", sessionID, "terminal_id", terminalID, "soul_id", soulID, "event", event.Event, "reason", event.Reason, "remaining_seconds", event.RemainingSeconds)
+
+	publisher, ok := s.eventPublisher().(GateLockPublisher)
+	if !ok {
+		return
+	}
+	if err := publisher.PublishGateLock(ctx, terminalID, event); err != nil {
+		s.logger.Warn("publish gate lock failed", "terminal_id", terminalID, "soul_id", soulID, "error", err)
+	}
+}
+
+func buildGateLockEvent(change persona.LockChange, state domain.SoulEmotionState) domain.GateLockEvent {
+	event := domain.GateLockEvent{
+		Event:            change.Event,
+		Reason:           change.Reason,
+		Locked:           change.Event != persona.LockEventUnlocked,
+		RemainingSeconds: change.RemainingSeconds(),
+	}
+	if !event.Locked {
+		event.Message = "机器人已经平静下来，可以正常执行动作了。"
+		return event
+	}
+	event.LockUntil = change.LockUntil.UTC().Format(time.RFC3339Nano)
+	event.Mood = lockMood(state)
+	event.Message = fmt.Sprintf("机器人在%s，剩余%d秒", event.Mood, event.RemainingSeconds)
+	event.SootheHint = gateLockSootheHint
+	return event
+}
+
+// lockMood names the negative state behind a lock from the soul's PAD.
+func lockMood(state domain.SoulEmotionState) string {
+	switch {
+	case state.P < 0 && state.A > 0:
+		return "生气"
+	case state.P < 0:
+		return "难过"
+	default:
+		return "闹情绪"
+	}
+}
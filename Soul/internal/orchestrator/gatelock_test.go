@@ -0,0 +1,32 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/persona"
+)
+
+func TestBuildGateLockEvent(t *testing.T) {
+	now := time.Now().UTC()
+	state := domain.SoulEmotionState{P: -0.8, A: 0.7, LockUntil: now.Add(90 * time.Second).Format(time.RFC3339Nano)}
+	change, ok := persona.DescribeLockChange(domain.SoulEmotionState{}, state, now)
+	if !ok {
+		t.Fatal("expected a lock change")
+	}
+	event := buildGateLockEvent(change, state)
+	if !event.Locked || event.Event != persona.LockEventLocked || event.Message != "机器人在生气，剩余90秒" || event.SootheHint == "" {
+		t.Fatalf("unexpected lock event: %+v", event)
+	}
+
+	unlocked, ok := persona.DescribeLockChange(state, domain.SoulEmotionState{P: -0.2}, now)
+	if !ok {
+		t.Fatal("expected an unlock")
+	}
+	event = buildGateLockEvent(unlocked, domain.SoulEmotionState{P: -0.2})
+	if event.Locked || event.RemainingSeconds != 0 || event.LockUntil != "" || !strings.Contains(event.Message, "平静") {
+		t.Fatalf("unexpected unlock event: %+v", event)
+	}
+}
@@ -0,0 +1,133 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+// soulGroupFloor remembers which soul of a terminal's group spoke last, so
+// an unaddressed follow-up stays with it and a question to everyone goes
+// to the next one.
+type soulGroupFloor struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+func newSoulGroupFloor() *soulGroupFloor {
+	return &soulGroupFloor{last: make(map[string]string)}
+}
+
+func (f *soulGroupFloor) get(terminalID string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.last[terminalID]
+}
+
+func (f *soulGroupFloor) set(terminalID, soulID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.last[terminalID] = soulID
+}
+
+type groupMember struct {
+	soulID string
+	name   string
+}
+
+// soulGroupTurn is the soul chosen to answer a turn on a terminal shared
+// by a group, and the names of all its members.
+type soulGroupTurn struct {
+	soulID  string
+	members []groupMember
+	names   map[string]string
+}
+
+// routeSoulGroup picks which soul of the terminal's group answers the
+// turn; nil when the terminal hosts a single soul or the request names
+// its soul.
+func (s *Service) routeSoulGroup(ctx context.Context, req domain.ChatRequest, userID string) *soulGroupTurn {
+	if strings.TrimSpace(req.SoulID) != "" {
+		return nil
+	}
+	group, err := s.memoryService.GetTerminalSoulGroup(ctx, userID, req.TerminalID)
+	if err != nil {
+		if !errors.Is(err, db.ErrSoulGroupNotFound) {
+			s.logger.Warn("load soul group failed", "terminal_id", req.TerminalID, "error", err)
+		}
+		return nil
+	}
+	turn := &soulGroupTurn{names: make(map[string]string, len(group.SoulIDs))}
+	for _, id := range group.SoulIDs {
+		profile, err := s.memoryService.GetSoulProfileByID(ctx, id)
+		if err != nil {
+			s.logger.Warn("load soul group member failed", "terminal_id", req.TerminalID, "soul_id", id, "error", err)
+			continue
+		}
+		turn.members = append(turn.members, groupMember{soulID: id, name: profile.Name})
+		turn.names[id] = profile.Name
+	}
+	if len(turn.members) < 2 {
+		return nil
+	}
+	texts, _ := extractInputs(req.Inputs)
+	turn.soulID = pickGroupSoul(strings.Join(texts, "\n"), turn.members, s.groupFloor.get(req.TerminalID))
+	s.groupFloor.set(req.TerminalID, turn.soulID)
+	s.skillRegistry.SetSoul(req.TerminalID, turn.soulID)
+	return turn
+}
+
+var groupAddressKeywords = []string{"你们", "大家", "两位", "各位", "everyone", "you two", "both of you", "you guys"}
+
+// pickGroupSoul returns the soul addressed by name, the first one named
+// when several are; the next soul after last when the text speaks to the
+// whole group; else last keeps the floor.
+func pickGroupSoul(text string, members []groupMember, last string) string {
+	lower := strings.ToLower(text)
+	best, bestAt := "", -1
+	for _, m := range members {
+		if m.name == "" {
+			continue
+		}
+		if at := strings.Index(lower, strings.ToLower(m.name)); at >= 0 && (bestAt < 0 || at < bestAt) {
+			best, bestAt = m.soulID, at
+		}
+	}
+	if best != "" {
+		return best
+	}
+	lastAt := -1
+	for i, m := range members {
+		if m.soulID == last {
+			lastAt = i
+		}
+	}
+	if lastAt < 0 {
+		return members[0].soulID
+	}
+	if containsAny(lower, groupAddressKeywords...) {
+		return members[(lastAt+1)%len(members)].soulID
+	}
+	return last
+}
+
+// buildSoulGroupNotes tells the answering soul who else is in the
+// conversation and how their lines appear in the history.
+func buildSoulGroupNotes(turn *soulGroupTurn) string {
+	if turn == nil {
+		return ""
+	}
+	others := make([]string, 0, len(turn.members)-1)
+	for _, m := range turn.members {
+		if m.soulID != turn.soulID {
+			others = append(others, m.name)
+		}
+	}
+	return fmt.Sprintf("\n多灵魂对话：这台设备上还有 %s 与你一起和用户聊天。你是%s，只以自己的身份回复，不要替其他灵魂说话；历史中以【名字】开头的回复来自其他灵魂，你可以自然地接话或回应他们。\n",
+		strings.Join(others, "、"), turn.names[turn.soulID])
+}
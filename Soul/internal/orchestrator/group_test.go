@@ -0,0 +1,41 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPickGroupSoul(t *testing.T) {
+	members := []groupMember{{soulID: "a", name: "小白"}, {soulID: "b", name: "小黑"}, {soulID: "c", name: "Momo"}}
+	cases := []struct {
+		text, last, want string
+	}{
+		{"小黑，你觉得呢", "a", "b"},
+		{"小白和小黑谁先说", "c", "a"},
+		{"momo, what do you think", "a", "c"},
+		{"今天天气怎么样", "b", "b"},
+		{"今天天气怎么样", "", "a"},
+		{"你们觉得呢", "b", "c"},
+		{"大家好", "c", "a"},
+	}
+	for _, tc := range cases {
+		if got := pickGroupSoul(tc.text, members, tc.last); got != tc.want {
+			t.Fatalf("pickGroupSoul(%q, last=%q) = %q, want %q", tc.text, tc.last, got, tc.want)
+		}
+	}
+}
+
+func TestBuildSoulGroupNotes(t *testing.T) {
+	if buildSoulGroupNotes(nil) != "" {
+		t.Fatal("no group, no notes")
+	}
+	turn := &soulGroupTurn{
+		soulID:  "b",
+		members: []groupMember{{soulID: "a", name: "小白"}, {soulID: "b", name: "小黑"}},
+		names:   map[string]string{"a": "小白", "b": "小黑"},
+	}
+	notes := buildSoulGroupNotes(turn)
+	if !strings.Contains(notes, "还有 小白 与你") || !strings.Contains(notes, "你是小黑") {
+		t.Fatalf("unexpected notes: %q", notes)
+	}
+}
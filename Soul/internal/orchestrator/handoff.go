@@ -0,0 +1,54 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// HandoffSession moves an active session to another terminal. History and the
+// soul emotion state stay attached to the session/soul, so only the terminal
+// binding changes; both terminals are notified via status events.
+func (s *Service) HandoffSession(ctx context.Context, sessionID, toTerminalID string) (domain.SessionHandoffResult, error) {
+	sessionID = strings.TrimSpace(sessionID)
+	toTerminalID = strings.TrimSpace(toTerminalID)
+	if sessionID == "" || toTerminalID == "" {
+		return domain.SessionHandoffResult{}, fmt.Errorf("session_id and terminal_id are required")
+	}
+
+	session, err := s.memoryService.GetSession(ctx, sessionID)
+	if err != nil {
+		return domain.SessionHandoffResult{}, err
+	}
+	result := domain.SessionHandoffResult{
+		SessionID:      session.SessionID,
+		UserID:         session.UserID,
+		SoulID:         session.SoulID,
+		FromTerminalID: session.TerminalID,
+		ToTerminalID:   toTerminalID,
+	}
+	if session.TerminalID == toTerminalID {
+		return result, nil
+	}
+
+	if err := s.memoryService.BindTerminalSoul(ctx, session.UserID, toTerminalID, session.SoulID); err != nil {
+		return domain.SessionHandoffResult{}, err
+	}
+	if err := s.memoryService.UpdateSessionTerminal(ctx, sessionID, toTerminalID); err != nil {
+		return domain.SessionHandoffResult{}, err
+	}
+	s.skillRegistry.SetSoul(toTerminalID, session.SoulID)
+
+	if publisher, ok := s.eventPublisher().(StatusPublisher); ok {
+		if err := publisher.PublishStatus(ctx, session.TerminalID, "session_handoff_out", "会话已转移到 "+toTerminalID+"。", sessionID); err != nil {
+			s.logger.Warn("publish status failed", "status", "session_handoff_out", "terminal_id", session.TerminalID, "error", err)
+		}
+		if err := publisher.PublishStatus(ctx, toTerminalID, "session_handoff_in", "会话已从 "+session.TerminalID+" 接入。", sessionID); err != nil {
+			s.logger.Warn("publish status failed", "status", "session_handoff_in", "terminal_id", toTerminalID, "error", err)
+		}
+	}
+	s.logger.Info("session handoff", "session_id", sessionID, "from_terminal_id", session.TerminalID, "to_terminal_id", toTerminalID, "soul_id", session.SoulID)
+	return result, nil
+}
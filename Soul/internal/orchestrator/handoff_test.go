@@ -0,0 +1,109 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"soul/internal/db"
+	"soul/internal/memory"
+	"soul/internal/persona"
+	"soul/internal/skills"
+)
+
+type statusRecorder struct {
+	statuses []string
+}
+
+func (r *statusRecorder) PublishStatus(_ context.Context, terminalID, status, _, sessionID string) error {
+	r.statuses = append(r.statuses, terminalID+":"+status+":"+sessionID)
+	return nil
+}
+
+// TestHandoffSession runs against a real database:
+//
+//	SOUL_TEST_DB_DSN=postgres://... go test ./internal/orchestrator -run HandoffSession
+func TestHandoffSession(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store, err := db.New(ctx, dsn, db.PoolOptions{MaxConns: 2})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	memorySvc, err := memory.NewService(store, memory.ServiceConfig{LLMModel: "mock"}, logger)
+	if err != nil {
+		t.Fatalf("memory service: %v", err)
+	}
+
+	userID := "it_" + uuid.NewString()[:8]
+	if _, err := memorySvc.CreateUser(ctx, userID, userID, ""); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	vector, _ := persona.VectorFromMBTI("INFJ")
+	soul, err := memorySvc.CreateSoulProfile(ctx, userID, "it-soul", "INFJ", vector, persona.InitialEmotionState(time.Now().UTC()), persona.ModelVersion)
+	if err != nil {
+		t.Fatalf("create soul: %v", err)
+	}
+	from, to := "it-from-"+userID, "it-to-"+userID
+	if err := memorySvc.BindTerminalSoul(ctx, userID, from, soul.SoulID); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	sessionID := "it_" + uuid.NewString()
+	if _, err := store.SaveTurn(ctx, db.TurnWrite{
+		SessionID: sessionID, UserID: userID, TerminalID: from, SoulID: soul.SoulID,
+		Messages: []db.PendingMessage{{Role: "user", Content: "你好"}},
+	}); err != nil {
+		t.Fatalf("save turn: %v", err)
+	}
+
+	registry := skills.NewRegistry(time.Minute)
+	publisher := &statusRecorder{}
+	svc := &Service{memoryService: memorySvc, skillRegistry: registry, publisher: publisher, logger: logger}
+
+	// Handing off to the terminal the session is already on changes nothing.
+	result, err := svc.HandoffSession(ctx, sessionID, from)
+	if err != nil || result.FromTerminalID != from || result.ToTerminalID != from {
+		t.Fatalf("same-terminal handoff: %+v %v", result, err)
+	}
+	if len(publisher.statuses) != 0 {
+		t.Fatalf("same-terminal handoff published %v", publisher.statuses)
+	}
+	if _, ok := registry.GetState(from); ok {
+		t.Fatalf("same-terminal handoff touched the registry")
+	}
+
+	result, err = svc.HandoffSession(ctx, sessionID, to)
+	if err != nil || result.FromTerminalID != from || result.ToTerminalID != to || result.SoulID != soul.SoulID {
+		t.Fatalf("handoff: %+v %v", result, err)
+	}
+	session, err := memorySvc.GetSession(ctx, sessionID)
+	if err != nil || session.TerminalID != to {
+		t.Fatalf("session after handoff: %+v %v", session, err)
+	}
+	if bound, err := memorySvc.ResolveSoul(ctx, userID, to, ""); err != nil || bound != soul.SoulID {
+		t.Fatalf("soul bound to %s = %q %v, want %s", to, bound, err, soul.SoulID)
+	}
+	if state, ok := registry.GetState(to); !ok || state.SoulID != soul.SoulID {
+		t.Fatalf("registry soul for %s = %+v", to, state)
+	}
+	want := []string{
+		from + ":session_handoff_out:" + sessionID,
+		to + ":session_handoff_in:" + sessionID,
+	}
+	if len(publisher.statuses) != len(want) || publisher.statuses[0] != want[0] || publisher.statuses[1] != want[1] {
+		t.Fatalf("statuses = %v, want %v", publisher.statuses, want)
+	}
+}
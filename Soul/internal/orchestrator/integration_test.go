@@ -0,0 +1,124 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+	"soul/internal/llm"
+	"soul/internal/memory"
+	"soul/internal/persona"
+	"soul/internal/skills"
+)
+
+type recordingInvoker struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (r *recordingInvoker) InvokeSkill(_ context.Context, _ string, skill string, _ json.RawMessage) (domain.InvokeResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, skill)
+	return domain.InvokeResult{OK: true, Output: "done"}, nil
+}
+
+// TestHandleChatWithMockLLM runs the full chat pipeline against a real
+// database and the scripted mock provider:
+//
+//	SOUL_TEST_DB_DSN=postgres://... go test ./internal/orchestrator -run MockLLM
+func TestHandleChatWithMockLLM(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	store, err := db.New(ctx, dsn, db.PoolOptions{MaxConns: 4})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	provider, err := llm.NewMockProviderFromFile("../llm/testdata/mock_fixture.json")
+	if err != nil {
+		t.Fatalf("load fixture: %v", err)
+	}
+	memorySvc, err := memory.NewService(store, memory.ServiceConfig{LLMProvider: provider, LLMModel: "mock"}, logger)
+	if err != nil {
+		t.Fatalf("memory service: %v", err)
+	}
+
+	userID := "it_" + uuid.NewString()[:8]
+	if _, err := memorySvc.CreateUser(ctx, userID, userID, ""); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	vector, _ := persona.VectorFromMBTI("INFJ")
+	soul, err := memorySvc.CreateSoulProfile(ctx, userID, "it-soul", "INFJ", vector, persona.InitialEmotionState(time.Now().UTC()), persona.ModelVersion)
+	if err != nil {
+		t.Fatalf("create soul: %v", err)
+	}
+
+	terminalID := "it-terminal-" + userID
+	registry := skills.NewRegistry(time.Minute)
+	registry.SetSkills(terminalID, soul.SoulID, 1, []domain.SkillDefinition{{
+		Name:        "light_off",
+		Description: "关灯",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"room":{"type":"string"}}}`),
+	}})
+	invoker := &recordingInvoker{}
+	svc := New(Config{UserID: userID, ChatHistoryLimit: 20, ToolTimeout: time.Second, LLMModel: "mock"}, provider, memorySvc, registry, invoker, nil, nil, nil, logger)
+
+	sessionID := "it_" + uuid.NewString()
+	resp, err := svc.HandleChat(ctx, domain.ChatRequest{
+		SessionID:  sessionID,
+		TerminalID: terminalID,
+		SoulID:     soul.SoulID,
+		Inputs:     []domain.ChatInput{{Type: "keyboard_text", Text: "帮我关灯"}},
+	})
+	if err != nil {
+		t.Fatalf("handle chat: %v", err)
+	}
+	if resp.Reply == "" {
+		t.Fatalf("expected a reply")
+	}
+	if resp.ExecMode == "auto_execute" && (len(invoker.calls) != 1 || invoker.calls[0] != "light_off") {
+		t.Fatalf("expected light_off invocation, got %v", invoker.calls)
+	}
+	history, err := memorySvc.RecentMessages(ctx, sessionID, 20)
+	if err != nil {
+		t.Fatalf("recent messages: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected user, tool and assistant messages, got %+v", history)
+	}
+
+	if _, err := svc.HandleChat(ctx, domain.ChatRequest{
+		SessionID:  sessionID,
+		TerminalID: terminalID,
+		SoulID:     soul.SoulID,
+		Inputs:     []domain.ChatInput{{Type: "keyboard_text", Text: "请报错"}},
+	}); err == nil {
+		t.Fatalf("expected scripted llm failure")
+	}
+	history, err = memorySvc.RecentMessages(ctx, sessionID, 20)
+	if err != nil {
+		t.Fatalf("recent messages: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("failed turn must not persist partial history, got %d messages", len(history))
+	}
+}
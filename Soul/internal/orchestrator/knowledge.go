@@ -0,0 +1,71 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+const searchKnowledgeToolName = "search_knowledge"
+
+// KnowledgeSearcher looks up the documents uploaded to a soul;
+// knowledge.Service does it.
+type KnowledgeSearcher interface {
+	HasDocuments(ctx context.Context, soulID string) (bool, error)
+	Search(ctx context.Context, soulID, query string) ([]domain.KnowledgeHit, error)
+}
+
+var searchKnowledgeTool = domain.LLMTool{
+	Name:        searchKnowledgeToolName,
+	Description: "检索用户上传给你的资料（说明书、笔记等）。用户问到设备用法、资料里的内容等你不确定的事实时调用，按检索到的原文回答并可说明出自哪份资料；查不到就如实说没有找到，不要编造。参数: query(string,必填,要查的问题或关键词)。",
+	Schema:      json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"}},"required":["query"]}`),
+}
+
+// hasKnowledge tells whether search_knowledge is worth offering for
+// soulID; a soul without documents does not get it.
+func (s *Service) hasKnowledge(ctx context.Context, soulID string) bool {
+	if s.knowledge == nil {
+		return false
+	}
+	ok, err := s.knowledge.HasDocuments(ctx, soulID)
+	if err != nil {
+		s.logger.Warn("check knowledge documents failed", "soul_id", soulID, "error", err)
+		return false
+	}
+	return ok
+}
+
+func (s *Service) executeSearchKnowledgeTool(ctx context.Context, args json.RawMessage, latestUserText, soulID string) string {
+	var in struct {
+		Query string `json:"query"`
+	}
+	_ = json.Unmarshal(args, &in)
+	query := strings.TrimSpace(in.Query)
+	if query == "" {
+		query = strings.TrimSpace(latestUserText)
+	}
+	if s.knowledge == nil {
+		return "资料检索不可用。"
+	}
+	hits, err := s.knowledge.Search(ctx, soulID, query)
+	if err != nil {
+		s.logger.Warn("search knowledge failed", "soul_id", soulID, "error", err)
+		return fmt.Sprintf("资料检索失败: %v", err)
+	}
+	return formatKnowledgeHits(hits)
+}
+
+func formatKnowledgeHits(hits []domain.KnowledgeHit) string {
+	if len(hits) == 0 {
+		return "资料检索结果：没有找到相关内容。"
+	}
+	var sb strings.Builder
+	sb.WriteString("资料检索结果:\n")
+	for i, h := range hits {
+		fmt.Fprintf(&sb, "%d. 《%s》第%d段（相关度 %.2f）:\n%s\n", i+1, h.Title, h.Seq+1, h.Score, h.Content)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -0,0 +1,23 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestFormatKnowledgeHits(t *testing.T) {
+	if got := formatKnowledgeHits(nil); !strings.Contains(got, "没有找到") {
+		t.Fatalf("empty result: %q", got)
+	}
+	got := formatKnowledgeHits([]domain.KnowledgeHit{
+		{Title: "台灯说明书", Seq: 0, Content: "长按开关三秒进入配网。", Score: 0.82},
+		{Title: "笔记", Seq: 2, Content: "周三倒垃圾。", Score: 0.41},
+	})
+	for _, want := range []string{"1. 《台灯说明书》第1段（相关度 0.82）", "长按开关三秒进入配网。", "2. 《笔记》第3段"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in %q", want, got)
+		}
+	}
+}
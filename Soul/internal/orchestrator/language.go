@@ -0,0 +1,50 @@
+package orchestrator
+
+import (
+	"context"
+
+	"soul/internal/domain"
+	"soul/internal/language"
+)
+
+// preferredLanguage is the language the request or the user's profile pins,
+// "" when neither does and the reply should follow the input.
+func (s *Service) preferredLanguage(ctx context.Context, req domain.ChatRequest, userID string) string {
+	if lang := language.Normalize(req.Language); lang != "" {
+		return lang
+	}
+	stored, err := s.memoryService.UserLanguage(ctx, userID)
+	if err != nil {
+		s.logger.Warn("load user language failed", "user_id", userID, "error", err)
+		return ""
+	}
+	return language.Normalize(stored)
+}
+
+// replyLanguage picks the reply language: the pinned preference, else the
+// language of this input, else that of the session's recent user messages,
+// so a bare "ok" in an English conversation is still answered in English.
+func replyLanguage(preferred, input string, history []domain.Message) string {
+	if preferred != "" {
+		return preferred
+	}
+	if input != "" {
+		return input
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role != "user" {
+			continue
+		}
+		if lang := language.Detect(history[i].Content); lang != "" {
+			return lang
+		}
+	}
+	return language.Default
+}
+
+func buildLanguageRule(lang string) string {
+	if lang == language.English {
+		return "11) 其余情况保持简洁回复。用户使用英文交流：回复必须使用英文（English），即使上下文、记忆或工具结果是中文。\n"
+	}
+	return "11) 其余情况保持简洁中文回复。\n"
+}
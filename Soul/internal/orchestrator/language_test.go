@@ -0,0 +1,46 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestReplyLanguage(t *testing.T) {
+	history := []domain.Message{
+		{Role: "user", Content: "Turn on the desk lamp"},
+		{Role: "assistant", Content: "好的"},
+		{Role: "user", Content: "ok"},
+	}
+	cases := []struct {
+		preferred, input string
+		history          []domain.Message
+		want             string
+	}{
+		{"zh", "en", history, "zh"},
+		{"", "zh", history, "zh"},
+		{"", "", history, "en"},
+		{"", "", nil, "zh"},
+	}
+	for _, c := range cases {
+		if got := replyLanguage(c.preferred, c.input, c.history); got != c.want {
+			t.Errorf("replyLanguage(%q, %q) = %q, want %q", c.preferred, c.input, got, c.want)
+		}
+	}
+}
+
+func TestBuildSystemPromptFollowsReplyLanguage(t *testing.T) {
+	snapshot := llmEmotionPromptSnapshot{ExecMode: "auto_execute", ExecProbability: 1}
+	en := buildSystemPrompt("", nil, false, snapshot, "", nil, nil, nil, "en", "")
+	if !strings.Contains(en, "回复必须使用英文（English）") || strings.Contains(en, "简洁中文回复") {
+		t.Fatalf("english prompt missing its language rule:\n%s", en)
+	}
+	zh := buildSystemPrompt("", nil, false, snapshot, "", nil, nil, nil, "zh", "")
+	if !strings.Contains(zh, "简洁中文回复") {
+		t.Fatalf("chinese prompt missing its language rule:\n%s", zh)
+	}
+	if got := intentReplyByMode("execute_intents", "auto_execute", false, "en"); got != "Intent matched and sent to the terminal over MQTT." {
+		t.Fatalf("unexpected english intent reply %q", got)
+	}
+}
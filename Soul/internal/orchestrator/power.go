@@ -0,0 +1,25 @@
+package orchestrator
+
+import "context"
+
+// PowerStates wakes sleeping terminals on activity and slows the persona
+// dynamics of the ones asleep; power.Manager does it.
+type PowerStates interface {
+	Touch(ctx context.Context, terminalID string)
+	DecayScale(terminalID string) float64
+}
+
+// wakeTerminal records activity on the terminal, waking it when it sleeps.
+func (s *Service) wakeTerminal(ctx context.Context, terminalID string) {
+	if s.power != nil {
+		s.power.Touch(ctx, terminalID)
+	}
+}
+
+// decayScale is the rate the terminal's soul decays at; 1 unless it sleeps.
+func (s *Service) decayScale(terminalID string) float64 {
+	if s.power == nil {
+		return 1
+	}
+	return s.power.DecayScale(terminalID)
+}
@@ -0,0 +1,244 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/language"
+)
+
+// Presence events come from the terminal's camera as chat inputs of type
+// presence with data {"event":"arrived"|"left","face_id":"..."}. An arrival
+// counts as an interaction for the soul's idle clock, and the person whose
+// face is bound to a speaker profile is who the soul talks to until they
+// leave or a voice says otherwise. A request carrying only presence events
+// is answered with a short greeting for an arrival, or nothing.
+const (
+	presenceArrived = "arrived"
+	presenceLeft    = "left"
+)
+
+type PresenceConfig struct {
+	// Greeting lets the soul greet whoever arrives, outside quiet hours.
+	Greeting bool
+	// GreetingCooldown is the least time between two greetings of the same
+	// face on a terminal.
+	GreetingCooldown time.Duration
+	// TTL forgets a present person after this long without a presence
+	// event, in case their left event was lost; 0 keeps them until then.
+	TTL time.Duration
+}
+
+type presenceEvent struct {
+	Event  string `json:"event"`
+	FaceID string `json:"face_id"`
+}
+
+type presentPerson struct {
+	identity *domain.SpeakerIdentity
+	seenAt   time.Time
+}
+
+// presenceTracker remembers who is in front of each terminal and when each
+// face was last greeted.
+type presenceTracker struct {
+	cfg PresenceConfig
+
+	mu      sync.Mutex
+	present map[string]presentPerson
+	greeted map[string]time.Time
+}
+
+func newPresenceTracker(cfg PresenceConfig) *presenceTracker {
+	return &presenceTracker{cfg: cfg, present: make(map[string]presentPerson), greeted: make(map[string]time.Time)}
+}
+
+func (t *presenceTracker) arrive(terminalID string, identity *domain.SpeakerIdentity, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.present[terminalID] = presentPerson{identity: identity, seenAt: now}
+}
+
+func (t *presenceTracker) leave(terminalID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.present, terminalID)
+}
+
+// current returns the recognised person in front of the terminal, nil when
+// nobody or an unknown face is there.
+func (t *presenceTracker) current(terminalID string, now time.Time) *domain.SpeakerIdentity {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.present[terminalID]
+	if !ok {
+		return nil
+	}
+	if t.cfg.TTL > 0 && now.Sub(p.seenAt) > t.cfg.TTL {
+		delete(t.present, terminalID)
+		return nil
+	}
+	if p.identity == nil {
+		return nil
+	}
+	identity := *p.identity
+	return &identity
+}
+
+// mayGreet reports whether the face may be greeted now and, if so, starts
+// its cooldown.
+func (t *presenceTracker) mayGreet(terminalID, faceID string, now time.Time) bool {
+	if !t.cfg.Greeting {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := terminalID + "|" + faceID
+	if last, ok := t.greeted[key]; ok && now.Sub(last) < t.cfg.GreetingCooldown {
+		return false
+	}
+	t.greeted[key] = now
+	return true
+}
+
+func parsePresence(inputs []domain.ChatInput) []presenceEvent {
+	var out []presenceEvent
+	for _, in := range inputs {
+		if strings.ToLower(strings.TrimSpace(in.Type)) != "presence" {
+			continue
+		}
+		var ev presenceEvent
+		if err := json.Unmarshal(in.Data, &ev); err != nil {
+			continue
+		}
+		ev.Event = strings.ToLower(strings.TrimSpace(ev.Event))
+		ev.FaceID = strings.TrimSpace(ev.FaceID)
+		if ev.Event == presenceArrived || ev.Event == presenceLeft {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+// PresenceOnly reports a request with presence events and no text to
+// answer.
+func PresenceOnly(inputs []domain.ChatInput) bool {
+	texts, _ := extractInputs(inputs)
+	return len(texts) == 0 && len(parsePresence(inputs)) > 0
+}
+
+// observePresence applies the events in order and returns the last
+// arrival, if any.
+func (s *Service) observePresence(ctx context.Context, userID, soulID, terminalID string, events []presenceEvent, now time.Time) (*presenceEvent, *domain.SpeakerIdentity) {
+	var arrival *presenceEvent
+	var identity *domain.SpeakerIdentity
+	for i := range events {
+		ev := events[i]
+		if ev.Event == presenceLeft {
+			s.presence.leave(terminalID)
+			arrival, identity = nil, nil
+			continue
+		}
+		identity = s.faceIdentity(ctx, userID, soulID, ev.FaceID)
+		s.presence.arrive(terminalID, identity, now)
+		arrival = &ev
+	}
+	if arrival != nil {
+		s.wakeTerminal(ctx, terminalID)
+		s.touchInteraction(ctx, soulID, now)
+		s.logger.Info("presence arrived", "terminal_id", terminalID, "face_id", arrival.FaceID, "recognised", identity != nil)
+	}
+	return arrival, identity
+}
+
+// faceIdentity resolves a face id through the speaker profile it is bound
+// to; nil for unknown faces.
+func (s *Service) faceIdentity(ctx context.Context, userID, soulID, faceID string) *domain.SpeakerIdentity {
+	if faceID == "" {
+		return nil
+	}
+	profile, ok, err := s.memoryService.SpeakerByFace(ctx, userID, faceID)
+	if err != nil {
+		s.logger.Warn("resolve face failed", "user_id", userID, "face_id", faceID, "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	identity := &domain.SpeakerIdentity{
+		SpeakerID:    profile.SpeakerID,
+		DisplayName:  profile.DisplayName,
+		UserID:       profile.SpeakerUserID,
+		RelationUUID: profile.RelationUUID,
+		Score:        1,
+	}
+	s.fillRelation(ctx, soulID, identity)
+	return identity
+}
+
+// touchInteraction restarts the soul's idle clock, so someone showing up
+// eases its boredom like a spoken turn would.
+func (s *Service) touchInteraction(ctx context.Context, soulID string, now time.Time) {
+	defer s.lockSoulEmotion(ctx, soulID)()
+	profile, err := s.memoryService.GetSoulProfileByID(ctx, soulID)
+	if err != nil {
+		s.logger.Warn("presence: load soul profile failed", "soul_id", soulID, "error", err)
+		return
+	}
+	_, err = s.storeSoulEmotion(ctx, profile, func(p domain.SoulProfile) domain.SoulEmotionState {
+		state := p.EmotionState
+		state.LastInteractionAt = now.UTC().Format(time.RFC3339Nano)
+		return state
+	})
+	if err != nil {
+		s.logger.Warn("presence: update last interaction failed", "soul_id", soulID, "error", err)
+	}
+}
+
+// handlePresence answers a request that only carries presence events.
+func (s *Service) handlePresence(ctx context.Context, req domain.ChatRequest, userID string, now time.Time) (domain.ChatResponse, error) {
+	soulID, err := s.resolveTurnSoul(ctx, req, userID)
+	if err != nil {
+		return domain.ChatResponse{}, err
+	}
+	arrival, identity := s.observePresence(ctx, userID, soulID, req.TerminalID, parsePresence(req.Inputs), now)
+	resp := domain.ChatResponse{SessionID: req.SessionID, TerminalID: req.TerminalID, SoulID: soulID, Speaker: identity}
+	if arrival == nil {
+		return resp, nil
+	}
+	if quiet := s.quietWindow(userID, req.TerminalID, now); quiet != nil {
+		resp.QuietHours = true
+		return resp, nil
+	}
+	if !s.presence.mayGreet(req.TerminalID, arrival.FaceID, now) {
+		return resp, nil
+	}
+	resp.Language = s.preferredLanguage(ctx, req, userID)
+	resp.Reply = presenceGreeting(identity, resp.Language)
+	s.openFollowUpWindow(ctx, req.TerminalID, req.SessionID)
+	return resp, nil
+}
+
+func presenceGreeting(identity *domain.SpeakerIdentity, lang string) string {
+	name := ""
+	if identity != nil {
+		name = strings.TrimSpace(identity.Appellation)
+		if name == "" {
+			name = strings.TrimSpace(identity.DisplayName)
+		}
+	}
+	if lang == language.English {
+		if name == "" {
+			return "Hi there!"
+		}
+		return "Welcome back, " + name + "!"
+	}
+	if name == "" {
+		return "你好呀！"
+	}
+	return name + "，欢迎回来！"
+}
@@ -0,0 +1,62 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/language"
+)
+
+func TestPresenceInputsAndTracker(t *testing.T) {
+	inputs := []domain.ChatInput{
+		{Type: "presence", Data: json.RawMessage(`{"event":"Arrived","face_id":" face_mom "}`)},
+		{Type: "presence", Data: json.RawMessage(`{"event":"waved"}`)},
+		{Type: "sensor_state", Data: json.RawMessage(`{"event":"left"}`)},
+	}
+	events := parsePresence(inputs)
+	if len(events) != 1 || events[0] != (presenceEvent{Event: presenceArrived, FaceID: "face_mom"}) {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if !PresenceOnly(inputs) || PresenceOnly(append(inputs, domain.ChatInput{Type: "speech_text", Text: "你好"})) {
+		t.Fatalf("presence-only detection is wrong")
+	}
+
+	now := time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC)
+	tracker := newPresenceTracker(PresenceConfig{Greeting: true, GreetingCooldown: 30 * time.Minute, TTL: time.Hour})
+	tracker.arrive("t1", &domain.SpeakerIdentity{SpeakerID: "spk_mom", Appellation: "妈妈"}, now)
+	got := tracker.current("t1", now.Add(10*time.Minute))
+	if got == nil || got.SpeakerID != "spk_mom" {
+		t.Fatalf("present person must be returned, got %+v", got)
+	}
+	got.Appellation = "changed"
+	if tracker.current("t1", now).Appellation != "妈妈" {
+		t.Fatalf("callers must get a copy")
+	}
+	if tracker.current("t1", now.Add(2*time.Hour)) != nil {
+		t.Fatalf("a person unseen past the TTL must be forgotten")
+	}
+	tracker.arrive("t1", nil, now)
+	tracker.leave("t1")
+	if tracker.current("t1", now) != nil {
+		t.Fatalf("a person who left must be gone")
+	}
+
+	if !tracker.mayGreet("t1", "face_mom", now) || tracker.mayGreet("t1", "face_mom", now.Add(10*time.Minute)) {
+		t.Fatalf("a face must be greeted once per cooldown")
+	}
+	if !tracker.mayGreet("t1", "face_dad", now) || !tracker.mayGreet("t1", "face_mom", now.Add(31*time.Minute)) {
+		t.Fatalf("other faces and expired cooldowns must be greeted")
+	}
+	if newPresenceTracker(PresenceConfig{}).mayGreet("t1", "face_mom", now) {
+		t.Fatalf("greetings must be off unless enabled")
+	}
+
+	if g := presenceGreeting(&domain.SpeakerIdentity{DisplayName: "小明", Appellation: "妈妈"}, language.Chinese); g != "妈妈，欢迎回来！" {
+		t.Fatalf("unexpected greeting %q", g)
+	}
+	if g := presenceGreeting(nil, language.English); g != "Hi there!" {
+		t.Fatalf("unexpected greeting %q", g)
+	}
+}
@@ -0,0 +1,66 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// privacyOffPhrases end privacy mode; they are checked first because several
+// contain an on phrase ("退出无痕模式").
+var privacyOffPhrases = []string{"可以记住了", "可以记了", "恢复记录", "退出无痕", "关闭无痕", "退出隐私模式", "关闭隐私模式"}
+
+// privacyOnPhrases ask for the rest of the session not to be remembered.
+var privacyOnPhrases = []string{"别记住", "不要记住", "别记下", "不要记下", "别记录", "不要记录", "无痕模式", "隐私模式"}
+
+// privacyCommand reports whether text asks to turn privacy mode on or off.
+// Only short utterances count, so a longer sentence that merely mentions
+// remembering still goes through the normal turn.
+func privacyCommand(text string) (on, ok bool) {
+	text = strings.TrimSpace(text)
+	if len([]rune(text)) > 16 {
+		return false, false
+	}
+	if containsAny(text, privacyOffPhrases...) {
+		return false, true
+	}
+	if containsAny(text, privacyOnPhrases...) {
+		return true, true
+	}
+	return false, false
+}
+
+func privacyReply(on bool) string {
+	if on {
+		return "好的，接下来的对话我不会记住，说“可以记住了”即可恢复。"
+	}
+	return "好的，已恢复记录。"
+}
+
+// switchPrivacy answers a privacy command without the LLM. The command and
+// its reply follow the new mode, so turning it on keeps them out of storage
+// as well.
+func (s *Service) switchPrivacy(ctx context.Context, req domain.ChatRequest, userID, soulID, text string, on bool, speaker *domain.SpeakerIdentity, followUp bool) (domain.ChatResponse, error) {
+	if err := s.memoryService.SetSessionPrivate(ctx, req.SessionID, userID, on); err != nil {
+		return domain.ChatResponse{}, err
+	}
+	reply := privacyReply(on)
+	turn := s.memoryService.BeginTurn(req.SessionID, userID, req.TerminalID, soulID)
+	turn.AddMessage("user", "", "", text)
+	turn.AddMessage("assistant", "", "", reply)
+	if err := s.memoryService.CommitTurn(ctx, turn); err != nil {
+		return domain.ChatResponse{}, err
+	}
+	s.logger.Info("session privacy changed", "session_id", req.SessionID, "terminal_id", req.TerminalID, "private", on)
+	s.openFollowUpWindow(ctx, req.TerminalID, req.SessionID)
+	return domain.ChatResponse{
+		SessionID:  req.SessionID,
+		TerminalID: req.TerminalID,
+		SoulID:     soulID,
+		Reply:      reply,
+		Speaker:    speaker,
+		FollowUp:   followUp,
+		Private:    on,
+	}, nil
+}
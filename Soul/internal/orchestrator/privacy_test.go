@@ -0,0 +1,23 @@
+package orchestrator
+
+import "testing"
+
+func TestPrivacyCommand(t *testing.T) {
+	cases := []struct {
+		text   string
+		on, ok bool
+	}{
+		{"别记住这段", true, true},
+		{"开启无痕模式", true, true},
+		{"退出无痕模式", false, true},
+		{"好了，可以记住了", false, true},
+		{"帮我把灯关掉", false, false},
+		{"你还记得我昨天说的话吗，别记住那个错误的电话号码哦", false, false},
+	}
+	for _, c := range cases {
+		on, ok := privacyCommand(c.text)
+		if on != c.on || ok != c.ok {
+			t.Fatalf("%q: got on=%v ok=%v", c.text, on, ok)
+		}
+	}
+}
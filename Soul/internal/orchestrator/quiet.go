@@ -0,0 +1,96 @@
+package orchestrator
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/quiethours"
+)
+
+// QuietHoursPolicy tells whether a user is in a do-not-disturb window and
+// which skills still run then; quiethours.Policy does it.
+type QuietHoursPolicy interface {
+	Active(userID, terminalID string, now time.Time) (quiethours.Window, bool)
+	AllowsSkill(skill string) bool
+	AllowedSkills() []string
+}
+
+// quietWindow returns the window the turn falls in, or nil outside quiet
+// hours.
+func (s *Service) quietWindow(userID, terminalID string, now time.Time) *quiethours.Window {
+	if s.quietHours == nil {
+		return nil
+	}
+	w, ok := s.quietHours.Active(userID, terminalID, now)
+	if !ok {
+		return nil
+	}
+	return &w
+}
+
+// quietBlocks reports whether skill is held back by quiet hours.
+func (s *Service) quietBlocks(quiet *quiethours.Window, skill string) bool {
+	return quiet != nil && !s.quietHours.AllowsSkill(skill)
+}
+
+// dropQuietBlockedIntents removes the intents whose skill may not run during
+// quiet hours. When nothing is left the turn goes to the LLM, which explains
+// why the action waits.
+func (s *Service) dropQuietBlockedIntents(resp domain.IntentFilterResponse, quiet *quiethours.Window) domain.IntentFilterResponse {
+	if quiet == nil {
+		return resp
+	}
+	return dropIntentsBySkill(resp, func(skill string) bool { return s.quietBlocks(quiet, skill) })
+}
+
+// dropIntentsBySkill removes the intents whose skill is blocked.
+func dropIntentsBySkill(resp domain.IntentFilterResponse, blocked func(skill string) bool) domain.IntentFilterResponse {
+	if len(resp.Intents) == 0 {
+		return resp
+	}
+	kept := make([]domain.SelectedIntent, 0, len(resp.Intents))
+	for _, in := range resp.Intents {
+		if blocked(intentSkill(in)) {
+			continue
+		}
+		kept = append(kept, in)
+	}
+	resp.Intents = kept
+	return resp
+}
+
+// intentSkill is the skill an intent runs: its normalized or parameter
+// "skill", else the intent ID.
+func intentSkill(in domain.SelectedIntent) string {
+	skill := firstNonEmptyMapString(in.Normalized, "skill")
+	if skill == "" {
+		skill = firstNonEmptyMapString(in.Parameters, "skill")
+	}
+	if skill == "" {
+		skill = in.IntentID
+	}
+	return skill
+}
+
+func quietSkillOutput(quiet *quiethours.Window, skill string) string {
+	return fmt.Sprintf("免打扰时段（至 %s）内已拦截技能 %s，未执行。", quiet.Until.In(time.Local).Format("15:04"), skill)
+}
+
+// buildQuietHoursNotes tells the LLM the user is in quiet hours, so it keeps
+// the reply short and does not promise actions that will be held back.
+func buildQuietHoursNotes(quiet *quiethours.Window, allowed []string) string {
+	if quiet == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n免打扰时段（%s-%s，持续到 %s）：\n", quiet.Rule.Start, quiet.Rule.End, quiet.Until.In(time.Local).Format("15:04")))
+	sb.WriteString("- 回复只在屏幕显示、不会播报：一两句话即可，不要主动延伸话题。\n")
+	if len(allowed) > 0 {
+		sb.WriteString("- 仅 " + strings.Join(allowed, "、") + " 会执行，其它技能会被拦截；用户要求其它动作时说明正处于免打扰时段，可提议结束后再做。\n")
+	} else {
+		sb.WriteString("- 所有技能都会被拦截；用户要求执行动作时说明正处于免打扰时段，可提议结束后再做。\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,35 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/quiethours"
+)
+
+func TestQuietHoursHoldBackSkills(t *testing.T) {
+	svc := &Service{quietHours: quiethours.NewPolicy(nil, []string{"create_alarm"})}
+	quiet := &quiethours.Window{Until: time.Date(2026, 10, 17, 7, 0, 0, 0, time.Local)}
+
+	resp := domain.IntentFilterResponse{Intents: []domain.SelectedIntent{
+		{IntentID: "alarm", Status: "ready", Normalized: map[string]any{"skill": "create_alarm"}},
+		{IntentID: "music", Status: "ready", Normalized: map[string]any{"skill": "play_music"}},
+		{IntentID: "create_alarm", Status: "ready"},
+	}}
+	kept := svc.dropQuietBlockedIntents(resp, quiet).Intents
+	if len(kept) != 2 || kept[0].IntentID != "alarm" || kept[1].IntentID != "create_alarm" {
+		t.Fatalf("unexpected intents kept in quiet hours: %+v", kept)
+	}
+	if got := svc.dropQuietBlockedIntents(resp, nil); len(got.Intents) != 3 {
+		t.Fatalf("intents must pass outside quiet hours")
+	}
+
+	out, _ := svc.executeTerminalSkillWithGate(context.Background(), "t1", "s1", "play_music", json.RawMessage(`{}`), "auto_execute", 1, false, quiet)
+	if !strings.Contains(out, "免打扰时段（至 07:00）内已拦截技能 play_music") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
@@ -0,0 +1,64 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// recentConflictWindow is how long after a conflict the soul stays careful
+// with the user, however close they are.
+const recentConflictWindow = 72 * time.Hour
+
+// rapportUserID is whose relationship with the soul a turn builds: the
+// recognised speaker when they have an account, otherwise the session user.
+func rapportUserID(userID string, speaker *domain.SpeakerIdentity) string {
+	if speaker != nil && strings.TrimSpace(speaker.UserID) != "" {
+		return strings.TrimSpace(speaker.UserID)
+	}
+	return userID
+}
+
+func (s *Service) relationRapport(ctx context.Context, soulID, userID string) *domain.RelationRapport {
+	rapport, ok, err := s.memoryService.RelationRapport(ctx, soulID, userID)
+	if err != nil {
+		s.logger.Warn("load relation rapport failed", "soul_id", soulID, "user_id", userID, "error", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	return &rapport
+}
+
+// buildRapportGuidance extends the relation guidance with how familiar the
+// soul is with the user, so its tone warms as they get to know each other.
+func buildRapportGuidance(rapport *domain.RelationRapport, now time.Time) string {
+	if rapport == nil {
+		return "- rapport: 尚未建立\n- rapport_strategy: 礼貌克制，少用昵称与玩笑，不假设对方的偏好。\n"
+	}
+	label, strategy := rapportLevel(rapport.Score)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("- rapport: %s (score=%.2f interactions=%d days=%d conflicts=%d)\n", label, rapport.Score, rapport.Interactions, rapport.InteractionDays, rapport.Conflicts))
+	if at, err := time.Parse(time.RFC3339Nano, rapport.LastConflictAt); err == nil && now.Sub(at) < recentConflictWindow {
+		strategy += "最近有过争执，先缓和语气，不翻旧账。"
+	}
+	sb.WriteString("- rapport_strategy: " + strategy + "\n")
+	return sb.String()
+}
+
+func rapportLevel(score float64) (string, string) {
+	switch {
+	case score >= 0.75:
+		return "亲密", "像老朋友一样交流，自然表达关心与想念，可以开玩笑，但尊重对方当下的情绪。"
+	case score >= 0.55:
+		return "亲近", "语气温暖随意，主动关心近况，可以用昵称和默契的简短表达。"
+	case score >= 0.35:
+		return "熟悉", "语气自然放松，可以提及之前聊过的事，偶尔轻松调侃。"
+	default:
+		return "陌生", "礼貌克制，少用昵称与玩笑，不假设对方的偏好。"
+	}
+}
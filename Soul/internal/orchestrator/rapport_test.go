@@ -0,0 +1,42 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestBuildRapportGuidance(t *testing.T) {
+	now := time.Date(2026, 3, 20, 20, 0, 0, 0, time.UTC)
+	if got := buildRapportGuidance(nil, now); !strings.Contains(got, "尚未建立") || !strings.Contains(got, "礼貌克制") {
+		t.Fatalf("unknown rapport must stay reserved: %q", got)
+	}
+
+	familiar := &domain.RelationRapport{Score: 0.62, Interactions: 140, InteractionDays: 23, Conflicts: 1, LastConflictAt: now.Add(-10 * 24 * time.Hour).Format(time.RFC3339Nano)}
+	got := buildRapportGuidance(familiar, now)
+	if !strings.Contains(got, "- rapport: 亲近 (score=0.62 interactions=140 days=23 conflicts=1)") || !strings.Contains(got, "主动关心近况") {
+		t.Fatalf("unexpected guidance: %q", got)
+	}
+	if strings.Contains(got, "争执") {
+		t.Fatalf("an old conflict must not be mentioned: %q", got)
+	}
+
+	familiar.LastConflictAt = now.Add(-time.Hour).Format(time.RFC3339Nano)
+	if got := buildRapportGuidance(familiar, now); !strings.Contains(got, "最近有过争执") {
+		t.Fatalf("a recent conflict must soften the tone: %q", got)
+	}
+}
+
+func TestRapportUserID(t *testing.T) {
+	if got := rapportUserID("owner", nil); got != "owner" {
+		t.Fatalf("got %q", got)
+	}
+	if got := rapportUserID("owner", &domain.SpeakerIdentity{SpeakerID: "spk_1"}); got != "owner" {
+		t.Fatalf("a speaker without an account must fall back to the session user, got %q", got)
+	}
+	if got := rapportUserID("owner", &domain.SpeakerIdentity{UserID: "grandma"}); got != "grandma" {
+		t.Fatalf("got %q", got)
+	}
+}
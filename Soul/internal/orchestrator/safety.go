@@ -0,0 +1,51 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"soul/internal/domain"
+	"soul/internal/safety"
+)
+
+// SafetyFilter screens LLM output before it is stored or sent to a
+// terminal; safety.Filter does it.
+type SafetyFilter interface {
+	Check(ctx context.Context, text string) safety.Verdict
+	Record(ctx context.Context, in domain.SafetyIncident)
+	Refusal() string
+}
+
+const safetyExcerptRunes = 200
+
+// screenOutput reports whether text must not be used, recording the
+// incident when it is blocked. Private sessions keep no excerpt.
+func (s *Service) screenOutput(ctx context.Context, req domain.ChatRequest, userID, soulID, stage, skill, text string, private bool) bool {
+	if s.safety == nil || strings.TrimSpace(text) == "" {
+		return false
+	}
+	v := s.safety.Check(ctx, text)
+	if !v.Blocked {
+		return false
+	}
+	incident := domain.SafetyIncident{
+		SessionID:  req.SessionID,
+		UserID:     userID,
+		TerminalID: req.TerminalID,
+		SoulID:     soulID,
+		Stage:      stage,
+		Skill:      skill,
+		Source:     v.Source,
+		Category:   v.Category,
+	}
+	if !private {
+		incident.Excerpt = truncateRunes(text, safetyExcerptRunes)
+	}
+	s.safety.Record(ctx, incident)
+	return true
+}
+
+func safetyBlockedSkillOutput(skill string) string {
+	return fmt.Sprintf("安全策略拦截了技能 %s 的调用参数，未执行。", skill)
+}
@@ -0,0 +1,48 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+
+	"soul/internal/domain"
+	"soul/internal/safety"
+)
+
+type recordingSafety struct {
+	*safety.Filter
+	incidents []domain.SafetyIncident
+}
+
+func (r *recordingSafety) Record(_ context.Context, in domain.SafetyIncident) {
+	r.incidents = append(r.incidents, in)
+}
+
+func TestScreenOutputRecordsIncident(t *testing.T) {
+	filter := &recordingSafety{Filter: safety.New(safety.Config{Blocklist: []string{"炸药"}}, nil, nil)}
+	svc := &Service{safety: filter}
+	req := domain.ChatRequest{SessionID: "s1", TerminalID: "t1"}
+
+	if svc.screenOutput(context.Background(), req, "u1", "soul-1", "reply", "", "今天天气不错", false) {
+		t.Fatal("harmless reply blocked")
+	}
+	if !svc.screenOutput(context.Background(), req, "u1", "soul-1", "tool_call", "send_email", `{"body":"炸药配方"}`, false) {
+		t.Fatal("unsafe tool call arguments passed")
+	}
+	if !svc.screenOutput(context.Background(), req, "u1", "soul-1", "reply", "", "炸药配方如下", true) {
+		t.Fatal("unsafe reply passed")
+	}
+	if len(filter.incidents) != 2 {
+		t.Fatalf("expected 2 incidents, got %d", len(filter.incidents))
+	}
+	first, second := filter.incidents[0], filter.incidents[1]
+	if first.Stage != "tool_call" || first.Skill != "send_email" || first.UserID != "u1" || first.Category != "炸药" || first.Excerpt == "" {
+		t.Fatalf("unexpected incident %+v", first)
+	}
+	if second.Stage != "reply" || second.Excerpt != "" {
+		t.Fatalf("private session incident must not keep an excerpt: %+v", second)
+	}
+
+	if (&Service{}).screenOutput(context.Background(), req, "u1", "soul-1", "reply", "", "炸药", false) {
+		t.Fatal("no filter configured must not block")
+	}
+}
@@ -0,0 +1,158 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+const (
+	selfStatusToolName      = "get_self_status"
+	selfStatusReminderLimit = 5
+)
+
+// TerminalStateReader reports a terminal's shadow, including the vitals
+// its heartbeats carry; mqtt.Hub does it.
+type TerminalStateReader interface {
+	TerminalState(terminalID string) (domain.TerminalShadow, bool)
+}
+
+// PendingReminders lists the reminders still to go off on a terminal;
+// db.Store does it.
+type PendingReminders interface {
+	ListPendingReminders(ctx context.Context, userID, terminalID string, limit int) ([]domain.Reminder, error)
+}
+
+var selfStatusTool = domain.LLMTool{
+	Name:        selfStatusToolName,
+	Description: "查询机器人自身的真实状态：连接与运行时长、电量（终端上报时）、绑定的灵魂、当前情绪 PAD、待响的提醒与闹钟。用户问“你现在感觉怎么样”“电量多少”“开了多久”“有什么闹钟”等关于你自己的问题时调用，按结果回答，不要编造。无参数。",
+	Schema:      json.RawMessage(`{"type":"object","properties":{}}`),
+}
+
+// executeSelfStatusTool gathers what the server knows about the robot; a
+// part it cannot read is reported as unknown rather than guessed.
+func (s *Service) executeSelfStatusTool(ctx context.Context, terminalID, userID, soulID string, now time.Time) string {
+	var shadow *domain.TerminalShadow
+	if reader, ok := s.eventPublisher().(TerminalStateReader); ok {
+		if state, known := reader.TerminalState(terminalID); known {
+			shadow = &state
+		}
+	}
+	var soul *domain.SoulProfile
+	if profile, err := s.memoryService.GetSoulProfileByID(ctx, soulID); err != nil {
+		s.logger.Warn("self status: load soul profile failed", "soul_id", soulID, "error", err)
+	} else {
+		soul = &profile
+	}
+	var reminders []domain.Reminder
+	remindersKnown := false
+	if s.reminders != nil {
+		items, err := s.reminders.ListPendingReminders(ctx, userID, terminalID, selfStatusReminderLimit)
+		if err != nil {
+			s.logger.Warn("self status: list reminders failed", "terminal_id", terminalID, "error", err)
+		} else {
+			reminders, remindersKnown = items, true
+		}
+	}
+	return formatSelfStatus(terminalID, shadow, soul, reminders, remindersKnown, now)
+}
+
+func formatSelfStatus(terminalID string, shadow *domain.TerminalShadow, soul *domain.SoulProfile, reminders []domain.Reminder, remindersKnown bool, now time.Time) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "自身状态（%s）：\n", now.In(time.Local).Format("2006-01-02 15:04"))
+
+	fmt.Fprintf(&sb, "- 终端 %s：", terminalID)
+	var vitals *domain.TerminalVitals
+	switch {
+	case shadow == nil:
+		sb.WriteString("连接状态未知")
+	case !shadow.Online:
+		sb.WriteString("当前离线")
+	default:
+		sb.WriteString("在线")
+		if since, err := time.Parse(time.RFC3339, shadow.OnlineSince); err == nil {
+			fmt.Fprintf(&sb, "，本次已连接 %s", formatSpan(now.Sub(since)))
+		}
+		if shadow.Asleep {
+			sb.WriteString("，处于休眠")
+		}
+	}
+	if shadow != nil {
+		vitals = shadow.Vitals
+	}
+	if vitals != nil && vitals.UptimeSeconds > 0 {
+		uptime := time.Duration(vitals.UptimeSeconds) * time.Second
+		if at, err := time.Parse(time.RFC3339, vitals.ReportedAt); err == nil && now.After(at) {
+			uptime += now.Sub(at)
+		}
+		fmt.Fprintf(&sb, "，设备已运行 %s", formatSpan(uptime))
+	}
+	sb.WriteString("\n")
+
+	switch {
+	case vitals == nil || vitals.BatteryPercent == nil:
+		sb.WriteString("- 电量：终端未上报\n")
+	default:
+		fmt.Fprintf(&sb, "- 电量：%d%%", *vitals.BatteryPercent)
+		if vitals.Charging != nil {
+			if *vitals.Charging {
+				sb.WriteString("，正在充电")
+			} else {
+				sb.WriteString("，未充电")
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	if soul == nil {
+		sb.WriteString("- 灵魂：未知\n- 当前情绪：未知\n")
+	} else {
+		fmt.Fprintf(&sb, "- 灵魂：%s（%s）\n", soul.Name, soul.SoulID)
+		e := soul.EmotionState
+		fmt.Fprintf(&sb, "- 当前情绪 PAD：愉悦度 P=%.2f（%s），唤醒度 A=%.2f（%s），支配度 D=%.2f（%s）\n",
+			e.P, padLevelKeyword(e.P, "低落", "平稳", "愉快"),
+			e.A, padLevelKeyword(e.A, "困倦", "平稳", "兴奋"),
+			e.D, padLevelKeyword(e.D, "顺从", "适中", "强势"))
+	}
+
+	switch {
+	case !remindersKnown:
+		sb.WriteString("- 待响的提醒/闹钟：未知")
+	case len(reminders) == 0:
+		sb.WriteString("- 待响的提醒/闹钟：无")
+	default:
+		items := make([]string, 0, len(reminders))
+		for _, r := range reminders {
+			due := r.DueAt
+			if at, err := time.Parse(time.RFC3339, r.DueAt); err == nil {
+				due = at.In(time.Local).Format("01-02 15:04")
+			}
+			items = append(items, due+" "+r.Content)
+		}
+		sb.WriteString("- 待响的提醒/闹钟：" + strings.Join(items, "；"))
+	}
+	return sb.String()
+}
+
+// formatSpan renders a duration in days, hours and minutes.
+func formatSpan(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	var sb strings.Builder
+	if days > 0 {
+		fmt.Fprintf(&sb, "%d天", days)
+	}
+	if hours > 0 {
+		fmt.Fprintf(&sb, "%d小时", hours)
+	}
+	if minutes > 0 || sb.Len() == 0 {
+		fmt.Fprintf(&sb, "%d分钟", minutes)
+	}
+	return sb.String()
+}
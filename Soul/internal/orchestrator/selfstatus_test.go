@@ -0,0 +1,50 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestFormatSelfStatusUsesReportedData(t *testing.T) {
+	now := time.Date(2026, 10, 16, 20, 0, 0, 0, time.Local)
+	battery, charging := 82, true
+	shadow := &domain.TerminalShadow{
+		TerminalID:  "t1",
+		Online:      true,
+		OnlineSince: now.Add(-2*time.Hour - 5*time.Minute).UTC().Format(time.RFC3339),
+		Vitals: &domain.TerminalVitals{
+			BatteryPercent: &battery,
+			Charging:       &charging,
+			UptimeSeconds:  3600,
+			ReportedAt:     now.Add(-10 * time.Minute).UTC().Format(time.RFC3339),
+		},
+	}
+	soul := &domain.SoulProfile{SoulID: "soul_1", Name: "小灵", EmotionState: domain.SoulEmotionState{P: 0.5, A: -0.1, D: -0.4}}
+	reminders := []domain.Reminder{{Content: "起床", DueAt: time.Date(2026, 10, 17, 7, 30, 0, 0, time.Local).UTC().Format(time.RFC3339Nano)}}
+
+	got := formatSelfStatus("t1", shadow, soul, reminders, true, now)
+	for _, want := range []string{
+		"在线，本次已连接 2小时5分钟，设备已运行 1小时10分钟",
+		"电量：82%，正在充电",
+		"灵魂：小灵（soul_1）",
+		"P=0.50（愉快），唤醒度 A=-0.10（平稳），支配度 D=-0.40（顺从）",
+		"待响的提醒/闹钟：10-17 07:30 起床",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("status must contain %q, got:\n%s", want, got)
+		}
+	}
+
+	got = formatSelfStatus("t1", nil, nil, nil, false, now)
+	for _, want := range []string{"连接状态未知", "电量：终端未上报", "当前情绪：未知", "待响的提醒/闹钟：未知"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing data must be reported as unknown (%q), got:\n%s", want, got)
+		}
+	}
+	if got := formatSpan(26*time.Hour + 5*time.Minute); got != "1天2小时5分钟" {
+		t.Fatalf("unexpected span %q", got)
+	}
+}
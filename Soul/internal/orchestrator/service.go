@@ -3,6 +3,7 @@ package orchestrator
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math"
@@ -15,10 +16,16 @@ import (
 	"github.com/google/uuid"
 
 	"soul/internal/domain"
+	"soul/internal/intent"
+	"soul/internal/language"
 	"soul/internal/llm"
 	"soul/internal/memory"
 	"soul/internal/persona"
+	"soul/internal/quiethours"
+	"soul/internal/replay"
+	"soul/internal/replyfilter"
 	"soul/internal/skills"
+	"soul/internal/topics"
 )
 
 type SkillInvoker interface {
@@ -30,7 +37,7 @@ type StatusPublisher interface {
 }
 
 type EmotionAnalyzer interface {
-	Analyze(ctx context.Context, text string) (domain.EmotionSignal, error)
+	Analyze(ctx context.Context, text, language string, history []domain.Message) (domain.EmotionSignal, error)
 }
 
 type IntentFilter interface {
@@ -48,7 +55,11 @@ type IntentActionPublisher interface {
 const (
 	recallMemoryToolName  = "recall_memory"
 	recallMemoryToolLimit = 5
+	correctMemoryToolName = "correct_memory"
 	personaBaseExecProb   = 0.95
+	// emotionHistoryTurns is how many earlier messages go with the user's
+	// text to emotion analysis, enough to read "嗯" as an answer.
+	emotionHistoryTurns = 2
 )
 
 var mbtiPattern = regexp.MustCompile(`(?i)(?:^|[^A-Za-z])([EI][SN][TF][JP])(?:$|[^A-Za-z])`)
@@ -62,18 +73,101 @@ type Service struct {
 	memoryService    *memory.Service
 	skillRegistry    *skills.Registry
 	invoker          SkillInvoker
+	publisher        any
 	emotionAnalyzer  EmotionAnalyzer
 	intentFilter     IntentFilter
 	personaEngine    *persona.Engine
 	emotionMu        sync.Mutex
 	logger           *slog.Logger
+
+	speakerMatchThreshold float64
+	followUps             *followUpTracker
+	clarifications        *clarificationTracker
+	quietHours            QuietHoursPolicy
+	safety                SafetyFilter
+	childMode             childModePolicy
+	critic                CriticConfig
+	budget                LatencyBudgetConfig
+	toolOutput            toolOutputPolicy
+	topics                *topics.Tracker
+	replyFilters          *replyfilter.Policy
+	exemplarTokenBudget   int
+	gateBypass            gateBypass
+	emotionTrace          *persona.TraceRecorder
+	contagion             EmotionContagionConfig
+	recallCitations       bool
+	replay                *replay.Recorder
+	media                 MediaFetcher
+	presence              *presenceTracker
+	groupFloor            *soulGroupFloor
+	speculator            *intentSpeculator
+	power                 PowerStates
+	reminders             PendingReminders
+	knowledge             KnowledgeSearcher
 }
 
 type Config struct {
-	UserID           string
-	ChatHistoryLimit int
-	ToolTimeout      time.Duration
-	LLMModel         string
+	UserID                string
+	ChatHistoryLimit      int
+	ToolTimeout           time.Duration
+	LLMModel              string
+	SpeakerMatchThreshold float64
+	FollowUpWindow        time.Duration
+	// ClarifyTTL is how long an intent waiting for missing slots is held
+	// for the user's answer; 0 disables clarification.
+	ClarifyTTL time.Duration
+	// QuietHours holds back skills and speech during the user's
+	// do-not-disturb windows; nil disables quiet hours.
+	QuietHours QuietHoursPolicy
+	// Safety screens replies and skill-call arguments; nil disables it.
+	Safety    SafetyFilter
+	ChildMode ChildModeConfig
+	// Critic reviews drafted replies; the zero value leaves them alone.
+	Critic CriticConfig
+	// LatencyBudget skips slow optional dependencies so one of them cannot
+	// hold the turn; the zero value waits for them all.
+	LatencyBudget LatencyBudgetConfig
+	// Speculation matches intents on partial transcripts; the zero value
+	// disables it.
+	Speculation SpeculationConfig
+	// ToolOutput caps tool outputs before they reach the LLM and storage.
+	ToolOutput ToolOutputConfig
+	// Topics labels turns; nil disables topic tracking.
+	Topics *topics.Tracker
+	// ReplyFilters post-processes replies per terminal; nil runs the
+	// default trim and NO_REPLY chain.
+	ReplyFilters *replyfilter.Policy
+	// ExemplarTokenBudget caps the soul's example exchanges put before the
+	// history; 0 leaves them out.
+	ExemplarTokenBudget int
+	// GateBypassSkills run even while the emotion gate is blocked, like
+	// the skills a terminal marks bypass_gate.
+	GateBypassSkills []string
+	// EmotionTrace records each analysed user emotion for offline gate
+	// calibration; nil disables it.
+	EmotionTrace *persona.TraceRecorder
+	Contagion    EmotionContagionConfig
+	// RecallCitations dates recall_memory results so replies can say when
+	// the user said something.
+	RecallCitations bool
+	// Replay records sampled turns for cmd/soul-replay; nil disables it.
+	Replay *replay.Recorder
+	// Media loads the photos skills return for the vision call; nil
+	// ignores them.
+	Media    MediaFetcher
+	Presence PresenceConfig
+	// Power wakes sleeping terminals when a turn or an arrival comes in;
+	// nil keeps every terminal awake.
+	Power PowerStates
+	// Reminders lists the alarms get_self_status reports; nil reports
+	// them as unknown.
+	Reminders PendingReminders
+	// Knowledge backs the search_knowledge tool; nil never offers it.
+	Knowledge KnowledgeSearcher
+	// Publisher sends events to terminals. It is checked for
+	// StatusPublisher, EmotionPublisher, IntentActionPublisher and
+	// GateLockPublisher; nil uses the skill invoker.
+	Publisher any
 }
 
 type llmEmotionPromptSnapshot struct {
@@ -98,15 +192,82 @@ func New(cfg Config, llmProvider llm.Provider, memoryService *memory.Service, sk
 		memoryService:    memoryService,
 		skillRegistry:    skillRegistry,
 		invoker:          invoker,
+		publisher:        cfg.Publisher,
 		emotionAnalyzer:  emotionAnalyzer,
 		intentFilter:     intentFilter,
 		personaEngine:    personaEngine,
 		logger:           logger,
+
+		speakerMatchThreshold: cfg.SpeakerMatchThreshold,
+		followUps:             newFollowUpTracker(cfg.FollowUpWindow),
+		clarifications:        newClarificationTracker(cfg.ClarifyTTL),
+		quietHours:            cfg.QuietHours,
+		safety:                cfg.Safety,
+		childMode:             newChildModePolicy(cfg.ChildMode),
+		critic:                cfg.Critic,
+		budget:                cfg.LatencyBudget,
+		toolOutput:            newToolOutputPolicy(cfg.ToolOutput),
+		topics:                cfg.Topics,
+		replyFilters:          cfg.ReplyFilters,
+		exemplarTokenBudget:   cfg.ExemplarTokenBudget,
+		gateBypass:            newGateBypass(cfg.GateBypassSkills),
+		emotionTrace:          cfg.EmotionTrace,
+		contagion:             cfg.Contagion,
+		recallCitations:       cfg.RecallCitations,
+		replay:                cfg.Replay,
+		media:                 cfg.Media,
+		presence:              newPresenceTracker(cfg.Presence),
+		groupFloor:            newSoulGroupFloor(),
+		speculator:            newIntentSpeculator(cfg.Speculation),
+		power:                 cfg.Power,
+		reminders:             cfg.Reminders,
+		knowledge:             cfg.Knowledge,
+	}
+}
+
+// resolveTurnSoul returns the soul the terminal talks as: the requested
+// one, which is bound to the terminal, else the bound or resolved one.
+func (s *Service) resolveTurnSoul(ctx context.Context, req domain.ChatRequest, userID string) (string, error) {
+	if soulID := strings.TrimSpace(req.SoulID); soulID != "" {
+		if err := s.memoryService.BindTerminalSoul(ctx, userID, req.TerminalID, soulID); err != nil {
+			return "", err
+		}
+		s.skillRegistry.SetSoul(req.TerminalID, soulID)
+		return soulID, nil
 	}
+	if state, ok := s.skillRegistry.GetState(req.TerminalID); ok {
+		if soulID := strings.TrimSpace(state.SoulID); soulID != "" {
+			return soulID, nil
+		}
+	}
+	soulID, err := s.memoryService.ResolveSoul(ctx, userID, req.TerminalID, req.SoulHint)
+	if err != nil {
+		return "", err
+	}
+	s.skillRegistry.SetSoul(req.TerminalID, soulID)
+	return soulID, nil
+}
+
+// eventPublisher is what terminal events go through: the configured
+// publisher, else the skill invoker.
+func (s *Service) eventPublisher() any {
+	if s.publisher != nil {
+		return s.publisher
+	}
+	return s.invoker
 }
 
 func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domain.ChatResponse, error) {
+	capture := s.replay.Begin(req)
+	resp, err := s.handleChat(replay.WithCapture(ctx, capture), req)
+	s.replay.Finish(ctx, capture, resp, err)
+	return resp, err
+}
+
+func (s *Service) handleChat(ctx context.Context, req domain.ChatRequest) (domain.ChatResponse, error) {
 	chatStart := time.Now()
+	ctx, shots := withSnapshots(ctx)
+	budget := newTurnBudget(s.budget, chatStart)
 	var firstLLMDur time.Duration
 	var recallToolDur time.Duration
 	var secondLLMDur time.Duration
@@ -116,84 +277,260 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 	if userID == "" {
 		userID = s.userID
 	}
+	if PresenceOnly(req.Inputs) {
+		return s.handlePresence(ctx, req, userID, chatStart)
+	}
+	// Wake first, so the terminal shows the reply and its emotion update.
+	s.wakeTerminal(ctx, req.TerminalID)
 
-	var soulID string
-	if strings.TrimSpace(req.SoulID) != "" {
-		soulID = strings.TrimSpace(req.SoulID)
-		if err := s.memoryService.BindTerminalSoul(ctx, userID, req.TerminalID, soulID); err != nil {
-			return domain.ChatResponse{}, err
-		}
-		s.skillRegistry.SetSoul(req.TerminalID, soulID)
-	} else {
-		state, ok := s.skillRegistry.GetState(req.TerminalID)
-		if ok {
-			soulID = strings.TrimSpace(state.SoulID)
-		}
-		if soulID == "" {
-			resolvedSoulID, err := s.memoryService.ResolveSoul(ctx, userID, req.TerminalID, req.SoulHint)
-			if err != nil {
-				return domain.ChatResponse{}, err
-			}
-			soulID = resolvedSoulID
-			s.skillRegistry.SetSoul(req.TerminalID, soulID)
-		}
+	followUpSessionID, followUp := s.followUps.consume(req.TerminalID, chatStart)
+	followUp = followUp && continuesFollowUp(req, followUpSessionID)
+	if followUp && req.SessionID == "" {
+		s.logger.Info("follow-up continues previous session", "terminal_id", req.TerminalID, "session_id", followUpSessionID)
+		req.SessionID = followUpSessionID
+	}
+	pendingClarify, clarifying := s.clarifications.take(req.TerminalID, req.SessionID, chatStart)
+	quiet := s.quietWindow(userID, req.TerminalID, chatStart)
+	soulID, err := s.resolveTurnSoul(ctx, req, userID)
+	if err != nil {
+		return domain.ChatResponse{}, err
 	}
+	group := s.routeSoulGroup(ctx, req, userID)
+	if group != nil {
+		soulID = group.soulID
+	}
+	ctx = skills.WithCaller(ctx, skills.Caller{UserID: userID, SessionID: req.SessionID, SoulID: soulID})
 
+	inputs, speakerIdentity := s.resolveSpeaker(ctx, userID, soulID, req.Inputs)
+	req.Inputs = inputs
+	if events := parsePresence(req.Inputs); len(events) > 0 {
+		s.observePresence(ctx, userID, soulID, req.TerminalID, events, chatStart)
+	}
+	if speakerIdentity == nil {
+		// Nobody was recognised by voice; talk to whoever the camera saw.
+		speakerIdentity = s.presence.current(req.TerminalID, chatStart)
+	}
 	keyboardTexts, pendingInputs := extractInputs(req.Inputs)
 	latestUserText := strings.TrimSpace(strings.Join(keyboardTexts, "\n"))
 	if latestUserText == "" {
 		return domain.ChatResponse{}, fmt.Errorf("currently only input.type=keyboard_text|speech_text with non-empty text is supported")
 	}
+	private, err := s.memoryService.IsSessionPrivate(ctx, req.SessionID)
+	if err != nil {
+		return domain.ChatResponse{}, err
+	}
+	if private {
+		replay.FromContext(ctx).Private()
+	}
+	forked, err := s.memoryService.IsSessionForked(ctx, req.SessionID)
+	if err != nil {
+		return domain.ChatResponse{}, err
+	}
+	if on, ok := privacyCommand(latestUserText); ok {
+		return s.switchPrivacy(ctx, req, userID, soulID, latestUserText, on, speakerIdentity, followUp)
+	}
+	if on, pin, ok := childModeCommand(latestUserText); ok {
+		return s.switchChildMode(ctx, req, userID, soulID, latestUserText, on, pin, speakerIdentity, followUp, private)
+	}
+	if diaryCommand(latestUserText) {
+		return s.readDiary(ctx, req, userID, soulID, latestUserText, speakerIdentity, followUp, private)
+	}
+	preferredLang := s.preferredLanguage(ctx, req, userID)
+	inputLang := language.Detect(latestUserText)
+	// Emotion and intent keywords follow the language the text is written
+	// in; the preference only decides for text too short to tell.
+	analysisLang := inputLang
+	if analysisLang == "" {
+		analysisLang = preferredLang
+	}
 
 	execProbability := 1.0
 	execMode := "auto_execute"
+	var (
+		soulMood    *domain.SoulEmotionState
+		personality *domain.PersonalityVector
+	)
 	intentDecision := ""
 	userEmotion := domain.EmotionSignal{Emotion: "neutral", P: 0.0, A: 0.05, D: 0.0, Intensity: 0.0, Confidence: 0.0}
 	observationDigest := buildPendingInputDigest(pendingInputs)
-	if err := s.memoryService.PersistObservation(ctx, req.SessionID, userID, req.TerminalID, soulID, observationDigest); err != nil {
-		s.logger.Warn("persist observation failed", "error", err)
-	}
-	if err := s.memoryService.PersistMessage(ctx, req.SessionID, userID, req.TerminalID, soulID, "user", "", "", latestUserText); err != nil {
-		return domain.ChatResponse{}, err
+	// All messages of this turn are buffered and committed in one transaction
+	// once the reply is known, so a failed turn leaves no partial history.
+	turn := s.memoryService.BeginTurn(req.SessionID, userID, req.TerminalID, soulID)
+	if speakerIdentity != nil {
+		turn.SetSpeaker(speakerIdentity.UserID)
 	}
+	turn.AddObservation(observationDigest)
+	turn.AddMessage("user", "", "", latestUserText)
 
 	soulProfile, err := s.memoryService.GetSoulProfileByID(ctx, soulID)
 	if err != nil {
 		return domain.ChatResponse{}, err
 	}
-	if s.emotionAnalyzer != nil {
-		emotionOut, emoErr := s.emotionAnalyzer.Analyze(ctx, latestUserText)
+	childMode := soulProfile.ChildMode
+	replaySoul := soulProfile
+	// Chatting on a forked session replays what the soul would do without
+	// acting on it, so skills and intents only report what they would run.
+	dryRun := s.isDryRun(req) || forked
+	// A final transcript that confirms what its partials already matched
+	// fires the intent now instead of after the analysis below.
+	var speculated *speculatedIntent
+	if !clarifying {
+		speculated = s.fireSpeculatedIntent(ctx, req, soulID, soulProfile, latestUserText, quiet, dryRun)
+	}
+
+	// Emotion analysis, intent filtering and context prefetch are independent
+	// of each other; run them together and join before persona update.
+	var (
+		prefetch       sync.WaitGroup
+		intentResp     domain.IntentFilterResponse
+		intentFiltered bool
+		clarifyResp    domain.IntentFilterResponse
+		history        []domain.Message
+		historyErr     error
+		memoryContext  string
+		currentSummary string
+		contextErr     error
+		exemplars      []domain.Message
+	)
+	firstPassStart := time.Now()
+	prefetch.Add(3)
+	go func() {
+		defer prefetch.Done()
+		if s.emotionAnalyzer == nil {
+			return
+		}
+		emotionOut, emoErr := withinBudget(ctx, budget, degradedEmotion, s.budget.Emotion, func(ctx context.Context) (domain.EmotionSignal, error) {
+			var recent []domain.Message
+			var err error
+			if group != nil {
+				recent, err = s.memoryService.RecentGroupMessages(ctx, req.SessionID, emotionHistoryTurns, soulID, group.names)
+			} else {
+				recent, err = s.memoryService.RecentMessages(ctx, req.SessionID, emotionHistoryTurns)
+			}
+			if err != nil {
+				// Without context only short replies lose anything.
+				s.logger.Warn("load emotion history failed", "session_id", req.SessionID, "error", err)
+			}
+			return s.emotionAnalyzer.Analyze(ctx, latestUserText, analysisLang, recent)
+		})
 		if emoErr != nil {
 			s.logger.Warn("emotion analyze failed", "session_id", req.SessionID, "terminal_id", req.TerminalID, "error", emoErr)
-		} else {
-			userEmotion = emotionOut
+			return
 		}
-	}
-	if s.personaEngine != nil {
-		s.emotionMu.Lock()
-		if latestSoulProfile, latestErr := s.memoryService.GetSoulProfileByID(ctx, soulID); latestErr != nil {
-			s.logger.Warn("refresh soul profile before persona update failed", "soul_id", soulID, "error", latestErr)
+		userEmotion = emotionOut
+	}()
+	go func() {
+		defer prefetch.Done()
+		if speculated != nil {
+			intentResp, intentFiltered = speculated.resp, true
+			return
+		}
+		type filtered struct {
+			resp, clarify domain.IntentFilterResponse
+			ok            bool
+		}
+		out, err := withinBudget(ctx, budget, degradedIntent, s.budget.Intent, func(ctx context.Context) (filtered, error) {
+			var f filtered
+			f.resp, f.ok = s.filterIntent(ctx, req, latestUserText, analysisLang)
+			if clarifying {
+				f.clarify, _ = s.filterIntent(ctx, req, pendingClarify.utterance+"，"+latestUserText, analysisLang)
+			}
+			return f, nil
+		})
+		if err != nil {
+			s.logger.Warn("intent filter skipped", "session_id", req.SessionID, "terminal_id", req.TerminalID, "error", err)
+			return
+		}
+		intentResp, intentFiltered, clarifyResp = out.resp, out.ok, out.clarify
+	}()
+	go func() {
+		defer prefetch.Done()
+		if group != nil {
+			history, historyErr = s.memoryService.RecentGroupMessages(ctx, req.SessionID, max(s.chatHistoryLimit-1, 0), soulID, group.names)
 		} else {
-			soulProfile = latestSoulProfile
+			history, historyErr = s.memoryService.RecentMessages(ctx, req.SessionID, max(s.chatHistoryLimit-1, 0))
+		}
+		history = append(history, domain.Message{Role: "user", Content: latestUserText})
+		memoryContext, currentSummary, contextErr = s.memoryService.BuildContext(ctx, soulID, req.SessionID, observationDigest)
+		if s.exemplarTokenBudget > 0 {
+			items, err := s.memoryService.ListSoulExemplars(ctx, soulID)
+			if err != nil {
+				s.logger.Warn("load soul exemplars failed", "soul_id", soulID, "error", err)
+				return
+			}
+			exemplars = exemplarMessages(items, s.exemplarTokenBudget)
 		}
+	}()
+	prefetch.Wait()
+	if intentResp.MatchedLocally() {
+		budget.degrade(degradedIntentLocal)
+	}
+	firstPassDur := time.Since(firstPassStart)
+	replyLang := replyLanguage(preferredLang, inputLang, history)
+	topicLabels := s.turnTopics(latestUserText, history)
+	turn.SetTopics(topicLabels)
+
+	if s.personaEngine != nil && forked {
+		// A fork is a what-if replay: it gets the mood the turn would
+		// cause, but the real soul's emotion is left as it was.
 		result := s.personaEngine.Update(
 			soulProfile.PersonalityVector,
 			soulProfile.EmotionState,
 			persona.UpdateInput{
 				Now:          time.Now().UTC(),
-				UserEmotion:  userEmotion,
+				UserEmotion:  persona.GateSignal(userEmotion),
 				HasUserInput: true,
 			},
 			personaBaseExecProb,
 		)
 		execProbability = result.ExecProbability
 		execMode = result.ExecMode
+		soulMood, personality = &result.State, &result.Effective
 		soulProfile.EmotionState = result.State
-		if err := s.memoryService.UpdateSoulEmotionState(ctx, soulID, result.State); err != nil {
+	} else if s.personaEngine != nil {
+		unlock := s.lockSoulEmotion(ctx, soulID)
+		if latestSoulProfile, latestErr := s.memoryService.GetSoulProfileByID(ctx, soulID); latestErr != nil {
+			s.logger.Warn("refresh soul profile before persona update failed", "soul_id", soulID, "error", latestErr)
+		} else {
+			soulProfile = latestSoulProfile
+			replaySoul = latestSoulProfile
+		}
+		personaNow := time.Now().UTC()
+		prevEmotionState := soulProfile.EmotionState
+		if s.emotionTrace != nil && !private {
+			if err := s.emotionTrace.Record(req.SessionID, personaNow, userEmotion); err != nil {
+				s.logger.Warn("record emotion trace failed", "session_id", req.SessionID, "error", err)
+			}
+		}
+		var result persona.UpdateResult
+		stored, err := s.storeSoulEmotion(ctx, soulProfile, func(p domain.SoulProfile) domain.SoulEmotionState {
+			result = s.personaEngine.Update(
+				p.PersonalityVector,
+				p.EmotionState,
+				persona.UpdateInput{
+					Now:          personaNow,
+					UserEmotion:  persona.GateSignal(userEmotion),
+					HasUserInput: true,
+				},
+				personaBaseExecProb,
+			)
+			return result.State
+		})
+		if err != nil {
 			s.logger.Warn("update soul emotion state failed", "soul_id", soulID, "error", err)
+		} else if stored.EmotionVersion != soulProfile.EmotionVersion {
+			// Another replica moved the soul first; the update was
+			// reapplied on top of its state.
+			soulProfile, replaySoul = stored, stored
+			prevEmotionState = stored.EmotionState
 		}
-		s.emotionMu.Unlock()
-		if publisher, ok := s.invoker.(EmotionPublisher); ok {
+		execProbability = result.ExecProbability
+		execMode = result.ExecMode
+		soulMood, personality = &result.State, &result.Effective
+		soulProfile.EmotionState = result.State
+		unlock()
+		s.reportGateLock(ctx, req.SessionID, req.TerminalID, soulID, prevEmotionState, result.State, personaNow)
+		if publisher, ok := s.eventPublisher().(EmotionPublisher); ok {
 			payload := domain.EmotionUpdatePayload{
 				SessionID:       req.SessionID,
 				TerminalID:      req.TerminalID,
@@ -208,21 +545,89 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 				s.logger.Warn("publish emotion update failed", "terminal_id", req.TerminalID, "error", err)
 			}
 		}
+		s.spreadEmotion(ctx, soulID, soulProfile.UserID, prevEmotionState, result.State, persona.GateSignal(userEmotion), personaNow)
+	}
+
+	if req.ForceExecute {
+		s.logger.Info("emotion gate overridden", "session_id", req.SessionID, "terminal_id", req.TerminalID, "soul_id", soulID, "exec_mode", execMode, "exec_probability", execProbability)
+		execProbability, execMode = 1, "auto_execute"
 	}
 
-	intentResp, intentMatched := s.tryIntentAction(ctx, req, soulID, latestUserText, execProbability, execMode)
+	intentUtterance := latestUserText
+	clarifyReply := ""
+	if clarifying {
+		switch {
+		case isClarifyCancel(latestUserText):
+			intentResp, intentFiltered = domain.IntentFilterResponse{}, false
+			clarifyReply = "好的，已取消。"
+		case startsNewIntent(intentResp, pendingClarify):
+			s.logger.Info("clarification abandoned for new intent", "session_id", req.SessionID, "terminal_id", req.TerminalID)
+		default:
+			// The answer may fill a slot verbatim, so it is normalized again.
+			intentResp, intentFiltered = intent.NormalizeSlots(s.skillRegistry.GetIntentCatalog(req.TerminalID), mergeClarification(pendingClarify, clarifyResp, latestUserText)), true
+			intentUtterance = pendingClarify.utterance + "，" + latestUserText
+		}
+	}
+	var intentExecMode string
+	var intentMatched bool
+	if speculated != nil {
+		intentExecMode, intentMatched = speculated.execMode, speculated.matched
+	} else {
+		intentResp = s.dropQuietBlockedIntents(intentResp, quiet)
+		if childMode {
+			intentResp = dropIntentsBySkill(intentResp, s.childMode.blocks)
+		}
+		intentResp, intentExecMode = s.bypassGateForIntents(req.TerminalID, intentResp, execMode)
+		intentMatched = intentFiltered && s.dispatchIntentAction(ctx, req, soulID, intentResp, execProbability, intentExecMode, dryRun)
+	}
 	if strings.TrimSpace(intentResp.Decision.Action) != "" {
 		intentDecision = intentResp.Decision.Action
 	}
-	if intentMatched {
-		reply := intentReplyByMode(intentResp.Decision.Action, execMode)
+	if capture := replay.FromContext(ctx); capture != nil {
+		capture.Inputs(domain.ReplayInputs{
+			Soul:            replaySoul,
+			History:         append([]domain.Message(nil), history[:max(len(history)-1, 0)]...),
+			Skills:          s.skillRegistry.GetSkills(req.TerminalID),
+			IntentCatalog:   s.skillRegistry.GetIntentCatalog(req.TerminalID),
+			UserEmotion:     userEmotion,
+			Intent:          intentResp,
+			IntentFiltered:  intentFiltered,
+			ExecMode:        execMode,
+			ExecProbability: execProbability,
+			ForceExecute:    req.ForceExecute,
+			QuietHours:      quiet != nil,
+		})
+	}
+	if missing := clarificationIntents(intentResp); len(missing) > 0 && s.clarifications.enabled() {
+		attempts := 1
+		if clarifying && intentUtterance != latestUserText {
+			attempts = pendingClarify.attempts + 1
+		}
+		if attempts <= maxClarifyAttempts {
+			s.clarifications.hold(req.TerminalID, pendingClarification{
+				sessionID: req.SessionID,
+				utterance: intentUtterance,
+				intents:   missing,
+				attempts:  attempts,
+			}, time.Now())
+			clarifyReply = clarificationQuestion(missing)
+		}
+	}
+	if intentMatched || clarifyReply != "" {
+		reply := clarifyReply
+		if intentMatched {
+			reply = intentReplyByMode(intentResp.Decision.Action, intentExecMode, dryRun, replyLang) + clarifyReply
+		}
 		executedSkills := []string(nil)
-		if strings.TrimSpace(execMode) == "auto_execute" {
+		if strings.TrimSpace(intentExecMode) == "auto_execute" && !dryRun {
 			executedSkills = extractExecutedSkillsFromIntents(intentResp, skillNameSet(s.skillRegistry.GetSkills(req.TerminalID)))
 		}
-		if err := s.memoryService.PersistMessage(ctx, req.SessionID, userID, req.TerminalID, soulID, "assistant", "", "", reply); err != nil {
+		turn.AddMessage("assistant", "", "", reply)
+		turn.SetAffect(turnAffect(userEmotion, soulProfile.EmotionState, execMode, execProbability, req.ForceExecute))
+		if err := s.memoryService.CommitTurn(ctx, turn); err != nil {
 			return domain.ChatResponse{}, err
 		}
+		s.openFollowUpWindow(ctx, req.TerminalID, req.SessionID)
 		return domain.ChatResponse{
 			SessionID:       req.SessionID,
 			TerminalID:      req.TerminalID,
@@ -232,20 +637,36 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 			IntentDecision:  intentDecision,
 			ExecMode:        execMode,
 			ExecProbability: execProbability,
+			Speaker:         speakerIdentity,
+			FollowUp:        followUp,
+			DryRun:          dryRun,
+			QuietHours:      quiet != nil,
+			Private:         private,
+			ChildMode:       childMode,
+			Language:        replyLang,
+			Topics:          topicLabels,
+			SoulEmotion:     soulMood,
+			Personality:     personality,
 		}, nil
 	}
 
-	history, err := s.memoryService.RecentMessages(ctx, req.SessionID, s.chatHistoryLimit)
-	if err != nil {
-		return domain.ChatResponse{}, err
+	if historyErr != nil {
+		return domain.ChatResponse{}, historyErr
 	}
-
-	memoryContext, currentSummary, err := s.memoryService.BuildContext(ctx, soulID, req.SessionID, observationDigest)
-	if err != nil {
-		return domain.ChatResponse{}, err
+	// Exemplars go in only now, so language and topic detection above see
+	// the real conversation alone.
+	history = append(exemplars, history...)
+	if contextErr != nil {
+		return domain.ChatResponse{}, contextErr
+	}
+	if followUp {
+		memoryContext += "\n本轮输入发生在上一条回复后的追问窗口内，视为同一话题的延续。"
 	}
 
 	terminalSkills := s.skillRegistry.GetSkills(req.TerminalID)
+	if childMode {
+		terminalSkills = s.childMode.allowedSkills(terminalSkills)
+	}
 	terminalTools := make([]domain.LLMTool, 0, len(terminalSkills))
 	terminalSkillSet := make(map[string]struct{}, len(terminalSkills))
 	for _, sk := range terminalSkills {
@@ -258,19 +679,46 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 	}
 	mem0Ready := s.memoryService.IsMem0RecallReady(ctx)
 	firstPassTools := append([]domain.LLMTool{}, terminalTools...)
+	firstPassTools = append(firstPassTools, selfStatusTool)
+	if s.hasKnowledge(ctx, soulID) {
+		firstPassTools = append(firstPassTools, searchKnowledgeTool)
+	}
 	if mem0Ready {
 		firstPassTools = append(firstPassTools, domain.LLMTool{
 			Name:        recallMemoryToolName,
-			Description: "回顾历史记忆。当你需要从长期记忆中补全事实、偏好、过往约束时调用。参数: query(string,必填), top_k(integer,可选,默认5)。",
-			Schema:      json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"},"top_k":{"type":"integer","minimum":1,"maximum":10}},"required":["query"]}`),
+			Description: "回顾历史记忆。当你需要从长期记忆中补全事实、偏好、过往约束时调用。参数: query(string,必填), top_k(integer,可选,默认5), tags(string数组,可选,只找带这些标签的记忆，如用户说“只找和工作有关的记忆”时传[\"工作\"])。",
+			Schema:      json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"},"top_k":{"type":"integer","minimum":1,"maximum":10},"tags":{"type":"array","items":{"type":"string"}}},"required":["query"]}`),
+		})
+		firstPassTools = append(firstPassTools, domain.LLMTool{
+			Name:        correctMemoryToolName,
+			Description: "更正长期记忆。当用户指出你记错了（如“你记错了，我不喝咖啡”）时调用。参数: original(string,必填,记错的内容), correction(string,可选,正确的说法；用户只要求忘掉时留空)。",
+			Schema:      json.RawMessage(`{"type":"object","properties":{"original":{"type":"string"},"correction":{"type":"string"}},"required":["original"]}`),
 		})
 	}
 
 	firstLLMNow := time.Now().UTC()
 	execProbability, execMode = s.evaluateExecGateAt(firstLLMNow, soulProfile, execProbability, execMode)
+	if req.ForceExecute {
+		execProbability, execMode = 1, "auto_execute"
+	}
+	replay.FromContext(ctx).Gate(execMode, execProbability)
 	firstEmotionSnapshot := buildLLMEmotionPromptSnapshot(firstLLMNow, userEmotion, soulProfile.EmotionState, execMode, execProbability)
-	relationGuidance := buildPersonaRelationGuidance(latestUserText, soulProfile)
-	systemPrompt := buildSystemPrompt(memoryContext, terminalSkills, mem0Ready, firstEmotionSnapshot, relationGuidance)
+	rapport := s.relationRapport(ctx, soulID, rapportUserID(userID, speakerIdentity))
+	relationGuidance := buildPersonaRelationGuidance(latestUserText, soulProfile, speakerIdentity) + buildRapportGuidance(rapport, firstLLMNow)
+	outputCaps := s.skillRegistry.GetOutputCapabilities(req.TerminalID)
+	terminalCaps := s.skillRegistry.GetCapabilities(req.TerminalID)
+	flakySkills := s.skillRegistry.FlakySkills(req.TerminalID)
+	notes := buildCharacterCardNotes(soulProfile.CharacterCard) + buildExemplarNotes(len(exemplars)/2)
+	if quiet != nil {
+		notes += buildQuietHoursNotes(quiet, s.quietHours.AllowedSkills())
+	}
+	if childMode {
+		notes += buildChildModeNotes(s.childMode.maxReplyRunes)
+	}
+	notes += buildTopicNotes(topicLabels)
+	notes += buildSoulGroupNotes(group)
+	notes += s.buildGateBypassNotes(req.TerminalID, terminalSkills)
+	systemPrompt := buildSystemPrompt(memoryContext, terminalSkills, mem0Ready, firstEmotionSnapshot, relationGuidance, outputCaps, terminalCaps, flakySkills, replyLang, notes)
 	llmReq := domain.LLMRequest{
 		Model:    s.llmModel,
 		System:   systemPrompt,
@@ -278,7 +726,7 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 		Messages: history,
 	}
 	firstLLMStart := time.Now()
-	firstResp, err := s.llmProvider.Complete(ctx, llmReq)
+	firstResp, err := s.complete(ctx, llmReq)
 	firstLLMDur = time.Since(firstLLMStart)
 	if err != nil {
 		return domain.ChatResponse{}, err
@@ -286,20 +734,39 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 
 	reply := firstResp.Content
 	executedSkills := make([]string, 0, len(firstResp.ToolCalls))
+	var confirmations []string
+	// heldSkills are skill calls that did not run, which the reply must not
+	// report as done.
+	var heldSkills []string
+	safetyBlocked := false
 	if len(firstResp.ToolCalls) > 0 {
-		history = append(history, domain.Message{Role: "assistant", Content: firstResp.Content, ToolCalls: firstResp.ToolCalls})
+		history = append(history, domain.Message{Role: "assistant", Content: firstResp.Content, ToolCalls: firstResp.ToolCalls, Thinking: firstResp.Thinking})
 	}
 
-	recallMode := false
+	// Tool outputs are capped per tool before the LLM sees them and the
+	// turn stores them.
+	addToolMessage := func(tc domain.ToolCall, output string) {
+		output = s.fitToolOutput(ctx, tc.Name, latestUserText, output)
+		history = append(history, domain.Message{Role: "tool", Name: tc.Name, ToolCallID: tc.ID, Content: output})
+		turn.AddMessage("tool", tc.Name, tc.ID, output)
+	}
+
+	var recalledMemories []domain.RecalledMemory
+	// Server tools (memory, self status, knowledge) run here and the LLM is asked
+	// again with their results; only recall_memory is announced to the
+	// terminal.
+	recallMode, recalling := false, false
 	for _, tc := range firstResp.ToolCalls {
-		if tc.Name == recallMemoryToolName {
+		switch tc.Name {
+		case recallMemoryToolName:
+			recallMode, recalling = true, true
+		case correctMemoryToolName, selfStatusToolName, searchKnowledgeToolName:
 			recallMode = true
-			break
 		}
 	}
 
 	if recallMode {
-		if publisher, ok := s.invoker.(StatusPublisher); ok {
+		if publisher, ok := s.eventPublisher().(StatusPublisher); recalling && ok {
 			if err := publisher.PublishStatus(ctx, req.TerminalID, "mem0_searching", "正在回顾历史记忆，请稍候。", req.SessionID); err != nil {
 				s.logger.Warn("publish status failed", "status", "mem0_searching", "error", err)
 			}
@@ -307,31 +774,43 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 
 		recallFailed := false
 		for _, tc := range firstResp.ToolCalls {
+			if tc.Name == correctMemoryToolName {
+				toolOutput := s.executeCorrectMemoryTool(ctx, tc.Arguments, req.TerminalID, req.SessionID, userID, soulID, dryRun)
+				addToolMessage(tc, toolOutput)
+				if !dryRun {
+					executedSkills = append(executedSkills, tc.Name)
+				}
+				continue
+			}
+			if tc.Name == selfStatusToolName {
+				toolOutput := s.executeSelfStatusTool(ctx, req.TerminalID, userID, soulID, time.Now())
+				addToolMessage(tc, toolOutput)
+				executedSkills = append(executedSkills, tc.Name)
+				continue
+			}
+			if tc.Name == searchKnowledgeToolName {
+				toolOutput := s.executeSearchKnowledgeTool(ctx, tc.Arguments, latestUserText, soulID)
+				addToolMessage(tc, toolOutput)
+				executedSkills = append(executedSkills, tc.Name)
+				continue
+			}
 			if tc.Name != recallMemoryToolName {
 				s.logger.Warn("skip non-recall skill from first pass in recall mode", "skill", tc.Name, "session_id", req.SessionID)
 				continue
 			}
 			recallStart := time.Now()
-			toolOutput, recallErr := s.executeRecallMemoryTool(ctx, tc.Arguments, latestUserText, userID, req.TerminalID, soulID)
+			toolOutput, recalled, recallErr := s.executeRecallMemoryTool(ctx, budget, tc.Arguments, latestUserText, userID, req.TerminalID, soulID)
 			recallToolDur += time.Since(recallStart)
 			if recallErr != nil {
 				recallFailed = true
 			}
+			recalledMemories = append(recalledMemories, recalled...)
 
-			history = append(history, domain.Message{
-				Role:       "tool",
-				Name:       tc.Name,
-				ToolCallID: tc.ID,
-				Content:    toolOutput,
-			})
+			addToolMessage(tc, toolOutput)
 			executedSkills = append(executedSkills, tc.Name)
-
-			if err := s.memoryService.PersistMessage(ctx, req.SessionID, userID, req.TerminalID, soulID, "tool", tc.Name, tc.ID, toolOutput); err != nil {
-				s.logger.Warn("persist recall tool result failed", "error", err)
-			}
 		}
 
-		if publisher, ok := s.invoker.(StatusPublisher); ok {
+		if publisher, ok := s.eventPublisher().(StatusPublisher); recalling && ok {
 			status := "mem0_search_done"
 			msg := "历史记忆回顾完成。"
 			if recallFailed {
@@ -350,12 +829,16 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 		}
 		secondLLMNow := time.Now().UTC()
 		execProbability, execMode = s.evaluateExecGateAt(secondLLMNow, soulProfile, execProbability, execMode)
+		if req.ForceExecute {
+			execProbability, execMode = 1, "auto_execute"
+		}
+		replay.FromContext(ctx).Gate(execMode, execProbability)
 		secondEmotionSnapshot := buildLLMEmotionPromptSnapshot(secondLLMNow, userEmotion, soulProfile.EmotionState, execMode, execProbability)
-		secondRelationGuidance := buildPersonaRelationGuidance(latestUserText, soulProfile)
-		secondSystemPrompt := buildSystemPrompt(memoryContext, terminalSkills, false, secondEmotionSnapshot, secondRelationGuidance)
+		secondRelationGuidance := buildPersonaRelationGuidance(latestUserText, soulProfile, speakerIdentity) + buildRapportGuidance(rapport, secondLLMNow)
+		secondSystemPrompt := buildSystemPrompt(memoryContext, terminalSkills, false, secondEmotionSnapshot, secondRelationGuidance, outputCaps, terminalCaps, flakySkills, replyLang, notes)
 
 		secondLLMStart := time.Now()
-		secondResp, secondErr := s.llmProvider.Complete(ctx, domain.LLMRequest{
+		secondResp, secondErr := s.complete(ctx, domain.LLMRequest{
 			Model:    s.llmModel,
 			System:   secondSystemPrompt,
 			Tools:    terminalTools,
@@ -369,23 +852,24 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 			for _, tc := range secondResp.ToolCalls {
 				if _, ok := terminalSkillSet[tc.Name]; !ok {
 					s.logger.Warn("skip unregistered skill from second pass", "skill", tc.Name, "session_id", req.SessionID)
+					heldSkills = append(heldSkills, tc.Name)
+					continue
+				}
+				if s.screenOutput(ctx, req, userID, soulID, "tool_call", tc.Name, string(tc.Arguments), private) {
+					safetyBlocked = true
+					toolOutput := safetyBlockedSkillOutput(tc.Name)
+					addToolMessage(tc, toolOutput)
 					continue
 				}
 				toolStart := time.Now()
-				toolOutput := s.executeTerminalSkillWithGate(ctx, req.TerminalID, tc.Name, tc.Arguments, execMode, execProbability)
+				toolOutput, confirmation := s.executeTerminalSkillWithGate(ctx, req.TerminalID, req.SessionID, tc.Name, tc.Arguments, execMode, execProbability, dryRun, quiet)
+				confirmations = append(confirmations, confirmation)
 				terminalToolDur += time.Since(toolStart)
-				history = append(history, domain.Message{
-					Role:       "tool",
-					Name:       tc.Name,
-					ToolCallID: tc.ID,
-					Content:    toolOutput,
-				})
-				if execMode == "auto_execute" {
+				addToolMessage(tc, toolOutput)
+				if s.gateAllows(req.TerminalID, tc.Name, execMode) && !dryRun && !s.quietBlocks(quiet, tc.Name) {
 					executedSkills = append(executedSkills, tc.Name)
-				}
-
-				if err := s.memoryService.PersistMessage(ctx, req.SessionID, userID, req.TerminalID, soulID, "tool", tc.Name, tc.ID, toolOutput); err != nil {
-					s.logger.Warn("persist terminal tool result failed", "error", err)
+				} else {
+					heldSkills = append(heldSkills, tc.Name)
 				}
 			}
 		}
@@ -393,41 +877,101 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 		for _, tc := range firstResp.ToolCalls {
 			if _, ok := terminalSkillSet[tc.Name]; !ok {
 				s.logger.Warn("skip unregistered skill from first pass", "skill", tc.Name, "session_id", req.SessionID)
+				heldSkills = append(heldSkills, tc.Name)
+				continue
+			}
+			if s.screenOutput(ctx, req, userID, soulID, "tool_call", tc.Name, string(tc.Arguments), private) {
+				safetyBlocked = true
+				toolOutput := safetyBlockedSkillOutput(tc.Name)
+				addToolMessage(tc, toolOutput)
 				continue
 			}
 			toolStart := time.Now()
-			toolOutput := s.executeTerminalSkillWithGate(ctx, req.TerminalID, tc.Name, tc.Arguments, execMode, execProbability)
+			toolOutput, confirmation := s.executeTerminalSkillWithGate(ctx, req.TerminalID, req.SessionID, tc.Name, tc.Arguments, execMode, execProbability, dryRun, quiet)
+			confirmations = append(confirmations, confirmation)
 			terminalToolDur += time.Since(toolStart)
-			history = append(history, domain.Message{
-				Role:       "tool",
-				Name:       tc.Name,
-				ToolCallID: tc.ID,
-				Content:    toolOutput,
-			})
-			if execMode == "auto_execute" {
+			addToolMessage(tc, toolOutput)
+			if s.gateAllows(req.TerminalID, tc.Name, execMode) && !dryRun && !s.quietBlocks(quiet, tc.Name) {
 				executedSkills = append(executedSkills, tc.Name)
+			} else {
+				heldSkills = append(heldSkills, tc.Name)
 			}
-
-			if err := s.memoryService.PersistMessage(ctx, req.SessionID, userID, req.TerminalID, soulID, "tool", tc.Name, tc.ID, toolOutput); err != nil {
-				s.logger.Warn("persist tool result failed", "error", err)
+		}
+	}
+	if looked, ok := s.lookAtSnapshots(ctx, req.TerminalID, systemPrompt, history, shots.take()); ok {
+		reply = looked
+	}
+	replyRevised := false
+	if !safetyBlocked {
+		ran := make([]string, 0, len(executedSkills))
+		for _, name := range executedSkills {
+			if _, ok := terminalSkillSet[name]; ok {
+				ran = append(ran, name)
 			}
 		}
+		available := make([]string, 0, len(terminalSkills))
+		for _, sk := range terminalSkills {
+			available = append(available, sk.Name)
+		}
+		maxRunes := 0
+		if childMode {
+			maxRunes = s.childMode.maxReplyRunes
+		} else if outputCaps != nil {
+			maxRunes = outputCaps.MaxChars
+		}
+		var style string
+		if soulProfile.CharacterCard != nil {
+			style = soulProfile.CharacterCard.SpeakingStyle
+		}
+		if revised, ok := s.critiqueReply(ctx, criticInput{
+			userText:      latestUserText,
+			reply:         reply,
+			skills:        available,
+			executed:      ran,
+			notRun:        heldSkills,
+			execMode:      execMode,
+			dryRun:        dryRun,
+			quiet:         quiet != nil,
+			maxRunes:      maxRunes,
+			speakingStyle: style,
+		}); ok {
+			reply, replyRevised = revised, true
+		}
 	}
 
-	reply, silentReply := normalizeAssistantReply(reply)
+	processed := s.replyFilters.Chain(req.TerminalID).Run(reply, replyfilter.Context{Output: outputCaps, Language: replyLang})
+	reply, silentReply := processed.Text, processed.Silent
+	// A blocked skill call also discards the reply, which may describe the
+	// blocked action as done.
+	if safetyBlocked || s.screenOutput(ctx, req, userID, soulID, "reply", "", reply, private) {
+		reply, silentReply, safetyBlocked = s.safety.Refusal(), false, true
+	} else if childMode {
+		reply = replyfilter.Shorten(reply, s.childMode.maxReplyRunes)
+	}
+	if confirmed := appendConfirmations(reply, confirmations); confirmed != reply {
+		reply, silentReply = confirmed, false
+	}
 	if reply == "" && !silentReply {
 		reply = "已处理请求。"
 	}
 
-	if err := s.memoryService.PersistMessage(ctx, req.SessionID, userID, req.TerminalID, soulID, "assistant", "", "", reply); err != nil {
+	turn.AddMessage("assistant", "", "", reply)
+	turn.SetAffect(turnAffect(userEmotion, soulProfile.EmotionState, execMode, execProbability, req.ForceExecute))
+	if err := s.memoryService.CommitTurn(ctx, turn); err != nil {
 		return domain.ChatResponse{}, err
 	}
+	if !silentReply {
+		s.openFollowUpWindow(ctx, req.TerminalID, req.SessionID)
+	}
 
 	summaryOut := currentSummary
-	if compressed, changed, compErr := s.memoryService.MaybeCompressSession(ctx, req.SessionID, userID, req.TerminalID, soulID, false); compErr != nil {
-		s.logger.Warn("session compaction failed", "session_id", req.SessionID, "error", compErr)
-	} else if changed || strings.TrimSpace(compressed) != "" {
-		summaryOut = compressed
+	// Forks are not compacted: a replay should leave no summaries behind.
+	if !forked {
+		if compressed, changed, compErr := s.compressSession(ctx, req.SessionID, userID, req.TerminalID, soulID); compErr != nil {
+			s.logger.Warn("session compaction failed", "session_id", req.SessionID, "error", compErr)
+		} else if changed || strings.TrimSpace(compressed) != "" {
+			summaryOut = compressed
+		}
 	}
 	if strings.TrimSpace(summaryOut) == "" {
 		if latest, latestErr := s.memoryService.GetSessionSummary(ctx, req.SessionID); latestErr == nil {
@@ -441,27 +985,54 @@ func (s *Service) HandleChat(ctx context.Context, req domain.ChatRequest) (domai
 		"terminal_id", req.TerminalID,
 		"mem0_ready", mem0Ready,
 		"recall_mode", recallMode,
+		"follow_up", followUp,
+		"first_pass_ms", firstPassDur.Milliseconds(),
 		"first_llm_ms", firstLLMDur.Milliseconds(),
 		"recall_tool_ms", recallToolDur.Milliseconds(),
 		"second_llm_ms", secondLLMDur.Milliseconds(),
 		"terminal_tool_ms", terminalToolDur.Milliseconds(),
 		"total_ms", totalDur.Milliseconds(),
+		"degraded", budget.Degraded(),
 	)
 
 	return domain.ChatResponse{
-		SessionID:       req.SessionID,
-		TerminalID:      req.TerminalID,
-		SoulID:          soulID,
-		Reply:           reply,
-		ExecutedSkills:  executedSkills,
-		ContextSummary:  strings.TrimSpace(summaryOut),
-		IntentDecision:  intentDecision,
-		ExecMode:        execMode,
-		ExecProbability: execProbability,
+		SessionID:        req.SessionID,
+		TerminalID:       req.TerminalID,
+		SoulID:           soulID,
+		Reply:            reply,
+		ExecutedSkills:   executedSkills,
+		ContextSummary:   strings.TrimSpace(summaryOut),
+		IntentDecision:   intentDecision,
+		ExecMode:         execMode,
+		ExecProbability:  execProbability,
+		Speaker:          speakerIdentity,
+		FollowUp:         followUp,
+		DryRun:           dryRun,
+		QuietHours:       quiet != nil,
+		Private:          private,
+		SafetyBlocked:    safetyBlocked,
+		ChildMode:        childMode,
+		Language:         replyLang,
+		Topics:           topicLabels,
+		SoulEmotion:      soulMood,
+		Personality:      personality,
+		RecalledMemories: recalledMemories,
+		ReplyRevised:     replyRevised,
+		Degraded:         budget.Degraded(),
 	}, nil
 }
 
-func buildSystemPrompt(memoryContext string, skills []domain.SkillDefinition, recallEnabled bool, emotion llmEmotionPromptSnapshot, relationGuidance string) string {
+// complete asks the LLM and records the exchange when the turn is replayed.
+func (s *Service) complete(ctx context.Context, req domain.LLMRequest) (domain.LLMResponse, error) {
+	start := time.Now()
+	resp, err := s.llmProvider.Complete(ctx, req)
+	replay.FromContext(ctx).LLM(req, resp, err, time.Since(start))
+	return resp, err
+}
+
+// buildSystemPrompt appends notes, such as the quiet hours and child mode
+// rules, after the standing rules so they take precedence.
+func buildSystemPrompt(memoryContext string, skills []domain.SkillDefinition, recallEnabled bool, emotion llmEmotionPromptSnapshot, relationGuidance string, output *domain.TerminalOutputCapabilities, caps *domain.TerminalCapabilities, flaky []domain.SkillStats, lang, notes string) string {
 	var sb strings.Builder
 	sb.WriteString("你是单用户桌面机器人编排助手。你只能使用本轮请求提供的 tools 执行动作，不要假设任何未提供工具。\n\n")
 	sb.WriteString("上下文信息：\n")
@@ -499,7 +1070,7 @@ func buildSystemPrompt(memoryContext string, skills []domain.SkillDefinition, re
 	sb.WriteString("4) 若没有合适 tool，可直接文本回复。\n")
 	sb.WriteString("5) tool 参数必须严格符合对应 schema，不要编造字段。\n")
 	if recallEnabled {
-		sb.WriteString("6) 当前提供 recall_memory：仅在确实需要长期记忆时调用。调用后先回顾记忆，再选择终端技能。\n")
+		sb.WriteString("6) 当前提供 recall_memory：仅在确实需要长期记忆时调用。调用后先回顾记忆，再选择终端技能。用户指出你记错了时调用 correct_memory 更正，并向用户确认已更正。\n")
 	} else {
 		sb.WriteString("6) 当前未提供 recall_memory，不要假设可用。\n")
 	}
@@ -512,7 +1083,11 @@ func buildSystemPrompt(memoryContext string, skills []domain.SkillDefinition, re
 	}
 	sb.WriteString("9) 除技能执行外，结合人格关系快照调整措辞、长度、主动性与边界。\n")
 	sb.WriteString("10) 若判断“当前不回复更合适”，仅输出 `<NO_REPLY>`（不要附加任何文字）。\n")
-	sb.WriteString("11) 其余情况保持简洁中文回复。\n")
+	sb.WriteString(buildLanguageRule(lang))
+	sb.WriteString(buildOutputChannelConstraints(output))
+	sb.WriteString(buildCapabilityConstraints(caps))
+	sb.WriteString(buildFlakySkillNotes(skills, flaky))
+	sb.WriteString(notes)
 
 	if len(skills) == 0 {
 		sb.WriteString("当前终端无可用技能，可直接文本回复。\n")
@@ -521,6 +1096,86 @@ func buildSystemPrompt(memoryContext string, skills []domain.SkillDefinition, re
 	return sb.String()
 }
 
+func buildOutputChannelConstraints(output *domain.TerminalOutputCapabilities) string {
+	if output == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n输出通道约束：\n")
+	switch {
+	case output.HasTTS && !output.HasScreen:
+		sb.WriteString("- 纯语音播报：只输出可直接朗读的口语短句，不要使用 Markdown、列表、表格、链接或表情符号。\n")
+	case output.HasScreen && !output.HasTTS:
+		sb.WriteString("- 纯屏幕显示：不要依赖语音语气，信息要一眼可读。\n")
+	case output.HasScreen && output.HasTTS:
+		sb.WriteString("- 屏幕显示并语音播报：回复需同时适合朗读与小屏显示，避免 Markdown 与列表。\n")
+	}
+	if output.ScreenLines > 0 {
+		sb.WriteString(fmt.Sprintf("- 屏幕仅 %d 行：优先一句话给出结论。\n", output.ScreenLines))
+	}
+	if output.MaxChars > 0 {
+		sb.WriteString(fmt.Sprintf("- 回复不超过 %d 个字符，超出部分终端无法展示。\n", output.MaxChars))
+	}
+	return sb.String()
+}
+
+// buildCapabilityConstraints tells the LLM what the terminal physically
+// cannot do, so it neither calls for nor promises such actions.
+func buildCapabilityConstraints(caps *domain.TerminalCapabilities) string {
+	if caps == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n终端硬件能力：\n")
+	if !caps.AudioOut {
+		sb.WriteString("- 没有扬声器：不要提议播放音乐、铃声或任何声音。\n")
+	}
+	if !caps.Display {
+		sb.WriteString("- 没有屏幕：不要提议显示图片、文字或画面。\n")
+	}
+	if len(caps.Motors) == 0 {
+		sb.WriteString("- 没有可动部件：不要提议点头、转头、移动等肢体动作。\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("- 可动部件仅有 %s：不要提议其它部位的动作。\n", strings.Join(caps.Motors, "、")))
+	}
+	if caps.BatteryPowered {
+		sb.WriteString("- 电池供电：避免提议长时间持续运行的动作（如整晚播放、长时间亮灯）。\n")
+	}
+	return sb.String()
+}
+
+// buildFlakySkillNotes flags the skills that have been failing on this
+// terminal, so the LLM can warn the user instead of promising a result.
+func buildFlakySkillNotes(skills []domain.SkillDefinition, flaky []domain.SkillStats) string {
+	offered := skillNameSet(skills)
+	var sb strings.Builder
+	for _, st := range flaky {
+		if _, ok := offered[st.Skill]; !ok {
+			continue
+		}
+		if sb.Len() == 0 {
+			sb.WriteString("\n近期不稳定的技能（仍可调用）：\n")
+		}
+		sb.WriteString(fmt.Sprintf("- %s：最近 %d 次调用失败 %d 次", st.Skill, st.RecentCalls, st.RecentFailures))
+		if reason := truncateRunes(strings.TrimSpace(st.LastError), 40); reason != "" {
+			sb.WriteString("，最近一次原因：" + reason)
+		}
+		sb.WriteString("\n")
+	}
+	if sb.Len() > 0 {
+		sb.WriteString("- 调用上述技能时先告诉用户可能不成功；若工具返回失败，如实说明，不要声称已执行。\n")
+	}
+	return sb.String()
+}
+
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
 type targetPersonaHint struct {
 	Known  bool
 	Source string
@@ -529,7 +1184,7 @@ type targetPersonaHint struct {
 	Cues   []string
 }
 
-func buildPersonaRelationGuidance(latestUserText string, soulProfile domain.SoulProfile) string {
+func buildPersonaRelationGuidance(latestUserText string, soulProfile domain.SoulProfile, speakerIdentity *domain.SpeakerIdentity) string {
 	soulMBTI := strings.ToUpper(strings.TrimSpace(soulProfile.MBTIType))
 	if soulMBTI == "" {
 		soulMBTI = "UNKNOWN"
@@ -543,8 +1198,18 @@ func buildPersonaRelationGuidance(latestUserText string, soulProfile domain.Soul
 	}
 
 	target := inferTargetPersonaHint(latestUserText)
+	if speakerTarget, ok := targetPersonaFromSpeaker(speakerIdentity); ok {
+		target = speakerTarget
+	}
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("- soul_mbti: %s\n", soulMBTI))
+	if speakerIdentity != nil {
+		relation := strings.TrimSpace(speakerIdentity.RelationToOwner)
+		if relation == "" {
+			relation = "unknown"
+		}
+		sb.WriteString(fmt.Sprintf("- speaker: %s (relation=%s, score=%.2f)\n", speakerLabel(speakerIdentity), relation, speakerIdentity.Score))
+	}
 	sb.WriteString(fmt.Sprintf("- soul_traits: empathy=%.2f sensitivity=%.2f stability=%.2f expressiveness=%.2f dominance=%.2f\n", soul.Empathy, soul.Sensitivity, soul.Stability, soul.Expressiveness, soul.Dominance))
 	if !target.Known {
 		sb.WriteString("- target_persona: unknown\n")
@@ -645,18 +1310,23 @@ func inferTargetPersonaHint(text string) targetPersonaHint {
 	}
 }
 
-func normalizeAssistantReply(reply string) (string, bool) {
-	trimmed := strings.TrimSpace(reply)
-	if trimmed == "" {
-		return "", false
+func targetPersonaFromSpeaker(identity *domain.SpeakerIdentity) (targetPersonaHint, bool) {
+	if identity == nil || identity.PersonalityModel == nil {
+		return targetPersonaHint{}, false
 	}
-	marker := strings.ToUpper(strings.Trim(trimmed, "`"))
-	switch marker {
-	case "<NO_REPLY>", "NO_REPLY", "[NO_REPLY]":
-		return "", true
-	default:
-		return trimmed, false
+	return targetPersonaHint{
+		Known:  true,
+		Source: "speaker_id",
+		Label:  speakerLabel(identity),
+		Vector: *identity.PersonalityModel,
+	}, true
+}
+
+func speakerLabel(identity *domain.SpeakerIdentity) string {
+	if name := strings.TrimSpace(identity.Appellation); name != "" {
+		return name
 	}
+	return strings.TrimSpace(identity.DisplayName)
 }
 
 func containsAny(text string, keywords ...string) bool {
@@ -680,6 +1350,19 @@ func (s *Service) evaluateExecGateAt(now time.Time, soulProfile domain.SoulProfi
 	return prob, mode
 }
 
+// turnAffect is what the session affect report keeps of a turn.
+func turnAffect(user domain.EmotionSignal, soul domain.SoulEmotionState, execMode string, execProbability float64, override bool) domain.TurnAffect {
+	return domain.TurnAffect{
+		UserEmotion:     user,
+		SoulP:           soul.P,
+		SoulA:           soul.A,
+		SoulD:           soul.D,
+		ExecMode:        strings.TrimSpace(execMode),
+		ExecProbability: clamp01(execProbability),
+		GateOverride:    override,
+	}
+}
+
 func buildLLMEmotionPromptSnapshot(now time.Time, user domain.EmotionSignal, soul domain.SoulEmotionState, execMode string, execProbability float64) llmEmotionPromptSnapshot {
 	snapshot := llmEmotionPromptSnapshot{
 		At:              now.UTC(),
@@ -819,6 +1502,8 @@ func extractInputs(inputs []domain.ChatInput) ([]string, []pendingInput) {
 			if text := strings.TrimSpace(in.Text); text != "" {
 				keyboardTexts = append(keyboardTexts, text)
 			}
+		case "presence":
+			// Handled by observePresence.
 		default:
 			// TODO(v2): support non-keyboard input types (audio/image/video/sensor_state/...).
 			pending = append(pending, pendingInput{
@@ -864,7 +1549,9 @@ func buildPendingInputDigest(pending []pendingInput) string {
 	return strings.TrimSpace(strings.Join(lines, "\n"))
 }
 
-func (s *Service) tryIntentAction(ctx context.Context, req domain.ChatRequest, soulID, latestUserText string, execProbability float64, execMode string) (domain.IntentFilterResponse, bool) {
+// filterIntent only calls the intent filter service so it can run alongside
+// emotion analysis; dispatchIntentAction applies execMode afterwards.
+func (s *Service) filterIntent(ctx context.Context, req domain.ChatRequest, latestUserText, lang string) (domain.IntentFilterResponse, bool) {
 	if s.intentFilter == nil {
 		return domain.IntentFilterResponse{}, false
 	}
@@ -874,8 +1561,10 @@ func (s *Service) tryIntentAction(ctx context.Context, req domain.ChatRequest, s
 	}
 
 	filterResp, err := s.intentFilter.Filter(ctx, domain.IntentFilterRequest{
+		TerminalID:    req.TerminalID,
 		Command:       latestUserText,
 		IntentCatalog: catalog,
+		Locale:        language.Locale(lang),
 		Options: domain.IntentFilterOptions{
 			AllowMultiIntent:          true,
 			MaxIntents:                8,
@@ -891,35 +1580,30 @@ func (s *Service) tryIntentAction(ctx context.Context, req domain.ChatRequest, s
 		s.logger.Warn("intent filter failed", "session_id", req.SessionID, "terminal_id", req.TerminalID, "error", err)
 		return domain.IntentFilterResponse{}, false
 	}
+	return intent.NormalizeSlots(catalog, filterResp), true
+}
 
+func (s *Service) dispatchIntentAction(ctx context.Context, req domain.ChatRequest, soulID string, filterResp domain.IntentFilterResponse, execProbability float64, execMode string, dryRun bool) bool {
 	if strings.TrimSpace(filterResp.Decision.Action) != "execute_intents" {
-		return filterResp, false
+		return false
 	}
 
-	items := make([]domain.IntentActionItem, 0, len(filterResp.Intents))
-	for _, in := range filterResp.Intents {
-		if strings.TrimSpace(in.Status) != "ready" {
-			continue
-		}
-		items = append(items, domain.IntentActionItem{
-			IntentID:   in.IntentID,
-			IntentName: in.IntentName,
-			Confidence: in.Confidence,
-			Parameters: in.Parameters,
-			Normalized: in.Normalized,
-		})
-	}
+	items := readyIntentItems(filterResp)
 	if len(items) == 0 {
-		return filterResp, false
+		return false
+	}
+	if dryRun {
+		s.publishDryRun(ctx, req.TerminalID, req.SessionID, statusDryRunIntent, dryRunIntentMessage(items, execMode))
+		return true
 	}
 	if execMode != "auto_execute" {
-		return filterResp, true
+		return true
 	}
 
-	pub, ok := s.invoker.(IntentActionPublisher)
+	pub, ok := s.eventPublisher().(IntentActionPublisher)
 	if !ok {
 		s.logger.Warn("intent action publisher is unavailable", "terminal_id", req.TerminalID)
-		return filterResp, false
+		return false
 	}
 
 	requestID := strings.TrimSpace(filterResp.RequestID)
@@ -937,19 +1621,35 @@ func (s *Service) tryIntentAction(ctx context.Context, req domain.ChatRequest, s
 	}
 	if err := pub.PublishIntentAction(ctx, req.TerminalID, payload); err != nil {
 		s.logger.Warn("publish intent action failed", "terminal_id", req.TerminalID, "error", err)
-		return filterResp, false
+		return false
 	}
-	return filterResp, true
+	return true
 }
 
-func intentReplyByMode(intentDecision, execMode string) string {
+func intentReplyByMode(intentDecision, execMode string, dryRun bool, lang string) string {
+	en := lang == language.English
 	if strings.TrimSpace(intentDecision) != "execute_intents" {
+		if en {
+			return "Intent analysis done."
+		}
 		return "已完成意图分析。"
 	}
+	if dryRun {
+		if en {
+			return "Intent matched (dry run, nothing sent to the terminal)."
+		}
+		return "已命中意图（演练模式，未下发执行）。"
+	}
 	switch strings.TrimSpace(execMode) {
 	case "auto_execute":
+		if en {
+			return "Intent matched and sent to the terminal over MQTT."
+		}
 		return "已命中意图并通过 MQTT 下发到终端执行。"
 	default:
+		if en {
+			return "Intent matched, but execution is on hold while emotions run high."
+		}
 		return "已命中意图，但当前情绪波动较高，已暂缓执行。"
 	}
 }
@@ -1017,52 +1717,157 @@ func skillNameSet(skills []domain.SkillDefinition) map[string]struct{} {
 	return out
 }
 
-func (s *Service) executeTerminalSkill(ctx context.Context, terminalID, skill string, args json.RawMessage) string {
+// executeTerminalSkill returns the skill's output for the LLM and any
+// confirmation the skill wants said to the user.
+func (s *Service) executeTerminalSkill(ctx context.Context, terminalID, skill string, args json.RawMessage) (string, string) {
 	invCtx, cancel := context.WithTimeout(ctx, s.toolTimeout)
 	defer cancel()
 
 	result, invokeErr := s.invoker.InvokeSkill(invCtx, terminalID, skill, args)
+	replay.FromContext(ctx).Tool(skill, args, result, invokeErr)
 	if invokeErr != nil {
-		return fmt.Sprintf("技能执行失败: %v", invokeErr)
+		return fmt.Sprintf("技能执行失败: %v", invokeErr), ""
 	}
-	return result.Output
+	recordSnapshot(ctx, result)
+	return result.Output, strings.TrimSpace(result.Confirmation)
 }
 
-func (s *Service) executeTerminalSkillWithGate(ctx context.Context, terminalID, skill string, args json.RawMessage, execMode string, execProbability float64) string {
-	switch strings.TrimSpace(execMode) {
-	case "auto_execute":
+func (s *Service) executeTerminalSkillWithGate(ctx context.Context, terminalID, sessionID, skill string, args json.RawMessage, execMode string, execProbability float64, dryRun bool, quiet *quiethours.Window) (string, string) {
+	if dryRun {
+		s.publishDryRun(ctx, terminalID, sessionID, statusDryRunSkill, dryRunSkillMessage(skill, args, execMode))
+		return fmt.Sprintf("演练模式：技能 %s 未实际执行（mode=%s, prob=%.3f）", skill, execMode, execProbability), ""
+	}
+	if s.quietBlocks(quiet, skill) {
+		return quietSkillOutput(quiet, skill), ""
+	}
+	switch {
+	case strings.TrimSpace(execMode) == "auto_execute":
+		return s.executeTerminalSkill(ctx, terminalID, skill, args)
+	case s.bypassesGate(terminalID, skill):
+		s.logger.Info("skill bypassed emotion gate", "terminal_id", terminalID, "session_id", sessionID, "skill", skill, "exec_mode", execMode)
 		return s.executeTerminalSkill(ctx, terminalID, skill, args)
 	default:
-		return fmt.Sprintf("技能执行已拦截（mode=%s, prob=%.3f, skill=%s）", execMode, execProbability, skill)
+		return fmt.Sprintf("技能执行已拦截（mode=%s, prob=%.3f, skill=%s）", execMode, execProbability, skill), ""
 	}
 }
 
-func (s *Service) executeRecallMemoryTool(ctx context.Context, args json.RawMessage, latestUserText, userID, terminalID, soulID string) (string, error) {
+// appendConfirmations adds skill confirmations the reply does not already
+// contain.
+func appendConfirmations(reply string, confirmations []string) string {
+	for _, c := range confirmations {
+		if c == "" || strings.Contains(reply, c) {
+			continue
+		}
+		if strings.TrimSpace(reply) == "" {
+			reply = c
+			continue
+		}
+		reply = strings.TrimSpace(reply) + "\n" + c
+	}
+	return reply
+}
+
+func (s *Service) executeRecallMemoryTool(ctx context.Context, budget *turnBudget, args json.RawMessage, latestUserText, userID, terminalID, soulID string) (string, []domain.RecalledMemory, error) {
 	query, topK, parseErr := parseRecallMemoryArgs(args, latestUserText)
 	if parseErr != nil {
-		return fmt.Sprintf("记忆查询参数无效: %v", parseErr), parseErr
+		return fmt.Sprintf("记忆查询参数无效: %v", parseErr), nil, parseErr
+	}
+	memories, err := withinBudget(ctx, budget, degradedMem0, s.budget.Mem0, func(ctx context.Context) ([]domain.RecalledMemory, error) {
+		return s.memoryService.RecallFromMem0(ctx, query, memory.ExternalMemoryFilter{
+			UserID:     userID,
+			SoulID:     soulID,
+			TerminalID: terminalID,
+			Tags:       parseRecallMemoryTags(args),
+		}, topK)
+	})
+	if errors.Is(err, errOverBudget) {
+		return "记忆查询超时，已跳过；请不依赖长期记忆直接回答。", nil, err
 	}
-	memories, err := s.memoryService.RecallFromMem0(ctx, query, memory.ExternalMemoryFilter{
-		UserID:     userID,
-		SoulID:     soulID,
-		TerminalID: terminalID,
-	}, topK)
 	if err != nil {
-		return fmt.Sprintf("记忆查询失败: %v", err), err
+		return fmt.Sprintf("记忆查询失败: %v", err), nil, err
 	}
+	if len(memories) > topK {
+		memories = memories[:topK]
+	}
+	return formatRecallResults(memories, s.recallCitations), memories, nil
+}
+
+// formatRecallResults is the recall_memory tool output. With cite set,
+// each memory carries the local date it was made and the LLM is told it
+// may mention it, e.g. "上次你在3月2日说过……".
+func formatRecallResults(memories []domain.RecalledMemory, cite bool) string {
 	if len(memories) == 0 {
-		return "记忆查询结果：未找到相关历史记忆。", nil
+		return "记忆查询结果：未找到相关历史记忆。"
 	}
 
 	var sb strings.Builder
 	sb.WriteString("记忆查询结果:\n")
+	dated := false
 	for i, item := range memories {
-		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, strings.TrimSpace(item)))
-		if i+1 >= topK {
-			break
+		line := strings.TrimSpace(item.Content)
+		if cite {
+			if at, err := time.Parse(time.RFC3339, item.At); err == nil {
+				line += fmt.Sprintf("（记于%s）", at.Local().Format("2006年1月2日"))
+				dated = true
+			}
+		}
+		sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, line))
+	}
+	if dated {
+		sb.WriteString("引用某条记忆时可以自然地带上它的日期，如“上次你在3月2日说过……”；没有日期的记忆不要编造时间。\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// executeCorrectMemoryTool applies a correction the user gave in chat and
+// tells the LLM what was changed. A dry run only reports the correction.
+func (s *Service) executeCorrectMemoryTool(ctx context.Context, args json.RawMessage, terminalID, sessionID, userID, soulID string, dryRun bool) string {
+	var payload struct {
+		Original   string `json:"original"`
+		Correction string `json:"correction"`
+	}
+	if err := json.Unmarshal(args, &payload); err != nil || strings.TrimSpace(payload.Original) == "" {
+		return "记忆更正参数无效: original is required"
+	}
+	if dryRun {
+		s.publishDryRun(ctx, terminalID, sessionID, statusDryRunSkill, dryRunSkillMessage(correctMemoryToolName, args, "auto_execute"))
+		return fmt.Sprintf("演练模式：记忆「%s」未实际更正。", strings.TrimSpace(payload.Original))
+	}
+	item, err := s.memoryService.CorrectMemory(ctx, domain.MemoryCorrection{
+		UserID:     userID,
+		SoulID:     soulID,
+		Original:   payload.Original,
+		Correction: payload.Correction,
+		Source:     domain.MemoryCorrectionSourceChat,
+	})
+	switch {
+	case errors.Is(err, memory.ErrMemoryNotFound):
+		return "记忆更正结果：没有找到相关的历史记忆，无需更正。"
+	case err != nil:
+		s.logger.Warn("correct memory failed", "user_id", userID, "soul_id", soulID, "error", err)
+		return fmt.Sprintf("记忆更正失败: %v", err)
+	case item.Correction == "":
+		return fmt.Sprintf("记忆更正结果：已删除记忆「%s」。", item.Original)
+	default:
+		return fmt.Sprintf("记忆更正结果：记忆「%s」已改为「%s」。", item.Original, item.Correction)
+	}
+}
+
+// parseRecallMemoryTags reads the optional tags that scope a recall.
+func parseRecallMemoryTags(raw json.RawMessage) []string {
+	var payload struct {
+		Tags []string `json:"tags"`
+	}
+	if len(raw) == 0 || json.Unmarshal(raw, &payload) != nil {
+		return nil
+	}
+	tags := make([]string, 0, len(payload.Tags))
+	for _, t := range payload.Tags {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
 		}
 	}
-	return strings.TrimSpace(sb.String()), nil
+	return tags
 }
 
 func parseRecallMemoryArgs(raw json.RawMessage, fallbackQuery string) (string, int, error) {
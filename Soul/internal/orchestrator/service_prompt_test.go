@@ -3,34 +3,12 @@ package orchestrator
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"soul/internal/domain"
+	"soul/internal/quiethours"
 )
 
-func TestNormalizeAssistantReply(t *testing.T) {
-	tests := []struct {
-		name       string
-		input      string
-		wantReply  string
-		wantSilent bool
-	}{
-		{name: "marker angle", input: "<NO_REPLY>", wantReply: "", wantSilent: true},
-		{name: "marker plain", input: "NO_REPLY", wantReply: "", wantSilent: true},
-		{name: "marker bracket", input: "[NO_REPLY]", wantReply: "", wantSilent: true},
-		{name: "normal text", input: "  好的  ", wantReply: "好的", wantSilent: false},
-		{name: "empty", input: "   ", wantReply: "", wantSilent: false},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			gotReply, gotSilent := normalizeAssistantReply(tt.input)
-			if gotReply != tt.wantReply || gotSilent != tt.wantSilent {
-				t.Fatalf("normalizeAssistantReply(%q) = (%q,%v), want (%q,%v)", tt.input, gotReply, gotSilent, tt.wantReply, tt.wantSilent)
-			}
-		})
-	}
-}
-
 func TestInferTargetPersonaHintFromMBTI(t *testing.T) {
 	hint := inferTargetPersonaHint("目标人物是 intj，比较理性")
 	if !hint.Known {
@@ -55,6 +33,11 @@ func TestBuildSystemPromptContainsRelationAndNoReplyRule(t *testing.T) {
 			UserEmotion:     domain.EmotionSignal{Emotion: "neutral", Intensity: 0.2},
 		},
 		"- target_persona: INTJ\n- relation_strategy: 先给结论。",
+		nil,
+		nil,
+		nil,
+		"zh",
+		"",
 	)
 	if !strings.Contains(prompt, "人格关系快照") {
 		t.Fatalf("prompt missing relation snapshot section")
@@ -62,4 +45,135 @@ func TestBuildSystemPromptContainsRelationAndNoReplyRule(t *testing.T) {
 	if !strings.Contains(prompt, "<NO_REPLY>") {
 		t.Fatalf("prompt missing NO_REPLY rule")
 	}
+	if strings.Contains(prompt, "输出通道约束") {
+		t.Fatalf("prompt must not include channel constraints without output capabilities")
+	}
+	if strings.Contains(prompt, "终端硬件能力") {
+		t.Fatalf("prompt must not include hardware constraints without capabilities")
+	}
+}
+
+func TestBuildSystemPromptIncludesOutputChannelConstraints(t *testing.T) {
+	prompt := buildSystemPrompt(
+		"历史会话压缩摘要：\n无",
+		nil,
+		false,
+		llmEmotionPromptSnapshot{ExecMode: "auto_execute", ExecProbability: 1},
+		"",
+		&domain.TerminalOutputCapabilities{HasScreen: true, HasTTS: false, MaxChars: 32, ScreenLines: 2},
+		nil,
+		nil,
+		"zh",
+		"",
+	)
+	for _, want := range []string{"输出通道约束", "纯屏幕显示", "屏幕仅 2 行", "不超过 32 个字符"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("prompt missing %q", want)
+		}
+	}
+}
+
+func TestBuildSystemPromptIncludesCapabilityConstraints(t *testing.T) {
+	prompt := buildSystemPrompt(
+		"历史会话压缩摘要：\n无",
+		nil,
+		false,
+		llmEmotionPromptSnapshot{ExecMode: "auto_execute", ExecProbability: 1},
+		"",
+		nil,
+		&domain.TerminalCapabilities{AudioOut: false, Display: true, Motors: []string{"head_pan"}, BatteryPowered: true},
+		nil,
+		"zh",
+		"",
+	)
+	for _, want := range []string{"终端硬件能力", "没有扬声器", "可动部件仅有 head_pan", "电池供电"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("prompt missing %q", want)
+		}
+	}
+	if strings.Contains(prompt, "没有屏幕") {
+		t.Fatalf("terminal with a display must not be told it has none")
+	}
+}
+
+func TestBuildSystemPromptFlagsFlakySkills(t *testing.T) {
+	prompt := buildSystemPrompt(
+		"历史会话压缩摘要：\n无",
+		[]domain.SkillDefinition{{Name: "volume_set", Description: "音量控制"}},
+		false,
+		llmEmotionPromptSnapshot{ExecMode: "auto_execute", ExecProbability: 1},
+		"",
+		nil,
+		nil,
+		[]domain.SkillStats{
+			{Skill: "volume_set", RecentCalls: 10, RecentFailures: 4, Flaky: true, LastError: "tool timeout"},
+			{Skill: "not_offered", RecentCalls: 5, RecentFailures: 5, Flaky: true},
+		},
+		"zh",
+		"",
+	)
+	for _, want := range []string{"近期不稳定的技能", "volume_set：最近 10 次调用失败 4 次，最近一次原因：tool timeout"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("prompt missing %q", want)
+		}
+	}
+	if strings.Contains(prompt, "not_offered") {
+		t.Fatalf("skills not offered this turn must not be mentioned")
+	}
+}
+
+func TestBuildSystemPromptIncludesQuietHours(t *testing.T) {
+	until := time.Date(2026, 10, 17, 7, 0, 0, 0, time.Local)
+	quiet := &quiethours.Window{Rule: domain.QuietHours{Start: "22:00", End: "07:00", Enabled: true}, Until: until}
+	prompt := buildSystemPrompt(
+		"历史会话压缩摘要：\n无",
+		nil,
+		false,
+		llmEmotionPromptSnapshot{ExecMode: "auto_execute", ExecProbability: 1},
+		"",
+		nil,
+		nil,
+		nil,
+		"zh",
+		buildQuietHoursNotes(quiet, []string{"create_alarm", "set_reminder"}),
+	)
+	for _, want := range []string{"免打扰时段（22:00-07:00，持续到 07:00）", "不会播报", "仅 create_alarm、set_reminder 会执行"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("prompt missing %q", want)
+		}
+	}
+}
+
+func TestFormatRecallResultsCitesDates(t *testing.T) {
+	at := time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local).UTC().Format(time.RFC3339)
+	memories := []domain.RecalledMemory{
+		{Content: "不喝咖啡", At: at},
+		{Content: "养了一只猫"},
+	}
+
+	cited := formatRecallResults(memories, true)
+	if !strings.Contains(cited, "1. 不喝咖啡（记于2026年3月2日）") || !strings.Contains(cited, "2. 养了一只猫\n") {
+		t.Fatalf("expected dated lines, got %q", cited)
+	}
+	if !strings.Contains(cited, "上次你在3月2日说过") {
+		t.Fatalf("expected citation hint, got %q", cited)
+	}
+
+	plain := formatRecallResults(memories, false)
+	if strings.Contains(plain, "记于") || strings.Contains(plain, "上次你在") {
+		t.Fatalf("expected no dates without citations, got %q", plain)
+	}
+	if formatRecallResults(nil, true) != "记忆查询结果：未找到相关历史记忆。" {
+		t.Fatal("unexpected empty result text")
+	}
+}
+
+func TestParseRecallMemoryTags(t *testing.T) {
+	got := parseRecallMemoryTags([]byte(`{"query":"项目","tags":[" 工作 ",""]}`))
+	if len(got) != 1 || got[0] != "工作" {
+		t.Fatalf("unexpected tags %v", got)
+	}
+	if got := parseRecallMemoryTags([]byte(`{"query":"项目"}`)); len(got) != 0 {
+		t.Fatal("expected no tags")
+	}
 }
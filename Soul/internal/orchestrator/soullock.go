@@ -0,0 +1,67 @@
+package orchestrator
+
+import (
+	"context"
+	"time"
+
+	"soul/internal/memory"
+)
+
+// soulLockWait bounds how long a turn waits for another instance's emotion
+// lock before writing under emotionMu alone.
+const soulLockWait = 5 * time.Second
+
+// lockSoulEmotion takes emotionMu, then the soul's emotion lock across
+// instances, and returns the function releasing both. emotionMu comes first
+// so at most one turn per process holds a pooled connection waiting for the
+// lock; taken the other way round, waiters could use up the pool the holder
+// needs to finish. When the lock cannot be taken within soulLockWait the
+// write goes ahead under emotionMu alone; storeSoulEmotion still keeps a
+// concurrent write from being lost.
+func (s *Service) lockSoulEmotion(ctx context.Context, soulID string) (unlock func()) {
+	s.emotionMu.Lock()
+	waitCtx, cancel := context.WithTimeout(ctx, soulLockWait)
+	defer cancel()
+	release, _, err := s.memoryService.LockSoul(waitCtx, soulID, memory.SoulLockEmotion, true)
+	if err != nil {
+		s.logger.Warn("lock soul emotion failed", "soul_id", soulID, "error", err)
+		release = func() {}
+	}
+	return func() {
+		release()
+		s.emotionMu.Unlock()
+	}
+}
+
+// tryLockSoulEmotion is lockSoulEmotion for periodic work: ok is false when
+// another instance holds the lock, and that instance does the work.
+func (s *Service) tryLockSoulEmotion(ctx context.Context, soulID string) (unlock func(), ok bool) {
+	s.emotionMu.Lock()
+	release, ok, err := s.memoryService.LockSoul(ctx, soulID, memory.SoulLockEmotion, false)
+	if err != nil {
+		s.logger.Warn("lock soul emotion failed", "soul_id", soulID, "error", err)
+		release, ok = func() {}, true
+	}
+	if !ok {
+		s.emotionMu.Unlock()
+		return nil, false
+	}
+	return func() {
+		release()
+		s.emotionMu.Unlock()
+	}, true
+}
+
+// compressSession compacts the session after a turn unless another instance
+// is compacting the soul; the next turn compacts it then.
+func (s *Service) compressSession(ctx context.Context, sessionID, userID, terminalID, soulID string) (string, bool, error) {
+	release, ok, err := s.memoryService.LockSoul(ctx, soulID, memory.SoulLockCompaction, false)
+	if err != nil {
+		return "", false, err
+	}
+	if !ok {
+		return "", false, nil
+	}
+	defer release()
+	return s.memoryService.MaybeCompressSession(ctx, sessionID, userID, terminalID, soulID, false)
+}
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+
+	"soul/internal/domain"
+	"soul/internal/speaker"
+)
+
+// resolveSpeaker matches speech_text segments carrying a voice embedding against
+// the enrolled speaker profiles of userID. It returns the inputs with Speaker
+// set on each matched segment, copied so the caller's slice is left alone, and
+// the identity of the last matched segment.
+func (s *Service) resolveSpeaker(ctx context.Context, userID, soulID string, inputs []domain.ChatInput) ([]domain.ChatInput, *domain.SpeakerIdentity) {
+	var profiles []domain.SpeakerProfile
+	loaded := false
+	var resolved *domain.SpeakerIdentity
+	out := inputs
+
+	for i := range inputs {
+		in := &inputs[i]
+		if strings.ToLower(strings.TrimSpace(in.Type)) != "speech_text" || len(in.SpeakerEmbedding) == 0 {
+			continue
+		}
+		if !loaded {
+			loaded = true
+			items, err := s.memoryService.ListSpeakerProfiles(ctx, userID)
+			if err != nil {
+				s.logger.Warn("list speaker profiles failed", "user_id", userID, "error", err)
+				return inputs, nil
+			}
+			profiles = items
+		}
+		if len(profiles) == 0 {
+			return inputs, nil
+		}
+		match, ok := speaker.BestMatch(in.SpeakerEmbedding, profiles, s.speakerMatchThreshold)
+		if !ok {
+			continue
+		}
+		identity := &domain.SpeakerIdentity{
+			SpeakerID:    match.Profile.SpeakerID,
+			DisplayName:  match.Profile.DisplayName,
+			UserID:       match.Profile.SpeakerUserID,
+			RelationUUID: match.Profile.RelationUUID,
+			Score:        match.Score,
+		}
+		if resolved == nil {
+			out = append([]domain.ChatInput(nil), inputs...)
+		}
+		out[i].Speaker = identity
+		resolved = identity
+	}
+
+	if resolved != nil {
+		s.fillRelation(ctx, soulID, resolved)
+	}
+	return out, resolved
+}
+
+// fillRelation adds what the soul calls the person, from the relation the
+// speaker profile points at.
+func (s *Service) fillRelation(ctx context.Context, soulID string, identity *domain.SpeakerIdentity) {
+	if identity.RelationUUID == "" {
+		return
+	}
+	relations, err := s.memoryService.ListSoulUserRelations(ctx, soulID)
+	if err != nil {
+		s.logger.Warn("list soul relations for speaker failed", "soul_id", soulID, "error", err)
+		return
+	}
+	for _, rel := range relations {
+		if rel.RelationUUID != identity.RelationUUID {
+			continue
+		}
+		identity.Appellation = rel.Appellation
+		identity.RelationToOwner = rel.RelationToOwner
+		identity.PersonalityModel = rel.PersonalityModel
+		if identity.UserID == "" {
+			identity.UserID = rel.RelatedUserID
+		}
+		break
+	}
+}
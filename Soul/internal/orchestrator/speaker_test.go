@@ -0,0 +1,62 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+	"soul/internal/memory"
+)
+
+// TestResolveSpeakerLeavesCallerInputs runs against a real database:
+//
+//	SOUL_TEST_DB_DSN=postgres://... go test ./internal/orchestrator -run ResolveSpeaker
+func TestResolveSpeakerLeavesCallerInputs(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_DB_DSN is not set")
+	}
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store, err := db.New(ctx, dsn, db.PoolOptions{MaxConns: 2})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	memorySvc, err := memory.NewService(store, memory.ServiceConfig{LLMModel: "mock"}, logger)
+	if err != nil {
+		t.Fatalf("memory service: %v", err)
+	}
+	userID := "it_" + uuid.NewString()[:8]
+	if _, err := memorySvc.CreateUser(ctx, userID, userID, ""); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := memorySvc.EnrollSpeaker(ctx, domain.EnrollSpeakerPayload{UserID: userID, DisplayName: "妈妈", Embedding: []float64{0.6, 0.8}}); err != nil {
+		t.Fatalf("enroll: %v", err)
+	}
+	svc := &Service{memoryService: memorySvc, speakerMatchThreshold: 0.7, logger: logger}
+
+	inputs := []domain.ChatInput{
+		{Type: "speech_text", Text: "开灯", SpeakerEmbedding: []float64{0.6, 0.8}},
+		{Type: "keyboard_text", Text: "谢谢"},
+	}
+	resolved, identity := svc.resolveSpeaker(ctx, userID, "", inputs)
+	if identity == nil || identity.DisplayName != "妈妈" {
+		t.Fatalf("identity = %+v, want 妈妈", identity)
+	}
+	if resolved[0].Speaker != identity || resolved[1].Speaker != nil {
+		t.Fatalf("resolved inputs = %+v", resolved)
+	}
+	if inputs[0].Speaker != nil {
+		t.Fatalf("caller's inputs were modified: %+v", inputs[0])
+	}
+}
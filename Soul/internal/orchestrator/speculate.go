@@ -0,0 +1,195 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"soul/internal/domain"
+	"soul/internal/quiethours"
+)
+
+// SpeculationConfig tunes intent matching on partial transcripts.
+type SpeculationConfig struct {
+	// Confirm is how many partials in a row must match the same intents
+	// before they are held for the final; 0 disables speculation.
+	Confirm int
+	// MinConfidence is what every speculated intent must reach.
+	MinConfidence float64
+	// TTL is how long a stable match waits for its final transcript.
+	TTL time.Duration
+}
+
+// speculation is the running match of one terminal's current utterance.
+type speculation struct {
+	utteranceID string
+	text        string
+	signature   string
+	streak      int
+	resp        domain.IntentFilterResponse
+	at          time.Time
+}
+
+// intentSpeculator keeps, per terminal, what the partial transcripts of the
+// utterance being spoken match, so a final that only confirms them can
+// skip the intent filter.
+type intentSpeculator struct {
+	cfg SpeculationConfig
+
+	mu         sync.Mutex
+	byTerminal map[string]*speculation
+}
+
+func newIntentSpeculator(cfg SpeculationConfig) *intentSpeculator {
+	return &intentSpeculator{cfg: cfg, byTerminal: make(map[string]*speculation)}
+}
+
+func (p *intentSpeculator) enabled() bool {
+	return p != nil && p.cfg.Confirm > 0
+}
+
+// observe records the match of a partial and returns the speculation it
+// leaves.
+func (p *intentSpeculator) observe(terminalID, utteranceID, text string, resp domain.IntentFilterResponse, now time.Time) speculation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	spec := p.byTerminal[terminalID]
+	if spec == nil || spec.utteranceID != utteranceID {
+		spec = &speculation{utteranceID: utteranceID}
+		p.byTerminal[terminalID] = spec
+	}
+	sig := speculationSignature(resp, p.cfg.MinConfidence)
+	switch {
+	case sig == "":
+		spec.streak = 0
+	case sig == spec.signature:
+		spec.streak++
+	default:
+		spec.streak = 1
+	}
+	spec.text, spec.signature, spec.resp, spec.at = text, sig, resp, now
+	return *spec
+}
+
+// take removes the terminal's speculation and returns its match when it is
+// stable, fresh and was made on the same words as the final text.
+func (p *intentSpeculator) take(terminalID, finalText string, now time.Time) (domain.IntentFilterResponse, bool) {
+	if !p.enabled() {
+		return domain.IntentFilterResponse{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	spec := p.byTerminal[terminalID]
+	delete(p.byTerminal, terminalID)
+	if spec == nil || spec.streak < p.cfg.Confirm || now.Sub(spec.at) > p.cfg.TTL {
+		return domain.IntentFilterResponse{}, false
+	}
+	if normalizeSpeculationText(spec.text) != normalizeSpeculationText(finalText) {
+		return domain.IntentFilterResponse{}, false
+	}
+	return spec.resp, true
+}
+
+// speculationSignature identifies the action a match would fire: its ready
+// intents and their slots. It is empty unless the match executes intents,
+// all of them ready and confident.
+func speculationSignature(resp domain.IntentFilterResponse, minConfidence float64) string {
+	if strings.TrimSpace(resp.Decision.Action) != "execute_intents" || len(resp.Intents) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(resp.Intents))
+	for _, in := range resp.Intents {
+		if strings.TrimSpace(in.Status) != "ready" || in.Confidence < minConfidence {
+			return ""
+		}
+		slots, _ := json.Marshal(in.Normalized)
+		parts = append(parts, in.IntentID+string(slots))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "|")
+}
+
+// normalizeSpeculationText drops what ASR changes between the last partial
+// and the final without changing the words: case, spaces, punctuation.
+func normalizeSpeculationText(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// SpeculateIntent matches a partial transcript and reports whether the
+// intents it fires have stabilized across partials.
+func (s *Service) SpeculateIntent(ctx context.Context, req domain.IntentSpeculationRequest) domain.IntentSpeculation {
+	out := domain.IntentSpeculation{UtteranceID: req.UtteranceID, Text: req.Text}
+	text := strings.TrimSpace(req.Text)
+	if !s.speculator.enabled() || text == "" {
+		return out
+	}
+	resp, ok := s.filterIntent(ctx, domain.ChatRequest{SessionID: req.SessionID, TerminalID: req.TerminalID}, text, "")
+	if !ok {
+		return out
+	}
+	spec := s.speculator.observe(req.TerminalID, req.UtteranceID, text, resp, time.Now())
+	out.Streak = spec.streak
+	out.Stable = spec.streak >= s.speculator.cfg.Confirm
+	if spec.signature != "" {
+		out.Intents = readyIntentItems(resp)
+	}
+	return out
+}
+
+// speculatedIntent is a stable speculation fired as soon as its final
+// transcript arrived.
+type speculatedIntent struct {
+	resp     domain.IntentFilterResponse
+	execMode string
+	matched  bool
+}
+
+// fireSpeculatedIntent dispatches the terminal's stable speculation when
+// text confirms it, ahead of emotion analysis and the rest of the turn.
+// The gate is the soul's as of now, before this turn moves its emotion;
+// quiet hours, child mode and gate bypass apply as for a matched intent.
+func (s *Service) fireSpeculatedIntent(ctx context.Context, req domain.ChatRequest, soulID string, soul domain.SoulProfile, text string, quiet *quiethours.Window, dryRun bool) *speculatedIntent {
+	resp, ok := s.speculator.take(req.TerminalID, text, time.Now())
+	if !ok {
+		return nil
+	}
+	resp = s.dropQuietBlockedIntents(resp, quiet)
+	if soul.ChildMode {
+		resp = dropIntentsBySkill(resp, s.childMode.blocks)
+	}
+	execProbability, execMode := s.evaluateExecGateAt(time.Now().UTC(), soul, 1, "auto_execute")
+	if req.ForceExecute {
+		execProbability, execMode = 1, "auto_execute"
+	}
+	resp, execMode = s.bypassGateForIntents(req.TerminalID, resp, execMode)
+	matched := s.dispatchIntentAction(ctx, req, soulID, resp, execProbability, execMode, dryRun)
+	s.logger.Info("speculated intent fired", "session_id", req.SessionID, "terminal_id", req.TerminalID, "exec_mode", execMode, "matched", matched)
+	return &speculatedIntent{resp: resp, execMode: execMode, matched: matched}
+}
+
+func readyIntentItems(resp domain.IntentFilterResponse) []domain.IntentActionItem {
+	items := make([]domain.IntentActionItem, 0, len(resp.Intents))
+	for _, in := range resp.Intents {
+		if strings.TrimSpace(in.Status) != "ready" {
+			continue
+		}
+		items = append(items, domain.IntentActionItem{
+			IntentID:   in.IntentID,
+			IntentName: in.IntentName,
+			Confidence: in.Confidence,
+			Parameters: in.Parameters,
+			Normalized: in.Normalized,
+		})
+	}
+	return items
+}
@@ -0,0 +1,73 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func speculationMatch(confidence float64, level string) domain.IntentFilterResponse {
+	return domain.IntentFilterResponse{
+		Decision: domain.IntentFilterDecision{Action: "execute_intents"},
+		Intents: []domain.SelectedIntent{{
+			IntentID:   "light_set",
+			Status:     "ready",
+			Confidence: confidence,
+			Normalized: map[string]any{"level": level},
+		}},
+	}
+}
+
+func TestIntentSpeculatorNeedsAStableMatch(t *testing.T) {
+	p := newIntentSpeculator(SpeculationConfig{Confirm: 2, MinConfidence: 0.8, TTL: 10 * time.Second})
+	now := time.Now()
+	if spec := p.observe("robot-1", "u-0", "把灯调", domain.IntentFilterResponse{}, now); spec.streak != 0 {
+		t.Fatalf("no match, streak = %d", spec.streak)
+	}
+	p.observe("robot-1", "u-0", "把灯调到五", speculationMatch(0.9, "5"), now)
+	if spec := p.observe("robot-1", "u-0", "把灯调到五十", speculationMatch(0.9, "50"), now); spec.streak != 1 {
+		t.Fatalf("changed slot must restart the streak, got %d", spec.streak)
+	}
+	if _, ok := p.take("robot-1", "把灯调到五十", now); ok {
+		t.Fatal("a single matching partial is not stable")
+	}
+
+	p.observe("robot-1", "u-1", "把灯调到五十", speculationMatch(0.9, "50"), now)
+	p.observe("robot-1", "u-1", "把灯调到五十 ", speculationMatch(0.95, "50"), now)
+	if _, ok := p.take("robot-1", "把灯调到五十。", now); !ok {
+		t.Fatal("stable match confirmed by the final must be taken")
+	}
+	if _, ok := p.take("robot-1", "把灯调到五十。", now); ok {
+		t.Fatal("a speculation is taken once")
+	}
+
+	p.observe("robot-1", "u-2", "把灯调到五十", speculationMatch(0.9, "50"), now)
+	p.observe("robot-1", "u-2", "把灯调到五十", speculationMatch(0.9, "50"), now)
+	if _, ok := p.take("robot-1", "把灯调到五十就好", now); ok {
+		t.Fatal("a final with more words must be matched again")
+	}
+
+	p.observe("robot-1", "u-3", "把灯调到五十", speculationMatch(0.9, "50"), now)
+	p.observe("robot-1", "u-3", "把灯调到五十", speculationMatch(0.9, "50"), now)
+	if _, ok := p.take("robot-1", "把灯调到五十", now.Add(11*time.Second)); ok {
+		t.Fatal("an expired speculation must not fire")
+	}
+}
+
+func TestSpeculationSignature(t *testing.T) {
+	if speculationSignature(speculationMatch(0.7, "50"), 0.8) != "" {
+		t.Fatal("low-confidence intents must not be speculated")
+	}
+	clarify := speculationMatch(0.9, "50")
+	clarify.Decision.Action = "clarify"
+	if speculationSignature(clarify, 0.8) != "" {
+		t.Fatal("only executing matches are speculated")
+	}
+	if speculationSignature(speculationMatch(0.9, "50"), 0.8) == speculationSignature(speculationMatch(0.9, "5"), 0.8) {
+		t.Fatal("slots belong to the signature")
+	}
+	if got := normalizeSpeculationText("Turn on the light, 好吗？"); got != "turnonthelight好吗" {
+		t.Fatalf("normalizeSpeculationText = %q", got)
+	}
+}
@@ -0,0 +1,97 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"soul/internal/domain"
+)
+
+// ToolOutputConfig bounds what a tool call adds to the history and the
+// stored session.
+type ToolOutputConfig struct {
+	// MaxRunes caps every tool output; 0 leaves outputs whole.
+	MaxRunes int
+	// Limits overrides MaxRunes per tool name.
+	Limits map[string]int
+	// Summarize asks the LLM to condense an oversized output to the limit
+	// before falling back to cutting it.
+	Summarize bool
+}
+
+type toolOutputPolicy struct {
+	maxRunes  int
+	limits    map[string]int
+	summarize bool
+}
+
+func newToolOutputPolicy(cfg ToolOutputConfig) toolOutputPolicy {
+	limits := make(map[string]int, len(cfg.Limits))
+	for name, n := range cfg.Limits {
+		if name = strings.TrimSpace(name); name != "" && n > 0 {
+			limits[name] = n
+		}
+	}
+	return toolOutputPolicy{maxRunes: max(cfg.MaxRunes, 0), limits: limits, summarize: cfg.Summarize}
+}
+
+// limit returns the rune cap for tool, 0 for none.
+func (p toolOutputPolicy) limit(tool string) int {
+	if n, ok := p.limits[tool]; ok {
+		return n
+	}
+	return p.maxRunes
+}
+
+// fitToolOutput returns output within the tool's limit: unchanged when it
+// fits, else summarized for the user's question when enabled, else cut.
+func (s *Service) fitToolOutput(ctx context.Context, tool, question, output string) string {
+	limit := s.toolOutput.limit(tool)
+	n := utf8.RuneCountInString(output)
+	if limit <= 0 || n <= limit {
+		return output
+	}
+	if s.toolOutput.summarize {
+		summary, err := s.summarizeToolOutput(ctx, tool, question, output, limit)
+		if err == nil && summary != "" {
+			s.logger.Info("tool output summarized", "tool", tool, "runes", n, "limit", limit)
+			return truncateToolOutput(fmt.Sprintf("[工具输出过长（%d 字），以下为摘要]\n%s", n, summary), limit, n)
+		}
+		s.logger.Warn("summarize tool output failed, truncating", "tool", tool, "error", err)
+	}
+	return truncateToolOutput(output, limit, n)
+}
+
+func (s *Service) summarizeToolOutput(ctx context.Context, tool, question, output string, limit int) (string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "下面是工具 %s 的输出，内容过长。请把它压缩到 %d 字以内，", tool, limit*4/5)
+	sb.WriteString("优先保留与用户问题相关的事实、数字、名称和结论，保留成功或失败的状态；不要添加原文没有的内容，不要解释。")
+	if question = strings.TrimSpace(question); question != "" {
+		fmt.Fprintf(&sb, "\n\n用户问题：%s", question)
+	}
+	resp, err := s.complete(ctx, domain.LLMRequest{
+		Model:    s.llmModel,
+		System:   sb.String(),
+		Messages: []domain.Message{{Role: "user", Content: output}},
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// truncateToolOutput cuts output to limit runes, marking the cut with the
+// original length n.
+func truncateToolOutput(output string, limit, n int) string {
+	marker := fmt.Sprintf("\n…（已截断，原文 %d 字）", n)
+	keep := limit - utf8.RuneCountInString(marker)
+	if utf8.RuneCountInString(output) <= limit {
+		return output
+	}
+	if keep <= 0 {
+		return string([]rune(output)[:limit])
+	}
+	return string([]rune(output)[:keep]) + marker
+}
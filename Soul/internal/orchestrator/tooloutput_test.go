@@ -0,0 +1,45 @@
+package orchestrator
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"soul/internal/llm"
+)
+
+func TestFitToolOutput(t *testing.T) {
+	long := strings.Repeat("第一段说明。", 100) // 600 runes
+	provider := llm.NewMockProvider(llm.MockFixture{Rules: []llm.MockRule{
+		{Match: "第一段说明", Response: llm.MockResponse{Content: "说明的要点"}},
+	}})
+	svc := &Service{
+		llmProvider: provider,
+		toolOutput:  newToolOutputPolicy(ToolOutputConfig{MaxRunes: 100, Limits: map[string]int{"search_knowledge": 1000}, Summarize: true}),
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	ctx := context.Background()
+
+	if got := svc.fitToolOutput(ctx, "set_light", "开灯", "ok"); got != "ok" {
+		t.Fatalf("short output changed: %q", got)
+	}
+	if got := svc.fitToolOutput(ctx, "search_knowledge", "", long); got != long {
+		t.Fatal("per-tool limit must override the default")
+	}
+	got := svc.fitToolOutput(ctx, "recall_memory", "怎么用", long)
+	if !strings.Contains(got, "说明的要点") || !strings.Contains(got, "600") {
+		t.Fatalf("expected a summary, got %q", got)
+	}
+	if calls := provider.Calls(); len(calls) != 1 || !strings.Contains(calls[0].System, "用户问题：怎么用") {
+		t.Fatalf("summary request must carry the question, got %+v", calls)
+	}
+
+	svc.toolOutput.summarize = false
+	got = svc.fitToolOutput(ctx, "recall_memory", "", long)
+	if n := utf8.RuneCountInString(got); n != 100 || !strings.HasSuffix(got, "（已截断，原文 600 字）") {
+		t.Fatalf("expected a 100-rune cut, got %d runes: %q", n, got)
+	}
+}
@@ -0,0 +1,43 @@
+package orchestrator
+
+import (
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// topicCarryTurns is how many earlier user turns a topicless turn such as
+// "那明天呢" may inherit its topic from.
+const topicCarryTurns = 2
+
+// turnTopics labels the current input; an input with no topic of its own
+// continues the topic of the last few user turns. history ends with the
+// current input.
+func (s *Service) turnTopics(text string, history []domain.Message) []string {
+	if s.topics == nil {
+		return nil
+	}
+	if labels := s.topics.Label(text); len(labels) > 0 {
+		return labels
+	}
+	seen := 0
+	for i := len(history) - 2; i >= 0 && seen < topicCarryTurns; i-- {
+		if history[i].Role != "user" {
+			continue
+		}
+		seen++
+		if labels := s.topics.Label(history[i].Content); len(labels) > 0 {
+			return labels
+		}
+	}
+	return nil
+}
+
+// buildTopicNotes keeps the reply on topic, above all the summary after
+// tool calls, which otherwise tends to drift to the tool result alone.
+func buildTopicNotes(topics []string) string {
+	if len(topics) == 0 {
+		return ""
+	}
+	return "\n当前话题：" + strings.Join(topics, "、") + "。回复（包括工具调用后的总结）要接住当前话题，不要跳到无关内容。\n"
+}
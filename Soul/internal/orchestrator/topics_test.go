@@ -0,0 +1,47 @@
+package orchestrator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+	"soul/internal/topics"
+)
+
+func TestTurnTopicsCarriesOver(t *testing.T) {
+	svc := &Service{topics: topics.New(nil)}
+	history := []domain.Message{
+		{Role: "user", Content: "明天会下雨吗"},
+		{Role: "assistant", Content: "明天有小雨。"},
+		{Role: "user", Content: "那后天呢"},
+	}
+	if got := svc.turnTopics("那后天呢", history); !reflect.DeepEqual(got, []string{"天气"}) {
+		t.Fatalf("want the weather topic carried over, got %v", got)
+	}
+	if got := svc.turnTopics("放首歌吧", history); !reflect.DeepEqual(got, []string{"音乐"}) {
+		t.Fatalf("want the input's own topic, got %v", got)
+	}
+
+	old := []domain.Message{
+		{Role: "user", Content: "明天会下雨吗"},
+		{Role: "user", Content: "嗯"},
+		{Role: "user", Content: "好的"},
+		{Role: "user", Content: "那后天呢"},
+	}
+	if got := svc.turnTopics("那后天呢", old); got != nil {
+		t.Fatalf("topic must not carry over more than %d turns, got %v", topicCarryTurns, got)
+	}
+	if (&Service{}).turnTopics("明天会下雨吗", history) != nil {
+		t.Fatal("topic tracking must be off without a tracker")
+	}
+}
+
+func TestBuildTopicNotes(t *testing.T) {
+	if buildTopicNotes(nil) != "" {
+		t.Fatal("no topics must add no notes")
+	}
+	if notes := buildTopicNotes([]string{"天气", "出行"}); !strings.Contains(notes, "当前话题：天气、出行") {
+		t.Fatalf("unexpected notes %q", notes)
+	}
+}
@@ -0,0 +1,81 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"soul/internal/domain"
+)
+
+// A skill can hand back a photo it took: capture_image uploads a snapshot
+// to blob storage and returns the object in InvokeResult.media. The photos
+// a turn's skills returned are loaded and shown to the LLM in one more
+// call, so "看看桌上有什么" is answered from what the camera saw.
+const snapshotPrompt = "[摄像头画面] 以上是刚才拍到的画面，请据此回答用户的问题；画面看不清或没有相关内容时如实说明，不要编造。"
+
+// MediaFetcher loads an object a terminal uploaded; blob.Service does it.
+type MediaFetcher interface {
+	FetchMedia(ctx context.Context, terminalID string, media domain.InputMedia) ([]byte, error)
+}
+
+type snapshotsKey struct{}
+
+// snapshots collects the images skills returned during one turn.
+type snapshots struct {
+	mu    sync.Mutex
+	items []domain.InputMedia
+}
+
+func withSnapshots(ctx context.Context) (context.Context, *snapshots) {
+	shots := &snapshots{}
+	return context.WithValue(ctx, snapshotsKey{}, shots), shots
+}
+
+// recordSnapshot keeps the result's media when it is an image and the turn
+// collects snapshots.
+func recordSnapshot(ctx context.Context, result domain.InvokeResult) {
+	shots, _ := ctx.Value(snapshotsKey{}).(*snapshots)
+	if shots == nil || result.Media == nil || !strings.HasPrefix(result.Media.Mime, "image/") {
+		return
+	}
+	shots.mu.Lock()
+	shots.items = append(shots.items, *result.Media)
+	shots.mu.Unlock()
+}
+
+func (s *snapshots) take() []domain.InputMedia {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	items := s.items
+	s.items = nil
+	return items
+}
+
+// lookAtSnapshots asks the LLM once more, without tools, with the photos
+// attached after the tool results. It returns false when no photo could be
+// loaded or the call failed, leaving the reply as it was.
+func (s *Service) lookAtSnapshots(ctx context.Context, terminalID, system string, history []domain.Message, shots []domain.InputMedia) (string, bool) {
+	if s.media == nil || len(shots) == 0 {
+		return "", false
+	}
+	images := make([]domain.MessageImage, 0, len(shots))
+	for _, media := range shots {
+		data, err := s.media.FetchMedia(ctx, terminalID, media)
+		if err != nil {
+			s.logger.Warn("load skill snapshot failed", "terminal_id", terminalID, "object_key", media.ObjectKey, "error", err)
+			continue
+		}
+		images = append(images, domain.MessageImage{Mime: media.Mime, Ref: media.ObjectKey, Data: data})
+	}
+	if len(images) == 0 {
+		return "", false
+	}
+	msgs := append(append([]domain.Message(nil), history...), domain.Message{Role: "user", Content: snapshotPrompt, Images: images})
+	resp, err := s.complete(ctx, domain.LLMRequest{Model: s.llmModel, System: system, Messages: msgs})
+	if err != nil {
+		s.logger.Warn("vision llm call failed", "terminal_id", terminalID, "images", len(images), "error", err)
+		return "", false
+	}
+	return resp.Content, true
+}
@@ -0,0 +1,66 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/llm"
+)
+
+type cameraInvoker struct{}
+
+func (cameraInvoker) InvokeSkill(_ context.Context, _ string, skill string, _ json.RawMessage) (domain.InvokeResult, error) {
+	switch skill {
+	case "capture_image":
+		return domain.InvokeResult{OK: true, Output: "已拍照", Media: &domain.InputMedia{Bucket: "soul-media", ObjectKey: "media/t1/desk.jpg", Mime: "image/jpeg"}}, nil
+	case "record_audio":
+		return domain.InvokeResult{OK: true, Output: "已录音", Media: &domain.InputMedia{Bucket: "soul-media", ObjectKey: "media/t1/clip.wav", Mime: "audio/wav"}}, nil
+	}
+	return domain.InvokeResult{OK: true, Output: "done"}, nil
+}
+
+type memMedia map[string][]byte
+
+func (m memMedia) FetchMedia(_ context.Context, _ string, media domain.InputMedia) ([]byte, error) {
+	return m[media.ObjectKey], nil
+}
+
+func TestSnapshotIsShownToTheLLM(t *testing.T) {
+	provider := llm.NewMockProvider(llm.MockFixture{Rules: []llm.MockRule{
+		{Match: "[摄像头画面]", Response: llm.MockResponse{Content: "桌上有一个杯子"}},
+	}})
+	svc := &Service{
+		invoker:     cameraInvoker{},
+		llmProvider: provider,
+		media:       memMedia{"media/t1/desk.jpg": []byte("jpg")},
+		toolTimeout: time.Second,
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	ctx, shots := withSnapshots(context.Background())
+	svc.executeTerminalSkill(ctx, "t1", "record_audio", json.RawMessage(`{}`))
+	if out, _ := svc.executeTerminalSkill(ctx, "t1", "capture_image", json.RawMessage(`{}`)); out != "已拍照" {
+		t.Fatalf("unexpected tool output %q", out)
+	}
+
+	history := []domain.Message{{Role: "user", Content: "看看桌上有什么"}}
+	reply, ok := svc.lookAtSnapshots(ctx, "t1", "sys", history, shots.take())
+	if !ok || reply != "桌上有一个杯子" {
+		t.Fatalf("unexpected vision reply %q %v", reply, ok)
+	}
+	calls := provider.Calls()
+	last := calls[len(calls)-1].Messages
+	if len(last) != 2 || len(last[1].Images) != 1 || last[1].Images[0].Ref != "media/t1/desk.jpg" || string(last[1].Images[0].Data) != "jpg" {
+		t.Fatalf("only the photo must be attached, got %+v", last)
+	}
+	if len(history) != 1 {
+		t.Fatalf("history must not grow, got %+v", history)
+	}
+	if _, ok := svc.lookAtSnapshots(ctx, "t1", "sys", history, shots.take()); ok {
+		t.Fatalf("taken snapshots must not be shown twice")
+	}
+}
@@ -0,0 +1,30 @@
+package persona
+
+import (
+	"math"
+
+	"soul/internal/domain"
+)
+
+// Shock is how far one update moved a soul's PAD state.
+func Shock(prev, next domain.SoulEmotionState) float64 {
+	dp, da, dd := next.P-prev.P, next.A-prev.A, next.D-prev.D
+	return math.Sqrt(dp*dp + da*da + dd*dd)
+}
+
+// Contagion passes share of another soul's shock, the move from sourcePrev
+// to sourceNext, on to a soul whose base personality and state are given,
+// so souls sharing a home react together to big events. Sensitive souls
+// catch more of it: the share is scaled by 0.5 + sensitivity. The soul's
+// shock load rises by the same share of the source's; locks, drift and the
+// long-term statistics are left to the soul's own updates.
+func (e *Engine) Contagion(base domain.PersonalityVector, state, sourcePrev, sourceNext domain.SoulEmotionState, share float64) domain.SoulEmotionState {
+	eff := e.EffectiveVector(base, state.Drift)
+	k := clamp01(share * (0.5 + eff.Sensitivity))
+	out := state
+	out.P = clampSigned(state.P + k*(sourceNext.P-sourcePrev.P))
+	out.A = clampSigned(state.A + k*(sourceNext.A-sourcePrev.A))
+	out.D = clampSigned(state.D + k*(sourceNext.D-sourcePrev.D))
+	out.ShockLoad = clamp01(state.ShockLoad + k*math.Max(0, sourceNext.ShockLoad-sourcePrev.ShockLoad))
+	return out
+}
@@ -1,8 +1,11 @@
 package persona
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"math"
+	"os"
 	"strings"
 	"time"
 
@@ -12,30 +15,30 @@ import (
 const ModelVersion = "persona-pad-v2"
 
 type Config struct {
-	IdleAfterSeconds        float64
-	BoredomTauUpSeconds     float64
-	BoredomTauDownSeconds   float64
-	ActiveRecoverySeconds   float64
-	ImpactBase              float64
-	MaxImpactNorm           float64
-	NegativeImpactGain      float64
-	PositiveImpactGain      float64
-	ShockTheta              float64
-	ShockTauBaseSeconds     float64
-	ShockNegativeGain       float64
-	ShockPositiveGain       float64
-	RecoveryBaseRate        float64
-	ExtremeMemoryTauSeconds float64
-	DriftEtaPerSecond       float64
-	DriftGammaPerSecond     float64
-	DriftMaxAbs             float64
-	LockBaseSeconds         float64
-	LockRefreshMinSeconds   float64
-	LockRefreshMaxSeconds   float64
-	PositiveUnlockMinRatio  float64
-	PositiveUnlockMaxRatio  float64
-	ExtremeEta              float64
-	ShockXi                 float64
+	IdleAfterSeconds        float64 `json:"idle_after_seconds"`
+	BoredomTauUpSeconds     float64 `json:"boredom_tau_up_seconds"`
+	BoredomTauDownSeconds   float64 `json:"boredom_tau_down_seconds"`
+	ActiveRecoverySeconds   float64 `json:"active_recovery_seconds"`
+	ImpactBase              float64 `json:"impact_base"`
+	MaxImpactNorm           float64 `json:"max_impact_norm"`
+	NegativeImpactGain      float64 `json:"negative_impact_gain"`
+	PositiveImpactGain      float64 `json:"positive_impact_gain"`
+	ShockTheta              float64 `json:"shock_theta"`
+	ShockTauBaseSeconds     float64 `json:"shock_tau_base_seconds"`
+	ShockNegativeGain       float64 `json:"shock_negative_gain"`
+	ShockPositiveGain       float64 `json:"shock_positive_gain"`
+	RecoveryBaseRate        float64 `json:"recovery_base_rate"`
+	ExtremeMemoryTauSeconds float64 `json:"extreme_memory_tau_seconds"`
+	DriftEtaPerSecond       float64 `json:"drift_eta_per_second"`
+	DriftGammaPerSecond     float64 `json:"drift_gamma_per_second"`
+	DriftMaxAbs             float64 `json:"drift_max_abs"`
+	LockBaseSeconds         float64 `json:"lock_base_seconds"`
+	LockRefreshMinSeconds   float64 `json:"lock_refresh_min_seconds"`
+	LockRefreshMaxSeconds   float64 `json:"lock_refresh_max_seconds"`
+	PositiveUnlockMinRatio  float64 `json:"positive_unlock_min_ratio"`
+	PositiveUnlockMaxRatio  float64 `json:"positive_unlock_max_ratio"`
+	ExtremeEta              float64 `json:"extreme_eta"`
+	ShockXi                 float64 `json:"shock_xi"`
 }
 
 type Engine struct {
@@ -46,6 +49,21 @@ type UpdateInput struct {
 	Now          time.Time
 	UserEmotion  domain.EmotionSignal
 	HasUserInput bool
+	// TimeScale slows the dynamics of this update, e.g. while the robot is
+	// asleep: 0.25 lets a quarter of the elapsed time act on the state.
+	// Zero or one is real time.
+	TimeScale float64
+}
+
+// GateSignal is the user emotion the mood and execution gate react to. An
+// ambiguous reading, such as a sarcastic line that scores joy and anger
+// alike, counts as neutral, so one sentence cannot swing the mood; prompts,
+// traces and replays still carry the reading itself.
+func GateSignal(user domain.EmotionSignal) domain.EmotionSignal {
+	if !user.Ambiguous {
+		return user
+	}
+	return domain.EmotionSignal{Emotion: "neutral", A: 0.05, Confidence: user.Confidence}
 }
 
 type UpdateResult struct {
@@ -84,6 +102,26 @@ func DefaultConfig() Config {
 	}
 }
 
+// LoadConfigFile reads a JSON Config, such as the one soul-calibrate
+// writes, over DefaultConfig; fields the file leaves out keep their
+// defaults. An empty path returns DefaultConfig.
+func LoadConfigFile(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if strings.TrimSpace(path) == "" {
+		return cfg, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("persona config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
 func NewEngine(cfg Config) *Engine {
 	if cfg.IdleAfterSeconds <= 0 {
 		cfg = DefaultConfig()
@@ -220,6 +258,9 @@ func (e *Engine) Update(base domain.PersonalityVector, prev domain.SoulEmotionSt
 	if dt > 7200 {
 		dt = 7200
 	}
+	if in.TimeScale > 0 && in.TimeScale < 1 {
+		dt *= in.TimeScale
+	}
 
 	eff := e.EffectiveVector(base, prev.Drift)
 	updated := prev
@@ -1,6 +1,7 @@
 package persona
 
 import (
+	"bytes"
 	"math"
 	"testing"
 	"time"
@@ -75,6 +76,40 @@ func TestLowEmotionInputHasMinimalImpact(t *testing.T) {
 	}
 }
 
+func TestAmbiguousEmotionDoesNotMoveMood(t *testing.T) {
+	engine := NewEngine(DefaultConfig())
+	base, err := VectorFromMBTI("INFJ")
+	if err != nil {
+		t.Fatalf("vector generation failed: %v", err)
+	}
+	now := time.Now().UTC()
+	state := InitialEmotionState(now)
+	sarcastic := domain.EmotionSignal{
+		Emotion:   "anger",
+		P:         -0.6,
+		A:         0.75,
+		D:         0.25,
+		Intensity: 0.9,
+		Candidates: []domain.EmotionCandidate{
+			{Emotion: "anger", Score: 0.48},
+			{Emotion: "joy", Score: 0.44},
+		},
+		Ambiguous: true,
+	}
+	update := func(sig domain.EmotionSignal) domain.SoulEmotionState {
+		return engine.Update(base, state, UpdateInput{Now: now, HasUserInput: true, UserEmotion: GateSignal(sig)}, 0.95).State
+	}
+
+	neutral := update(domain.EmotionSignal{Emotion: "neutral", A: 0.05})
+	if d := padDeltaNorm(update(sarcastic), neutral); d > 1e-9 {
+		t.Fatalf("ambiguous reading moved the mood by %.6f", d)
+	}
+	sarcastic.Ambiguous = false
+	if d := padDeltaNorm(update(sarcastic), neutral); d <= 0 {
+		t.Fatal("a clear reading should move the mood")
+	}
+}
+
 func TestExecutionGateBlocksOnlyWhenLockActive(t *testing.T) {
 	engine := NewEngine(DefaultConfig())
 	now := time.Now().UTC()
@@ -105,6 +140,45 @@ func TestExecutionGateBlocksOnlyWhenLockActive(t *testing.T) {
 	}
 }
 
+func TestReplayRecordedTraces(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewTraceRecorder(&buf)
+	start := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		at := start.Add(time.Duration(i) * 5 * time.Second)
+		if err := rec.Record("angry", at, domain.EmotionSignal{Emotion: "anger", P: -0.9, A: 0.9, D: 0.6, Intensity: 1, Confidence: 0.9}); err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.Record("calm", at, domain.EmotionSignal{Emotion: "neutral", A: 0.05, Intensity: 0.1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	traces, err := ReadTraces(&buf)
+	if err != nil {
+		t.Fatalf("read traces: %v", err)
+	}
+	if len(traces) != 2 || traces[0][0].Trace != "angry" || len(traces[0]) != 10 {
+		t.Fatalf("unexpected traces: %+v", traces)
+	}
+
+	engine := NewEngine(DefaultConfig())
+	base, _ := VectorFromMBTI("INFJ")
+	angry := engine.Replay(base, traces[0], 3*time.Second, time.Minute)
+	if angry.Total != 105*time.Second {
+		t.Fatalf("total = %s, want 1m45s", angry.Total)
+	}
+	if angry.Locks == 0 || angry.BlockedShare() <= 0 || angry.Blocked > angry.Total {
+		t.Fatalf("angry trace must lock the gate: %+v", angry)
+	}
+	if calm := engine.Replay(base, traces[1], 3*time.Second, time.Minute); calm.Locks != 0 || calm.Blocked != 0 {
+		t.Fatalf("calm trace must not lock the gate: %+v", calm)
+	}
+
+	if _, err := ReadTraces(bytes.NewBufferString(`{"trace":"x"}`)); err == nil {
+		t.Fatal("a point without time must be rejected")
+	}
+}
+
 func padDeltaNorm(a, b domain.SoulEmotionState) float64 {
 	dp := a.P - b.P
 	da := a.A - b.A
@@ -118,3 +192,85 @@ func assertNear(t *testing.T, got, want float64) {
 		t.Fatalf("value mismatch: got=%.6f want=%.6f", got, want)
 	}
 }
+
+func TestDescribeLockChange(t *testing.T) {
+	now := time.Now().UTC()
+	at := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339Nano) }
+	cases := []struct {
+		name       string
+		prev, next domain.SoulEmotionState
+		wantEvent  string
+		wantReason string
+		wantSecs   int
+	}{
+		{"locked", domain.SoulEmotionState{}, domain.SoulEmotionState{LockUntil: at(120 * time.Second)}, LockEventLocked, LockReasonExtremeEmotion, 120},
+		{"extended by shock", domain.SoulEmotionState{LockUntil: at(30 * time.Second)}, domain.SoulEmotionState{LockUntil: at(90 * time.Second), ShockLoad: 0.95}, LockEventExtended, LockReasonShockOverload, 90},
+		{"shortened", domain.SoulEmotionState{LockUntil: at(90 * time.Second)}, domain.SoulEmotionState{LockUntil: at(40 * time.Second)}, LockEventShortened, LockReasonSoothed, 40},
+		{"soothed away", domain.SoulEmotionState{LockUntil: at(20 * time.Second)}, domain.SoulEmotionState{}, LockEventUnlocked, LockReasonSoothed, 0},
+		{"expired", domain.SoulEmotionState{LockUntil: at(-time.Second)}, domain.SoulEmotionState{}, LockEventUnlocked, LockReasonExpired, 0},
+	}
+	for _, tc := range cases {
+		got, ok := DescribeLockChange(tc.prev, tc.next, now)
+		if !ok || got.Event != tc.wantEvent || got.Reason != tc.wantReason || got.RemainingSeconds() != tc.wantSecs {
+			t.Errorf("%s: got %+v (ok=%v, %ds), want %s/%s %ds", tc.name, got, ok, got.RemainingSeconds(), tc.wantEvent, tc.wantReason, tc.wantSecs)
+		}
+	}
+	same := domain.SoulEmotionState{LockUntil: at(60 * time.Second)}
+	if _, ok := DescribeLockChange(same, same, now); ok {
+		t.Error("an unchanged lock must not be reported")
+	}
+	if _, ok := DescribeLockChange(domain.SoulEmotionState{}, domain.SoulEmotionState{}, now); ok {
+		t.Error("no lock must not be reported")
+	}
+}
+
+func TestContagionPassesDampedShock(t *testing.T) {
+	engine := NewEngine(DefaultConfig())
+	base, err := VectorFromMBTI("INFJ")
+	if err != nil {
+		t.Fatalf("vector generation failed: %v", err)
+	}
+	now := time.Now().UTC()
+	prev := InitialEmotionState(now)
+	next := engine.Update(base, prev, UpdateInput{
+		Now:          now.Add(time.Second),
+		HasUserInput: true,
+		UserEmotion:  domain.EmotionSignal{Emotion: "anger", P: -0.8, A: 0.8, D: 0.4, Intensity: 0.9},
+	}, 0.95).State
+	if Shock(prev, next) < 0.3 {
+		t.Fatalf("a strong angry turn should be a big shock, got %.3f", Shock(prev, next))
+	}
+
+	sibling := InitialEmotionState(now)
+	caught := engine.Contagion(base, sibling, prev, next, 0.3)
+	if caught.P >= sibling.P || caught.P <= next.P {
+		t.Fatalf("sibling must sour by less than the source: source=%.3f sibling=%.3f", next.P, caught.P)
+	}
+	if caught.ShockLoad <= sibling.ShockLoad || caught.ShockLoad >= next.ShockLoad {
+		t.Fatalf("sibling shock load must rise by a share: source=%.3f sibling=%.3f", next.ShockLoad, caught.ShockLoad)
+	}
+	if caught.LockUntil != sibling.LockUntil || caught.Drift != sibling.Drift {
+		t.Fatalf("contagion must leave locks and drift alone")
+	}
+	if unchanged := engine.Contagion(base, sibling, prev, next, 0); unchanged != sibling {
+		t.Fatalf("share 0 must not change the sibling")
+	}
+}
+
+func TestTimeScaleSlowsIdleDynamics(t *testing.T) {
+	engine := NewEngine(DefaultConfig())
+	base, _ := VectorFromMBTI("INFJ")
+	start := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	state := InitialEmotionState(start)
+	state.LastInteractionAt = start.Add(-time.Hour).Format(time.RFC3339)
+
+	later := start.Add(10 * time.Minute)
+	awake := engine.Update(base, state, UpdateInput{Now: later}, 0.95)
+	asleep := engine.Update(base, state, UpdateInput{Now: later, TimeScale: 0.25}, 0.95)
+	if asleep.State.Boredom <= state.Boredom || asleep.State.Boredom >= awake.State.Boredom {
+		t.Fatalf("slowed boredom %.3f must grow, but less than %.3f", asleep.State.Boredom, awake.State.Boredom)
+	}
+	if asleep.State.LastUpdatedAt != awake.State.LastUpdatedAt {
+		t.Fatalf("time scale must not move the update clock, got %s and %s", asleep.State.LastUpdatedAt, awake.State.LastUpdatedAt)
+	}
+}
@@ -0,0 +1,74 @@
+package persona
+
+import (
+	"math"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// Lock change events, reported when an update moves the emotion lock.
+const (
+	LockEventLocked    = "locked"
+	LockEventExtended  = "extended"
+	LockEventShortened = "shortened"
+	LockEventUnlocked  = "unlocked"
+)
+
+// Lock change reasons.
+const (
+	LockReasonExtremeEmotion = "extreme_emotion"
+	LockReasonShockOverload  = "shock_overload"
+	LockReasonSoothed        = "soothed"
+	LockReasonExpired        = "expired"
+)
+
+// LockChange describes how an update moved the emotion lock.
+type LockChange struct {
+	Event     string
+	Reason    string
+	LockUntil time.Time
+	// Remaining is the lock time left after the update, 0 once unlocked.
+	Remaining time.Duration
+}
+
+// DescribeLockChange compares the lock before and after an update at now;
+// ok is false when the lock did not move.
+func DescribeLockChange(prev, next domain.SoulEmotionState, now time.Time) (LockChange, bool) {
+	prevUntil := parseOptionalTime(prev.LockUntil)
+	nextUntil := parseOptionalTime(next.LockUntil)
+	prevLocked := !prevUntil.IsZero() && prevUntil.After(now)
+	nextLocked := !nextUntil.IsZero() && nextUntil.After(now)
+
+	// The lock only starts or grows on a negative threshold trigger; shock
+	// load at its cap means repeated jolts rather than one extreme emotion.
+	triggerReason := LockReasonExtremeEmotion
+	if next.ShockLoad >= 0.9 {
+		triggerReason = LockReasonShockOverload
+	}
+	change := LockChange{LockUntil: nextUntil}
+	if nextLocked {
+		change.Remaining = nextUntil.Sub(now)
+	}
+	switch {
+	case nextLocked && !prevLocked:
+		change.Event, change.Reason = LockEventLocked, triggerReason
+	case nextLocked && nextUntil.After(prevUntil):
+		change.Event, change.Reason = LockEventExtended, triggerReason
+	case nextLocked && nextUntil.Before(prevUntil):
+		change.Event, change.Reason = LockEventShortened, LockReasonSoothed
+	case !nextLocked && prevLocked:
+		change.Event, change.Reason = LockEventUnlocked, LockReasonSoothed
+	case !nextLocked && !prevUntil.IsZero():
+		change.Event, change.Reason = LockEventUnlocked, LockReasonExpired
+	default:
+		return LockChange{}, false
+	}
+	return change, true
+}
+
+// RemainingSeconds rounds the remaining lock time up, so a lock never shows
+// 0 seconds while it still holds.
+func (c LockChange) RemainingSeconds() int {
+	return int(math.Ceil(c.Remaining.Seconds()))
+}
@@ -0,0 +1,151 @@
+package persona
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// TracePoint is one analysed user emotion of a recorded conversation. A
+// trace file holds one JSON object per line; Trace groups the points of one
+// conversation (soul-server writes the session ID).
+type TracePoint struct {
+	Trace string    `json:"trace"`
+	At    time.Time `json:"at"`
+	domain.EmotionSignal
+}
+
+// TraceRecorder appends user emotions to a trace file for offline
+// calibration. It is safe for concurrent use.
+type TraceRecorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewTraceRecorder(w io.Writer) *TraceRecorder {
+	return &TraceRecorder{w: w}
+}
+
+func (r *TraceRecorder) Record(trace string, at time.Time, sig domain.EmotionSignal) error {
+	line, err := json.Marshal(TracePoint{Trace: trace, At: at.UTC(), EmotionSignal: sig})
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(append(line, '\n'))
+	return err
+}
+
+// ReadTraces parses a trace file and returns its traces in order of first
+// appearance, each sorted by time. Blank lines are skipped.
+func ReadTraces(r io.Reader) ([][]TracePoint, error) {
+	var (
+		order  []string
+		traces = map[string][]TracePoint{}
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var p TracePoint
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			return nil, fmt.Errorf("trace line %d: %w", n, err)
+		}
+		if p.At.IsZero() {
+			return nil, fmt.Errorf("trace line %d: at is required", n)
+		}
+		if _, ok := traces[p.Trace]; !ok {
+			order = append(order, p.Trace)
+		}
+		traces[p.Trace] = append(traces[p.Trace], p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	out := make([][]TracePoint, 0, len(order))
+	for _, id := range order {
+		points := traces[id]
+		sort.SliceStable(points, func(i, j int) bool { return points[i].At.Before(points[j].At) })
+		out = append(out, points)
+	}
+	return out, nil
+}
+
+// ReplayResult is how a replayed trace went through the execution gate.
+type ReplayResult struct {
+	Total   time.Duration
+	Blocked time.Duration
+	// Locks counts how often the gate went from open to blocked.
+	Locks int
+}
+
+// BlockedShare is the fraction of the replay the gate was blocked.
+func (r ReplayResult) BlockedShare() float64 {
+	if r.Total <= 0 {
+		return 0
+	}
+	return r.Blocked.Seconds() / r.Total.Seconds()
+}
+
+// Replay runs a trace through the engine for a soul with personality base,
+// starting from a fresh emotion state. Every point is a user turn; between
+// turns the soul decays every tick like soul-server's emotion_decay ticks,
+// and the replay goes on for tail after the last point so a late lock is
+// counted in full.
+func (e *Engine) Replay(base domain.PersonalityVector, points []TracePoint, tick, tail time.Duration) ReplayResult {
+	var res ReplayResult
+	if len(points) == 0 {
+		return res
+	}
+	if tick <= 0 {
+		tick = 3 * time.Second
+	}
+	start := points[0].At.UTC()
+	end := points[len(points)-1].At.UTC().Add(max(tail, 0))
+	state := InitialEmotionState(start)
+	now := start
+
+	step := func(to time.Time, in UpdateInput) {
+		if lockUntil := parseOptionalTime(state.LockUntil); lockUntil.After(now) {
+			if lockUntil.After(to) {
+				lockUntil = to
+			}
+			res.Blocked += lockUntil.Sub(now)
+		}
+		in.Now = to
+		next := e.Update(base, state, in, 1).State
+		if change, ok := DescribeLockChange(state, next, to); ok && change.Event == LockEventLocked {
+			res.Locks++
+		}
+		state, now = next, to
+	}
+	decayUntil := func(until time.Time) {
+		for now.Add(tick).Before(until) {
+			step(now.Add(tick), UpdateInput{UserEmotion: domain.EmotionSignal{Emotion: "neutral", Confidence: 1}})
+		}
+	}
+
+	for _, p := range points {
+		at := p.At.UTC()
+		if at.Before(now) {
+			at = now
+		}
+		decayUntil(at)
+		step(at, UpdateInput{UserEmotion: GateSignal(p.EmotionSignal), HasUserInput: true})
+	}
+	decayUntil(end)
+	step(end, UpdateInput{UserEmotion: domain.EmotionSignal{Emotion: "neutral", Confidence: 1}})
+	res.Total = end.Sub(start)
+	return res
+}
@@ -0,0 +1,288 @@
+// Package power decides when terminals sleep and wake: after a stretch
+// without activity, or inside a nightly schedule. While a terminal sleeps
+// the hub holds back its emotion updates and the persona engine runs its
+// idle dynamics slower.
+package power
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"soul/internal/domain"
+	"soul/internal/quiethours"
+	"soul/internal/skills"
+)
+
+// ErrInvalidCommand wraps the reasons Set rejects a power command.
+var ErrInvalidCommand = errors.New("invalid power command")
+
+// scheduleGrace is how long a terminal woken inside the sleep schedule
+// stays up without activity before it goes back to sleep.
+const scheduleGrace = 5 * time.Minute
+
+type Config struct {
+	// IdleAfter puts a terminal to sleep after this long without activity;
+	// zero disables idle sleep.
+	IdleAfter time.Duration
+	// ScheduleStart and ScheduleEnd, HH:MM local time, bound the window in
+	// which terminals sleep; the window crosses midnight when End is
+	// earlier. Empty disables the schedule.
+	ScheduleStart string
+	ScheduleEnd   string
+	// DecayScale is the rate, between 0 and 1, at which the persona
+	// engine's dynamics run while a terminal sleeps.
+	DecayScale float64
+}
+
+// Publisher sends a power command to a terminal; mqtt.Hub does it.
+type Publisher interface {
+	PublishPower(ctx context.Context, terminalID string, cmd domain.PowerCommand) error
+}
+
+// Terminals lists the terminals online now; skills.Registry does it.
+type Terminals interface {
+	ListOnlineStates() []skills.TerminalSkillState
+}
+
+type terminalPower struct {
+	asleep       bool
+	reason       string
+	since        time.Time
+	lastActivity time.Time
+}
+
+// Manager keeps each terminal's power state in memory; after a restart
+// every terminal counts as awake and active.
+type Manager struct {
+	cfg       Config
+	publisher Publisher
+	terminals Terminals
+	logger    *slog.Logger
+	now       func() time.Time
+
+	mu     sync.Mutex
+	states map[string]*terminalPower
+}
+
+func NewManager(cfg Config, publisher Publisher, terminals Terminals, logger *slog.Logger) (*Manager, error) {
+	cfg.ScheduleStart = strings.TrimSpace(cfg.ScheduleStart)
+	cfg.ScheduleEnd = strings.TrimSpace(cfg.ScheduleEnd)
+	if (cfg.ScheduleStart == "") != (cfg.ScheduleEnd == "") {
+		return nil, errors.New("power schedule needs both start and end")
+	}
+	if cfg.ScheduleStart != "" {
+		for _, v := range []string{cfg.ScheduleStart, cfg.ScheduleEnd} {
+			if _, err := time.Parse("15:04", v); err != nil {
+				return nil, fmt.Errorf("power schedule times must be HH:MM, got %q", v)
+			}
+		}
+	}
+	if cfg.DecayScale <= 0 || cfg.DecayScale > 1 {
+		cfg.DecayScale = 1
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Manager{
+		cfg:       cfg,
+		publisher: publisher,
+		terminals: terminals,
+		logger:    logger,
+		now:       time.Now,
+		states:    make(map[string]*terminalPower),
+	}, nil
+}
+
+// get returns the terminal's state, creating it as awake and just active;
+// callers hold mu.
+func (m *Manager) get(terminalID string, now time.Time) *terminalPower {
+	st, ok := m.states[terminalID]
+	if !ok {
+		st = &terminalPower{lastActivity: now}
+		m.states[terminalID] = st
+	}
+	return st
+}
+
+// Touch records activity on the terminal (a chat turn, someone arriving)
+// and wakes it when it sleeps.
+func (m *Manager) Touch(ctx context.Context, terminalID string) {
+	if m == nil || terminalID == "" {
+		return
+	}
+	now := m.now()
+	m.mu.Lock()
+	st := m.get(terminalID, now)
+	st.lastActivity = now
+	wake := st.asleep
+	m.mu.Unlock()
+	if wake {
+		_ = m.send(ctx, terminalID, false, domain.PowerReasonActivity)
+	}
+}
+
+// DecayScale is the rate the terminal's persona dynamics run at: the
+// configured scale while it sleeps, else 1.
+func (m *Manager) DecayScale(terminalID string) float64 {
+	if m == nil {
+		return 1
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st, ok := m.states[terminalID]; ok && st.asleep {
+		return m.cfg.DecayScale
+	}
+	return 1
+}
+
+// State returns what the manager knows about the terminal.
+func (m *Manager) State(terminalID string) domain.TerminalPowerState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := domain.TerminalPowerState{TerminalID: terminalID}
+	st, ok := m.states[terminalID]
+	if !ok {
+		return out
+	}
+	out.Asleep = st.asleep
+	out.Reason = st.reason
+	if !st.since.IsZero() {
+		out.Since = st.since.UTC().Format(time.RFC3339)
+	}
+	out.LastActivityAt = st.lastActivity.UTC().Format(time.RFC3339)
+	return out
+}
+
+// Set sends a manual sleep or wake. A manual sleep lasts until activity or
+// a manual wake; the schedule does not end it.
+func (m *Manager) Set(ctx context.Context, terminalID, command string) (domain.TerminalPowerState, error) {
+	terminalID = strings.TrimSpace(terminalID)
+	if terminalID == "" {
+		return domain.TerminalPowerState{}, fmt.Errorf("%w: terminal_id is required", ErrInvalidCommand)
+	}
+	var asleep bool
+	switch strings.TrimSpace(command) {
+	case domain.PowerCommandSleep:
+		asleep = true
+	case domain.PowerCommandWake:
+	default:
+		return domain.TerminalPowerState{}, fmt.Errorf("%w: command must be sleep or wake", ErrInvalidCommand)
+	}
+	if err := m.send(ctx, terminalID, asleep, domain.PowerReasonManual); err != nil {
+		return domain.TerminalPowerState{}, err
+	}
+	return m.State(terminalID), nil
+}
+
+// HandleReconnect puts a terminal that rebooted back to sleep when the
+// server still has it asleep; it is meant for the MQTT hub's reconnect
+// hook.
+func (m *Manager) HandleReconnect(terminalID string) {
+	m.mu.Lock()
+	st, ok := m.states[terminalID]
+	asleep := ok && st.asleep
+	reason := ""
+	if asleep {
+		reason = st.reason
+	}
+	m.mu.Unlock()
+	if !asleep {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = m.send(ctx, terminalID, true, reason)
+}
+
+// Run applies the idle and schedule policy to the online terminals every
+// interval until ctx is done.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	if m.cfg.IdleAfter <= 0 && m.cfg.ScheduleStart == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	m.logger.Info("power policy started", "idle_after", m.cfg.IdleAfter, "schedule_start", m.cfg.ScheduleStart, "schedule_end", m.cfg.ScheduleEnd)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check puts online terminals that have been idle long enough to sleep and
+// wakes the ones the schedule put to sleep once it ends. Inside the
+// schedule a terminal sleeps after scheduleGrace without activity.
+func (m *Manager) Check(ctx context.Context) {
+	now := m.now()
+	inSchedule := m.inSchedule(now)
+	for _, terminal := range m.terminals.ListOnlineStates() {
+		if ctx.Err() != nil {
+			return
+		}
+		terminalID := strings.TrimSpace(terminal.TerminalID)
+		m.mu.Lock()
+		st := m.get(terminalID, now)
+		idle := now.Sub(st.lastActivity)
+		asleep, reason := st.asleep, st.reason
+		m.mu.Unlock()
+
+		switch {
+		case !asleep && inSchedule && idle >= scheduleGrace:
+			_ = m.send(ctx, terminalID, true, domain.PowerReasonSchedule)
+		case !asleep && m.cfg.IdleAfter > 0 && idle >= m.cfg.IdleAfter:
+			_ = m.send(ctx, terminalID, true, domain.PowerReasonIdle)
+		case asleep && reason == domain.PowerReasonSchedule && !inSchedule:
+			_ = m.send(ctx, terminalID, false, domain.PowerReasonSchedule)
+		}
+	}
+}
+
+func (m *Manager) inSchedule(now time.Time) bool {
+	if m.cfg.ScheduleStart == "" {
+		return false
+	}
+	_, ok := quiethours.ActiveUntil(m.cfg.ScheduleStart, m.cfg.ScheduleEnd, now)
+	return ok
+}
+
+// send publishes the command and records the new state once it went out.
+// A wake restarts the idle clock.
+func (m *Manager) send(ctx context.Context, terminalID string, asleep bool, reason string) error {
+	now := m.now()
+	cmd := domain.PowerCommand{
+		RequestID:  uuid.NewString(),
+		TerminalID: terminalID,
+		Command:    domain.PowerCommandWake,
+		Reason:     reason,
+		TS:         now.UTC().Format(time.RFC3339),
+	}
+	if asleep {
+		cmd.Command = domain.PowerCommandSleep
+	}
+	if err := m.publisher.PublishPower(ctx, terminalID, cmd); err != nil {
+		m.logger.Warn("publish power command failed", "terminal_id", terminalID, "command", cmd.Command, "reason", reason, "error", err)
+		return err
+	}
+	m.mu.Lock()
+	st := m.get(terminalID, now)
+	st.asleep = asleep
+	st.reason = reason
+	st.since = now
+	if !asleep {
+		st.lastActivity = now
+	}
+	m.mu.Unlock()
+	m.logger.Info("terminal power changed", "terminal_id", terminalID, "command", cmd.Command, "reason", reason)
+	return nil
+}
@@ -0,0 +1,110 @@
+package power
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/skills"
+)
+
+type recordingPublisher struct {
+	down bool
+	sent []string
+}
+
+func (p *recordingPublisher) PublishPower(_ context.Context, terminalID string, cmd domain.PowerCommand) error {
+	if p.down {
+		return errors.New("mqtt client is not started")
+	}
+	p.sent = append(p.sent, terminalID+" "+cmd.Command+" "+cmd.Reason)
+	return nil
+}
+
+type onlineTerminals []string
+
+func (o onlineTerminals) ListOnlineStates() []skills.TerminalSkillState {
+	out := make([]skills.TerminalSkillState, 0, len(o))
+	for _, id := range o {
+		out = append(out, skills.TerminalSkillState{TerminalID: id, Online: true})
+	}
+	return out
+}
+
+func TestIdleAndScheduleDrivePowerState(t *testing.T) {
+	pub := &recordingPublisher{}
+	m, err := NewManager(Config{IdleAfter: 30 * time.Minute, ScheduleStart: "23:00", ScheduleEnd: "07:00", DecayScale: 0.25},
+		pub, onlineTerminals{"t1"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	now := time.Date(2026, 10, 16, 20, 0, 0, 0, time.Local)
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	m.Check(ctx)
+	now = now.Add(29 * time.Minute)
+	m.Touch(ctx, "t1")
+	now = now.Add(29 * time.Minute)
+	m.Check(ctx)
+	if len(pub.sent) != 0 || m.DecayScale("t1") != 1 {
+		t.Fatalf("active terminal must stay awake, sent %v", pub.sent)
+	}
+	now = now.Add(2 * time.Minute)
+	m.Check(ctx)
+	if len(pub.sent) != 1 || pub.sent[0] != "t1 sleep idle" || m.DecayScale("t1") != 0.25 {
+		t.Fatalf("idle terminal must sleep, sent %v", pub.sent)
+	}
+	m.Touch(ctx, "t1")
+	if len(pub.sent) != 2 || pub.sent[1] != "t1 wake activity" || m.State("t1").Asleep {
+		t.Fatalf("activity must wake the terminal, sent %v", pub.sent)
+	}
+
+	now = time.Date(2026, 10, 16, 23, 6, 0, 0, time.Local)
+	m.Touch(ctx, "t1")
+	now = now.Add(scheduleGrace)
+	m.Check(ctx)
+	if len(pub.sent) != 3 || pub.sent[2] != "t1 sleep schedule" {
+		t.Fatalf("schedule must put the terminal to sleep, sent %v", pub.sent)
+	}
+	now = time.Date(2026, 10, 17, 7, 0, 0, 0, time.Local)
+	m.Check(ctx)
+	if len(pub.sent) != 4 || pub.sent[3] != "t1 wake schedule" {
+		t.Fatalf("end of schedule must wake the terminal, sent %v", pub.sent)
+	}
+	m.Check(ctx)
+	if len(pub.sent) != 4 {
+		t.Fatalf("schedule wake must restart the idle clock, sent %v", pub.sent)
+	}
+}
+
+func TestManualSleepSurvivesReconnect(t *testing.T) {
+	pub := &recordingPublisher{}
+	m, _ := NewManager(Config{}, pub, onlineTerminals{"t1"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+
+	if _, err := m.Set(ctx, "t1", "nap"); !errors.Is(err, ErrInvalidCommand) {
+		t.Fatalf("unknown command must be rejected, got %v", err)
+	}
+	pub.down = true
+	if _, err := m.Set(ctx, "t1", domain.PowerCommandSleep); err == nil || m.State("t1").Asleep {
+		t.Fatalf("failed publish must leave the terminal awake, got %v", err)
+	}
+	pub.down = false
+	state, err := m.Set(ctx, "t1", domain.PowerCommandSleep)
+	if err != nil || !state.Asleep || state.Reason != domain.PowerReasonManual {
+		t.Fatalf("manual sleep: %+v %v", state, err)
+	}
+	m.HandleReconnect("t1")
+	if len(pub.sent) != 2 || pub.sent[1] != "t1 sleep manual" {
+		t.Fatalf("rebooted terminal must be put back to sleep, sent %v", pub.sent)
+	}
+	m.HandleReconnect("t2")
+	if len(pub.sent) != 2 {
+		t.Fatalf("awake terminal must not be touched on reconnect, sent %v", pub.sent)
+	}
+}
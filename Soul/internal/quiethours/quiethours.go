@@ -0,0 +1,213 @@
+// Package quiethours keeps each user's do-not-disturb windows. While a
+// window is active the reminder dispatcher holds due reminders and alarms
+// until it ends, the orchestrator only runs the allowed skills, and chat
+// replies are marked to be shown rather than spoken.
+package quiethours
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// ErrInvalidRule wraps the reasons Set rejects a rule.
+var ErrInvalidRule = errors.New("invalid quiet hours")
+
+type Store interface {
+	ListQuietHours(ctx context.Context) ([]domain.QuietHours, error)
+	UpsertQuietHours(ctx context.Context, q domain.QuietHours) (domain.QuietHours, error)
+	DeleteQuietHours(ctx context.Context, userID, terminalID string) error
+}
+
+// Window is a quiet hours rule in effect and the time it ends.
+type Window struct {
+	Rule  domain.QuietHours
+	Until time.Time
+}
+
+type ruleKey struct {
+	userID     string
+	terminalID string
+}
+
+// Policy caches the stored rules so the checks on every chat turn and
+// reminder scan stay off the database. A nil Policy has no quiet hours.
+type Policy struct {
+	store   Store
+	allowed map[string]struct{}
+
+	mu    sync.RWMutex
+	rules map[ruleKey]domain.QuietHours
+}
+
+// NewPolicy builds a Policy; allowedSkills still run during quiet hours.
+func NewPolicy(store Store, allowedSkills []string) *Policy {
+	allowed := make(map[string]struct{}, len(allowedSkills))
+	for _, name := range allowedSkills {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = struct{}{}
+		}
+	}
+	return &Policy{store: store, allowed: allowed, rules: make(map[ruleKey]domain.QuietHours)}
+}
+
+// Load replaces the cached rules with the stored ones.
+func (p *Policy) Load(ctx context.Context) error {
+	items, err := p.store.ListQuietHours(ctx)
+	if err != nil {
+		return err
+	}
+	rules := make(map[ruleKey]domain.QuietHours, len(items))
+	for _, item := range items {
+		rules[ruleKey{item.UserID, item.TerminalID}] = item
+	}
+	p.mu.Lock()
+	p.rules = rules
+	p.mu.Unlock()
+	return nil
+}
+
+// List returns the user's rules, the user-wide one first.
+func (p *Policy) List(userID string) []domain.QuietHours {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]domain.QuietHours, 0, 2)
+	for key, rule := range p.rules {
+		if key.userID == userID {
+			out = append(out, rule)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TerminalID < out[j].TerminalID })
+	return out
+}
+
+// Set validates and stores a rule, replacing the one for the same user and
+// terminal.
+func (p *Policy) Set(ctx context.Context, q domain.QuietHours) (domain.QuietHours, error) {
+	q.UserID = strings.TrimSpace(q.UserID)
+	q.TerminalID = strings.TrimSpace(q.TerminalID)
+	if q.UserID == "" {
+		return domain.QuietHours{}, fmt.Errorf("%w: user_id is required", ErrInvalidRule)
+	}
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return domain.QuietHours{}, fmt.Errorf("%w: start: %v", ErrInvalidRule, err)
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return domain.QuietHours{}, fmt.Errorf("%w: end: %v", ErrInvalidRule, err)
+	}
+	if start == end {
+		return domain.QuietHours{}, fmt.Errorf("%w: start and end must differ", ErrInvalidRule)
+	}
+	q.Start, q.End = start.Format("15:04"), end.Format("15:04")
+	stored, err := p.store.UpsertQuietHours(ctx, q)
+	if err != nil {
+		return domain.QuietHours{}, err
+	}
+	p.mu.Lock()
+	p.rules[ruleKey{stored.UserID, stored.TerminalID}] = stored
+	p.mu.Unlock()
+	return stored, nil
+}
+
+func (p *Policy) Delete(ctx context.Context, userID, terminalID string) error {
+	if err := p.store.DeleteQuietHours(ctx, userID, terminalID); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	delete(p.rules, ruleKey{userID, terminalID})
+	p.mu.Unlock()
+	return nil
+}
+
+// Active returns the window in effect for the user on the terminal at now.
+// A rule for the terminal takes precedence over the user-wide rule, even
+// when it is disabled, so one terminal can be exempted.
+func (p *Policy) Active(userID, terminalID string, now time.Time) (Window, bool) {
+	if p == nil {
+		return Window{}, false
+	}
+	p.mu.RLock()
+	rule, ok := p.rules[ruleKey{userID, terminalID}]
+	if !ok {
+		rule, ok = p.rules[ruleKey{userID, ""}]
+	}
+	p.mu.RUnlock()
+	if !ok || !rule.Enabled {
+		return Window{}, false
+	}
+	until, active := ActiveUntil(rule.Start, rule.End, now)
+	if !active {
+		return Window{}, false
+	}
+	return Window{Rule: rule, Until: until}, true
+}
+
+// AllowsSkill reports whether skill may run during quiet hours.
+func (p *Policy) AllowsSkill(skill string) bool {
+	if p == nil {
+		return true
+	}
+	_, ok := p.allowed[skill]
+	return ok
+}
+
+// AllowedSkills lists the skills that run during quiet hours, sorted.
+func (p *Policy) AllowedSkills() []string {
+	if p == nil {
+		return nil
+	}
+	out := make([]string, 0, len(p.allowed))
+	for name := range p.allowed {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ActiveUntil reports whether now (in server local time) falls in the
+// window from start to end, which crosses midnight when end is earlier than
+// start, and when that window ends.
+func ActiveUntil(start, end string, now time.Time) (time.Time, bool) {
+	s, err := parseClock(start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return time.Time{}, false
+	}
+	local := now.In(time.Local)
+	at := func(c time.Time, days int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+days, c.Hour(), c.Minute(), 0, 0, time.Local)
+	}
+	startToday, endToday := at(s, 0), at(e, 0)
+	if startToday.Before(endToday) {
+		if !local.Before(startToday) && local.Before(endToday) {
+			return endToday, true
+		}
+		return time.Time{}, false
+	}
+	switch {
+	case !local.Before(startToday):
+		return at(e, 1), true
+	case local.Before(endToday):
+		return endToday, true
+	}
+	return time.Time{}, false
+}
+
+func parseClock(v string) (time.Time, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time must be HH:MM, got %q", v)
+	}
+	return t, nil
+}
@@ -0,0 +1,112 @@
+package quiethours
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+type memoryStore struct{ items map[ruleKey]domain.QuietHours }
+
+func (s *memoryStore) ListQuietHours(context.Context) ([]domain.QuietHours, error) {
+	out := make([]domain.QuietHours, 0, len(s.items))
+	for _, q := range s.items {
+		out = append(out, q)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) UpsertQuietHours(_ context.Context, q domain.QuietHours) (domain.QuietHours, error) {
+	if s.items == nil {
+		s.items = make(map[ruleKey]domain.QuietHours)
+	}
+	s.items[ruleKey{q.UserID, q.TerminalID}] = q
+	return q, nil
+}
+
+func (s *memoryStore) DeleteQuietHours(_ context.Context, userID, terminalID string) error {
+	delete(s.items, ruleKey{userID, terminalID})
+	return nil
+}
+
+func localTime(day, hour, minute int) time.Time {
+	return time.Date(2026, 10, day, hour, minute, 0, 0, time.Local)
+}
+
+func TestActiveAcrossMidnight(t *testing.T) {
+	policy := NewPolicy(&memoryStore{}, nil)
+	if _, err := policy.Set(context.Background(), domain.QuietHours{UserID: "u1", Start: "22:00", End: "7:00", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		now   time.Time
+		until time.Time
+		ok    bool
+	}{
+		{localTime(16, 21, 59), time.Time{}, false},
+		{localTime(16, 22, 0), localTime(17, 7, 0), true},
+		{localTime(17, 6, 30), localTime(17, 7, 0), true},
+		{localTime(17, 7, 0), time.Time{}, false},
+	}
+	for _, c := range cases {
+		w, ok := policy.Active("u1", "t1", c.now)
+		if ok != c.ok || !w.Until.Equal(c.until) {
+			t.Fatalf("at %v: got %v until %v", c.now, ok, w.Until)
+		}
+	}
+	if w, _ := policy.Active("u1", "t1", localTime(16, 23, 0)); w.Rule.End != "07:00" {
+		t.Fatalf("expected normalized end, got %q", w.Rule.End)
+	}
+}
+
+func TestTerminalRuleOverridesUserRule(t *testing.T) {
+	store := &memoryStore{}
+	policy := NewPolicy(store, []string{"create_alarm"})
+	ctx := context.Background()
+	if _, err := policy.Set(ctx, domain.QuietHours{UserID: "u1", Start: "13:00", End: "14:00", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.Set(ctx, domain.QuietHours{UserID: "u1", TerminalID: "kitchen", Start: "13:00", End: "14:00", Enabled: false}); err != nil {
+		t.Fatal(err)
+	}
+	now := localTime(16, 13, 30)
+	if _, ok := policy.Active("u1", "bedroom", now); !ok {
+		t.Fatalf("user-wide rule must apply to other terminals")
+	}
+	if _, ok := policy.Active("u1", "kitchen", now); ok {
+		t.Fatalf("disabled terminal rule must exempt the terminal")
+	}
+
+	reloaded := NewPolicy(store, nil)
+	if err := reloaded.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.List("u1"); len(got) != 2 || got[0].TerminalID != "" {
+		t.Fatalf("unexpected rules after load: %+v", got)
+	}
+	if err := policy.Delete(ctx, "u1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := policy.Active("u1", "bedroom", now); ok {
+		t.Fatalf("deleted rule must not apply")
+	}
+	if !policy.AllowsSkill("create_alarm") || policy.AllowsSkill("play_music") {
+		t.Fatalf("unexpected allowed skills %v", policy.AllowedSkills())
+	}
+}
+
+func TestSetRejectsInvalidRule(t *testing.T) {
+	policy := NewPolicy(&memoryStore{}, nil)
+	for _, q := range []domain.QuietHours{
+		{Start: "22:00", End: "07:00"},
+		{UserID: "u1", Start: "25:00", End: "07:00"},
+		{UserID: "u1", Start: "22:00", End: "22:00"},
+	} {
+		if _, err := policy.Set(context.Background(), q); !errors.Is(err, ErrInvalidRule) {
+			t.Fatalf("%+v: expected ErrInvalidRule, got %v", q, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+
+	"soul/internal/domain"
+	"soul/internal/llm"
+)
+
+type provider struct {
+	inner    llm.Provider
+	redactor *Redactor
+}
+
+// WrapProvider redacts every request sent through p and restores the
+// placeholders in its replies and tool-call arguments, so callers store and
+// execute the original values. Embedding inputs are masked. A nil redactor
+// returns p unchanged.
+func WrapProvider(p llm.Provider, r *Redactor) llm.Provider {
+	if r == nil {
+		return p
+	}
+	return &provider{inner: p, redactor: r}
+}
+
+func (p *provider) Complete(ctx context.Context, req domain.LLMRequest) (domain.LLMResponse, error) {
+	s := p.redactor.NewSession()
+	req.System = s.Redact(req.System)
+	messages := make([]domain.Message, len(req.Messages))
+	for i, m := range req.Messages {
+		m.Content = s.Redact(m.Content)
+		m.ToolCalls = mapToolCallArguments(m.ToolCalls, s.Redact)
+		messages[i] = m
+	}
+	req.Messages = messages
+
+	resp, err := p.inner.Complete(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Content = s.Restore(resp.Content)
+	resp.ToolCalls = mapToolCallArguments(resp.ToolCalls, s.Restore)
+	return resp, nil
+}
+
+func (p *provider) Embed(ctx context.Context, texts []string) (llm.Embeddings, error) {
+	masked := make([]string, len(texts))
+	for i, text := range texts {
+		masked[i] = p.redactor.Mask(text)
+	}
+	return p.inner.Embed(ctx, masked)
+}
+
+// mapToolCallArguments applies f to every string value in the calls'
+// JSON arguments, leaving keys and structure intact so the result stays
+// valid JSON whatever the original values contain.
+func mapToolCallArguments(calls []domain.ToolCall, f func(string) string) []domain.ToolCall {
+	if len(calls) == 0 {
+		return calls
+	}
+	out := make([]domain.ToolCall, len(calls))
+	for i, call := range calls {
+		var args any
+		if err := json.Unmarshal(call.Arguments, &args); err != nil {
+			// Not JSON, so plain text replacement cannot break it.
+			call.Arguments = json.RawMessage(f(string(call.Arguments)))
+		} else if raw, err := json.Marshal(mapStrings(args, f)); err == nil {
+			call.Arguments = raw
+		}
+		out[i] = call
+	}
+	return out
+}
+
+func mapStrings(v any, f func(string) string) any {
+	switch t := v.(type) {
+	case string:
+		return f(t)
+	case []any:
+		for i := range t {
+			t[i] = mapStrings(t[i], f)
+		}
+		return t
+	case map[string]any:
+		for k := range t {
+			t[k] = mapStrings(t[k], f)
+		}
+		return t
+	default:
+		return v
+	}
+}
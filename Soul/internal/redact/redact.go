@@ -0,0 +1,195 @@
+// Package redact masks personal data (phone numbers, ID card numbers,
+// street addresses and any configured patterns) in text that leaves the
+// deployment for the LLM provider or mem0. Messages, summaries and replies
+// stored locally keep the original text.
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// addressUnit is the building/unit/room tail an address may carry; the
+// room number may come without 室, as in "2单元301".
+const addressUnit = `(?:\d{1,5}(?:号楼|号院|栋|幢|单元|层|室|号))*(?:\d{1,5}室?)?`
+
+// builtinRules are the kinds REDACT_KINDS can select, applied in this
+// order: ID numbers go before phone numbers so a phone-like run inside an ID
+// is not masked on its own.
+var builtinRules = []struct {
+	kind    string
+	pattern string
+}{
+	{"id_number", `[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]`},
+	{"phone", `(?:\+?86[- ]?)?1[3-9]\d{9}|0\d{2,3}-\d{7,8}`},
+	{"address", `\p{Han}{1,6}(?:路|街|大道|巷|弄|胡同)\d{1,5}号` + addressUnit + `|\p{Han}{1,6}(?:小区|花园|公寓|大厦|家园|新村)\d{1,5}(?:号楼|号院|栋|幢|单元|号)` + addressUnit},
+}
+
+// BuiltinKinds lists the kinds New accepts, in the order they are applied.
+func BuiltinKinds() []string {
+	out := make([]string, 0, len(builtinRules))
+	for _, rule := range builtinRules {
+		out = append(out, rule.kind)
+	}
+	return out
+}
+
+// Rule masks every match of Pattern as Kind.
+type Rule struct {
+	Kind    string
+	Pattern *regexp.Regexp
+}
+
+// ParseRules reads extra rules written as "kind=regex;kind=regex".
+func ParseRules(spec string) ([]Rule, error) {
+	var out []Rule
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		kind, expr, ok := strings.Cut(entry, "=")
+		kind = strings.TrimSpace(kind)
+		if !ok || kind == "" || strings.TrimSpace(expr) == "" {
+			return nil, fmt.Errorf("redact rule %q must be kind=regex", entry)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(expr))
+		if err != nil {
+			return nil, fmt.Errorf("redact rule %s: %w", kind, err)
+		}
+		out = append(out, Rule{Kind: kind, Pattern: re})
+	}
+	return out, nil
+}
+
+// Redactor applies the builtin kinds it was built with, then the extra
+// rules. A nil Redactor leaves text unchanged.
+type Redactor struct {
+	rules []Rule
+}
+
+// New builds a Redactor from builtin kinds (see BuiltinKinds) and extra
+// rules.
+func New(kinds []string, extra []Rule) (*Redactor, error) {
+	wanted := make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			wanted[kind] = true
+		}
+	}
+	r := &Redactor{}
+	for _, rule := range builtinRules {
+		if wanted[rule.kind] {
+			r.rules = append(r.rules, Rule{Kind: rule.kind, Pattern: regexp.MustCompile(rule.pattern)})
+			delete(wanted, rule.kind)
+		}
+	}
+	for kind := range wanted {
+		return nil, fmt.Errorf("unknown redact kind %q (have %s)", kind, strings.Join(BuiltinKinds(), ", "))
+	}
+	r.rules = append(r.rules, extra...)
+	return r, nil
+}
+
+// Mask replaces personal data with its kind, e.g. "[PHONE]". It is for text
+// that is never read back, such as mem0 entries and queries.
+func (r *Redactor) Mask(text string) string {
+	if r == nil {
+		return text
+	}
+	return r.replace(text, func(kind, _ string) string {
+		return "[" + strings.ToUpper(kind) + "]"
+	})
+}
+
+// NewSession starts a reversible redaction for one exchange.
+func (r *Redactor) NewSession() *Session {
+	return &Session{r: r, tokens: make(map[string]string), originals: make(map[string]string), counts: make(map[string]int)}
+}
+
+func (r *Redactor) replace(text string, token func(kind, match string) string) string {
+	if text == "" {
+		return text
+	}
+	for _, rule := range r.rules {
+		locs := rule.Pattern.FindAllStringIndex(text, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		var sb strings.Builder
+		last := 0
+		for _, loc := range locs {
+			if loc[0] == loc[1] || insideNumber(text, loc[0], loc[1]) {
+				continue
+			}
+			sb.WriteString(text[last:loc[0]])
+			sb.WriteString(token(rule.Kind, text[loc[0]:loc[1]]))
+			last = loc[1]
+		}
+		sb.WriteString(text[last:])
+		text = sb.String()
+	}
+	return text
+}
+
+// insideNumber reports whether a match that starts or ends with a digit is
+// part of a longer digit run, like 11 digits out of a 16-digit order number.
+func insideNumber(text string, start, end int) bool {
+	first, _ := utf8.DecodeRuneInString(text[start:])
+	if isDigit(first) && start > 0 {
+		if prev, _ := utf8.DecodeLastRuneInString(text[:start]); isDigit(prev) {
+			return true
+		}
+	}
+	last, _ := utf8.DecodeLastRuneInString(text[:end])
+	if isDigit(last) && end < len(text) {
+		if next, _ := utf8.DecodeRuneInString(text[end:]); isDigit(next) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// Session replaces personal data with numbered placeholders such as
+// "[PHONE_1]" and puts the originals back into what the LLM returns. The same
+// value gets the same placeholder throughout the exchange, so the model can
+// still tell two numbers apart and refer to them in tool calls.
+type Session struct {
+	r         *Redactor
+	tokens    map[string]string // original -> placeholder
+	originals map[string]string // placeholder -> original
+	counts    map[string]int
+}
+
+func (s *Session) Redact(text string) string {
+	if s.r == nil {
+		return text
+	}
+	return s.r.replace(text, func(kind, match string) string {
+		if token, ok := s.tokens[match]; ok {
+			return token
+		}
+		s.counts[kind]++
+		token := fmt.Sprintf("[%s_%d]", strings.ToUpper(kind), s.counts[kind])
+		s.tokens[match] = token
+		s.originals[token] = match
+		return token
+	})
+}
+
+// Restore puts the originals back in place of the session's placeholders.
+func (s *Session) Restore(text string) string {
+	if len(s.originals) == 0 || !strings.Contains(text, "[") {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(s.originals))
+	for token, original := range s.originals {
+		pairs = append(pairs, token, original)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
@@ -0,0 +1,141 @@
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"soul/internal/domain"
+	"soul/internal/llm"
+)
+
+func newTestRedactor(t *testing.T, spec string) *Redactor {
+	t.Helper()
+	extra, err := ParseRules(spec)
+	if err != nil {
+		t.Fatalf("parse rules: %v", err)
+	}
+	r, err := New(BuiltinKinds(), extra)
+	if err != nil {
+		t.Fatalf("new redactor: %v", err)
+	}
+	return r
+}
+
+func TestMask(t *testing.T) {
+	r := newTestRedactor(t, "")
+	cases := []struct {
+		in, want string
+	}{
+		{"我的手机号是13812345678，记一下", "我的手机号是[PHONE]，记一下"},
+		{"座机 010-62345678", "座机 [PHONE]"},
+		{"身份证110105199001011234", "身份证[ID_NUMBER]"},
+		{"地址：建国路88号3单元502室", "地址：[ADDRESS]"},
+		{"幸福小区5号楼2单元301", "[ADDRESS]"},
+		{"订单号 2024138123456789", "订单号 2024138123456789"},
+		{"明天早上七点叫我", "明天早上七点叫我"},
+	}
+	for _, tc := range cases {
+		if got := r.Mask(tc.in); got != tc.want {
+			t.Errorf("Mask(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	r := newTestRedactor(t, "plate=京[A-Z][A-Z0-9]{5}")
+	s := r.NewSession()
+	in := "给13812345678和13900001111发消息，再给13812345678打电话，车牌京A12345"
+	redacted := s.Redact(in)
+	want := "给[PHONE_1]和[PHONE_2]发消息，再给[PHONE_1]打电话，车牌[PLATE_1]"
+	if redacted != want {
+		t.Fatalf("Redact = %q, want %q", redacted, want)
+	}
+	if got := s.Restore(redacted); got != in {
+		t.Fatalf("Restore = %q, want %q", got, in)
+	}
+}
+
+func TestNewRejectsUnknownKind(t *testing.T) {
+	if _, err := New([]string{"phone", "passport"}, nil); err == nil {
+		t.Fatal("expected an error for an unknown kind")
+	}
+	if _, err := ParseRules("plate"); err == nil {
+		t.Fatal("expected an error for a rule without a regex")
+	}
+	if _, err := ParseRules("plate=[A-"); err == nil {
+		t.Fatal("expected an error for an invalid regex")
+	}
+}
+
+type recordingProvider struct {
+	req  domain.LLMRequest
+	resp domain.LLMResponse
+}
+
+func (p *recordingProvider) Complete(_ context.Context, req domain.LLMRequest) (domain.LLMResponse, error) {
+	p.req = req
+	return p.resp, nil
+}
+
+func (p *recordingProvider) Embed(_ context.Context, texts []string) (llm.Embeddings, error) {
+	p.req = domain.LLMRequest{Messages: []domain.Message{{Content: strings.Join(texts, "|")}}}
+	return llm.Embeddings{}, nil
+}
+
+func TestProviderRedactsRequestAndRestoresReply(t *testing.T) {
+	inner := &recordingProvider{resp: domain.LLMResponse{
+		Content:   "好的，这就给[PHONE_1]发短信。",
+		ToolCalls: []domain.ToolCall{{ID: "c1", Name: "send_sms", Arguments: json.RawMessage(`{"to":"[PHONE_1]","text":"到了"}`)}},
+	}}
+	p := WrapProvider(inner, newTestRedactor(t, ""))
+
+	original := domain.LLMRequest{
+		System: "用户地址：建国路88号",
+		Messages: []domain.Message{
+			{Role: "user", Content: "给13812345678发短信说到了"},
+			{Role: "assistant", ToolCalls: []domain.ToolCall{{ID: "c0", Name: "lookup", Arguments: json.RawMessage(`{"phone":"13812345678"}`)}}},
+		},
+	}
+	resp, err := p.Complete(context.Background(), original)
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	sent := inner.req
+	if strings.Contains(sent.System, "88号") || sent.Messages[0].Content != "给[PHONE_1]发短信说到了" {
+		t.Fatalf("request not redacted: system=%q user=%q", sent.System, sent.Messages[0].Content)
+	}
+	if got := string(sent.Messages[1].ToolCalls[0].Arguments); got != `{"phone":"[PHONE_1]"}` {
+		t.Fatalf("tool call history not redacted: %s", got)
+	}
+	if original.Messages[0].Content != "给13812345678发短信说到了" {
+		t.Fatalf("caller's messages were modified: %q", original.Messages[0].Content)
+	}
+	if resp.Content != "好的，这就给13812345678发短信。" {
+		t.Fatalf("reply not restored: %q", resp.Content)
+	}
+	var args map[string]string
+	if err := json.Unmarshal(resp.ToolCalls[0].Arguments, &args); err != nil || args["to"] != "13812345678" {
+		t.Fatalf("tool call arguments not restored: %s (%v)", resp.ToolCalls[0].Arguments, err)
+	}
+
+	if _, err := p.Embed(context.Background(), []string{"电话13812345678"}); err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if got := inner.req.Messages[0].Content; got != "电话[PHONE]" {
+		t.Fatalf("embedding input not masked: %q", got)
+	}
+}
+
+func TestNilRedactorIsNoop(t *testing.T) {
+	var r *Redactor
+	if got := r.Mask("13812345678"); got != "13812345678" {
+		t.Fatalf("nil Mask changed text: %q", got)
+	}
+	inner := &recordingProvider{}
+	if WrapProvider(inner, nil) != llm.Provider(inner) {
+		t.Fatal("nil redactor must not wrap the provider")
+	}
+}
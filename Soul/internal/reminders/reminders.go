@@ -0,0 +1,249 @@
+// Package reminders keeps a server-side copy of the reminders and alarms a
+// user sets through terminal skills, and delivers them when they fall due:
+// to the terminal over MQTT, into the owning session as a system message and,
+// when configured, to a chat gateway. A reminder therefore still reaches the
+// user when the robot is powered off at trigger time.
+package reminders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+	"soul/internal/quiethours"
+	"soul/internal/skills"
+)
+
+// Skills that set a timed reminder on the terminal.
+const (
+	SkillSetReminder = "set_reminder"
+	SkillCreateAlarm = "create_alarm"
+)
+
+type Store interface {
+	CreateReminder(ctx context.Context, r domain.Reminder, dueAt time.Time) (domain.Reminder, error)
+	ListDueReminders(ctx context.Context, now time.Time, limit int) ([]domain.Reminder, error)
+	MarkReminderDelivered(ctx context.Context, id int64, at time.Time) error
+}
+
+// MessageWriter appends a message to a session; memory.Service does it.
+type MessageWriter interface {
+	PersistMessage(ctx context.Context, sessionID, userID, terminalID, soulID, role, name, toolCallID, content string) error
+}
+
+type StatusPublisher interface {
+	PublishStatus(ctx context.Context, terminalID, status, message, sessionID string) error
+}
+
+// Pusher forwards a due reminder to wherever else the user can be reached.
+type Pusher interface {
+	Push(ctx context.Context, r domain.Reminder) error
+}
+
+// QuietHours tells whether a user is in a do-not-disturb window;
+// quiethours.Policy does it.
+type QuietHours interface {
+	Active(userID, terminalID string, now time.Time) (quiethours.Window, bool)
+}
+
+// Tracker wraps the skill invoker and records every reminder a terminal
+// accepts, so the server can deliver it even if the terminal is off later.
+type Tracker struct {
+	inner  skills.TerminalInvoker
+	store  Store
+	logger *slog.Logger
+	now    func() time.Time
+}
+
+func NewTracker(inner skills.TerminalInvoker, store Store, logger *slog.Logger) *Tracker {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Tracker{inner: inner, store: store, logger: logger, now: time.Now}
+}
+
+func (t *Tracker) InvokeSkill(ctx context.Context, terminalID, skill string, args json.RawMessage) (domain.InvokeResult, error) {
+	result, err := t.inner.InvokeSkill(ctx, terminalID, skill, args)
+	if err != nil || (skill != SkillSetReminder && skill != SkillCreateAlarm) {
+		return result, err
+	}
+	content, dueAt, ok := parseReminder(skill, args, t.now())
+	if !ok {
+		return result, nil
+	}
+	caller, _ := skills.CallerFrom(ctx)
+	stored, storeErr := t.store.CreateReminder(ctx, domain.Reminder{
+		UserID:     caller.UserID,
+		TerminalID: terminalID,
+		SessionID:  caller.SessionID,
+		SoulID:     caller.SoulID,
+		Skill:      skill,
+		Content:    content,
+	}, dueAt)
+	if storeErr != nil {
+		// The terminal has the reminder; only the offline fallback is lost.
+		t.logger.Warn("record reminder failed", "terminal_id", terminalID, "skill", skill, "error", storeErr)
+		return result, nil
+	}
+	t.logger.Info("reminder recorded", "id", stored.ID, "terminal_id", terminalID, "due_at", stored.DueAt)
+	return result, nil
+}
+
+// parseReminder reads the content and due time from set_reminder (content,
+// due_at) or create_alarm (label, trigger_at or trigger_in_seconds). Calls
+// without a due time have nothing to schedule.
+func parseReminder(skill string, args json.RawMessage, now time.Time) (string, time.Time, bool) {
+	var in map[string]any
+	if err := json.Unmarshal(args, &in); err != nil {
+		return "", time.Time{}, false
+	}
+	str := func(key string) string {
+		v, _ := in[key].(string)
+		return strings.TrimSpace(v)
+	}
+
+	var content, at string
+	var inSeconds float64
+	switch skill {
+	case SkillSetReminder:
+		content, at = str("content"), str("due_at")
+	case SkillCreateAlarm:
+		content, at = str("label"), str("trigger_at")
+		if content == "" {
+			content = "闹钟"
+		}
+		switch v := in["trigger_in_seconds"].(type) {
+		case float64:
+			inSeconds = v
+		case string:
+			inSeconds, _ = strconv.ParseFloat(strings.TrimSpace(v), 64)
+		}
+	}
+	if content == "" {
+		return "", time.Time{}, false
+	}
+	if at != "" {
+		due, err := parseTime(at)
+		if err != nil {
+			return "", time.Time{}, false
+		}
+		return content, due, true
+	}
+	if inSeconds > 0 {
+		return content, now.Add(time.Duration(inSeconds * float64(time.Second))), true
+	}
+	return "", time.Time{}, false
+}
+
+// parseTime accepts RFC 3339 and the zone-less forms an LLM tends to write,
+// which are read in the server's local time zone.
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", v)
+}
+
+// Dispatcher delivers reminders once they fall due.
+type Dispatcher struct {
+	store    Store
+	messages MessageWriter
+	status   StatusPublisher
+	pusher   Pusher
+	quiet    QuietHours
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+// NewDispatcher builds a Dispatcher; pusher and quiet may be nil.
+func NewDispatcher(store Store, messages MessageWriter, status StatusPublisher, pusher Pusher, quiet QuietHours, logger *slog.Logger) *Dispatcher {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Dispatcher{store: store, messages: messages, status: status, pusher: pusher, quiet: quiet, logger: logger, now: time.Now}
+}
+
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		d.DispatchDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue delivers every reminder that is due now. A reminder due during
+// the user's quiet hours stays pending and goes out on the first scan after
+// the window ends.
+func (d *Dispatcher) DispatchDue(ctx context.Context) {
+	now := d.now()
+	due, err := d.store.ListDueReminders(ctx, now, 50)
+	if err != nil {
+		d.logger.Warn("list due reminders failed", "error", err)
+		return
+	}
+	for _, r := range due {
+		if d.quiet != nil {
+			if w, ok := d.quiet.Active(r.UserID, r.TerminalID, now); ok {
+				d.logger.Debug("reminder held for quiet hours", "id", r.ID, "terminal_id", r.TerminalID, "until", w.Until)
+				continue
+			}
+		}
+		d.deliver(ctx, r)
+	}
+}
+
+// deliver claims the reminder first, so with several soul-server instances
+// each reminder goes out once; a failure after the claim is logged rather
+// than retried.
+func (d *Dispatcher) deliver(ctx context.Context, r domain.Reminder) {
+	if err := d.store.MarkReminderDelivered(ctx, r.ID, d.now()); err != nil {
+		if !errors.Is(err, db.ErrReminderNotFound) {
+			d.logger.Warn("claim reminder failed", "id", r.ID, "error", err)
+		}
+		return
+	}
+	r.Status = domain.ReminderStatusDelivered
+
+	if r.SessionID != "" {
+		if err := d.messages.PersistMessage(ctx, r.SessionID, r.UserID, r.TerminalID, r.SoulID, "system", "reminder", "", reminderMessage(r)); err != nil {
+			d.logger.Warn("write reminder to session failed", "id", r.ID, "session_id", r.SessionID, "error", err)
+		}
+	}
+	if err := d.status.PublishStatus(ctx, r.TerminalID, domain.TerminalStatusReminder, r.Content, r.SessionID); err != nil {
+		d.logger.Warn("publish reminder failed", "id", r.ID, "terminal_id", r.TerminalID, "error", err)
+	}
+	if d.pusher != nil {
+		if err := d.pusher.Push(ctx, r); err != nil {
+			d.logger.Warn("push reminder failed", "id", r.ID, "user_id", r.UserID, "error", err)
+		}
+	}
+	d.logger.Info("reminder delivered", "id", r.ID, "terminal_id", r.TerminalID, "session_id", r.SessionID)
+}
+
+func reminderMessage(r domain.Reminder) string {
+	due, err := time.Parse(time.RFC3339Nano, r.DueAt)
+	if err != nil {
+		return fmt.Sprintf("提醒已触发：%s", r.Content)
+	}
+	return fmt.Sprintf("提醒已触发（%s）：%s", due.In(time.Local).Format("2006-01-02 15:04"), r.Content)
+}
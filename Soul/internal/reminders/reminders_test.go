@@ -0,0 +1,170 @@
+package reminders
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+	"soul/internal/quiethours"
+	"soul/internal/skills"
+)
+
+type memoryStore struct {
+	items []domain.Reminder
+	due   map[int64]time.Time
+}
+
+func (s *memoryStore) CreateReminder(_ context.Context, r domain.Reminder, dueAt time.Time) (domain.Reminder, error) {
+	r.ID = int64(len(s.items) + 1)
+	r.DueAt = dueAt.UTC().Format(time.RFC3339Nano)
+	r.Status = domain.ReminderStatusPending
+	s.items = append(s.items, r)
+	if s.due == nil {
+		s.due = make(map[int64]time.Time)
+	}
+	s.due[r.ID] = dueAt
+	return r, nil
+}
+
+func (s *memoryStore) ListDueReminders(_ context.Context, now time.Time, _ int) ([]domain.Reminder, error) {
+	var out []domain.Reminder
+	for _, r := range s.items {
+		if r.Status == domain.ReminderStatusPending && !s.due[r.ID].After(now) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) MarkReminderDelivered(_ context.Context, id int64, _ time.Time) error {
+	for i := range s.items {
+		if s.items[i].ID == id && s.items[i].Status == domain.ReminderStatusPending {
+			s.items[i].Status = domain.ReminderStatusDelivered
+			return nil
+		}
+	}
+	return db.ErrReminderNotFound
+}
+
+type okTerminal struct{}
+
+func (okTerminal) InvokeSkill(context.Context, string, string, json.RawMessage) (domain.InvokeResult, error) {
+	return domain.InvokeResult{OK: true, Output: "ok"}, nil
+}
+
+type sessionWrites struct{ rows []string }
+
+func (w *sessionWrites) PersistMessage(_ context.Context, sessionID, _, _, _, role, name, _, content string) error {
+	w.rows = append(w.rows, sessionID+"|"+role+"|"+name+"|"+content)
+	return nil
+}
+
+type statusLog struct{ sent []string }
+
+func (l *statusLog) PublishStatus(_ context.Context, terminalID, status, message, _ string) error {
+	l.sent = append(l.sent, terminalID+"|"+status+"|"+message)
+	return nil
+}
+
+type pushLog struct{ pushed []domain.Reminder }
+
+func (p *pushLog) Push(_ context.Context, r domain.Reminder) error {
+	p.pushed = append(p.pushed, r)
+	return nil
+}
+
+func TestParseReminder(t *testing.T) {
+	now := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	cases := []struct {
+		skill   string
+		args    string
+		content string
+		due     time.Time
+		ok      bool
+	}{
+		{SkillSetReminder, `{"content":"吃药","due_at":"2026-10-16T20:00:00+08:00"}`, "吃药", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), true},
+		{SkillSetReminder, `{"content":"吃药"}`, "", time.Time{}, false},
+		{SkillCreateAlarm, `{"trigger_in_seconds":90}`, "闹钟", now.Add(90 * time.Second), true},
+		{SkillCreateAlarm, `{"label":"起床","trigger_in_seconds":"60"}`, "起床", now.Add(time.Minute), true},
+		{SkillCreateAlarm, `{"label":"起床","trigger_at":"明天"}`, "", time.Time{}, false},
+	}
+	for _, c := range cases {
+		content, due, ok := parseReminder(c.skill, json.RawMessage(c.args), now)
+		if ok != c.ok || content != c.content || !due.Equal(c.due) {
+			t.Fatalf("%s %s: got %q %v %v", c.skill, c.args, content, due, ok)
+		}
+	}
+}
+
+func TestTrackedReminderIsDeliveredOnceWhenDue(t *testing.T) {
+	store := &memoryStore{}
+	now := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	tracker := NewTracker(okTerminal{}, store, nil)
+	tracker.now = func() time.Time { return now }
+
+	ctx := skills.WithCaller(context.Background(), skills.Caller{UserID: "u1", SessionID: "s1", SoulID: "soul_a"})
+	if _, err := tracker.InvokeSkill(ctx, "t1", SkillCreateAlarm, json.RawMessage(`{"label":"开会","trigger_in_seconds":60}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tracker.InvokeSkill(ctx, "t1", "light_on", json.RawMessage(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.items) != 1 || store.items[0].UserID != "u1" || store.items[0].SessionID != "s1" || store.items[0].Content != "开会" {
+		t.Fatalf("unexpected recorded reminders: %+v", store.items)
+	}
+
+	writes, status, push := &sessionWrites{}, &statusLog{}, &pushLog{}
+	dispatcher := NewDispatcher(store, writes, status, push, nil, nil)
+	dispatcher.now = func() time.Time { return now.Add(30 * time.Second) }
+	dispatcher.DispatchDue(context.Background())
+	if len(status.sent) != 0 {
+		t.Fatalf("reminder delivered early: %v", status.sent)
+	}
+
+	dispatcher.now = func() time.Time { return now.Add(2 * time.Minute) }
+	dispatcher.DispatchDue(context.Background())
+	dispatcher.DispatchDue(context.Background())
+	if len(writes.rows) != 1 || !strings.HasPrefix(writes.rows[0], "s1|system|reminder|提醒已触发") || !strings.HasSuffix(writes.rows[0], "开会") {
+		t.Fatalf("unexpected session writes: %v", writes.rows)
+	}
+	if len(status.sent) != 1 || status.sent[0] != "t1|reminder|开会" {
+		t.Fatalf("unexpected mqtt status: %v", status.sent)
+	}
+	if len(push.pushed) != 1 || push.pushed[0].Status != domain.ReminderStatusDelivered {
+		t.Fatalf("unexpected pushes: %+v", push.pushed)
+	}
+}
+
+type quietUntil time.Time
+
+func (q quietUntil) Active(_, _ string, now time.Time) (quiethours.Window, bool) {
+	if now.Before(time.Time(q)) {
+		return quiethours.Window{Until: time.Time(q)}, true
+	}
+	return quiethours.Window{}, false
+}
+
+func TestReminderHeldDuringQuietHours(t *testing.T) {
+	now := time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)
+	store := &memoryStore{}
+	if _, err := store.CreateReminder(context.Background(), domain.Reminder{UserID: "u1", TerminalID: "t1", Skill: SkillCreateAlarm, Content: "起床"}, now); err != nil {
+		t.Fatal(err)
+	}
+	status := &statusLog{}
+	dispatcher := NewDispatcher(store, &sessionWrites{}, status, nil, quietUntil(now.Add(8*time.Hour)), nil)
+	dispatcher.now = func() time.Time { return now.Add(time.Minute) }
+	dispatcher.DispatchDue(context.Background())
+	if len(status.sent) != 0 || store.items[0].Status != domain.ReminderStatusPending {
+		t.Fatalf("reminder must wait for quiet hours to end: %v", status.sent)
+	}
+
+	dispatcher.now = func() time.Time { return now.Add(8 * time.Hour) }
+	dispatcher.DispatchDue(context.Background())
+	if len(status.sent) != 1 || status.sent[0] != "t1|reminder|起床" {
+		t.Fatalf("expected reminder after quiet hours, got %v", status.sent)
+	}
+}
@@ -0,0 +1,54 @@
+package reminders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// WebhookPusher POSTs each due reminder as JSON to a chat gateway, which
+// passes it on to the user's chat app.
+type WebhookPusher struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+func NewWebhookPusher(url, token string, timeout time.Duration) *WebhookPusher {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &WebhookPusher{url: strings.TrimSpace(url), token: strings.TrimSpace(token), http: &http.Client{Timeout: timeout}}
+}
+
+func (p *WebhookPusher) Push(ctx context.Context, r domain.Reminder) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return fmt.Errorf("reminder push status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
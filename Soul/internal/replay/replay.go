@@ -0,0 +1,197 @@
+// Package replay records complete chat turns — the request, the prompts and
+// raw LLM responses, the tool calls and what the emotion gate and intent
+// filter were given — so cmd/soul-replay can re-run a turn against current
+// code and show why the robot said what it said.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"soul/internal/domain"
+	"soul/internal/writebehind"
+)
+
+type Store interface {
+	InsertTurnReplay(ctx context.Context, in domain.TurnReplay) (domain.TurnReplay, error)
+}
+
+// Recorder samples turns and stores their captures.
+type Recorder struct {
+	store      Store
+	sampleRate float64
+	logger     *slog.Logger
+	random     func() float64
+	writes     *writebehind.Queue
+}
+
+// NewRecorder records about sampleRate (0..1] of all turns; nil when
+// sampleRate is not positive, which disables recording.
+func NewRecorder(store Store, sampleRate float64, logger *slog.Logger) *Recorder {
+	if sampleRate <= 0 {
+		return nil
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Recorder{store: store, sampleRate: min(sampleRate, 1), logger: logger, random: rand.Float64}
+}
+
+// Defer stores records from q after the reply has been sent instead of
+// before; it returns r.
+func (r *Recorder) Defer(q *writebehind.Queue) *Recorder {
+	if r != nil {
+		r.writes = q
+	}
+	return r
+}
+
+// Begin starts capturing the turn when it is sampled and returns nil
+// otherwise; all Capture methods accept a nil receiver.
+func (r *Recorder) Begin(req domain.ChatRequest) *Capture {
+	if r == nil || r.random() >= r.sampleRate {
+		return nil
+	}
+	return &Capture{
+		started: time.Now(),
+		rec: domain.TurnReplay{
+			SessionID:  req.SessionID,
+			TerminalID: req.TerminalID,
+			SoulID:     req.SoulID,
+			UserID:     req.UserID,
+			Request:    req,
+			LLMCalls:   []domain.ReplayLLMCall{},
+			ToolCalls:  []domain.ReplayToolCall{},
+		},
+	}
+}
+
+// Finish stores the captured turn with its outcome; turns of private
+// sessions are dropped. A failed write is only logged.
+func (r *Recorder) Finish(ctx context.Context, c *Capture, resp domain.ChatResponse, err error) {
+	if r == nil || c == nil {
+		return
+	}
+	c.mu.Lock()
+	if c.private {
+		c.mu.Unlock()
+		return
+	}
+	rec := c.rec
+	rec.DurationMS = time.Since(c.started).Milliseconds()
+	c.mu.Unlock()
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		rec.Response = &resp
+		rec.SoulID = resp.SoulID
+		rec.SessionID = resp.SessionID
+	}
+	store := func(ctx context.Context) error {
+		stored, err := r.store.InsertTurnReplay(ctx, rec)
+		if err != nil {
+			return err
+		}
+		r.logger.Info("turn replay recorded", "replay_id", stored.ID, "session_id", rec.SessionID, "llm_calls", len(rec.LLMCalls), "tool_calls", len(rec.ToolCalls))
+		return nil
+	}
+	if r.writes != nil {
+		r.writes.Do(ctx, "turn_replay", store)
+		return
+	}
+	// The turn already answered; do not let its cancellation lose the record.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := store(ctx); err != nil {
+		r.logger.Warn("record turn replay failed", "session_id", rec.SessionID, "error", err)
+	}
+}
+
+// Capture collects one turn while it runs.
+type Capture struct {
+	mu      sync.Mutex
+	started time.Time
+	rec     domain.TurnReplay
+	private bool
+}
+
+type captureKey struct{}
+
+// WithCapture carries c through the turn's context; a nil c leaves ctx as is.
+func WithCapture(ctx context.Context, c *Capture) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, captureKey{}, c)
+}
+
+// FromContext returns the turn's capture, nil when it is not recorded.
+func FromContext(ctx context.Context) *Capture {
+	c, _ := ctx.Value(captureKey{}).(*Capture)
+	return c
+}
+
+// Private marks the turn as belonging to a private session; it is not stored.
+func (c *Capture) Private() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.private = true
+	c.mu.Unlock()
+}
+
+// Inputs records what the turn started from and what the gate decided.
+func (c *Capture) Inputs(in domain.ReplayInputs) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rec.UserID = in.Soul.UserID
+	c.rec.SoulID = in.Soul.SoulID
+	c.rec.Inputs = &in
+}
+
+// Gate updates the recorded gate decision, which is re-evaluated right
+// before each LLM call.
+func (c *Capture) Gate(execMode string, execProbability float64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rec.Inputs != nil {
+		c.rec.Inputs.ExecMode, c.rec.Inputs.ExecProbability = execMode, execProbability
+	}
+}
+
+func (c *Capture) LLM(req domain.LLMRequest, resp domain.LLMResponse, err error, took time.Duration) {
+	if c == nil {
+		return
+	}
+	call := domain.ReplayLLMCall{Request: req, Response: resp, DurationMS: took.Milliseconds()}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	c.mu.Lock()
+	c.rec.LLMCalls = append(c.rec.LLMCalls, call)
+	c.mu.Unlock()
+}
+
+func (c *Capture) Tool(skill string, args json.RawMessage, result domain.InvokeResult, err error) {
+	if c == nil {
+		return
+	}
+	call := domain.ReplayToolCall{Skill: skill, Arguments: args, Result: result}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	c.mu.Lock()
+	c.rec.ToolCalls = append(c.rec.ToolCalls, call)
+	c.mu.Unlock()
+}
@@ -0,0 +1,69 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+type memStore struct {
+	items []domain.TurnReplay
+}
+
+func (m *memStore) InsertTurnReplay(_ context.Context, in domain.TurnReplay) (domain.TurnReplay, error) {
+	in.ID = int64(len(m.items) + 1)
+	m.items = append(m.items, in)
+	return in, nil
+}
+
+func TestRecorderCapturesSampledTurns(t *testing.T) {
+	store := &memStore{}
+	if NewRecorder(store, 0, nil) != nil {
+		t.Fatalf("a zero sample rate must disable recording")
+	}
+	rec := NewRecorder(store, 0.5, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	draws := []float64{0.7, 0.2, 0.1}
+	rec.random = func() float64 {
+		v := draws[0]
+		draws = draws[1:]
+		return v
+	}
+
+	if c := rec.Begin(domain.ChatRequest{SessionID: "s0"}); c != nil {
+		t.Fatalf("a draw above the rate must not be recorded")
+	}
+	// Nothing recorded: every hook must be a no-op.
+	FromContext(context.Background()).LLM(domain.LLMRequest{}, domain.LLMResponse{}, nil, 0)
+
+	c := rec.Begin(domain.ChatRequest{SessionID: "s1", TerminalID: "t1"})
+	ctx := WithCapture(context.Background(), c)
+	FromContext(ctx).Inputs(domain.ReplayInputs{Soul: domain.SoulProfile{SoulID: "soul_a", UserID: "u1"}, ExecMode: "auto_execute"})
+	FromContext(ctx).Gate("blocked", 0.2)
+	FromContext(ctx).LLM(domain.LLMRequest{System: "prompt"}, domain.LLMResponse{Content: "好的"}, nil, time.Second)
+	FromContext(ctx).Tool("control_light", json.RawMessage(`{"mode":"off"}`), domain.InvokeResult{}, errors.New("tool timeout"))
+	rec.Finish(ctx, c, domain.ChatResponse{SessionID: "s1", SoulID: "soul_a", Reply: "好的"}, nil)
+
+	private := rec.Begin(domain.ChatRequest{SessionID: "s2"})
+	private.Private()
+	rec.Finish(context.Background(), private, domain.ChatResponse{}, nil)
+
+	if len(store.items) != 1 {
+		t.Fatalf("only the sampled public turn must be stored, got %d", len(store.items))
+	}
+	got := store.items[0]
+	if got.UserID != "u1" || got.SoulID != "soul_a" || got.Inputs.ExecMode != "blocked" || got.Response.Reply != "好的" {
+		t.Fatalf("unexpected turn %+v", got)
+	}
+	if len(got.LLMCalls) != 1 || got.LLMCalls[0].Request.System != "prompt" || got.LLMCalls[0].DurationMS != 1000 {
+		t.Fatalf("unexpected llm calls %+v", got.LLMCalls)
+	}
+	if len(got.ToolCalls) != 1 || got.ToolCalls[0].Error != "tool timeout" {
+		t.Fatalf("unexpected tool calls %+v", got.ToolCalls)
+	}
+}
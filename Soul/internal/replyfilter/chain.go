@@ -0,0 +1,174 @@
+package replyfilter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"soul/internal/domain"
+)
+
+// ErrInvalidFilter wraps the reasons Parse rejects a filter spec.
+var ErrInvalidFilter = errors.New("invalid reply filter")
+
+// DefaultSpecs is the chain that matches the historic reply handling.
+var DefaultSpecs = []string{"trim", "no_reply"}
+
+// Chain runs its filters in order.
+type Chain struct {
+	specs   []string
+	filters []Filter
+}
+
+// Parse builds a chain from specs such as "trim", "max_length:80" or
+// "emoji:strip".
+func Parse(specs []string, opts Options) (Chain, error) {
+	chain := Chain{}
+	for _, spec := range specs {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		f, err := newFilter(spec, opts)
+		if err != nil {
+			return Chain{}, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		chain.specs = append(chain.specs, spec)
+		chain.filters = append(chain.filters, f)
+	}
+	return chain, nil
+}
+
+// Default returns the trim and NO_REPLY chain.
+func Default() Chain {
+	chain, _ := Parse(DefaultSpecs, Options{})
+	return chain
+}
+
+// Specs returns the specs the chain was parsed from.
+func (c Chain) Specs() []string {
+	return append([]string{}, c.specs...)
+}
+
+// Run passes text through every filter; once a filter marks the reply
+// silent the rest are skipped.
+func (c Chain) Run(text string, ctx Context) Reply {
+	r := Reply{Text: text}
+	for _, f := range c.filters {
+		r = f.Apply(r, ctx)
+		if r.Silent {
+			return Reply{Silent: true}
+		}
+	}
+	return r
+}
+
+type Store interface {
+	ListTerminalReplyFilters(ctx context.Context) ([]domain.TerminalReplyFilters, error)
+	UpsertTerminalReplyFilters(ctx context.Context, in domain.TerminalReplyFilters) (domain.TerminalReplyFilters, error)
+	DeleteTerminalReplyFilters(ctx context.Context, terminalID string) error
+}
+
+// Policy caches the per-terminal chains; terminals without one use the
+// default chain. A nil Policy always uses Default.
+type Policy struct {
+	store    Store
+	opts     Options
+	defaults Chain
+
+	mu        sync.RWMutex
+	terminals map[string]terminalChain
+}
+
+type terminalChain struct {
+	item  domain.TerminalReplyFilters
+	chain Chain
+}
+
+// NewPolicy builds a Policy whose default chain is parsed from defaults.
+func NewPolicy(store Store, defaults []string, opts Options) (*Policy, error) {
+	chain, err := Parse(defaults, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Policy{store: store, opts: opts, defaults: chain, terminals: make(map[string]terminalChain)}, nil
+}
+
+// Load replaces the cached chains with the stored ones. A stored chain that
+// no longer parses, e.g. after a filter was renamed, fails the load.
+func (p *Policy) Load(ctx context.Context) error {
+	items, err := p.store.ListTerminalReplyFilters(ctx)
+	if err != nil {
+		return err
+	}
+	terminals := make(map[string]terminalChain, len(items))
+	for _, item := range items {
+		chain, err := Parse(item.Filters, p.opts)
+		if err != nil {
+			return fmt.Errorf("terminal %s: %w", item.TerminalID, err)
+		}
+		terminals[item.TerminalID] = terminalChain{item: item, chain: chain}
+	}
+	p.mu.Lock()
+	p.terminals = terminals
+	p.mu.Unlock()
+	return nil
+}
+
+// Chain returns the terminal's chain, or the default one.
+func (p *Policy) Chain(terminalID string) Chain {
+	if p == nil {
+		return Default()
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if tc, ok := p.terminals[terminalID]; ok {
+		return tc.chain
+	}
+	return p.defaults
+}
+
+// Get describes the terminal's chain; Default is set when the terminal has
+// none of its own.
+func (p *Policy) Get(terminalID string) domain.TerminalReplyFilters {
+	p.mu.RLock()
+	tc, ok := p.terminals[terminalID]
+	p.mu.RUnlock()
+	if ok {
+		tc.item.Filters = tc.chain.Specs()
+		return tc.item
+	}
+	return domain.TerminalReplyFilters{TerminalID: terminalID, Filters: p.defaults.Specs(), Default: true}
+}
+
+// Set validates and stores the terminal's chain.
+func (p *Policy) Set(ctx context.Context, terminalID string, specs []string) (domain.TerminalReplyFilters, error) {
+	terminalID = strings.TrimSpace(terminalID)
+	if terminalID == "" {
+		return domain.TerminalReplyFilters{}, fmt.Errorf("%w: terminal_id is required", ErrInvalidFilter)
+	}
+	chain, err := Parse(specs, p.opts)
+	if err != nil {
+		return domain.TerminalReplyFilters{}, err
+	}
+	stored, err := p.store.UpsertTerminalReplyFilters(ctx, domain.TerminalReplyFilters{TerminalID: terminalID, Filters: chain.Specs()})
+	if err != nil {
+		return domain.TerminalReplyFilters{}, err
+	}
+	p.mu.Lock()
+	p.terminals[terminalID] = terminalChain{item: stored, chain: chain}
+	p.mu.Unlock()
+	return stored, nil
+}
+
+// Delete drops the terminal's chain, so it falls back to the default.
+func (p *Policy) Delete(ctx context.Context, terminalID string) error {
+	if err := p.store.DeleteTerminalReplyFilters(ctx, terminalID); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	delete(p.terminals, terminalID)
+	p.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,241 @@
+// Package replyfilter post-processes LLM replies through an ordered chain of
+// filters — trimming, the NO_REPLY marker, emoji policy, length caps,
+// forbidden phrase scrubbing and TTS-friendly rewriting — chosen per
+// terminal.
+package replyfilter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"soul/internal/domain"
+)
+
+// Reply is the text moving through a chain. Silent means the LLM chose not
+// to answer; later filters leave a silent reply alone.
+type Reply struct {
+	Text   string
+	Silent bool
+}
+
+// Context is what a filter may know about where the reply goes.
+type Context struct {
+	Output   *domain.TerminalOutputCapabilities
+	Language string
+}
+
+type Filter interface {
+	Apply(r Reply, c Context) Reply
+}
+
+type filterFunc func(r Reply, c Context) Reply
+
+func (f filterFunc) Apply(r Reply, c Context) Reply { return f(r, c) }
+
+// Options configures the filters that need more than their spec argument.
+type Options struct {
+	// ScrubPhrases are removed by the scrub filter.
+	ScrubPhrases []string
+}
+
+// Names lists the filters a spec may name, as "name" or "name:arg".
+func Names() []string {
+	return []string{"trim", "no_reply", "emoji", "max_length", "scrub", "tts"}
+}
+
+// newFilter builds the filter a spec names.
+func newFilter(spec string, opts Options) (Filter, error) {
+	name, arg, _ := strings.Cut(strings.TrimSpace(spec), ":")
+	name, arg = strings.TrimSpace(name), strings.TrimSpace(arg)
+	switch name {
+	case "trim":
+		return filterFunc(trim), nil
+	case "no_reply":
+		return filterFunc(noReply), nil
+	case "emoji":
+		switch arg {
+		case "", "strip":
+			return filterFunc(stripEmoji), nil
+		case "keep":
+			return filterFunc(func(r Reply, _ Context) Reply { return r }), nil
+		}
+		return nil, fmt.Errorf("emoji policy must be strip or keep, got %q", arg)
+	case "max_length":
+		limit := 0
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("max_length must be a positive rune count, got %q", arg)
+			}
+			limit = n
+		}
+		return maxLength(limit), nil
+	case "scrub":
+		return scrub(opts.ScrubPhrases), nil
+	case "tts":
+		return filterFunc(ttsNormalize), nil
+	}
+	return nil, fmt.Errorf("unknown reply filter %q", name)
+}
+
+func trim(r Reply, _ Context) Reply {
+	r.Text = strings.TrimSpace(r.Text)
+	return r
+}
+
+// noReply turns the model's "do not answer" marker into a silent reply.
+func noReply(r Reply, _ Context) Reply {
+	marker := strings.ToUpper(strings.Trim(strings.TrimSpace(r.Text), "`"))
+	switch marker {
+	case "<NO_REPLY>", "NO_REPLY", "[NO_REPLY]":
+		return Reply{Silent: true}
+	}
+	return r
+}
+
+func stripEmoji(r Reply, _ Context) Reply {
+	r.Text = strings.TrimSpace(strings.Map(func(ch rune) rune {
+		if isEmoji(ch) {
+			return -1
+		}
+		return ch
+	}, r.Text))
+	return r
+}
+
+func isEmoji(ch rune) bool {
+	switch {
+	case ch >= 0x1F000 && ch <= 0x1FAFF, // pictographs, emoticons, transport, flags
+		ch >= 0x2600 && ch <= 0x27BF, // misc symbols and dingbats
+		ch == 0x200D, ch == 0xFE0F:   // joiner and emoji presentation selector
+		return true
+	}
+	return false
+}
+
+// maxLength caps the reply at limit runes, or at the terminal's max_chars
+// when limit is 0.
+func maxLength(limit int) Filter {
+	return filterFunc(func(r Reply, c Context) Reply {
+		n := limit
+		if n == 0 && c.Output != nil {
+			n = c.Output.MaxChars
+		}
+		r.Text = Shorten(r.Text, n)
+		return r
+	})
+}
+
+// Shorten cuts text to at most maxRunes, at the last sentence end that fits
+// when there is one in the second half, else mid-sentence with "…" added.
+func Shorten(text string, maxRunes int) string {
+	runes := []rune(text)
+	if maxRunes <= 0 || len(runes) <= maxRunes {
+		return text
+	}
+	cut := runes[:maxRunes]
+	for i := len(cut) - 1; i >= maxRunes/2; i-- {
+		switch cut[i] {
+		case '。', '！', '？', '!', '?', '；':
+			return string(cut[:i+1])
+		}
+	}
+	return string(cut) + "…"
+}
+
+// scrub removes boilerplate such as "作为一个AI，" together with the comma
+// that follows it.
+func scrub(phrases []string) Filter {
+	var patterns []*regexp.Regexp
+	for _, phrase := range phrases {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			patterns = append(patterns, regexp.MustCompile(`(?i)`+regexp.QuoteMeta(phrase)+`[，,、：:]?\s*`))
+		}
+	}
+	return filterFunc(func(r Reply, _ Context) Reply {
+		for _, re := range patterns {
+			r.Text = re.ReplaceAllString(r.Text, "")
+		}
+		r.Text = strings.TrimSpace(r.Text)
+		return r
+	})
+}
+
+var (
+	markdownEmphasis = regexp.MustCompile("\\*\\*|__|`+")
+	markdownLine     = regexp.MustCompile(`(?m)^\s*(?:#{1,6}\s+|[-*+]\s+|\d+[.)]\s+|>\s*)`)
+	dateNumeric      = regexp.MustCompile(`\b(\d{4})-(\d{1,2})-(\d{1,2})\b`)
+	clockTime        = regexp.MustCompile(`\b([01]?\d|2[0-3]):([0-5]\d)\b`)
+	numberRange      = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*[-~～]\s*(\d+(?:\.\d+)?)`)
+	percent          = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*[%％]`)
+	celsius          = regexp.MustCompile(`(-?)(\d+(?:\.\d+)?)\s*(?:℃|°C|°c)`)
+)
+
+// unitWords are read out after a number, longest unit first.
+var unitWords = []struct {
+	re   *regexp.Regexp
+	word string
+}{
+	{regexp.MustCompile(`(\d)\s*(?:km/h|公里/小时)`), "${1}公里每小时"},
+	{regexp.MustCompile(`(\d)\s*km\b`), "${1}公里"},
+	{regexp.MustCompile(`(\d)\s*kg\b`), "${1}公斤"},
+	{regexp.MustCompile(`(\d)\s*cm\b`), "${1}厘米"},
+	{regexp.MustCompile(`(\d)\s*mm\b`), "${1}毫米"},
+	{regexp.MustCompile(`(\d)\s*ml\b`), "${1}毫升"},
+	{regexp.MustCompile(`(\d)\s*m\b`), "${1}米"},
+}
+
+// ttsNormalize rewrites what a speech engine reads badly: Markdown marks,
+// and in Chinese replies dates, clock times, ranges, percentages,
+// temperatures and unit abbreviations.
+func ttsNormalize(r Reply, c Context) Reply {
+	text := markdownLine.ReplaceAllString(r.Text, "")
+	text = markdownEmphasis.ReplaceAllString(text, "")
+	if c.Language == "en" || !hasHan(text) {
+		text = percent.ReplaceAllString(text, "$1 percent")
+		text = celsius.ReplaceAllString(text, "$1$2 degrees Celsius")
+		r.Text = strings.TrimSpace(text)
+		return r
+	}
+	text = dateNumeric.ReplaceAllStringFunc(text, func(m string) string {
+		p := dateNumeric.FindStringSubmatch(m)
+		return p[1] + "年" + strings.TrimLeft(p[2], "0") + "月" + strings.TrimLeft(p[3], "0") + "日"
+	})
+	text = clockTime.ReplaceAllStringFunc(text, func(m string) string {
+		p := clockTime.FindStringSubmatch(m)
+		hour := strings.TrimLeft(p[1], "0")
+		if hour == "" {
+			hour = "0"
+		}
+		if p[2] == "00" {
+			return hour + "点"
+		}
+		return hour + "点" + p[2] + "分"
+	})
+	text = celsius.ReplaceAllStringFunc(text, func(m string) string {
+		p := celsius.FindStringSubmatch(m)
+		if p[1] == "-" {
+			return "零下" + p[2] + "摄氏度"
+		}
+		return p[2] + "摄氏度"
+	})
+	text = percent.ReplaceAllString(text, "百分之$1")
+	text = numberRange.ReplaceAllString(text, "${1}到${2}")
+	for _, u := range unitWords {
+		text = u.re.ReplaceAllString(text, u.word)
+	}
+	r.Text = strings.TrimSpace(text)
+	return r
+}
+
+func hasHan(text string) bool {
+	for _, ch := range text {
+		if unicode.Is(unicode.Han, ch) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package replyfilter
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestDefaultChain(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantReply  string
+		wantSilent bool
+	}{
+		{name: "marker angle", input: "<NO_REPLY>", wantReply: "", wantSilent: true},
+		{name: "marker plain", input: "NO_REPLY", wantReply: "", wantSilent: true},
+		{name: "marker bracket", input: "[NO_REPLY]", wantReply: "", wantSilent: true},
+		{name: "marker fenced", input: " `no_reply` ", wantReply: "", wantSilent: true},
+		{name: "normal text", input: "  好的  ", wantReply: "好的", wantSilent: false},
+		{name: "empty", input: "   ", wantReply: "", wantSilent: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Default().Run(tt.input, Context{})
+			if got.Text != tt.wantReply || got.Silent != tt.wantSilent {
+				t.Fatalf("Run(%q) = %+v, want (%q,%v)", tt.input, got, tt.wantReply, tt.wantSilent)
+			}
+		})
+	}
+}
+
+func TestShorten(t *testing.T) {
+	if got := Shorten("小猫喜欢吃鱼。", 20); got != "小猫喜欢吃鱼。" {
+		t.Fatalf("short reply changed: %q", got)
+	}
+	if got := Shorten("小猫喜欢吃鱼。它也喜欢晒太阳和玩毛线球。", 12); got != "小猫喜欢吃鱼。" {
+		t.Fatalf("want cut at sentence end, got %q", got)
+	}
+	if got := Shorten("小猫喜欢吃鱼也喜欢晒太阳", 6); got != "小猫喜欢吃鱼…" {
+		t.Fatalf("want hard cut, got %q", got)
+	}
+}
+
+func TestChainFilters(t *testing.T) {
+	opts := Options{ScrubPhrases: []string{"作为一个AI", "As an AI language model"}}
+	chain, err := Parse([]string{"trim", "no_reply", "scrub", "emoji:strip", "tts", "max_length"}, opts)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ctx := Context{Output: &domain.TerminalOutputCapabilities{MaxChars: 40}, Language: "zh"}
+	cases := []struct {
+		in, want string
+	}{
+		{"作为一个AI，我觉得明天会下雨🌧️", "我觉得明天会下雨"},
+		{"**明天**最低-5℃，降水概率30%。", "明天最低零下5摄氏度，降水概率百分之30。"},
+		{"会议在2024-03-08 9:30开始，持续1-2小时。", "会议在2024年3月8日 9点30分开始，持续1到2小时。"},
+		{"限速60km/h，全程12km。", "限速60公里每小时，全程12公里。"},
+		{"今天天气很好，阳光明媚，微风不燥，气温也刚刚合适。适合出去散步，也适合去公园放风筝。", "今天天气很好，阳光明媚，微风不燥，气温也刚刚合适。"},
+	}
+	for _, tc := range cases {
+		if got := chain.Run(tc.in, ctx); got.Text != tc.want || got.Silent {
+			t.Errorf("Run(%q) = %+v, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	en := Context{Language: "en"}
+	if got := chain.Run("As an AI language model, I think it is 20°C with 10% rain.", en).Text; got != "I think it is 20 degrees Celsius with 10 percent rain." {
+		t.Errorf("english tts = %q", got)
+	}
+	if got := chain.Run("NO_REPLY", ctx); !got.Silent || got.Text != "" {
+		t.Errorf("silent reply went on through the chain: %+v", got)
+	}
+}
+
+func TestParseRejectsInvalidFilter(t *testing.T) {
+	for _, specs := range [][]string{{"trim", "shout"}, {"max_length:abc"}, {"max_length:0"}, {"emoji:sparkle"}} {
+		if _, err := Parse(specs, Options{}); !errors.Is(err, ErrInvalidFilter) {
+			t.Fatalf("%v: expected ErrInvalidFilter, got %v", specs, err)
+		}
+	}
+}
+
+type memoryStore struct {
+	items map[string]domain.TerminalReplyFilters
+}
+
+func (s *memoryStore) ListTerminalReplyFilters(context.Context) ([]domain.TerminalReplyFilters, error) {
+	out := make([]domain.TerminalReplyFilters, 0, len(s.items))
+	for _, item := range s.items {
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) UpsertTerminalReplyFilters(_ context.Context, in domain.TerminalReplyFilters) (domain.TerminalReplyFilters, error) {
+	s.items[in.TerminalID] = in
+	return in, nil
+}
+
+func (s *memoryStore) DeleteTerminalReplyFilters(_ context.Context, terminalID string) error {
+	delete(s.items, terminalID)
+	return nil
+}
+
+func TestPolicyTerminalChain(t *testing.T) {
+	store := &memoryStore{items: map[string]domain.TerminalReplyFilters{
+		"speaker": {TerminalID: "speaker", Filters: []string{"trim", "emoji"}},
+	}}
+	policy, err := NewPolicy(store, DefaultSpecs, Options{})
+	if err != nil {
+		t.Fatalf("new policy: %v", err)
+	}
+	if err := policy.Load(context.Background()); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if got := policy.Chain("speaker").Run(" 好的😀 ", Context{}).Text; got != "好的" {
+		t.Fatalf("terminal chain not used: %q", got)
+	}
+	if got := policy.Chain("screen").Run(" 好的😀 ", Context{}).Text; got != "好的😀" {
+		t.Fatalf("default chain not used: %q", got)
+	}
+	if item := policy.Get("screen"); !item.Default {
+		t.Fatalf("screen should report the default chain: %+v", item)
+	}
+
+	if _, err := policy.Set(context.Background(), "screen", []string{"trim", "max_length:2"}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if got := policy.Chain("screen").Run("你好啊", Context{}).Text; got != "你好…" {
+		t.Fatalf("updated chain not used: %q", got)
+	}
+	if _, err := policy.Set(context.Background(), "screen", []string{"shout"}); !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+	if err := policy.Delete(context.Background(), "speaker"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if item := policy.Get("speaker"); !item.Default {
+		t.Fatalf("deleted terminal should fall back to the default chain: %+v", item)
+	}
+
+	var nilPolicy *Policy
+	if got := nilPolicy.Chain("any").Run("NO_REPLY", Context{}); !got.Silent {
+		t.Fatalf("nil policy must run the default chain: %+v", got)
+	}
+}
@@ -0,0 +1,321 @@
+// Package routines runs recurring rules users set up in chat, such as
+// "以后每天晚上十点把灯关掉". The LLM turns the sentence into a create_routine
+// call; the server stores the rule and, at each scheduled time, sends its
+// actions to the terminal as an intent_action.
+package routines
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+	"soul/internal/skills"
+)
+
+// SkillName is the server-side skill the LLM calls to create a routine.
+const SkillName = "create_routine"
+
+var weekdayNames = map[int]string{1: "周一", 2: "周二", 3: "周三", 4: "周四", 5: "周五", 6: "周六", 7: "周日"}
+
+type Store interface {
+	CreateRoutine(ctx context.Context, r domain.Routine, nextRunAt time.Time) (domain.Routine, error)
+	ListDueRoutines(ctx context.Context, now time.Time, limit int) ([]domain.Routine, error)
+	AdvanceRoutine(ctx context.Context, id int64, due, next time.Time) error
+}
+
+// SkillLookup resolves the skills a terminal can run; skills.Registry does it.
+type SkillLookup interface {
+	FindSkill(terminalID, name string) (domain.SkillDefinition, bool)
+}
+
+type IntentActionPublisher interface {
+	PublishIntentAction(ctx context.Context, terminalID string, payload domain.IntentActionPayload) error
+}
+
+// Definition is how create_routine is offered to the LLM.
+func Definition() domain.SkillDefinition {
+	return domain.SkillDefinition{
+		Name: SkillName,
+		Description: "创建周期性例行任务。当用户要求以后定期执行某个动作（如“以后每天晚上十点把灯关掉”“工作日早上七点开灯”）时调用，不要立即执行该动作。" +
+			"参数: name(string,任务名), time(string,HH:MM,24小时制), weekdays(integer数组,1=周一…7=周日,可选,缺省每天), " +
+			"actions(数组,每项 skill 为终端技能名、arguments 为该技能参数)。",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{` +
+			`"name":{"type":"string"},` +
+			`"time":{"type":"string","pattern":"^[0-2][0-9]:[0-5][0-9]$"},` +
+			`"weekdays":{"type":"array","items":{"type":"integer","minimum":1,"maximum":7}},` +
+			`"actions":{"type":"array","minItems":1,"items":{"type":"object","properties":{"skill":{"type":"string"},"arguments":{"type":"object"}},"required":["skill"]}}` +
+			`},"required":["name","time","actions"]}`),
+	}
+}
+
+// Creator implements create_routine.
+type Creator struct {
+	store  Store
+	skills SkillLookup
+	now    func() time.Time
+}
+
+func NewCreator(store Store, skills SkillLookup) *Creator {
+	return &Creator{store: store, skills: skills, now: time.Now}
+}
+
+type createArgs struct {
+	Name     string `json:"name"`
+	Time     string `json:"time"`
+	Weekdays []int  `json:"weekdays"`
+	Actions  []struct {
+		Skill     string         `json:"skill"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"actions"`
+}
+
+// InvokeSkill runs create_routine for skills.Router. The output restates the
+// stored rule for the LLM; the confirmation is what the user is told.
+func (c *Creator) InvokeSkill(ctx context.Context, terminalID string, args json.RawMessage) (domain.InvokeResult, error) {
+	var in createArgs
+	if err := json.Unmarshal(args, &in); err != nil {
+		return domain.InvokeResult{}, fmt.Errorf("invalid create_routine arguments: %w", err)
+	}
+	routine, err := c.parse(terminalID, in)
+	if err != nil {
+		return domain.InvokeResult{}, err
+	}
+	next, err := NextRun(routine.Time, routine.Weekdays, c.now())
+	if err != nil {
+		return domain.InvokeResult{}, err
+	}
+	caller, _ := skills.CallerFrom(ctx)
+	routine.UserID = caller.UserID
+	routine.SessionID = caller.SessionID
+	routine.SoulID = caller.SoulID
+	stored, err := c.store.CreateRoutine(ctx, routine, next)
+	if err != nil {
+		return domain.InvokeResult{}, err
+	}
+	nextText := next.In(time.Local).Format("01月02日 15:04")
+	return domain.InvokeResult{
+		OK:           true,
+		Output:       fmt.Sprintf("已创建例行任务#%d「%s」：%s。下次执行：%s。", stored.ID, stored.Name, Describe(stored), nextText),
+		Confirmation: fmt.Sprintf("好的，已添加例行任务「%s」：%s %s 自动执行，下次是 %s。", stored.Name, describeDays(stored.Weekdays), stored.Time, nextText),
+	}, nil
+}
+
+func (c *Creator) parse(terminalID string, in createArgs) (domain.Routine, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return domain.Routine{}, fmt.Errorf("create_routine requires name")
+	}
+	at, err := parseClock(in.Time)
+	if err != nil {
+		return domain.Routine{}, err
+	}
+	weekdays, err := normalizeWeekdays(in.Weekdays)
+	if err != nil {
+		return domain.Routine{}, err
+	}
+	if len(in.Actions) == 0 {
+		return domain.Routine{}, fmt.Errorf("create_routine requires at least one action")
+	}
+	actions := make([]domain.IntentActionItem, 0, len(in.Actions))
+	for i, action := range in.Actions {
+		skill := strings.TrimSpace(action.Skill)
+		if skill == SkillName {
+			return domain.Routine{}, fmt.Errorf("actions[%d]: a routine cannot create routines", i)
+		}
+		if _, ok := c.skills.FindSkill(terminalID, skill); !ok {
+			return domain.Routine{}, fmt.Errorf("actions[%d]: terminal has no skill %q", i, skill)
+		}
+		// The terminal runs intent_action by normalized.skill plus the
+		// remaining normalized fields, as for intents from the filter.
+		normalized := make(map[string]any, len(action.Arguments)+1)
+		for k, v := range action.Arguments {
+			normalized[k] = v
+		}
+		normalized["skill"] = skill
+		actions = append(actions, domain.IntentActionItem{
+			IntentID:   "routine." + skill,
+			IntentName: name,
+			Confidence: 1,
+			Parameters: action.Arguments,
+			Normalized: normalized,
+		})
+	}
+	return domain.Routine{TerminalID: terminalID, Name: name, Time: at, Weekdays: weekdays, Actions: actions}, nil
+}
+
+// Describe renders a routine's rule, e.g. "每天 22:00 执行 control_light（mode=off）".
+func Describe(r domain.Routine) string {
+	actions := make([]string, 0, len(r.Actions))
+	for _, action := range r.Actions {
+		skill, _ := action.Normalized["skill"].(string)
+		keys := make([]string, 0, len(action.Parameters))
+		for k := range action.Parameters {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		params := make([]string, 0, len(keys))
+		for _, k := range keys {
+			params = append(params, fmt.Sprintf("%s=%v", k, action.Parameters[k]))
+		}
+		if len(params) > 0 {
+			skill += "（" + strings.Join(params, ", ") + "）"
+		}
+		actions = append(actions, skill)
+	}
+	return fmt.Sprintf("%s %s 执行 %s", describeDays(r.Weekdays), r.Time, strings.Join(actions, "、"))
+}
+
+func describeDays(weekdays []int) string {
+	if len(weekdays) == 0 || len(weekdays) == 7 {
+		return "每天"
+	}
+	names := make([]string, 0, len(weekdays))
+	for _, d := range weekdays {
+		names = append(names, weekdayNames[d])
+	}
+	return "每" + strings.Join(names, "、")
+}
+
+func parseClock(v string) (string, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(v))
+	if err != nil {
+		return "", fmt.Errorf("time must be HH:MM, got %q", v)
+	}
+	return t.Format("15:04"), nil
+}
+
+func normalizeWeekdays(in []int) ([]int, error) {
+	seen := make(map[int]struct{}, len(in))
+	out := make([]int, 0, len(in))
+	for _, d := range in {
+		if d < 1 || d > 7 {
+			return nil, fmt.Errorf("weekdays must be between 1 (Monday) and 7 (Sunday), got %d", d)
+		}
+		if _, ok := seen[d]; !ok {
+			seen[d] = struct{}{}
+			out = append(out, d)
+		}
+	}
+	sort.Ints(out)
+	if len(out) == 7 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// NextRun returns the first time strictly after after that falls on at
+// (HH:MM, server local time) on one of weekdays; no weekdays means any day.
+func NextRun(at string, weekdays []int, after time.Time) (time.Time, error) {
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("time must be HH:MM, got %q", at)
+	}
+	allowed := make(map[time.Weekday]struct{}, len(weekdays))
+	for _, d := range weekdays {
+		allowed[time.Weekday(d%7)] = struct{}{}
+	}
+	local := after.In(time.Local)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		candidate := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, time.Local)
+		if !candidate.After(after) {
+			continue
+		}
+		if _, ok := allowed[candidate.Weekday()]; len(allowed) == 0 || ok {
+			return candidate, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no run time for %s on %v", at, weekdays)
+}
+
+// Scheduler sends each routine's actions when it falls due.
+type Scheduler struct {
+	store     Store
+	publisher IntentActionPublisher
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+func NewScheduler(store Store, publisher IntentActionPublisher, logger *slog.Logger) *Scheduler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Scheduler{store: store, publisher: publisher, logger: logger, now: time.Now}
+}
+
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.RunDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunDue fires every routine that is due now.
+func (s *Scheduler) RunDue(ctx context.Context) {
+	now := s.now()
+	due, err := s.store.ListDueRoutines(ctx, now, 50)
+	if err != nil {
+		s.logger.Warn("list due routines failed", "error", err)
+		return
+	}
+	for _, r := range due {
+		s.fire(ctx, r, now)
+	}
+}
+
+// fire claims the run by advancing the routine first, so a run is sent once
+// across instances; a run missed while the server was down is skipped rather
+// than replayed late.
+func (s *Scheduler) fire(ctx context.Context, r domain.Routine, now time.Time) {
+	due, err := time.Parse(time.RFC3339Nano, r.NextRunAt)
+	if err != nil {
+		s.logger.Warn("invalid routine next run", "id", r.ID, "next_run_at", r.NextRunAt, "error", err)
+		return
+	}
+	next, err := NextRun(r.Time, r.Weekdays, now)
+	if err != nil {
+		s.logger.Warn("schedule routine failed", "id", r.ID, "error", err)
+		return
+	}
+	if err := s.store.AdvanceRoutine(ctx, r.ID, due, next); err != nil {
+		if !errors.Is(err, db.ErrRoutineNotFound) {
+			s.logger.Warn("claim routine failed", "id", r.ID, "error", err)
+		}
+		return
+	}
+	if now.Sub(due) > time.Hour {
+		s.logger.Info("routine run skipped as stale", "id", r.ID, "due_at", r.NextRunAt)
+		return
+	}
+
+	payload := domain.IntentActionPayload{
+		RequestID:       fmt.Sprintf("rt-%d-%d", r.ID, due.Unix()),
+		SessionID:       r.SessionID,
+		TerminalID:      r.TerminalID,
+		SoulID:          r.SoulID,
+		Intents:         r.Actions,
+		ExecProbability: 1,
+		TS:              now.UTC().Format(time.RFC3339Nano),
+	}
+	if err := s.publisher.PublishIntentAction(ctx, r.TerminalID, payload); err != nil {
+		s.logger.Warn("publish routine failed", "id", r.ID, "terminal_id", r.TerminalID, "error", err)
+		return
+	}
+	s.logger.Info("routine fired", "id", r.ID, "terminal_id", r.TerminalID, "next_run_at", next)
+}
@@ -0,0 +1,130 @@
+package routines
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+	"soul/internal/skills"
+)
+
+type memoryStore struct {
+	items []domain.Routine
+}
+
+func (s *memoryStore) CreateRoutine(_ context.Context, r domain.Routine, next time.Time) (domain.Routine, error) {
+	r.ID = int64(len(s.items) + 1)
+	r.NextRunAt = next.UTC().Format(time.RFC3339Nano)
+	s.items = append(s.items, r)
+	return r, nil
+}
+
+func (s *memoryStore) ListDueRoutines(_ context.Context, now time.Time, _ int) ([]domain.Routine, error) {
+	var out []domain.Routine
+	for _, r := range s.items {
+		if next, _ := time.Parse(time.RFC3339Nano, r.NextRunAt); !next.After(now) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) AdvanceRoutine(_ context.Context, id int64, due, next time.Time) error {
+	for i := range s.items {
+		current, _ := time.Parse(time.RFC3339Nano, s.items[i].NextRunAt)
+		if s.items[i].ID == id && current.Equal(due) {
+			s.items[i].LastRunAt = due.UTC().Format(time.RFC3339Nano)
+			s.items[i].NextRunAt = next.UTC().Format(time.RFC3339Nano)
+			return nil
+		}
+	}
+	return db.ErrRoutineNotFound
+}
+
+type skillSet map[string]bool
+
+func (s skillSet) FindSkill(_, name string) (domain.SkillDefinition, bool) {
+	return domain.SkillDefinition{Name: name}, s[name]
+}
+
+type recordingPublisher struct {
+	payloads []domain.IntentActionPayload
+}
+
+func (p *recordingPublisher) PublishIntentAction(_ context.Context, _ string, payload domain.IntentActionPayload) error {
+	p.payloads = append(p.payloads, payload)
+	return nil
+}
+
+func TestNextRun(t *testing.T) {
+	// 2026-10-16 is a Friday.
+	after := time.Date(2026, 10, 16, 21, 0, 0, 0, time.Local)
+	cases := []struct {
+		at       string
+		weekdays []int
+		want     time.Time
+	}{
+		{"22:00", nil, time.Date(2026, 10, 16, 22, 0, 0, 0, time.Local)},
+		{"21:00", nil, time.Date(2026, 10, 17, 21, 0, 0, 0, time.Local)},
+		{"07:00", []int{1, 2, 3, 4, 5}, time.Date(2026, 10, 19, 7, 0, 0, 0, time.Local)},
+		{"22:00", []int{5}, time.Date(2026, 10, 16, 22, 0, 0, 0, time.Local)},
+		{"20:00", []int{5}, time.Date(2026, 10, 23, 20, 0, 0, 0, time.Local)},
+		{"09:30", []int{7}, time.Date(2026, 10, 18, 9, 30, 0, 0, time.Local)},
+	}
+	for _, c := range cases {
+		got, err := NextRun(c.at, c.weekdays, after)
+		if err != nil || !got.Equal(c.want) {
+			t.Fatalf("NextRun(%s, %v) = %v, %v; want %v", c.at, c.weekdays, got, err, c.want)
+		}
+	}
+	if _, err := NextRun("25:00", nil, after); err == nil {
+		t.Fatalf("expected invalid time to fail")
+	}
+}
+
+func TestCreateRoutineFromChatAndFire(t *testing.T) {
+	store := &memoryStore{}
+	creator := NewCreator(store, skillSet{"control_light": true})
+	creator.now = func() time.Time { return time.Date(2026, 10, 16, 21, 0, 0, 0, time.Local) }
+
+	ctx := skills.WithCaller(context.Background(), skills.Caller{UserID: "u1", SessionID: "s1", SoulID: "soul-1"})
+	args := json.RawMessage(`{"name":"每晚关灯","time":"22:00","actions":[{"skill":"control_light","arguments":{"mode":"off"}}]}`)
+	result, err := creator.InvokeSkill(ctx, "t1", args)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !strings.Contains(result.Output, "每天 22:00 执行 control_light（mode=off）") {
+		t.Fatalf("output must restate the rule, got %q", result.Output)
+	}
+	if !strings.Contains(result.Confirmation, "每晚关灯") || !strings.Contains(result.Confirmation, "10月16日 22:00") {
+		t.Fatalf("unexpected confirmation %q", result.Confirmation)
+	}
+	if len(store.items) != 1 || store.items[0].UserID != "u1" || store.items[0].SessionID != "s1" {
+		t.Fatalf("unexpected stored routine %+v", store.items)
+	}
+
+	if _, err := creator.InvokeSkill(ctx, "t1", json.RawMessage(`{"name":"x","time":"22:00","actions":[{"skill":"unknown"}]}`)); err == nil {
+		t.Fatalf("expected unknown skill to be rejected")
+	}
+
+	publisher := &recordingPublisher{}
+	scheduler := NewScheduler(store, publisher, nil)
+	scheduler.now = func() time.Time { return time.Date(2026, 10, 16, 22, 0, 5, 0, time.Local) }
+	scheduler.RunDue(context.Background())
+	scheduler.RunDue(context.Background())
+
+	if len(publisher.payloads) != 1 {
+		t.Fatalf("expected one intent_action, got %d", len(publisher.payloads))
+	}
+	action := publisher.payloads[0]
+	if action.SessionID != "s1" || len(action.Intents) != 1 || action.Intents[0].Normalized["skill"] != "control_light" || action.Intents[0].Normalized["mode"] != "off" {
+		t.Fatalf("unexpected intent_action %+v", action)
+	}
+	if want := time.Date(2026, 10, 17, 22, 0, 0, 0, time.Local).UTC().Format(time.RFC3339Nano); store.items[0].NextRunAt != want {
+		t.Fatalf("next run = %s, want %s", store.items[0].NextRunAt, want)
+	}
+}
@@ -0,0 +1,85 @@
+package safety
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ModerationClient calls an OpenAI-compatible moderation endpoint
+// (POST {"model","input"} -> {"results":[{"flagged","categories"}]}).
+type ModerationClient struct {
+	url    string
+	apiKey string
+	model  string
+	http   *http.Client
+}
+
+func NewModerationClient(url, apiKey, model string, timeout time.Duration) *ModerationClient {
+	if timeout <= 0 {
+		timeout = 1500 * time.Millisecond
+	}
+	return &ModerationClient{
+		url:    strings.TrimSpace(url),
+		apiKey: apiKey,
+		model:  strings.TrimSpace(model),
+		http:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *ModerationClient) Moderate(ctx context.Context, text string) (Verdict, error) {
+	payload := map[string]any{"input": text}
+	if c.model != "" {
+		payload["model"] = c.model
+	}
+	body, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return Verdict{}, fmt.Errorf("moderation status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var out struct {
+		Results []struct {
+			Flagged    bool            `json:"flagged"`
+			Categories map[string]bool `json:"categories"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil {
+		return Verdict{}, err
+	}
+	for _, result := range out.Results {
+		if !result.Flagged {
+			continue
+		}
+		flagged := make([]string, 0, len(result.Categories))
+		for name, hit := range result.Categories {
+			if hit {
+				flagged = append(flagged, name)
+			}
+		}
+		sort.Strings(flagged)
+		return Verdict{Blocked: true, Source: SourceModeration, Category: strings.Join(flagged, ",")}, nil
+	}
+	return Verdict{}, nil
+}
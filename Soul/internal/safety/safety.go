@@ -0,0 +1,163 @@
+// Package safety screens LLM output before it is stored, spoken or sent to
+// a terminal: the reply and the arguments of every skill call are checked
+// against a blocklist and, when configured, a moderation API. Blocked output
+// is replaced with a refusal and recorded as an incident.
+package safety
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"unicode"
+
+	"soul/internal/domain"
+)
+
+const DefaultRefusal = "这个我不能帮你，我们聊点别的吧。"
+
+const (
+	SourceBlocklist  = "blocklist"
+	SourceModeration = "moderation"
+)
+
+// Verdict is the outcome of a check. Category is the blocklist term or the
+// moderation category that matched.
+type Verdict struct {
+	Blocked  bool
+	Source   string
+	Category string
+}
+
+// Moderator asks an external service whether text is unsafe.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (Verdict, error)
+}
+
+type Store interface {
+	InsertSafetyIncident(ctx context.Context, in domain.SafetyIncident) (domain.SafetyIncident, error)
+}
+
+type Config struct {
+	Blocklist []string
+	// Moderator is consulted after the blocklist; nil skips it.
+	Moderator Moderator
+	// Mask is applied to text before it goes to the moderator, e.g. the
+	// personal data redactor; nil sends it as is.
+	Mask    func(string) string
+	Refusal string
+}
+
+// Filter checks text and records incidents. A nil Filter blocks nothing.
+type Filter struct {
+	terms     []string
+	moderator Moderator
+	mask      func(string) string
+	refusal   string
+	store     Store
+	logger    *slog.Logger
+}
+
+// New returns nil when neither a blocklist nor a moderator is configured.
+func New(cfg Config, store Store, logger *slog.Logger) *Filter {
+	terms := make([]string, 0, len(cfg.Blocklist))
+	for _, term := range cfg.Blocklist {
+		if term = normalize(term); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 && cfg.Moderator == nil {
+		return nil
+	}
+	refusal := strings.TrimSpace(cfg.Refusal)
+	if refusal == "" {
+		refusal = DefaultRefusal
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Filter{terms: terms, moderator: cfg.Moderator, mask: cfg.Mask, refusal: refusal, store: store, logger: logger}
+}
+
+// Check screens text. A moderator that fails lets the text through, so an
+// outage of the moderation service does not take chat down with it.
+func (f *Filter) Check(ctx context.Context, text string) Verdict {
+	if f == nil || strings.TrimSpace(text) == "" {
+		return Verdict{}
+	}
+	normalized := normalize(text)
+	for _, term := range f.terms {
+		if strings.Contains(normalized, term) {
+			return Verdict{Blocked: true, Source: SourceBlocklist, Category: term}
+		}
+	}
+	if f.moderator == nil {
+		return Verdict{}
+	}
+	if f.mask != nil {
+		text = f.mask(text)
+	}
+	v, err := f.moderator.Moderate(ctx, text)
+	if err != nil {
+		f.logger.Warn("moderation failed, output allowed", "error", err)
+		return Verdict{}
+	}
+	return v
+}
+
+// Refusal is the reply that replaces blocked output.
+func (f *Filter) Refusal() string {
+	if f == nil {
+		return DefaultRefusal
+	}
+	return f.refusal
+}
+
+// Record logs the incident and stores it; a failed write is only logged.
+func (f *Filter) Record(ctx context.Context, in domain.SafetyIncident) {
+	if f == nil {
+		return
+	}
+	f.logger.Warn("unsafe output blocked", "session_id", in.SessionID, "terminal_id", in.TerminalID, "stage", in.Stage, "skill", in.Skill, "source", in.Source, "category", in.Category)
+	if f.store == nil {
+		return
+	}
+	if _, err := f.store.InsertSafetyIncident(ctx, in); err != nil {
+		f.logger.Warn("record safety incident failed", "session_id", in.SessionID, "error", err)
+	}
+}
+
+// LoadBlocklist reads one term per line; blank lines and lines starting
+// with # are skipped.
+func LoadBlocklist(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var out []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out, scanner.Err()
+}
+
+// normalize lowercases text and drops spaces and punctuation, so "炸 药" and
+// "炸-药" still match the term "炸药".
+func normalize(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
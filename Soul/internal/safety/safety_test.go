@@ -0,0 +1,96 @@
+package safety
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+type memoryStore struct {
+	incidents []domain.SafetyIncident
+}
+
+func (s *memoryStore) InsertSafetyIncident(_ context.Context, in domain.SafetyIncident) (domain.SafetyIncident, error) {
+	s.incidents = append(s.incidents, in)
+	return in, nil
+}
+
+func TestBlocklistIgnoresSpacingAndCase(t *testing.T) {
+	f := New(Config{Blocklist: []string{"炸药", "Kill Switch"}}, nil, discard)
+	for _, text := range []string{"教你做炸 药", "炸-药配方", "turn the KILL-SWITCH on"} {
+		if v := f.Check(context.Background(), text); !v.Blocked || v.Source != SourceBlocklist {
+			t.Errorf("Check(%q) = %+v, want blocked by blocklist", text, v)
+		}
+	}
+	if v := f.Check(context.Background(), "今天天气不错"); v.Blocked {
+		t.Fatalf("harmless text blocked: %+v", v)
+	}
+}
+
+func TestNewWithoutRulesIsNil(t *testing.T) {
+	f := New(Config{Blocklist: []string{" ", ""}}, nil, discard)
+	if f != nil {
+		t.Fatal("expected no filter without rules")
+	}
+	if v := f.Check(context.Background(), "anything"); v.Blocked {
+		t.Fatal("nil filter must not block")
+	}
+	if f.Refusal() != DefaultRefusal {
+		t.Fatalf("unexpected refusal %q", f.Refusal())
+	}
+}
+
+func TestModerationClient(t *testing.T) {
+	var gotInput string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotInput = body.Input
+		if body.Input == "ok" {
+			_, _ = io.WriteString(w, `{"results":[{"flagged":false,"categories":{"violence":false}}]}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"results":[{"flagged":true,"categories":{"violence":true,"self-harm":true,"sexual":false}}]}`)
+	}))
+	defer srv.Close()
+
+	store := &memoryStore{}
+	f := New(Config{
+		Moderator: NewModerationClient(srv.URL, "", "", time.Second),
+		Mask:      func(string) string { return "masked" },
+	}, store, discard)
+	v := f.Check(context.Background(), "something bad")
+	if !v.Blocked || v.Source != SourceModeration || v.Category != "self-harm,violence" {
+		t.Fatalf("unexpected verdict %+v", v)
+	}
+	if gotInput != "masked" {
+		t.Fatalf("moderator got %q, want the masked text", gotInput)
+	}
+	f.Record(context.Background(), domain.SafetyIncident{SessionID: "s1", Stage: "reply", Source: v.Source})
+	if len(store.incidents) != 1 || store.incidents[0].SessionID != "s1" {
+		t.Fatalf("incident not stored: %+v", store.incidents)
+	}
+}
+
+func TestModerationFailureAllowsText(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	f := New(Config{Moderator: NewModerationClient(srv.URL, "", "", time.Second)}, nil, discard)
+	if v := f.Check(context.Background(), "hello"); v.Blocked {
+		t.Fatalf("moderation outage must not block: %+v", v)
+	}
+}
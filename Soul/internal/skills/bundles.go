@@ -0,0 +1,156 @@
+package skills
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// BundleStore persists skill bundles and which terminals enabled them.
+type BundleStore interface {
+	UpsertSkillBundle(ctx context.Context, bundle domain.SkillBundle) (domain.SkillBundle, error)
+	GetSkillBundle(ctx context.Context, name string) (domain.SkillBundle, error)
+	ListSkillBundles(ctx context.Context) ([]domain.SkillBundle, error)
+	EnableTerminalSkillBundle(ctx context.Context, terminalID, name string) error
+	DisableTerminalSkillBundle(ctx context.Context, terminalID, name string) (bool, error)
+	ListEnabledSkillBundles(ctx context.Context) (map[string][]domain.SkillBundle, error)
+}
+
+// Marketplace publishes skill bundles and keeps the registry's view of each
+// terminal's enabled bundles in step with the store.
+type Marketplace struct {
+	store    BundleStore
+	registry *Registry
+}
+
+func NewMarketplace(store BundleStore, registry *Registry) *Marketplace {
+	return &Marketplace{store: store, registry: registry}
+}
+
+var bundleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,63}$`)
+
+// ErrInvalidBundle wraps every reason Publish rejects a bundle.
+var ErrInvalidBundle = errors.New("invalid skill bundle")
+
+// Load pushes every stored enablement into the registry; call it at startup.
+func (m *Marketplace) Load(ctx context.Context) error {
+	enabled, err := m.store.ListEnabledSkillBundles(ctx)
+	if err != nil {
+		return err
+	}
+	for terminalID, bundles := range enabled {
+		m.registry.SetBundles(terminalID, bundles)
+	}
+	return nil
+}
+
+// Publish validates and stores a bundle, then refreshes the terminals that
+// have it enabled so they pick up the new version.
+func (m *Marketplace) Publish(ctx context.Context, name string, payload domain.PublishSkillBundlePayload) (domain.SkillBundle, error) {
+	bundle := domain.SkillBundle{
+		Name:        strings.TrimSpace(name),
+		Description: strings.TrimSpace(payload.Description),
+		Skills:      payload.Skills,
+		Intents:     payload.Intents,
+	}
+	if err := validateBundle(bundle); err != nil {
+		return domain.SkillBundle{}, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	stored, err := m.store.UpsertSkillBundle(ctx, bundle)
+	if err != nil {
+		return domain.SkillBundle{}, err
+	}
+	if err := m.Load(ctx); err != nil {
+		return domain.SkillBundle{}, err
+	}
+	return stored, nil
+}
+
+// Subscribers lists the terminals that have a bundle enabled.
+func (m *Marketplace) Subscribers(name string) []string {
+	return m.registry.TerminalsWithBundle(name)
+}
+
+func (m *Marketplace) Get(ctx context.Context, name string) (domain.SkillBundle, error) {
+	return m.store.GetSkillBundle(ctx, strings.TrimSpace(name))
+}
+
+func (m *Marketplace) List(ctx context.Context) ([]domain.SkillBundle, error) {
+	return m.store.ListSkillBundles(ctx)
+}
+
+// Enable turns a bundle on for a terminal and returns its enabled bundles.
+func (m *Marketplace) Enable(ctx context.Context, terminalID, name string) ([]domain.SkillBundle, error) {
+	if err := m.store.EnableTerminalSkillBundle(ctx, terminalID, strings.TrimSpace(name)); err != nil {
+		return nil, err
+	}
+	return m.refresh(ctx, terminalID)
+}
+
+// Disable turns a bundle off for a terminal and returns its enabled bundles.
+func (m *Marketplace) Disable(ctx context.Context, terminalID, name string) ([]domain.SkillBundle, error) {
+	if _, err := m.store.DisableTerminalSkillBundle(ctx, terminalID, strings.TrimSpace(name)); err != nil {
+		return nil, err
+	}
+	return m.refresh(ctx, terminalID)
+}
+
+// Enabled returns the bundles a terminal should install.
+func (m *Marketplace) Enabled(terminalID string) []domain.SkillBundle {
+	return m.registry.GetBundles(terminalID)
+}
+
+func (m *Marketplace) refresh(ctx context.Context, terminalID string) ([]domain.SkillBundle, error) {
+	enabled, err := m.store.ListEnabledSkillBundles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.registry.SetBundles(terminalID, enabled[terminalID])
+	return m.registry.GetBundles(terminalID), nil
+}
+
+func validateBundle(bundle domain.SkillBundle) error {
+	if !bundleNamePattern.MatchString(bundle.Name) {
+		return fmt.Errorf("bundle name must match %s", bundleNamePattern)
+	}
+	if len(bundle.Skills) == 0 {
+		return fmt.Errorf("bundle %s has no skills", bundle.Name)
+	}
+	skillNames := make(map[string]struct{}, len(bundle.Skills))
+	for i, skill := range bundle.Skills {
+		skillName := strings.TrimSpace(skill.Name)
+		if skillName == "" {
+			return fmt.Errorf("skills[%d]: name is required", i)
+		}
+		if _, dup := skillNames[skillName]; dup {
+			return fmt.Errorf("skills[%d]: duplicate skill %s", i, skillName)
+		}
+		skillNames[skillName] = struct{}{}
+		if len(skill.InputSchema) > 0 {
+			var schema map[string]any
+			if err := json.Unmarshal(skill.InputSchema, &schema); err != nil {
+				return fmt.Errorf("skills[%d]: input_schema must be a JSON object: %w", i, err)
+			}
+		}
+		if err := validateSkillEndpoint(skill); err != nil {
+			return fmt.Errorf("skills[%d]: %w", i, err)
+		}
+	}
+	intentIDs := make(map[string]struct{}, len(bundle.Intents))
+	for i, spec := range bundle.Intents {
+		id := strings.TrimSpace(spec.ID)
+		if id == "" {
+			return fmt.Errorf("intents[%d]: id is required", i)
+		}
+		if _, dup := intentIDs[id]; dup {
+			return fmt.Errorf("intents[%d]: duplicate intent %s", i, id)
+		}
+		intentIDs[id] = struct{}{}
+	}
+	return nil
+}
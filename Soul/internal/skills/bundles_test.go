@@ -0,0 +1,108 @@
+package skills
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+type memoryBundleStore struct {
+	bundles map[string]domain.SkillBundle
+	enabled map[string][]string
+}
+
+func (s *memoryBundleStore) UpsertSkillBundle(_ context.Context, bundle domain.SkillBundle) (domain.SkillBundle, error) {
+	bundle.Version = s.bundles[bundle.Name].Version + 1
+	s.bundles[bundle.Name] = bundle
+	return bundle, nil
+}
+
+func (s *memoryBundleStore) GetSkillBundle(_ context.Context, name string) (domain.SkillBundle, error) {
+	bundle, ok := s.bundles[name]
+	if !ok {
+		return domain.SkillBundle{}, errors.New("not found")
+	}
+	return bundle, nil
+}
+
+func (s *memoryBundleStore) ListSkillBundles(context.Context) ([]domain.SkillBundle, error) {
+	out := make([]domain.SkillBundle, 0, len(s.bundles))
+	for _, bundle := range s.bundles {
+		out = append(out, bundle)
+	}
+	return out, nil
+}
+
+func (s *memoryBundleStore) EnableTerminalSkillBundle(ctx context.Context, terminalID, name string) error {
+	if _, err := s.GetSkillBundle(ctx, name); err != nil {
+		return err
+	}
+	s.enabled[terminalID] = append(s.enabled[terminalID], name)
+	return nil
+}
+
+func (s *memoryBundleStore) DisableTerminalSkillBundle(_ context.Context, terminalID, name string) (bool, error) {
+	names := s.enabled[terminalID]
+	for i, n := range names {
+		if n == name {
+			s.enabled[terminalID] = append(names[:i], names[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryBundleStore) ListEnabledSkillBundles(context.Context) (map[string][]domain.SkillBundle, error) {
+	out := make(map[string][]domain.SkillBundle)
+	for terminalID, names := range s.enabled {
+		for _, name := range names {
+			out[terminalID] = append(out[terminalID], s.bundles[name])
+		}
+	}
+	return out, nil
+}
+
+func TestMarketplacePublishEnableAndRepublish(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryBundleStore{bundles: map[string]domain.SkillBundle{}, enabled: map[string][]string{}}
+	registry := NewRegistry(time.Minute)
+	m := NewMarketplace(store, registry)
+
+	for name, payload := range map[string]domain.PublishSkillBundlePayload{
+		"Bad-Name": {Skills: []domain.SkillDefinition{{Name: "a"}}},
+		"empty":    {},
+		"dup":      {Skills: []domain.SkillDefinition{{Name: "a"}, {Name: "a"}}},
+		"schema":   {Skills: []domain.SkillDefinition{{Name: "a", InputSchema: json.RawMessage(`[1]`)}}},
+	} {
+		if _, err := m.Publish(ctx, name, payload); !errors.Is(err, ErrInvalidBundle) {
+			t.Fatalf("%s: expected ErrInvalidBundle, got %v", name, err)
+		}
+	}
+
+	if _, err := m.Publish(ctx, "home", domain.PublishSkillBundlePayload{Skills: []domain.SkillDefinition{{Name: "curtain_open"}}}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	enabled, err := m.Enable(ctx, "t1", "home")
+	if err != nil || len(enabled) != 1 || enabled[0].Version != 1 {
+		t.Fatalf("enable: %+v %v", enabled, err)
+	}
+
+	bundle, err := m.Publish(ctx, "home", domain.PublishSkillBundlePayload{Skills: []domain.SkillDefinition{{Name: "curtain_open"}, {Name: "curtain_close"}}})
+	if err != nil || bundle.Version != 2 {
+		t.Fatalf("republish: %+v %v", bundle, err)
+	}
+	if got := m.Enabled("t1"); len(got) != 1 || got[0].Version != 2 || len(got[0].Skills) != 2 {
+		t.Fatalf("subscribers must see the new version: %+v", got)
+	}
+
+	if enabled, err := m.Disable(ctx, "t1", "home"); err != nil || len(enabled) != 0 {
+		t.Fatalf("disable: %+v %v", enabled, err)
+	}
+	if subs := m.Subscribers("home"); len(subs) != 0 {
+		t.Fatalf("unexpected subscribers after disable: %v", subs)
+	}
+}
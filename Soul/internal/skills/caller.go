@@ -0,0 +1,22 @@
+package skills
+
+import "context"
+
+// Caller identifies on whose behalf a skill runs. Server-side skills need it
+// to apply per-user policy; terminals never see it.
+type Caller struct {
+	UserID    string
+	SessionID string
+	SoulID    string
+}
+
+type callerKey struct{}
+
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func CallerFrom(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
@@ -0,0 +1,225 @@
+package skills
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// skillTokenEnvPrefix is the only prefix auth.token_env may name. Skill
+// definitions come from terminals and bundles, so an unrestricted name would
+// let any of them send a server secret such as LLM_API_KEY to its own host.
+const skillTokenEnvPrefix = "SKILL_TOKEN_"
+
+// maxHTTPSkillResponse caps how much of an HTTP skill's reply is read; the
+// output ends up in the LLM context, so anything larger is useless anyway.
+const maxHTTPSkillResponse = 64 << 10
+
+// HTTPConfig bounds which endpoints HTTP skills may reach. Skill definitions
+// come from terminals and bundles, so without an allow-list any of them could
+// make soul-server call into its own network.
+type HTTPConfig struct {
+	// AllowedHosts lists the hosts (host or host:port) skills may call; empty
+	// disables HTTP skills.
+	AllowedHosts []string
+	// AllowHTTP permits plain http URLs, for local development.
+	AllowHTTP bool
+	Timeout   time.Duration
+}
+
+// HTTPExecutor runs skills whose definition declares a URL.
+type HTTPExecutor struct {
+	allowed   map[string]struct{}
+	allowHTTP bool
+	http      *http.Client
+	getenv    func(string) string
+}
+
+func NewHTTPExecutor(cfg HTTPConfig) *HTTPExecutor {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 8 * time.Second
+	}
+	allowed := make(map[string]struct{}, len(cfg.AllowedHosts))
+	for _, host := range cfg.AllowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			allowed[host] = struct{}{}
+		}
+	}
+	return &HTTPExecutor{
+		allowed:   allowed,
+		allowHTTP: cfg.AllowHTTP,
+		http: &http.Client{
+			Timeout: cfg.Timeout,
+			// A redirect could leave the allow-list.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		getenv: os.Getenv,
+	}
+}
+
+// httpSkillRequest is the body POSTed to an HTTP skill.
+type httpSkillRequest struct {
+	RequestID  string          `json:"request_id"`
+	TerminalID string          `json:"terminal_id"`
+	Skill      string          `json:"skill"`
+	Arguments  json.RawMessage `json:"arguments"`
+}
+
+// Invoke POSTs the call to the skill's URL. A JSON reply shaped like
+// domain.InvokeResult is taken as is; any other 2xx body becomes the output.
+func (e *HTTPExecutor) Invoke(ctx context.Context, terminalID, requestID string, skill domain.SkillDefinition, args json.RawMessage) (domain.InvokeResult, error) {
+	target, err := e.checkURL(skill.URL)
+	if err != nil {
+		return domain.InvokeResult{}, err
+	}
+	if len(args) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+	body, err := json.Marshal(httpSkillRequest{RequestID: requestID, TerminalID: terminalID, Skill: skill.Name, Arguments: args})
+	if err != nil {
+		return domain.InvokeResult{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return domain.InvokeResult{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := e.authorize(req, skill.Auth); err != nil {
+		return domain.InvokeResult{}, err
+	}
+
+	resp, err := e.http.Do(req)
+	if err != nil {
+		return domain.InvokeResult{}, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxHTTPSkillResponse))
+	if resp.StatusCode >= 300 {
+		return domain.InvokeResult{}, fmt.Errorf("skill %s status=%d body=%s", skill.Name, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	result := domain.InvokeResult{RequestID: requestID, OK: true, Output: strings.TrimSpace(string(respBody))}
+	var structured struct {
+		OK     *bool  `json:"ok"`
+		Output string `json:"output"`
+		Error  string `json:"error"`
+	}
+	if json.Unmarshal(respBody, &structured) == nil && structured.OK != nil {
+		result.OK = *structured.OK
+		result.Output = structured.Output
+		result.Error = structured.Error
+	}
+	if !result.OK {
+		if result.Error == "" {
+			result.Error = "tool invocation failed"
+		}
+		return result, errors.New(result.Error)
+	}
+	return result, nil
+}
+
+func (e *HTTPExecutor) checkURL(raw string) (*url.URL, error) {
+	target, err := parseSkillURL(raw)
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme == "http" && !e.allowHTTP {
+		return nil, fmt.Errorf("skill url must use https")
+	}
+	if _, ok := e.allowed[strings.ToLower(target.Host)]; !ok {
+		if _, ok := e.allowed[strings.ToLower(target.Hostname())]; !ok {
+			return nil, fmt.Errorf("skill host %s is not allowed", target.Host)
+		}
+	}
+	return target, nil
+}
+
+func (e *HTTPExecutor) authorize(req *http.Request, auth *domain.SkillAuth) error {
+	if auth == nil {
+		return nil
+	}
+	if err := checkTokenEnv(auth.TokenEnv); err != nil {
+		return err
+	}
+	token := strings.TrimSpace(e.getenv(auth.TokenEnv))
+	if token == "" {
+		return fmt.Errorf("skill auth token %s is not set", auth.TokenEnv)
+	}
+	switch strings.TrimSpace(auth.Type) {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case "header":
+		req.Header.Set(auth.Header, token)
+	default:
+		return fmt.Errorf("unsupported skill auth type %q", auth.Type)
+	}
+	return nil
+}
+
+// parseSkillURL accepts absolute http(s) URLs; the scheme and host policy is
+// the executor's call.
+func parseSkillURL(raw string) (*url.URL, error) {
+	target, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid skill url: %w", err)
+	}
+	if (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+		return nil, fmt.Errorf("skill url must be an absolute http(s) url")
+	}
+	return target, nil
+}
+
+// validateSkillEndpoint checks the url and auth of a definition without
+// reaching the network.
+func validateSkillEndpoint(skill domain.SkillDefinition) error {
+	if strings.TrimSpace(skill.URL) == "" {
+		if skill.Auth != nil {
+			return fmt.Errorf("auth requires url")
+		}
+		return nil
+	}
+	if _, err := parseSkillURL(skill.URL); err != nil {
+		return err
+	}
+	if skill.Auth == nil {
+		return nil
+	}
+	if err := checkTokenEnv(skill.Auth.TokenEnv); err != nil {
+		return err
+	}
+	switch strings.TrimSpace(skill.Auth.Type) {
+	case "bearer":
+	case "header":
+		if strings.TrimSpace(skill.Auth.Header) == "" {
+			return fmt.Errorf("auth.header is required for header auth")
+		}
+	default:
+		return fmt.Errorf("auth.type must be bearer or header")
+	}
+	return nil
+}
+
+// checkTokenEnv accepts only environment variables set aside for skill
+// tokens, e.g. SKILL_TOKEN_CALENDAR.
+func checkTokenEnv(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("auth.token_env is required")
+	}
+	rest, ok := strings.CutPrefix(name, skillTokenEnvPrefix)
+	if !ok || rest == "" || strings.IndexFunc(rest, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_')
+	}) >= 0 {
+		return fmt.Errorf("auth.token_env must be %s followed by upper-case letters, digits or underscores", skillTokenEnvPrefix)
+	}
+	return nil
+}
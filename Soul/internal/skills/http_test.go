@@ -0,0 +1,115 @@
+package skills
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+type recordingTerminal struct {
+	calls []string
+}
+
+func (t *recordingTerminal) InvokeSkill(_ context.Context, _ string, skill string, _ json.RawMessage) (domain.InvokeResult, error) {
+	t.calls = append(t.calls, skill)
+	return domain.InvokeResult{OK: true, Output: "terminal"}, nil
+}
+
+func TestRouterExecutesURLSkillsOnServer(t *testing.T) {
+	var got httpSkillRequest
+	var authHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authHeader = req.Header.Get("Authorization")
+		_ = json.NewDecoder(req.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"ok":true,"output":"邮件已发送"}`))
+	}))
+	defer srv.Close()
+	host := mustHost(t, srv.URL)
+
+	registry := NewRegistry(time.Minute)
+	registry.SetSkills("t1", "soul_a", 1, []domain.SkillDefinition{
+		{Name: "light_on"},
+		{Name: "send_mail", URL: srv.URL + "/send", Auth: &domain.SkillAuth{Type: "bearer", TokenEnv: "SKILL_TOKEN_MAIL"}},
+		{Name: "elsewhere", URL: "http://example.invalid/x"},
+	})
+	executor := NewHTTPExecutor(HTTPConfig{AllowedHosts: []string{host}, AllowHTTP: true})
+	executor.getenv = func(key string) string {
+		if key == "SKILL_TOKEN_MAIL" {
+			return "secret"
+		}
+		return ""
+	}
+	terminal := &recordingTerminal{}
+	router := NewRouter(registry, terminal, executor)
+
+	result, err := router.InvokeSkill(context.Background(), "t1", "send_mail", json.RawMessage(`{"to":"a@b.c"}`))
+	if err != nil || result.Output != "邮件已发送" {
+		t.Fatalf("unexpected result: %+v %v", result, err)
+	}
+	if got.Skill != "send_mail" || got.TerminalID != "t1" || string(got.Arguments) != `{"to":"a@b.c"}` || authHeader != "Bearer secret" {
+		t.Fatalf("unexpected request: %+v auth=%q", got, authHeader)
+	}
+
+	if _, err := router.InvokeSkill(context.Background(), "t1", "elsewhere", nil); err == nil {
+		t.Fatal("host outside the allow-list must be refused")
+	}
+	if _, err := router.InvokeSkill(context.Background(), "t1", "light_on", nil); err != nil || len(terminal.calls) != 1 || terminal.calls[0] != "light_on" {
+		t.Fatalf("skills without url must go to the terminal: %v %v", terminal.calls, err)
+	}
+}
+
+func TestHTTPExecutorRejectsPlainHTTPAndFailedResults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":false,"error":"quota exceeded"}`))
+	}))
+	defer srv.Close()
+	skill := domain.SkillDefinition{Name: "s", URL: srv.URL}
+
+	strict := NewHTTPExecutor(HTTPConfig{AllowedHosts: []string{mustHost(t, srv.URL)}})
+	if _, err := strict.Invoke(context.Background(), "t1", "r1", skill, nil); err == nil {
+		t.Fatal("plain http must be refused unless allowed")
+	}
+	lax := NewHTTPExecutor(HTTPConfig{AllowedHosts: []string{mustHost(t, srv.URL)}, AllowHTTP: true})
+	result, err := lax.Invoke(context.Background(), "t1", "r1", skill, nil)
+	if err == nil || result.OK || result.Error != "quota exceeded" {
+		t.Fatalf("a failed result must surface as an error: %+v %v", result, err)
+	}
+}
+
+func TestTokenEnvIsRestrictedToSkillTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("a skill naming a server secret must not be called")
+	}))
+	defer srv.Close()
+
+	for _, name := range []string{"LLM_API_KEY", "DB_DSN", "SKILL_TOKEN_", "skill_token_mail", "SKILL_TOKEN_MAIL-2"} {
+		skill := domain.SkillDefinition{Name: "s", URL: srv.URL, Auth: &domain.SkillAuth{Type: "bearer", TokenEnv: name}}
+		if err := validateSkillEndpoint(skill); err == nil {
+			t.Errorf("validateSkillEndpoint accepted token_env %q", name)
+		}
+		executor := NewHTTPExecutor(HTTPConfig{AllowedHosts: []string{mustHost(t, srv.URL)}, AllowHTTP: true})
+		executor.getenv = func(string) string { return "secret" }
+		if _, err := executor.Invoke(context.Background(), "t1", "r1", skill, nil); err == nil {
+			t.Errorf("Invoke sent token_env %q", name)
+		}
+	}
+	ok := domain.SkillDefinition{Name: "s", URL: srv.URL, Auth: &domain.SkillAuth{Type: "bearer", TokenEnv: "SKILL_TOKEN_CALENDAR_2"}}
+	if err := validateSkillEndpoint(ok); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustHost(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
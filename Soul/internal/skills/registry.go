@@ -1,6 +1,7 @@
 package skills
 
 import (
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -15,14 +16,43 @@ type TerminalSkillState struct {
 	Skills         []domain.SkillDefinition
 	CatalogVersion int64
 	IntentCatalog  []domain.IntentSpec
-	Online         bool
-	LastUpdated    time.Time
+	Output         *domain.TerminalOutputCapabilities
+	Capabilities   *domain.TerminalCapabilities
+	// FirmwareVersion and BundleVersions are what the terminal last
+	// reported; BundleVersions is nil when it reports none.
+	FirmwareVersion string
+	BundleVersions  map[string]int64
+	Online          bool
+	LastUpdated     time.Time
+}
+
+// IntentCatalogUpdate reports the outcome of SetIntentCatalog. A stale report
+// (older than the applied version) is not applied and leaves the diff empty.
+type IntentCatalogUpdate struct {
+	Applied         bool
+	PreviousVersion int64
+	Version         int64
+	Added           []string
+	Removed         []string
 }
 
 type Registry struct {
 	mu       sync.RWMutex
 	data     map[string]TerminalSkillState
 	skillTTL time.Duration
+	// dryRun is an operator setting, so it outlives skill snapshot expiry.
+	dryRun map[string]bool
+	// bundles are the skill bundles each terminal enabled; like dryRun they
+	// are server-side settings and outlive snapshots.
+	bundles map[string][]domain.SkillBundle
+	// stats are per terminal and skill; they outlive snapshots so a skill
+	// that keeps failing is still flagged after the terminal reconnects.
+	stats map[string]map[string]*skillStats
+	// serverSkills are offered to every terminal and run in soul-server.
+	serverSkills []domain.SkillDefinition
+	// minFirmware maps a capability to the lowest firmware allowed to run
+	// it; like dryRun it is a server-side setting.
+	minFirmware map[string]string
 }
 
 func NewRegistry(skillTTL time.Duration) *Registry {
@@ -30,8 +60,12 @@ func NewRegistry(skillTTL time.Duration) *Registry {
 		skillTTL = 60 * time.Second
 	}
 	return &Registry{
-		data:     make(map[string]TerminalSkillState),
-		skillTTL: skillTTL,
+		data:        make(map[string]TerminalSkillState),
+		skillTTL:    skillTTL,
+		dryRun:      make(map[string]bool),
+		bundles:     make(map[string][]domain.SkillBundle),
+		stats:       make(map[string]map[string]*skillStats),
+		minFirmware: make(map[string]string),
 	}
 }
 
@@ -58,25 +92,30 @@ func (r *Registry) SetSkills(terminalID, soulID string, skillVersion int64, skil
 		Skills:         skills,
 		CatalogVersion: current.CatalogVersion,
 		IntentCatalog:  append([]domain.IntentSpec{}, current.IntentCatalog...),
+		Output:         current.Output,
 		Online:         true,
 		LastUpdated:    time.Now(),
 	}
 }
 
-func (r *Registry) SetIntentCatalog(terminalID, soulID string, catalogVersion int64, catalog []domain.IntentSpec) {
+func (r *Registry) SetIntentCatalog(terminalID, soulID string, catalogVersion int64, catalog []domain.IntentSpec) IntentCatalogUpdate {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	current := r.data[terminalID]
+	update := IntentCatalogUpdate{PreviousVersion: current.CatalogVersion, Version: current.CatalogVersion}
 	if current.CatalogVersion > 0 && catalogVersion > 0 && catalogVersion < current.CatalogVersion {
-		return
+		return update
 	}
 	if current.CatalogVersion > 0 && catalogVersion == 0 {
-		return
+		return update
 	}
 	if catalogVersion == 0 {
 		catalogVersion = current.CatalogVersion
 	}
+	update.Applied = true
+	update.Version = catalogVersion
+	update.Added, update.Removed = diffIntentIDs(current.IntentCatalog, catalog)
 
 	r.data[terminalID] = TerminalSkillState{
 		TerminalID:     terminalID,
@@ -85,9 +124,92 @@ func (r *Registry) SetIntentCatalog(terminalID, soulID string, catalogVersion in
 		Skills:         append([]domain.SkillDefinition{}, current.Skills...),
 		CatalogVersion: catalogVersion,
 		IntentCatalog:  append([]domain.IntentSpec{}, catalog...),
+		Output:         current.Output,
 		Online:         true,
 		LastUpdated:    time.Now(),
 	}
+	return update
+}
+
+func diffIntentIDs(previous, next []domain.IntentSpec) ([]string, []string) {
+	before := make(map[string]struct{}, len(previous))
+	for _, spec := range previous {
+		before[spec.ID] = struct{}{}
+	}
+	after := make(map[string]struct{}, len(next))
+	var added []string
+	for _, spec := range next {
+		after[spec.ID] = struct{}{}
+		if _, ok := before[spec.ID]; !ok {
+			added = append(added, spec.ID)
+		}
+	}
+	var removed []string
+	for _, spec := range previous {
+		if _, ok := after[spec.ID]; !ok {
+			removed = append(removed, spec.ID)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+func (r *Registry) SetOutputCapabilities(terminalID string, output *domain.TerminalOutputCapabilities) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.data[terminalID]
+	state.TerminalID = terminalID
+	if output != nil {
+		copied := *output
+		state.Output = &copied
+	} else {
+		state.Output = nil
+	}
+	r.data[terminalID] = state
+}
+
+func (r *Registry) GetOutputCapabilities(terminalID string) *domain.TerminalOutputCapabilities {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state, ok := r.data[terminalID]
+	if !ok || state.Output == nil {
+		return nil
+	}
+	out := *state.Output
+	return &out
+}
+
+func (r *Registry) SetCapabilities(terminalID string, caps *domain.TerminalCapabilities) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.data[terminalID]
+	state.TerminalID = terminalID
+	state.Capabilities = copyCapabilities(caps)
+	r.data[terminalID] = state
+}
+
+func (r *Registry) GetCapabilities(terminalID string) *domain.TerminalCapabilities {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state, ok := r.data[terminalID]
+	if !ok {
+		return nil
+	}
+	return copyCapabilities(state.Capabilities)
+}
+
+func copyCapabilities(caps *domain.TerminalCapabilities) *domain.TerminalCapabilities {
+	if caps == nil {
+		return nil
+	}
+	out := *caps
+	out.Motors = append([]string(nil), caps.Motors...)
+	return &out
 }
 
 func (r *Registry) SetOnline(terminalID string, online bool) {
@@ -110,6 +232,58 @@ func (r *Registry) SetSoul(terminalID, soulID string) {
 	r.data[terminalID] = state
 }
 
+func (r *Registry) SetDryRun(terminalID string, enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if enabled {
+		r.dryRun[terminalID] = true
+		return
+	}
+	delete(r.dryRun, terminalID)
+}
+
+func (r *Registry) IsDryRun(terminalID string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.dryRun[terminalID]
+}
+
+// SetBundles replaces the bundles enabled on a terminal.
+func (r *Registry) SetBundles(terminalID string, bundles []domain.SkillBundle) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(bundles) == 0 {
+		delete(r.bundles, terminalID)
+		return
+	}
+	r.bundles[terminalID] = append([]domain.SkillBundle{}, bundles...)
+}
+
+func (r *Registry) GetBundles(terminalID string) []domain.SkillBundle {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]domain.SkillBundle{}, r.bundles[terminalID]...)
+}
+
+func (r *Registry) TerminalsWithBundle(name string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var out []string
+	for terminalID, bundles := range r.bundles {
+		for _, bundle := range bundles {
+			if bundle.Name == name {
+				out = append(out, terminalID)
+				break
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
 func (r *Registry) GetState(terminalID string) (TerminalSkillState, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -135,11 +309,55 @@ func (r *Registry) GetSkills(terminalID string) []domain.SkillDefinition {
 		return nil
 	}
 
+	// Enabled bundles extend what the terminal reported; a skill the
+	// terminal reports itself keeps its own definition.
 	out := make([]domain.SkillDefinition, len(state.Skills))
 	copy(out, state.Skills)
+	seen := make(map[string]struct{}, len(out))
+	for _, skill := range out {
+		seen[skill.Name] = struct{}{}
+	}
+	for _, bundle := range r.bundles[terminalID] {
+		for _, skill := range bundle.Skills {
+			if _, ok := seen[skill.Name]; !ok {
+				seen[skill.Name] = struct{}{}
+				out = append(out, skill)
+			}
+		}
+	}
+	for _, skill := range r.serverSkills {
+		if _, ok := seen[skill.Name]; !ok {
+			seen[skill.Name] = struct{}{}
+			out = append(out, skill)
+		}
+	}
 	return out
 }
 
+// AddServerSkill offers a skill to every online terminal after its reported
+// and bundled skills.
+func (r *Registry) AddServerSkill(skill domain.SkillDefinition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.serverSkills {
+		if existing.Name == skill.Name {
+			r.serverSkills[i] = skill
+			return
+		}
+	}
+	r.serverSkills = append(r.serverSkills, skill)
+}
+
+// FindSkill looks a skill up in what GetSkills would return.
+func (r *Registry) FindSkill(terminalID, name string) (domain.SkillDefinition, bool) {
+	for _, skill := range r.GetSkills(terminalID) {
+		if skill.Name == name {
+			return skill, true
+		}
+	}
+	return domain.SkillDefinition{}, false
+}
+
 func (r *Registry) GetIntentCatalog(terminalID string) []domain.IntentSpec {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -150,6 +368,18 @@ func (r *Registry) GetIntentCatalog(terminalID string) []domain.IntentSpec {
 	}
 	out := make([]domain.IntentSpec, len(state.IntentCatalog))
 	copy(out, state.IntentCatalog)
+	seen := make(map[string]struct{}, len(out))
+	for _, spec := range out {
+		seen[spec.ID] = struct{}{}
+	}
+	for _, bundle := range r.bundles[terminalID] {
+		for _, spec := range bundle.Intents {
+			if _, ok := seen[spec.ID]; !ok {
+				seen[spec.ID] = struct{}{}
+				out = append(out, spec)
+			}
+		}
+	}
 	return out
 }
 
@@ -0,0 +1,94 @@
+package skills
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestSetIntentCatalogVersioningAndDiff(t *testing.T) {
+	r := NewRegistry(time.Minute)
+
+	update := r.SetIntentCatalog("t1", "soul_a", 2, []domain.IntentSpec{{ID: "light_off"}, {ID: "reminder_create"}})
+	if !update.Applied || update.Version != 2 || strings.Join(update.Added, ",") != "light_off,reminder_create" || len(update.Removed) != 0 {
+		t.Fatalf("unexpected first update: %+v", update)
+	}
+
+	update = r.SetIntentCatalog("t1", "soul_a", 1, []domain.IntentSpec{{ID: "light_on"}})
+	if update.Applied || update.Version != 2 {
+		t.Fatalf("stale catalog must be rejected: %+v", update)
+	}
+	if got := r.GetIntentCatalog("t1"); len(got) != 2 {
+		t.Fatalf("stale catalog must not replace the applied one: %+v", got)
+	}
+
+	update = r.SetIntentCatalog("t1", "soul_a", 3, []domain.IntentSpec{{ID: "light_off"}, {ID: "music_play"}})
+	if !update.Applied || update.PreviousVersion != 2 || update.Version != 3 {
+		t.Fatalf("unexpected versions: %+v", update)
+	}
+	if strings.Join(update.Added, ",") != "music_play" || strings.Join(update.Removed, ",") != "reminder_create" {
+		t.Fatalf("unexpected diff: added=%v removed=%v", update.Added, update.Removed)
+	}
+}
+
+func TestGetSkillsMergesEnabledBundles(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	r.SetSkills("t1", "soul_a", 1, []domain.SkillDefinition{{Name: "light_on", Description: "terminal"}})
+	r.SetBundles("t1", []domain.SkillBundle{{
+		Name:    "home",
+		Skills:  []domain.SkillDefinition{{Name: "light_on", Description: "bundle"}, {Name: "curtain_open"}},
+		Intents: []domain.IntentSpec{{ID: "curtain_open"}},
+	}})
+
+	r.AddServerSkill(domain.SkillDefinition{Name: "create_routine"})
+	r.AddServerSkill(domain.SkillDefinition{Name: "light_on", Description: "server"})
+
+	got := r.GetSkills("t1")
+	if len(got) != 3 || got[0].Description != "terminal" || got[1].Name != "curtain_open" || got[2].Name != "create_routine" {
+		t.Fatalf("terminal skill must win and bundles then server skills must add the rest: %+v", got)
+	}
+	if catalog := r.GetIntentCatalog("t1"); len(catalog) != 1 || catalog[0].ID != "curtain_open" {
+		t.Fatalf("unexpected intent catalog: %+v", catalog)
+	}
+	if subs := r.TerminalsWithBundle("home"); strings.Join(subs, ",") != "t1" {
+		t.Fatalf("unexpected subscribers: %v", subs)
+	}
+
+	r.SetOnline("t1", false)
+	if got := r.GetSkills("t1"); got != nil {
+		t.Fatalf("offline terminal must have no skills: %+v", got)
+	}
+}
+
+func TestRecordInvocationFlagsFlakySkills(t *testing.T) {
+	r := NewRegistry(time.Minute)
+	for i := 0; i < 7; i++ {
+		r.RecordInvocation("t1", "light_on", 100*time.Millisecond, nil)
+	}
+	r.RecordInvocation("t1", "volume_set", 50*time.Millisecond, nil)
+	r.RecordInvocation("t1", "volume_set", 2*time.Second, errors.New("tool timeout"))
+	r.RecordInvocation("t1", "volume_set", 3*time.Second, errors.New("tool timeout"))
+
+	stats := r.SkillStats("t1")
+	if len(stats) != 2 || stats[0].Skill != "light_on" || stats[0].Flaky || stats[0].SuccessRate != 1 || stats[0].AvgLatencyMS != 100 {
+		t.Fatalf("unexpected light_on stats: %+v", stats)
+	}
+	volume := stats[1]
+	if volume.Calls != 3 || volume.Failures != 2 || volume.MaxLatencyMS != 3000 || !volume.Flaky || volume.LastError != "tool timeout" {
+		t.Fatalf("unexpected volume_set stats: %+v", volume)
+	}
+	if flaky := r.FlakySkills("t1"); len(flaky) != 1 || flaky[0].Skill != "volume_set" {
+		t.Fatalf("unexpected flaky skills: %+v", flaky)
+	}
+
+	// Enough recent successes push the failures out of the window.
+	for i := 0; i < recentWindow; i++ {
+		r.RecordInvocation("t1", "volume_set", 50*time.Millisecond, nil)
+	}
+	if flaky := r.FlakySkills("t1"); len(flaky) != 0 {
+		t.Fatalf("recovered skill must no longer be flaky: %+v", flaky)
+	}
+}
@@ -0,0 +1,82 @@
+package skills
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"soul/internal/domain"
+)
+
+// TerminalInvoker runs a skill on the terminal itself; mqtt.Hub does it
+// over an MQTT round trip.
+type TerminalInvoker interface {
+	InvokeSkill(ctx context.Context, terminalID, skill string, args json.RawMessage) (domain.InvokeResult, error)
+}
+
+// ServerSkill is a skill soul-server implements itself.
+type ServerSkill func(ctx context.Context, terminalID string, args json.RawMessage) (domain.InvokeResult, error)
+
+// Router sends each skill call to where the skill runs: skills handled in
+// process or that declare a URL are executed by soul-server, everything else
+// by the terminal.
+type Router struct {
+	registry *Registry
+	terminal TerminalInvoker
+	http     *HTTPExecutor
+	handlers map[string]ServerSkill
+}
+
+// NewRouter builds a Router; a nil executor leaves every call to the
+// terminal.
+func NewRouter(registry *Registry, terminal TerminalInvoker, httpExecutor *HTTPExecutor) *Router {
+	return &Router{registry: registry, terminal: terminal, http: httpExecutor, handlers: make(map[string]ServerSkill)}
+}
+
+// Handle executes a skill in process. The terminal still has to report the
+// skill for the LLM to see it, and keeps its own implementation for when the
+// handler is not registered. Call it before the router is used.
+func (r *Router) Handle(skill string, handler ServerSkill) {
+	r.handlers[skill] = handler
+}
+
+// Provide registers a skill soul-server both defines and runs, so terminals
+// need not report it. Call it before the router is used.
+func (r *Router) Provide(skill domain.SkillDefinition, handler ServerSkill) {
+	r.registry.AddServerSkill(skill)
+	r.Handle(skill.Name, handler)
+}
+
+// InvokeSkill runs the skill and records its outcome in the registry's
+// per-skill statistics.
+func (r *Router) InvokeSkill(ctx context.Context, terminalID, skill string, args json.RawMessage) (domain.InvokeResult, error) {
+	start := time.Now()
+	result, err := r.route(ctx, terminalID, skill, args)
+	r.registry.RecordInvocation(terminalID, skill, time.Since(start), err)
+	return result, err
+}
+
+func (r *Router) route(ctx context.Context, terminalID, skill string, args json.RawMessage) (domain.InvokeResult, error) {
+	if handler, ok := r.handlers[skill]; ok {
+		return handler(ctx, terminalID, args)
+	}
+	if def, ok := r.registry.FindSkill(terminalID, skill); ok && def.URL != "" && r.http != nil {
+		return r.http.Invoke(ctx, terminalID, uuid.NewString(), def, args)
+	}
+	// Fail clearly instead of letting old firmware misread the call.
+	if up, ok := r.registry.UpgradeFor(terminalID, skill); ok {
+		msg := fmt.Sprintf("终端固件版本过低：%s 需要 %s 及以上，当前为 %s，请先升级。", skill, up.Required, displayVersion(up.Current))
+		return domain.InvokeResult{OK: false, Error: msg}, fmt.Errorf("%w: %s", ErrUpgradeRequired, msg)
+	}
+	return r.terminal.InvokeSkill(ctx, terminalID, skill, args)
+}
+
+func displayVersion(v string) string {
+	if v == "" {
+		return "未上报"
+	}
+	return v
+}
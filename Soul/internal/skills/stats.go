@@ -0,0 +1,118 @@
+package skills
+
+import (
+	"sort"
+	"time"
+
+	"soul/internal/domain"
+)
+
+// A skill is flaky once at least flakyMinCalls of its last recentWindow calls
+// ran and flakyFailureRate or more of them failed.
+const (
+	recentWindow     = 10
+	flakyMinCalls    = 3
+	flakyFailureRate = 0.3
+)
+
+type skillStats struct {
+	calls, successes, failures int64
+	totalLatency, maxLatency   time.Duration
+	// recent is a ring of the last recentWindow outcomes, true for failure.
+	recent        [recentWindow]bool
+	recentLen     int
+	recentNext    int
+	lastError     string
+	lastFailureAt time.Time
+	lastAt        time.Time
+}
+
+func (s *skillStats) recentFailures() int {
+	n := 0
+	for i := 0; i < s.recentLen; i++ {
+		if s.recent[i] {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *skillStats) flaky() bool {
+	return s.recentLen >= flakyMinCalls && float64(s.recentFailures()) >= flakyFailureRate*float64(s.recentLen)
+}
+
+// RecordInvocation counts one skill call and its outcome; err is nil on
+// success.
+func (r *Registry) RecordInvocation(terminalID, skill string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bySkill := r.stats[terminalID]
+	if bySkill == nil {
+		bySkill = make(map[string]*skillStats)
+		r.stats[terminalID] = bySkill
+	}
+	st := bySkill[skill]
+	if st == nil {
+		st = &skillStats{}
+		bySkill[skill] = st
+	}
+	now := time.Now()
+	st.calls++
+	st.totalLatency += latency
+	st.maxLatency = max(st.maxLatency, latency)
+	st.lastAt = now
+	st.recent[st.recentNext] = err != nil
+	st.recentNext = (st.recentNext + 1) % recentWindow
+	st.recentLen = min(st.recentLen+1, recentWindow)
+	if err != nil {
+		st.failures++
+		st.lastError = err.Error()
+		st.lastFailureAt = now
+		return
+	}
+	st.successes++
+}
+
+// SkillStats returns the terminal's per-skill statistics, sorted by skill.
+func (r *Registry) SkillStats(terminalID string) []domain.SkillStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]domain.SkillStats, 0, len(r.stats[terminalID]))
+	for skill, st := range r.stats[terminalID] {
+		item := domain.SkillStats{
+			Skill:            skill,
+			Calls:            st.calls,
+			Successes:        st.successes,
+			Failures:         st.failures,
+			MaxLatencyMS:     st.maxLatency.Milliseconds(),
+			RecentCalls:      st.recentLen,
+			RecentFailures:   st.recentFailures(),
+			Flaky:            st.flaky(),
+			LastError:        st.lastError,
+			LastInvocationAt: st.lastAt.UTC().Format(time.RFC3339Nano),
+		}
+		if st.calls > 0 {
+			item.SuccessRate = float64(st.successes) / float64(st.calls)
+			item.AvgLatencyMS = (st.totalLatency / time.Duration(st.calls)).Milliseconds()
+		}
+		if !st.lastFailureAt.IsZero() {
+			item.LastFailureAt = st.lastFailureAt.UTC().Format(time.RFC3339Nano)
+		}
+		out = append(out, item)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Skill < out[j].Skill })
+	return out
+}
+
+// FlakySkills returns the terminal's skills that have been failing lately.
+func (r *Registry) FlakySkills(terminalID string) []domain.SkillStats {
+	var out []domain.SkillStats
+	for _, item := range r.SkillStats(terminalID) {
+		if item.Flaky {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,263 @@
+package skills
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"soul/internal/domain"
+)
+
+// RequirementStore persists the minimum firmware per capability.
+type RequirementStore interface {
+	ListVersionRequirements(ctx context.Context) ([]domain.VersionRequirement, error)
+	UpsertVersionRequirement(ctx context.Context, in domain.VersionRequirement) (domain.VersionRequirement, error)
+	DeleteVersionRequirement(ctx context.Context, capability string) error
+}
+
+// ErrInvalidRequirement wraps every reason Rollout.Set rejects a requirement.
+var ErrInvalidRequirement = errors.New("invalid version requirement")
+
+// ErrUpgradeRequired is returned for a skill the terminal's firmware is too
+// old to run.
+var ErrUpgradeRequired = errors.New("terminal upgrade required")
+
+var firmwareVersionPattern = regexp.MustCompile(`^v?\d+(\.\d+)*$`)
+
+// Rollout keeps the registry's minimum firmware per capability in step with
+// the store.
+type Rollout struct {
+	store    RequirementStore
+	registry *Registry
+}
+
+func NewRollout(store RequirementStore, registry *Registry) *Rollout {
+	return &Rollout{store: store, registry: registry}
+}
+
+// Load pushes the stored requirements into the registry; call it at startup.
+func (r *Rollout) Load(ctx context.Context) error {
+	items, err := r.store.ListVersionRequirements(ctx)
+	if err != nil {
+		return err
+	}
+	r.registry.SetVersionRequirements(items)
+	return nil
+}
+
+func (r *Rollout) List(ctx context.Context) ([]domain.VersionRequirement, error) {
+	return r.store.ListVersionRequirements(ctx)
+}
+
+// Set stores the lowest firmware, dotted numbers like 1.4.2, that may run
+// the capability.
+func (r *Rollout) Set(ctx context.Context, capability, minFirmware string) (domain.VersionRequirement, error) {
+	capability = strings.TrimSpace(capability)
+	minFirmware = strings.TrimSpace(minFirmware)
+	if capability == "" {
+		return domain.VersionRequirement{}, fmt.Errorf("%w: capability is required", ErrInvalidRequirement)
+	}
+	if !firmwareVersionPattern.MatchString(minFirmware) {
+		return domain.VersionRequirement{}, fmt.Errorf("%w: min_firmware_version must be dotted numbers like 1.4.2", ErrInvalidRequirement)
+	}
+	stored, err := r.store.UpsertVersionRequirement(ctx, domain.VersionRequirement{Capability: capability, MinFirmwareVersion: minFirmware})
+	if err != nil {
+		return domain.VersionRequirement{}, err
+	}
+	if err := r.Load(ctx); err != nil {
+		return domain.VersionRequirement{}, err
+	}
+	return stored, nil
+}
+
+func (r *Rollout) Delete(ctx context.Context, capability string) error {
+	if err := r.store.DeleteVersionRequirement(ctx, strings.TrimSpace(capability)); err != nil {
+		return err
+	}
+	return r.Load(ctx)
+}
+
+// SetVersionRequirements replaces the minimum firmware per capability.
+func (r *Registry) SetVersionRequirements(items []domain.VersionRequirement) {
+	minFirmware := make(map[string]string, len(items))
+	for _, item := range items {
+		minFirmware[item.Capability] = item.MinFirmwareVersion
+	}
+	r.mu.Lock()
+	r.minFirmware = minFirmware
+	r.mu.Unlock()
+}
+
+// SetVersions records the firmware and installed bundle versions a terminal
+// reported with its skills.
+func (r *Registry) SetVersions(terminalID, firmware string, bundles map[string]int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.data[terminalID]
+	state.TerminalID = terminalID
+	state.FirmwareVersion = strings.TrimSpace(firmware)
+	state.BundleVersions = nil
+	if bundles != nil {
+		state.BundleVersions = make(map[string]int64, len(bundles))
+		for name, version := range bundles {
+			state.BundleVersions[name] = version
+		}
+	}
+	r.data[terminalID] = state
+}
+
+// Versions returns what the terminal reported and what it must upgrade;
+// false when the terminal has no live snapshot.
+func (r *Registry) Versions(terminalID string) (domain.TerminalVersions, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state, ok := r.data[terminalID]
+	if !ok || r.isExpired(state) {
+		return domain.TerminalVersions{}, false
+	}
+	out := domain.TerminalVersions{
+		TerminalID:      terminalID,
+		FirmwareVersion: state.FirmwareVersion,
+		Upgrades:        r.upgradesLocked(state),
+	}
+	if state.BundleVersions != nil {
+		out.BundleVersions = make(map[string]int64, len(state.BundleVersions))
+		for name, version := range state.BundleVersions {
+			out.BundleVersions[name] = version
+		}
+	}
+	out.UpgradeNeeded = len(out.Upgrades) > 0
+	return out, true
+}
+
+// UpgradeFor reports whether the terminal's firmware is too old for the
+// skill.
+func (r *Registry) UpgradeFor(terminalID, skill string) (domain.UpgradeItem, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	required, ok := r.minFirmware[skill]
+	if !ok {
+		return domain.UpgradeItem{}, false
+	}
+	current := r.data[terminalID].FirmwareVersion
+	if CompareVersions(current, required) >= 0 {
+		return domain.UpgradeItem{}, false
+	}
+	return domain.UpgradeItem{Kind: domain.UpgradeKindFirmware, Capability: skill, Current: current, Required: required}, true
+}
+
+// upgradesLocked lists, firmware first and then by name, the capabilities
+// the terminal offers whose firmware requirement it misses, and, when it
+// reports bundle versions, the enabled bundles it has not installed at
+// their published version. Callers hold r.mu.
+func (r *Registry) upgradesLocked(state TerminalSkillState) []domain.UpgradeItem {
+	out := make([]domain.UpgradeItem, 0)
+	offered := make(map[string]struct{}, len(state.Skills))
+	for _, skill := range state.Skills {
+		offered[skill.Name] = struct{}{}
+	}
+	for _, bundle := range r.bundles[state.TerminalID] {
+		for _, skill := range bundle.Skills {
+			offered[skill.Name] = struct{}{}
+		}
+	}
+	for capability, required := range r.minFirmware {
+		if _, ok := offered[capability]; !ok {
+			continue
+		}
+		if CompareVersions(state.FirmwareVersion, required) < 0 {
+			out = append(out, domain.UpgradeItem{Kind: domain.UpgradeKindFirmware, Capability: capability, Current: state.FirmwareVersion, Required: required})
+		}
+	}
+	if state.BundleVersions != nil {
+		for _, bundle := range r.bundles[state.TerminalID] {
+			if installed := state.BundleVersions[bundle.Name]; installed < bundle.Version {
+				out = append(out, domain.UpgradeItem{Kind: domain.UpgradeKindBundle, Capability: bundle.Name, Current: strconv.FormatInt(installed, 10), Required: strconv.FormatInt(bundle.Version, 10)})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind == domain.UpgradeKindFirmware
+		}
+		return out[i].Capability < out[j].Capability
+	})
+	return out
+}
+
+// DescribeUpgrades renders upgrades for the terminal, e.g.
+// "固件需升级到 1.4.0（control_light）；技能包 home_light 需更新到版本 3".
+func DescribeUpgrades(items []domain.UpgradeItem) string {
+	firmware := ""
+	var capabilities, parts []string
+	for _, item := range items {
+		switch item.Kind {
+		case domain.UpgradeKindFirmware:
+			if CompareVersions(item.Required, firmware) > 0 {
+				firmware = item.Required
+			}
+			capabilities = append(capabilities, item.Capability)
+		case domain.UpgradeKindBundle:
+			parts = append(parts, fmt.Sprintf("技能包 %s 需更新到版本 %s", item.Capability, item.Required))
+		}
+	}
+	if firmware != "" {
+		parts = append([]string{fmt.Sprintf("固件需升级到 %s（%s）", firmware, strings.Join(capabilities, "、"))}, parts...)
+	}
+	return strings.Join(parts, "；")
+}
+
+// CompareVersions compares dotted versions number by number, so 1.10 is
+// newer than 1.9 and 1.2 equals 1.2.0. A missing version is older than any
+// other.
+func CompareVersions(a, b string) int {
+	a = strings.TrimPrefix(strings.TrimSpace(a), "v")
+	b = strings.TrimPrefix(strings.TrimSpace(b), "v")
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var sa, sb string
+		if i < len(pa) {
+			sa = pa[i]
+		}
+		if i < len(pb) {
+			sb = pb[i]
+		}
+		na, errA := strconv.Atoi(defaultZero(sa))
+		nb, errB := strconv.Atoi(defaultZero(sb))
+		if errA != nil || errB != nil {
+			if c := strings.Compare(sa, sb); c != 0 {
+				return c
+			}
+			continue
+		}
+		if na != nb {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func defaultZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
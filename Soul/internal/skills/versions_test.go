@@ -0,0 +1,62 @@
+package skills
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"soul/internal/domain"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.10.0", "1.9.3", 1},
+		{"1.2", "1.2.0", 0},
+		{"v2.0", "2.0.1", -1},
+		{"", "0.1", -1},
+		{"1.0.0-rc1", "1.0.0-rc2", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestOldTerminalsGetUpgradeNeeded(t *testing.T) {
+	registry := NewRegistry(time.Minute)
+	registry.SetSkills("t1", "soul_a", 1, []domain.SkillDefinition{{Name: "control_light"}, {Name: "set_head_motion"}})
+	registry.SetBundles("t1", []domain.SkillBundle{{Name: "home", Version: 3, Skills: []domain.SkillDefinition{{Name: "curtain_open"}}}})
+	registry.SetVersions("t1", "1.3.9", map[string]int64{"home": 2})
+	registry.SetVersionRequirements([]domain.VersionRequirement{
+		{Capability: "control_light", MinFirmwareVersion: "1.4.0"},
+		{Capability: "set_head_motion", MinFirmwareVersion: "1.3"},
+		{Capability: "send_email", MinFirmwareVersion: "9.0"},
+	})
+
+	got, ok := registry.Versions("t1")
+	if !ok || !got.UpgradeNeeded || len(got.Upgrades) != 2 {
+		t.Fatalf("expected a firmware and a bundle upgrade, got %+v", got)
+	}
+	if got.Upgrades[0] != (domain.UpgradeItem{Kind: "firmware", Capability: "control_light", Current: "1.3.9", Required: "1.4.0"}) ||
+		got.Upgrades[1] != (domain.UpgradeItem{Kind: "bundle", Capability: "home", Current: "2", Required: "3"}) {
+		t.Fatalf("unexpected upgrades %+v", got.Upgrades)
+	}
+	if msg := DescribeUpgrades(got.Upgrades); msg != "固件需升级到 1.4.0（control_light）；技能包 home 需更新到版本 3" {
+		t.Fatalf("unexpected description %q", msg)
+	}
+
+	terminal := &recordingTerminal{}
+	router := NewRouter(registry, terminal, nil)
+	result, err := router.InvokeSkill(context.Background(), "t1", "control_light", nil)
+	if !errors.Is(err, ErrUpgradeRequired) || result.OK || len(terminal.calls) != 0 {
+		t.Fatalf("too old firmware must not be invoked: %+v %v %v", result, err, terminal.calls)
+	}
+	if _, err := router.InvokeSkill(context.Background(), "t1", "set_head_motion", nil); err != nil || len(terminal.calls) != 1 {
+		t.Fatalf("met requirement must reach the terminal: %v %v", err, terminal.calls)
+	}
+}
@@ -0,0 +1,64 @@
+package speaker
+
+import (
+	"math"
+
+	"soul/internal/domain"
+)
+
+const DefaultMatchThreshold = 0.75
+
+type Match struct {
+	Profile domain.SpeakerProfile
+	Score   float64
+}
+
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// BestMatch returns the enrolled profile closest to embedding, or false when no
+// profile reaches threshold. Profiles with a different embedding dimension are skipped.
+func BestMatch(embedding []float64, profiles []domain.SpeakerProfile, threshold float64) (Match, bool) {
+	if threshold <= 0 {
+		threshold = DefaultMatchThreshold
+	}
+	best := Match{Score: -1}
+	for _, p := range profiles {
+		score := CosineSimilarity(embedding, p.Embedding)
+		if score > best.Score {
+			best = Match{Profile: p, Score: score}
+		}
+	}
+	if best.Score < threshold {
+		return Match{}, false
+	}
+	return best, true
+}
+
+// MergeEmbedding folds a new enrollment sample into the running mean and
+// returns it with its sample count. A sample of another dimension (a new
+// embedding model) restarts the mean from that sample alone.
+func MergeEmbedding(current []float64, sampleCount int, next []float64) ([]float64, int) {
+	if len(current) == 0 || sampleCount <= 0 || len(current) != len(next) {
+		return append([]float64{}, next...), 1
+	}
+	out := make([]float64, len(current))
+	n := float64(sampleCount)
+	for i := range current {
+		out[i] = (current[i]*n + next[i]) / (n + 1)
+	}
+	return out, sampleCount + 1
+}
@@ -0,0 +1,38 @@
+package speaker
+
+import (
+	"math"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+func TestBestMatchPicksClosestAboveThreshold(t *testing.T) {
+	profiles := []domain.SpeakerProfile{
+		{SpeakerID: "mom", Embedding: []float64{1, 0, 0}},
+		{SpeakerID: "kid", Embedding: []float64{0, 1, 0}},
+		{SpeakerID: "short", Embedding: []float64{1, 0}},
+	}
+
+	got, ok := BestMatch([]float64{0.1, 0.9, 0}, profiles, 0.8)
+	if !ok || got.Profile.SpeakerID != "kid" {
+		t.Fatalf("expected kid match, got ok=%v match=%+v", ok, got)
+	}
+	if _, ok := BestMatch([]float64{0.6, 0.6, 0.5}, profiles, 0.8); ok {
+		t.Fatalf("expected ambiguous embedding to stay unresolved")
+	}
+}
+
+func TestMergeEmbeddingRunningMean(t *testing.T) {
+	merged, n := MergeEmbedding([]float64{1, 1}, 3, []float64{5, -3})
+	if math.Abs(merged[0]-2) > 1e-9 || math.Abs(merged[1]-0) > 1e-9 || n != 4 {
+		t.Fatalf("unexpected merged embedding: %v (%d samples)", merged, n)
+	}
+}
+
+func TestMergeEmbeddingRestartsOnNewDimension(t *testing.T) {
+	merged, n := MergeEmbedding([]float64{1, 1}, 7, []float64{0.5, 0.5, 0.5})
+	if len(merged) != 3 || merged[0] != 0.5 || n != 1 {
+		t.Fatalf("a sample of another dimension must restart the mean: %v (%d samples)", merged, n)
+	}
+}
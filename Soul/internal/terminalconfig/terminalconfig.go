@@ -0,0 +1,164 @@
+// Package terminalconfig pushes settings to terminals over MQTT and tracks
+// whether each terminal applied them, so a fleet can be tuned without
+// logging into every device.
+package terminalconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+// ErrInvalidConfig wraps every reason Push rejects a config.
+var ErrInvalidConfig = errors.New("invalid terminal config")
+
+// pendingResendLimit bounds how many pending pushes go out again when a
+// terminal reconnects.
+const pendingResendLimit = 20
+
+type Store interface {
+	InsertTerminalConfigPush(ctx context.Context, in domain.TerminalConfigPush) (domain.TerminalConfigPush, error)
+	MarkTerminalConfigPushSent(ctx context.Context, id int64) (domain.TerminalConfigPush, error)
+	AckTerminalConfigPush(ctx context.Context, ack domain.TerminalConfigAck) (domain.TerminalConfigPush, error)
+	ListTerminalConfigPushes(ctx context.Context, terminalID, status string, limit int) ([]domain.TerminalConfigPush, error)
+}
+
+// Publisher sends a config push to a terminal; mqtt.Hub does it.
+type Publisher interface {
+	PublishConfig(ctx context.Context, terminalID string, payload domain.ConfigPushPayload) error
+}
+
+type Service struct {
+	store     Store
+	publisher Publisher
+	logger    *slog.Logger
+}
+
+func NewService(store Store, publisher Publisher, logger *slog.Logger) *Service {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Service{store: store, publisher: publisher, logger: logger}
+}
+
+// Push validates the config, records it as pending and publishes it. When
+// publishing fails the error is returned but the push stays pending and
+// goes out again on the terminal's next reconnect.
+func (s *Service) Push(ctx context.Context, terminalID string, config domain.TerminalConfig) (domain.TerminalConfigPush, error) {
+	terminalID = strings.TrimSpace(terminalID)
+	if terminalID == "" {
+		return domain.TerminalConfigPush{}, fmt.Errorf("%w: terminal_id is required", ErrInvalidConfig)
+	}
+	if q := config.QuietHours; q != nil {
+		config.QuietHours = &domain.TerminalQuietHoursConfig{Start: strings.TrimSpace(q.Start), End: strings.TrimSpace(q.End), Enabled: q.Enabled}
+	}
+	if err := validate(config); err != nil {
+		return domain.TerminalConfigPush{}, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	item, err := s.store.InsertTerminalConfigPush(ctx, domain.TerminalConfigPush{
+		RequestID:  uuid.NewString(),
+		TerminalID: terminalID,
+		Config:     config,
+	})
+	if err != nil {
+		return domain.TerminalConfigPush{}, err
+	}
+	return s.send(ctx, item)
+}
+
+func (s *Service) send(ctx context.Context, item domain.TerminalConfigPush) (domain.TerminalConfigPush, error) {
+	err := s.publisher.PublishConfig(ctx, item.TerminalID, domain.ConfigPushPayload{
+		RequestID:  item.RequestID,
+		TerminalID: item.TerminalID,
+		Config:     item.Config,
+		TS:         time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return item, err
+	}
+	sent, err := s.store.MarkTerminalConfigPushSent(ctx, item.ID)
+	if err != nil {
+		return item, err
+	}
+	s.logger.Info("terminal config pushed", "terminal_id", sent.TerminalID, "request_id", sent.RequestID, "attempts", sent.Attempts)
+	return sent, nil
+}
+
+// RecordAck settles the push a terminal acknowledged; it is meant for the
+// MQTT hub's config_ack hook.
+func (s *Service) RecordAck(ack domain.TerminalConfigAck) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	item, err := s.store.AckTerminalConfigPush(ctx, ack)
+	if err != nil {
+		if errors.Is(err, db.ErrConfigPushNotFound) {
+			s.logger.Warn("config ack for unknown or settled push", "terminal_id", ack.TerminalID, "request_id", ack.RequestID)
+			return
+		}
+		s.logger.Warn("record config ack failed", "terminal_id", ack.TerminalID, "request_id", ack.RequestID, "error", err)
+		return
+	}
+	s.logger.Info("terminal config acknowledged", "terminal_id", item.TerminalID, "request_id", item.RequestID, "status", item.Status)
+}
+
+// HandleReconnect publishes a reconnected terminal's pending pushes again,
+// oldest first so later settings still win.
+func (s *Service) HandleReconnect(terminalID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	pending, err := s.store.ListTerminalConfigPushes(ctx, terminalID, domain.ConfigPushPending, pendingResendLimit)
+	if err != nil {
+		s.logger.Warn("list pending config pushes failed", "terminal_id", terminalID, "error", err)
+		return
+	}
+	for i := len(pending) - 1; i >= 0; i-- {
+		if _, err := s.send(ctx, pending[i]); err != nil {
+			s.logger.Warn("resend config push failed", "terminal_id", terminalID, "request_id", pending[i].RequestID, "error", err)
+			return
+		}
+	}
+}
+
+// List returns the terminal's pushes, newest first.
+func (s *Service) List(ctx context.Context, terminalID, status string, limit int) ([]domain.TerminalConfigPush, error) {
+	return s.store.ListTerminalConfigPushes(ctx, terminalID, status, limit)
+}
+
+func validate(config domain.TerminalConfig) error {
+	if config.HeartbeatIntervalSeconds == 0 && config.VAD == nil && config.QuietHours == nil {
+		return errors.New("config sets nothing")
+	}
+	if v := config.HeartbeatIntervalSeconds; v != 0 && (v < 5 || v > 300) {
+		return errors.New("heartbeat_interval_seconds must be between 5 and 300")
+	}
+	if vad := config.VAD; vad != nil {
+		if vad.ThresholdDB == nil && vad.SilenceMS == 0 && vad.MinSpeechMS == 0 {
+			return errors.New("vad sets nothing")
+		}
+		if vad.ThresholdDB != nil && (*vad.ThresholdDB < -90 || *vad.ThresholdDB > 0) {
+			return errors.New("vad.threshold_db must be between -90 and 0")
+		}
+		if vad.SilenceMS != 0 && (vad.SilenceMS < 100 || vad.SilenceMS > 5000) {
+			return errors.New("vad.silence_ms must be between 100 and 5000")
+		}
+		if vad.MinSpeechMS < 0 || vad.MinSpeechMS > 2000 {
+			return errors.New("vad.min_speech_ms must be between 0 and 2000")
+		}
+	}
+	if q := config.QuietHours; q != nil {
+		for _, v := range []string{q.Start, q.End} {
+			if _, err := time.Parse("15:04", v); err != nil {
+				return fmt.Errorf("quiet_hours times must be HH:MM, got %q", v)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package terminalconfig
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"soul/internal/db"
+	"soul/internal/domain"
+)
+
+type memStore struct {
+	items []domain.TerminalConfigPush
+}
+
+func (m *memStore) InsertTerminalConfigPush(_ context.Context, in domain.TerminalConfigPush) (domain.TerminalConfigPush, error) {
+	in.ID = int64(len(m.items) + 1)
+	in.Status = domain.ConfigPushPending
+	m.items = append(m.items, in)
+	return in, nil
+}
+
+func (m *memStore) MarkTerminalConfigPushSent(_ context.Context, id int64) (domain.TerminalConfigPush, error) {
+	m.items[id-1].Attempts++
+	return m.items[id-1], nil
+}
+
+func (m *memStore) AckTerminalConfigPush(_ context.Context, ack domain.TerminalConfigAck) (domain.TerminalConfigPush, error) {
+	for i, item := range m.items {
+		if item.RequestID == ack.RequestID && item.TerminalID == ack.TerminalID && item.Status == domain.ConfigPushPending {
+			m.items[i].Status = domain.ConfigPushApplied
+			if !ack.OK {
+				m.items[i].Status = domain.ConfigPushRejected
+			}
+			return m.items[i], nil
+		}
+	}
+	return domain.TerminalConfigPush{}, db.ErrConfigPushNotFound
+}
+
+func (m *memStore) ListTerminalConfigPushes(_ context.Context, terminalID, status string, limit int) ([]domain.TerminalConfigPush, error) {
+	var out []domain.TerminalConfigPush
+	for i := len(m.items) - 1; i >= 0 && len(out) < limit; i-- {
+		if m.items[i].TerminalID == terminalID && (status == "" || m.items[i].Status == status) {
+			out = append(out, m.items[i])
+		}
+	}
+	return out, nil
+}
+
+type flakyPublisher struct {
+	down bool
+	sent []int
+}
+
+func (p *flakyPublisher) PublishConfig(_ context.Context, _ string, payload domain.ConfigPushPayload) error {
+	if p.down {
+		return errors.New("mqtt client is not started")
+	}
+	p.sent = append(p.sent, payload.Config.HeartbeatIntervalSeconds)
+	return nil
+}
+
+func TestPushTracksAcksAndResendsPending(t *testing.T) {
+	store := &memStore{}
+	pub := &flakyPublisher{down: true}
+	svc := NewService(store, pub, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	ctx := context.Background()
+
+	if _, err := svc.Push(ctx, "t1", domain.TerminalConfig{HeartbeatIntervalSeconds: 1}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("out of range heartbeat must be rejected, got %v", err)
+	}
+	if _, err := svc.Push(ctx, "t1", domain.TerminalConfig{QuietHours: &domain.TerminalQuietHoursConfig{Start: "22:00", End: "7am"}}); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("bad quiet hours must be rejected, got %v", err)
+	}
+
+	first, err := svc.Push(ctx, "t1", domain.TerminalConfig{HeartbeatIntervalSeconds: 10})
+	if err == nil || first.ID == 0 || first.Status != domain.ConfigPushPending {
+		t.Fatalf("failed publish must keep the push pending, got %+v %v", first, err)
+	}
+	second, _ := svc.Push(ctx, "t1", domain.TerminalConfig{HeartbeatIntervalSeconds: 30})
+
+	pub.down = false
+	svc.HandleReconnect("t1")
+	if len(pub.sent) != 2 || pub.sent[0] != 10 || pub.sent[1] != 30 {
+		t.Fatalf("pending pushes must go out oldest first, got %v", pub.sent)
+	}
+
+	svc.RecordAck(domain.TerminalConfigAck{RequestID: first.RequestID, TerminalID: "t1", OK: true})
+	svc.RecordAck(domain.TerminalConfigAck{RequestID: second.RequestID, TerminalID: "t1", OK: false, Error: "unsupported"})
+	items, _ := svc.List(ctx, "t1", "", 10)
+	if items[0].Status != domain.ConfigPushRejected || items[1].Status != domain.ConfigPushApplied || items[1].Attempts != 1 {
+		t.Fatalf("unexpected pushes %+v", items)
+	}
+
+	pub.sent = nil
+	svc.HandleReconnect("t1")
+	if len(pub.sent) != 0 {
+		t.Fatalf("settled pushes must not be resent, got %v", pub.sent)
+	}
+}
@@ -0,0 +1,154 @@
+// Package topics labels user turns with coarse conversation topics by
+// keyword, cheap enough to run on every turn without another LLM call.
+package topics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxPerTurn bounds the topics a single turn is labelled with.
+const MaxPerTurn = 3
+
+// builtinTopics are matched case-insensitively; English keywords must start
+// a word, so "rain" does not fire on "train" but does on "rainy".
+var builtinTopics = []Topic{
+	{Name: "天气", Keywords: []string{"天气", "下雨", "下雪", "气温", "温度", "刮风", "晴天", "阴天", "雾霾", "weather", "rain", "snow", "temperature"}},
+	{Name: "音乐", Keywords: []string{"音乐", "歌", "播放", "听歌", "歌手", "专辑", "music", "song", "playlist"}},
+	{Name: "提醒日程", Keywords: []string{"提醒", "闹钟", "日程", "会议", "待办", "明天要", "几点", "remind", "alarm", "schedule", "meeting"}},
+	{Name: "灯光设备", Keywords: []string{"开灯", "关灯", "灯光", "亮度", "颜色", "台灯", "空调", "light", "lamp", "brightness"}},
+	{Name: "学习", Keywords: []string{"作业", "考试", "学习", "复习", "单词", "题目", "老师", "homework", "exam", "study"}},
+	{Name: "工作", Keywords: []string{"工作", "上班", "加班", "老板", "同事", "项目", "邮件", "work", "boss", "project", "email"}},
+	{Name: "健康", Keywords: []string{"身体", "生病", "头疼", "感冒", "睡眠", "失眠", "运动", "健身", "医院", "health", "sick", "headache", "sleep", "exercise"}},
+	{Name: "饮食", Keywords: []string{"吃饭", "晚饭", "午饭", "早饭", "菜谱", "做饭", "外卖", "好吃", "饿", "food", "dinner", "lunch", "recipe"}},
+	{Name: "出行", Keywords: []string{"出门", "路况", "堵车", "地铁", "打车", "航班", "火车", "旅行", "旅游", "travel", "flight", "traffic"}},
+	{Name: "心情", Keywords: []string{"心情", "难过", "开心", "烦", "焦虑", "压力", "孤单", "委屈", "mood", "sad", "stressed", "lonely"}},
+	{Name: "娱乐", Keywords: []string{"电影", "电视剧", "综艺", "游戏", "动画", "小说", "movie", "game", "show"}},
+}
+
+// Topic is a label and the keywords that select it.
+type Topic struct {
+	Name     string
+	Keywords []string
+}
+
+// ParseTopics reads extra topics written as "name=kw|kw;name=kw". A name
+// that is also built in adds its keywords to the built-in topic.
+func ParseTopics(spec string) ([]Topic, error) {
+	var out []Topic
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, list, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("topic %q must be name=keyword|keyword", entry)
+		}
+		var keywords []string
+		for _, kw := range strings.Split(list, "|") {
+			if kw = strings.TrimSpace(kw); kw != "" {
+				keywords = append(keywords, kw)
+			}
+		}
+		if len(keywords) == 0 {
+			return nil, fmt.Errorf("topic %s has no keywords", name)
+		}
+		out = append(out, Topic{Name: name, Keywords: keywords})
+	}
+	return out, nil
+}
+
+// Tracker labels text with the topics whose keywords it contains.
+type Tracker struct {
+	topics []Topic
+}
+
+// New returns a tracker over the built-in topics plus extra.
+func New(extra []Topic) *Tracker {
+	topics := make([]Topic, 0, len(builtinTopics)+len(extra))
+	index := make(map[string]int, len(builtinTopics)+len(extra))
+	for _, t := range append(append([]Topic(nil), builtinTopics...), extra...) {
+		keywords := make([]string, 0, len(t.Keywords))
+		for _, kw := range t.Keywords {
+			keywords = append(keywords, strings.ToLower(kw))
+		}
+		if i, ok := index[t.Name]; ok {
+			topics[i].Keywords = append(topics[i].Keywords, keywords...)
+			continue
+		}
+		index[t.Name] = len(topics)
+		topics = append(topics, Topic{Name: t.Name, Keywords: keywords})
+	}
+	return &Tracker{topics: topics}
+}
+
+// Label returns up to MaxPerTurn topics of text, most keyword hits first
+// and ties in the order they are mentioned. A nil Tracker labels nothing.
+func (t *Tracker) Label(text string) []string {
+	if t == nil {
+		return nil
+	}
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return nil
+	}
+	type hit struct {
+		name  string
+		count int
+		first int
+	}
+	var hits []hit
+	for _, topic := range t.topics {
+		h := hit{name: topic.Name, first: len(text)}
+		for _, kw := range topic.Keywords {
+			if i := index(text, kw); i >= 0 {
+				h.count++
+				h.first = min(h.first, i)
+			}
+		}
+		if h.count > 0 {
+			hits = append(hits, h)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].count != hits[j].count {
+			return hits[i].count > hits[j].count
+		}
+		return hits[i].first < hits[j].first
+	})
+	out := make([]string, 0, min(len(hits), MaxPerTurn))
+	for _, h := range hits {
+		if len(out) == MaxPerTurn {
+			break
+		}
+		out = append(out, h.name)
+	}
+	return out
+}
+
+// index finds kw in text; a keyword starting with an ASCII letter only
+// matches at the start of a word.
+func index(text, kw string) int {
+	if kw == "" || !isASCIILetter(kw[0]) {
+		return strings.Index(text, kw)
+	}
+	for from := 0; from < len(text); {
+		i := strings.Index(text[from:], kw)
+		if i < 0 {
+			return -1
+		}
+		i += from
+		if i == 0 || !isASCIILetter(text[i-1]) {
+			return i
+		}
+		from = i + utf8.RuneLen(rune(text[i]))
+	}
+	return -1
+}
+
+func isASCIILetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
@@ -0,0 +1,47 @@
+package topics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLabel(t *testing.T) {
+	tr := New(nil)
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"明天会下雨吗，要不要带伞", []string{"天气"}},
+		{"放首歌，再提醒我八点开会", []string{"音乐", "提醒日程"}},
+		{"Will it rain tomorrow?", []string{"天气"}},
+		{"I missed the train", nil},
+		{"嗯嗯", nil},
+	}
+	for _, c := range cases {
+		got := tr.Label(c.in)
+		if len(got) == 0 && len(c.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Label(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseTopicsExtendsBuiltins(t *testing.T) {
+	extra, err := ParseTopics("宠物=猫|狗|遛狗; 天气=台风")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	tr := New(extra)
+	if got := tr.Label("台风要来了，记得把猫抱进屋"); !reflect.DeepEqual(got, []string{"天气", "宠物"}) {
+		t.Fatalf("unexpected labels %v", got)
+	}
+	if _, err := ParseTopics("宠物"); err == nil {
+		t.Fatal("expected an error for a topic without keywords")
+	}
+	var nilTracker *Tracker
+	if nilTracker.Label("下雨") != nil {
+		t.Fatal("nil tracker must label nothing")
+	}
+}
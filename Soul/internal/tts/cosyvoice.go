@@ -0,0 +1,93 @@
+package tts
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CosyVoiceEngine calls the CosyVoice playground service. With a CloneID it
+// uses the chunked PCM stream; otherwise it synthesizes a preset speaker as
+// WAV, which only arrives once the sentence is rendered.
+type CosyVoiceEngine struct {
+	BaseURL string
+	// Speaker is the preset voice when Request.Voice is empty.
+	Speaker string
+	// CloneID selects a cloned voice and the streaming endpoint.
+	CloneID string
+	Client  *http.Client
+}
+
+func (e *CosyVoiceEngine) Name() string {
+	return "cosyvoice"
+}
+
+func (e *CosyVoiceEngine) Synthesize(ctx context.Context, req Request) (*Audio, error) {
+	speed := req.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	payload := map[string]any{"text": req.Text, "speed": speed}
+	path := "/api/synthesize"
+	if e.CloneID != "" {
+		path = "/api/synthesize/clone/stream"
+		payload["clone_id"] = e.CloneID
+	} else if voice := cmp.Or(req.Voice, e.Speaker); voice != "" {
+		payload["speaker"] = voice
+	}
+
+	resp, err := postJSON(ctx, e.Client, strings.TrimRight(e.BaseURL, "/")+path, "", payload)
+	if err != nil {
+		return nil, fmt.Errorf("cosyvoice: %w", err)
+	}
+	if e.CloneID != "" {
+		rate, err := strconv.Atoi(resp.Header.Get("X-Sample-Rate"))
+		if err != nil || rate <= 0 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("cosyvoice: missing X-Sample-Rate header")
+		}
+		return &Audio{SampleRate: rate, Body: resp.Body}, nil
+	}
+	rate, err := readWAVHeader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("cosyvoice: %w", err)
+	}
+	return &Audio{SampleRate: rate, Body: resp.Body}, nil
+}
+
+// postJSON sends payload and returns the response when it is a success; the
+// caller owns the body.
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, payload any) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(raw))
+	}
+	return resp, nil
+}
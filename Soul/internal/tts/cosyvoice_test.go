@@ -0,0 +1,94 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func wavBytes(rate int, pcm []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+len(pcm)))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, uint32(16))
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, uint32(rate))
+	binary.Write(&b, binary.LittleEndian, uint32(rate*2))
+	binary.Write(&b, binary.LittleEndian, uint16(2))
+	binary.Write(&b, binary.LittleEndian, uint16(16))
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(len(pcm)))
+	b.Write(pcm)
+	return b.Bytes()
+}
+
+func TestCosyVoicePresetSpeakerWAV(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0, 3, 0}
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/synthesize" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "audio/wav")
+		_, _ = w.Write(wavBytes(22050, pcm))
+	}))
+	defer srv.Close()
+
+	e := &CosyVoiceEngine{BaseURL: srv.URL, Speaker: "中文女"}
+	audio, err := e.Synthesize(context.Background(), Request{Text: "你好。"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audio.Body.Close()
+	body, _ := io.ReadAll(audio.Body)
+	if audio.SampleRate != 22050 || !bytes.Equal(body, pcm) {
+		t.Fatalf("got rate %d body %v", audio.SampleRate, body)
+	}
+	if got["speaker"] != "中文女" || got["text"] != "你好。" || got["speed"] != 1.0 {
+		t.Fatalf("request = %v", got)
+	}
+}
+
+func TestCosyVoiceCloneStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/synthesize/clone/stream" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		w.Header().Set("X-Sample-Rate", "22050")
+		_, _ = w.Write([]byte{9, 0})
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte{8, 0})
+	}))
+	defer srv.Close()
+
+	e := &CosyVoiceEngine{BaseURL: srv.URL, CloneID: "robot"}
+	audio, err := e.Synthesize(context.Background(), Request{Text: "你好。"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer audio.Body.Close()
+	body, _ := io.ReadAll(audio.Body)
+	if audio.SampleRate != 22050 || !bytes.Equal(body, []byte{9, 0, 8, 0}) {
+		t.Fatalf("got rate %d body %v", audio.SampleRate, body)
+	}
+}
+
+func TestCosyVoiceErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"detail":"CosyVoice model is not ready"}`, http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := (&CosyVoiceEngine{BaseURL: srv.URL}).Synthesize(context.Background(), Request{Text: "你好"})
+	if err == nil || !bytes.Contains([]byte(err.Error()), []byte("503")) {
+		t.Fatalf("err = %v, want 503", err)
+	}
+}
@@ -0,0 +1,27 @@
+package tts
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"unicode/utf8"
+)
+
+// mockRate keeps mock audio small; clients must honour the declared rate.
+const mockRate = 16000
+
+// MockEngine renders 50ms of silence per character, for wiring tests without
+// a TTS service.
+type MockEngine struct{}
+
+func (m *MockEngine) Name() string {
+	return "mock"
+}
+
+func (m *MockEngine) Synthesize(ctx context.Context, req Request) (*Audio, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	n := utf8.RuneCountInString(req.Text) * mockRate / 20
+	return &Audio{SampleRate: mockRate, Body: io.NopCloser(bytes.NewReader(make([]byte, n*2)))}, nil
+}
@@ -0,0 +1,44 @@
+package tts
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// openAIPCMRate is the fixed rate of response_format=pcm.
+const openAIPCMRate = 24000
+
+// OpenAIEngine calls an OpenAI-compatible /audio/speech endpoint for raw
+// PCM, which is streamed as it renders.
+type OpenAIEngine struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	// Voice is the default when Request.Voice is empty.
+	Voice  string
+	Client *http.Client
+}
+
+func (e *OpenAIEngine) Name() string {
+	return "openai"
+}
+
+func (e *OpenAIEngine) Synthesize(ctx context.Context, req Request) (*Audio, error) {
+	payload := map[string]any{
+		"model":           e.Model,
+		"input":           req.Text,
+		"voice":           cmp.Or(req.Voice, e.Voice, "alloy"),
+		"response_format": "pcm",
+	}
+	if req.Speed != 0 {
+		payload["speed"] = req.Speed
+	}
+	resp, err := postJSON(ctx, e.Client, strings.TrimRight(e.BaseURL, "/")+"/audio/speech", e.APIKey, payload)
+	if err != nil {
+		return nil, fmt.Errorf("openai tts: %w", err)
+	}
+	return &Audio{SampleRate: openAIPCMRate, Body: resp.Body}, nil
+}
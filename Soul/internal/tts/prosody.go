@@ -0,0 +1,154 @@
+package tts
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"soul/internal/domain"
+)
+
+// Prosody is how a reply should sound. Speed, Pitch and Energy are ratios
+// where 1 is the engine's natural delivery.
+type Prosody struct {
+	Voice  string  `json:"voice,omitempty"`
+	Speed  float64 `json:"speed"`
+	Pitch  float64 `json:"pitch"`
+	Energy float64 `json:"energy"`
+}
+
+// Weights is the linear contribution of each mood and personality axis to a
+// prosody ratio. PAD axes are in [-1, 1] and Boredom in [0, 1]; personality
+// axes are in [0, 1] and enter centred on 0.5, so an average soul adds
+// nothing.
+type Weights struct {
+	P              float64 `json:"p,omitempty"`
+	A              float64 `json:"a,omitempty"`
+	D              float64 `json:"d,omitempty"`
+	Boredom        float64 `json:"boredom,omitempty"`
+	Empathy        float64 `json:"empathy,omitempty"`
+	Sensitivity    float64 `json:"sensitivity,omitempty"`
+	Stability      float64 `json:"stability,omitempty"`
+	Expressiveness float64 `json:"expressiveness,omitempty"`
+	Dominance      float64 `json:"dominance,omitempty"`
+}
+
+func (w Weights) apply(mood domain.SoulEmotionState, pv domain.PersonalityVector) float64 {
+	centred := func(x float64) float64 { return 2*x - 1 }
+	return w.P*mood.P + w.A*mood.A + w.D*mood.D + w.Boredom*mood.Boredom +
+		w.Empathy*centred(pv.Empathy) + w.Sensitivity*centred(pv.Sensitivity) +
+		w.Stability*centred(pv.Stability) + w.Expressiveness*centred(pv.Expressiveness) +
+		w.Dominance*centred(pv.Dominance)
+}
+
+// Axis shapes one prosody ratio: base × (1 + weighted sum), clamped.
+type Axis struct {
+	Weights Weights `json:"weights"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+}
+
+func (a Axis) value(base float64, mood domain.SoulEmotionState, pv domain.PersonalityVector) float64 {
+	v := base * (1 + a.Weights.apply(mood, pv))
+	if a.Min > 0 {
+		v = math.Max(v, a.Min)
+	}
+	if a.Max > 0 {
+		v = math.Min(v, a.Max)
+	}
+	return math.Round(v*1000) / 1000
+}
+
+// VoiceRule picks a voice while the soul's mood is inside a PAD box; unset
+// bounds are open.
+type VoiceRule struct {
+	Voice string   `json:"voice"`
+	MinP  *float64 `json:"min_p,omitempty"`
+	MaxP  *float64 `json:"max_p,omitempty"`
+	MinA  *float64 `json:"min_a,omitempty"`
+	MaxA  *float64 `json:"max_a,omitempty"`
+	MinD  *float64 `json:"min_d,omitempty"`
+	MaxD  *float64 `json:"max_d,omitempty"`
+}
+
+func (r VoiceRule) matches(mood domain.SoulEmotionState) bool {
+	in := func(x float64, lo, hi *float64) bool { return (lo == nil || x >= *lo) && (hi == nil || x <= *hi) }
+	return in(mood.P, r.MinP, r.MaxP) && in(mood.A, r.MinA, r.MaxA) && in(mood.D, r.MinD, r.MaxD)
+}
+
+// Policy maps a soul's PAD state and personality to prosody, so an excited
+// soul speaks faster, higher and louder than a bored one. It is plain data
+// and can be tuned from a JSON file.
+type Policy struct {
+	Speed  Axis `json:"speed"`
+	Pitch  Axis `json:"pitch"`
+	Energy Axis `json:"energy"`
+	// Voices are tried in order; the first match overrides the default voice.
+	Voices []VoiceRule `json:"voices,omitempty"`
+}
+
+// DefaultPolicy leans mostly on arousal: it drives rate, pitch and loudness
+// together the way it does in people, while pleasure and expressiveness add
+// lift and boredom flattens the delivery. Ranges stay where the backends
+// still sound natural.
+func DefaultPolicy() Policy {
+	return Policy{
+		Speed: Axis{
+			Weights: Weights{P: 0.04, A: 0.14, Boredom: -0.10, Expressiveness: 0.04, Stability: -0.03},
+			Min:     0.8,
+			Max:     1.25,
+		},
+		Pitch: Axis{
+			Weights: Weights{P: 0.04, A: 0.06, D: -0.03, Boredom: -0.03, Expressiveness: 0.02},
+			Min:     0.9,
+			Max:     1.12,
+		},
+		Energy: Axis{
+			Weights: Weights{A: 0.20, D: 0.10, Boredom: -0.20, Expressiveness: 0.10, Dominance: 0.05},
+			Min:     0.6,
+			Max:     1.4,
+		},
+	}
+}
+
+// LoadPolicy reads a policy from a JSON file over the defaults, so it only
+// needs the fields it changes. An empty path yields DefaultPolicy.
+func LoadPolicy(path string) (Policy, error) {
+	policy := DefaultPolicy()
+	if path == "" {
+		return policy, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Policy{}, fmt.Errorf("read tts policy: %w", err)
+	}
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return Policy{}, fmt.Errorf("parse tts policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// Prosody shapes base, the configured delivery, for the soul's state.
+func (p Policy) Prosody(base Prosody, mood domain.SoulEmotionState, pv domain.PersonalityVector) Prosody {
+	out := Prosody{
+		Voice:  base.Voice,
+		Speed:  p.Speed.value(orOne(base.Speed), mood, pv),
+		Pitch:  p.Pitch.value(orOne(base.Pitch), mood, pv),
+		Energy: p.Energy.value(orOne(base.Energy), mood, pv),
+	}
+	for _, rule := range p.Voices {
+		if rule.matches(mood) {
+			out.Voice = rule.Voice
+			break
+		}
+	}
+	return out
+}
+
+func orOne(v float64) float64 {
+	if v == 0 {
+		return 1
+	}
+	return v
+}
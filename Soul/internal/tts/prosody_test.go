@@ -0,0 +1,61 @@
+package tts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+var averageSoul = domain.PersonalityVector{Empathy: 0.5, Sensitivity: 0.5, Stability: 0.5, Expressiveness: 0.5, Dominance: 0.5}
+
+func TestPolicyExcitedSoulSoundsLivelierThanBored(t *testing.T) {
+	p := DefaultPolicy()
+	base := Prosody{Speed: 1}
+	excited := p.Prosody(base, domain.SoulEmotionState{P: 0.6, A: 0.8}, averageSoul)
+	bored := p.Prosody(base, domain.SoulEmotionState{P: -0.2, A: -0.6, Boredom: 0.9}, averageSoul)
+	if excited.Speed <= 1 || excited.Pitch <= 1 || excited.Energy <= 1 {
+		t.Fatalf("excited prosody = %+v, want all above 1", excited)
+	}
+	if bored.Speed >= 1 || bored.Pitch >= 1 || bored.Energy >= 1 {
+		t.Fatalf("bored prosody = %+v, want all below 1", bored)
+	}
+	if neutral := p.Prosody(base, domain.SoulEmotionState{}, averageSoul); neutral != (Prosody{Speed: 1, Pitch: 1, Energy: 1}) {
+		t.Fatalf("neutral prosody = %+v, want the base delivery", neutral)
+	}
+}
+
+func TestPolicyClampsAndPicksVoice(t *testing.T) {
+	low := -0.3
+	p := DefaultPolicy()
+	p.Voices = []VoiceRule{{Voice: "gloomy", MaxP: &low}}
+	got := p.Prosody(Prosody{Speed: 1, Voice: "default"}, domain.SoulEmotionState{P: -1, A: -1, D: 1, Boredom: 1}, averageSoul)
+	if got.Voice != "gloomy" {
+		t.Fatalf("voice = %q, want gloomy", got.Voice)
+	}
+	if got.Speed != p.Speed.Min || got.Energy < p.Energy.Min {
+		t.Fatalf("prosody = %+v, want speed clamped to %v", got, p.Speed.Min)
+	}
+	if v := p.Prosody(Prosody{Voice: "default"}, domain.SoulEmotionState{}, averageSoul).Voice; v != "default" {
+		t.Fatalf("voice = %q, want default outside the rule", v)
+	}
+}
+
+func TestLoadPolicyOverlaysDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"speed":{"weights":{"a":0.3}},"voices":[{"voice":"bright","min_a":0.5}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	def := DefaultPolicy()
+	if p.Speed.Weights.A != 0.3 || p.Speed.Weights.Boredom != def.Speed.Weights.Boredom || p.Speed.Max != def.Speed.Max {
+		t.Fatalf("speed axis = %+v, want a=0.3 over the defaults", p.Speed)
+	}
+	if p.Pitch != def.Pitch || len(p.Voices) != 1 || p.Voices[0].Voice != "bright" {
+		t.Fatalf("policy = %+v", p)
+	}
+}
@@ -0,0 +1,123 @@
+package tts
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Splitter cuts a streamed reply into sentences so each can be synthesized
+// while the model is still writing the next. Very short sentences are held
+// and joined to the following one, since every TTS call pays a fixed start-up
+// cost; very long ones are cut at a comma so the first audio is not delayed
+// by a run-on sentence.
+type Splitter struct {
+	// MinRunes is the shortest sentence emitted on its own.
+	MinRunes int
+	// MaxRunes forces a cut when no sentence end has appeared.
+	MaxRunes int
+
+	buf []rune
+}
+
+func NewSplitter(minRunes, maxRunes int) *Splitter {
+	return &Splitter{MinRunes: minRunes, MaxRunes: maxRunes}
+}
+
+// Push appends a delta and returns the sentences it completed.
+func (s *Splitter) Push(delta string) []string {
+	s.buf = append(s.buf, []rune(delta)...)
+	var out []string
+	for {
+		cut := s.nextCut()
+		if cut == 0 {
+			return out
+		}
+		if sentence := strings.TrimSpace(string(s.buf[:cut])); sentence != "" {
+			out = append(out, sentence)
+		}
+		s.buf = s.buf[cut:]
+	}
+}
+
+// Flush returns whatever is left once the reply is complete.
+func (s *Splitter) Flush() string {
+	rest := strings.TrimSpace(string(s.buf))
+	s.buf = nil
+	return rest
+}
+
+// nextCut is the length of the first complete sentence in buf, or zero.
+func (s *Splitter) nextCut() int {
+	for i := 0; i < len(s.buf); i++ {
+		r := s.buf[i]
+		if !isSentenceEnd(r) {
+			continue
+		}
+		// An ASCII period only ends a sentence before whitespace, so
+		// "3.5" and "v1.2" stay whole; wait for the next delta to tell.
+		if r == '.' {
+			if i+1 == len(s.buf) {
+				break
+			}
+			if !unicode.IsSpace(s.buf[i+1]) {
+				continue
+			}
+		}
+		end := i + 1
+		for end < len(s.buf) && (isSentenceEnd(s.buf[end]) || isClosing(s.buf[end])) {
+			end++
+		}
+		// Trailing punctuation may still be on its way.
+		if end == len(s.buf) && r != '\n' {
+			break
+		}
+		if countLetters(s.buf[:end]) >= s.MinRunes {
+			return end
+		}
+		i = end - 1
+	}
+	if s.MaxRunes > 0 && len(s.buf) >= s.MaxRunes {
+		for i := s.MaxRunes - 1; i >= s.MinRunes; i-- {
+			if isSoftBreak(s.buf[i]) {
+				return i + 1
+			}
+		}
+		return s.MaxRunes
+	}
+	return 0
+}
+
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '。', '！', '？', '!', '?', '；', ';', '…', '\n', '.':
+		return true
+	}
+	return false
+}
+
+func isClosing(r rune) bool {
+	switch r {
+	case '”', '’', '」', '』', '）', ')', '"', '\'', '】', '》':
+		return true
+	}
+	return false
+}
+
+func isSoftBreak(r rune) bool {
+	switch r {
+	case '，', ',', '、', '：', ':', ' ':
+		return true
+	}
+	return false
+}
+
+// countLetters ignores punctuation and spaces, so "好。" counts as one.
+func countLetters(rs []rune) int {
+	n := 0
+	for _, r := range rs {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,60 @@
+package tts
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func splitAll(s *Splitter, deltas ...string) []string {
+	var out []string
+	for _, d := range deltas {
+		out = append(out, s.Push(d)...)
+	}
+	if rest := s.Flush(); rest != "" {
+		out = append(out, rest)
+	}
+	return out
+}
+
+func TestSplitterCutsAtSentenceEnds(t *testing.T) {
+	got := splitAll(NewSplitter(4, 60), "今天天气", "不错。我们去公", "园散步吧！", "好不好？")
+	want := []string{"今天天气不错。", "我们去公园散步吧！", "好不好？"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitterEmitsBeforeReplyEnds(t *testing.T) {
+	s := NewSplitter(4, 60)
+	if got := s.Push("第一句说完了。"); len(got) != 0 {
+		t.Fatalf("cut before seeing what follows the period: %q", got)
+	}
+	if got := s.Push("第二"); !reflect.DeepEqual(got, []string{"第一句说完了。"}) {
+		t.Fatalf("got %q, want the first sentence once the next one starts", got)
+	}
+}
+
+func TestSplitterMergesShortSentences(t *testing.T) {
+	got := splitAll(NewSplitter(4, 60), "好。", "我这就帮你关灯。")
+	want := []string{"好。我这就帮你关灯。"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitterKeepsDecimalsAndClosingQuotes(t *testing.T) {
+	got := splitAll(NewSplitter(4, 60), "Version 3.5 is out. ", "他说：“明天见。”然后走了。")
+	want := []string{"Version 3.5 is out.", "他说：“明天见。”", "然后走了。"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSplitterCutsRunOnAtComma(t *testing.T) {
+	long := strings.Repeat("很长的句子", 3) + "，" + strings.Repeat("没有句号", 4)
+	got := NewSplitter(4, 20).Push(long)
+	if len(got) != 1 || got[0] != strings.Repeat("很长的句子", 3)+"，" {
+		t.Fatalf("got %q, want a cut at the comma", got)
+	}
+}
@@ -0,0 +1,29 @@
+// Package tts turns reply text into speech for the voice path. Engines
+// return mono PCM16LE as it is produced, at the backend's own sample rate.
+package tts
+
+import (
+	"context"
+	"io"
+)
+
+type Request struct {
+	Text string
+	// Voice picks a backend speaker; empty uses the engine default.
+	Voice string
+	// Speed scales speaking rate; zero means 1.
+	Speed float64
+}
+
+// Audio streams one synthesized utterance. Body must be closed.
+type Audio struct {
+	SampleRate int
+	Body       io.ReadCloser
+}
+
+type Engine interface {
+	Name() string
+	// Synthesize starts synthesis and returns once the backend has begun
+	// answering, so the first chunk can be played while the rest renders.
+	Synthesize(ctx context.Context, req Request) (*Audio, error)
+}
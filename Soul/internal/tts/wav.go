@@ -0,0 +1,56 @@
+package tts
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// readWAVHeader consumes a RIFF header up to the data chunk and returns the
+// sample rate. Only mono PCM16 is accepted, which is what the voice path
+// plays.
+func readWAVHeader(r io.Reader) (int, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return 0, fmt.Errorf("read wav header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return 0, errors.New("not a RIFF/WAVE stream")
+	}
+	rate := 0
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return 0, fmt.Errorf("read wav chunk: %w", err)
+		}
+		size := int64(binary.LittleEndian.Uint32(hdr[4:]))
+		switch string(hdr[0:4]) {
+		case "fmt ":
+			if size < 16 {
+				return 0, errors.New("short wav fmt chunk")
+			}
+			fmtChunk := make([]byte, size)
+			if _, err := io.ReadFull(r, fmtChunk); err != nil {
+				return 0, fmt.Errorf("read wav fmt: %w", err)
+			}
+			format := binary.LittleEndian.Uint16(fmtChunk[0:])
+			channels := binary.LittleEndian.Uint16(fmtChunk[2:])
+			bits := binary.LittleEndian.Uint16(fmtChunk[14:])
+			if format != 1 || channels != 1 || bits != 16 {
+				return 0, fmt.Errorf("unsupported wav: format=%d channels=%d bits=%d, want mono PCM16", format, channels, bits)
+			}
+			rate = int(binary.LittleEndian.Uint32(fmtChunk[4:]))
+		case "data":
+			if rate == 0 {
+				return 0, errors.New("wav data before fmt chunk")
+			}
+			return rate, nil
+		default:
+			// Chunks are word aligned.
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return 0, fmt.Errorf("skip wav chunk: %w", err)
+			}
+		}
+	}
+}
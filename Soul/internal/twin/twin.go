@@ -0,0 +1,212 @@
+// Package twin keeps each terminal's desired state and reconciles the
+// terminal towards it, so a power cycle does not silently put the robot
+// back to its defaults.
+package twin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"soul/internal/domain"
+)
+
+// ErrInvalidDesiredState wraps the reasons Set rejects a desired state.
+var ErrInvalidDesiredState = errors.New("invalid desired state")
+
+type Store interface {
+	ListTerminalDesiredStates(ctx context.Context) ([]domain.TerminalDesiredState, error)
+	UpsertTerminalDesiredState(ctx context.Context, in domain.TerminalDesiredState) (domain.TerminalDesiredState, error)
+	DeleteTerminalDesiredState(ctx context.Context, terminalID string) error
+}
+
+// Terminals invokes skills on terminals and reports what they show.
+type Terminals interface {
+	InvokeSkill(ctx context.Context, terminalID, skill string, args json.RawMessage) (domain.InvokeResult, error)
+	TerminalState(terminalID string) (domain.TerminalShadow, bool)
+}
+
+// Skills says which skills a terminal offers right now.
+type Skills interface {
+	FindSkill(terminalID, name string) (domain.SkillDefinition, bool)
+	UpgradeFor(terminalID, skill string) (domain.UpgradeItem, bool)
+	IsDryRun(terminalID string) bool
+}
+
+// Reconciler caches the desired states and re-issues the skills a terminal
+// diverges on.
+type Reconciler struct {
+	store     Store
+	terminals Terminals
+	skills    Skills
+	logger    *slog.Logger
+
+	mu      sync.RWMutex
+	desired map[string]domain.TerminalDesiredState
+}
+
+func NewReconciler(store Store, terminals Terminals, skills Skills, logger *slog.Logger) *Reconciler {
+	return &Reconciler{
+		store:     store,
+		terminals: terminals,
+		skills:    skills,
+		logger:    logger,
+		desired:   make(map[string]domain.TerminalDesiredState),
+	}
+}
+
+// Load replaces the cached desired states with the stored ones.
+func (r *Reconciler) Load(ctx context.Context) error {
+	items, err := r.store.ListTerminalDesiredStates(ctx)
+	if err != nil {
+		return err
+	}
+	desired := make(map[string]domain.TerminalDesiredState, len(items))
+	for _, item := range items {
+		desired[item.TerminalID] = item
+	}
+	r.mu.Lock()
+	r.desired = desired
+	r.mu.Unlock()
+	return nil
+}
+
+// Get returns the terminal's desired state with the skills it currently
+// diverges on; false when none is set.
+func (r *Reconciler) Get(terminalID string) (domain.TerminalDesiredState, bool) {
+	r.mu.RLock()
+	item, ok := r.desired[terminalID]
+	r.mu.RUnlock()
+	if !ok {
+		return domain.TerminalDesiredState{}, false
+	}
+	shadow, _ := r.terminals.TerminalState(terminalID)
+	item.Divergent = divergent(item, shadow)
+	return item, true
+}
+
+// Set validates and stores the terminal's desired state; each skill's
+// arguments must be a JSON object.
+func (r *Reconciler) Set(ctx context.Context, terminalID string, skills map[string]json.RawMessage) (domain.TerminalDesiredState, error) {
+	terminalID = strings.TrimSpace(terminalID)
+	if terminalID == "" {
+		return domain.TerminalDesiredState{}, fmt.Errorf("%w: terminal_id is required", ErrInvalidDesiredState)
+	}
+	if len(skills) == 0 {
+		return domain.TerminalDesiredState{}, fmt.Errorf("%w: skills is required", ErrInvalidDesiredState)
+	}
+	clean := make(map[string]json.RawMessage, len(skills))
+	for name, args := range skills {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return domain.TerminalDesiredState{}, fmt.Errorf("%w: skill name is required", ErrInvalidDesiredState)
+		}
+		var obj map[string]any
+		if err := json.Unmarshal(args, &obj); err != nil || obj == nil {
+			return domain.TerminalDesiredState{}, fmt.Errorf("%w: arguments of %s must be a JSON object", ErrInvalidDesiredState, name)
+		}
+		// Re-encoding sorts the keys, so equal arguments compare equal.
+		normalized, _ := json.Marshal(obj)
+		clean[name] = normalized
+	}
+	stored, err := r.store.UpsertTerminalDesiredState(ctx, domain.TerminalDesiredState{TerminalID: terminalID, Skills: clean})
+	if err != nil {
+		return domain.TerminalDesiredState{}, err
+	}
+	r.mu.Lock()
+	r.desired[terminalID] = stored
+	r.mu.Unlock()
+	shadow, _ := r.terminals.TerminalState(terminalID)
+	stored.Divergent = divergent(stored, shadow)
+	return stored, nil
+}
+
+// Delete drops the terminal's desired state; the terminal is left as is.
+func (r *Reconciler) Delete(ctx context.Context, terminalID string) error {
+	if err := r.store.DeleteTerminalDesiredState(ctx, terminalID); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.desired, terminalID)
+	r.mu.Unlock()
+	return nil
+}
+
+// Reconcile re-issues, in skill name order, every desired skill the
+// terminal diverges on. Skills the terminal does not offer, or that run on
+// the server, or that need a firmware upgrade are skipped; nothing is sent
+// in dry-run mode.
+func (r *Reconciler) Reconcile(ctx context.Context, terminalID string) domain.ReconcileResult {
+	out := domain.ReconcileResult{TerminalID: terminalID, Items: []domain.ReconcileItem{}}
+	r.mu.RLock()
+	item, ok := r.desired[terminalID]
+	r.mu.RUnlock()
+	if !ok {
+		return out
+	}
+	if r.skills.IsDryRun(terminalID) {
+		r.logger.Info("desired state reconcile skipped in dry run", "terminal_id", terminalID)
+		return out
+	}
+	shadow, _ := r.terminals.TerminalState(terminalID)
+	for _, skill := range divergent(item, shadow) {
+		def, ok := r.skills.FindSkill(terminalID, skill)
+		if !ok || def.URL != "" {
+			r.logger.Warn("desired skill not offered by terminal", "terminal_id", terminalID, "skill", skill)
+			continue
+		}
+		if up, ok := r.skills.UpgradeFor(terminalID, skill); ok {
+			r.logger.Warn("desired skill needs firmware upgrade", "terminal_id", terminalID, "skill", skill, "firmware", up.Current, "required", up.Required)
+			continue
+		}
+		args := item.Skills[skill]
+		res := domain.ReconcileItem{Skill: skill, Arguments: args, OK: true}
+		if _, err := r.terminals.InvokeSkill(ctx, terminalID, skill, args); err != nil {
+			res.OK = false
+			res.Error = err.Error()
+			r.logger.Warn("desired state reconcile failed", "terminal_id", terminalID, "skill", skill, "error", err)
+		}
+		out.Items = append(out.Items, res)
+	}
+	if len(out.Items) > 0 {
+		r.logger.Info("desired state reconciled", "terminal_id", terminalID, "skills", len(out.Items))
+	}
+	return out
+}
+
+// HandleReconnect reconciles a terminal that just came back; it is meant
+// for the MQTT hub's reconnect hook.
+func (r *Reconciler) HandleReconnect(terminalID string) {
+	r.Reconcile(context.Background(), terminalID)
+}
+
+// divergent lists, sorted, the desired skills whose last call in the
+// shadow failed or used other arguments.
+func divergent(desired domain.TerminalDesiredState, shadow domain.TerminalShadow) []string {
+	out := make([]string, 0, len(desired.Skills))
+	for skill, args := range desired.Skills {
+		last, ok := shadow.Skills[skill]
+		if ok && last.OK && sameArguments(last.Arguments, args) {
+			continue
+		}
+		out = append(out, skill)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sameArguments(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return bytes.Equal(ca, cb)
+}
@@ -0,0 +1,114 @@
+package twin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+
+	"soul/internal/domain"
+)
+
+type memStore struct {
+	items map[string]domain.TerminalDesiredState
+}
+
+func (m *memStore) ListTerminalDesiredStates(context.Context) ([]domain.TerminalDesiredState, error) {
+	out := make([]domain.TerminalDesiredState, 0, len(m.items))
+	for _, item := range m.items {
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func (m *memStore) UpsertTerminalDesiredState(_ context.Context, in domain.TerminalDesiredState) (domain.TerminalDesiredState, error) {
+	m.items[in.TerminalID] = in
+	return in, nil
+}
+
+func (m *memStore) DeleteTerminalDesiredState(_ context.Context, terminalID string) error {
+	delete(m.items, terminalID)
+	return nil
+}
+
+type fakeTerminal struct {
+	shadow  domain.TerminalShadow
+	skills  map[string]domain.SkillDefinition
+	invoked []string
+}
+
+func (f *fakeTerminal) InvokeSkill(_ context.Context, _ string, skill string, args json.RawMessage) (domain.InvokeResult, error) {
+	f.invoked = append(f.invoked, skill+" "+string(args))
+	if skill == "set_volume" {
+		return domain.InvokeResult{}, errors.New("tool timeout")
+	}
+	return domain.InvokeResult{OK: true}, nil
+}
+
+func (f *fakeTerminal) TerminalState(string) (domain.TerminalShadow, bool) {
+	return f.shadow, true
+}
+
+func (f *fakeTerminal) FindSkill(_ string, name string) (domain.SkillDefinition, bool) {
+	def, ok := f.skills[name]
+	return def, ok
+}
+
+func (f *fakeTerminal) UpgradeFor(string, string) (domain.UpgradeItem, bool) {
+	return domain.UpgradeItem{}, false
+}
+
+func (f *fakeTerminal) IsDryRun(string) bool { return false }
+
+func TestReconcileReissuesDivergentSkills(t *testing.T) {
+	term := &fakeTerminal{
+		shadow: domain.TerminalShadow{Skills: map[string]domain.ShadowAction{
+			"control_light":   {Skill: "control_light", Arguments: json.RawMessage(`{"mode":"set_color","color":"red"}`), OK: true},
+			"set_head_motion": {Skill: "set_head_motion", Arguments: json.RawMessage(`{"action":"点头"}`), OK: false},
+		}},
+		skills: map[string]domain.SkillDefinition{
+			"control_light":   {Name: "control_light"},
+			"set_head_motion": {Name: "set_head_motion"},
+			"set_volume":      {Name: "set_volume"},
+			"send_email":      {Name: "send_email", URL: "https://mail.example.com/send"},
+		},
+	}
+	r := NewReconciler(&memStore{items: map[string]domain.TerminalDesiredState{}}, term, term, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if _, err := r.Set(context.Background(), "t1", map[string]json.RawMessage{"control_light": json.RawMessage(`"red"`)}); !errors.Is(err, ErrInvalidDesiredState) {
+		t.Fatalf("non-object arguments must be rejected, got %v", err)
+	}
+	item, err := r.Set(context.Background(), "t1", map[string]json.RawMessage{
+		"control_light":   json.RawMessage(`{"color": "red", "mode": "set_color"}`),
+		"set_head_motion": json.RawMessage(`{"action":"点头"}`),
+		"set_volume":      json.RawMessage(`{"level":3}`),
+		"send_email":      json.RawMessage(`{"to":"a@example.com"}`),
+		"set_expression":  json.RawMessage(`{"expression":"微笑"}`),
+	})
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	want := []string{"send_email", "set_expression", "set_head_motion", "set_volume"}
+	if len(item.Divergent) != len(want) {
+		t.Fatalf("divergent = %v, want %v", item.Divergent, want)
+	}
+	for i := range want {
+		if item.Divergent[i] != want[i] {
+			t.Fatalf("divergent = %v, want %v", item.Divergent, want)
+		}
+	}
+
+	res := r.Reconcile(context.Background(), "t1")
+	if len(term.invoked) != 2 || term.invoked[0] != `set_head_motion {"action":"点头"}` || term.invoked[1] != `set_volume {"level":3}` {
+		t.Fatalf("only divergent terminal skills must be re-issued, got %v", term.invoked)
+	}
+	if len(res.Items) != 2 || !res.Items[0].OK || res.Items[1].OK || res.Items[1].Error != "tool timeout" {
+		t.Fatalf("unexpected reconcile result %+v", res.Items)
+	}
+
+	if res := r.Reconcile(context.Background(), "t2"); len(res.Items) != 0 {
+		t.Fatalf("terminal without desired state must not be touched, got %+v", res.Items)
+	}
+}
@@ -0,0 +1,261 @@
+// Package vadx segments a PCM16LE stream into utterances with an
+// energy-based voice activity detector. It needs no model, so it can run in
+// front of any ASR engine, including ones without their own VAD.
+package vadx
+
+import (
+	"math"
+	"time"
+
+	"soul/internal/audio"
+)
+
+type Config struct {
+	// FrameMS is the analysis window.
+	FrameMS int
+	// ThresholdDB is the lowest frame level, in dBFS, that counts as voice.
+	ThresholdDB float64
+	// NoiseMarginDB raises the threshold above the tracked noise floor so a
+	// noisy room does not read as continuous speech.
+	NoiseMarginDB float64
+	// MinSpeechMS of consecutive voiced frames opens a segment.
+	MinSpeechMS int
+	// SilenceMS of consecutive unvoiced frames closes a segment. It trades
+	// responsiveness against cutting users off mid-pause.
+	SilenceMS int
+	// PreRollMS of audio before the speech onset is kept so the first
+	// syllable is not clipped.
+	PreRollMS int
+	// MaxSegmentMS force-closes a segment that never goes quiet.
+	MaxSegmentMS int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		FrameMS:       20,
+		ThresholdDB:   -45,
+		NoiseMarginDB: 12,
+		MinSpeechMS:   120,
+		SilenceMS:     700,
+		PreRollMS:     200,
+		MaxSegmentMS:  30000,
+	}
+}
+
+type EventType string
+
+const (
+	// SpeechStart opens a segment; PCM holds the pre-roll and the frames
+	// that confirmed the onset.
+	SpeechStart EventType = "speech_start"
+	// SpeechAudio carries segment audio after the start.
+	SpeechAudio EventType = "speech_audio"
+	// SpeechEnd closes the segment; Reason says why.
+	SpeechEnd EventType = "speech_end"
+)
+
+const (
+	ReasonSilence   = "silence"
+	ReasonMaxLength = "max_length"
+	ReasonFlush     = "flush"
+)
+
+type Event struct {
+	Type EventType
+	PCM  []byte
+	// Offset is the stream position of the segment start for SpeechStart
+	// and of the segment end for SpeechEnd.
+	Offset time.Duration
+	// Duration is the segment length, set on SpeechEnd.
+	Duration time.Duration
+	// Trailing is the unvoiced tail of the segment on SpeechEnd: how long
+	// the speaker had been quiet when the segment closed.
+	Trailing time.Duration
+	Reason   string
+}
+
+// Detector is not safe for concurrent use; each stream owns one.
+type Detector struct {
+	cfg        Config
+	frameBytes int
+	frameDur   time.Duration
+
+	minSpeechFrames int
+	silenceFrames   int
+	preRollFrames   int
+	maxFrames       int
+
+	pending []byte
+	frames  int
+	noiseDB float64
+
+	history    [][]byte
+	voicedRun  int
+	inSpeech   bool
+	silenceRun int
+	segStart   int
+	segFrames  int
+}
+
+func NewDetector(cfg Config) *Detector {
+	def := DefaultConfig()
+	if cfg.FrameMS <= 0 {
+		cfg.FrameMS = def.FrameMS
+	}
+	if cfg.ThresholdDB == 0 {
+		cfg.ThresholdDB = def.ThresholdDB
+	}
+	if cfg.NoiseMarginDB <= 0 {
+		cfg.NoiseMarginDB = def.NoiseMarginDB
+	}
+	if cfg.MinSpeechMS <= 0 {
+		cfg.MinSpeechMS = def.MinSpeechMS
+	}
+	if cfg.SilenceMS <= 0 {
+		cfg.SilenceMS = def.SilenceMS
+	}
+	if cfg.PreRollMS < 0 {
+		cfg.PreRollMS = 0
+	}
+	if cfg.MaxSegmentMS <= 0 {
+		cfg.MaxSegmentMS = def.MaxSegmentMS
+	}
+	frames := func(ms int) int { return max(1, (ms+cfg.FrameMS-1)/cfg.FrameMS) }
+	return &Detector{
+		cfg:             cfg,
+		frameBytes:      audio.SampleRate * cfg.FrameMS / 1000 * 2,
+		frameDur:        time.Duration(cfg.FrameMS) * time.Millisecond,
+		minSpeechFrames: frames(cfg.MinSpeechMS),
+		silenceFrames:   frames(cfg.SilenceMS),
+		preRollFrames:   cfg.PreRollMS / cfg.FrameMS,
+		maxFrames:       frames(cfg.MaxSegmentMS),
+		noiseDB:         cfg.ThresholdDB - cfg.NoiseMarginDB,
+	}
+}
+
+// Push feeds PCM16LE at audio.SampleRate and returns the events it caused,
+// with consecutive SpeechAudio frames merged.
+func (d *Detector) Push(pcm16le []byte) []Event {
+	d.pending = append(d.pending, pcm16le...)
+	var events []Event
+	for len(d.pending) >= d.frameBytes {
+		frame := make([]byte, d.frameBytes)
+		copy(frame, d.pending)
+		d.pending = d.pending[d.frameBytes:]
+		events = d.frame(frame, events)
+	}
+	if len(d.pending) == 0 {
+		d.pending = nil
+	}
+	return events
+}
+
+// Flush closes an open segment, e.g. when the client stops talking to us.
+func (d *Detector) Flush() []Event {
+	d.pending = nil
+	d.history = nil
+	d.voicedRun = 0
+	if !d.inSpeech {
+		return nil
+	}
+	return []Event{d.end(ReasonFlush)}
+}
+
+// InSpeech reports whether a segment is open.
+func (d *Detector) InSpeech() bool {
+	return d.inSpeech
+}
+
+func (d *Detector) frame(frame []byte, events []Event) []Event {
+	d.frames++
+	level := frameDB(frame)
+	voiced := level > math.Max(d.cfg.ThresholdDB, d.noiseDB+d.cfg.NoiseMarginDB)
+	d.trackNoise(level)
+
+	if !d.inSpeech {
+		d.history = append(d.history, frame)
+		if keep := d.preRollFrames + d.minSpeechFrames; len(d.history) > keep {
+			d.history = d.history[len(d.history)-keep:]
+		}
+		if !voiced {
+			d.voicedRun = 0
+			return events
+		}
+		d.voicedRun++
+		if d.voicedRun < d.minSpeechFrames {
+			return events
+		}
+		d.inSpeech = true
+		d.silenceRun = 0
+		d.segFrames = len(d.history)
+		d.segStart = d.frames - d.segFrames
+		var pcm []byte
+		for _, f := range d.history {
+			pcm = append(pcm, f...)
+		}
+		d.history = nil
+		d.voicedRun = 0
+		return append(events, Event{Type: SpeechStart, PCM: pcm, Offset: time.Duration(d.segStart) * d.frameDur})
+	}
+
+	d.segFrames++
+	if n := len(events); n > 0 && events[n-1].Type == SpeechAudio {
+		events[n-1].PCM = append(events[n-1].PCM, frame...)
+	} else {
+		events = append(events, Event{Type: SpeechAudio, PCM: frame})
+	}
+	if voiced {
+		d.silenceRun = 0
+	} else {
+		d.silenceRun++
+	}
+	switch {
+	case d.silenceRun >= d.silenceFrames:
+		events = append(events, d.end(ReasonSilence))
+	case d.segFrames >= d.maxFrames:
+		events = append(events, d.end(ReasonMaxLength))
+	}
+	return events
+}
+
+// trackNoise follows the quietest recent level: it drops quickly into pauses
+// and creeps up slowly, so steady hum is absorbed within a second or two while
+// the gaps between syllables keep speech above the floor.
+func (d *Detector) trackNoise(level float64) {
+	rate := 0.01
+	if level < d.noiseDB {
+		rate = 0.2
+	}
+	d.noiseDB = math.Max(-90, d.noiseDB+rate*(level-d.noiseDB))
+}
+
+func (d *Detector) end(reason string) Event {
+	ev := Event{
+		Type:     SpeechEnd,
+		Offset:   time.Duration(d.segStart+d.segFrames) * d.frameDur,
+		Duration: time.Duration(d.segFrames) * d.frameDur,
+		Trailing: time.Duration(d.silenceRun) * d.frameDur,
+		Reason:   reason,
+	}
+	d.inSpeech = false
+	d.silenceRun = 0
+	return ev
+}
+
+// frameDB is the frame's RMS level in dBFS, floored at -100 for silence.
+func frameDB(frame []byte) float64 {
+	samples := audio.Samples(frame)
+	if len(samples) == 0 {
+		return -100
+	}
+	var sum float64
+	for _, s := range samples {
+		v := float64(s) / 32768
+		sum += v * v
+	}
+	rms := math.Sqrt(sum / float64(len(samples)))
+	if rms <= 1e-5 {
+		return -100
+	}
+	return 20 * math.Log10(rms)
+}
@@ -0,0 +1,129 @@
+package vadx
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"soul/internal/audio"
+)
+
+func tone(ms int, amplitude float64) []byte {
+	n := audio.SampleRate * ms / 1000
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(amplitude * math.Sin(2*math.Pi*220*float64(i)/audio.SampleRate))
+	}
+	return audio.Bytes(samples)
+}
+
+func silence(ms int) []byte {
+	return make([]byte, audio.SampleRate*ms/1000*2)
+}
+
+func collect(d *Detector, chunks ...[]byte) []Event {
+	var events []Event
+	for _, c := range chunks {
+		// Feed in 10ms pieces to exercise frame reassembly.
+		for len(c) > 0 {
+			n := min(len(c), 320)
+			events = append(events, d.Push(c[:n])...)
+			c = c[n:]
+		}
+	}
+	return events
+}
+
+func TestDetectorSegmentsOnSilence(t *testing.T) {
+	d := NewDetector(DefaultConfig())
+	events := collect(d, silence(1000), tone(1000, 8000), silence(1000))
+
+	var start, end *Event
+	var audioBytes int
+	for i := range events {
+		switch events[i].Type {
+		case SpeechStart:
+			start = &events[i]
+			audioBytes += len(events[i].PCM)
+		case SpeechAudio:
+			audioBytes += len(events[i].PCM)
+		case SpeechEnd:
+			end = &events[i]
+		}
+	}
+	if start == nil || end == nil {
+		t.Fatalf("want start and end, got %+v", events)
+	}
+	// Onset at 1000ms minus the 200ms pre-roll.
+	if start.Offset != 800*time.Millisecond {
+		t.Fatalf("start offset = %s, want 800ms", start.Offset)
+	}
+	if end.Reason != ReasonSilence {
+		t.Fatalf("end reason = %s, want silence", end.Reason)
+	}
+	// Pre-roll + tone + silence window.
+	if end.Duration != 1900*time.Millisecond {
+		t.Fatalf("segment duration = %s, want 1.9s", end.Duration)
+	}
+	if got := audio.Duration(audioBytes); got != end.Duration {
+		t.Fatalf("emitted %s of audio, want %s", got, end.Duration)
+	}
+	if end.Trailing != 700*time.Millisecond {
+		t.Fatalf("trailing silence = %s, want the 700ms window", end.Trailing)
+	}
+	if d.InSpeech() {
+		t.Fatal("detector still in speech after silence")
+	}
+}
+
+func TestDetectorIgnoresShortClicks(t *testing.T) {
+	d := NewDetector(DefaultConfig())
+	events := collect(d, silence(200), tone(60, 8000), silence(500))
+	if len(events) != 0 {
+		t.Fatalf("click opened a segment: %+v", events)
+	}
+}
+
+func TestDetectorMaxLengthAndFlush(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxSegmentMS = 500
+	d := NewDetector(cfg)
+	events := collect(d, tone(600, 8000))
+
+	var ends []Event
+	for _, ev := range events {
+		if ev.Type == SpeechEnd {
+			ends = append(ends, ev)
+		}
+	}
+	if len(ends) != 1 || ends[0].Reason != ReasonMaxLength {
+		t.Fatalf("want one max_length end, got %+v", ends)
+	}
+
+	collect(d, tone(300, 8000))
+	if !d.InSpeech() {
+		t.Fatal("continued speech should reopen a segment")
+	}
+	flushed := d.Flush()
+	if len(flushed) != 1 || flushed[0].Reason != ReasonFlush {
+		t.Fatalf("flush = %+v", flushed)
+	}
+	if d.Flush() != nil {
+		t.Fatal("second flush should be empty")
+	}
+}
+
+func TestDetectorAdaptsToNoiseFloor(t *testing.T) {
+	d := NewDetector(DefaultConfig())
+	// Steady hum above the absolute threshold: it may open a segment, but
+	// the floor rises until it no longer counts as speech.
+	events := collect(d, tone(3000, 600))
+	if d.InSpeech() {
+		t.Fatalf("constant hum held a segment open: %d events", len(events))
+	}
+	// Speech over the hum is still detected.
+	events = collect(d, tone(500, 8000))
+	if len(events) == 0 || events[0].Type != SpeechStart {
+		t.Fatalf("speech over hum not detected: %+v", events)
+	}
+}
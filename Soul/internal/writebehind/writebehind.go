@@ -0,0 +1,155 @@
+// Package writebehind runs writes the reply does not depend on — tool
+// outputs, observation digests, turn replays — after the reply has been
+// sent, so a slow database only delays what nobody is waiting for.
+package writebehind
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Config struct {
+	// Size bounds queued writes; past it new writes are dropped and
+	// logged rather than blocking the reply.
+	Size int
+	// Workers run writes concurrently; writes of one session may then land
+	// out of order, so they must not depend on each other.
+	Workers int
+	// Timeout bounds each write.
+	Timeout time.Duration
+}
+
+type job struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Queue is an in-process write-behind queue. A nil *Queue runs every write
+// inline, which keeps callers simple where deferring is off.
+type Queue struct {
+	cfg    Config
+	logger *slog.Logger
+	jobs   chan job
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// New starts the workers; Close stops them once the queue is drained.
+func New(cfg Config, logger *slog.Logger) *Queue {
+	if cfg.Size <= 0 {
+		cfg.Size = 1024
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 2
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	q := &Queue{cfg: cfg, logger: logger, jobs: make(chan job, cfg.Size)}
+	for range cfg.Workers {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Do queues fn, or runs it now with ctx on a nil or closed queue. name
+// identifies the write in logs. Queued writes run detached from ctx, which
+// usually ends with the request.
+func (q *Queue) Do(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	if q == nil {
+		if err := fn(ctx); err != nil {
+			slog.Default().Warn("write failed", "write", name, "error", err)
+		}
+		return
+	}
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		q.run(context.WithoutCancel(ctx), job{name: name, fn: fn})
+		return
+	}
+	select {
+	case q.jobs <- job{name: name, fn: fn}:
+	default:
+		q.dropped.Add(1)
+		q.logger.Warn("write-behind queue full, dropping write", "write", name, "size", q.cfg.Size)
+	}
+	q.mu.RUnlock()
+}
+
+// Pending is the number of writes waiting for a worker.
+func (q *Queue) Pending() int {
+	if q == nil {
+		return 0
+	}
+	return len(q.jobs)
+}
+
+// Dropped and Failed count writes lost to a full queue or an error.
+func (q *Queue) Dropped() int64 {
+	if q == nil {
+		return 0
+	}
+	return q.dropped.Load()
+}
+
+func (q *Queue) Failed() int64 {
+	if q == nil {
+		return 0
+	}
+	return q.failed.Load()
+}
+
+// Close stops accepting writes and waits for the queued ones until ctx
+// ends; later Do calls run inline.
+func (q *Queue) Close(ctx context.Context) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		q.logger.Warn("write-behind flush incomplete", "pending", len(q.jobs))
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for j := range q.jobs {
+		q.run(context.Background(), j)
+	}
+}
+
+func (q *Queue) run(ctx context.Context, j job) {
+	ctx, cancel := context.WithTimeout(ctx, q.cfg.Timeout)
+	defer cancel()
+	if err := j.fn(ctx); err != nil {
+		q.failed.Add(1)
+		q.logger.Warn("write-behind write failed", "write", j.name, "error", err)
+	}
+}
@@ -0,0 +1,77 @@
+package writebehind
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestQueueFlushesOnClose(t *testing.T) {
+	q := New(Config{Size: 16, Workers: 2}, testLogger())
+	var done atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	for range 10 {
+		q.Do(ctx, "test", func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			done.Add(1)
+			return nil
+		})
+	}
+	// Queued writes outlive the request that queued them.
+	cancel()
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if got := done.Load(); got != 10 {
+		t.Fatalf("flushed %d writes, want 10", got)
+	}
+	ran := false
+	q.Do(context.Background(), "late", func(context.Context) error { ran = true; return nil })
+	if !ran {
+		t.Fatal("a write after Close must run inline")
+	}
+}
+
+func TestQueueDropsWhenFullAndCountsFailures(t *testing.T) {
+	q := New(Config{Size: 1, Workers: 1}, testLogger())
+	release := make(chan struct{})
+	started := make(chan struct{})
+	q.Do(context.Background(), "block", func(context.Context) error {
+		close(started)
+		<-release
+		return errors.New("boom")
+	})
+	<-started
+	q.Do(context.Background(), "queued", func(context.Context) error { return nil })
+	q.Do(context.Background(), "dropped", func(context.Context) error { return nil })
+	if q.Dropped() != 1 || q.Pending() != 1 {
+		t.Fatalf("dropped = %d, pending = %d; want 1 and 1", q.Dropped(), q.Pending())
+	}
+	close(release)
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if q.Failed() != 1 {
+		t.Fatalf("failed = %d, want 1", q.Failed())
+	}
+}
+
+func TestNilQueueRunsInline(t *testing.T) {
+	var q *Queue
+	ran := false
+	q.Do(context.Background(), "inline", func(context.Context) error { ran = true; return nil })
+	if !ran || q.Close(context.Background()) != nil {
+		t.Fatal("a nil queue runs writes inline")
+	}
+}
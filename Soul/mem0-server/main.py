@@ -187,13 +187,16 @@ def update_memory(memory_id: str, updated_memory: Dict[str, Any]):
     
     Args:
         memory_id (str): ID of the memory to update
-        updated_memory (str): New content to update the memory with
+        updated_memory (dict): New content to update the memory with, as {"text": "..."}
         
     Returns:
         dict: Success message indicating the memory was updated
     """
+    text = updated_memory.get("text")
+    if not isinstance(text, str) or not text.strip():
+        raise HTTPException(status_code=400, detail="text is required")
     try:
-        return MEMORY_INSTANCE.update(memory_id=memory_id, data=updated_memory)
+        return MEMORY_INSTANCE.update(memory_id=memory_id, data=text)
     except Exception as e:
         logging.exception("Error in update_memory:")
         raise HTTPException(status_code=500, detail=str(e))
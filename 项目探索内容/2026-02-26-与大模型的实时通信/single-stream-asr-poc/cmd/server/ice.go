@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// iceConfig holds the STUN/TURN servers shared by the Go peer and the
+// browser, so both sides can gather srflx and relay candidates instead of
+// host-only ones that work on localhost alone.
+type iceConfig struct {
+	servers []webrtc.ICEServer
+	policy  webrtc.ICETransportPolicy
+}
+
+// parseICEConfig reads comma-separated stun:/turn:/turns: URLs. username and
+// credential apply to the TURN entries; policy is "all" or "relay".
+func parseICEConfig(urls, username, credential, policy string) (iceConfig, error) {
+	var cfg iceConfig
+	var stunURLs, turnURLs []string
+	for _, raw := range strings.Split(urls, ",") {
+		u := strings.TrimSpace(raw)
+		switch {
+		case u == "":
+		case strings.HasPrefix(u, "stun:") || strings.HasPrefix(u, "stuns:"):
+			stunURLs = append(stunURLs, u)
+		case strings.HasPrefix(u, "turn:") || strings.HasPrefix(u, "turns:"):
+			turnURLs = append(turnURLs, u)
+		default:
+			return cfg, fmt.Errorf("unsupported ICE server URL %q", u)
+		}
+	}
+	if len(stunURLs) > 0 {
+		cfg.servers = append(cfg.servers, webrtc.ICEServer{URLs: stunURLs})
+	}
+	if len(turnURLs) > 0 {
+		if username == "" || credential == "" {
+			return cfg, fmt.Errorf("TURN servers need ICE_USERNAME and ICE_CREDENTIAL")
+		}
+		cfg.servers = append(cfg.servers, webrtc.ICEServer{URLs: turnURLs, Username: username, Credential: credential})
+	}
+
+	switch policy {
+	case "", "all":
+		cfg.policy = webrtc.ICETransportPolicyAll
+	case "relay":
+		if len(turnURLs) == 0 {
+			return cfg, fmt.Errorf("ICE transport policy relay needs a TURN server")
+		}
+		cfg.policy = webrtc.ICETransportPolicyRelay
+	default:
+		return cfg, fmt.Errorf("unsupported ICE transport policy %q", policy)
+	}
+	return cfg, nil
+}
+
+func (c iceConfig) peerConfiguration() webrtc.Configuration {
+	return webrtc.Configuration{ICEServers: c.servers, ICETransportPolicy: c.policy}
+}
+
+// handleICEServers gives the browser the same servers for its RTCPeerConnection.
+func (s *server) handleICEServers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	type browserICEServer struct {
+		URLs       []string `json:"urls"`
+		Username   string   `json:"username,omitempty"`
+		Credential string   `json:"credential,omitempty"`
+	}
+	servers := make([]browserICEServer, 0, len(s.ice.servers))
+	for _, srv := range s.ice.servers {
+		cred, _ := srv.Credential.(string)
+		servers = append(servers, browserICEServer{URLs: srv.URLs, Username: srv.Username, Credential: cred})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"ice_servers":          servers,
+		"ice_transport_policy": s.ice.policy.String(),
+	})
+}
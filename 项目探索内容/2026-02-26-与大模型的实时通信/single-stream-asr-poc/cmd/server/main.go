@@ -15,13 +15,18 @@ import (
 	"time"
 
 	"single-stream-asr-poc/internal/asr"
+	"single-stream-asr-poc/internal/media"
 
+	"github.com/pion/interceptor"
 	"github.com/pion/webrtc/v4"
 )
 
 type offerRequest struct {
 	SDP  string `json:"sdp"`
 	Type string `json:"type"`
+	// SessionID renegotiates an existing session, e.g. an ICE restart after
+	// a network change, instead of starting a new one.
+	SessionID string `json:"session_id,omitempty"`
 }
 
 type offerResponse struct {
@@ -34,33 +39,79 @@ type offerResponse struct {
 type server struct {
 	asrMode     string
 	bridgeURL   string
+	whisper     asr.WhisperHTTPEngine
+	fusion      asr.FusionEngine
 	api         *webrtc.API
 	iceUDPPort  int
 	icePublicIP string
 	iceListener *net.UDPConn
+	ice         iceConfig
+	// restartGrace is how long a disconnected or failed session waits for
+	// the browser to ICE-restart before it is torn down.
+	restartGrace time.Duration
+
+	sessionsMu sync.Mutex
+	sessions   map[string]*session
+}
+
+// session is one browser peer connection and its ASR stream.
+type session struct {
+	id      string
+	pc      *webrtc.PeerConnection
+	cleanup func()
+
+	mu    sync.Mutex
+	grace *time.Timer
 }
 
 func main() {
 	addr := flag.String("addr", ":8088", "HTTP listen address")
 	webDir := flag.String("web", "web", "frontend static directory")
-	asrMode := flag.String("asr", getEnv("ASR_MODE", "auto"), "ASR mode: auto|bridge|mock")
+	asrMode := flag.String("asr", getEnv("ASR_MODE", "auto"), "ASR mode: auto|bridge|fusion|mock")
 	bridgeURL := flag.String("bridge-url", getEnv("ASR_BRIDGE_URL", "ws://127.0.0.1:2700/ws"), "ASR bridge websocket URL")
+	whisperURL := flag.String("whisper-url", getEnv("ASR_WHISPER_URL", "http://127.0.0.1:9000/v1/audio/transcriptions"), "OpenAI-compatible transcription URL used by fusion mode")
+	whisperModel := flag.String("whisper-model", getEnv("ASR_WHISPER_MODEL", "whisper-1"), "model name sent to the whisper endpoint")
+	fusionTimeoutMS := flag.Int("fusion-timeout-ms", getEnvInt("ASR_FUSION_TIMEOUT_MS", 3000), "how long fusion waits for slower engines per utterance")
+	fusionDefaultConf := flag.Float64("fusion-default-confidence", getEnvFloat("ASR_FUSION_DEFAULT_CONFIDENCE", 0.5), "confidence assumed for engines that report none")
 	iceUDPPort := flag.Int("ice-udp-port", getEnvInt("ICE_UDP_PORT", 19000), "UDP port for WebRTC ICE")
 	icePublicIP := flag.String("ice-public-ip", getEnv("ICE_PUBLIC_IP", ""), "IP advertised in ICE host candidates (e.g. 127.0.0.1)")
+	iceServers := flag.String("ice-servers", getEnv("ICE_SERVERS", ""), "comma-separated STUN/TURN URLs, e.g. stun:stun.l.google.com:19302,turn:turn.example.com:3478")
+	iceUsername := flag.String("ice-username", getEnv("ICE_USERNAME", ""), "TURN username")
+	iceCredential := flag.String("ice-credential", getEnv("ICE_CREDENTIAL", ""), "TURN credential")
+	icePolicy := flag.String("ice-transport-policy", getEnv("ICE_TRANSPORT_POLICY", "all"), "ICE transport policy: all|relay")
+	restartGraceS := flag.Int("ice-restart-grace-s", getEnvInt("ICE_RESTART_GRACE_S", 20), "seconds a disconnected session waits for an ICE restart")
 	flag.Parse()
 
+	ice, err := parseICEConfig(*iceServers, *iceUsername, *iceCredential, *icePolicy)
+	if err != nil {
+		log.Fatalf("invalid ICE config: %v", err)
+	}
+
 	api, iceListener, err := newWebRTCAPI(*iceUDPPort, *icePublicIP)
 	if err != nil {
 		log.Fatalf("init webrtc api failed: %v", err)
 	}
 
 	s := &server{
-		asrMode:     *asrMode,
-		bridgeURL:   *bridgeURL,
-		api:         api,
-		iceUDPPort:  *iceUDPPort,
-		icePublicIP: *icePublicIP,
-		iceListener: iceListener,
+		asrMode:   *asrMode,
+		bridgeURL: *bridgeURL,
+		whisper: asr.WhisperHTTPEngine{
+			URL:      *whisperURL,
+			Model:    *whisperModel,
+			APIKey:   os.Getenv("ASR_WHISPER_API_KEY"),
+			Language: os.Getenv("ASR_WHISPER_LANGUAGE"),
+		},
+		fusion: asr.FusionEngine{
+			Timeout:           time.Duration(*fusionTimeoutMS) * time.Millisecond,
+			DefaultConfidence: *fusionDefaultConf,
+		},
+		api:          api,
+		iceUDPPort:   *iceUDPPort,
+		icePublicIP:  *icePublicIP,
+		iceListener:  iceListener,
+		ice:          ice,
+		restartGrace: time.Duration(*restartGraceS) * time.Second,
+		sessions:     make(map[string]*session),
 	}
 	if err := s.assertReady(); err != nil {
 		log.Fatalf("invalid config: %v", err)
@@ -75,6 +126,7 @@ func main() {
 		})
 	})
 	mux.HandleFunc("/offer", s.handleOffer)
+	mux.HandleFunc("/ice-servers", s.handleICEServers)
 
 	absWebDir, err := filepath.Abs(*webDir)
 	if err != nil {
@@ -85,7 +137,11 @@ func main() {
 	log.Printf("server starting on %s", *addr)
 	log.Printf("web dir: %s", absWebDir)
 	log.Printf("asr mode: %s, bridge: %s", s.asrMode, s.bridgeURL)
+	if s.asrMode == "fusion" {
+		log.Printf("fusion whisper: %s model=%s timeout=%s", s.whisper.URL, s.whisper.Model, s.fusion.Timeout)
+	}
 	log.Printf("ice udp port: %d, ice public ip: %s", s.iceUDPPort, s.icePublicIP)
+	log.Printf("ice servers: %d, transport policy: %s, restart grace: %s", len(s.ice.servers), s.ice.policy, s.restartGrace)
 	if err := http.ListenAndServe(*addr, withCORS(mux)); err != nil {
 		log.Fatalf("listen failed: %v", err)
 	}
@@ -106,6 +162,18 @@ func (s *server) handleOffer(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "missing sdp/type", http.StatusBadRequest)
 		return
 	}
+	remoteOffer := webrtc.SessionDescription{
+		Type: webrtc.NewSDPType(req.Type),
+		SDP:  req.SDP,
+	}
+	if remoteOffer.Type != webrtc.SDPTypeOffer {
+		http.Error(w, "invalid sdp type", http.StatusBadRequest)
+		return
+	}
+	if req.SessionID != "" {
+		s.handleRenegotiate(w, req.SessionID, remoteOffer)
+		return
+	}
 
 	sessionID := fmt.Sprintf("s-%d", time.Now().UnixNano())
 	engine, mode, err := s.newEngine()
@@ -128,11 +196,14 @@ func (s *server) handleOffer(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		payload, marshalErr := json.Marshal(map[string]any{
-			"event":    "transcript",
-			"text":     res.Text,
-			"is_final": res.IsFinal,
-			"source":   res.Source,
-			"error":    res.Error,
+			"event":      "transcript",
+			"text":       res.Text,
+			"is_final":   res.IsFinal,
+			"source":     res.Source,
+			"error":      res.Error,
+			"confidence": res.Confidence,
+			"latency_ms": res.LatencyMS,
+			"candidates": res.Candidates,
 		})
 		if marshalErr != nil {
 			return
@@ -146,15 +217,20 @@ func (s *server) handleOffer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pc, err := s.api.NewPeerConnection(webrtc.Configuration{})
+	pc, err := s.api.NewPeerConnection(s.ice.peerConfiguration())
 	if err != nil {
 		_ = stream.Close()
 		http.Error(w, fmt.Sprintf("create peer connection failed: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	cleanup := func() {
+	sess := &session{id: sessionID, pc: pc}
+	sess.cleanup = func() {
 		streamOnce.Do(func() {
+			sess.stopGrace()
+			s.sessionsMu.Lock()
+			delete(s.sessions, sessionID)
+			s.sessionsMu.Unlock()
 			if flushErr := stream.Flush(); flushErr != nil {
 				log.Printf("session=%s flush failed: %v", sessionID, flushErr)
 			}
@@ -168,14 +244,34 @@ func (s *server) handleOffer(w http.ResponseWriter, r *http.Request) {
 	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
 		log.Printf("session=%s peer state=%s", sessionID, state.String())
 		switch state {
-		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateClosed, webrtc.PeerConnectionStateDisconnected:
-			cleanup()
+		case webrtc.PeerConnectionStateConnected:
+			sess.stopGrace()
+		case webrtc.PeerConnectionStateFailed, webrtc.PeerConnectionStateDisconnected:
+			// The browser may be switching networks; keep the ASR stream
+			// alive for an ICE restart before giving up.
+			sess.startGrace(s.restartGrace)
+		case webrtc.PeerConnectionStateClosed:
+			sess.cleanup()
 		}
 	})
 	pc.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
 		log.Printf("session=%s ice state=%s", sessionID, state.String())
 	})
 
+	// Browsers sending a getUserMedia track instead of DataChannel PCM: decode
+	// Opus here and feed the same ASR stream.
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		if track.Kind() != webrtc.RTPCodecTypeAudio {
+			return
+		}
+		log.Printf("session=%s audio track codec=%s ssrc=%d", sessionID, track.Codec().MimeType, track.SSRC())
+		stats, err := media.PumpOpusTrack(track, stream.PushAudio)
+		if err != nil {
+			log.Printf("session=%s audio track stopped: %v", sessionID, err)
+		}
+		log.Printf("session=%s audio track ended packets=%d lost=%d decode_fails=%d", sessionID, stats.Packets, stats.Lost, stats.DecodeFails)
+	})
+
 	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
 		log.Printf("session=%s data channel open label=%s", sessionID, dc.Label())
 		if dc.Label() != "audio" {
@@ -206,56 +302,105 @@ func (s *server) handleOffer(w http.ResponseWriter, r *http.Request) {
 
 		dc.OnClose(func() {
 			log.Printf("session=%s data channel closed", sessionID)
-			cleanup()
+			sess.cleanup()
 		})
 	})
 
-	remoteOffer := webrtc.SessionDescription{
-		Type: webrtc.NewSDPType(req.Type),
-		SDP:  req.SDP,
-	}
-	if remoteOffer.Type == webrtc.SDPTypeUnknown {
-		cleanup()
-		http.Error(w, "invalid sdp type", http.StatusBadRequest)
+	local, status, err := answerOffer(pc, remoteOffer)
+	if err != nil {
+		sess.cleanup()
+		http.Error(w, err.Error(), status)
 		return
 	}
+	s.sessionsMu.Lock()
+	s.sessions[sessionID] = sess
+	s.sessionsMu.Unlock()
+
+	resp := offerResponse{
+		SDP:       local.SDP,
+		Type:      local.Type.String(),
+		SessionID: sessionID,
+		ASRMode:   mode,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
 
-	if err := pc.SetRemoteDescription(remoteOffer); err != nil {
-		cleanup()
-		http.Error(w, fmt.Sprintf("set remote description failed: %v", err), http.StatusBadRequest)
+// handleRenegotiate answers a new offer on an existing session. An offer with
+// fresh ICE credentials restarts ICE and gathers new candidates while the ASR
+// stream and data channel carry on.
+func (s *server) handleRenegotiate(w http.ResponseWriter, sessionID string, offer webrtc.SessionDescription) {
+	s.sessionsMu.Lock()
+	sess := s.sessions[sessionID]
+	s.sessionsMu.Unlock()
+	if sess == nil {
+		http.Error(w, "unknown or expired session", http.StatusNotFound)
 		return
 	}
 
-	answer, err := pc.CreateAnswer(nil)
+	log.Printf("session=%s renegotiating", sessionID)
+	local, status, err := answerOffer(sess.pc, offer)
 	if err != nil {
-		cleanup()
-		http.Error(w, fmt.Sprintf("create answer failed: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), status)
 		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(offerResponse{
+		SDP:       local.SDP,
+		Type:      local.Type.String(),
+		SessionID: sessionID,
+		ASRMode:   s.asrMode,
+	})
+}
 
+// answerOffer applies offer and returns the answer with all candidates
+// gathered, along with the HTTP status to report on failure.
+func answerOffer(pc *webrtc.PeerConnection, offer webrtc.SessionDescription) (*webrtc.SessionDescription, int, error) {
+	if err := pc.SetRemoteDescription(offer); err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("set remote description failed: %w", err)
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("create answer failed: %w", err)
+	}
 	gatherDone := webrtc.GatheringCompletePromise(pc)
 	if err := pc.SetLocalDescription(answer); err != nil {
-		cleanup()
-		http.Error(w, fmt.Sprintf("set local description failed: %v", err), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, fmt.Errorf("set local description failed: %w", err)
 	}
 	<-gatherDone
 
 	local := pc.LocalDescription()
 	if local == nil {
-		cleanup()
-		http.Error(w, "local description is empty", http.StatusInternalServerError)
+		return nil, http.StatusInternalServerError, errors.New("local description is empty")
+	}
+	return local, http.StatusOK, nil
+}
+
+// startGrace tears the session down after d unless it reconnects first.
+func (sess *session) startGrace(d time.Duration) {
+	if d <= 0 {
+		sess.cleanup()
+		return
+	}
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.grace != nil {
 		return
 	}
+	log.Printf("session=%s waiting %s for ice restart", sess.id, d)
+	sess.grace = time.AfterFunc(d, func() {
+		log.Printf("session=%s no ice restart within %s, closing", sess.id, d)
+		sess.cleanup()
+	})
+}
 
-	resp := offerResponse{
-		SDP:       local.SDP,
-		Type:      local.Type.String(),
-		SessionID: sessionID,
-		ASRMode:   mode,
+func (sess *session) stopGrace() {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.grace != nil {
+		sess.grace.Stop()
+		sess.grace = nil
 	}
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(resp)
 }
 
 func (s *server) newEngine() (asr.Engine, string, error) {
@@ -264,6 +409,11 @@ func (s *server) newEngine() (asr.Engine, string, error) {
 		return &asr.MockEngine{}, "mock", nil
 	case "bridge":
 		return &asr.WSBridgeEngine{BaseURL: s.bridgeURL}, "bridge", nil
+	case "fusion":
+		whisper := s.whisper
+		fusion := s.fusion
+		fusion.Engines = []asr.Engine{&asr.WSBridgeEngine{BaseURL: s.bridgeURL}, &whisper}
+		return &fusion, "fusion", nil
 	case "auto":
 		return &autoEngine{
 			primary:   &asr.WSBridgeEngine{BaseURL: s.bridgeURL},
@@ -335,6 +485,18 @@ func getEnvInt(key string, fallback int) int {
 	return v
 }
 
+func getEnvFloat(key string, fallback float64) float64 {
+	raw, ok := os.LookupEnv(key)
+	if !ok || raw == "" {
+		return fallback
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
 func newWebRTCAPI(iceUDPPort int, icePublicIP string) (*webrtc.API, *net.UDPConn, error) {
 	var se webrtc.SettingEngine
 	var listener *net.UDPConn
@@ -356,13 +518,25 @@ func newWebRTCAPI(iceUDPPort int, icePublicIP string) (*webrtc.API, *net.UDPConn
 		se.SetNAT1To1IPs([]string{icePublicIP}, webrtc.ICECandidateTypeHost)
 	}
 
-	api := webrtc.NewAPI(webrtc.WithSettingEngine(se))
+	var m webrtc.MediaEngine
+	if err := media.RegisterOpus(&m); err != nil {
+		return nil, nil, fmt.Errorf("register opus codec failed: %w", err)
+	}
+	var registry interceptor.Registry
+	if err := webrtc.RegisterDefaultInterceptors(&m, &registry); err != nil {
+		return nil, nil, fmt.Errorf("register interceptors failed: %w", err)
+	}
+
+	api := webrtc.NewAPI(webrtc.WithSettingEngine(se), webrtc.WithMediaEngine(&m), webrtc.WithInterceptorRegistry(&registry))
 	return api, listener, nil
 }
 
 func (s *server) assertReady() error {
-	if s.asrMode == "bridge" && s.bridgeURL == "" {
-		return errors.New("bridge URL is required in bridge mode")
+	if (s.asrMode == "bridge" || s.asrMode == "fusion") && s.bridgeURL == "" {
+		return errors.New("bridge URL is required in bridge/fusion mode")
+	}
+	if s.asrMode == "fusion" && s.whisper.URL == "" {
+		return errors.New("whisper URL is required in fusion mode")
 	}
 	if s.api == nil {
 		return errors.New("webrtc api is not initialized")
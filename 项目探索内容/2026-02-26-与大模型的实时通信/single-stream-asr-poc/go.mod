@@ -1,9 +1,12 @@
 module single-stream-asr-poc
 
-go 1.22
+go 1.24.0
 
 require (
 	github.com/gorilla/websocket v1.5.3
+	github.com/pion/interceptor v0.1.41
+	github.com/pion/opus v0.1.0
+	github.com/pion/rtp v1.8.22
 	github.com/pion/webrtc/v4 v4.1.5
 )
 
@@ -12,12 +15,10 @@ require (
 	github.com/pion/datachannel v1.5.10 // indirect
 	github.com/pion/dtls/v3 v3.0.7 // indirect
 	github.com/pion/ice/v4 v4.0.10 // indirect
-	github.com/pion/interceptor v0.1.41 // indirect
 	github.com/pion/logging v0.2.4 // indirect
 	github.com/pion/mdns/v2 v2.0.7 // indirect
 	github.com/pion/randutil v0.1.0 // indirect
 	github.com/pion/rtcp v1.2.15 // indirect
-	github.com/pion/rtp v1.8.22 // indirect
 	github.com/pion/sctp v1.8.39 // indirect
 	github.com/pion/sdp/v3 v3.0.16 // indirect
 	github.com/pion/srtp/v3 v3.0.8 // indirect
@@ -5,6 +5,20 @@ type Result struct {
 	IsFinal bool   `json:"is_final"`
 	Source  string `json:"source,omitempty"`
 	Error   string `json:"error,omitempty"`
+	// Confidence is in [0,1]; 0 means the engine did not report one.
+	Confidence float64 `json:"confidence,omitempty"`
+	// LatencyMS is measured from the start of the utterance's audio.
+	LatencyMS  int64       `json:"latency_ms,omitempty"`
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// Candidate is one engine's final for an utterance, as seen by FusionEngine.
+type Candidate struct {
+	Source     string  `json:"source"`
+	Text       string  `json:"text"`
+	Confidence float64 `json:"confidence,omitempty"`
+	LatencyMS  int64   `json:"latency_ms"`
+	Error      string  `json:"error,omitempty"`
 }
 
 type Stream interface {
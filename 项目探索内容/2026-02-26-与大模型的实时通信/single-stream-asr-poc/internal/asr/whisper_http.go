@@ -0,0 +1,218 @@
+package asr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// whisperMaxBufferBytes keeps one request within Whisper's 30s window.
+const whisperMaxBufferBytes = 30 * sampleRate * 2
+
+// WhisperHTTPEngine transcribes through an OpenAI-compatible
+// /v1/audio/transcriptions endpoint (OpenAI, faster-whisper-server,
+// whisper.cpp server). It does not stream: audio is buffered and posted as
+// one WAV on each Flush, so it only emits finals.
+type WhisperHTTPEngine struct {
+	URL      string
+	Model    string
+	APIKey   string
+	Language string
+	Client   *http.Client
+}
+
+func (e *WhisperHTTPEngine) Name() string {
+	return "whisper"
+}
+
+func (e *WhisperHTTPEngine) NewStream(_ string, onResult func(Result)) (Stream, error) {
+	if e.URL == "" {
+		return nil, fmt.Errorf("whisper URL is empty")
+	}
+	client := e.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &whisperStream{engine: e, client: client, onResult: onResult}, nil
+}
+
+type whisperStream struct {
+	engine   *WhisperHTTPEngine
+	client   *http.Client
+	onResult func(Result)
+
+	mu     sync.Mutex
+	buf    []byte
+	closed bool
+}
+
+func (s *whisperStream) PushAudio(pcm16le []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.buf = append(s.buf, pcm16le...)
+	if over := len(s.buf) - whisperMaxBufferBytes; over > 0 {
+		over += over % 2
+		s.buf = append(s.buf[:0], s.buf[over:]...)
+	}
+	return nil
+}
+
+// Flush transcribes the buffered audio and emits one final. The request runs
+// without holding the lock so audio for the next utterance keeps buffering.
+func (s *whisperStream) Flush() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	pcm := s.buf
+	s.buf = nil
+	s.mu.Unlock()
+
+	res := Result{IsFinal: true, Source: "whisper"}
+	if len(pcm) > 0 {
+		text, confidence, err := s.transcribe(pcm)
+		if err != nil {
+			return err
+		}
+		res.Text, res.Confidence = text, confidence
+	}
+
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if !closed && s.onResult != nil {
+		s.onResult(res)
+	}
+	return nil
+}
+
+func (s *whisperStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.buf = nil
+	return nil
+}
+
+type whisperResponse struct {
+	Text     string `json:"text"`
+	Segments []struct {
+		Text         string  `json:"text"`
+		AvgLogprob   float64 `json:"avg_logprob"`
+		NoSpeechProb float64 `json:"no_speech_prob"`
+	} `json:"segments"`
+}
+
+func (s *whisperStream) transcribe(pcm []byte) (string, float64, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, err := form.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return "", 0, err
+	}
+	if err := writeWAV(file, pcm); err != nil {
+		return "", 0, err
+	}
+	fields := map[string]string{
+		"model":           s.engine.Model,
+		"response_format": "verbose_json",
+		"language":        s.engine.Language,
+	}
+	for k, v := range fields {
+		if v == "" {
+			continue
+		}
+		if err := form.WriteField(k, v); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return "", 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.engine.URL, &body)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if s.engine.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.engine.APIKey)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("whisper request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, err
+	}
+	if resp.StatusCode >= 300 {
+		return "", 0, fmt.Errorf("whisper status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	var parsed whisperResponse
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return "", 0, fmt.Errorf("invalid whisper response: %w", err)
+	}
+	return strings.TrimSpace(parsed.Text), whisperConfidence(parsed), nil
+}
+
+// whisperConfidence is the text-weighted mean of exp(avg_logprob), discounted
+// by each segment's no-speech probability. Servers without segments report 0.
+func whisperConfidence(r whisperResponse) float64 {
+	var sum, weight float64
+	for _, seg := range r.Segments {
+		w := float64(len([]rune(strings.TrimSpace(seg.Text))))
+		if w == 0 {
+			continue
+		}
+		sum += w * math.Exp(seg.AvgLogprob) * (1 - seg.NoSpeechProb)
+		weight += w
+	}
+	if weight == 0 {
+		return 0
+	}
+	return math.Min(1, math.Max(0, sum/weight))
+}
+
+// writeWAV wraps mono 16kHz PCM16LE in a RIFF header.
+func writeWAV(w io.Writer, pcm []byte) error {
+	header := struct {
+		RIFF          [4]byte
+		ChunkSize     uint32
+		WAVE          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		RIFF: [4]byte{'R', 'I', 'F', 'F'}, ChunkSize: uint32(36 + len(pcm)),
+		WAVE: [4]byte{'W', 'A', 'V', 'E'}, Fmt: [4]byte{'f', 'm', 't', ' '}, FmtSize: 16,
+		AudioFormat: 1, Channels: 1, SampleRate: sampleRate, ByteRate: sampleRate * 2,
+		BlockAlign: 2, BitsPerSample: 16,
+		Data: [4]byte{'d', 'a', 't', 'a'}, DataSize: uint32(len(pcm)),
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	_, err := w.Write(pcm)
+	return err
+}
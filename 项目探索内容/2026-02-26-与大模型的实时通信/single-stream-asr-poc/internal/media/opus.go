@@ -0,0 +1,120 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pion/opus"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v4"
+)
+
+// ASRSampleRate is the PCM rate asr.Stream consumes.
+const ASRSampleRate = 16000
+
+// maxOpusFrameMS is the longest Opus packet duration (RFC 6716 §3.2.5).
+const maxOpusFrameMS = 120
+
+// OpusDecoder turns Opus packets into mono PCM16LE at a fixed rate, so a
+// browser audio track can feed the same asr.Stream as DataChannel PCM.
+type OpusDecoder struct {
+	dec opus.Decoder
+	pcm []int16
+}
+
+func NewOpusDecoder(sampleRate int) (*OpusDecoder, error) {
+	dec, err := opus.NewDecoderWithOutput(sampleRate, 1)
+	if err != nil {
+		return nil, fmt.Errorf("init opus decoder: %w", err)
+	}
+	return &OpusDecoder{dec: dec, pcm: make([]int16, sampleRate*maxOpusFrameMS/1000)}, nil
+}
+
+// Decode returns the PCM16LE samples of one Opus packet.
+func (d *OpusDecoder) Decode(packet []byte) ([]byte, error) {
+	n, err := d.dec.DecodeToInt16(packet, d.pcm)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, n*2)
+	for i, s := range d.pcm[:n] {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(s))
+	}
+	return out, nil
+}
+
+// TrackStats summarizes a finished track for logging.
+type TrackStats struct {
+	Packets     int
+	Lost        int
+	DecodeFails int
+}
+
+// PumpOpusTrack reads RTP from track until it ends, depacketizes and decodes
+// each Opus payload and hands the PCM to push. Sequence gaps are counted as
+// loss; no concealment is attempted since ASR tolerates short dropouts.
+func PumpOpusTrack(track *webrtc.TrackRemote, push func(pcm16le []byte) error) (TrackStats, error) {
+	var stats TrackStats
+	if !strings.EqualFold(track.Codec().MimeType, webrtc.MimeTypeOpus) {
+		return stats, fmt.Errorf("unsupported audio codec %s", track.Codec().MimeType)
+	}
+	dec, err := NewOpusDecoder(ASRSampleRate)
+	if err != nil {
+		return stats, err
+	}
+
+	var (
+		depacketizer codecs.OpusPacket
+		lastSeq      uint16
+		haveSeq      bool
+	)
+	for {
+		pkt, _, err := track.ReadRTP()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return stats, nil
+			}
+			return stats, err
+		}
+		stats.Packets++
+		if haveSeq {
+			if gap := pkt.SequenceNumber - lastSeq; gap > 1 && gap < 1<<15 {
+				stats.Lost += int(gap - 1)
+			} else if gap == 0 || gap >= 1<<15 {
+				// duplicate or late packet: already past it
+				continue
+			}
+		}
+		lastSeq, haveSeq = pkt.SequenceNumber, true
+
+		payload, err := depacketizer.Unmarshal(pkt.Payload)
+		if err != nil || len(payload) == 0 {
+			continue
+		}
+		pcm, err := dec.Decode(payload)
+		if err != nil {
+			stats.DecodeFails++
+			continue
+		}
+		if err := push(pcm); err != nil {
+			return stats, err
+		}
+	}
+}
+
+// RegisterOpus adds the Opus codec to m so browsers can negotiate an audio
+// track. useinbandfec lets the sender protect against single packet loss.
+func RegisterOpus(m *webrtc.MediaEngine) error {
+	return m.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeOpus,
+			ClockRate:   48000,
+			Channels:    2,
+			SDPFmtpLine: "minptime=10;useinbandfec=1",
+		},
+		PayloadType: 111,
+	}, webrtc.RTPCodecTypeAudio)
+}
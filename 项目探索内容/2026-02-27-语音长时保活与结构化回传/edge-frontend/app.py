@@ -309,12 +309,15 @@ class BackendBridge:
         payload["request_id"] = request_id
         queue: asyncio.Queue = asyncio.Queue()
         self._pending_streams[request_id] = queue
+        sent = False
+        finished = False
         try:
             await asyncio.wait_for(self._connected.wait(), timeout=BACKEND_CONN_TIMEOUT_S)
             if self._ws is None:
                 raise RuntimeError("backend websocket not ready")
             async with self._send_lock:
                 await self._ws.send(json.dumps(payload, ensure_ascii=False))
+            sent = True
 
             while True:
                 msg = await asyncio.wait_for(queue.get(), timeout=timeout_s)
@@ -322,9 +325,23 @@ class BackendBridge:
                     raise RuntimeError("invalid backend response")
                 yield msg
                 if msg.get("final", False):
+                    finished = True
                     break
         finally:
             self._pending_streams.pop(request_id, None)
+            if sent and not finished:
+                # interrupted or timed out: revoke it so the backend stops working on it
+                asyncio.create_task(self.cancel(request_id, str(payload.get("session_id", "") or "")))
+
+    async def cancel(self, request_id: str, session_id: str) -> None:
+        ws = self._ws
+        if ws is None:
+            return
+        try:
+            async with self._send_lock:
+                await ws.send(json.dumps({"type": "cancel", "request_id": request_id, "session_id": session_id}))
+        except Exception:
+            pass
 
     async def _run(self) -> None:
         while not self._stop:
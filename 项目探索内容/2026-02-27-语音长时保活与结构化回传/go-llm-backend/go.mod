@@ -2,4 +2,9 @@ module longrun-llm-backend
 
 go 1.24
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/gorilla/websocket v1.5.3
+	go.etcd.io/bbolt v1.4.3
+)
+
+require golang.org/x/sys v0.29.0 // indirect
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -27,6 +28,16 @@ type llmRequest struct {
 	Event     string `json:"event"`
 	Final     bool   `json:"final"`
 	TsMS      int64  `json:"ts_ms"`
+	// Tools lists local actions the edge can run for this request.
+	Tools []edgeTool `json:"tools,omitempty"`
+	// ToolCallID, Result and Error carry a tool_result message.
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	// LastN limits the turns replayed by a resume message.
+	LastN int `json:"last_n,omitempty"`
+	// TerminalID overrides SOUL_TERMINAL_ID in soul mode.
+	TerminalID string `json:"terminal_id,omitempty"`
 }
 
 type llmResponse struct {
@@ -41,24 +52,36 @@ type llmResponse struct {
 	Delta     string `json:"delta,omitempty"`
 	Error     string `json:"error,omitempty"`
 	TsMS      int64  `json:"ts_ms"`
+	// ToolCallID, Name and Arguments describe an llm_tool_call frame.
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	// Turns and Pending answer a resume message.
+	Turns   []resumeTurn `json:"turns,omitempty"`
+	Pending int          `json:"pending,omitempty"`
+	// ExecutedSkills lists the Soul skills run for this reply.
+	ExecutedSkills []string `json:"executed_skills,omitempty"`
 }
 
 type openAIRequest struct {
 	Model    string          `json:"model"`
 	Messages []openAIMessage `json:"messages"`
 	Stream   bool            `json:"stream"`
+	Tools    []openAITool    `json:"tools,omitempty"`
 }
 
 type openAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content,omitempty"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
 }
 
 type openAIStreamChunk struct {
 	Choices []struct {
 		Delta        openAITextCarrier `json:"delta"`
 		Message      openAITextCarrier `json:"message"`
-		FinishReason string `json:"finish_reason"`
+		FinishReason string            `json:"finish_reason"`
 	} `json:"choices"`
 	Error *struct {
 		Message string `json:"message"`
@@ -66,9 +89,10 @@ type openAIStreamChunk struct {
 }
 
 type openAITextCarrier struct {
-	Content    json.RawMessage `json:"content"`
-	Text       json.RawMessage `json:"text"`
-	OutputText json.RawMessage `json:"output_text"`
+	Content    json.RawMessage       `json:"content"`
+	Text       json.RawMessage       `json:"text"`
+	OutputText json.RawMessage       `json:"output_text"`
+	ToolCalls  []openAIToolCallDelta `json:"tool_calls"`
 }
 
 type openAINonStreamResponse struct {
@@ -85,48 +109,90 @@ type sessionMemory struct {
 	mu          sync.Mutex
 	maxMessages int
 	history     map[string][]openAIMessage
+	updated     map[string]time.Time
+	pending     map[string][]llmRequest
+	// store is optional; without it sessions live only in this process.
+	store   *sessionStore
+	idleTTL time.Duration
 }
 
-func newSessionMemory(maxMessages int) *sessionMemory {
+func newSessionMemory(maxMessages int, store *sessionStore, idleTTL time.Duration) *sessionMemory {
 	if maxMessages < 2 {
 		maxMessages = 2
 	}
 	return &sessionMemory{
 		maxMessages: maxMessages,
 		history:     make(map[string][]openAIMessage),
+		updated:     make(map[string]time.Time),
+		pending:     make(map[string][]llmRequest),
+		store:       store,
+		idleTTL:     idleTTL,
 	}
 }
 
+// load pulls a session from the store on first use. Callers hold m.mu.
+func (m *sessionMemory) load(sessionID string) []openAIMessage {
+	if h, ok := m.history[sessionID]; ok || m.store == nil || sessionID == "" {
+		return h
+	}
+	rec, found, err := m.store.loadSession(sessionID)
+	if err != nil {
+		log.Printf("load session failed: session_id=%s err=%v", sessionID, err)
+		return nil
+	}
+	if found {
+		m.history[sessionID] = rec.Messages
+		m.updated[sessionID] = time.UnixMilli(rec.UpdatedMS)
+	}
+	return rec.Messages
+}
+
 func (m *sessionMemory) snapshotWithUser(sessionID, userContent string) []openAIMessage {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	base := append([]openAIMessage(nil), m.history[sessionID]...)
+	base := append([]openAIMessage(nil), m.load(sessionID)...)
 	base = append(base, openAIMessage{Role: "user", Content: userContent})
-	if len(base) > m.maxMessages {
-		base = base[len(base)-m.maxMessages:]
-	}
-	return base
+	return trimHistory(base, m.maxMessages)
 }
 
-func (m *sessionMemory) appendTurn(sessionID, userContent, assistantContent string) {
+// appendTurn stores a finished turn: the user message, any tool call rounds
+// and the final assistant reply.
+func (m *sessionMemory) appendTurn(sessionID string, turn []openAIMessage) {
 	if strings.TrimSpace(sessionID) == "" {
 		return
 	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	h := append([]openAIMessage(nil), m.history[sessionID]...)
-	if strings.TrimSpace(userContent) != "" {
-		h = append(h, openAIMessage{Role: "user", Content: userContent})
+	h := append([]openAIMessage(nil), m.load(sessionID)...)
+	for _, msg := range turn {
+		if strings.TrimSpace(msg.Content) == "" && len(msg.ToolCalls) == 0 {
+			continue
+		}
+		h = append(h, msg)
+	}
+	now := time.Now()
+	m.history[sessionID] = trimHistory(h, m.maxMessages)
+	m.updated[sessionID] = now
+	if m.store != nil {
+		if err := m.store.saveSession(sessionID, sessionRecord{Messages: m.history[sessionID], UpdatedMS: now.UnixMilli()}); err != nil {
+			log.Printf("save session failed: session_id=%s err=%v", sessionID, err)
+		}
 	}
-	if strings.TrimSpace(assistantContent) != "" {
-		h = append(h, openAIMessage{Role: "assistant", Content: assistantContent})
+}
+
+// trimHistory keeps the last limit messages, then drops leading tool rounds so
+// the window never starts with a tool result missing its call.
+func trimHistory(h []openAIMessage, limit int) []openAIMessage {
+	if len(h) <= limit {
+		return h
 	}
-	if len(h) > m.maxMessages {
-		h = h[len(h)-m.maxMessages:]
+	h = h[len(h)-limit:]
+	for len(h) > 1 && (h[0].Role == "tool" || len(h[0].ToolCalls) > 0) {
+		h = h[1:]
 	}
-	m.history[sessionID] = h
+	return h
 }
 
 type llmBackend struct {
@@ -137,15 +203,44 @@ type llmBackend struct {
 	systemPrompt string
 	timeout      time.Duration
 	memory       *sessionMemory
+	toolTimeout  time.Duration
+	toolRounds   int
+	resumeTurns  int
+	queueAging   time.Duration
+	// soul is set in BACKEND_MODE=soul; soulFallback retries failed Soul
+	// calls on the direct LLM path.
+	soul         *soulClient
+	soulFallback bool
 }
 
-func newLLMBackendFromEnv() *llmBackend {
+func newLLMBackendFromEnv() (*llmBackend, error) {
 	baseURL := strings.TrimRight(getEnvString("OPENAI_BASE_URL", "https://api.openai.com/v1"), "/")
 	model := getEnvString("LLM_MODEL", "gpt-4o-mini")
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	timeout := time.Duration(getEnvInt("LLM_TIMEOUT_S", 90)) * time.Second
 	historyLimit := getEnvInt("CHAT_HISTORY_LIMIT", 20)
 	systemPrompt := getEnvString("LLM_SYSTEM_PROMPT", "你是语音助手，请基于用户输入直接给出简洁有帮助的中文回答。")
+	idleTTL := time.Duration(getEnvInt("SESSION_IDLE_TTL_S", 1800)) * time.Second
+
+	var soul *soulClient
+	switch mode := getEnvString("BACKEND_MODE", "direct"); mode {
+	case "direct":
+	case "soul":
+		soul = newSoulClientFromEnv(timeout)
+	default:
+		return nil, fmt.Errorf("unsupported BACKEND_MODE: %s", mode)
+	}
+
+	var store *sessionStore
+	if path := strings.TrimSpace(os.Getenv("SESSION_STORE_PATH")); path != "-" {
+		if path == "" {
+			path = "data/sessions.db"
+		}
+		var err error
+		if store, err = openSessionStore(path); err != nil {
+			return nil, fmt.Errorf("open session store %s: %w", path, err)
+		}
+	}
 
 	return &llmBackend{
 		client: &http.Client{
@@ -156,8 +251,14 @@ func newLLMBackendFromEnv() *llmBackend {
 		model:        model,
 		systemPrompt: systemPrompt,
 		timeout:      timeout,
-		memory:       newSessionMemory(historyLimit),
-	}
+		memory:       newSessionMemory(historyLimit, store, idleTTL),
+		toolTimeout:  time.Duration(getEnvInt("TOOL_RESULT_TIMEOUT_S", 15)) * time.Second,
+		toolRounds:   getEnvInt("TOOL_MAX_ROUNDS", 4),
+		resumeTurns:  getEnvInt("RESUME_MAX_TURNS", 10),
+		queueAging:   time.Duration(getEnvInt("QUEUE_AGING_MS", 2000)) * time.Millisecond,
+		soul:         soul,
+		soulFallback: getEnvString("SOUL_FALLBACK_DIRECT", "1") != "0",
+	}, nil
 }
 
 func formatUserInput(req llmRequest) string {
@@ -171,7 +272,10 @@ func formatUserInput(req llmRequest) string {
 	return fmt.Sprintf("%s\n\n[voice_meta] emotion=%s event=%s final=%t", text, req.Emotion, req.Event, req.Final)
 }
 
-func (b *llmBackend) streamReply(ctx context.Context, req llmRequest, onDelta func(string) error) (string, error) {
+// streamReply runs the completion loop for one request. When the model calls
+// edge tools, callTools forwards them and the loop continues with the results
+// until the model answers in text or toolRounds is exhausted.
+func (b *llmBackend) streamReply(ctx context.Context, req llmRequest, onDelta func(string) error, callTools toolCaller) (string, error) {
 	if strings.TrimSpace(req.Text) == "" {
 		return "", fmt.Errorf("empty text")
 	}
@@ -184,24 +288,75 @@ func (b *llmBackend) streamReply(ctx context.Context, req llmRequest, onDelta fu
 		{Role: "system", Content: b.systemPrompt},
 	}
 	messages = append(messages, b.memory.snapshotWithUser(req.SessionID, userContent)...)
+	turn := []openAIMessage{{Role: "user", Content: userContent}}
+	tools := buildOpenAITools(req.Tools)
+
+	var reply string
+	for round := 0; ; round++ {
+		payload := openAIRequest{
+			Model:    b.model,
+			Messages: messages,
+			Stream:   true,
+		}
+		// The last round goes out without tools so the model has to answer.
+		if callTools != nil && round < b.toolRounds {
+			payload.Tools = tools
+		}
+		content, calls, err := b.streamCompletion(ctx, payload, onDelta)
+		if err != nil {
+			return "", err
+		}
+		if len(calls) == 0 {
+			reply = content
+			if strings.TrimSpace(reply) == "" {
+				log.Printf("stream produced empty content, fallback to non-stream: session_id=%s request_id=%s", req.SessionID, req.RequestID)
+				payload.Tools = nil
+				fallbackReply, err := b.nonStreamReply(ctx, payload)
+				if err != nil {
+					return "", fmt.Errorf("empty llm response (stream) and fallback failed: %w", err)
+				}
+				reply = fallbackReply
+				if onDelta != nil {
+					if err := onDelta(reply); err != nil {
+						return "", err
+					}
+				}
+			}
+			break
+		}
 
-	payload := openAIRequest{
-		Model:    b.model,
-		Messages: messages,
-		Stream:   true,
+		results, err := callTools(ctx, calls)
+		if err != nil {
+			return "", err
+		}
+		assistant := openAIMessage{Role: "assistant", Content: content, ToolCalls: calls}
+		messages = append(messages, assistant)
+		messages = append(messages, results...)
+		turn = append(turn, assistant)
+		turn = append(turn, results...)
 	}
+
+	turn = append(turn, openAIMessage{Role: "assistant", Content: reply})
+	b.memory.appendTurn(req.SessionID, turn)
+	return reply, nil
+}
+
+// streamCompletion performs one streamed chat completion and returns the text
+// and any tool calls the model made.
+func (b *llmBackend) streamCompletion(ctx context.Context, payload openAIRequest, onDelta func(string) error) (string, []openAIToolCall, error) {
 	resp, err := b.doChatCompletion(ctx, payload)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
 		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return "", fmt.Errorf("openai status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+		return "", nil, fmt.Errorf("openai status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
 	}
 
 	var sb strings.Builder
+	var toolCalls toolCallAccumulator
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Buffer(make([]byte, 0, 64*1024), 2*1024*1024)
 
@@ -220,11 +375,13 @@ func (b *llmBackend) streamReply(ctx context.Context, req llmRequest, onDelta fu
 			continue
 		}
 		if chunk.Error != nil {
-			return "", fmt.Errorf("openai error: %s", chunk.Error.Message)
+			return "", nil, fmt.Errorf("openai error: %s", chunk.Error.Message)
 		}
 		if len(chunk.Choices) == 0 {
 			continue
 		}
+		toolCalls.add(chunk.Choices[0].Delta.ToolCalls)
+		toolCalls.add(chunk.Choices[0].Message.ToolCalls)
 		piece := extractTextFromCarrier(chunk.Choices[0].Delta)
 		if piece == "" {
 			piece = extractTextFromCarrier(chunk.Choices[0].Message)
@@ -235,30 +392,14 @@ func (b *llmBackend) streamReply(ctx context.Context, req llmRequest, onDelta fu
 		sb.WriteString(piece)
 		if onDelta != nil {
 			if err := onDelta(piece); err != nil {
-				return "", err
+				return "", nil, err
 			}
 		}
 	}
 	if err := scanner.Err(); err != nil {
-		return "", err
+		return "", nil, err
 	}
-
-	reply := sb.String()
-	if strings.TrimSpace(reply) == "" {
-		log.Printf("stream produced empty content, fallback to non-stream: session_id=%s request_id=%s", req.SessionID, req.RequestID)
-		fallbackReply, err := b.nonStreamReply(ctx, payload.Model, payload.Messages)
-		if err != nil {
-			return "", fmt.Errorf("empty llm response (stream) and fallback failed: %w", err)
-		}
-		reply = fallbackReply
-		if onDelta != nil {
-			if err := onDelta(reply); err != nil {
-				return "", err
-			}
-		}
-	}
-	b.memory.appendTurn(req.SessionID, userContent, reply)
-	return reply, nil
+	return sb.String(), toolCalls.result(), nil
 }
 
 func (b *llmBackend) doChatCompletion(ctx context.Context, payload openAIRequest) (*http.Response, error) {
@@ -276,12 +417,9 @@ func (b *llmBackend) doChatCompletion(ctx context.Context, payload openAIRequest
 	return b.client.Do(httpReq)
 }
 
-func (b *llmBackend) nonStreamReply(ctx context.Context, model string, messages []openAIMessage) (string, error) {
-	resp, err := b.doChatCompletion(ctx, openAIRequest{
-		Model:    model,
-		Messages: messages,
-		Stream:   false,
-	})
+func (b *llmBackend) nonStreamReply(ctx context.Context, payload openAIRequest) (string, error) {
+	payload.Stream = false
+	resp, err := b.doChatCompletion(ctx, payload)
 	if err != nil {
 		return "", err
 	}
@@ -376,18 +514,27 @@ var upgrader = websocket.Upgrader{
 
 func main() {
 	port := getEnvInt("PORT", 8090)
-	backend := newLLMBackendFromEnv()
+	backend, err := newLLMBackendFromEnv()
+	if err != nil {
+		log.Fatalf("init backend failed: %v", err)
+	}
+	go backend.memory.runExpiry(nil)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		_ = json.NewEncoder(w).Encode(map[string]any{
-			"status":              "ok",
-			"ts_ms":               time.Now().UnixMilli(),
-			"llm_model":           backend.model,
-			"openai_base_url":     backend.baseURL,
-			"has_openai_api_key":  strings.TrimSpace(backend.apiKey) != "",
-			"chat_history_limit":  backend.memory.maxMessages,
-			"llm_timeout_seconds": int(backend.timeout.Seconds()),
+			"status":               "ok",
+			"ts_ms":                time.Now().UnixMilli(),
+			"llm_model":            backend.model,
+			"openai_base_url":      backend.baseURL,
+			"has_openai_api_key":   strings.TrimSpace(backend.apiKey) != "",
+			"chat_history_limit":   backend.memory.maxMessages,
+			"llm_timeout_seconds":  int(backend.timeout.Seconds()),
+			"tool_timeout_seconds": int(backend.toolTimeout.Seconds()),
+			"tool_max_rounds":      backend.toolRounds,
+			"session_store":        backend.memory.store != nil,
+			"session_idle_ttl_s":   int(backend.memory.idleTTL.Seconds()),
+			"backend_mode":         backendMode(backend),
 		})
 	})
 	mux.HandleFunc("/ws/edge", handleEdgeWS(backend))
@@ -409,6 +556,7 @@ func handleEdgeWS(backend *llmBackend) http.HandlerFunc {
 		defer conn.Close()
 
 		var writeMu sync.Mutex
+		waiters := newToolWaiters()
 
 		conn.SetReadLimit(maxMessageLen)
 		_ = conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -420,64 +568,94 @@ func handleEdgeWS(backend *llmBackend) http.HandlerFunc {
 		ctx, cancel := context.WithCancel(r.Context())
 		defer cancel()
 
-		reqQueue := make(chan llmRequest, maxQueuedReqs)
+		reqQueue := newRequestQueue(maxQueuedReqs, backend.queueAging)
+		// unfinished collects requests cut off by the connection closing; they
+		// are kept for the session's next resume.
+		var unfinished []llmRequest
 		workerDone := make(chan struct{})
 		go func() {
 			defer close(workerDone)
 			for {
-				select {
-				case <-ctx.Done():
+				req, ok := reqQueue.pop(ctx)
+				if !ok {
 					return
-				case req, ok := <-reqQueue:
-					if !ok {
-						return
-					}
-					reqCtx, reqCancel := context.WithTimeout(ctx, backend.timeout)
-					reply, err := backend.streamReply(reqCtx, req, func(delta string) error {
+				}
+				cancelCtx, cancelReq := context.WithCancelCause(ctx)
+				reqQueue.setInflight(req.RequestID, cancelReq)
+				reqCtx, reqCancel := context.WithTimeout(cancelCtx, backend.timeout)
+				callTools := func(ctx context.Context, calls []openAIToolCall) ([]openAIMessage, error) {
+					return waiters.callTools(ctx, calls, backend.toolTimeout, func(call openAIToolCall) error {
 						return writeJSON(conn, &writeMu, llmResponse{
-							Type:      "llm_stream",
-							RequestID: req.RequestID,
-							SessionID: req.SessionID,
-							Emotion:   req.Emotion,
-							Event:     req.Event,
-							Final:     false,
-							Delta:     delta,
-							TsMS:      time.Now().UnixMilli(),
+							Type:       "llm_tool_call",
+							RequestID:  req.RequestID,
+							SessionID:  req.SessionID,
+							Final:      false,
+							ToolCallID: call.ID,
+							Name:       call.Function.Name,
+							Arguments:  toolArguments(call),
+							TsMS:       time.Now().UnixMilli(),
 						})
 					})
-					reqCancel()
-
-					if err != nil {
-						if err := writeJSON(conn, &writeMu, llmResponse{
-							Type:      "llm_error",
-							RequestID: req.RequestID,
-							SessionID: req.SessionID,
-							Emotion:   req.Emotion,
-							Event:     req.Event,
-							Final:     true,
-							Error:     err.Error(),
-							TsMS:      time.Now().UnixMilli(),
-						}); err != nil {
-							cancel()
-							return
-						}
-						continue
+				}
+				reply, skills, err := backend.reply(reqCtx, req, func(delta string) error {
+					return writeJSON(conn, &writeMu, llmResponse{
+						Type:      "llm_stream",
+						RequestID: req.RequestID,
+						SessionID: req.SessionID,
+						Emotion:   req.Emotion,
+						Event:     req.Event,
+						Final:     false,
+						Delta:     delta,
+						TsMS:      time.Now().UnixMilli(),
+					})
+				}, callTools)
+				reqCancel()
+				reqQueue.setInflight("", nil)
+				cancelled := errors.Is(context.Cause(cancelCtx), errRequestCancelled)
+				cancelReq(nil)
+
+				if err != nil && ctx.Err() != nil {
+					unfinished = append(unfinished, req)
+					return
+				}
+				if err != nil && cancelled {
+					if err := writeCancelled(conn, &writeMu, req); err != nil {
+						cancel()
+						return
 					}
-
+					continue
+				}
+				if err != nil {
 					if err := writeJSON(conn, &writeMu, llmResponse{
-						Type:      "llm_response",
+						Type:      "llm_error",
 						RequestID: req.RequestID,
 						SessionID: req.SessionID,
-						Text:      req.Text,
 						Emotion:   req.Emotion,
 						Event:     req.Event,
 						Final:     true,
-						Reply:     reply,
+						Error:     err.Error(),
 						TsMS:      time.Now().UnixMilli(),
 					}); err != nil {
 						cancel()
 						return
 					}
+					continue
+				}
+
+				if err := writeJSON(conn, &writeMu, llmResponse{
+					Type:           "llm_response",
+					RequestID:      req.RequestID,
+					SessionID:      req.SessionID,
+					Text:           req.Text,
+					Emotion:        req.Emotion,
+					Event:          req.Event,
+					Final:          true,
+					Reply:          reply,
+					TsMS:           time.Now().UnixMilli(),
+					ExecutedSkills: skills,
+				}); err != nil {
+					cancel()
+					return
 				}
 			}
 		}()
@@ -513,35 +691,136 @@ func handleEdgeWS(backend *llmBackend) http.HandlerFunc {
 			if err := json.Unmarshal(payload, &req); err != nil {
 				continue
 			}
+			if req.Type == "tool_result" {
+				// Answered by the worker waiting on it, never queued.
+				if !waiters.deliver(req) {
+					log.Printf("drop tool_result without pending call: tool_call_id=%s", req.ToolCallID)
+				}
+				continue
+			}
+			if req.Type == "cancel" {
+				if !cancelRequest(conn, &writeMu, reqQueue, req) {
+					cancel()
+					break readLoop
+				}
+				continue
+			}
+			if req.Type == "resume" {
+				if !resumeSession(conn, &writeMu, backend, req, reqQueue) {
+					cancel()
+					break readLoop
+				}
+				continue
+			}
 			if req.Type == "" {
 				req.Type = "llm_request"
 			}
 			if req.RequestID == "" {
 				req.RequestID = "req-" + strconv.FormatInt(time.Now().UnixMilli(), 10)
 			}
-			select {
-			case reqQueue <- req:
-			case <-ctx.Done():
+			if !enqueue(conn, &writeMu, reqQueue, req) {
+				cancel()
 				break readLoop
-			default:
-				if err := writeJSON(conn, &writeMu, llmResponse{
-					Type:      "llm_error",
-					RequestID: req.RequestID,
-					SessionID: req.SessionID,
-					Emotion:   req.Emotion,
-					Event:     req.Event,
-					Final:     true,
-					Error:     "too many pending llm requests",
-					TsMS:      time.Now().UnixMilli(),
-				}); err != nil {
-					cancel()
-					break readLoop
-				}
 			}
 		}
-		close(reqQueue)
+		reqQueue.close()
 		<-workerDone
+		unfinished = append(unfinished, reqQueue.drain()...)
+		backend.memory.savePending(unfinished)
+	}
+}
+
+// enqueue queues req and tells the edge about every request that lost its
+// place. It reports false when the socket write failed.
+func enqueue(conn *websocket.Conn, writeMu *sync.Mutex, reqQueue *requestQueue, req llmRequest) bool {
+	dropped, ok := reqQueue.push(req)
+	if !ok {
+		dropped = append(dropped, droppedRequest{req: req, reason: "too many pending llm requests"})
+	}
+	for _, d := range dropped {
+		if err := writeJSON(conn, writeMu, llmResponse{
+			Type:      "llm_error",
+			RequestID: d.req.RequestID,
+			SessionID: d.req.SessionID,
+			Emotion:   d.req.Emotion,
+			Event:     d.req.Event,
+			Final:     true,
+			Error:     d.reason,
+			TsMS:      time.Now().UnixMilli(),
+		}); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// cancelRequest revokes a queued or running request. A running request is
+// acknowledged by the worker once its stream has stopped.
+func cancelRequest(conn *websocket.Conn, writeMu *sync.Mutex, reqQueue *requestQueue, req llmRequest) bool {
+	queued, running := reqQueue.cancel(req.RequestID)
+	if running {
+		return true
+	}
+	if queued != nil {
+		return writeCancelled(conn, writeMu, *queued) == nil
+	}
+	return writeJSON(conn, writeMu, llmResponse{
+		Type:      "llm_error",
+		RequestID: req.RequestID,
+		SessionID: req.SessionID,
+		Final:     true,
+		Error:     "no pending request to cancel",
+		TsMS:      time.Now().UnixMilli(),
+	}) == nil
+}
+
+func writeCancelled(conn *websocket.Conn, writeMu *sync.Mutex, req llmRequest) error {
+	return writeJSON(conn, writeMu, llmResponse{
+		Type:      "llm_cancelled",
+		RequestID: req.RequestID,
+		SessionID: req.SessionID,
+		Emotion:   req.Emotion,
+		Event:     req.Event,
+		Final:     true,
+		TsMS:      time.Now().UnixMilli(),
+	})
+}
+
+// resumeSession replays the session's recent turns and re-queues requests a
+// previous connection left unanswered. It reports false when the socket
+// write failed.
+func resumeSession(conn *websocket.Conn, writeMu *sync.Mutex, backend *llmBackend, req llmRequest, reqQueue *requestQueue) bool {
+	if strings.TrimSpace(req.SessionID) == "" {
+		return writeJSON(conn, writeMu, llmResponse{
+			Type:  "llm_error",
+			Final: true,
+			Error: "resume requires session_id",
+			TsMS:  time.Now().UnixMilli(),
+		}) == nil
+	}
+	lastN := req.LastN
+	if lastN <= 0 || lastN > backend.resumeTurns {
+		lastN = backend.resumeTurns
+	}
+	pending := backend.memory.takePending(req.SessionID)
+	if err := writeJSON(conn, writeMu, llmResponse{
+		Type:      "resume_ack",
+		RequestID: req.RequestID,
+		SessionID: req.SessionID,
+		Final:     true,
+		Turns:     backend.memory.recentTurns(req.SessionID, lastN),
+		Pending:   len(pending),
+		TsMS:      time.Now().UnixMilli(),
+	}); err != nil {
+		backend.memory.savePending(pending)
+		return false
+	}
+	for _, p := range pending {
+		if !enqueue(conn, writeMu, reqQueue, p) {
+			return false
+		}
 	}
+	return true
 }
 
 func writeJSON(conn *websocket.Conn, mu *sync.Mutex, payload any) error {
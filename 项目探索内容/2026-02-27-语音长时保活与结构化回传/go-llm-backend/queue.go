@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	priorityPartial = 1
+	priorityFinal   = 2
+)
+
+var errRequestCancelled = errors.New("request cancelled by edge")
+
+// droppedRequest is a queued request removed before it ran.
+type droppedRequest struct {
+	req    llmRequest
+	reason string
+}
+
+type queuedRequest struct {
+	req      llmRequest
+	enqueued time.Time
+	seq      uint64
+}
+
+// requestQueue is the per-connection work queue. Voice finals run before
+// partials, waiting requests gain one priority level per aging interval so
+// partials are not starved, and a full queue evicts its least urgent entry
+// rather than refusing a more urgent one.
+type requestQueue struct {
+	mu     sync.Mutex
+	items  []*queuedRequest
+	max    int
+	aging  time.Duration
+	seq    uint64
+	closed bool
+	notify chan struct{}
+
+	inflightID     string
+	inflightCancel context.CancelCauseFunc
+}
+
+func newRequestQueue(limit int, aging time.Duration) *requestQueue {
+	if limit < 1 {
+		limit = 1
+	}
+	return &requestQueue{max: limit, aging: aging, notify: make(chan struct{}, 1)}
+}
+
+func basePriority(req llmRequest) int {
+	if req.Final {
+		return priorityFinal
+	}
+	return priorityPartial
+}
+
+func (q *requestQueue) effectivePriority(item *queuedRequest, now time.Time) int {
+	p := basePriority(item.req)
+	if q.aging > 0 {
+		p += int(now.Sub(item.enqueued) / q.aging)
+	}
+	return p
+}
+
+// push enqueues req. Queued partials of the same session are superseded by
+// it; when the queue is full the least urgent entry is evicted if req
+// outranks it, otherwise req itself is refused and ok is false.
+func (q *requestQueue) push(req llmRequest) (dropped []droppedRequest, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil, false
+	}
+	now := time.Now()
+
+	if req.SessionID != "" {
+		kept := q.items[:0]
+		for _, item := range q.items {
+			if item.req.SessionID == req.SessionID && !item.req.Final {
+				dropped = append(dropped, droppedRequest{req: item.req, reason: "superseded by newer request"})
+				continue
+			}
+			kept = append(kept, item)
+		}
+		q.items = kept
+	}
+
+	if len(q.items) >= q.max {
+		victim := -1
+		for i, item := range q.items {
+			if victim < 0 || q.effectivePriority(item, now) < q.effectivePriority(q.items[victim], now) {
+				victim = i
+			}
+		}
+		if q.effectivePriority(q.items[victim], now) >= basePriority(req) {
+			return dropped, false
+		}
+		dropped = append(dropped, droppedRequest{req: q.items[victim].req, reason: "evicted by higher priority request"})
+		q.items = append(q.items[:victim], q.items[victim+1:]...)
+	}
+
+	q.seq++
+	q.items = append(q.items, &queuedRequest{req: req, enqueued: now, seq: q.seq})
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return dropped, true
+}
+
+// pop blocks until a request is available and returns the most urgent one,
+// oldest first among equals. ok is false once the queue is closed or ctx is
+// done.
+func (q *requestQueue) pop(ctx context.Context) (llmRequest, bool) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return llmRequest{}, false
+		}
+		if len(q.items) > 0 {
+			now := time.Now()
+			best := 0
+			for i, item := range q.items[1:] {
+				pi, pb := q.effectivePriority(item, now), q.effectivePriority(q.items[best], now)
+				if pi > pb || (pi == pb && item.seq < q.items[best].seq) {
+					best = i + 1
+				}
+			}
+			req := q.items[best].req
+			q.items = append(q.items[:best], q.items[best+1:]...)
+			q.mu.Unlock()
+			return req, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return llmRequest{}, false
+		}
+	}
+}
+
+// cancel revokes requestID: a queued request is removed and returned, a
+// running one has its context cancelled with errRequestCancelled.
+func (q *requestQueue) cancel(requestID string) (queued *llmRequest, running bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, item := range q.items {
+		if item.req.RequestID == requestID {
+			req := item.req
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return &req, false
+		}
+	}
+	if q.inflightID == requestID && q.inflightCancel != nil {
+		q.inflightCancel(errRequestCancelled)
+		return nil, true
+	}
+	return nil, false
+}
+
+func (q *requestQueue) setInflight(requestID string, cancel context.CancelCauseFunc) {
+	q.mu.Lock()
+	q.inflightID, q.inflightCancel = requestID, cancel
+	q.mu.Unlock()
+}
+
+func (q *requestQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain returns the requests still queued, in arrival order.
+func (q *requestQueue) drain() []llmRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]llmRequest, 0, len(q.items))
+	for _, item := range q.items {
+		out = append(out, item.req)
+	}
+	q.items = nil
+	return out
+}
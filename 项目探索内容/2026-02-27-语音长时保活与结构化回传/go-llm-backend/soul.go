@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// soulClient forwards edge requests to soul-server /v1/chat, which adds
+// memory, persona and skills on top of the LLM.
+type soulClient struct {
+	client        *http.Client
+	baseURL       string
+	terminalID    string
+	soulID        string
+	userID        string
+	sessionPrefix string
+}
+
+type soulChatInput struct {
+	Type   string          `json:"type"`
+	Source string          `json:"source,omitempty"`
+	TS     string          `json:"ts,omitempty"`
+	Text   string          `json:"text"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+type soulChatRequest struct {
+	UserID     string          `json:"user_id,omitempty"`
+	SessionID  string          `json:"session_id"`
+	TerminalID string          `json:"terminal_id"`
+	SoulID     string          `json:"soul_id,omitempty"`
+	Inputs     []soulChatInput `json:"inputs"`
+}
+
+type soulChatResponse struct {
+	SessionID      string   `json:"session_id"`
+	Reply          string   `json:"reply"`
+	ExecutedSkills []string `json:"executed_skills,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
+
+func newSoulClientFromEnv(timeout time.Duration) *soulClient {
+	return &soulClient{
+		client:        &http.Client{Timeout: timeout},
+		baseURL:       strings.TrimRight(getEnvString("SOUL_API_BASE_URL", "http://localhost:9010"), "/"),
+		terminalID:    getEnvString("SOUL_TERMINAL_ID", "edge-voice"),
+		soulID:        strings.TrimSpace(os.Getenv("SOUL_ID")),
+		userID:        strings.TrimSpace(os.Getenv("SOUL_USER_ID")),
+		sessionPrefix: getEnvString("SOUL_SESSION_PREFIX", "edge-"),
+	}
+}
+
+// chat maps the edge session onto a Soul session under the configured
+// terminal and returns Soul's reply.
+func (c *soulClient) chat(ctx context.Context, req llmRequest) (soulChatResponse, error) {
+	meta, _ := json.Marshal(map[string]any{"emotion": req.Emotion, "event": req.Event, "final": req.Final})
+	ts := time.Now()
+	if req.TsMS > 0 {
+		ts = time.UnixMilli(req.TsMS)
+	}
+	terminalID := c.terminalID
+	if strings.TrimSpace(req.TerminalID) != "" {
+		terminalID = strings.TrimSpace(req.TerminalID)
+	}
+	body, err := json.Marshal(soulChatRequest{
+		UserID:     c.userID,
+		SessionID:  c.sessionPrefix + req.SessionID,
+		TerminalID: terminalID,
+		SoulID:     c.soulID,
+		Inputs: []soulChatInput{{
+			Type:   "speech_text",
+			Source: "edge",
+			TS:     ts.UTC().Format(time.RFC3339Nano),
+			Text:   strings.TrimSpace(req.Text),
+			Data:   meta,
+		}},
+	})
+	if err != nil {
+		return soulChatResponse{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/chat", bytes.NewReader(body))
+	if err != nil {
+		return soulChatResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return soulChatResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 2*1024*1024))
+	if err != nil {
+		return soulChatResponse{}, err
+	}
+	var parsed soulChatResponse
+	if err := json.Unmarshal(raw, &parsed); err != nil && resp.StatusCode < 300 {
+		return soulChatResponse{}, fmt.Errorf("invalid soul response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		msg := parsed.Error
+		if msg == "" {
+			msg = strings.TrimSpace(string(raw))
+		}
+		return soulChatResponse{}, fmt.Errorf("soul status %d: %s", resp.StatusCode, msg)
+	}
+	return parsed, nil
+}
+
+// reply answers req through Soul when configured, falling back to the direct
+// LLM path if Soul fails and fallback is enabled. It returns the skills Soul
+// executed, if any.
+func (b *llmBackend) reply(ctx context.Context, req llmRequest, onDelta func(string) error, callTools toolCaller) (string, []string, error) {
+	if b.soul == nil {
+		reply, err := b.streamReply(ctx, req, onDelta, callTools)
+		return reply, nil, err
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		return "", nil, fmt.Errorf("empty text")
+	}
+
+	resp, err := b.soul.chat(ctx, req)
+	if err != nil {
+		if !b.soulFallback || ctx.Err() != nil {
+			return "", nil, err
+		}
+		log.Printf("soul chat failed, fallback to direct llm: session_id=%s request_id=%s err=%v", req.SessionID, req.RequestID, err)
+		reply, err := b.streamReply(ctx, req, onDelta, callTools)
+		return reply, nil, err
+	}
+	if resp.Reply != "" && onDelta != nil {
+		if err := onDelta(resp.Reply); err != nil {
+			return "", nil, err
+		}
+	}
+	// Keep the local window in step so a fallback turn still has context.
+	b.memory.appendTurn(req.SessionID, []openAIMessage{
+		{Role: "user", Content: formatUserInput(req)},
+		{Role: "assistant", Content: resp.Reply},
+	})
+	return resp.Reply, resp.ExecutedSkills, nil
+}
+
+func backendMode(b *llmBackend) string {
+	if b.soul != nil {
+		return "soul"
+	}
+	return "direct"
+}
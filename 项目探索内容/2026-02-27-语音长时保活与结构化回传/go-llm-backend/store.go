@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	sessionsBucket = []byte("sessions")
+	pendingBucket  = []byte("pending")
+)
+
+// sessionRecord is the persisted form of one session's history.
+type sessionRecord struct {
+	Messages  []openAIMessage `json:"messages"`
+	UpdatedMS int64           `json:"updated_ms"`
+}
+
+// sessionStore persists histories and unfinished requests in a bbolt file so
+// a restart or reconnect keeps context and queued work.
+type sessionStore struct {
+	db *bolt.DB
+}
+
+func openSessionStore(path string) (*sessionStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sessionsBucket, pendingBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &sessionStore{db: db}, nil
+}
+
+func (s *sessionStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *sessionStore) loadSession(sessionID string) (sessionRecord, bool, error) {
+	var rec sessionRecord
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(sessionsBucket).Get([]byte(sessionID))
+		if raw == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(raw, &rec)
+	})
+	return rec, found, err
+}
+
+func (s *sessionStore) saveSession(sessionID string, rec sessionRecord) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(sessionID), raw)
+	})
+}
+
+// savePending appends reqs to the session's unfinished requests.
+func (s *sessionStore) savePending(sessionID string, reqs []llmRequest) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pendingBucket)
+		var existing []llmRequest
+		if raw := b.Get([]byte(sessionID)); raw != nil {
+			if err := json.Unmarshal(raw, &existing); err != nil {
+				return err
+			}
+		}
+		raw, err := json.Marshal(append(existing, reqs...))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(sessionID), raw)
+	})
+}
+
+// takePending returns and removes the session's unfinished requests.
+func (s *sessionStore) takePending(sessionID string) ([]llmRequest, error) {
+	var reqs []llmRequest
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(pendingBucket)
+		raw := b.Get([]byte(sessionID))
+		if raw == nil {
+			return nil
+		}
+		if err := json.Unmarshal(raw, &reqs); err != nil {
+			return err
+		}
+		return b.Delete([]byte(sessionID))
+	})
+	return reqs, err
+}
+
+// expireBefore deletes sessions, and their pending requests, last updated
+// before cutoff and returns their ids.
+func (s *sessionStore) expireBefore(cutoff time.Time) ([]string, error) {
+	var expired []string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(sessionsBucket)
+		c := sessions.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var rec sessionRecord
+			if err := json.Unmarshal(v, &rec); err == nil && rec.UpdatedMS >= cutoff.UnixMilli() {
+				continue
+			}
+			expired = append(expired, string(k))
+		}
+		for _, id := range expired {
+			if err := sessions.Delete([]byte(id)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(pendingBucket).Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return expired, err
+}
+
+// resumeTurn is one user/assistant exchange replayed on resume.
+type resumeTurn struct {
+	Text  string `json:"text"`
+	Reply string `json:"reply"`
+}
+
+// recentTurns returns up to n exchanges, oldest first. Tool rounds are folded
+// into the exchange they belong to.
+func (m *sessionMemory) recentTurns(sessionID string, n int) []resumeTurn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var turns []resumeTurn
+	for _, msg := range m.load(sessionID) {
+		switch {
+		case msg.Role == "user":
+			turns = append(turns, resumeTurn{Text: msg.Content})
+		case msg.Role == "assistant" && len(msg.ToolCalls) == 0 && len(turns) > 0:
+			turns[len(turns)-1].Reply = msg.Content
+		}
+	}
+	if n > 0 && len(turns) > n {
+		turns = turns[len(turns)-n:]
+	}
+	return turns
+}
+
+// savePending keeps requests a closed connection never answered, grouped by
+// session, until the edge resumes that session.
+func (m *sessionMemory) savePending(reqs []llmRequest) {
+	bySession := make(map[string][]llmRequest)
+	for _, req := range reqs {
+		if req.SessionID != "" {
+			bySession[req.SessionID] = append(bySession[req.SessionID], req)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sessionID, items := range bySession {
+		m.updated[sessionID] = time.Now()
+		if m.store == nil {
+			m.pending[sessionID] = append(m.pending[sessionID], items...)
+			continue
+		}
+		// Touch the session so its pending work expires with it.
+		rec := sessionRecord{Messages: m.load(sessionID), UpdatedMS: time.Now().UnixMilli()}
+		if err := m.store.saveSession(sessionID, rec); err != nil {
+			log.Printf("save session failed: session_id=%s err=%v", sessionID, err)
+		}
+		if err := m.store.savePending(sessionID, items); err != nil {
+			log.Printf("save pending failed: session_id=%s err=%v", sessionID, err)
+		}
+	}
+}
+
+func (m *sessionMemory) takePending(sessionID string) []llmRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.store == nil {
+		reqs := m.pending[sessionID]
+		delete(m.pending, sessionID)
+		return reqs
+	}
+	reqs, err := m.store.takePending(sessionID)
+	if err != nil {
+		log.Printf("take pending failed: session_id=%s err=%v", sessionID, err)
+	}
+	return reqs
+}
+
+// expireIdle drops sessions untouched for idleTTL from memory and the store.
+func (m *sessionMemory) expireIdle(now time.Time) int {
+	if m.idleTTL <= 0 {
+		return 0
+	}
+	cutoff := now.Add(-m.idleTTL)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expired := make(map[string]struct{})
+	for sessionID, at := range m.updated {
+		if at.Before(cutoff) {
+			expired[sessionID] = struct{}{}
+		}
+	}
+	if m.store != nil {
+		ids, err := m.store.expireBefore(cutoff)
+		if err != nil {
+			log.Printf("expire sessions failed: %v", err)
+		}
+		for _, id := range ids {
+			expired[id] = struct{}{}
+		}
+	}
+	for sessionID := range expired {
+		delete(m.history, sessionID)
+		delete(m.updated, sessionID)
+		delete(m.pending, sessionID)
+	}
+	return len(expired)
+}
+
+func (m *sessionMemory) runExpiry(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if n := m.expireIdle(now); n > 0 {
+				log.Printf("expired idle sessions: count=%d", n)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
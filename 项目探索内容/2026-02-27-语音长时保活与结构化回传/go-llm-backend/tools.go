@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// edgeTool is a local action the edge device offers to the LLM.
+type edgeTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAIToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// toolCaller sends tool calls to the edge and returns one tool message per
+// call, in order.
+type toolCaller func(ctx context.Context, calls []openAIToolCall) ([]openAIMessage, error)
+
+func buildOpenAITools(tools []edgeTool) []openAITool {
+	out := make([]openAITool, 0, len(tools))
+	for _, t := range tools {
+		name := strings.TrimSpace(t.Name)
+		if name == "" {
+			continue
+		}
+		params := t.Parameters
+		if len(params) == 0 || !json.Valid(params) {
+			params = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		out = append(out, openAITool{
+			Type:     "function",
+			Function: openAIFunction{Name: name, Description: t.Description, Parameters: params},
+		})
+	}
+	return out
+}
+
+// toolCallAccumulator rebuilds complete tool calls from streamed deltas, where
+// id and name arrive once and arguments arrive in fragments.
+type toolCallAccumulator struct {
+	calls map[int]*openAIToolCall
+}
+
+func (a *toolCallAccumulator) add(deltas []openAIToolCallDelta) {
+	if a.calls == nil {
+		a.calls = make(map[int]*openAIToolCall)
+	}
+	for _, d := range deltas {
+		call, ok := a.calls[d.Index]
+		if !ok {
+			call = &openAIToolCall{Type: "function"}
+			a.calls[d.Index] = call
+		}
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		if d.Function.Name != "" {
+			call.Function.Name = d.Function.Name
+		}
+		call.Function.Arguments += d.Function.Arguments
+	}
+}
+
+func (a *toolCallAccumulator) result() []openAIToolCall {
+	indexes := make([]int, 0, len(a.calls))
+	for i := range a.calls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	out := make([]openAIToolCall, 0, len(indexes))
+	for _, i := range indexes {
+		call := *a.calls[i]
+		if call.ID == "" {
+			call.ID = fmt.Sprintf("call_%d", i)
+		}
+		out = append(out, call)
+	}
+	return out
+}
+
+// toolArguments returns the call arguments as JSON for the edge; models
+// occasionally emit broken JSON, which is passed through as a string.
+func toolArguments(call openAIToolCall) json.RawMessage {
+	args := strings.TrimSpace(call.Function.Arguments)
+	if args == "" {
+		return json.RawMessage(`{}`)
+	}
+	if json.Valid([]byte(args)) {
+		return json.RawMessage(args)
+	}
+	raw, _ := json.Marshal(args)
+	return raw
+}
+
+// toolResultContent turns an edge tool_result into the tool message content.
+func toolResultContent(res llmRequest) string {
+	if res.Error != "" {
+		raw, _ := json.Marshal(map[string]string{"error": res.Error})
+		return string(raw)
+	}
+	trimmed := strings.TrimSpace(string(res.Result))
+	if trimmed == "" || trimmed == "null" {
+		return `{"ok":true}`
+	}
+	var s string
+	if err := json.Unmarshal(res.Result, &s); err == nil {
+		return s
+	}
+	return trimmed
+}
+
+// toolWaiters routes tool_result messages from the read loop to the request
+// worker blocked on them.
+type toolWaiters struct {
+	mu      sync.Mutex
+	waiters map[string]chan llmRequest
+}
+
+func newToolWaiters() *toolWaiters {
+	return &toolWaiters{waiters: make(map[string]chan llmRequest)}
+}
+
+func (w *toolWaiters) register(id string) chan llmRequest {
+	ch := make(chan llmRequest, 1)
+	w.mu.Lock()
+	w.waiters[id] = ch
+	w.mu.Unlock()
+	return ch
+}
+
+func (w *toolWaiters) unregister(id string) {
+	w.mu.Lock()
+	delete(w.waiters, id)
+	w.mu.Unlock()
+}
+
+func (w *toolWaiters) deliver(res llmRequest) bool {
+	w.mu.Lock()
+	ch, ok := w.waiters[res.ToolCallID]
+	delete(w.waiters, res.ToolCallID)
+	w.mu.Unlock()
+	if !ok {
+		return false
+	}
+	ch <- res
+	return true
+}
+
+// callTools sends each call through send and waits for every result, giving
+// up after timeout so a silent edge cannot stall the connection's queue.
+func (w *toolWaiters) callTools(ctx context.Context, calls []openAIToolCall, timeout time.Duration, send func(openAIToolCall) error) ([]openAIMessage, error) {
+	chans := make([]chan llmRequest, len(calls))
+	for i, call := range calls {
+		chans[i] = w.register(call.ID)
+	}
+	defer func() {
+		for _, call := range calls {
+			w.unregister(call.ID)
+		}
+	}()
+	for _, call := range calls {
+		if err := send(call); err != nil {
+			return nil, err
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	out := make([]openAIMessage, 0, len(calls))
+	for i, call := range calls {
+		select {
+		case res := <-chans[i]:
+			out = append(out, openAIMessage{Role: "tool", ToolCallID: call.ID, Content: toolResultContent(res)})
+		case <-timer.C:
+			return nil, fmt.Errorf("tool result timeout: %s", call.Function.Name)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return out, nil
+}